@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+var (
+	// accounting counts the provider requests and bytes of this run, for cost attribution
+	accounting = objectstore.NewAccounting()
+	// accountingReported is set once the summary was included in the command's JSON output
+	accountingReported bool
+	// runningCmd is the command being run, whose --quiet flag silences the summary
+	runningCmd *cobra.Command
+)
+
+// exitCode is the status a command ends the process with through exit
+type exitCode int
+
+// exit ends the running command with the given status
+// It unwinds to execute rather than calling os.Exit, so the accounting summary is still
+// printed for commands that fail after making provider requests.
+func exit(code int) {
+	panic(exitCode(code))
+}
+
+// printJSON writes v to stdout as indented JSON
+// When the run made provider requests and v is a JSON object, the accounting summary is
+// added to it as "accounting" and not printed again at the end of the command.
+func printJSON(v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if summary := accounting.Summary(); summary.Requests > 0 && len(body) >= 2 && body[0] == '{' {
+		extra, err := json.Marshal(summary)
+		if err != nil {
+			return err
+		}
+		separator := ","
+		if string(body) == "{}" {
+			separator = ""
+		}
+		body = append(body[:len(body)-1], []byte(separator+`"accounting":`+string(extra)+"}")...)
+		accountingReported = true
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, body, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err = out.WriteTo(os.Stdout)
+	return err
+}
+
+// printAccountingSummary prints the requests and bytes of the run to stderr
+// Nothing is printed with --quiet, when the run made no provider requests, or when the
+// summary was already part of the command's JSON output.
+func printAccountingSummary() {
+	if runningCmd != nil {
+		if quiet, _ := runningCmd.Flags().GetBool("quiet"); quiet {
+			return
+		}
+	}
+	if accountingReported {
+		return
+	}
+	summary := accounting.Summary()
+	if summary.Requests == 0 {
+		return
+	}
+	writeAccountingSummary(os.Stderr, summary)
+}
+
+// writeAccountingSummary writes totals, then one line per provider when more than one was used
+func writeAccountingSummary(w io.Writer, summary objectstore.AccountingSummary) {
+	fmt.Fprintf(w, "Transferred %s up, %s down in %s\n", formatBytes(summary.BytesUploaded), formatBytes(summary.BytesDownloaded), formatRequests(summary.TransferCounts))
+	if len(summary.Providers) < 2 {
+		return
+	}
+	providers := make([]string, 0, len(summary.Providers))
+	for provider := range summary.Providers {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	for _, provider := range providers {
+		counts := summary.Providers[provider]
+		fmt.Fprintf(w, "  %s: %s up, %s down in %s\n", provider, formatBytes(counts.BytesUploaded), formatBytes(counts.BytesDownloaded), formatRequests(counts))
+	}
+}
+
+// formatRequests prints a request count with its breakdown by kind, e.g. "9 requests (6 upload, 3 head)"
+func formatRequests(counts objectstore.TransferCounts) string {
+	var kinds []string
+	for _, kind := range []objectstore.RequestKind{objectstore.RequestUpload, objectstore.RequestDownload, objectstore.RequestDelete, objectstore.RequestHead, objectstore.RequestList} {
+		if n := counts.ByKind[kind]; n > 0 {
+			kinds = append(kinds, fmt.Sprintf("%d %s", n, kind))
+		}
+	}
+	noun := "requests"
+	if counts.Requests == 1 {
+		noun = "request"
+	}
+	return fmt.Sprintf("%d %s (%s)", counts.Requests, noun, strings.Join(kinds, ", "))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive [directory] [zs://bucket/prefix/archive.tar.zst]",
+	Short: "Store a directory as one compressed, erasure-coded archive (destination optional - uses <directory>.tar.zst)",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := args[0]
+
+		key := filepath.Base(filepath.Clean(dir)) + ".tar.zst"
+		if len(args) == 2 {
+			var err error
+			key, err = parseZsURL(args[1])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			if strings.HasSuffix(key, "/") {
+				key += filepath.Base(filepath.Clean(dir)) + ".tar.zst"
+			}
+		}
+
+		dataShards, _ := cmd.Flags().GetInt("data-shards")
+		parityShards, _ := cmd.Flags().GetInt("parity-shards")
+		if err := service.ValidateShardConfig(dataShards, parityShards); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		quiet, _ := cmd.Flags().GetBool("quiet")
+		concurrency := applyConcurrency(cmd)
+		mirrorFactor, _ := cmd.Flags().GetInt("mirror-factor")
+		fileService.SetMirrorFactor(mirrorFactor)
+		if err := applyCustomerKey(cmd); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if err := applyRetention(cmd); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		members, err := fileService.ArchiveDir(context.Background(), dir, key, quiet, dataShards, parityShards, concurrency)
+		if err != nil {
+			fmt.Printf("Error archiving directory: %v\n", err)
+			exit(1)
+		}
+		fmt.Printf("Directory archived successfully: %s -> %s (%d files)\n", dir, key, len(members))
+	},
+}
+
+var extractCmd = &cobra.Command{
+	Use:   "extract [zs://bucket/prefix/archive.tar.zst] [directory | member output-path]",
+	Short: "Recreate an archive's files under a directory, or extract a single member to a file",
+	Args:  cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := parseZsURL(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		verifyIntegrity, _ := cmd.Flags().GetBool("verify-integrity")
+		if err := applyCustomerKey(cmd); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		if len(args) == 3 {
+			extractMember(key, args[1], args[2], verifyIntegrity)
+			return
+		}
+
+		destDir := args[1]
+		if err := os.MkdirAll(destDir, 0755); err != nil {
+			fmt.Printf("Error creating output directory: %v\n", err)
+			return
+		}
+
+		extracted, err := fileService.ExtractArchive(context.Background(), key, destDir, verifyIntegrity)
+		if err != nil {
+			fmt.Printf("Error extracting archive after %d file(s): %v\n", len(extracted), err)
+			exit(1)
+		}
+		fmt.Printf("Archive extracted successfully: %s -> %s (%d files)\n", key, destDir, len(extracted))
+	},
+}
+
+// extractMember writes one member of an archive to outputPath, removing the file if extraction fails
+func extractMember(key, member, outputPath string, verifyIntegrity bool) {
+	if stat, err := os.Stat(outputPath); err == nil && stat.IsDir() {
+		outputPath = filepath.Join(outputPath, filepath.Base(filepath.FromSlash(member)))
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		fmt.Printf("Error creating output directory: %v\n", err)
+		return
+	}
+
+	outFile, err := os.Create(outputPath)
+	if err != nil {
+		fmt.Printf("Error creating output file: %v\n", err)
+		return
+	}
+	err = fileService.ExtractArchiveMember(context.Background(), key, member, outFile, verifyIntegrity)
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outputPath)
+		fmt.Printf("Error extracting %s: %v\n", member, err)
+		exit(1)
+	}
+	fmt.Printf("Member extracted successfully: %s:%s -> %s\n", key, member, outputPath)
+}
+
+func init() {
+	archiveCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress progress bars")
+	archiveCmd.Flags().Int("data-shards", 4, "Number of data shards for erasure coding")
+	archiveCmd.Flags().Int("parity-shards", 2, "Number of parity shards for erasure coding")
+	archiveCmd.Flags().Int("concurrency", 3, "Number of concurrent shard uploads")
+	archiveCmd.Flags().Int("upload-concurrency", 0, "Number of concurrent shard uploads (default: --concurrency)")
+	archiveCmd.Flags().Int("mirror-factor", 1, "Number of copies of each shard, each stored in a different bucket")
+	archiveCmd.Flags().String("sse-customer-key", "", "Base64 256-bit key for provider-side encryption (S3 SSE-C, GCS CSEK); never stored, required again to extract")
+	archiveCmd.Flags().String("retention-mode", "", "Lock uploaded shards in governance or compliance mode (default: retention_mode from config)")
+	archiveCmd.Flags().String("retain-until", "", "End of the lock as an RFC 3339 date (default: now + retention_period from config)")
+	extractCmd.Flags().Bool("verify-integrity", false, "Verify shard integrity using CRC64 hashes")
+	extractCmd.Flags().String("sse-customer-key", "", "Base64 256-bit key the archive was uploaded with")
+	rootCmd.AddCommand(archiveCmd)
+	rootCmd.AddCommand(extractCmd)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure upload/download throughput and latency against the configured buckets",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		sizeFlag, _ := cmd.Flags().GetString("size")
+		size, err := parseByteSize(sizeFlag)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		iterations, _ := cmd.Flags().GetInt("iterations")
+		dataShards, _ := cmd.Flags().GetInt("data-shards")
+		parityShards, _ := cmd.Flags().GetInt("parity-shards")
+		uploadConcurrency := applyConcurrency(cmd)
+		prefix, _ := cmd.Flags().GetString("prefix")
+		var alignment int64
+		switch alignmentFlag, _ := cmd.Flags().GetString("shard-alignment"); alignmentFlag {
+		case "":
+		case "0", "none":
+			alignment = -1
+		default:
+			if alignment, err = parseByteSize(alignmentFlag); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+		}
+
+		fmt.Printf("Benchmarking %s objects, %d iterations, %d data + %d parity shards\n", formatBytes(size), iterations, dataShards, parityShards)
+		report, err := fileService.Bench(context.Background(), service.BenchOptions{
+			Size:              size,
+			Iterations:        iterations,
+			DataShards:        dataShards,
+			ParityShards:      parityShards,
+			UploadConcurrency: uploadConcurrency,
+			ShardAlignment:    alignment,
+			Prefix:            strings.Trim(strings.TrimPrefix(prefix, "zs://"), "/"),
+		})
+		if err != nil {
+			fmt.Printf("Benchmark failed: %v\n", err)
+			exit(1)
+		}
+		printBenchReport(report)
+	},
+}
+
+// printBenchReport prints object-level throughput and latencies followed by per-shard timings
+func printBenchReport(report service.BenchReport) {
+	fmt.Printf("Concurrency: %d shard uploads, %d shard downloads\n", report.Options.UploadConcurrency, report.Options.DownloadConcurrency)
+	if report.Options.ShardAlignment > 0 {
+		fmt.Printf("Shard alignment: %s\n", formatBytes(report.Options.ShardAlignment))
+	} else {
+		fmt.Printf("Shard alignment: none\n")
+	}
+	fmt.Printf("\n%-9s %10s %10s %10s %10s %10s\n", "", "MB/s", "p50", "p95", "min", "max")
+	for _, row := range []struct {
+		name  string
+		stats service.LatencyStats
+	}{{"Upload", report.Upload}, {"Download", report.Download}} {
+		fmt.Printf("%-9s %10.2f %10s %10s %10s %10s\n", row.name, row.stats.MBps,
+			formatLatency(row.stats.P50), formatLatency(row.stats.P95), formatLatency(row.stats.Min), formatLatency(row.stats.Max))
+	}
+
+	fmt.Printf("\nPer-shard timings:\n")
+	fmt.Printf("%-6s %-20s %12s %12s %12s %12s %9s\n", "SHARD", "BUCKET", "UP p50", "UP p95", "DOWN p50", "DOWN p95", "FAILURES")
+	for _, shard := range report.Shards {
+		fmt.Printf("%-6d %-20s %12s %12s %12s %12s %9d\n", shard.Index, shard.BucketName,
+			formatLatency(shard.Upload.P50), formatLatency(shard.Upload.P95),
+			formatLatency(shard.Download.P50), formatLatency(shard.Download.P95), shard.Failures)
+	}
+	fmt.Printf("\nShards that were not needed for reconstruction have fewer download samples.\n")
+}
+
+// formatLatency prints a latency rounded to milliseconds, or "-" when nothing was measured
+func formatLatency(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// parseByteSize parses sizes such as "100MB", "512KB" or "1GB" (binary units) into bytes
+func parseByteSize(value string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+
+	trimmed := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(trimmed, unit.suffix) {
+			trimmed = strings.TrimSpace(strings.TrimSuffix(trimmed, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(trimmed, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. 100MB, 512KB, 1GB)", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// formatBytes prints a byte count using the largest binary unit that fits
+func formatBytes(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%.1fGB", float64(size)/(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+func init() {
+	benchCmd.Flags().String("size", "100MB", "Size of each benchmark object (e.g. 512KB, 100MB, 1GB)")
+	benchCmd.Flags().Int("iterations", 5, "Number of upload/download round trips")
+	benchCmd.Flags().Int("data-shards", 4, "Number of data shards for erasure coding")
+	benchCmd.Flags().Int("parity-shards", 2, "Number of parity shards for erasure coding")
+	benchCmd.Flags().Int("concurrency", 3, "Number of concurrent shard transfers")
+	benchCmd.Flags().Int("upload-concurrency", 0, "Number of concurrent shard uploads (default: --concurrency)")
+	benchCmd.Flags().Int("download-concurrency", 0, "Number of concurrent shard downloads (default: --concurrency)")
+	benchCmd.Flags().String("shard-alignment", "", "Pad shards to a multiple of this size for the run (e.g. 4KB; 0 disables; default: shard_alignment)")
+	benchCmd.Flags().String("prefix", "zstore-bench", "Key prefix for the temporary benchmark objects")
+	rootCmd.AddCommand(benchCmd)
+}
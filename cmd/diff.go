@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [zs://bucket/prefix/object-a] [zs://bucket/prefix/object-b]",
+	Short: "Check whether two objects are identical from their metadata, without downloading them",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		keyA, err := parseZsURL(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(2)
+		}
+		keyB, err := parseZsURL(args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(2)
+		}
+
+		comparison, err := fileService.CompareFiles(context.Background(), keyA, keyB)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error comparing objects: %v\n", err)
+			exit(2)
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			if err := printJSON(comparison); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(2)
+			}
+		} else {
+			printComparison(comparison)
+		}
+
+		switch comparison.Verdict {
+		case service.ObjectsDifferent:
+			exit(1)
+		case service.ObjectsUnknown:
+			exit(2)
+		}
+	},
+}
+
+// printComparison prints both objects' size and hash followed by the verdict
+func printComparison(comparison service.ObjectComparison) {
+	for _, object := range []struct {
+		key  string
+		size int64
+		hash string
+	}{{comparison.KeyA, comparison.SizeA, comparison.HashA}, {comparison.KeyB, comparison.SizeB, comparison.HashB}} {
+		hash := object.hash
+		if hash == "" {
+			hash = "-"
+		}
+		fmt.Printf("%s\t%d bytes\tcrc64 %s\n", object.key, object.size, hash)
+	}
+
+	switch comparison.Verdict {
+	case service.ObjectsIdentical:
+		fmt.Printf("IDENTICAL (by %s)\n", comparison.Basis)
+	case service.ObjectsDifferent:
+		fmt.Printf("DIFFERENT (by %s)\n", comparison.Basis)
+	default:
+		fmt.Printf("UNKNOWN: %s\n", comparison.Basis)
+	}
+}
+
+func init() {
+	diffCmd.Flags().Bool("json", false, "Print the comparison as JSON")
+	rootCmd.AddCommand(diffCmd)
+}
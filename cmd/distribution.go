@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var distributionCmd = &cobra.Command{
+	Use:   "distribution [zs://bucket/prefix]",
+	Short: "Show how many shards and bytes each bucket holds for the objects under a prefix",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		prefix := "."
+		if len(args) == 1 {
+			var err error
+			prefix, err = parseZsURL(args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			prefix = strings.TrimSuffix(prefix, "/")
+			if prefix == "" {
+				prefix = "."
+			}
+		}
+
+		distribution, err := fileService.Distribution(context.Background(), prefix)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error computing distribution for %s: %v\n", prefix, err)
+			exit(1)
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			if err := printJSON(distribution); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "BUCKET\tPROVIDER\tSHARDS\tBYTES\tSHARE")
+		for _, bucket := range distribution.Buckets {
+			name := bucket.Bucket
+			if bucket.Unregistered {
+				name += " (unregistered)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%.1f%%\n", name, bucket.StorageType, bucket.Shards, formatBytes(bucket.Bytes), bucket.Percent)
+		}
+		w.Flush()
+
+		if len(distribution.Providers) > 1 {
+			fmt.Println()
+			w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "PROVIDER\tBUCKETS\tSHARDS\tBYTES\tSHARE")
+			for _, provider := range distribution.Providers {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%.1f%%\n", provider.StorageType, provider.Buckets, provider.Shards, formatBytes(provider.Bytes), provider.Percent)
+			}
+			w.Flush()
+		}
+		fmt.Printf("\n%d objects, %d shards, %s stored\n", distribution.Objects, distribution.Shards, formatBytes(distribution.Bytes))
+	},
+}
+
+func init() {
+	distributionCmd.Flags().Bool("json", false, "Print the distribution as JSON")
+	rootCmd.AddCommand(distributionCmd)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var drRestoreCmd = &cobra.Command{
+	Use:   "dr-restore [zs://bucket/prefix/object] [output-path]",
+	Short: "Download a file, restoring it from its DR copy if erasure reconstruction fails",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		zsURL, outputPath := args[0], args[1]
+		key, err := parseZsURL(zsURL)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		quiet, _ := cmd.Flags().GetBool("quiet")
+		verifyIntegrity, _ := cmd.Flags().GetBool("verify-integrity")
+		drOnly, _ := cmd.Flags().GetBool("dr-only")
+
+		if stat, err := os.Stat(outputPath); err == nil && stat.IsDir() {
+			outputPath = filepath.Join(outputPath, downloadFileName(key))
+		}
+		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+			fmt.Printf("Error creating output directory: %v\n", err)
+			return
+		}
+		outFile, err := os.Create(outputPath)
+		if err != nil {
+			fmt.Printf("Error creating output file: %v\n", err)
+			return
+		}
+		defer outFile.Close()
+
+		fromDR := drOnly
+		if drOnly {
+			err = fileService.DownloadFromDR(context.Background(), key, outFile, quiet)
+		} else {
+			fromDR, err = fileService.RestoreWithDRFallback(context.Background(), key, outFile, quiet, verifyIntegrity)
+		}
+		if err != nil {
+			fmt.Printf("Error restoring file: %v\n", err)
+			exit(1)
+		}
+
+		if fromDR {
+			fmt.Printf("File restored from its DR copy: %s -> %s\n", key, outputPath)
+			return
+		}
+		fmt.Printf("File downloaded successfully: %s -> %s\n", key, outputPath)
+	},
+}
+
+func init() {
+	drRestoreCmd.Flags().BoolP("quiet", "q", false, "Suppress progress bars")
+	drRestoreCmd.Flags().Bool("verify-integrity", false, "Verify shard integrity using CRC64 hashes")
+	drRestoreCmd.Flags().Bool("dr-only", false, "Read the DR copy without trying erasure reconstruction first")
+	rootCmd.AddCommand(drRestoreCmd)
+}
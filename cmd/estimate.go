@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+var estimateCmd = &cobra.Command{
+	Use:   "estimate",
+	Short: "Estimate storage footprint and cost of operations",
+}
+
+var estimateUploadCmd = &cobra.Command{
+	Use:   "upload [file-path]",
+	Short: "Estimate stored bytes, PUT requests and cost of uploading a file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		info, err := os.Stat(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		dataShards, _ := cmd.Flags().GetInt("data-shards")
+		parityShards, _ := cmd.Flags().GetInt("parity-shards")
+		mirrorFactor, _ := cmd.Flags().GetInt("mirror-factor")
+		fileService.SetMirrorFactor(mirrorFactor)
+
+		rates := make(map[string]service.ProviderRates, len(cfg.Pricing))
+		for platform, pricing := range cfg.Pricing {
+			rates[platform] = service.ProviderRates{
+				StoragePerGBMonth: pricing.StoragePerGBMonth,
+				PerThousandPuts:   pricing.PerThousandPuts,
+			}
+		}
+
+		estimate, err := fileService.EstimateUpload(info.Size(), dataShards, parityShards, rates)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		printUploadEstimate(estimate)
+	},
+}
+
+// printUploadEstimate prints the totals followed by per-bucket and per-provider breakdowns
+func printUploadEstimate(estimate service.UploadEstimate) {
+	fmt.Printf("File size:     %s\n", formatBytes(estimate.OriginalSize))
+	fmt.Printf("Shards:        %d data + %d parity, %d cop(ies) each\n", estimate.DataShards, estimate.ParityShards, estimate.Copies)
+	if estimate.AlignmentBytes > 0 {
+		fmt.Printf("Shard size:    %s (%s padding, %s per shard for alignment)\n", formatBytes(estimate.ShardSize), formatBytes(estimate.PaddingBytes), formatBytes(estimate.AlignmentBytes))
+	} else {
+		fmt.Printf("Shard size:    %s (%s padding)\n", formatBytes(estimate.ShardSize), formatBytes(estimate.PaddingBytes))
+	}
+	fmt.Printf("Stored bytes:  %s (%.2fx overhead)\n", formatBytes(estimate.StoredBytes), float64(estimate.StoredBytes)/float64(estimate.OriginalSize))
+	fmt.Printf("PUT requests:  %d\n", estimate.Puts)
+	fmt.Printf("Upload cost:   $%.6f\n", estimate.RequestCost)
+	fmt.Printf("Monthly cost:  $%.6f\n", estimate.StorageCost)
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BUCKET\tTYPE\tSHARDS\tBYTES\tPUTS\tUPLOAD COST\tMONTHLY COST")
+	for _, bucket := range estimate.Buckets {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\t$%.6f\t$%.6f\n", bucket.BucketName, bucket.StorageType,
+			bucket.Shards, formatBytes(bucket.Bytes), bucket.Puts, bucket.RequestCost, bucket.StorageCost)
+	}
+	w.Flush()
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tBUCKETS\tBYTES\tPUTS\tUPLOAD COST\tMONTHLY COST")
+	for _, provider := range estimate.Providers {
+		upload, monthly := fmt.Sprintf("$%.6f", provider.RequestCost), fmt.Sprintf("$%.6f", provider.StorageCost)
+		if !provider.Priced {
+			upload, monthly = "unpriced", "unpriced"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%s\t%s\n", provider.StorageType, provider.Buckets,
+			formatBytes(provider.Bytes), provider.Puts, upload, monthly)
+	}
+	w.Flush()
+}
+
+func init() {
+	estimateUploadCmd.Flags().Int("data-shards", 4, "Number of data shards for erasure coding")
+	estimateUploadCmd.Flags().Int("parity-shards", 2, "Number of parity shards for erasure coding")
+	estimateUploadCmd.Flags().Int("mirror-factor", 1, "Number of copies of each shard, each stored in a different bucket")
+	estimateCmd.AddCommand(estimateUploadCmd)
+	rootCmd.AddCommand(estimateCmd)
+}
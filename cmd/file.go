@@ -1,14 +1,18 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/zzenonn/zstore/internal/domain"
 	"github.com/zzenonn/zstore/internal/repository/objectstore"
+	"github.com/zzenonn/zstore/internal/service"
 )
 
 var quiet bool
@@ -21,6 +25,85 @@ func parseZsURL(zsURL string) (string, error) {
 	return strings.TrimPrefix(zsURL, "zs://"), nil
 }
 
+// applyConcurrency configures shard transfer concurrency from --concurrency and, where the
+// command has them, --upload-concurrency/--download-concurrency, which override it for one
+// direction. It returns the number of concurrent shard uploads.
+func applyConcurrency(cmd *cobra.Command) int {
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	fileService.SetConcurrency(concurrency)
+
+	uploadConcurrency, _ := cmd.Flags().GetInt("upload-concurrency")
+	fileService.SetUploadConcurrency(uploadConcurrency)
+	downloadConcurrency, _ := cmd.Flags().GetInt("download-concurrency")
+	fileService.SetDownloadConcurrency(downloadConcurrency)
+
+	if uploadConcurrency > 0 {
+		return uploadConcurrency
+	}
+	return concurrency
+}
+
+// applyCustomerKey configures SSE-C/CSEK from --sse-customer-key or ZSTORE_SSE_CUSTOMER_KEY
+func applyCustomerKey(cmd *cobra.Command) error {
+	encoded, _ := cmd.Flags().GetString("sse-customer-key")
+	if encoded == "" {
+		encoded = os.Getenv("ZSTORE_SSE_CUSTOMER_KEY")
+	}
+	if encoded == "" {
+		return nil
+	}
+
+	key, err := objectstore.ParseCustomerKey(encoded)
+	if err != nil {
+		return err
+	}
+	return fileService.SetCustomerKey(key)
+}
+
+// providerFilterContext restricts ctx to the providers named by --from-providers and --exclude-providers
+func providerFilterContext(cmd *cobra.Command, ctx context.Context) (context.Context, error) {
+	include, _ := cmd.Flags().GetStringSlice("from-providers")
+	exclude, _ := cmd.Flags().GetStringSlice("exclude-providers")
+	known := make(map[string]bool)
+	for _, provider := range objectstore.Providers() {
+		known[string(provider.Type)] = true
+	}
+	for _, name := range append(append([]string(nil), include...), exclude...) {
+		if !known[name] {
+			return ctx, fmt.Errorf("unknown provider %q (see 'zstore providers')", name)
+		}
+	}
+	return service.WithProviderFilter(ctx, include, exclude), nil
+}
+
+// applyRetention configures object lock from --retention-mode/--retain-until, falling back
+// to retention_mode/retention_period from the config file
+func applyRetention(cmd *cobra.Command) error {
+	modeValue, _ := cmd.Flags().GetString("retention-mode")
+	if modeValue == "" {
+		modeValue = cfg.RetentionMode
+	}
+	if modeValue == "" {
+		return nil
+	}
+	mode, err := objectstore.ParseRetentionMode(modeValue)
+	if err != nil {
+		return err
+	}
+
+	var retainUntil time.Time
+	if until, _ := cmd.Flags().GetString("retain-until"); until != "" {
+		if retainUntil, err = time.Parse(time.RFC3339, until); err != nil {
+			return fmt.Errorf("invalid --retain-until %q (expected RFC 3339, e.g. 2030-01-01T00:00:00Z): %w", until, err)
+		}
+	} else if cfg.RetentionPeriod > 0 {
+		retainUntil = time.Now().Add(cfg.RetentionPeriod)
+	} else {
+		return fmt.Errorf("retention mode %s needs --retain-until or retention_period", strings.ToLower(string(mode)))
+	}
+	return fileService.SetRetention(objectstore.Retention{Mode: mode, RetainUntil: retainUntil})
+}
+
 // parseS3URL parses an s3:// URL and returns the bucket and key
 func parseS3URL(s3URL string) (string, string, error) {
 	if !strings.HasPrefix(s3URL, "s3://") {
@@ -47,6 +130,28 @@ func parseGCSURL(gsURL string) (string, string, error) {
 	return parts[0], parts[1], nil
 }
 
+// statusFileInterval is how often a --status-file is rewritten while an operation makes progress
+const statusFileInterval = time.Second
+
+// startStatusFile starts the --status-file of an operation, if one was given
+// It returns the context to run the operation with and a function that records its outcome.
+func startStatusFile(cmd *cobra.Command, ctx context.Context, operation, key string) (context.Context, func(error), error) {
+	path, _ := cmd.Flags().GetString("status-file")
+	if path == "" {
+		return ctx, func(error) {}, nil
+	}
+	status, err := service.NewStatusFile(path, operation, key, statusFileInterval)
+	if err != nil {
+		return nil, nil, err
+	}
+	finish := func(opErr error) {
+		if err := status.Close(opErr); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	return service.WithProgressReporter(ctx, status), finish, nil
+}
+
 var uploadCmd = &cobra.Command{
 	Use:   "upload [file-path] [zs://bucket/prefix/object]",
 	Short: "Upload a file with erasure coding (destination optional - uses filename if not specified)",
@@ -73,6 +178,13 @@ var uploadCmd = &cobra.Command{
 			key = filepath.Base(filePath)
 		}
 
+		dataShards, _ := cmd.Flags().GetInt("data-shards")
+		parityShards, _ := cmd.Flags().GetInt("parity-shards")
+		if err := service.ValidateShardConfig(dataShards, parityShards); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
 		file, err := os.Open(filePath)
 		if err != nil {
 			fmt.Printf("Error opening file: %v\n", err)
@@ -81,15 +193,113 @@ var uploadCmd = &cobra.Command{
 		defer file.Close()
 
 		quiet, _ := cmd.Flags().GetBool("quiet")
-		dataShards, _ := cmd.Flags().GetInt("data-shards")
-		parityShards, _ := cmd.Flags().GetInt("parity-shards")
-		concurrency, _ := cmd.Flags().GetInt("concurrency")
-		err = fileService.UploadFile(context.Background(), key, file, quiet, dataShards, parityShards, concurrency)
+		concurrency := applyConcurrency(cmd)
+		writeManifest, _ := cmd.Flags().GetBool("write-manifest")
+		fileService.SetWriteManifest(writeManifest)
+		maxShardFailures, _ := cmd.Flags().GetInt("max-shard-failures")
+		fileService.SetMaxShardFailures(maxShardFailures)
+		mirrorFactor, _ := cmd.Flags().GetInt("mirror-factor")
+		fileService.SetMirrorFactor(mirrorFactor)
+		if err := applyCustomerKey(cmd); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if err := applyRetention(cmd); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		info, err := file.Stat()
+		if err != nil {
+			fmt.Printf("Error reading file attributes: %v\n", err)
+			return
+		}
+		buckets, _ := cmd.Flags().GetStringSlice("buckets")
+		ctx := service.WithPlacementBuckets(context.Background(), buckets)
+		if drTarget, _ := cmd.Flags().GetString("dr-replicate"); drTarget != "" {
+			target, err := service.ParseDRTarget(drTarget)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			ctx = service.WithDRReplication(ctx, target)
+		}
+		ctx, finishStatus, err := startStatusFile(cmd, ctx, "upload", key)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		err = fileService.UploadLocalFile(ctx, key, info, file, quiet, dataShards, parityShards, concurrency)
+		finishStatus(err)
 		if err != nil {
 			fmt.Printf("Error uploading file: %v\n", err)
 			return
 		}
-		fmt.Printf("File uploaded successfully: %s -> %s\n", filePath, key)
+		if quiet {
+			fmt.Printf("File uploaded successfully: %s -> %s\n", filePath, key)
+			return
+		}
+		fmt.Printf("File uploaded successfully: %s -> %s%s\n", filePath, key, storedShardsSummary(key))
+	},
+}
+
+// storedShardsSummary describes how many of an uploaded object's shards were stored, e.g. " (5/6 shards stored, 4 needed, DEGRADED(1 missing))"
+// It is empty when the metadata cannot be read back.
+func storedShardsSummary(key string) string {
+	metadata, err := fileService.StatFile(context.Background(), key)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf(" (%d/%d shards stored, %d needed, %s)", metadata.StoredShards(), len(metadata.ShardHashes), metadata.RequiredShards(), service.RecordedHealth(metadata))
+}
+
+var uploadDirCmd = &cobra.Command{
+	Use:   "upload-dir [directory] [zs://bucket/prefix]",
+	Short: "Upload every file under a directory with erasure coding (destination optional - uses the directory name if not specified)",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := args[0]
+
+		prefix := filepath.Base(filepath.Clean(dir))
+		if len(args) == 2 {
+			var err error
+			prefix, err = parseZsURL(args[1])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			prefix = strings.TrimSuffix(prefix, "/")
+		}
+
+		dataShards, _ := cmd.Flags().GetInt("data-shards")
+		parityShards, _ := cmd.Flags().GetInt("parity-shards")
+		if err := service.ValidateShardConfig(dataShards, parityShards); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		quiet, _ := cmd.Flags().GetBool("quiet")
+		concurrency := applyConcurrency(cmd)
+		followSymlinks, _ := cmd.Flags().GetBool("follow-symlinks")
+		mirrorFactor, _ := cmd.Flags().GetInt("mirror-factor")
+		fileService.SetMirrorFactor(mirrorFactor)
+		if err := applyCustomerKey(cmd); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if err := applyRetention(cmd); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		result, err := fileService.UploadDir(context.Background(), dir, prefix, followSymlinks, quiet, dataShards, parityShards, concurrency)
+		for _, skipped := range result.Skipped {
+			fmt.Printf("Skipped %s: %s\n", skipped.Path, skipped.Reason)
+		}
+		if err != nil {
+			fmt.Printf("Error uploading directory after %d file(s): %v\n", len(result.Uploaded), err)
+			exit(1)
+		}
+		fmt.Printf("Directory uploaded successfully: %s -> %s (%d files, %d skipped)\n", dir, prefix, len(result.Uploaded), len(result.Skipped))
 	},
 }
 
@@ -177,13 +387,28 @@ var downloadCmd = &cobra.Command{
 		}
 
 		quiet, _ := cmd.Flags().GetBool("quiet")
-		concurrency, _ := cmd.Flags().GetInt("concurrency")
 		verifyIntegrity, _ := cmd.Flags().GetBool("verify-integrity")
+		if err := applyCustomerKey(cmd); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		ctx, err := providerFilterContext(cmd, context.Background())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if writeBufferSize, _ := cmd.Flags().GetString("write-buffer-size"); writeBufferSize != "" {
+			size, err := parseByteSize(writeBufferSize)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			fileService.SetWriteBufferSize(int(size))
+		}
 
-		// If output path is a directory, use the filename from the key
+		// If output path is a directory, use the original file name (or the key's name)
 		if stat, err := os.Stat(outputPath); err == nil && stat.IsDir() {
-			fileName := filepath.Base(key)
-			outputPath = filepath.Join(outputPath, fileName)
+			outputPath = filepath.Join(outputPath, downloadFileName(key))
 		}
 
 		// Create output directory if it doesn't exist
@@ -199,17 +424,49 @@ var downloadCmd = &cobra.Command{
 		}
 		defer outFile.Close()
 
-		fileService.SetConcurrency(concurrency)
-		err = fileService.DownloadFile(context.Background(), key, outFile, quiet, verifyIntegrity)
+		resume, _ := cmd.Flags().GetBool("resume")
+		fileService.SetResumeDownloads(resume)
+		keepTemp, _ := cmd.Flags().GetBool("keep-temp")
+		fileService.SetKeepTempFiles(keepTemp)
+		applyConcurrency(cmd)
+		ctx, finishStatus, err := startStatusFile(cmd, ctx, "download", key)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		err = fileService.DownloadFile(ctx, key, outFile, quiet, verifyIntegrity)
+		finishStatus(err)
 		if err != nil {
 			fmt.Printf("Error downloading file: %v\n", err)
 			return
 		}
 
+		if preserve, _ := cmd.Flags().GetBool("preserve"); preserve {
+			if err := outFile.Close(); err != nil {
+				fmt.Printf("Error writing output file: %v\n", err)
+				return
+			}
+			if err := fileService.RestoreFileAttributes(context.Background(), key, outputPath); err != nil {
+				fmt.Printf("Error restoring file attributes: %v\n", err)
+				return
+			}
+		}
+
 		fmt.Printf("File downloaded successfully: %s -> %s\n", key, outputPath)
 	},
 }
 
+// downloadFileName picks the local file name for a download into a directory
+// The name recorded at upload time is preferred, so a key without an extension still
+// produces e.g. report.pdf; objects uploaded without one fall back to the key's base name.
+func downloadFileName(key string) string {
+	metadata, err := fileService.StatFile(context.Background(), key)
+	if err == nil && metadata.OriginalName != "" {
+		return filepath.Base(metadata.OriginalName)
+	}
+	return filepath.Base(key)
+}
+
 var downloadRawCmd = &cobra.Command{
 	Use:   "download-raw [s3://bucket/object | gs://bucket/object] [output-path]",
 	Short: "Download a file directly without erasure coding from S3 or GCS",
@@ -278,8 +535,8 @@ var downloadRawCmd = &cobra.Command{
 }
 
 var deleteCmd = &cobra.Command{
-	Use:   "delete [zs://bucket/prefix/object]",
-	Short: "Delete a file from cloud storage",
+	Use:   "delete [zs://bucket/prefix/object | zs://bucket/prefix/ --recursive]",
+	Short: "Delete a file, or every file under a prefix, from cloud storage",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		zsURL := args[0]
@@ -291,15 +548,81 @@ var deleteCmd = &cobra.Command{
 			return
 		}
 
+		if recursive, _ := cmd.Flags().GetBool("recursive"); recursive {
+			deletePrefix(cmd, strings.TrimSuffix(key, "/"))
+			return
+		}
+
 		err = fileService.DeleteFile(context.Background(), key)
 		if err != nil {
 			fmt.Printf("Error deleting file: %v\n", err)
 			return
 		}
+		if cfg.SoftDelete {
+			fmt.Printf("File moved to the trash: %s (restore with 'zstore restore', remove with 'zstore purge')\n", key)
+			return
+		}
 		fmt.Printf("File deleted successfully: %s\n", key)
 	},
 }
 
+// deletePrefix deletes every file under a prefix and its sub-prefixes after listing them and asking for confirmation
+func deletePrefix(cmd *cobra.Command, prefix string) {
+	files, err := fileService.ListFilesRecursive(context.Background(), prefix)
+	if err != nil {
+		fmt.Printf("Error listing files: %v\n", err)
+		exit(1)
+	}
+	if len(files) == 0 {
+		fmt.Printf("No files found in zs://%s/\n", prefix)
+		return
+	}
+
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		for _, file := range files {
+			fmt.Printf("Would delete %s\n", file.DisplayName())
+		}
+		fmt.Printf("%d file(s) would be deleted\n", len(files))
+		return
+	}
+
+	if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+		fmt.Printf("Delete %d file(s) under zs://%s/? [y/N] ", len(files), prefix)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			fmt.Println("Aborted")
+			exit(1)
+		}
+	}
+
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	fileService.SetDeleteConcurrency(concurrency)
+	result, err := fileService.DeletePrefix(context.Background(), prefix)
+	if err != nil {
+		fmt.Printf("Error deleting files: %v\n", err)
+		exit(1)
+	}
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		if err := printJSON(result); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+	} else {
+		for _, failure := range result.Failed {
+			fmt.Printf("FAILED  %s: %s\n", failure.Key, failure.Err)
+		}
+		verb := "Deleted"
+		if cfg.SoftDelete {
+			verb = "Moved to the trash"
+		}
+		fmt.Printf("%s %d file(s), %d failed\n", verb, len(result.Deleted), len(result.Failed))
+	}
+	if len(result.Failed) > 0 {
+		exit(1)
+	}
+}
+
 var deleteRawCmd = &cobra.Command{
 	Use:   "delete-raw [s3://bucket/object | gs://bucket/object]",
 	Short: "Delete a file directly without erasure coding from S3 or GCS",
@@ -347,7 +670,7 @@ var deleteRawCmd = &cobra.Command{
 }
 
 var listCmd = &cobra.Command{
-	Use:   "list [zs://bucket/prefix]",
+	Use:   "list [zs://bucket/prefix[/pattern]]",
 	Short: "List files in cloud storage",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
@@ -360,24 +683,137 @@ var listCmd = &cobra.Command{
 			return
 		}
 		
-		// Remove trailing slash for consistent prefix matching
-		prefix = strings.TrimSuffix(prefix, "/")
+		// A wildcard in the file name filters the prefix listing; the trailing slash is dropped
+		// for consistent prefix matching
+		prefix, pattern, err := service.SplitListPattern(prefix)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
 		
-		files, err := fileService.ListFiles(context.Background(), prefix)
+		sortBy, _ := cmd.Flags().GetString("sort")
+		order, _ := cmd.Flags().GetString("order")
+		format, _ := cmd.Flags().GetString("format")
+		if format != "" && format != listFormatTable && format != listFormatCSV {
+			fmt.Printf("Error: invalid --format %q: must be %s or %s\n", format, listFormatTable, listFormatCSV)
+			return
+		}
+		thresholds, err := listThresholds(cmd)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		var files []domain.ObjectMetadata
+		if sortBy != "" {
+			files, err = fileService.ListFilesSorted(context.Background(), prefix, domain.MetadataSortField(sortBy), domain.SortOrder(order))
+		} else {
+			files, err = fileService.ListFiles(context.Background(), prefix)
+		}
 		if err != nil {
 			fmt.Printf("Error listing files: %v\n", err)
 			return
 		}
+		files = service.MatchFileNames(files, fileService.NormalizeKey(pattern))
+		files = service.FilterByThresholds(files, thresholds)
+
+		// Formatted listings print their header even when empty, so they stay parseable
+		if format != "" {
+			checkHealth, _ := cmd.Flags().GetBool("check-health")
+			if err := printFormattedList(os.Stdout, files, format, checkHealth); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing listing: %v\n", err)
+			}
+			return
+		}
 		
 		if len(files) == 0 {
 			fmt.Printf("No files found in %s\n", zsURL)
 			return
 		}
 		
+		checkHealth, _ := cmd.Flags().GetBool("check-health")
+
 		fmt.Printf("Files in %s:\n", zsURL)
 		for _, file := range files {
-			fmt.Printf("  %s/%s\n", file.Prefix, file.FileName)
+			line := "  " + file.DisplayName()
+			if sortBy != "" {
+				// Show the attributes the listing is ordered by
+				line = fmt.Sprintf("%s\t%d\t%s", line, file.ContentSize(), formatCreatedAt(file.CreatedAt))
+			}
+			if thresholds.MinSize > 0 || thresholds.MaxSize > 0 {
+				line = fmt.Sprintf("%s\t%s", line, formatBytes(file.ContentSize()))
+			}
+			if thresholds.MinOverhead > 0 || thresholds.MaxOverhead > 0 {
+				line = fmt.Sprintf("%s\t%.2fx", line, file.Overhead())
+			}
+			if checkHealth {
+				health := fileService.HealthStatus(context.Background(), file)
+				line = fmt.Sprintf("%s\t%s", line, health)
+			}
+			fmt.Println(line)
+		}
+	},
+}
+
+// listThresholds reads the overhead and size filters of list
+func listThresholds(cmd *cobra.Command) (service.ListThresholds, error) {
+	var thresholds service.ListThresholds
+	thresholds.MinOverhead, _ = cmd.Flags().GetFloat64("min-overhead")
+	thresholds.MaxOverhead, _ = cmd.Flags().GetFloat64("max-overhead")
+	for _, flag := range []struct {
+		name string
+		size *int64
+	}{
+		{"min-size", &thresholds.MinSize},
+		{"max-size", &thresholds.MaxSize},
+	} {
+		value, _ := cmd.Flags().GetString(flag.name)
+		if value == "" {
+			continue
+		}
+		size, err := parseByteSize(value)
+		if err != nil {
+			return thresholds, fmt.Errorf("--%s: %w", flag.name, err)
+		}
+		*flag.size = size
+	}
+	return thresholds, thresholds.Validate()
+}
+
+// formatCreatedAt prints an upload time, or "-" for objects stored before it was recorded
+func formatCreatedAt(createdAt time.Time) string {
+	if createdAt.IsZero() {
+		return "-"
+	}
+	return createdAt.Local().Format(time.RFC3339)
+}
+
+var reencodeCmd = &cobra.Command{
+	Use:   "reencode [zs://bucket/prefix/object]",
+	Short: "Change the erasure coding configuration of a stored file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := parseZsURL(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		quiet, _ := cmd.Flags().GetBool("quiet")
+		dataShards, _ := cmd.Flags().GetInt("data-shards")
+		parityShards, _ := cmd.Flags().GetInt("parity-shards")
+		if err := service.ValidateShardConfig(dataShards, parityShards); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		applyConcurrency(cmd)
+		err = fileService.ReEncode(context.Background(), key, dataShards, parityShards, quiet)
+		if err != nil {
+			fmt.Printf("Error re-encoding file: %v\n", err)
+			return
 		}
+		fmt.Printf("File re-encoded successfully: %s (%d data + %d parity shards)\n", key, dataShards, parityShards)
 	},
 }
 
@@ -386,19 +822,69 @@ func init() {
 	uploadCmd.Flags().Int("data-shards", 4, "Number of data shards for erasure coding")
 	uploadCmd.Flags().Int("parity-shards", 2, "Number of parity shards for erasure coding")
 	uploadCmd.Flags().Int("concurrency", 3, "Number of concurrent shard uploads")
+	uploadCmd.Flags().Int("upload-concurrency", 0, "Number of concurrent shard uploads (default: --concurrency)")
+	uploadCmd.Flags().Int("max-shard-failures", -1, "Shard upload failures to tolerate (default: parity shard count; 0 aborts on any failure)")
+	uploadCmd.Flags().Int("mirror-factor", 1, "Number of copies of each shard, each stored in a different bucket")
+	uploadCmd.Flags().String("sse-customer-key", "", "Base64 256-bit key for provider-side encryption (S3 SSE-C, GCS CSEK); never stored, required again to download")
+	uploadCmd.Flags().String("retention-mode", "", "Lock uploaded shards in governance or compliance mode (default: retention_mode from config)")
+	uploadCmd.Flags().String("retain-until", "", "End of the lock as an RFC 3339 date (default: now + retention_period from config)")
+	uploadCmd.Flags().StringSlice("buckets", nil, "Store this object's shards only in these registered buckets (comma-separated; default: all buckets)")
+	uploadCmd.Flags().String("status-file", "", "Keep a JSON status of the upload (phase, bytes and shards done, ETA) in this file for monitoring")
+	uploadCmd.Flags().String("dr-replicate", "", "Also store the whole object in this DR bucket (e.g. gs://dr-bucket/prefix), for zstore dr-restore")
+	uploadCmd.Flags().Bool("write-manifest", false, "Store a (signed) manifest at <key>/.manifest.json for recovery without DynamoDB")
+	uploadDirCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress progress bars")
+	uploadDirCmd.Flags().Int("data-shards", 4, "Number of data shards for erasure coding")
+	uploadDirCmd.Flags().Int("parity-shards", 2, "Number of parity shards for erasure coding")
+	uploadDirCmd.Flags().Int("concurrency", 3, "Number of concurrent shard uploads")
+	uploadDirCmd.Flags().Int("upload-concurrency", 0, "Number of concurrent shard uploads (default: --concurrency)")
+	uploadDirCmd.Flags().Int("mirror-factor", 1, "Number of copies of each shard, each stored in a different bucket")
+	uploadDirCmd.Flags().Bool("follow-symlinks", false, "Upload the targets of symlinks instead of skipping them")
+	uploadDirCmd.Flags().String("sse-customer-key", "", "Base64 256-bit key for provider-side encryption (S3 SSE-C, GCS CSEK); never stored, required again to download")
+	uploadDirCmd.Flags().String("retention-mode", "", "Lock uploaded shards in governance or compliance mode (default: retention_mode from config)")
+	uploadDirCmd.Flags().String("retain-until", "", "End of the lock as an RFC 3339 date (default: now + retention_period from config)")
 	uploadRawCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress progress bars")
 	uploadRawCmd.Flags().String("region", "", "AWS region for S3 bucket (required for S3)")
 	downloadCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress progress bars")
 	downloadCmd.Flags().Int("concurrency", 3, "Number of concurrent shard downloads")
+	downloadCmd.Flags().Int("download-concurrency", 0, "Number of concurrent shard downloads (default: --concurrency)")
 	downloadCmd.Flags().Bool("verify-integrity", false, "Verify shard integrity using CRC64 hashes")
+	downloadCmd.Flags().String("write-buffer-size", "", "Write the output through a buffer of this size (e.g. 4MB) instead of in one call")
+	downloadCmd.Flags().String("sse-customer-key", "", "Base64 256-bit key the file was uploaded with")
+	downloadCmd.Flags().Bool("preserve", false, "Restore the permission bits and modification time the file had at upload")
+	downloadCmd.Flags().Bool("resume", false, "Keep downloaded shards if the download fails and reuse them when it is run again")
+	downloadCmd.Flags().String("status-file", "", "Keep a JSON status of the download (phase, bytes and shards done, ETA) in this file for monitoring")
+	downloadCmd.Flags().Bool("keep-temp", false, "Keep the downloaded shard temp files, even on success, and log their locations for debugging")
+	downloadCmd.Flags().StringSlice("from-providers", nil, "Only read shards stored on these providers (e.g. s3,gcs)")
+	downloadCmd.Flags().StringSlice("exclude-providers", nil, "Never read shards stored on these providers, e.g. during an outage")
 	downloadRawCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress progress bars")
 	downloadRawCmd.Flags().String("region", "", "AWS region for S3 bucket (required for S3)")
+	deleteCmd.Flags().BoolP("recursive", "r", false, "Delete every file under the prefix and its sub-prefixes")
+	deleteCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt of a recursive delete")
+	deleteCmd.Flags().Bool("dry-run", false, "List the files a recursive delete would remove without deleting them")
+	deleteCmd.Flags().Bool("json", false, "Print the result of a recursive delete as JSON")
+	deleteCmd.Flags().Int("concurrency", 3, "Number of files deleted concurrently by a recursive delete")
 	deleteRawCmd.Flags().String("region", "", "AWS region for S3 bucket (required for S3)")
+	listCmd.Flags().String("sort", "", "Order files by size or date (upload time)")
+	listCmd.Flags().String("order", "asc", "Sort order with --sort: asc or desc")
+	listCmd.Flags().Bool("check-health", false, "Check shard presence and show OK/DEGRADED/LOST for each file")
+	listCmd.Flags().String("format", "", "Print metadata columns as an aligned table or as CSV (table, csv)")
+	listCmd.Flags().Float64("min-overhead", 0, "Only list files storing at least this many bytes per original byte (e.g. 2.0)")
+	listCmd.Flags().Float64("max-overhead", 0, "Only list files storing at most this many bytes per original byte")
+	listCmd.Flags().String("min-size", "", "Only list files of at least this size (e.g. 1GB)")
+	listCmd.Flags().String("max-size", "", "Only list files of at most this size (e.g. 1KB)")
+	reencodeCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress progress bars")
+	reencodeCmd.Flags().Int("data-shards", 4, "New number of data shards")
+	reencodeCmd.Flags().Int("parity-shards", 2, "New number of parity shards")
+	reencodeCmd.Flags().Int("concurrency", 3, "Number of concurrent shard transfers")
+	reencodeCmd.Flags().Int("upload-concurrency", 0, "Number of concurrent shard uploads (default: --concurrency)")
+	reencodeCmd.Flags().Int("download-concurrency", 0, "Number of concurrent shard downloads (default: --concurrency)")
 	rootCmd.AddCommand(uploadCmd)
+	rootCmd.AddCommand(uploadDirCmd)
 	rootCmd.AddCommand(uploadRawCmd)
 	rootCmd.AddCommand(downloadCmd)
 	rootCmd.AddCommand(downloadRawCmd)
 	rootCmd.AddCommand(deleteCmd)
 	rootCmd.AddCommand(deleteRawCmd)
 	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(reencodeCmd)
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+var fsckCmd = &cobra.Command{
+	Use:   "fsck [zs://bucket/prefix]",
+	Short: "Check metadata and shards for inconsistencies (read-only unless --repair)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		prefix := "."
+		if len(args) == 1 {
+			var err error
+			prefix, err = parseZsURL(args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			prefix = strings.TrimSuffix(prefix, "/")
+			if prefix == "" {
+				prefix = "."
+			}
+		}
+
+		repair, _ := cmd.Flags().GetBool("repair")
+		report, err := fileService.Fsck(context.Background(), prefix, repair)
+		if err != nil {
+			fmt.Printf("Error checking %s: %v\n", prefix, err)
+			return
+		}
+
+		for _, issue := range report.Issues {
+			printFsckIssue(issue, repair)
+		}
+
+		fmt.Printf("Checked %d objects (%d shards): %d issues found\n", report.ObjectsChecked, report.ShardsChecked, len(report.Issues))
+		if len(report.Issues) > 0 && !repair {
+			exit(1)
+		}
+	},
+}
+
+// printFsckIssue prints one inconsistency with its suggested fix or repair outcome
+func printFsckIssue(issue service.FsckIssue, repair bool) {
+	location := issue.ObjectKey
+	if issue.ShardIndex >= 0 {
+		location = fmt.Sprintf("%s shard %d", issue.ObjectKey, issue.ShardIndex)
+	}
+	if issue.ShardKey != "" {
+		location = fmt.Sprintf("%s (%s/%s)", location, issue.BucketName, issue.ShardKey)
+	}
+	fmt.Printf("%s: %s: %s\n", strings.ToUpper(string(issue.Kind)), location, issue.Detail)
+
+	switch {
+	case issue.Repaired:
+		fmt.Printf("  repaired\n")
+	case repair && issue.RepairError != nil:
+		fmt.Printf("  repair failed: %v\n", issue.RepairError)
+	default:
+		fmt.Printf("  suggestion: %s\n", issue.Suggestion)
+	}
+}
+
+func init() {
+	fsckCmd.Flags().Bool("repair", false, "Regenerate missing or damaged shards and delete orphaned shards")
+	rootCmd.AddCommand(fsckCmd)
+}
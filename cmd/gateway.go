@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/zzenonn/zstore/internal/gateway"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve stored objects over HTTP (GET /objects/{key}, supports Range requests)",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		addr, _ := cmd.Flags().GetString("addr")
+
+		// Keep progress bars out of the gateway's logs
+		fileService.SetProgressOutput(io.Discard)
+
+		handler := gateway.NewHandler(fileService)
+		log.Infof("Gateway listening on %s", addr)
+		if err := http.ListenAndServe(addr, handler); err != nil {
+			fmt.Printf("Error running gateway: %v\n", err)
+			return
+		}
+	},
+}
+
+func init() {
+	serveCmd.Flags().String("addr", ":8080", "Address for the HTTP gateway to listen on")
+	rootCmd.AddCommand(serveCmd)
+}
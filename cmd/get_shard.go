@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var getShardCmd = &cobra.Command{
+	Use:   "get-shard [zs://bucket/prefix/object] [output-file]",
+	Short: "Download one raw shard of an object as stored, without verification or reconstruction",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := parseZsURL(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		outputPath := args[1]
+		index, _ := cmd.Flags().GetInt("index")
+		copyIndex, _ := cmd.Flags().GetInt("copy")
+		quiet, _ := cmd.Flags().GetBool("quiet")
+
+		file, err := os.Create(outputPath)
+		if err != nil {
+			fmt.Printf("Error creating output file: %v\n", err)
+			exit(1)
+		}
+		shard, err := fileService.DownloadShard(context.Background(), key, index, copyIndex, file, quiet)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(outputPath)
+			fmt.Fprintf(os.Stderr, "Error downloading shard: %v\n", err)
+			exit(1)
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			if err := printJSON(shard); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+			return
+		}
+		fmt.Printf("Shard %d (copy %d) of %s: %d bytes from %s/%s, recorded hash %s\n",
+			shard.Index, shard.Copy, key, shard.Size, shard.BucketName, shard.Key, shard.Hash)
+	},
+}
+
+func init() {
+	getShardCmd.Flags().Int("index", 0, "Index of the shard to download (data shards first, then parity)")
+	getShardCmd.Flags().Int("copy", 0, "Stored copy of the shard to download (0 is the primary; see zstore locate)")
+	getShardCmd.Flags().Bool("json", false, "Print where the shard came from as JSON")
+	getShardCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress progress bars")
+	getShardCmd.MarkFlagRequired("index")
+	rootCmd.AddCommand(getShardCmd)
+}
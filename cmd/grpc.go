@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/zzenonn/zstore/internal/grpcapi"
+	"github.com/zzenonn/zstore/internal/grpcapi/zstorepb"
+	"google.golang.org/grpc"
+)
+
+var grpcCmd = &cobra.Command{
+	Use:   "grpc",
+	Short: "Serve stored objects over gRPC (Upload, Download, Delete, List, Stat)",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		addr, _ := cmd.Flags().GetString("addr")
+		dataShards, _ := cmd.Flags().GetInt("data-shards")
+		parityShards, _ := cmd.Flags().GetInt("parity-shards")
+
+		// Keep progress bars out of the server's logs
+		fileService.SetProgressOutput(io.Discard)
+
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			fmt.Printf("Error listening on %s: %v\n", addr, err)
+			return
+		}
+		server := grpc.NewServer()
+		zstorepb.RegisterZstoreServer(server, grpcapi.NewServer(fileService, dataShards, parityShards))
+		log.Infof("gRPC server listening on %s", listener.Addr())
+		if err := server.Serve(listener); err != nil {
+			fmt.Printf("Error running gRPC server: %v\n", err)
+			return
+		}
+	},
+}
+
+func init() {
+	grpcCmd.Flags().String("addr", ":9090", "Address for the gRPC server to listen on")
+	grpcCmd.Flags().Int("data-shards", 4, "Data shards for uploads that do not set their own")
+	grpcCmd.Flags().Int("parity-shards", 2, "Parity shards for uploads that do not set their own")
+	rootCmd.AddCommand(grpcCmd)
+}
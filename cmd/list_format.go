@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/domain"
+)
+
+// Output formats of list --format besides the default one name per line
+const (
+	listFormatTable = "table"
+	listFormatCSV   = "csv"
+)
+
+// listHeader returns the column names of a formatted listing
+func listHeader(checkHealth bool) []string {
+	header := []string{"KEY", "SIZE", "DATA_SHARDS", "PARITY_SHARDS", "SHARD_SIZE", "CREATED_AT", "OVERHEAD"}
+	if checkHealth {
+		header = append(header, "HEALTH")
+	}
+	return header
+}
+
+// listRow returns the columns of one file, human-readable for tables and exact for CSV
+func listRow(file domain.ObjectMetadata, human bool) []string {
+	dataShards := len(file.ShardHashes) - file.ParityShards
+	if human {
+		return []string{
+			file.DisplayName(),
+			formatBytes(file.ContentSize()),
+			strconv.Itoa(dataShards),
+			strconv.Itoa(file.ParityShards),
+			formatBytes(file.ShardSize),
+			formatCreatedAt(file.CreatedAt),
+			fmt.Sprintf("%.2fx", file.Overhead()),
+		}
+	}
+
+	createdAt := ""
+	if !file.CreatedAt.IsZero() {
+		createdAt = file.CreatedAt.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		file.DisplayName(),
+		strconv.FormatInt(file.ContentSize(), 10),
+		strconv.Itoa(dataShards),
+		strconv.Itoa(file.ParityShards),
+		strconv.FormatInt(file.ShardSize, 10),
+		createdAt,
+		strconv.FormatFloat(file.Overhead(), 'f', 4, 64),
+	}
+}
+
+// printFormattedList writes files as an aligned table or as CSV with a header row
+// With checkHealth, every file's shards are checked and its health is added as the last column.
+func printFormattedList(w io.Writer, files []domain.ObjectMetadata, format string, checkHealth bool) error {
+	rows := make([][]string, 0, len(files))
+	for _, file := range files {
+		row := listRow(file, format == listFormatTable)
+		if checkHealth {
+			row = append(row, fileService.HealthStatus(context.Background(), file).String())
+		}
+		rows = append(rows, row)
+	}
+
+	if format == listFormatCSV {
+		writer := csv.NewWriter(w)
+		writer.Write(listHeader(checkHealth))
+		writer.WriteAll(rows)
+		return writer.Error()
+	}
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, row := range append([][]string{listHeader(checkHealth)}, rows...) {
+		for i, column := range row {
+			if i > 0 {
+				fmt.Fprint(writer, "\t")
+			}
+			fmt.Fprint(writer, column)
+		}
+		fmt.Fprintln(writer)
+	}
+	return writer.Flush()
+}
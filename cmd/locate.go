@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var locateCmd = &cobra.Command{
+	Use:   "locate [zs://bucket/prefix/object]",
+	Short: "Show which buckets hold an object's shards",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := parseZsURL(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		location, err := fileService.LocateFile(context.Background(), key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error locating %s: %v\n", key, err)
+			exit(1)
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			if err := printJSON(location); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+			return
+		}
+
+		// One line per bucket: BUCKET<TAB>TYPE<TAB>comma-separated shard indices
+		for _, bucket := range location.Buckets {
+			fmt.Printf("%s\t%s\t%s\n", bucket.BucketName, bucket.StorageType, joinInts(bucket.Shards))
+		}
+		if len(location.Unstored) > 0 {
+			fmt.Fprintf(os.Stderr, "Shards with no stored copy: %s\n", joinInts(location.Unstored))
+		}
+	},
+}
+
+// joinInts formats integers as a comma-separated list
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(parts, ",")
+}
+
+func init() {
+	locateCmd.Flags().Bool("json", false, "Print the bucket to shard mapping as JSON")
+	rootCmd.AddCommand(locateCmd)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/user"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -16,6 +18,7 @@ import (
 	"github.com/zzenonn/zstore/internal/repository/db"
 	"github.com/zzenonn/zstore/internal/repository/objectstore"
 	"github.com/zzenonn/zstore/internal/service"
+	"github.com/zzenonn/zstore/internal/version"
 )
 
 var (
@@ -26,9 +29,14 @@ var (
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "zstore",
-	Short: "CLI application for user and file management",
-	Long:  "A CLI application built with Cobra for managing users and file operations",
+	Use:     "zstore",
+	Short:   "CLI application for user and file management",
+	Long:    "A CLI application built with Cobra for managing users and file operations",
+	Version: version.Version,
+
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		runningCmd = cmd
+	},
 }
 
 func init() {
@@ -41,6 +49,8 @@ func setupFlags() {
 	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "config file path (default is ./config.yaml)")
 	rootCmd.PersistentFlags().String("log-level", "info", "log level (debug, info, warn, error)")
 	rootCmd.PersistentFlags().String("dynamodb-table", "default-table", "DynamoDB table name")
+	rootCmd.PersistentFlags().Bool("debug-http", false, "log cloud SDK requests and responses (credentials redacted)")
+	rootCmd.PersistentFlags().String("perf-csv", "", "write per-shard transfer timings of the operation to this CSV file")
 }
 
 var initCmd = &cobra.Command{
@@ -55,8 +65,12 @@ var debugCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Printf("Configuration:\n")
 		fmt.Printf("  Log Level: %s\n", cfg.LogLevel)
+		fmt.Printf("  Version: %s\n", version.Version)
 		fmt.Printf("  DynamoDB Table: %s\n", cfg.DynamoDBTable)
 		fmt.Printf("  DynamoDB Region: %s\n", cfg.DynamoDBRegion)
+		fmt.Printf("  DynamoDB Keys: %s / %s\n", cfg.DynamoDBPartitionKey, cfg.DynamoDBSortKey)
+		fmt.Printf("  DynamoDB Namespace: %s\n", cfg.DynamoDBNamespace)
+		fmt.Printf("  Placement: %s\n", cfg.Placement)
 		fmt.Printf("\nBuckets:\n")
 		for key, bucket := range cfg.Buckets {
 			fmt.Printf("  %s:\n", key)
@@ -118,22 +132,147 @@ func initConfig() {
 	if err != nil {
 		log.Fatalf("Failed to connect to the database: %v", err)
 	}
-	placer := initRepositories(cfg.AwsConfig, cfg.GcsClient, cfg.Buckets)
-	metadataRepository := db.NewMetadataRepository(dynamoDb.Client, cfg.DynamoDBTable)
+	placer, err := initRepositories(cfg.AwsConfig, cfg.GcsClient, cfg.Buckets, cfg.Placement, cfg.PlacementUsageTTL)
+	if err != nil {
+		log.Fatalf("Failed to set up shard placement: %v", err)
+	}
+	dynamoMetadataRepository := db.NewMetadataRepository(dynamoDb.Client, cfg.DynamoDBTable)
+	dynamoMetadataRepository.SetKeyNames(cfg.DynamoDBPartitionKey, cfg.DynamoDBSortKey)
+	if err := dynamoMetadataRepository.SetNamespace(cfg.DynamoDBNamespace); err != nil {
+		log.Fatalf("Invalid DynamoDB namespace: %v", err)
+	}
+	dynamoMetadataRepository.SetVerifyChecksum(cfg.VerifyMetadataChecksum)
+	var metadataRepository service.MetadataRepository = &dynamoMetadataRepository
+	if cfg.MetadataRetryAttempts > 1 {
+		// Ride out DynamoDB throttling instead of failing uploads whose shards are already stored
+		metadataRepository = service.NewRetryingMetadataRepository(metadataRepository, cfg.MetadataRetryAttempts, cfg.MetadataRetryDelay)
+	}
+	if cfg.MetadataCacheSize > 0 {
+		// Keep recently accessed objects readable through brief metadata store outages
+		metadataRepository = service.NewCachedMetadataRepository(metadataRepository, cfg.MetadataCacheSize, cfg.MetadataCacheTTL)
+	}
+
+	checksum, err := objectstore.ParseChecksumAlgorithm(cfg.S3ChecksumAlgorithm)
+	if err != nil {
+		log.Fatalf("Invalid s3_checksum_algorithm: %v", err)
+	}
 
 	// Create repository factory for raw file service
 	factory := objectstore.NewObjectRepositoryFactory(cfg.AwsConfig, cfg.GcsClient)
+	factory.SetChecksumAlgorithm(checksum)
+	factory.SetAccounting(accounting)
 
-	fileService = service.NewFileService(placer, &metadataRepository)
+	fileService = service.NewFileService(placer, metadataRepository)
+	fileService.SetMinShardSize(cfg.MinShardSize)
+	fileService.SetShardAlignment(cfg.ShardAlignment)
+	if err := fileService.SetCompression(cfg.Compression); err != nil {
+		log.Fatalf("Invalid compression: %v", err)
+	}
+	if cfg.EncryptionKey != "" {
+		key, err := service.ParseEncryptionKey(cfg.EncryptionKey)
+		if err != nil {
+			log.Fatalf("Invalid encryption_key: %v", err)
+		}
+		fileService.SetEncryptionKey(key)
+	}
+	fileService.SetMaxObjectSize(cfg.MaxObjectSize)
+	fileService.SetManifestBucket(cfg.ManifestBucket)
+	fileService.SetManifestSigningKey(cfg.ECDSAPrivateKey)
+	fileService.SetSoftDelete(cfg.SoftDelete, cfg.SoftDeleteRetention)
+	fileService.SetCaseInsensitiveKeys(cfg.CaseInsensitiveKeys)
+	fileService.SetEncoderGoroutines(cfg.EncoderGoroutines)
+	fileService.SetMetadataWriteRetries(cfg.MetadataWriteAttempts, cfg.MetadataWriteDelay)
+	fileService.SetInitialDownloadBuffer(cfg.InitialDownloadBuffer)
+	if cfg.ReconstructionCacheDir != "" {
+		cache, err := service.NewReconstructionCache(cfg.ReconstructionCacheDir, cfg.ReconstructionCacheSize, cfg.ReconstructionCacheTTL)
+		if err != nil {
+			log.Fatalf("Invalid reconstruction cache configuration: %v", err)
+		}
+		fileService.SetReconstructionCache(cache)
+	}
+	if err := fileService.SetMaxConcurrentDownloads(cfg.MaxConcurrentDownloads, cfg.DownloadLimitMode); err != nil {
+		log.Fatalf("Invalid download limit configuration: %v", err)
+	}
+	if err := fileService.SetVerifySampleRate(cfg.VerifySampleRate); err != nil {
+		log.Fatalf("Invalid verify_sample_rate: %v", err)
+	}
+	if err := fileService.SetShardKeyScheme(cfg.ShardKeyScheme); err != nil {
+		log.Fatalf("Invalid shard_key_scheme: %v", err)
+	}
+	if err := fileService.SetUploadLogDir(cfg.UploadLogDir); err != nil {
+		log.Fatalf("Invalid upload_log_dir: %v", err)
+	}
+	if perfCSV, _ := rootCmd.PersistentFlags().GetString("perf-csv"); perfCSV != "" {
+		enablePerfCSV(perfCSV)
+	}
+	if err := fileService.SetChecksumAlgorithm(checksum); err != nil {
+		log.Fatalf("Failed to configure upload checksums: %v", err)
+	}
+	auditLogger, err := newAuditLogger(dynamoDb)
+	if err != nil {
+		log.Fatalf("Failed to set up audit logging: %v", err)
+	}
+	if auditLogger != nil {
+		fileService.SetAuditLogger(auditLogger, currentUser())
+	}
+	if err := fileService.SetTempFiles(cfg.TempDir, cfg.TempFilePrefix); err != nil {
+		log.Fatalf("Invalid temp file configuration: %v", err)
+	}
+	if cfg.TempSweep {
+		removed, err := service.SweepTempFiles(cfg.TempDir, cfg.TempFilePrefix, cfg.TempSweepAge)
+		if err != nil {
+			log.Warnf("Temp file sweep incomplete: %v", err)
+		}
+		if removed > 0 {
+			log.Infof("Removed %d stale temp files older than %s", removed, cfg.TempSweepAge)
+		}
+	}
 	rawFileService = service.NewRawFileService(factory)
+	fileService.SetDRService(rawFileService)
+}
+
+// newAuditLogger creates the audit log sink selected by audit_log, or nil when auditing is off
+func newAuditLogger(dynamoDb *db.DynamoDb) (service.AuditLogger, error) {
+	switch cfg.AuditLog {
+	case "":
+		return nil, nil
+	case "file":
+		return service.NewFileAuditLogger(cfg.AuditLogFile)
+	case "dynamodb":
+		repo := db.NewAuditRepository(dynamoDb.Client, cfg.AuditLogTable)
+		return &repo, nil
+	}
+	return nil, fmt.Errorf("unknown audit_log sink %q (use file or dynamodb)", cfg.AuditLog)
+}
+
+// currentUser returns the name of the OS user running zstore, which CLI operations are audited as
+func currentUser() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
 }
 
 // initRepositories initializes the placement system and repositories
-func initRepositories(awsConfig aws.Config, gcsClient *storage.Client, buckets map[string]config.BucketConfig) placement.Placer {
+func initRepositories(awsConfig aws.Config, gcsClient *storage.Client, buckets map[string]config.BucketConfig, strategy string, usageTTL time.Duration) (placement.Placer, error) {
 	// Create factory that can build S3 and GCS repositories
 	factory := objectstore.NewObjectRepositoryFactory(awsConfig, gcsClient)
-	// Create round-robin placer for distributing shards across buckets
-	placer := placement.NewRoundRobinPlacer()
+	factory.SetAccounting(accounting)
+
+	// Create the placer for distributing shards across buckets
+	var placer placement.Placer
+	switch strategy {
+	case "", "round-robin":
+		placer = placement.NewRoundRobinPlacer()
+	case "least-loaded":
+		placer = placement.NewLeastLoadedPlacer(usageTTL)
+	default:
+		return nil, fmt.Errorf("unknown placement strategy %q (use round-robin or least-loaded)", strategy)
+	}
+
+	// Buckets with a quota stop receiving shards once full
+	quotaPlacer := placement.NewQuotaPlacer(placer, usageTTL)
+	limited := false
 
 	// Register each configured bucket with the placer
 	for bucketKey, bucketConfig := range buckets {
@@ -141,10 +280,18 @@ func initRepositories(awsConfig aws.Config, gcsClient *storage.Client, buckets m
 		if repo != nil {
 			// Add repository to placement system
 			placer.RegisterBucket(bucketKey, repo)
+			quota := placement.Quota{MaxObjects: bucketConfig.MaxObjects, MaxBytes: bucketConfig.MaxBytes}
+			if quota.Enabled() {
+				quotaPlacer.SetQuota(bucketKey, quota)
+				limited = true
+			}
 		}
 	}
 
-	return placer
+	if limited {
+		return quotaPlacer, nil
+	}
+	return placer, nil
 }
 
 // createRepository creates a single repository from bucket configuration
@@ -167,6 +314,20 @@ func createRepository(factory *objectstore.ObjectRepositoryFactory, bucketKey st
 	return repo
 }
 
+// enablePerfCSV records every shard transfer of this run as a row of the CSV file at path
+// The file is replaced, and each row is flushed as it is written, so a failed run keeps its timings.
+func enablePerfCSV(path string) {
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("Failed to create --perf-csv file: %v", err)
+	}
+	timings, err := fileService.NewShardTimingCSV(file)
+	if err != nil {
+		log.Fatalf("Failed to write --perf-csv file: %v", err)
+	}
+	fileService.SetShardObserver(timings.Observe)
+}
+
 func init() {
 	addCommands()
 }
@@ -179,8 +340,27 @@ func addCommands() {
 }
 
 func main() {
+	os.Exit(execute())
+}
+
+// execute runs the command line and returns the process exit status
+// The accounting summary is printed from a deferred call, so commands that end early
+// through exit are covered as well as those that return.
+func execute() (code int) {
+	defer func() {
+		if r := recover(); r != nil {
+			status, ok := r.(exitCode)
+			if !ok {
+				panic(r)
+			}
+			code = int(status)
+		}
+		printAccountingSummary()
+	}()
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		return 1
 	}
+	return 0
 }
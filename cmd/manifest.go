@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zzenonn/zstore/internal/config"
+)
+
+var manifestCmd = &cobra.Command{
+	Use:   "manifest",
+	Short: "Manage the signed manifests stored alongside objects",
+}
+
+var manifestResignCmd = &cobra.Command{
+	Use:   "resign [zs://bucket/prefix]",
+	Short: "Re-sign manifests under a prefix with a new signing key after verifying them with the old one",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		prefix, err := parseZsURL(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		oldKeyPath, _ := cmd.Flags().GetString("old-key")
+		oldKey, err := config.LoadECDSAPublicKey(oldKeyPath)
+		if err != nil {
+			fmt.Printf("Error loading old key: %v\n", err)
+			exit(1)
+		}
+
+		// The new key defaults to the configured manifest_signing_key
+		newKey := cfg.ECDSAPrivateKey
+		if newKeyPath, _ := cmd.Flags().GetString("new-key"); newKeyPath != "" {
+			if newKey, err = config.LoadECDSAPrivateKey(newKeyPath); err != nil {
+				fmt.Printf("Error loading new key: %v\n", err)
+				exit(1)
+			}
+		}
+		if newKey == nil {
+			fmt.Println("Error: no new key given; pass --new-key or set manifest_signing_key")
+			exit(1)
+		}
+
+		result, err := fileService.ResignManifests(context.Background(), strings.TrimSuffix(prefix, "/"), oldKey, newKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error re-signing manifests: %v\n", err)
+			exit(1)
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			if err := printJSON(result); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+		} else {
+			for _, failure := range result.Failed {
+				fmt.Printf("FAILED    %s: %s\n", failure.Key, failure.Err)
+			}
+			fmt.Printf("Re-signed %d manifest(s), %d already current, %d failed\n", len(result.Resigned), len(result.Current), len(result.Failed))
+		}
+		if len(result.Failed) > 0 {
+			exit(1)
+		}
+	},
+}
+
+func init() {
+	manifestResignCmd.Flags().String("old-key", "", "PEM file with the key the manifests are signed with (public or private)")
+	manifestResignCmd.Flags().String("new-key", "", "PEM file with the private key to sign with (default: manifest_signing_key from config)")
+	manifestResignCmd.Flags().Bool("json", false, "Print the result as JSON")
+	manifestResignCmd.MarkFlagRequired("old-key")
+	manifestCmd.AddCommand(manifestResignCmd)
+	rootCmd.AddCommand(manifestCmd)
+}
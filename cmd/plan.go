@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Preview which bucket each shard of an upload would be stored in",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dataShards, _ := cmd.Flags().GetInt("data-shards")
+		parityShards, _ := cmd.Flags().GetInt("parity-shards")
+		mirrorFactor, _ := cmd.Flags().GetInt("mirror-factor")
+		fileService.SetMirrorFactor(mirrorFactor)
+
+		var size int64
+		if sizeFlag, _ := cmd.Flags().GetString("size"); sizeFlag != "" {
+			parsed, err := parseByteSize(sizeFlag)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			size = parsed
+		}
+
+		plan, err := fileService.PlanPlacement(size, dataShards, parityShards)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			if err := printJSON(plan); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+			return
+		}
+
+		fmt.Printf("Placement: %s\n", cfg.Placement)
+		fmt.Printf("Shards:    %d data + %d parity\n", plan.DataShards, plan.ParityShards)
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SHARD\tKIND\tBUCKETS")
+		for _, shard := range plan.Shards {
+			kind := "data"
+			if shard.Parity {
+				kind = "parity"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", shard.Shard, kind, strings.Join(shard.Buckets, ","))
+		}
+		w.Flush()
+
+		fmt.Println()
+		if plan.SurvivesBucketLoss {
+			fmt.Printf("Losing any one bucket loses at most %d shard(s); %d parity shard(s) cover it.\n", plan.MaxShardsLost, plan.ParityShards)
+		} else {
+			fmt.Printf("Warning: losing one bucket can lose %d shards, more than the %d parity shard(s); the object would be unreadable.\n", plan.MaxShardsLost, plan.ParityShards)
+		}
+	},
+}
+
+func init() {
+	planCmd.Flags().Int("data-shards", 4, "Number of data shards for erasure coding")
+	planCmd.Flags().Int("parity-shards", 2, "Number of parity shards for erasure coding")
+	planCmd.Flags().Int("mirror-factor", 1, "Number of copies of each shard, each stored in a different bucket")
+	planCmd.Flags().String("size", "", "Object size (e.g. 10MB); applies min_shard_size to the data shard count")
+	planCmd.Flags().Bool("json", false, "Print the plan as JSON")
+	rootCmd.AddCommand(planCmd)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+// providerReport is one row of the providers command
+type providerReport struct {
+	Type        string   `json:"type"`
+	Scheme      string   `json:"scheme"`
+	Description string   `json:"description"`
+	Ready       bool     `json:"ready"`
+	Detail      string   `json:"detail,omitempty"`
+	Buckets     []string `json:"buckets"` // Configured bucket keys using the provider
+}
+
+var providersCmd = &cobra.Command{
+	Use:   "providers",
+	Short: "List the storage providers this build supports and whether each is usable here",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		factory := objectstore.NewObjectRepositoryFactory(cfg.AwsConfig, cfg.GcsClient)
+		var reports []providerReport
+		for _, status := range factory.ProviderStatuses(ctx) {
+			report := providerReport{
+				Type:        string(status.Type),
+				Scheme:      status.Scheme,
+				Description: status.Description,
+				Ready:       status.Ready,
+				Detail:      status.Detail,
+				Buckets:     []string{},
+			}
+			for bucketKey, bucketConfig := range cfg.Buckets {
+				if objectstore.RepositoryType(bucketConfig.Platform) == status.Type {
+					report.Buckets = append(report.Buckets, bucketKey)
+				}
+			}
+			sort.Strings(report.Buckets)
+			reports = append(reports, report)
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			if err := printJSON(reports); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TYPE\tSCHEME\tSTATUS\tBUCKETS\tDESCRIPTION")
+		for _, report := range reports {
+			status := "ready"
+			if !report.Ready {
+				status = "not ready: " + report.Detail
+			}
+			buckets := strings.Join(report.Buckets, ",")
+			if buckets == "" {
+				buckets = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s://\t%s\t%s\t%s\n", report.Type, report.Scheme, status, buckets, report.Description)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	providersCmd.Flags().Bool("json", false, "Print the providers as JSON")
+	rootCmd.AddCommand(providersCmd)
+}
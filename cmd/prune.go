@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune [zs://bucket/prefix/] --older-than 30d",
+	Short: "Delete every file under a prefix uploaded longer ago than a given age",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		prefix, err := parseZsURL(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(2)
+		}
+		prefix = strings.TrimSuffix(prefix, "/")
+
+		olderThan, _ := cmd.Flags().GetString("older-than")
+		age, err := parseAge(olderThan)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(2)
+		}
+		cutoff := time.Now().Add(-age)
+
+		old, undated, err := fileService.PruneCandidates(context.Background(), prefix, cutoff)
+		if err != nil {
+			fmt.Printf("Error listing files: %v\n", err)
+			exit(1)
+		}
+		if len(undated) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %d file(s) under zs://%s/ have no upload time and are kept\n", len(undated), prefix)
+		}
+		if len(old) == 0 {
+			fmt.Printf("No files under zs://%s/ were uploaded before %s\n", prefix, cutoff.UTC().Format(time.RFC3339))
+			return
+		}
+
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			for _, file := range old {
+				fmt.Printf("Would delete %s\t(uploaded %s)\n", file.DisplayName(), formatCreatedAt(file.CreatedAt))
+			}
+			fmt.Printf("%d file(s) would be deleted\n", len(old))
+			return
+		}
+
+		if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+			fmt.Printf("Delete %d file(s) under zs://%s/ uploaded before %s? [y/N] ", len(old), prefix, cutoff.UTC().Format(time.RFC3339))
+			answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+				fmt.Println("Aborted")
+				exit(1)
+			}
+		}
+
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		fileService.SetDeleteConcurrency(concurrency)
+		// Listed again with the same cutoff, so files uploaded since the prompt are never included
+		result, err := fileService.Prune(context.Background(), prefix, cutoff)
+		if err != nil {
+			fmt.Printf("Error pruning files: %v\n", err)
+			exit(1)
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			if err := printJSON(result); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+		} else {
+			for _, failure := range result.Failed {
+				fmt.Printf("FAILED  %s: %s\n", failure.Key, failure.Err)
+			}
+			verb := "Deleted"
+			if cfg.SoftDelete {
+				verb = "Moved to the trash"
+			}
+			fmt.Printf("%s %d file(s), %d failed\n", verb, len(result.Deleted), len(result.Failed))
+		}
+		if len(result.Failed) > 0 {
+			exit(1)
+		}
+	},
+}
+
+// parseAge parses ages such as "30d", "2w" or "36h" (Go durations plus days and weeks)
+func parseAge(value string) (time.Duration, error) {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return 0, fmt.Errorf("--older-than is required (e.g. 30d, 2w, 36h)")
+	}
+
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	var age time.Duration
+	var err error
+	if unit, ok := units[trimmed[len(trimmed)-1:]]; ok {
+		var n float64
+		n, err = strconv.ParseFloat(trimmed[:len(trimmed)-1], 64)
+		age = time.Duration(n * float64(unit))
+	} else {
+		age, err = time.ParseDuration(trimmed)
+	}
+	if err != nil || age <= 0 {
+		return 0, fmt.Errorf("invalid age %q (expected e.g. 30d, 2w, 36h)", value)
+	}
+	return age, nil
+}
+
+func init() {
+	pruneCmd.Flags().String("older-than", "", "Delete files uploaded longer ago than this (e.g. 30d, 2w, 36h)")
+	pruneCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+	pruneCmd.Flags().Bool("dry-run", false, "List the files that would be deleted without deleting them")
+	pruneCmd.Flags().Bool("json", false, "Print the result as JSON")
+	pruneCmd.Flags().Int("concurrency", 3, "Number of files deleted concurrently")
+	rootCmd.AddCommand(pruneCmd)
+}
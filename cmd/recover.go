@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+var recoverCmd = &cobra.Command{
+	Use:   "recover",
+	Short: "Complete or clean up uploads interrupted by a crash, using the upload log",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		olderThan, _ := cmd.Flags().GetDuration("older-than")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		uploads, err := fileService.RecoverUploads(context.Background(), olderThan, dryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error recovering uploads: %v\n", err)
+			exit(1)
+		}
+
+		failed := 0
+		for _, upload := range uploads {
+			if upload.Err != "" {
+				failed++
+			}
+		}
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			printJSON(uploads)
+		} else {
+			printRecoveredUploads(uploads, dryRun)
+		}
+		if failed > 0 {
+			exit(1)
+		}
+	},
+}
+
+// printRecoveredUploads prints what recovery did, or would do, for each interrupted upload
+func printRecoveredUploads(uploads []service.InterruptedUpload, dryRun bool) {
+	if len(uploads) == 0 {
+		fmt.Println("No interrupted uploads found")
+		return
+	}
+
+	verb := map[string]string{
+		service.RecoveryCompleted: "completed (metadata written)",
+		service.RecoveryCleaned:   "cleaned up",
+		service.RecoveryFinished:  "already finished",
+	}
+	for _, upload := range uploads {
+		action := verb[upload.Action]
+		if dryRun {
+			action = "would be " + action
+		}
+		fmt.Printf("%s (started %s, %s): %s", upload.Key, upload.StartedAt.Local().Format(time.RFC3339), upload.Phase, action)
+		if upload.Deleted > 0 {
+			fmt.Printf(", %d shard copies", upload.Deleted)
+		}
+		fmt.Println()
+		if upload.Err != "" {
+			fmt.Printf("  failed, kept for another attempt: %s\n", upload.Err)
+		}
+	}
+}
+
+func init() {
+	recoverCmd.Flags().Duration("older-than", 15*time.Minute, "Only recover uploads started at least this long ago, so running uploads are left alone")
+	recoverCmd.Flags().Bool("dry-run", false, "Show what would be done without changing anything")
+	recoverCmd.Flags().Bool("json", false, "Print the result as JSON")
+	rootCmd.AddCommand(recoverCmd)
+}
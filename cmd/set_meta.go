@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+var setMetaCmd = &cobra.Command{
+	Use:   "set-meta [zs://bucket/prefix/object]",
+	Short: "Change an object's content type or tags without re-uploading it",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := parseZsURL(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(2)
+		}
+
+		var update service.MetadataUpdate
+		if cmd.Flags().Changed("content-type") {
+			contentType, _ := cmd.Flags().GetString("content-type")
+			update.ContentType = &contentType
+		}
+		tags, _ := cmd.Flags().GetStringArray("tag")
+		for _, tag := range tags {
+			name, value, ok := strings.Cut(tag, "=")
+			if !ok {
+				fmt.Printf("Error: invalid tag %q (expected name=value)\n", tag)
+				exit(2)
+			}
+			if update.SetTags == nil {
+				update.SetTags = make(map[string]string)
+			}
+			update.SetTags[name] = value
+		}
+		update.RemoveTags, _ = cmd.Flags().GetStringArray("remove-tag")
+		if err := update.Validate(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(2)
+		}
+
+		metadata, err := fileService.UpdateMetadataFields(context.Background(), key, update)
+		if err != nil {
+			fmt.Printf("Error updating metadata of %s: %v\n", key, err)
+			exit(1)
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			if err := printJSON(metadata); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+			return
+		}
+
+		fmt.Printf("Metadata updated: %s\n", key)
+		if metadata.ContentType != "" {
+			fmt.Printf("  Content type: %s\n", metadata.ContentType)
+		}
+		names := make([]string, 0, len(metadata.UserMetadata))
+		for name := range metadata.UserMetadata {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("  %s=%s\n", name, metadata.UserMetadata[name])
+		}
+	},
+}
+
+func init() {
+	setMetaCmd.Flags().String("content-type", "", "MIME type to serve the object with (empty to derive it from the original file name)")
+	setMetaCmd.Flags().StringArray("tag", nil, "Tag to add or replace as name=value (repeatable)")
+	setMetaCmd.Flags().StringArray("remove-tag", nil, "Name of a tag to remove (repeatable)")
+	setMetaCmd.Flags().Bool("json", false, "Print the updated metadata as JSON")
+	rootCmd.AddCommand(setMetaCmd)
+}
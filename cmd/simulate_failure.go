@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var simulateFailureCmd = &cobra.Command{
+	Use:   "simulate-failure --bucket NAME [zs://bucket/prefix]",
+	Short: "Report which objects would become unreadable if the given buckets were lost",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		buckets, _ := cmd.Flags().GetStringSlice("bucket")
+		if len(buckets) == 0 {
+			fmt.Println("Error: at least one --bucket is required")
+			exit(2)
+		}
+
+		prefix := "."
+		if len(args) == 1 {
+			var err error
+			prefix, err = parseZsURL(args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(2)
+			}
+			prefix = strings.TrimSuffix(prefix, "/")
+			if prefix == "" {
+				prefix = "."
+			}
+		}
+
+		simulation, err := fileService.SimulateBucketLoss(context.Background(), prefix, buckets)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error simulating loss of %s: %v\n", strings.Join(buckets, ", "), err)
+			exit(2)
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			if err := printJSON(simulation); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(2)
+			}
+		} else {
+			if len(simulation.Unrecoverable) > 0 {
+				fmt.Println("Unrecoverable:")
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "  OBJECT\tSHARDS LOST\tNEEDED")
+				for _, impact := range simulation.Unrecoverable {
+					fmt.Fprintf(w, "  %s\t%d of %d\t%d\n", impact.Key, impact.ShardsLost, impact.TotalShards, impact.DataShards)
+				}
+				w.Flush()
+				fmt.Println()
+			}
+			fmt.Printf("Losing %s: %d of %d objects affected, %d unrecoverable, %d still readable\n",
+				strings.Join(simulation.Buckets, ", "), simulation.ObjectsAffected, simulation.ObjectsChecked,
+				len(simulation.Unrecoverable), len(simulation.Degraded))
+		}
+
+		if len(simulation.Unrecoverable) > 0 {
+			exit(1)
+		}
+	},
+}
+
+func init() {
+	simulateFailureCmd.Flags().StringSlice("bucket", nil, "Bucket to simulate losing (repeat or comma-separate for several)")
+	simulateFailureCmd.Flags().Bool("json", false, "Print the simulation as JSON")
+	rootCmd.AddCommand(simulateFailureCmd)
+}
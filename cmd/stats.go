@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+var statCmd = &cobra.Command{
+	Use:   "stat [zs://bucket/prefix/object]",
+	Short: "Show the stored metadata of an object",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := parseZsURL(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		metadata, err := fileService.StatFile(context.Background(), key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading metadata of %s: %v\n", key, err)
+			exit(1)
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			if err := printJSON(metadata); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+			return
+		}
+
+		fmt.Printf("Key:           %s\n", key)
+		if metadata.OriginalName != "" {
+			fmt.Printf("Original name: %s\n", metadata.OriginalName)
+		}
+		fmt.Printf("Size:          %d bytes\n", metadata.ContentSize())
+		if len(metadata.Transforms) > 0 {
+			fmt.Printf("Transforms:    %s (%d bytes stored before sharding)\n", strings.Join(metadata.Transforms, ", "), metadata.OriginalSize)
+		}
+		if metadata.UnalignedShardSize > 0 {
+			fmt.Printf("Shards:        %d data + %d parity, %d bytes each (%d before alignment)\n", metadata.RequiredShards(), metadata.ParityShards, metadata.ShardSize, metadata.UnalignedShardSize)
+		} else {
+			fmt.Printf("Shards:        %d data + %d parity, %d bytes each\n", metadata.RequiredShards(), metadata.ParityShards, metadata.ShardSize)
+		}
+		fmt.Printf("Stored shards: %d/%d (%d needed, %s)\n", metadata.StoredShards(), len(metadata.ShardHashes), metadata.RequiredShards(), service.RecordedHealth(metadata))
+		fmt.Printf("Stored:        %d bytes\n", metadata.StoredBytes())
+		fmt.Printf("Overhead:      %.2fx\n", metadata.Overhead())
+		fmt.Printf("Uploaded:      %s\n", formatCreatedAt(metadata.CreatedAt))
+		fmt.Printf("ETag:          %s\n", metadata.ETag())
+		if metadata.ContentType != "" {
+			fmt.Printf("Content type:  %s\n", metadata.ContentType)
+		}
+		if len(metadata.UserMetadata) > 0 {
+			names := make([]string, 0, len(metadata.UserMetadata))
+			for name := range metadata.UserMetadata {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fmt.Printf("Tags:\n")
+			for _, name := range names {
+				fmt.Printf("  %s=%s\n", name, metadata.UserMetadata[name])
+			}
+		}
+		if !metadata.RetainUntil.IsZero() {
+			fmt.Printf("Retention:     %s until %s\n", strings.ToLower(metadata.RetentionMode), formatCreatedAt(metadata.RetainUntil))
+		}
+		if metadata.DRCopy != nil {
+			drCopy := service.DRTarget{Type: objectstore.RepositoryType(metadata.DRCopy.StorageType), Bucket: metadata.DRCopy.BucketName, Prefix: metadata.DRCopy.Key}
+			fmt.Printf("DR copy:       %s\n", drCopy)
+		}
+		if len(metadata.ArchiveMembers) > 0 {
+			fmt.Printf("Archive:       %d files\n", len(metadata.ArchiveMembers))
+			for _, member := range metadata.ArchiveMembers {
+				fmt.Printf("  %10d  %s  %s\n", member.Size, formatCreatedAt(member.ModTime), member.Name)
+			}
+		}
+	},
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [zs://bucket/prefix]",
+	Short: "Report the erasure coding storage overhead of the files under a prefix",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		prefix, err := parseZsURL(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		prefix = strings.TrimSuffix(prefix, "/")
+
+		stats, err := fileService.Stats(context.Background(), prefix)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error computing stats for %s: %v\n", prefix, err)
+			exit(1)
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			if err := printJSON(stats); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+			return
+		}
+
+		fmt.Printf("Prefix:           %s\n", stats.Prefix)
+		fmt.Printf("Objects:          %d\n", stats.Objects)
+		fmt.Printf("Original bytes:   %s\n", formatBytes(stats.OriginalBytes))
+		fmt.Printf("Stored bytes:     %s\n", formatBytes(stats.StoredBytes))
+		if stats.Objects > 0 {
+			fmt.Printf("Average overhead: %.2fx (min %.2fx, max %.2fx)\n", stats.AverageOverhead, stats.MinOverhead, stats.MaxOverhead)
+			fmt.Printf("Total overhead:   %.2fx\n", stats.TotalOverhead)
+		}
+	},
+}
+
+func init() {
+	statCmd.Flags().Bool("json", false, "Print the metadata record as JSON")
+	statsCmd.Flags().Bool("json", false, "Print the statistics as JSON")
+	rootCmd.AddCommand(statCmd)
+	rootCmd.AddCommand(statsCmd)
+}
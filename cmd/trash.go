@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [zs://bucket/prefix/object]",
+	Short: "Bring a deleted file back from the trash (requires soft_delete)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := parseZsURL(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		if err := fileService.RestoreFile(context.Background(), key); err != nil {
+			fmt.Printf("Error restoring file: %v\n", err)
+			exit(1)
+		}
+		fmt.Printf("File restored successfully: %s\n", key)
+	},
+}
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge [zs://bucket/prefix/object | zs://bucket/prefix --expired]",
+	Short: "Permanently remove a deleted file, or every deleted file past the retention window",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := parseZsURL(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		if expired, _ := cmd.Flags().GetBool("expired"); expired {
+			purged, err := fileService.PurgeExpired(context.Background(), strings.TrimSuffix(key, "/"))
+			for _, purgedKey := range purged {
+				fmt.Printf("Purged %s\n", purgedKey)
+			}
+			if err != nil {
+				fmt.Printf("Error purging expired files: %v\n", err)
+				exit(1)
+			}
+			fmt.Printf("Purged %d expired file(s)\n", len(purged))
+			return
+		}
+
+		if err := fileService.PurgeFile(context.Background(), key); err != nil {
+			fmt.Printf("Error purging file: %v\n", err)
+			exit(1)
+		}
+		fmt.Printf("File purged successfully: %s\n", key)
+	},
+}
+
+var trashCmd = &cobra.Command{
+	Use:   "trash [zs://bucket/prefix]",
+	Short: "List deleted files that can still be restored or purged",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		prefix, err := parseZsURL(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		files, err := fileService.ListTrash(context.Background(), strings.TrimSuffix(prefix, "/"))
+		if err != nil {
+			fmt.Printf("Error listing trash: %v\n", err)
+			exit(1)
+		}
+		if len(files) == 0 {
+			fmt.Printf("No deleted files in %s\n", args[0])
+			return
+		}
+
+		fmt.Printf("Deleted files in %s:\n", args[0])
+		for _, file := range files {
+			line := fmt.Sprintf("  %s\tdeleted %s", file.DisplayName(), formatCreatedAt(file.DeletedAt))
+			if cfg.SoftDeleteRetention > 0 {
+				line = fmt.Sprintf("%s\trestorable until %s", line, formatCreatedAt(file.DeletedAt.Add(cfg.SoftDeleteRetention)))
+			}
+			fmt.Println(line)
+		}
+	},
+}
+
+func init() {
+	purgeCmd.Flags().Bool("expired", false, "Purge every deleted file under the prefix whose soft_delete_retention has passed")
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(purgeCmd)
+	rootCmd.AddCommand(trashCmd)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+var uploadBatchCmd = &cobra.Command{
+	Use:   "upload-batch [jobs-file]",
+	Short: "Upload a list of files to zs:// keys, each with an optional shard layout (- reads the list from stdin)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		input := os.Stdin
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(2)
+			}
+			defer f.Close()
+			input = f
+		}
+		jobs, err := service.ParseBatchJobs(input)
+		if err != nil {
+			fmt.Printf("Error reading %s: %v\n", args[0], err)
+			exit(2)
+		}
+		if len(jobs) == 0 {
+			fmt.Println("No upload jobs found")
+			return
+		}
+
+		dataShards, _ := cmd.Flags().GetInt("data-shards")
+		parityShards, _ := cmd.Flags().GetInt("parity-shards")
+		if err := service.ValidateShardConfig(dataShards, parityShards); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(2)
+		}
+
+		quiet, _ := cmd.Flags().GetBool("quiet")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		concurrency := applyConcurrency(cmd)
+		mirrorFactor, _ := cmd.Flags().GetInt("mirror-factor")
+		fileService.SetMirrorFactor(mirrorFactor)
+		if err := applyCustomerKey(cmd); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(2)
+		}
+		if err := applyRetention(cmd); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(2)
+		}
+
+		// The bar would interleave with the JSON document
+		result := fileService.UploadBatch(context.Background(), jobs, quiet || asJSON, dataShards, parityShards, concurrency)
+
+		if asJSON {
+			if err := printJSON(result); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+		} else {
+			for _, job := range result.Jobs {
+				if job.Err != "" {
+					fmt.Printf("FAILED  %s -> %s: %s\n", job.Source, job.Destination, job.Err)
+				} else {
+					fmt.Printf("OK      %s -> %s\n", job.Source, job.Destination)
+				}
+			}
+			fmt.Printf("Uploaded %d of %d file(s), %d failed\n", len(result.Jobs)-result.Failed, len(result.Jobs), result.Failed)
+		}
+		if result.Failed > 0 {
+			exit(1)
+		}
+	},
+}
+
+func init() {
+	uploadBatchCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress progress bars")
+	uploadBatchCmd.Flags().Int("data-shards", 4, "Number of data shards for jobs without their own layout")
+	uploadBatchCmd.Flags().Int("parity-shards", 2, "Number of parity shards for jobs without their own layout")
+	uploadBatchCmd.Flags().Int("concurrency", 3, "Number of concurrent shard uploads")
+	uploadBatchCmd.Flags().Int("upload-concurrency", 0, "Number of concurrent shard uploads (default: --concurrency)")
+	uploadBatchCmd.Flags().Int("mirror-factor", 1, "Number of copies of each shard, each stored in a different bucket")
+	uploadBatchCmd.Flags().String("sse-customer-key", "", "Base64 256-bit key for provider-side encryption (S3 SSE-C, GCS CSEK); never stored, required again to download")
+	uploadBatchCmd.Flags().String("retention-mode", "", "Lock uploaded shards in governance or compliance mode (default: retention_mode from config)")
+	uploadBatchCmd.Flags().String("retain-until", "", "End of the lock as an RFC 3339 date (default: now + retention_period from config)")
+	uploadBatchCmd.Flags().Bool("json", false, "Print the result of every job as JSON")
+	rootCmd.AddCommand(uploadBatchCmd)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [directory] [zs://bucket/prefix]",
+	Short: "Upload new and changed files under a directory as they change, until interrupted",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := args[0]
+
+		prefix := filepath.Base(filepath.Clean(dir))
+		if len(args) == 2 {
+			var err error
+			prefix, err = parseZsURL(args[1])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			prefix = strings.TrimSuffix(prefix, "/")
+		}
+
+		dataShards, _ := cmd.Flags().GetInt("data-shards")
+		parityShards, _ := cmd.Flags().GetInt("parity-shards")
+		if err := service.ValidateShardConfig(dataShards, parityShards); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		concurrency := applyConcurrency(cmd)
+		mirrorFactor, _ := cmd.Flags().GetInt("mirror-factor")
+		fileService.SetMirrorFactor(mirrorFactor)
+		if err := applyCustomerKey(cmd); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		ignore, _ := cmd.Flags().GetStringSlice("ignore")
+		debounce, _ := cmd.Flags().GetDuration("debounce")
+		deleteRemote, _ := cmd.Flags().GetBool("delete")
+
+		// Progress bars would interleave with the per-file lines
+		fileService.SetProgressOutput(io.Discard)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		log.Infof("Watching %s -> %s (press Ctrl+C to stop)", dir, prefix)
+		err := fileService.WatchDir(ctx, dir, service.WatchOptions{
+			Prefix:       prefix,
+			Ignore:       ignore,
+			Debounce:     debounce,
+			DeleteRemote: deleteRemote,
+			DataShards:   dataShards,
+			ParityShards: parityShards,
+			Concurrency:  concurrency,
+			OnEvent:      printWatchEvent,
+		})
+		if err != nil {
+			fmt.Printf("Error watching %s: %v\n", dir, err)
+			exit(1)
+		}
+		fmt.Println("Watch stopped")
+	},
+}
+
+// printWatchEvent prints one line per file the watch acted on; unchanged files are only logged
+func printWatchEvent(event service.WatchEvent) {
+	switch event.Action {
+	case service.WatchUnchanged:
+		log.Debugf("Unchanged: %s", event.Path)
+	case service.WatchSkipped:
+		fmt.Printf("Skipped %s: %s\n", event.Path, event.Reason)
+	case service.WatchFailed:
+		fmt.Printf("FAILED %s -> %s: %v\n", event.Path, event.Key, event.Err)
+	default:
+		fmt.Printf("%s %s -> %s\n", strings.ToUpper(string(event.Action)), event.Path, event.Key)
+	}
+}
+
+func init() {
+	watchCmd.Flags().Int("data-shards", 4, "Number of data shards for erasure coding")
+	watchCmd.Flags().Int("parity-shards", 2, "Number of parity shards for erasure coding")
+	watchCmd.Flags().Int("concurrency", 3, "Number of concurrent shard uploads")
+	watchCmd.Flags().Int("upload-concurrency", 0, "Number of concurrent shard uploads (default: --concurrency)")
+	watchCmd.Flags().Int("mirror-factor", 1, "Number of copies of each shard, each stored in a different bucket")
+	watchCmd.Flags().String("sse-customer-key", "", "Base64 256-bit key for provider-side encryption (S3 SSE-C, GCS CSEK); never stored, required again to download")
+	watchCmd.Flags().StringSlice("ignore", nil, "Do not upload files or directories matching these patterns (e.g. '*.tmp,.git'; comma-separated or repeated)")
+	watchCmd.Flags().Duration("debounce", 2*time.Second, "Wait this long after a file's last change before uploading it")
+	watchCmd.Flags().Bool("delete", false, "Delete a file's object when the file is removed")
+	rootCmd.AddCommand(watchCmd)
+}
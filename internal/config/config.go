@@ -3,22 +3,40 @@ package config
 import (
 	"context"
 	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go/middleware"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/version"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 )
 
 // BucketConfig represents a storage bucket configuration
 type BucketConfig struct {
 	BucketName string `yaml:"bucket_name"`
 	Platform   string `yaml:"platform"`
-	Region     string `yaml:"region"` // Required for S3, optional for GCS
+	Region     string `yaml:"region"` // S3 region (detected automatically when omitted or wrong), optional for GCS
+	MaxObjects int64  `yaml:"max_objects"` // Quota: no shards are placed in the bucket once it holds this many objects (0 is unlimited)
+	MaxBytes   int64  `yaml:"max_bytes"`   // Quota: no shards are placed in the bucket once it stores this many bytes (0 is unlimited)
+}
+
+// ProviderPricing holds the rates used by cost estimates for one storage platform
+type ProviderPricing struct {
+	StoragePerGBMonth float64 `yaml:"storage_per_gb_month"`
+	PerThousandPuts   float64 `yaml:"per_thousand_puts"`
 }
 
 // Config holds the application configuration
@@ -30,12 +48,103 @@ type Config struct {
 	AwsConfig aws.Config
 	// DynamoDBRegion: AWS region for DynamoDB table
 	DynamoDBRegion  string `yaml:"dynamodb_region"`
+	// AWSUseFIPS: send DynamoDB and S3 requests to FIPS 140 validated endpoints
+	AWSUseFIPS bool `yaml:"aws_use_fips"`
+	// AWSEndpointURL: base URL of every AWS request, e.g. a VPC or GovCloud endpoint (empty uses the SDK's resolution)
+	AWSEndpointURL string `yaml:"aws_endpoint_url"`
 	// GcsClient: Google Cloud SDK uses individual service clients that
 	// handle their own configuration internally via environment variables,
 	// service account files, or metadata service. No shared config needed.
 	GcsClient       *storage.Client
 	DynamoDBTable   string                  `yaml:"dynamodb_table"`
+	// DynamoDBPartitionKey/DynamoDBSortKey: key attribute names of a pre-provisioned table
+	DynamoDBPartitionKey string `yaml:"dynamodb_partition_key"`
+	DynamoDBSortKey      string `yaml:"dynamodb_sort_key"`
+	// DynamoDBNamespace: isolates this deployment's items in a table shared with others (empty disables)
+	DynamoDBNamespace string `yaml:"dynamodb_namespace"`
 	Buckets         map[string]BucketConfig `yaml:"buckets"`
+	// MinShardSize: shards smaller than this many bytes trigger fewer data shards (0 disables)
+	MinShardSize int64 `yaml:"min_shard_size"`
+	// ShardAlignment: shards are zero padded to a multiple of this many bytes, e.g. 4096 (0 disables)
+	ShardAlignment int64 `yaml:"shard_alignment"`
+	// Compression: compression applied to uploads before sharding ("zstd"; empty disables)
+	Compression string `yaml:"compression"`
+	// EncryptionKey: base64 256-bit key for client-side encryption before sharding (empty disables)
+	EncryptionKey string `yaml:"encryption_key"`
+	// MaxObjectSize: uploads larger than this many bytes are rejected (0 disables)
+	MaxObjectSize int64 `yaml:"max_object_size"`
+	// ManifestBucket: bucket key that stores object manifests (empty uses the first bucket)
+	ManifestBucket string `yaml:"manifest_bucket"`
+	// ManifestSigningKey: path to a PEM-encoded EC private key used to sign manifests
+	ManifestSigningKey string `yaml:"manifest_signing_key"`
+	// MetadataCacheSize: number of metadata records kept for reads during metadata store outages (0 disables)
+	MetadataCacheSize int `yaml:"metadata_cache_size"`
+	// MetadataCacheTTL: how long a cached metadata record may be served
+	MetadataCacheTTL time.Duration `yaml:"metadata_cache_ttl"`
+	// MetadataRetryAttempts: attempts per metadata request while DynamoDB is throttling or failing transiently (1 disables retries)
+	MetadataRetryAttempts int `yaml:"metadata_retry_attempts"`
+	// MetadataRetryDelay: backoff before the first metadata retry, doubled for each further one
+	MetadataRetryDelay time.Duration `yaml:"metadata_retry_delay"`
+	// MetadataWriteAttempts: attempts at an upload's metadata write, on transient errors, before its shards are rolled back
+	MetadataWriteAttempts int `yaml:"metadata_write_attempts"`
+	// MetadataWriteDelay: backoff before the first retry of an upload's metadata write, doubled for each further one
+	MetadataWriteDelay time.Duration `yaml:"metadata_write_delay"`
+	// VerifyMetadataChecksum: reject metadata records whose stored checksum is missing or does not match
+	VerifyMetadataChecksum bool `yaml:"verify_metadata_checksum"`
+	// DebugHTTP: log cloud SDK requests and responses (credentials are redacted)
+	DebugHTTP bool `yaml:"debug_http"`
+	// Placement: shard placement strategy ("round-robin" or "least-loaded")
+	Placement string `yaml:"placement"`
+	// PlacementUsageTTL: how long bucket usage is cached by the least-loaded placer
+	PlacementUsageTTL time.Duration `yaml:"placement_usage_ttl"`
+	// TempDir: directory for shard temp files during downloads (empty uses the system temp directory)
+	TempDir string `yaml:"temp_dir"`
+	// TempFilePrefix: name prefix of zstore's temp files; the startup sweep only removes files with it
+	TempFilePrefix string `yaml:"temp_file_prefix"`
+	// TempSweep: remove stale zstore temp files from TempDir on startup
+	TempSweep bool `yaml:"temp_sweep"`
+	// TempSweepAge: temp files last modified longer ago than this are removed by the sweep
+	TempSweepAge time.Duration `yaml:"temp_sweep_age"`
+	// RetentionMode: object lock mode for uploaded shards ("governance" or "compliance"; empty disables)
+	RetentionMode string `yaml:"retention_mode"`
+	// RetentionPeriod: how long uploaded shards are locked, counted from the upload
+	RetentionPeriod time.Duration `yaml:"retention_period"`
+	// S3ChecksumAlgorithm: checksum S3 verifies on every upload ("crc32c" or "sha256"; empty leaves the SDK default)
+	S3ChecksumAlgorithm string `yaml:"s3_checksum_algorithm"`
+	// AuditLog: where audit records of uploads, downloads and deletes go ("file", "dynamodb"; empty disables)
+	AuditLog string `yaml:"audit_log"`
+	// AuditLogFile: file audit records are appended to when AuditLog is "file"
+	AuditLogFile string `yaml:"audit_log_file"`
+	// AuditLogTable: DynamoDB table audit records are written to when AuditLog is "dynamodb"
+	AuditLogTable string `yaml:"audit_log_table"`
+	// SoftDelete: delete moves objects to the trash, keeping their shards until they are purged
+	SoftDelete bool `yaml:"soft_delete"`
+	// SoftDeleteRetention: how long deleted objects can be restored (0 keeps them restorable until purged)
+	SoftDeleteRetention time.Duration `yaml:"soft_delete_retention"`
+	// EncoderGoroutines: goroutines each Reed-Solomon encode is split into (0 picks them from the shard size and CPU count)
+	EncoderGoroutines int `yaml:"encoder_goroutines"`
+	// MaxConcurrentDownloads: downloads that may read and reconstruct objects at once (0 is unlimited)
+	MaxConcurrentDownloads int `yaml:"max_concurrent_downloads"`
+	// DownloadLimitMode: what happens to downloads beyond the limit ("queue" or "reject")
+	DownloadLimitMode string `yaml:"download_limit_mode"`
+	// ReconstructionCacheDir: local directory caching reconstructed objects for repeated reads (empty disables)
+	ReconstructionCacheDir string `yaml:"reconstruction_cache_dir"`
+	// ReconstructionCacheSize: bytes the reconstruction cache may hold before evicting the least recently used objects
+	ReconstructionCacheSize int64 `yaml:"reconstruction_cache_size"`
+	// ReconstructionCacheTTL: how long a cached object is served (0 keeps it until evicted)
+	ReconstructionCacheTTL time.Duration `yaml:"reconstruction_cache_ttl"`
+	// InitialDownloadBuffer: shards beyond the needed ones each download starts with (negative starts the download concurrency)
+	InitialDownloadBuffer int `yaml:"initial_download_buffer"`
+	// VerifySampleRate: fraction of shards (0-1) hash-checked on reads without --verify-integrity
+	VerifySampleRate float64 `yaml:"verify_sample_rate"`
+	// ShardKeyScheme: how new shards are named ("content" for key/hash, "indexed" for key/index-hash)
+	ShardKeyScheme string `yaml:"shard_key_scheme"`
+	// UploadLogDir: local directory recording uploads in progress so `zstore recover` can resolve crashed ones (empty disables)
+	UploadLogDir string `yaml:"upload_log_dir"`
+	// CaseInsensitiveKeys: lower-case object keys on upload and lookup, so keys differing only in case name one object
+	CaseInsensitiveKeys bool `yaml:"case_insensitive_keys"`
+	// Pricing: per-platform rates used by cost estimates, keyed by platform ("s3", "gcs", "ipfs")
+	Pricing map[string]ProviderPricing `yaml:"pricing"`
 }
 
 // LoadConfig loads configuration from config.yaml, environment variables, or CLI flags
@@ -55,25 +164,100 @@ func LoadConfig(configPath string, rootCmd *cobra.Command) (*Config, error) {
 		return nil, err
 	}
 
-	awsConfig, dynamoDBRegion, err := loadAWSConfig()
+	debugHTTP := viper.GetBool("debug-http")
+
+	awsConfig, dynamoDBRegion, err := loadAWSConfig(debugHTTP)
 	if err != nil {
 		return nil, err
 	}
 
-	gcsClient, err := loadGCSClient()
+	gcsClient, err := loadGCSClient(debugHTTP)
 	if err != nil {
 		return nil, err
 	}
 
 	buckets := parseBuckets()
 
+	signingKeyPath := viper.GetString("manifest_signing_key")
+	privateKey, err := LoadECDSAPrivateKey(signingKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	var publicKey *ecdsa.PublicKey
+	if privateKey != nil {
+		publicKey = &privateKey.PublicKey
+	}
+
 	return &Config{
 		LogLevel:       viper.GetString("log_level"),
 		AwsConfig:      awsConfig,
+		AWSUseFIPS:     viper.GetBool("aws_use_fips"),
+		AWSEndpointURL: viper.GetString("aws_endpoint_url"),
 		DynamoDBRegion: dynamoDBRegion,
 		GcsClient:      gcsClient,
 		DynamoDBTable:  viper.GetString("dynamodb_table"),
+
+		DynamoDBPartitionKey: viper.GetString("dynamodb_partition_key"),
+		DynamoDBSortKey:      viper.GetString("dynamodb_sort_key"),
+		DynamoDBNamespace:    viper.GetString("dynamodb_namespace"),
+
 		Buckets:        buckets,
+		MinShardSize:   viper.GetInt64("min_shard_size"),
+		ShardAlignment: viper.GetInt64("shard_alignment"),
+		Compression:    viper.GetString("compression"),
+		EncryptionKey:  viper.GetString("encryption_key"),
+		MaxObjectSize:  viper.GetInt64("max_object_size"),
+		DebugHTTP:      debugHTTP,
+		ManifestBucket: viper.GetString("manifest_bucket"),
+
+		MetadataCacheSize: viper.GetInt("metadata_cache_size"),
+		MetadataCacheTTL:  viper.GetDuration("metadata_cache_ttl"),
+
+		MetadataRetryAttempts: viper.GetInt("metadata_retry_attempts"),
+		MetadataRetryDelay:    viper.GetDuration("metadata_retry_delay"),
+		MetadataWriteAttempts: viper.GetInt("metadata_write_attempts"),
+		MetadataWriteDelay:    viper.GetDuration("metadata_write_delay"),
+
+		VerifyMetadataChecksum: viper.GetBool("verify_metadata_checksum"),
+
+		Placement:         viper.GetString("placement"),
+		PlacementUsageTTL: viper.GetDuration("placement_usage_ttl"),
+
+		TempDir:        viper.GetString("temp_dir"),
+		TempFilePrefix: viper.GetString("temp_file_prefix"),
+		TempSweep:      viper.GetBool("temp_sweep"),
+		TempSweepAge:   viper.GetDuration("temp_sweep_age"),
+
+		RetentionMode:   viper.GetString("retention_mode"),
+		RetentionPeriod: viper.GetDuration("retention_period"),
+
+		S3ChecksumAlgorithm: viper.GetString("s3_checksum_algorithm"),
+
+		AuditLog:      viper.GetString("audit_log"),
+		AuditLogFile:  viper.GetString("audit_log_file"),
+		AuditLogTable: viper.GetString("audit_log_table"),
+
+		SoftDelete:          viper.GetBool("soft_delete"),
+		SoftDeleteRetention: viper.GetDuration("soft_delete_retention"),
+		CaseInsensitiveKeys: viper.GetBool("case_insensitive_keys"),
+		EncoderGoroutines:   viper.GetInt("encoder_goroutines"),
+
+		MaxConcurrentDownloads: viper.GetInt("max_concurrent_downloads"),
+		DownloadLimitMode:      viper.GetString("download_limit_mode"),
+		InitialDownloadBuffer:  viper.GetInt("initial_download_buffer"),
+		VerifySampleRate:       viper.GetFloat64("verify_sample_rate"),
+		ShardKeyScheme:         viper.GetString("shard_key_scheme"),
+		UploadLogDir:           viper.GetString("upload_log_dir"),
+
+		ReconstructionCacheDir:  viper.GetString("reconstruction_cache_dir"),
+		ReconstructionCacheSize: viper.GetInt64("reconstruction_cache_size"),
+		ReconstructionCacheTTL:  viper.GetDuration("reconstruction_cache_ttl"),
+
+		Pricing: parsePricing(),
+
+		ManifestSigningKey: signingKeyPath,
+		ECDSAPrivateKey:    privateKey,
+		ECDSAPublicKey:     publicKey,
 	}, nil
 }
 
@@ -108,6 +292,50 @@ func setupViper(configPath string, rootCmd *cobra.Command) error {
 func setDefaults() {
 	viper.SetDefault("log_level", "info")
 	viper.SetDefault("dynamodb_table", "default-table")
+	viper.SetDefault("dynamodb_partition_key", "prefix")
+	viper.SetDefault("dynamodb_sort_key", "file_name")
+	viper.SetDefault("aws_use_fips", false)
+	viper.SetDefault("aws_endpoint_url", "")
+	viper.SetDefault("min_shard_size", 0)
+	viper.SetDefault("shard_alignment", 0)
+	viper.SetDefault("compression", "")
+	viper.SetDefault("encryption_key", "")
+	viper.SetDefault("max_object_size", 0)
+	viper.SetDefault("debug-http", false)
+	viper.SetDefault("metadata_cache_size", 1024)
+	viper.SetDefault("metadata_cache_ttl", "5m")
+	viper.SetDefault("metadata_retry_attempts", 5)
+	viper.SetDefault("metadata_retry_delay", "100ms")
+	viper.SetDefault("metadata_write_attempts", 3)
+	viper.SetDefault("metadata_write_delay", "200ms")
+	viper.SetDefault("verify_metadata_checksum", false)
+	viper.SetDefault("placement", "round-robin")
+	viper.SetDefault("placement_usage_ttl", "10m")
+	viper.SetDefault("temp_file_prefix", "zstore_")
+	viper.SetDefault("temp_sweep", false)
+	viper.SetDefault("temp_sweep_age", "24h")
+	viper.SetDefault("s3_checksum_algorithm", "")
+	viper.SetDefault("audit_log", "")
+	viper.SetDefault("audit_log_file", "zstore-audit.log")
+	viper.SetDefault("audit_log_table", "audit_log")
+	viper.SetDefault("soft_delete", false)
+	viper.SetDefault("soft_delete_retention", "720h")
+	viper.SetDefault("case_insensitive_keys", false)
+	viper.SetDefault("encoder_goroutines", 0)
+	viper.SetDefault("max_concurrent_downloads", 0)
+	viper.SetDefault("download_limit_mode", "queue")
+	viper.SetDefault("initial_download_buffer", -1)
+	viper.SetDefault("verify_sample_rate", 0.0)
+	viper.SetDefault("shard_key_scheme", "content")
+	viper.SetDefault("upload_log_dir", "")
+	viper.SetDefault("reconstruction_cache_dir", "")
+	viper.SetDefault("reconstruction_cache_size", 1073741824)
+	viper.SetDefault("reconstruction_cache_ttl", "1h")
+	viper.SetDefault("pricing", map[string]interface{}{
+		"s3":   map[string]interface{}{"storage_per_gb_month": 0.023, "per_thousand_puts": 0.005},
+		"gcs":  map[string]interface{}{"storage_per_gb_month": 0.020, "per_thousand_puts": 0.005},
+		"ipfs": map[string]interface{}{"storage_per_gb_month": 0.0, "per_thousand_puts": 0.0},
+	})
 	viper.SetDefault("buckets", map[string]interface{}{
 		"default-bucket": map[string]interface{}{
 			"bucket_name": "default-bucket",
@@ -117,7 +345,7 @@ func setDefaults() {
 }
 
 // loadAWSConfig loads AWS SDK configuration for DynamoDB with explicit region handling
-func loadAWSConfig() (aws.Config, string, error) {
+func loadAWSConfig(debugHTTP bool) (aws.Config, string, error) {
 	// Priority order for DynamoDB region configuration:
 	// 1. config.yaml: dynamodb_region
 	// 2. Environment: AWS_REGION
@@ -135,25 +363,133 @@ func loadAWSConfig() (aws.Config, string, error) {
 		return aws.Config{}, "", errors.ErrAWSRegionNotConfigured
 	}
 	
-	cfg, err := awsconfig.LoadDefaultConfig(
-		context.Background(),
+	opts := []func(*awsconfig.LoadOptions) error{
 		awsconfig.WithRegion(region),
-	)
+		awsconfig.WithAPIOptions([]func(*middleware.Stack) error{AWSUserAgent()}),
+	}
+	endpointOpts, err := AWSEndpointOptions(viper.GetBool("aws_use_fips"), viper.GetString("aws_endpoint_url"))
+	if err != nil {
+		return aws.Config{}, "", err
+	}
+	opts = append(opts, endpointOpts...)
+	if debugHTTP {
+		// Request bodies are never logged; credential headers are redacted by the logger
+		opts = append(opts,
+			awsconfig.WithLogger(awsDebugLogger{}),
+			awsconfig.WithClientLogMode(aws.LogRequest|aws.LogResponse|aws.LogRetries),
+		)
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
 	if err != nil {
 		return aws.Config{}, "", fmt.Errorf("unable to load AWS SDK config: %v", err)
 	}
 	return cfg, region, nil
 }
 
+// AWSEndpointOptions returns the AWS config load options for FIPS endpoints and a custom endpoint URL
+// Both apply to every client built from the loaded config. The SDK resolves FIPS endpoints
+// per service and region (e.g. dynamodb-fips.us-gov-west-1.amazonaws.com); a custom URL
+// replaces that resolution entirely, so it is meant for VPC, GovCloud or ISO endpoints
+// that serve all the AWS APIs zstore uses.
+func AWSEndpointOptions(useFIPS bool, endpointURL string) ([]func(*awsconfig.LoadOptions) error, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if useFIPS {
+		opts = append(opts, awsconfig.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+	if endpointURL != "" {
+		parsed, err := url.Parse(endpointURL)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid aws_endpoint_url %q: expected a URL such as https://s3.us-gov-west-1.amazonaws.com", endpointURL)
+		}
+		opts = append(opts, awsconfig.WithBaseEndpoint(endpointURL))
+	}
+	return opts, nil
+}
+
+// AWSUserAgent returns middleware that adds zstore and its version to the User-Agent of AWS requests
+// Every client built from the loaded aws.Config (DynamoDB and S3) inherits it.
+func AWSUserAgent() func(*middleware.Stack) error {
+	return awsmiddleware.AddUserAgentKeyValue(version.Product, version.Version)
+}
+
 // loadGCSClient loads Google Cloud Storage client
-func loadGCSClient() (*storage.Client, error) {
-	client, err := storage.NewClient(context.Background())
+func loadGCSClient(debugHTTP bool) (*storage.Client, error) {
+	ctx := context.Background()
+	userAgent := option.WithUserAgent(version.UserAgent())
+	opts := []option.ClientOption{userAgent}
+	if debugHTTP {
+		// The logging transport sits beneath authentication and never logs request headers
+		scopes := option.WithScopes(storage.ScopeFullControl, "https://www.googleapis.com/auth/cloud-platform")
+		transport, err := htransport.NewTransport(ctx, &gcsDebugTransport{base: http.DefaultTransport}, scopes, userAgent)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create GCS debug transport: %v", err)
+		}
+		opts = append(opts, option.WithHTTPClient(&http.Client{Transport: transport}))
+	}
+
+	client, err := storage.NewClient(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create GCS client: %v", err)
 	}
 	return client, nil
 }
 
+// LoadECDSAPrivateKey reads a PEM-encoded EC private key (SEC 1 or PKCS #8); an empty path returns nil
+func LoadECDSAPrivateKey(path string) (*ecdsa.PrivateKey, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s is not PEM encoded", path)
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse signing key %s: %w", path, err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not an ECDSA key", path)
+	}
+	return key, nil
+}
+
+// LoadECDSAPublicKey reads a PEM-encoded EC public key (PKIX), or the public half of a private key file
+func LoadECDSAPublicKey(path string) (*ecdsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s is not PEM encoded", path)
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		privateKey, privateErr := LoadECDSAPrivateKey(path)
+		if privateErr != nil {
+			return nil, fmt.Errorf("unable to parse key %s as a public or private key: %w", path, err)
+		}
+		return &privateKey.PublicKey, nil
+	}
+	key, ok := parsed.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("key %s is not an ECDSA key", path)
+	}
+	return key, nil
+}
+
 // parseBuckets parses bucket configuration from Viper
 func parseBuckets() map[string]BucketConfig {
 	bucketsMap := make(map[string]BucketConfig)
@@ -165,6 +501,8 @@ func parseBuckets() map[string]BucketConfig {
 				BucketName: getString(bucketMap, "bucket_name", key),
 				Platform:   getString(bucketMap, "platform", "s3"),
 				Region:     getString(bucketMap, "region", ""),
+				MaxObjects: getInt64(bucketMap, "max_objects"),
+				MaxBytes:   getInt64(bucketMap, "max_bytes"),
 			}
 		}
 	}
@@ -172,6 +510,20 @@ func parseBuckets() map[string]BucketConfig {
 	return bucketsMap
 }
 
+// parsePricing parses per-platform pricing from Viper
+func parsePricing() map[string]ProviderPricing {
+	pricing := make(map[string]ProviderPricing)
+	for platform, value := range viper.GetStringMap("pricing") {
+		if rates, ok := value.(map[string]interface{}); ok {
+			pricing[platform] = ProviderPricing{
+				StoragePerGBMonth: getFloat(rates, "storage_per_gb_month"),
+				PerThousandPuts:   getFloat(rates, "per_thousand_puts"),
+			}
+		}
+	}
+	return pricing
+}
+
 // SetConfigValue sets a configuration value (used for CLI flags)
 func SetConfigValue(key string, value interface{}) {
 	viper.Set(key, value)
@@ -186,3 +538,29 @@ func getString(m map[string]interface{}, key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getInt64 safely extracts an integer value from map, defaulting to zero
+func getInt64(m map[string]interface{}, key string) int64 {
+	switch value := m[key].(type) {
+	case int:
+		return int64(value)
+	case int64:
+		return value
+	case float64:
+		return int64(value)
+	}
+	return 0
+}
+
+// getFloat safely extracts a numeric value from map, defaulting to zero
+func getFloat(m map[string]interface{}, key string) float64 {
+	switch value := m[key].(type) {
+	case float64:
+		return value
+	case int:
+		return float64(value)
+	case int64:
+		return float64(value)
+	}
+	return 0
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/aws/smithy-go/logging"
+	log "github.com/sirupsen/logrus"
+)
+
+// sensitiveHeaderPattern matches request header lines that carry credentials or encryption keys
+// The key digests of customer-provided keys (SSE-C) are included: they identify the key.
+var sensitiveHeaderPattern = regexp.MustCompile(`(?im)^(Authorization|X-Amz-Security-Token|X-Amz-(?:Copy-Source-)?Server-Side-Encryption-Customer-Key(?:-MD5)?|X-Goog-(?:Copy-Source-)?Encryption-Key(?:-Sha256)?):[^\r\n]*`)
+
+// sensitiveQueryPattern matches query parameters of presigned URLs that carry signatures or credentials
+var sensitiveQueryPattern = regexp.MustCompile(`(?i)([?&](?:X-Amz-Signature|X-Amz-Credential|X-Amz-Security-Token|X-Goog-Signature|X-Goog-Credential|Signature|sig)=)[^&\s#]*`)
+
+// RedactHTTPDump replaces the values of credential-bearing headers and presigned URL
+// signatures in an HTTP dump or URL, leaving everything else unchanged.
+func RedactHTTPDump(dump string) string {
+	dump = sensitiveHeaderPattern.ReplaceAllString(dump, "$1: [REDACTED]")
+	return sensitiveQueryPattern.ReplaceAllString(dump, "${1}[REDACTED]")
+}
+
+// awsDebugLogger forwards AWS SDK wire logs to logrus with credentials redacted
+type awsDebugLogger struct{}
+
+// Logf implements the smithy-go logging.Logger interface
+func (awsDebugLogger) Logf(classification logging.Classification, format string, v ...interface{}) {
+	message := RedactHTTPDump(fmt.Sprintf(format, v...))
+	if classification == logging.Warn {
+		log.Warnf("[aws] %s", message)
+		return
+	}
+	log.Infof("[aws] %s", message)
+}
+
+// gcsDebugTransport logs each GCS API call with its status and request ID.
+// Only the method, URL (with presigned signatures redacted) and selected response headers
+// are logged, never request headers.
+type gcsDebugTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *gcsDebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		log.Infof("[gcs] %s %s failed after %v: %v", req.Method, RedactHTTPDump(req.URL.Redacted()), elapsed, err)
+		return resp, err
+	}
+
+	log.Infof("[gcs] %s %s -> %s in %v (request id: %s)",
+		req.Method, RedactHTTPDump(req.URL.Redacted()), resp.Status, elapsed, gcsRequestID(resp.Header))
+	return resp, nil
+}
+
+// gcsRequestID extracts the identifier GCS support uses to trace a request
+func gcsRequestID(header http.Header) string {
+	if id := header.Get("X-Guploader-Uploadid"); id != "" {
+		return id
+	}
+	if id := header.Get("X-Goog-Request-Id"); id != "" {
+		return id
+	}
+	return "unknown"
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// AuditOperation is an operation recorded in the audit log
+type AuditOperation string
+
+const (
+	AuditUpload   AuditOperation = "upload"
+	AuditDownload AuditOperation = "download"
+	AuditDelete   AuditOperation = "delete"
+	AuditPurge    AuditOperation = "purge"
+)
+
+// Audit outcomes
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditResult - what an audited operation did
+type AuditResult struct {
+	Bytes    int64         // Object bytes transferred (0 for deletes)
+	Duration time.Duration // Wall-clock time of the operation
+	Err      error         // nil when the operation succeeded
+}
+
+// AuditRecord - one entry of the audit log
+type AuditRecord struct {
+	ID         string         `json:"id" dynamodbav:"id"` // Time-ordered unique ID (sort key of the audit table)
+	Time       time.Time      `json:"time" dynamodbav:"time"`
+	Operation  AuditOperation `json:"operation" dynamodbav:"operation"`
+	Key        string         `json:"key" dynamodbav:"object_key"` // Partition key of the audit table
+	User       string         `json:"user" dynamodbav:"user"`
+	Bytes      int64          `json:"bytes" dynamodbav:"bytes"`
+	DurationMs int64          `json:"duration_ms" dynamodbav:"duration_ms"`
+	Outcome    string         `json:"outcome" dynamodbav:"outcome"`
+	Error      string         `json:"error,omitempty" dynamodbav:"error,omitempty"`
+}
+
+// NewAuditRecord builds the audit record of an operation that has just finished
+func NewAuditRecord(op AuditOperation, key, user string, result AuditResult) AuditRecord {
+	now := time.Now().UTC()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+
+	record := AuditRecord{
+		ID:         now.Format("20060102T150405.000000000Z") + "-" + hex.EncodeToString(suffix),
+		Time:       now,
+		Operation:  op,
+		Key:        key,
+		User:       user,
+		Bytes:      result.Bytes,
+		DurationMs: result.Duration.Milliseconds(),
+		Outcome:    AuditSuccess,
+	}
+	if result.Err != nil {
+		record.Outcome = AuditFailure
+		record.Error = result.Err.Error()
+	}
+	return record
+}
@@ -0,0 +1,11 @@
+package domain
+
+// ManifestVersion is the current manifest format version
+const ManifestVersion = 1
+
+// Manifest - self-describing copy of an object's metadata stored alongside its shards
+type Manifest struct {
+	Version   int            `json:"version"`
+	Metadata  ObjectMetadata `json:"metadata"`
+	Signature string         `json:"signature,omitempty"` // Base64 ECDSA signature over the canonical metadata
+}
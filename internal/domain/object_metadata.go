@@ -1,19 +1,317 @@
 package domain
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
 // ShardStorage - storage information for a shard
+// A shard can be stored in several places (mirrors, or a repaired copy kept next to the
+// original); Locations lists them in the order reads should try them. A shard that was
+// never stored has no locations.
 type ShardStorage struct {
-	Hash        string `json:"hash" dynamodbav:"hash"`
+	Hash      string
+	Locations []Location
+}
+
+// Location - one stored copy of a shard
+type Location struct {
 	StorageType string `json:"storage_type" dynamodbav:"storage_type"`
 	BucketName  string `json:"bucket_name" dynamodbav:"bucket_name"`
 	Key         string `json:"key" dynamodbav:"key"`
 }
 
+// Primary returns the first location of the shard, or an empty location if it was never stored
+func (s ShardStorage) Primary() Location {
+	if len(s.Locations) == 0 {
+		return Location{}
+	}
+	return s.Locations[0]
+}
+
+// shardStorageRecord - stored form of ShardStorage
+// The first location is kept in the original single-location attributes and any further
+// locations in mirrors, so records written before multiple locations existed read back as
+// one-location shards, older readers still find the first copy, and single-location shards
+// serialize exactly as before (which keeps existing manifest signatures valid).
+type shardStorageRecord struct {
+	Hash        string     `json:"hash" dynamodbav:"hash"`
+	StorageType string     `json:"storage_type" dynamodbav:"storage_type"`
+	BucketName  string     `json:"bucket_name" dynamodbav:"bucket_name"`
+	Key         string     `json:"key" dynamodbav:"key"`
+	Mirrors     []Location `json:"mirrors,omitempty" dynamodbav:"mirrors,omitempty"` // Locations after the first
+}
+
+func (s ShardStorage) record() shardStorageRecord {
+	primary := s.Primary()
+	record := shardStorageRecord{
+		Hash:        s.Hash,
+		StorageType: primary.StorageType,
+		BucketName:  primary.BucketName,
+		Key:         primary.Key,
+	}
+	if len(s.Locations) > 1 {
+		record.Mirrors = s.Locations[1:]
+	}
+	return record
+}
+
+func (r shardStorageRecord) shardStorage() ShardStorage {
+	shard := ShardStorage{Hash: r.Hash}
+	if r.Key != "" {
+		shard.Locations = append(shard.Locations, Location{StorageType: r.StorageType, BucketName: r.BucketName, Key: r.Key})
+	}
+	for _, mirror := range r.Mirrors {
+		if mirror.Key != "" {
+			shard.Locations = append(shard.Locations, mirror)
+		}
+	}
+	return shard
+}
+
+// MarshalJSON writes the shard in its backward-compatible stored form
+func (s ShardStorage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.record())
+}
+
+// UnmarshalJSON reads both single-location and multi-location shard records
+func (s *ShardStorage) UnmarshalJSON(data []byte) error {
+	var record shardStorageRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return err
+	}
+	*s = record.shardStorage()
+	return nil
+}
+
+// MarshalDynamoDBAttributeValue writes the shard in its backward-compatible stored form
+func (s ShardStorage) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	return attributevalue.Marshal(s.record())
+}
+
+// UnmarshalDynamoDBAttributeValue reads both single-location and multi-location shard records
+func (s *ShardStorage) UnmarshalDynamoDBAttributeValue(value types.AttributeValue) error {
+	var record shardStorageRecord
+	if err := attributevalue.Unmarshal(value, &record); err != nil {
+		return err
+	}
+	*s = record.shardStorage()
+	return nil
+}
+
 // ObjectMetadata - representation of an erasure coded object's metadata
 type ObjectMetadata struct {
 	Prefix       string         `json:"prefix" dynamodbav:"prefix"`           // Directory path - Partition Key
 	FileName     string         `json:"file_name" dynamodbav:"file_name"`     // Filename - Sort Key
 	OriginalSize int64          `json:"original_size" dynamodbav:"original_size"`
-	ShardSize    int64          `json:"shard_size" dynamodbav:"shard_size"`
+	ShardSize    int64          `json:"shard_size" dynamodbav:"shard_size"` // Stored bytes per shard, including alignment padding
+	UnalignedShardSize int64    `json:"unaligned_shard_size,omitempty" dynamodbav:"unaligned_shard_size,omitempty"` // Encoded bytes per shard before padding to the shard alignment (0 when not padded)
+	DataShards   int            `json:"data_shards,omitempty" dynamodbav:"data_shards,omitempty"` // Effective data shard count used at upload
 	ParityShards int            `json:"parity_shards" dynamodbav:"parity_shards"`
 	ShardHashes  []ShardStorage `json:"shard_hashes" dynamodbav:"shard_hashes"` // Ordered array of shard storage info
+	FileHash     string         `json:"file_hash,omitempty" dynamodbav:"file_hash,omitempty"` // CRC64 of the original file (of the transformed content when Transforms is set)
+	Transforms   []string       `json:"transforms,omitempty" dynamodbav:"transforms,omitempty"` // Applied to the content before sharding, in order (compression before encryption); OriginalSize and FileHash describe the result
+	PlainSize    int64          `json:"plain_size,omitempty" dynamodbav:"plain_size,omitempty"` // Size of the content before Transforms (0 when none were applied)
+	PlainHash    string         `json:"plain_hash,omitempty" dynamodbav:"plain_hash,omitempty"` // CRC64 of the content before Transforms (empty when none were applied)
+	OriginalName string         `json:"original_name,omitempty" dynamodbav:"original_name,omitempty"` // File name at upload time, including its extension
+	ContentType  string         `json:"content_type,omitempty" dynamodbav:"content_type,omitempty"` // MIME type given at upload (empty to derive it from OriginalName)
+	UserMetadata map[string]string `json:"user_metadata,omitempty" dynamodbav:"user_metadata,omitempty"` // Custom attributes given at upload, served by the gateway as X-Zstore-Meta-* headers
+	DisplayKey   string         `json:"display_key,omitempty" dynamodbav:"display_key,omitempty"` // Key as given at upload when case-insensitive keys stored it in lower case
+	CreatedAt    time.Time      `json:"created_at,omitzero" dynamodbav:"created_at,unixtime"` // Upload time - Sort Key of the prefix/created_at index
+	FileMode     os.FileMode    `json:"file_mode,omitempty" dynamodbav:"file_mode,omitempty"` // Permission bits of the local file at upload (0 when unknown)
+	ModTime      time.Time      `json:"mod_time,omitzero" dynamodbav:"mod_time"`              // Modification time of the local file at upload (zero when unknown)
+	RetentionMode string        `json:"retention_mode,omitempty" dynamodbav:"retention_mode,omitempty"` // Object lock mode of the shards (empty when unlocked)
+	RetainUntil  time.Time      `json:"retain_until,omitzero" dynamodbav:"retain_until"`      // End of the shards' retention period (zero when unlocked)
+	StorageOverhead float64     `json:"storage_overhead,omitempty" dynamodbav:"storage_overhead,omitempty"` // Stored bytes (all shard copies, parity and padding) per original byte
+	Namespace    string         `json:"namespace,omitempty" dynamodbav:"namespace,omitempty"` // Deployment the record belongs to when a metadata table is shared (empty when unset)
+	ArchiveMembers []ArchiveMember `json:"archive_members,omitempty" dynamodbav:"archive_members,omitempty"` // Files inside the object when it is a zstd-compressed tar archive
+	Deleted      bool           `json:"deleted,omitempty" dynamodbav:"deleted,omitempty"` // In the trash: hidden from reads and listings, shards kept until purged
+	DeletedAt    time.Time      `json:"deleted_at,omitzero" dynamodbav:"deleted_at"`      // When the object was moved to the trash (zero when live)
+	DRCopy       *Location      `json:"dr_copy,omitempty" dynamodbav:"dr_copy,omitempty"` // Whole unsharded copy in a disaster recovery bucket (nil when not replicated)
+	MetadataChecksum string     `json:"metadata_checksum,omitempty" dynamodbav:"metadata_checksum,omitempty"` // SHA-256 of the record's other fields (see Checksum; empty for records written before checksums)
+}
+
+// ArchiveMember - one file stored inside an archive object
+type ArchiveMember struct {
+	Name    string    `json:"name" dynamodbav:"name"` // Path inside the archive, slash-separated
+	Size    int64     `json:"size" dynamodbav:"size"` // Uncompressed size
+	ModTime time.Time `json:"mod_time,omitzero" dynamodbav:"mod_time,unixtime"`
+}
+
+// ETag returns a version tag for the object's content
+// The tag is a hash of the shard layout and the shard hashes, so it changes whenever the
+// object is uploaded again or re-encoded, and is derived from the stored metadata alone
+// (records written before tags existed have one too).
+func (m ObjectMetadata) ETag() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d/%d/%d/%d/%s", m.OriginalSize, m.ShardSize, m.DataShards, m.ParityShards, m.FileHash)
+	for _, shard := range m.ShardHashes {
+		fmt.Fprintf(h, "/%s", shard.Hash)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// Checksum returns a hash of the record for detecting corruption or tampering in the metadata store
+// The hash covers the canonical JSON serialization of every field except MetadataChecksum
+// itself (struct fields in declaration order, map keys sorted), so it only matches a
+// record that reads back exactly as it was hashed.
+func (m ObjectMetadata) Checksum() (string, error) {
+	m.MetadataChecksum = ""
+	canonical, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// StoredBytes returns the bytes stored for the object across every stored shard copy,
+// including parity shards, the padding of the last data shard and shard alignment padding
+func (m ObjectMetadata) StoredBytes() int64 {
+	var copies int64
+	for _, shard := range m.ShardHashes {
+		copies += int64(len(shard.Locations))
+	}
+	return copies * m.ShardSize
+}
+
+// ContentSize returns the size of the object's content as it was uploaded and is downloaded
+// Objects compressed or encrypted before sharding record it separately from OriginalSize.
+func (m ObjectMetadata) ContentSize() int64 {
+	if len(m.Transforms) > 0 {
+		return m.PlainSize
+	}
+	return m.OriginalSize
+}
+
+// ContentHash returns the CRC64 of the object's content as it was uploaded and is downloaded
+func (m ObjectMetadata) ContentHash() string {
+	if len(m.Transforms) > 0 {
+		return m.PlainHash
+	}
+	return m.FileHash
+}
+
+// EncodedShardSize returns the bytes of each stored shard that hold Reed-Solomon encoded data
+// Shards padded to the shard alignment are trimmed to this size before they are decoded.
+func (m ObjectMetadata) EncodedShardSize() int64 {
+	if m.UnalignedShardSize > 0 {
+		return m.UnalignedShardSize
+	}
+	return m.ShardSize
+}
+
+// StoredShards returns how many shards have at least one stored copy
+// An upload that tolerated shard failures records the failed shards without a location.
+func (m ObjectMetadata) StoredShards() int {
+	stored := 0
+	for _, shard := range m.ShardHashes {
+		if len(shard.Locations) > 0 {
+			stored++
+		}
+	}
+	return stored
+}
+
+// RequiredShards returns how many shards reconstruction needs
+// Records written before the data shard count was recorded derive it from the shard layout.
+func (m ObjectMetadata) RequiredShards() int {
+	if m.DataShards > 0 {
+		return m.DataShards
+	}
+	return len(m.ShardHashes) - m.ParityShards
+}
+
+// Overhead returns the storage amplification of the object (stored bytes per original byte)
+// Records written before the ratio was recorded get it computed from their shard layout.
+func (m ObjectMetadata) Overhead() float64 {
+	if m.StorageOverhead > 0 {
+		return m.StorageOverhead
+	}
+	if m.OriginalSize == 0 {
+		return 0
+	}
+	return float64(m.StoredBytes()) / float64(m.OriginalSize)
+}
+
+// DisplayName returns the object's key as it should be shown to users
+// With case-insensitive keys this is the key in the case it was uploaded with.
+func (m ObjectMetadata) DisplayName() string {
+	if m.DisplayKey != "" {
+		return m.DisplayKey
+	}
+	return m.Prefix + "/" + m.FileName
+}
+
+// MetadataKey - the prefix and file name identifying an object's metadata record
+type MetadataKey struct {
+	Prefix   string
+	FileName string
+}
+
+// Key returns the key of the metadata record
+func (m ObjectMetadata) Key() MetadataKey {
+	return MetadataKey{Prefix: m.Prefix, FileName: m.FileName}
+}
+
+// PrefixWithin reports whether prefix is parent or one of its sub-prefixes ("parent/...")
+// Every prefix is within the empty parent.
+func PrefixWithin(prefix, parent string) bool {
+	return parent == "" || prefix == parent || strings.HasPrefix(prefix, parent+"/")
+}
+
+// ValidMetadataName reports whether a user metadata name is an HTTP header token (RFC 9110)
+// The gateway serves user metadata as headers, so names are checked when set and when served.
+func ValidMetadataName(name string) bool {
+	return name != "" && !strings.ContainsFunc(name, func(r rune) bool {
+		return r > '~' || r <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r)
+	})
+}
+
+// MetadataSortField - attribute to order object listings by
+type MetadataSortField string
+
+const (
+	SortBySize MetadataSortField = "size"
+	SortByDate MetadataSortField = "date"
+)
+
+// SortOrder - direction of an ordered listing
+type SortOrder string
+
+const (
+	SortAscending  SortOrder = "asc"
+	SortDescending SortOrder = "desc"
+)
+
+// SortObjectMetadata orders metadata in place by size or upload time, breaking ties by file name
+func SortObjectMetadata(list []ObjectMetadata, sortBy MetadataSortField, order SortOrder) {
+	less := func(a, b ObjectMetadata) bool {
+		switch sortBy {
+		case SortBySize:
+			if a.ContentSize() != b.ContentSize() {
+				return a.ContentSize() < b.ContentSize()
+			}
+		case SortByDate:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+		}
+		return a.FileName < b.FileName
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if order == SortDescending {
+			return less(list[j], list[i])
+		}
+		return less(list[i], list[j])
+	})
 }
@@ -12,6 +12,40 @@ var (
 	ErrInsufficientShards    = errors.New("insufficient shards available for reconstruction")
 	ErrEmptyFile             = errors.New("cannot upload empty file")
 	ErrFileIntegrityCheck    = errors.New("file integrity check failed")
+	ErrInvalidRange          = errors.New("requested range is not satisfiable")
+	ErrMetadataNotFound      = errors.New("metadata not found")
+	ErrObjectNotFound        = errors.New("object not found in storage")
+	ErrManifestSignature     = errors.New("manifest signature is missing or invalid")
+	ErrManifestNotConfigured = errors.New("no manifest bucket is available")
+	ErrShardFailureTolerance = errors.New("max shard failures cannot exceed the parity shard count")
+	ErrNoBucketsRegistered   = errors.New("no storage buckets are configured; add at least one entry under 'buckets' in the config file")
+	ErrInvalidShardConfig    = errors.New("invalid shard configuration")
+	ErrInvalidListSort       = errors.New("invalid list sort")
+	ErrMirrorFactor          = errors.New("mirror factor exceeds the number of buckets")
+	ErrNotModified           = errors.New("object not modified")
+	ErrObjectLocked          = errors.New("object is locked by a retention policy")
+	ErrMetadataThrottled     = errors.New("metadata store request was throttled")
+	ErrMetadataUnavailable   = errors.New("metadata store is temporarily unavailable")
+	ErrSizeMismatch          = errors.New("reconstructed size does not match metadata")
+	ErrArchiveMemberNotFound = errors.New("archive has no such member")
+	ErrNotInTrash            = errors.New("object is not in the trash")
+	ErrTrashExpired          = errors.New("object's trash retention window has passed")
+	ErrObjectTooLarge        = errors.New("object exceeds the maximum object size")
+	ErrBucketsAtQuota        = errors.New("every bucket is at its quota")
+	ErrInvalidListPattern    = errors.New("invalid list pattern")
+	ErrShardIndexOutOfRange  = errors.New("shard index out of range")
+	ErrBucketNotRegistered   = errors.New("bucket is not registered")
+	ErrUploadRolledBack      = errors.New("upload failed and its shards were removed")
+	ErrTooManyDownloads      = errors.New("too many concurrent downloads")
+	ErrMetadataChecksum      = errors.New("metadata checksum is missing or does not match the record")
+	ErrPlacementTooFewBuckets = errors.New("too few buckets given for the shard configuration")
+	ErrInvalidMetadataUpdate = errors.New("invalid metadata update")
+	ErrTransactionTooLarge   = errors.New("metadata transaction exceeds the store's limits")
+	ErrNoDRCopy              = errors.New("object has no DR copy")
+	ErrDRNotConfigured       = errors.New("no DR service is configured for DR copies")
+	ErrInvalidTransforms     = errors.New("invalid content transforms; compression must come before encryption")
+	ErrEncryptionKeyRequired = errors.New("object is encrypted; an encryption key is required to read it")
+	ErrDecryptionFailed      = errors.New("object could not be decrypted with the configured key")
 	ErrAWSRegionNotConfigured = errors.New(`DynamoDB region not configured. Please set region using one of:
 1. config.yaml: dynamodb_region: us-east-1
 2. Environment: export AWS_REGION=us-east-1
@@ -0,0 +1,251 @@
+// Package gateway exposes erasure-coded objects over HTTP.
+//
+// The gateway is a thin read-only HTTP layer on top of FileService:
+// - GET /objects/{key} serves an object, streaming data shards as they arrive
+// - Range requests are honored with 206 Partial Content, backed by DownloadRange
+// - Unsatisfiable ranges are rejected with 416 Range Not Satisfiable
+// - Objects carry an ETag; a matching If-None-Match gets 304 Not Modified from metadata alone
+// - Requests beyond the service's download limit are queued or rejected with 503 Service Unavailable
+// - The stored content type and custom metadata are sent as Content-Type and X-Zstore-Meta-* headers
+//
+// Objects are addressed by the same key used with zs:// URLs, so
+// zs://photos/cat.jpg is served at /objects/photos/cat.jpg.
+package gateway
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+// ObjectService is the subset of FileService the gateway depends on
+type ObjectService interface {
+	StatFile(ctx context.Context, key string) (domain.ObjectMetadata, error)
+	DownloadRange(ctx context.Context, key string, offset, length int64, dest io.Writer, verifyIntegrity bool) error
+	StreamFile(ctx context.Context, key string, dest io.Writer, verifyIntegrity bool) error
+}
+
+// downloadLimiter is implemented by services that limit concurrent downloads
+// The gateway takes the slot before sending headers, so a rejection can still become a 503.
+type downloadLimiter interface {
+	AcquireDownloadSlot(ctx context.Context) (context.Context, func(), error)
+}
+
+// Handler serves objects stored in zstore over HTTP
+type Handler struct {
+	service ObjectService
+	mux     *http.ServeMux
+}
+
+// NewHandler creates a new gateway handler backed by the given object service
+func NewHandler(service ObjectService) *Handler {
+	h := &Handler{
+		service: service,
+		mux:     http.NewServeMux(),
+	}
+	h.mux.HandleFunc("GET /objects/{key...}", h.getObject)
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// getObject serves a whole object or a single byte range of it
+func (h *Handler) getObject(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		http.Error(w, "object key is required", http.StatusBadRequest)
+		return
+	}
+
+	metadata, err := h.service.StatFile(r.Context(), key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	size := metadata.ContentSize()
+
+	etag := `"` + metadata.ETag() + `"`
+	w.Header().Set("ETag", etag)
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	offset, length := int64(0), size
+	status := http.StatusOK
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		offset, length, err = parseRange(rangeHeader, size)
+		if err != nil {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, size))
+	}
+
+	ctx := r.Context()
+	if limiter, ok := h.service.(downloadLimiter); ok && r.Method != http.MethodHead {
+		var release func()
+		ctx, release, err = limiter.AcquireDownloadSlot(ctx)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		defer release()
+	}
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	setContentHeaders(w, metadata)
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	w.WriteHeader(status)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+	// Headers are already sent, so failures past this point can only be logged
+	if status == http.StatusOK {
+		err = h.service.StreamFile(ctx, key, flushWriter{w}, false)
+	} else {
+		// A range reads few shards, so checking them is cheap and lets corrupt stripes be rebuilt
+		err = h.service.DownloadRange(ctx, key, offset, length, w, true)
+	}
+	if err != nil {
+		log.Errorf("Gateway failed to serve %s: %v", key, err)
+	}
+}
+
+// flushWriter flushes the response after every write so streamed shards reach the client immediately
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err == nil {
+		http.NewResponseController(f.w).Flush()
+	}
+	return n, err
+}
+
+// userMetadataHeaderPrefix prefixes the header of each custom metadata attribute
+const userMetadataHeaderPrefix = "X-Zstore-Meta-"
+
+// setContentHeaders sets Content-Type, Content-Disposition and X-Zstore-Meta-* from the object's metadata
+// The content type recorded at upload wins; otherwise it is derived from the name recorded at
+// upload, and objects without either, or with an unknown extension, are served as octet streams.
+// Attributes whose names are not valid header names are not sent.
+func setContentHeaders(w http.ResponseWriter, metadata domain.ObjectMetadata) {
+	contentType := metadata.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+		if byExtension := mime.TypeByExtension(filepath.Ext(metadata.OriginalName)); metadata.OriginalName != "" && byExtension != "" {
+			contentType = byExtension
+		}
+	}
+	if metadata.OriginalName != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": metadata.OriginalName}))
+	}
+	w.Header().Set("Content-Type", contentType)
+
+	for name, value := range metadata.UserMetadata {
+		if !domain.ValidMetadataName(name) {
+			log.Warnf("Not sending metadata attribute %q: not a valid header name", name)
+			continue
+		}
+		w.Header().Set(userMetadataHeaderPrefix+name, value)
+	}
+}
+
+// etagMatches reports whether an If-None-Match header lists the given quoted ETag
+// Comparison is weak, as RFC 9110 requires for If-None-Match, so W/ prefixes are ignored.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRange parses a single-range "bytes=" header against an object of the given size
+// Supported forms: "bytes=start-end", "bytes=start-" and "bytes=-suffixLength"
+func parseRange(header string, size int64) (int64, int64, error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: unsupported range unit", errors.ErrInvalidRange)
+	}
+	if strings.Contains(spec, ",") {
+		return 0, 0, fmt.Errorf("%w: multiple ranges are not supported", errors.ErrInvalidRange)
+	}
+
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: malformed range %q", errors.ErrInvalidRange, spec)
+	}
+
+	// Suffix range: last N bytes of the object
+	if startStr == "" {
+		suffix, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || suffix <= 0 {
+			return 0, 0, fmt.Errorf("%w: malformed range %q", errors.ErrInvalidRange, spec)
+		}
+		// An empty object has no last bytes to return
+		if size == 0 {
+			return 0, 0, fmt.Errorf("%w: range %q of an empty object", errors.ErrInvalidRange, spec)
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, suffix, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, fmt.Errorf("%w: range start %q outside object of %d bytes", errors.ErrInvalidRange, startStr, size)
+	}
+
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, fmt.Errorf("%w: malformed range %q", errors.ErrInvalidRange, spec)
+		}
+		// Ranges running past the end are truncated to the object size
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	return start, end - start + 1, nil
+}
+
+// writeError maps service errors to HTTP status codes
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case stderrors.Is(err, errors.ErrMetadataNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case stderrors.Is(err, errors.ErrInvalidRange):
+		http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+	case stderrors.Is(err, errors.ErrTooManyDownloads):
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		log.Errorf("Gateway request failed: %v", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,230 @@
+// Package grpcapi exposes erasure-coded objects over gRPC.
+//
+// The server is a thin typed layer on top of FileService for service-to-service use,
+// alongside the HTTP gateway:
+// - Upload is client-streaming: a header naming the key and shard layout, then the content in chunks
+// - Download is server-streaming, sending data shards as they arrive through StreamFile
+// - Delete, List and Stat are unary and answer from metadata (Delete also removes the shards)
+//
+// Service errors are mapped to gRPC status codes, so clients can tell a missing object
+// (NotFound) from a bad request (InvalidArgument) or a busy server (ResourceExhausted).
+// The API is defined in zstorepb/zstore.proto.
+package grpcapi
+
+//go:generate protoc -I zstorepb --go_out=zstorepb --go_opt=paths=source_relative --go-grpc_out=zstorepb --go-grpc_opt=paths=source_relative zstore.proto
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"path"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/grpcapi/zstorepb"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// downloadChunkSize caps the content carried by one Download message, well under gRPC's default 4MB limit
+const downloadChunkSize = 1 << 20
+
+// ObjectService is the subset of FileService the gRPC server depends on
+type ObjectService interface {
+	UploadFile(ctx context.Context, key string, r io.Reader, quiet bool, dataShards, parityShards, concurrency int) error
+	StreamFile(ctx context.Context, key string, dest io.Writer, verifyIntegrity bool) error
+	DeleteFile(ctx context.Context, key string) error
+	ListFiles(ctx context.Context, prefix string) ([]domain.ObjectMetadata, error)
+	StatFile(ctx context.Context, key string) (domain.ObjectMetadata, error)
+}
+
+// Server implements the Zstore gRPC service backed by an object service
+type Server struct {
+	zstorepb.UnimplementedZstoreServer
+	service      ObjectService
+	dataShards   int // Used when an upload asks for none
+	parityShards int
+}
+
+// NewServer creates a gRPC server backed by the given object service
+// Uploads that do not name a shard layout use dataShards and parityShards.
+func NewServer(service ObjectService, dataShards, parityShards int) *Server {
+	return &Server{service: service, dataShards: dataShards, parityShards: parityShards}
+}
+
+// Upload stores the object described by the stream's header with the content that follows it
+func (s *Server) Upload(stream zstorepb.Zstore_UploadServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	header := first.GetHeader()
+	if header == nil || header.GetKey() == "" {
+		return status.Error(codes.InvalidArgument, "the first upload message must be a header naming the key")
+	}
+	dataShards, parityShards := int(header.GetDataShards()), int(header.GetParityShards())
+	if dataShards == 0 {
+		dataShards = s.dataShards
+	}
+	if parityShards == 0 {
+		parityShards = s.parityShards
+	}
+
+	// Feed chunks to the upload as they arrive instead of collecting the whole object first
+	reader, writer := io.Pipe()
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			if err == io.EOF {
+				writer.Close()
+				return
+			}
+			if err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+			if msg.GetHeader() != nil {
+				writer.CloseWithError(status.Error(codes.InvalidArgument, "an upload carries only one header"))
+				return
+			}
+			if _, err := writer.Write(msg.GetChunk()); err != nil {
+				return // The upload stopped reading
+			}
+		}
+	}()
+
+	var content io.Reader = reader
+	if header.GetSize() > 0 {
+		content = objectstore.WithSizeHint(reader, header.GetSize())
+	}
+	err = s.service.UploadFile(stream.Context(), header.GetKey(), content, true, dataShards, parityShards, 0)
+	reader.Close()
+	if err != nil {
+		return statusError(err)
+	}
+
+	metadata, err := s.service.StatFile(stream.Context(), header.GetKey())
+	if err != nil {
+		return statusError(err)
+	}
+	return stream.SendAndClose(&zstorepb.UploadResponse{Object: objectInfo(metadata)})
+}
+
+// Download streams an object's content in order
+func (s *Server) Download(req *zstorepb.DownloadRequest, stream zstorepb.Zstore_DownloadServer) error {
+	if req.GetKey() == "" {
+		return status.Error(codes.InvalidArgument, "key is required")
+	}
+	if err := s.service.StreamFile(stream.Context(), req.GetKey(), chunkSender{stream: stream}, req.GetVerifyIntegrity()); err != nil {
+		return statusError(err)
+	}
+	return nil
+}
+
+// Delete removes an object and its shards
+func (s *Server) Delete(ctx context.Context, req *zstorepb.DeleteRequest) (*zstorepb.DeleteResponse, error) {
+	if req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key is required")
+	}
+	if err := s.service.DeleteFile(ctx, req.GetKey()); err != nil {
+		return nil, statusError(err)
+	}
+	return &zstorepb.DeleteResponse{}, nil
+}
+
+// List describes the objects directly under a prefix
+func (s *Server) List(ctx context.Context, req *zstorepb.ListRequest) (*zstorepb.ListResponse, error) {
+	prefix := req.GetPrefix()
+	if prefix == "" {
+		prefix = "."
+	}
+	files, err := s.service.ListFiles(ctx, prefix)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &zstorepb.ListResponse{Objects: make([]*zstorepb.ObjectInfo, 0, len(files))}
+	for _, file := range files {
+		resp.Objects = append(resp.Objects, objectInfo(file))
+	}
+	return resp, nil
+}
+
+// Stat describes one object
+func (s *Server) Stat(ctx context.Context, req *zstorepb.StatRequest) (*zstorepb.ObjectInfo, error) {
+	if req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key is required")
+	}
+	metadata, err := s.service.StatFile(ctx, req.GetKey())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return objectInfo(metadata), nil
+}
+
+// chunkSender sends everything written to it as Download messages of at most downloadChunkSize bytes
+type chunkSender struct {
+	stream zstorepb.Zstore_DownloadServer
+}
+
+func (c chunkSender) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		end := min(written+downloadChunkSize, len(p))
+		if err := c.stream.Send(&zstorepb.DownloadResponse{Chunk: p[written:end]}); err != nil {
+			return written, err
+		}
+		written = end
+	}
+	return written, nil
+}
+
+// objectInfo converts object metadata to its API form
+func objectInfo(metadata domain.ObjectMetadata) *zstorepb.ObjectInfo {
+	info := &zstorepb.ObjectInfo{
+		Key:          path.Join(metadata.Prefix, metadata.FileName),
+		Size:         metadata.ContentSize(),
+		DataShards:   int32(metadata.RequiredShards()),
+		ParityShards: int32(metadata.ParityShards),
+		StoredShards: int32(metadata.StoredShards()),
+		Etag:         metadata.ETag(),
+		ContentType:  metadata.ContentType,
+		UserMetadata: metadata.UserMetadata,
+	}
+	if metadata.DisplayKey != "" {
+		info.Key = metadata.DisplayKey
+	}
+	if !metadata.CreatedAt.IsZero() {
+		info.CreatedAt = timestamppb.New(metadata.CreatedAt)
+	}
+	return info
+}
+
+// statusError maps service errors to gRPC status codes
+func statusError(err error) error {
+	switch {
+	case stderrors.Is(err, errors.ErrMetadataNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case stderrors.Is(err, errors.ErrEmptyFile), stderrors.Is(err, errors.ErrInvalidShardConfig),
+		stderrors.Is(err, errors.ErrObjectTooLarge), stderrors.Is(err, errors.ErrShardFailureTolerance):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case stderrors.Is(err, errors.ErrObjectLocked), stderrors.Is(err, errors.ErrBucketsAtQuota):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case stderrors.Is(err, errors.ErrTooManyDownloads):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case stderrors.Is(err, errors.ErrInsufficientShards), stderrors.Is(err, errors.ErrFileIntegrityCheck):
+		return status.Error(codes.DataLoss, err.Error())
+	case stderrors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case stderrors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		if _, ok := status.FromError(err); ok {
+			return err // Already a status, e.g. from the client's stream
+		}
+		log.Errorf("gRPC request failed: %v", err)
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
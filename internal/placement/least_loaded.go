@@ -0,0 +1,201 @@
+package placement
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+// usageRefreshTimeout bounds a single background refresh of bucket usage
+const usageRefreshTimeout = 5 * time.Minute
+
+// LeastLoadedPlacer places shards in the buckets that currently store the least data
+//
+// Bucket usage (bytes stored) is queried from repositories implementing
+// objectstore.UsageReporter and cached for a TTL. Buckets are ranked from least to
+// most loaded, and shard i goes to the i-th bucket in that ranking (wrapping around
+// like round-robin). Every shard of an object therefore still lands in a different
+// bucket whenever there are enough buckets, while new data flows to the emptiest ones.
+//
+// The ranking only changes when usage is refreshed, so placement is stable within a
+// TTL window. Placement does not need to be reproducible: downloads find shards through
+// the bucket names recorded in metadata. Stale usage is refreshed in the background,
+// so Place never blocks on listing buckets. Buckets whose usage is unknown (the
+// repository cannot report it, or the query failed) rank after all known buckets.
+type LeastLoadedPlacer struct {
+	mu           sync.RWMutex
+	repositories map[string]objectstore.ObjectRepository
+	bucketNames  []string // Registration order
+
+	ttl        time.Duration
+	usage      map[string]int64 // Bytes stored per bucket, for buckets with known usage
+	ranking    []string         // Buckets from least to most loaded
+	refreshed  time.Time        // Zero until the first refresh completes
+	refreshing bool
+}
+
+// NewLeastLoadedPlacer creates a placer that refreshes bucket usage at most once per ttl
+func NewLeastLoadedPlacer(ttl time.Duration) *LeastLoadedPlacer {
+	return &LeastLoadedPlacer{
+		repositories: make(map[string]objectstore.ObjectRepository),
+		bucketNames:  make([]string, 0),
+		ttl:          ttl,
+		usage:        make(map[string]int64),
+	}
+}
+
+// RegisterBucket adds a bucket and its repository
+func (p *LeastLoadedPlacer) RegisterBucket(bucketName string, repo objectstore.ObjectRepository) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.repositories[bucketName]; exists {
+		return fmt.Errorf("bucket %s already registered", bucketName)
+	}
+
+	p.repositories[bucketName] = repo
+	p.bucketNames = append(p.bucketNames, bucketName)
+	p.rank()
+	return nil
+}
+
+// GetRepositoryForBucket returns the repository for a specific bucket
+func (p *LeastLoadedPlacer) GetRepositoryForBucket(bucketName string) (objectstore.ObjectRepository, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	repo, exists := p.repositories[bucketName]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", errors.ErrBucketNotRegistered, bucketName)
+	}
+	return repo, nil
+}
+
+// Place selects the bucket ranked at shardIndex in the least-loaded ordering
+func (p *LeastLoadedPlacer) Place(shardIndex int) (string, objectstore.ObjectRepository, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.ranking) == 0 {
+		return "", nil, fmt.Errorf("no buckets registered")
+	}
+
+	if !p.refreshing && time.Since(p.refreshed) >= p.ttl {
+		p.refreshing = true
+		go p.refreshInBackground()
+	}
+
+	bucketName := p.ranking[shardIndex%len(p.ranking)]
+	return bucketName, p.repositories[bucketName], nil
+}
+
+// PreviewPlacement returns the bucket chosen for each of the first numShards shards
+// The current ranking is used as is; stale usage is not refreshed.
+func (p *LeastLoadedPlacer) PreviewPlacement(numShards int) ([]string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if len(p.ranking) == 0 {
+		return nil, fmt.Errorf("no buckets registered")
+	}
+
+	buckets := make([]string, numShards)
+	for i := range buckets {
+		buckets[i] = p.ranking[i%len(p.ranking)]
+	}
+	return buckets, nil
+}
+
+// ListBuckets returns all registered bucket names
+func (p *LeastLoadedPlacer) ListBuckets() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	buckets := make([]string, len(p.bucketNames))
+	copy(buckets, p.bucketNames)
+	return buckets
+}
+
+// Ranking returns the bucket names from least to most loaded
+func (p *LeastLoadedPlacer) Ranking() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	ranking := make([]string, len(p.ranking))
+	copy(ranking, p.ranking)
+	return ranking
+}
+
+// Refresh queries the usage of every bucket and re-ranks them
+// Buckets whose usage cannot be determined keep their last known usage, if any.
+func (p *LeastLoadedPlacer) Refresh(ctx context.Context) error {
+	p.mu.RLock()
+	repositories := make(map[string]objectstore.ObjectRepository, len(p.repositories))
+	for name, repo := range p.repositories {
+		repositories[name] = repo
+	}
+	p.mu.RUnlock()
+
+	usage := make(map[string]int64, len(repositories))
+	var firstErr error
+	for name, repo := range repositories {
+		reporter, ok := repo.(objectstore.UsageReporter)
+		if !ok {
+			continue
+		}
+		bucketUsage, err := reporter.GetUsage(ctx)
+		if err != nil {
+			log.Warnf("Could not refresh usage of bucket %s: %v", name, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("bucket %s: %w", name, err)
+			}
+			continue
+		}
+		usage[name] = bucketUsage.Bytes
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for name, bytes := range usage {
+		p.usage[name] = bytes
+	}
+	p.refreshed = time.Now()
+	p.rank()
+	log.Debugf("Least-loaded placement ranking: %v", p.ranking)
+	return firstErr
+}
+
+// refreshInBackground refreshes usage without blocking placement
+func (p *LeastLoadedPlacer) refreshInBackground() {
+	ctx, cancel := context.WithTimeout(context.Background(), usageRefreshTimeout)
+	defer cancel()
+
+	p.Refresh(ctx)
+
+	p.mu.Lock()
+	p.refreshing = false
+	p.mu.Unlock()
+}
+
+// rank orders buckets by known usage, then unknown buckets in registration order
+// Must be called with the write lock held.
+func (p *LeastLoadedPlacer) rank() {
+	ranking := make([]string, len(p.bucketNames))
+	copy(ranking, p.bucketNames)
+
+	sort.SliceStable(ranking, func(i, j int) bool {
+		usageI, knownI := p.usage[ranking[i]]
+		usageJ, knownJ := p.usage[ranking[j]]
+		if knownI != knownJ {
+			return knownI
+		}
+		return knownI && usageI < usageJ
+	})
+	p.ranking = ranking
+}
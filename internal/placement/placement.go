@@ -70,4 +70,8 @@ type Placer interface {
 	// ListBuckets returns all registered bucket names.
 	// Used for administrative operations like cleanup across all buckets.
 	ListBuckets() []string
+
+	// PreviewPlacement returns the bucket Place would choose for shards 0 to numShards-1.
+	// It has no side effects (it never triggers usage refreshes), so it can back dry runs.
+	PreviewPlacement(numShards int) ([]string, error)
 }
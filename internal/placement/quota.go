@@ -0,0 +1,221 @@
+package placement
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+// Quota limits how much a bucket may store; zero fields are unlimited
+type Quota struct {
+	MaxObjects int64
+	MaxBytes   int64
+}
+
+// Enabled reports whether the quota limits anything
+func (q Quota) Enabled() bool {
+	return q.MaxObjects > 0 || q.MaxBytes > 0
+}
+
+// full reports whether a bucket with the given usage cannot take another object
+func (q Quota) full(usage objectstore.BucketUsage) bool {
+	return (q.MaxObjects > 0 && usage.Objects >= q.MaxObjects) || (q.MaxBytes > 0 && usage.Bytes >= q.MaxBytes)
+}
+
+// UsageRecorder is implemented by placers that track what is uploaded to the buckets they chose
+// The file service reports every stored shard, so usage between refreshes stays accurate.
+type UsageRecorder interface {
+	RecordUpload(bucketName string, bytes int64)
+}
+
+// QuotaPlacer wraps another placer and never places shards in buckets that are at quota
+//
+// When the wrapped placer picks a full bucket for shard i, the placer it would pick for
+// shard i+1, i+2 and so on is tried instead, so shards flow to the buckets with room in
+// the wrapped placer's order. When every bucket is full, Place fails with
+// errors.ErrBucketsAtQuota; uploads place every shard before storing any, so they fail
+// before anything is written rather than midway.
+//
+// Usage of buckets with a quota is listed through objectstore.UsageReporter on the
+// first placement and then refreshed in the background once per TTL. Shards uploaded
+// in between are added as they are reported through RecordUpload. A bucket whose
+// repository cannot report usage is only limited by what was recorded since startup.
+type QuotaPlacer struct {
+	Placer
+
+	mu         sync.Mutex
+	quotas     map[string]Quota
+	usage      map[string]objectstore.BucketUsage // Usage at the last refresh plus recorded uploads
+	ttl        time.Duration
+	refreshed  time.Time // Zero until the first refresh completes
+	refreshing bool
+}
+
+// NewQuotaPlacer wraps placer, refreshing the usage of buckets with a quota at most once per ttl
+func NewQuotaPlacer(placer Placer, ttl time.Duration) *QuotaPlacer {
+	return &QuotaPlacer{
+		Placer: placer,
+		quotas: make(map[string]Quota),
+		usage:  make(map[string]objectstore.BucketUsage),
+		ttl:    ttl,
+	}
+}
+
+// SetQuota limits a bucket; a zero quota removes the limit
+func (p *QuotaPlacer) SetQuota(bucketName string, quota Quota) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if quota.Enabled() {
+		p.quotas[bucketName] = quota
+	} else {
+		delete(p.quotas, bucketName)
+	}
+}
+
+// Place selects the bucket the wrapped placer chooses, skipping buckets at quota
+func (p *QuotaPlacer) Place(shardIndex int) (string, objectstore.ObjectRepository, error) {
+	p.ensureUsage()
+
+	bucketCount := len(p.ListBuckets())
+	for offset := 0; offset < max(bucketCount, 1); offset++ {
+		bucketName, repo, err := p.Placer.Place(shardIndex + offset)
+		if err != nil {
+			return "", nil, err
+		}
+		if !p.atQuota(bucketName) {
+			return bucketName, repo, nil
+		}
+	}
+	return "", nil, fmt.Errorf("%w: no bucket can accept shard %d", errors.ErrBucketsAtQuota, shardIndex)
+}
+
+// PreviewPlacement returns the bucket Place would choose for each of the first numShards shards
+// Usage is neither loaded nor refreshed, so buckets count as having room until it is known.
+func (p *QuotaPlacer) PreviewPlacement(numShards int) ([]string, error) {
+	bucketCount := len(p.ListBuckets())
+	preview, err := p.Placer.PreviewPlacement(numShards + bucketCount)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]string, numShards)
+	for i := range buckets {
+		for offset := 0; offset < max(bucketCount, 1); offset++ {
+			if bucketName := preview[i+offset]; !p.atQuota(bucketName) {
+				buckets[i] = bucketName
+				break
+			}
+		}
+		if buckets[i] == "" {
+			return nil, fmt.Errorf("%w: no bucket can accept shard %d", errors.ErrBucketsAtQuota, i)
+		}
+	}
+	return buckets, nil
+}
+
+// RecordUpload adds an uploaded shard to its bucket's usage
+func (p *QuotaPlacer) RecordUpload(bucketName string, bytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, limited := p.quotas[bucketName]; !limited {
+		return
+	}
+	usage := p.usage[bucketName]
+	usage.Objects++
+	usage.Bytes += bytes
+	p.usage[bucketName] = usage
+}
+
+// Usage returns the tracked usage of a bucket with a quota
+func (p *QuotaPlacer) Usage(bucketName string) objectstore.BucketUsage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.usage[bucketName]
+}
+
+// Refresh lists the usage of every bucket with a quota
+// Buckets whose usage cannot be determined keep their tracked usage.
+func (p *QuotaPlacer) Refresh(ctx context.Context) error {
+	p.mu.Lock()
+	limited := make([]string, 0, len(p.quotas))
+	for bucketName := range p.quotas {
+		limited = append(limited, bucketName)
+	}
+	p.mu.Unlock()
+
+	usage := make(map[string]objectstore.BucketUsage, len(limited))
+	var firstErr error
+	for _, bucketName := range limited {
+		repo, err := p.GetRepositoryForBucket(bucketName)
+		if err != nil {
+			continue
+		}
+		reporter, ok := repo.(objectstore.UsageReporter)
+		if !ok {
+			continue
+		}
+		bucketUsage, err := reporter.GetUsage(ctx)
+		if err != nil {
+			log.Warnf("Could not refresh usage of bucket %s: %v", bucketName, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("bucket %s: %w", bucketName, err)
+			}
+			continue
+		}
+		usage[bucketName] = bucketUsage
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for bucketName, bucketUsage := range usage {
+		p.usage[bucketName] = bucketUsage
+	}
+	p.refreshed = time.Now()
+	return firstErr
+}
+
+// ensureUsage loads usage before the first placement and refreshes it in the background afterwards
+func (p *QuotaPlacer) ensureUsage() {
+	p.mu.Lock()
+	if len(p.quotas) == 0 {
+		p.mu.Unlock()
+		return
+	}
+	if p.refreshed.IsZero() && !p.refreshing {
+		// Quotas cannot be enforced without knowing usage, so the first placement waits for it
+		p.refreshing = true
+		p.mu.Unlock()
+		p.refresh()
+		return
+	}
+	if !p.refreshing && time.Since(p.refreshed) >= p.ttl {
+		p.refreshing = true
+		go p.refresh()
+	}
+	p.mu.Unlock()
+}
+
+// refresh refreshes usage within usageRefreshTimeout
+func (p *QuotaPlacer) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), usageRefreshTimeout)
+	defer cancel()
+
+	p.Refresh(ctx)
+
+	p.mu.Lock()
+	p.refreshing = false
+	p.mu.Unlock()
+}
+
+// atQuota reports whether a bucket has reached its quota
+func (p *QuotaPlacer) atQuota(bucketName string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	quota, limited := p.quotas[bucketName]
+	return limited && quota.full(p.usage[bucketName])
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/zzenonn/zstore/internal/errors"
 	"github.com/zzenonn/zstore/internal/repository/objectstore"
 )
 
@@ -43,7 +44,7 @@ func (p *RoundRobinPlacer) GetRepositoryForBucket(bucketName string) (objectstor
 	
 	repo, exists := p.repositories[bucketName]
 	if !exists {
-		return nil, fmt.Errorf("no repository found for bucket: %s", bucketName)
+		return nil, fmt.Errorf("%w: %s", errors.ErrBucketNotRegistered, bucketName)
 	}
 	return repo, nil
 }
@@ -64,6 +65,22 @@ func (p *RoundRobinPlacer) Place(shardIndex int) (string, objectstore.ObjectRepo
 	return bucketName, repo, nil
 }
 
+// PreviewPlacement returns the bucket chosen for each of the first numShards shards
+func (p *RoundRobinPlacer) PreviewPlacement(numShards int) ([]string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if len(p.bucketNames) == 0 {
+		return nil, fmt.Errorf("no buckets registered")
+	}
+
+	buckets := make([]string, numShards)
+	for i := range buckets {
+		buckets[i] = p.bucketNames[i%len(p.bucketNames)]
+	}
+	return buckets, nil
+}
+
 // ListBuckets returns all registered bucket names
 func (p *RoundRobinPlacer) ListBuckets() []string {
 	p.mu.RLock()
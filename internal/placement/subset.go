@@ -0,0 +1,101 @@
+package placement
+
+import (
+	"fmt"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+// SubsetPlacer wraps another placer and only places shards in a chosen set of its buckets
+//
+// Shard i goes to the i-th bucket of the subset in the wrapped placer's order: the
+// wrapped placer's choices for shards 0, 1, 2 and so on are walked and buckets outside
+// the subset are skipped. Round-robin placement therefore cycles through the subset,
+// least-loaded placement ranks within it, and quota placement still skips full buckets.
+// ListBuckets returns the subset, so callers size their placement to it; reads are not
+// restricted, since GetRepositoryForBucket is passed through.
+type SubsetPlacer struct {
+	Placer
+
+	buckets []string
+	allowed map[string]bool
+}
+
+// NewSubsetPlacer restricts placer to buckets, which must all be registered with it
+func NewSubsetPlacer(placer Placer, buckets []string) (*SubsetPlacer, error) {
+	if len(buckets) == 0 {
+		return nil, fmt.Errorf("no buckets given for placement")
+	}
+
+	registered := make(map[string]bool)
+	for _, bucketName := range placer.ListBuckets() {
+		registered[bucketName] = true
+	}
+	allowed := make(map[string]bool, len(buckets))
+	for _, bucketName := range buckets {
+		if !registered[bucketName] {
+			return nil, fmt.Errorf("%w: %s", errors.ErrBucketNotRegistered, bucketName)
+		}
+		if allowed[bucketName] {
+			return nil, fmt.Errorf("bucket %s is listed more than once", bucketName)
+		}
+		allowed[bucketName] = true
+	}
+
+	return &SubsetPlacer{
+		Placer:  placer,
+		buckets: append([]string(nil), buckets...),
+		allowed: allowed,
+	}, nil
+}
+
+// Place selects the shardIndex-th subset bucket in the wrapped placer's order
+func (p *SubsetPlacer) Place(shardIndex int) (string, objectstore.ObjectRepository, error) {
+	// Every full cycle of the wrapped placer visits each bucket, so shardIndex+1 cycles suffice
+	limit := (shardIndex + 1) * len(p.Placer.ListBuckets())
+	found := 0
+	for i := 0; i < limit; i++ {
+		bucketName, repo, err := p.Placer.Place(i)
+		if err != nil {
+			return "", nil, err
+		}
+		if !p.allowed[bucketName] {
+			continue
+		}
+		if found == shardIndex {
+			return bucketName, repo, nil
+		}
+		found++
+	}
+	return "", nil, fmt.Errorf("no bucket of %v can accept shard %d", p.buckets, shardIndex)
+}
+
+// PreviewPlacement returns the bucket Place would choose for each of the first numShards shards
+func (p *SubsetPlacer) PreviewPlacement(numShards int) ([]string, error) {
+	preview, err := p.Placer.PreviewPlacement(numShards * len(p.Placer.ListBuckets()))
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]string, 0, numShards)
+	for _, bucketName := range preview {
+		if len(buckets) == numShards {
+			break
+		}
+		if p.allowed[bucketName] {
+			buckets = append(buckets, bucketName)
+		}
+	}
+	if len(buckets) < numShards {
+		return nil, fmt.Errorf("no bucket of %v can accept shard %d", p.buckets, len(buckets))
+	}
+	return buckets, nil
+}
+
+// ListBuckets returns the buckets shards may be placed in
+func (p *SubsetPlacer) ListBuckets() []string {
+	buckets := make([]string, len(p.buckets))
+	copy(buckets, p.buckets)
+	return buckets
+}
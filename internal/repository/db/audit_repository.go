@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/zzenonn/zstore/internal/domain"
+)
+
+// AuditRepository writes audit records to a DynamoDB table
+// Records are keyed by object key (object_key) and a time-ordered ID (id), so the
+// history of an object is a single query.
+type AuditRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+// NewAuditRepository initializes a new AuditRepository.
+func NewAuditRepository(client *dynamodb.Client, tableName string) AuditRepository {
+	return AuditRepository{client: client, tableName: tableName}
+}
+
+// LogOperation stores one audit record
+func (repo *AuditRepository) LogOperation(ctx context.Context, op domain.AuditOperation, key, user string, result domain.AuditResult) error {
+	item, err := attributevalue.MarshalMap(domain.NewAuditRecord(op, key, user, result))
+	if err != nil {
+		return err
+	}
+	_, err = repo.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(repo.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
@@ -2,33 +2,200 @@ package db
 
 import (
 	"context"
-	"errors"
+	stderrors "errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
 	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/migrate"
 )
 
+// Default key attribute names, matching the table created by the migrations
+const (
+	DefaultPartitionKey = "prefix"
+	DefaultSortKey      = "file_name"
+)
+
+// namespaceSeparator joins a namespace to the prefix in the stored partition key
+const namespaceSeparator = "#"
+
 // MetadataRepository manages DynamoDB interactions for ObjectMetadata.
 type MetadataRepository struct {
-	client    *dynamodb.Client
-	tableName string
+	client         *dynamodb.Client
+	tableName      string
+	partitionKey   string // Attribute holding the object prefix
+	sortKey        string // Attribute holding the file name
+	namespace      string // Deployment sharing the table (empty stores prefixes as they are)
+	verifyChecksum bool   // Reject items read by GetMetadata whose checksum is missing or wrong
 }
 
 // NewMetadataRepository initializes a new MetadataRepository.
 func NewMetadataRepository(client *dynamodb.Client, tableName string) MetadataRepository {
 	return MetadataRepository{
-		client:    client,
-		tableName: tableName,
+		client:       client,
+		tableName:    tableName,
+		partitionKey: DefaultPartitionKey,
+		sortKey:      DefaultSortKey,
+	}
+}
+
+// SetKeyNames sets the attribute names of the table's partition and sort keys
+// Pre-provisioned tables may name them differently (e.g. pk/sk); items are stored with the
+// prefix and file name under these attributes instead of "prefix" and "file_name".
+// Empty names keep the defaults.
+func (repo *MetadataRepository) SetKeyNames(partitionKey, sortKey string) {
+	if partitionKey == "" {
+		partitionKey = DefaultPartitionKey
+	}
+	if sortKey == "" {
+		sortKey = DefaultSortKey
+	}
+	repo.partitionKey, repo.sortKey = partitionKey, sortKey
+}
+
+// SetNamespace isolates this deployment's items from others sharing the table
+// The partition key is stored as "namespace#prefix" and each item records its namespace,
+// so deployments with different namespaces never see or overwrite each other's objects.
+// Items written without a namespace are only visible with an empty namespace.
+func (repo *MetadataRepository) SetNamespace(namespace string) error {
+	if strings.Contains(namespace, namespaceSeparator) {
+		return fmt.Errorf("namespace %q must not contain %q", namespace, namespaceSeparator)
+	}
+	repo.namespace = namespace
+	return nil
+}
+
+// SetVerifyChecksum makes GetMetadata verify the checksum stored with every item
+// Items are always written with a checksum; with verification on, an item whose stored
+// checksum is missing or does not match its content fails with errors.ErrMetadataChecksum.
+// Leave it off until items written before checksums existed have been rewritten.
+func (repo *MetadataRepository) SetVerifyChecksum(verify bool) {
+	repo.verifyChecksum = verify
+}
+
+// partitionValue returns the stored partition key value for a prefix
+func (repo *MetadataRepository) partitionValue(prefix string) string {
+	if repo.namespace == "" {
+		return prefix
 	}
+	return repo.namespace + namespaceSeparator + prefix
+}
+
+// key returns the primary key of an item
+func (repo *MetadataRepository) key(prefix, fileName string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		repo.partitionKey: &types.AttributeValueMemberS{Value: repo.partitionValue(prefix)},
+		repo.sortKey:      &types.AttributeValueMemberS{Value: fileName},
+	}
+}
+
+// toItem marshals metadata into an item, storing the prefix and file name under the key attributes
+// The item carries the checksum of the metadata as it will be read back, so values the
+// item stores at lower precision (e.g. created_at in seconds) still verify.
+func (repo *MetadataRepository) toItem(metadata domain.ObjectMetadata) (map[string]types.AttributeValue, error) {
+	metadata.Namespace = repo.namespace
+	metadata.Prefix = repo.partitionValue(metadata.Prefix)
+	metadata.MetadataChecksum = ""
+	item, err := attributevalue.MarshalMap(metadata)
+	if err != nil {
+		return nil, err
+	}
+	renameAttribute(item, DefaultPartitionKey, repo.partitionKey)
+	renameAttribute(item, DefaultSortKey, repo.sortKey)
+
+	stored, err := repo.fromItem(item)
+	if err != nil {
+		return nil, err
+	}
+	checksum, err := stored.Checksum()
+	if err != nil {
+		return nil, err
+	}
+	item[checksumAttribute] = &types.AttributeValueMemberS{Value: checksum}
+	return item, nil
+}
+
+// checksumAttribute is the attribute holding an item's metadata checksum
+const checksumAttribute = "metadata_checksum"
+
+// verifyItemChecksum checks the checksum read with metadata against its content
+func verifyItemChecksum(metadata domain.ObjectMetadata) error {
+	if metadata.MetadataChecksum == "" {
+		return fmt.Errorf("%w: %s/%s has no checksum", errors.ErrMetadataChecksum, metadata.Prefix, metadata.FileName)
+	}
+	checksum, err := metadata.Checksum()
+	if err != nil {
+		return err
+	}
+	if checksum != metadata.MetadataChecksum {
+		return fmt.Errorf("%w: %s/%s", errors.ErrMetadataChecksum, metadata.Prefix, metadata.FileName)
+	}
+	return nil
+}
+
+// fromItem unmarshals an item written by toItem
+func (repo *MetadataRepository) fromItem(item map[string]types.AttributeValue) (domain.ObjectMetadata, error) {
+	if repo.partitionKey != DefaultPartitionKey || repo.sortKey != DefaultSortKey {
+		renamed := make(map[string]types.AttributeValue, len(item))
+		for name, value := range item {
+			renamed[name] = value
+		}
+		renameAttribute(renamed, repo.partitionKey, DefaultPartitionKey)
+		renameAttribute(renamed, repo.sortKey, DefaultSortKey)
+		item = renamed
+	}
+
+	var metadata domain.ObjectMetadata
+	if err := attributevalue.UnmarshalMap(item, &metadata); err != nil {
+		return domain.ObjectMetadata{}, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	}
+	if metadata.Namespace != "" {
+		metadata.Prefix = strings.TrimPrefix(metadata.Prefix, metadata.Namespace+namespaceSeparator)
+	}
+	return metadata, nil
+}
+
+// renameAttribute moves an attribute of item from one name to another
+func renameAttribute(item map[string]types.AttributeValue, from, to string) {
+	if from == to {
+		return
+	}
+	if value, ok := item[from]; ok {
+		item[to] = value
+		delete(item, from)
+	}
+}
+
+// throttlingErrorCodes are the DynamoDB error codes reported when a request is throttled
+var throttlingErrorCodes = map[string]bool{
+	"ProvisionedThroughputExceededException": true,
+	"ThrottlingException":                    true,
+	"RequestLimitExceeded":                   true,
+}
+
+// wrapError adds context to a DynamoDB error, marking throttling with errors.ErrMetadataThrottled
+// and server-side faults (such as InternalServerError) with errors.ErrMetadataUnavailable.
+func wrapError(message string, err error) error {
+	var apiErr smithy.APIError
+	if stderrors.As(err, &apiErr) && throttlingErrorCodes[apiErr.ErrorCode()] {
+		return fmt.Errorf("%s: %w: %w", message, errors.ErrMetadataThrottled, err)
+	}
+	if stderrors.As(err, &apiErr) && apiErr.ErrorFault() == smithy.FaultServer {
+		return fmt.Errorf("%s: %w: %w", message, errors.ErrMetadataUnavailable, err)
+	}
+	return fmt.Errorf("%s: %w", message, err)
 }
 
 // CreateMetadata stores object metadata in DynamoDB.
 func (repo *MetadataRepository) CreateMetadata(ctx context.Context, metadata domain.ObjectMetadata) (domain.ObjectMetadata, error) {
-	metadataMap, err := attributevalue.MarshalMap(metadata)
+	metadataMap, err := repo.toItem(metadata)
 	if err != nil {
 		return domain.ObjectMetadata{}, fmt.Errorf("failed to marshal metadata: %w", err)
 	}
@@ -39,9 +206,10 @@ func (repo *MetadataRepository) CreateMetadata(ctx context.Context, metadata dom
 	}
 
 	if _, err := repo.client.PutItem(ctx, input); err != nil {
-		return domain.ObjectMetadata{}, fmt.Errorf("failed to create metadata: %w", err)
+		return domain.ObjectMetadata{}, wrapError("failed to create metadata", err)
 	}
 
+	metadata.Namespace = repo.namespace
 	return metadata, nil
 }
 
@@ -49,26 +217,27 @@ func (repo *MetadataRepository) CreateMetadata(ctx context.Context, metadata dom
 func (repo *MetadataRepository) GetMetadata(ctx context.Context, prefix, fileName string) (domain.ObjectMetadata, error) {
 	input := &dynamodb.GetItemInput{
 		TableName: aws.String(repo.tableName),
-		Key: map[string]types.AttributeValue{
-			"prefix":    &types.AttributeValueMemberS{Value: prefix},
-			"file_name": &types.AttributeValueMemberS{Value: fileName},
-		},
+		Key:       repo.key(prefix, fileName),
 	}
 
 	result, err := repo.client.GetItem(ctx, input)
 	if err != nil {
-		return domain.ObjectMetadata{}, fmt.Errorf("failed to get metadata: %w", err)
+		return domain.ObjectMetadata{}, wrapError("failed to get metadata", err)
 	}
 
 	if result.Item == nil {
-		return domain.ObjectMetadata{}, errors.New("metadata not found")
+		return domain.ObjectMetadata{}, errors.ErrMetadataNotFound
 	}
 
-	var metadata domain.ObjectMetadata
-	if err := attributevalue.UnmarshalMap(result.Item, &metadata); err != nil {
-		return domain.ObjectMetadata{}, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	metadata, err := repo.fromItem(result.Item)
+	if err != nil {
+		return domain.ObjectMetadata{}, err
+	}
+	if repo.verifyChecksum {
+		if err := verifyItemChecksum(metadata); err != nil {
+			return domain.ObjectMetadata{}, err
+		}
 	}
-
 	return metadata, nil
 }
 
@@ -78,23 +247,23 @@ func (repo *MetadataRepository) ListMetadataByPrefix(ctx context.Context, prefix
 		TableName:              aws.String(repo.tableName),
 		KeyConditionExpression: aws.String("#prefix = :prefix"),
 		ExpressionAttributeNames: map[string]string{
-			"#prefix": "prefix",
+			"#prefix": repo.partitionKey,
 		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":prefix": &types.AttributeValueMemberS{Value: prefix},
+			":prefix": &types.AttributeValueMemberS{Value: repo.partitionValue(prefix)},
 		},
 	}
 
 	result, err := repo.client.Query(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query metadata by prefix: %w", err)
+		return nil, wrapError("failed to query metadata by prefix", err)
 	}
 
 	var metadataList []domain.ObjectMetadata
 	for _, item := range result.Items {
-		var metadata domain.ObjectMetadata
-		if err := attributevalue.UnmarshalMap(item, &metadata); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		metadata, err := repo.fromItem(item)
+		if err != nil {
+			return nil, err
 		}
 		metadataList = append(metadataList, metadata)
 	}
@@ -102,6 +271,100 @@ func (repo *MetadataRepository) ListMetadataByPrefix(ctx context.Context, prefix
 	return metadataList, nil
 }
 
+// ListMetadataSorted retrieves the object metadata within a prefix ordered by size or upload time.
+// Date ordering is served by the prefix/created_at index. Items written before created_at
+// was recorded are not in the index and are omitted until their metadata is rewritten.
+// Size has no index, so the prefix query is sorted in memory.
+func (repo *MetadataRepository) ListMetadataSorted(ctx context.Context, prefix string, sortBy domain.MetadataSortField, order domain.SortOrder) ([]domain.ObjectMetadata, error) {
+	if sortBy != domain.SortByDate {
+		metadataList, err := repo.ListMetadataByPrefix(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		domain.SortObjectMetadata(metadataList, sortBy, order)
+		return metadataList, nil
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(repo.tableName),
+		IndexName:              aws.String(migrate.CreatedAtIndexName),
+		KeyConditionExpression: aws.String("#prefix = :prefix"),
+		ExpressionAttributeNames: map[string]string{
+			"#prefix": repo.partitionKey,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":prefix": &types.AttributeValueMemberS{Value: repo.partitionValue(prefix)},
+		},
+		ScanIndexForward: aws.Bool(order != domain.SortDescending),
+	}
+
+	var metadataList []domain.ObjectMetadata
+	paginator := dynamodb.NewQueryPaginator(repo.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError("failed to query metadata by upload time", err)
+		}
+		for _, item := range page.Items {
+			metadata, err := repo.fromItem(item)
+			if err != nil {
+				return nil, err
+			}
+			metadataList = append(metadataList, metadata)
+		}
+	}
+
+	return metadataList, nil
+}
+
+// ListPrefixes returns prefix and every prefix below it ("prefix/...") that holds objects, sorted.
+// DynamoDB cannot query partition keys by prefix, so this scans the whole table, reading only
+// the partition key and namespace of each item; it consumes read capacity for every item.
+func (repo *MetadataRepository) ListPrefixes(ctx context.Context, prefix string) ([]string, error) {
+	input := &dynamodb.ScanInput{
+		TableName:            aws.String(repo.tableName),
+		ProjectionExpression: aws.String("#prefix, #namespace"),
+		ExpressionAttributeNames: map[string]string{
+			"#prefix":    repo.partitionKey,
+			"#namespace": "namespace",
+		},
+	}
+
+	found := make(map[string]bool)
+	paginator := dynamodb.NewScanPaginator(repo.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError("failed to scan metadata prefixes", err)
+		}
+		for _, item := range page.Items {
+			stored, ok := item[repo.partitionKey].(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+			namespace := ""
+			if value, ok := item["namespace"].(*types.AttributeValueMemberS); ok {
+				namespace = value.Value
+			}
+			// Other deployments' items share the table but are not visible to this one
+			if namespace != repo.namespace {
+				continue
+			}
+			itemPrefix := strings.TrimPrefix(stored.Value, repo.partitionValue(""))
+			if domain.PrefixWithin(itemPrefix, prefix) {
+				found[itemPrefix] = true
+			}
+		}
+	}
+
+	prefixes := make([]string, 0, len(found))
+	for itemPrefix := range found {
+		prefixes = append(prefixes, itemPrefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes, nil
+}
+
 // UpdateMetadata replaces existing object metadata (full replacement as preferred).
 func (repo *MetadataRepository) UpdateMetadata(ctx context.Context, metadata domain.ObjectMetadata) (domain.ObjectMetadata, error) {
 	// Use PutItem for full replacement as specified in requirements
@@ -112,14 +375,128 @@ func (repo *MetadataRepository) UpdateMetadata(ctx context.Context, metadata dom
 func (repo *MetadataRepository) DeleteMetadata(ctx context.Context, prefix, fileName string) error {
 	input := &dynamodb.DeleteItemInput{
 		TableName: aws.String(repo.tableName),
-		Key: map[string]types.AttributeValue{
-			"prefix":    &types.AttributeValueMemberS{Value: prefix},
-			"file_name": &types.AttributeValueMemberS{Value: fileName},
-		},
+		Key:       repo.key(prefix, fileName),
 	}
 
 	if _, err := repo.client.DeleteItem(ctx, input); err != nil {
-		return fmt.Errorf("failed to delete metadata: %w", err)
+		return wrapError("failed to delete metadata", err)
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// DynamoDB limits on a single TransactWriteItems call
+const (
+	MaxTransactionItems = 25      // Writes per transaction
+	MaxTransactionBytes = 4 << 20 // Aggregate size of the items written
+)
+
+// Transact stores puts and removes deletes in one DynamoDB transaction: all of them apply or none do.
+// Transactions larger than MaxTransactionItems writes or MaxTransactionBytes are rejected
+// with errors.ErrTransactionTooLarge before anything is sent, and a record may only appear
+// once in a transaction. A transaction cancelled because of throttling matches
+// errors.ErrMetadataThrottled, so it can be retried as a whole.
+func (repo *MetadataRepository) Transact(ctx context.Context, puts []domain.ObjectMetadata, deletes []domain.MetadataKey) error {
+	count := len(puts) + len(deletes)
+	if count == 0 {
+		return nil
+	}
+	if count > MaxTransactionItems {
+		return fmt.Errorf("%w: %d writes, at most %d allowed", errors.ErrTransactionTooLarge, count, MaxTransactionItems)
+	}
+
+	seen := make(map[domain.MetadataKey]bool, count)
+	checkUnique := func(key domain.MetadataKey) error {
+		if seen[key] {
+			return fmt.Errorf("%s/%s appears more than once in the transaction", key.Prefix, key.FileName)
+		}
+		seen[key] = true
+		return nil
+	}
+
+	items := make([]types.TransactWriteItem, 0, count)
+	size := 0
+	for _, metadata := range puts {
+		if err := checkUnique(metadata.Key()); err != nil {
+			return err
+		}
+		item, err := repo.toItem(metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		size += itemSize(item)
+		items = append(items, types.TransactWriteItem{Put: &types.Put{TableName: aws.String(repo.tableName), Item: item}})
+	}
+	for _, key := range deletes {
+		if err := checkUnique(key); err != nil {
+			return err
+		}
+		itemKey := repo.key(key.Prefix, key.FileName)
+		size += itemSize(itemKey)
+		items = append(items, types.TransactWriteItem{Delete: &types.Delete{TableName: aws.String(repo.tableName), Key: itemKey}})
+	}
+	if size > MaxTransactionBytes {
+		return fmt.Errorf("%w: %d bytes, at most %d allowed", errors.ErrTransactionTooLarge, size, MaxTransactionBytes)
+	}
+
+	if _, err := repo.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items}); err != nil {
+		var canceled *types.TransactionCanceledException
+		if stderrors.As(err, &canceled) {
+			for _, reason := range canceled.CancellationReasons {
+				if code := aws.ToString(reason.Code); code == "ThrottlingError" || throttlingErrorCodes[code] {
+					return fmt.Errorf("metadata transaction was cancelled: %w: %w", errors.ErrMetadataThrottled, err)
+				}
+			}
+		}
+		return wrapError("metadata transaction failed", err)
+	}
+	return nil
+}
+
+// itemSize estimates the size DynamoDB counts for an item: attribute names plus values
+func itemSize(item map[string]types.AttributeValue) int {
+	size := 0
+	for name, value := range item {
+		size += len(name) + attributeValueSize(value)
+	}
+	return size
+}
+
+// attributeValueSize estimates the stored size of one attribute value
+func attributeValueSize(value types.AttributeValue) int {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return len(v.Value)
+	case *types.AttributeValueMemberN:
+		return len(v.Value)
+	case *types.AttributeValueMemberB:
+		return len(v.Value)
+	case *types.AttributeValueMemberSS:
+		size := 0
+		for _, s := range v.Value {
+			size += len(s)
+		}
+		return size
+	case *types.AttributeValueMemberNS:
+		size := 0
+		for _, n := range v.Value {
+			size += len(n)
+		}
+		return size
+	case *types.AttributeValueMemberBS:
+		size := 0
+		for _, b := range v.Value {
+			size += len(b)
+		}
+		return size
+	case *types.AttributeValueMemberL:
+		size := 3
+		for _, element := range v.Value {
+			size += 1 + attributeValueSize(element)
+		}
+		return size
+	case *types.AttributeValueMemberM:
+		return 3 + itemSize(v.Value)
+	default: // BOOL and NULL
+		return 1
+	}
+}
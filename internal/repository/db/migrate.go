@@ -25,8 +25,19 @@ type Migration interface {
 // Global migrations list in order
 var migrations = []Migration{
 	&migrate.CreateObjectMetadataTable{}, // New ObjectMetadata table migration
+	&migrate.AddCreatedAtIndex{},         // (prefix, created_at) index for listing by upload time
+	&migrate.CreateAuditLogTable{},       // Table for audit_log: dynamodb
 }
 
+// Each applied migration is recorded as its own "Migration:<version>" tag on its table, so
+// later migrations on the same table do not overwrite earlier records. Tables migrated by
+// older releases carry a single "Migration" tag whose value is the version; it is still honored.
+const (
+	migrationTagPrefix = "Migration:"
+	legacyMigrationTag = "Migration"
+	legacyMigratedAt   = "MigratedAt"
+)
+
 func (d *DynamoDb) MigrateDb(ctx context.Context) error {
 	log.Info("migrating database")
 
@@ -76,7 +87,7 @@ func (d *DynamoDb) MigrateDown(ctx context.Context) error {
 		}
 
 		// Remove migration tag
-		if err := d.removeMigrationRecord(ctx, migration.TableName()); err != nil {
+		if err := d.removeMigrationRecord(ctx, migration.Version(), migration.TableName()); err != nil {
 			return fmt.Errorf("could not remove migration record %s: %w", migration.Version(), err)
 		}
 
@@ -88,11 +99,19 @@ func (d *DynamoDb) MigrateDown(ctx context.Context) error {
 }
 
 func (d *DynamoDb) isMigrationApplied(ctx context.Context, version string) (bool, error) {
+	if applied, err := d.hasMigrationTag(ctx, migrationTagPrefix+version, nil); err != nil || applied {
+		return applied, err
+	}
+	return d.hasMigrationTag(ctx, legacyMigrationTag, []string{version})
+}
+
+// hasMigrationTag reports whether any table carries the tag key (with one of values, if given)
+func (d *DynamoDb) hasMigrationTag(ctx context.Context, key string, values []string) (bool, error) {
 	input := &resourcegroupstaggingapi.GetResourcesInput{
 		TagFilters: []rgTypes.TagFilter{
 			{
-				Key:    aws.String("Migration"),
-				Values: []string{version},
+				Key:    aws.String(key),
+				Values: values,
 			},
 		},
 		ResourceTypeFilters: []string{"dynamodb:table"},
@@ -107,22 +126,16 @@ func (d *DynamoDb) isMigrationApplied(ctx context.Context, version string) (bool
 }
 
 func (d *DynamoDb) recordMigration(ctx context.Context, version string, tableName string) error {
-	// Get table ARN
-	describeInput := &dynamodb.DescribeTableInput{
-		TableName: aws.String(tableName),
-	}
-
-	tableDesc, err := d.Client.DescribeTable(ctx, describeInput)
+	tableArn, err := d.tableArn(ctx, tableName)
 	if err != nil {
-		return fmt.Errorf("failed to get table ARN: %w", err)
+		return err
 	}
 
 	// Tag the table
 	input := &resourcegroupstaggingapi.TagResourcesInput{
-		ResourceARNList: []string{*tableDesc.Table.TableArn},
+		ResourceARNList: []string{tableArn},
 		Tags: map[string]string{
-			"Migration":  version,
-			"MigratedAt": time.Now().UTC().Format(time.RFC3339),
+			migrationTagPrefix + version: time.Now().UTC().Format(time.RFC3339),
 		},
 	}
 
@@ -134,21 +147,23 @@ func (d *DynamoDb) recordMigration(ctx context.Context, version string, tableNam
 	return nil
 }
 
-func (d *DynamoDb) removeMigrationRecord(ctx context.Context, tableName string) error {
-	// Get table ARN
-	describeInput := &dynamodb.DescribeTableInput{
-		TableName: aws.String(tableName),
+func (d *DynamoDb) removeMigrationRecord(ctx context.Context, version string, tableName string) error {
+	tableArn, err := d.tableArn(ctx, tableName)
+	if err != nil {
+		return err
 	}
 
-	tableDesc, err := d.Client.DescribeTable(ctx, describeInput)
-	if err != nil {
-		return fmt.Errorf("failed to get table ARN: %w", err)
+	tagKeys := []string{migrationTagPrefix + version}
+	if legacy, err := d.hasMigrationTag(ctx, legacyMigrationTag, []string{version}); err != nil {
+		return err
+	} else if legacy {
+		tagKeys = append(tagKeys, legacyMigrationTag, legacyMigratedAt)
 	}
 
 	// Remove migration tags
 	input := &resourcegroupstaggingapi.UntagResourcesInput{
-		ResourceARNList: []string{*tableDesc.Table.TableArn},
-		TagKeys:         []string{"Migration", "MigratedAt"},
+		ResourceARNList: []string{tableArn},
+		TagKeys:         tagKeys,
 	}
 
 	_, err = d.TaggingClient.UntagResources(ctx, input)
@@ -158,3 +173,16 @@ func (d *DynamoDb) removeMigrationRecord(ctx context.Context, tableName string)
 
 	return nil
 }
+
+// tableArn looks up the ARN of a table for tagging
+func (d *DynamoDb) tableArn(ctx context.Context, tableName string) (string, error) {
+	describeInput := &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	}
+
+	tableDesc, err := d.Client.DescribeTable(ctx, describeInput)
+	if err != nil {
+		return "", fmt.Errorf("failed to get table ARN: %w", err)
+	}
+	return *tableDesc.Table.TableArn, nil
+}
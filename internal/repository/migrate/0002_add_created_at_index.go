@@ -0,0 +1,111 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	CreatedAtIndexName    = "prefix-created_at-index"
+	CreatedAtIndexVersion = "20250901000000_created_at_index"
+)
+
+// AddCreatedAtIndex adds a (prefix, created_at) index for listing objects by upload time
+type AddCreatedAtIndex struct{}
+
+func (m *AddCreatedAtIndex) Version() string {
+	return CreatedAtIndexVersion
+}
+
+func (m *AddCreatedAtIndex) TableName() string {
+	return ObjectMetadataTableName
+}
+
+func (m *AddCreatedAtIndex) Up(ctx context.Context, client *dynamodb.Client) error {
+	input := &dynamodb.UpdateTableInput{
+		TableName: aws.String(ObjectMetadataTableName),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("prefix"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("created_at"),
+				AttributeType: types.ScalarAttributeTypeN, // Unix seconds
+			},
+		},
+		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+			{
+				Create: &types.CreateGlobalSecondaryIndexAction{
+					IndexName: aws.String(CreatedAtIndexName),
+					KeySchema: []types.KeySchemaElement{
+						{
+							AttributeName: aws.String("prefix"),
+							KeyType:       types.KeyTypeHash, // Partition Key
+						},
+						{
+							AttributeName: aws.String("created_at"),
+							KeyType:       types.KeyTypeRange, // Sort Key
+						},
+					},
+					// Listings return full metadata, so project every attribute
+					Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+				},
+			},
+		},
+	}
+
+	if _, err := client.UpdateTable(ctx, input); err != nil {
+		return err
+	}
+
+	// Wait for the index to finish backfilling existing items
+	return waitForIndex(ctx, client, CreatedAtIndexName, 15*time.Minute)
+}
+
+func (m *AddCreatedAtIndex) Down(ctx context.Context, client *dynamodb.Client) error {
+	input := &dynamodb.UpdateTableInput{
+		TableName: aws.String(ObjectMetadataTableName),
+		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+			{
+				Delete: &types.DeleteGlobalSecondaryIndexAction{
+					IndexName: aws.String(CreatedAtIndexName),
+				},
+			},
+		},
+	}
+
+	_, err := client.UpdateTable(ctx, input)
+	return err
+}
+
+// waitForIndex polls the table until the named global secondary index is active
+func waitForIndex(ctx context.Context, client *dynamodb.Client, indexName string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		output, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(ObjectMetadataTableName),
+		})
+		if err != nil {
+			return err
+		}
+		for _, index := range output.Table.GlobalSecondaryIndexes {
+			if aws.ToString(index.IndexName) == indexName && index.IndexStatus == types.IndexStatusActive {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("index %s did not become active: %w", indexName, ctx.Err())
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package migrate
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	AuditLogTableName = "audit_log"
+	AuditLogVersion   = "20251001000000_audit_log_table"
+)
+
+// CreateAuditLogTable creates the table audit_log: dynamodb writes audit records to
+// Records are keyed by object key and a time-ordered record ID.
+type CreateAuditLogTable struct{}
+
+func (m *CreateAuditLogTable) Version() string {
+	return AuditLogVersion
+}
+
+func (m *CreateAuditLogTable) TableName() string {
+	return AuditLogTableName
+}
+
+func (m *CreateAuditLogTable) Up(ctx context.Context, client *dynamodb.Client) error {
+	input := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("object_key"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("id"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("object_key"),
+				KeyType:       types.KeyTypeHash, // Partition Key
+			},
+			{
+				AttributeName: aws.String("id"),
+				KeyType:       types.KeyTypeRange, // Sort Key
+			},
+		},
+		TableName:   aws.String(AuditLogTableName),
+		BillingMode: types.BillingModePayPerRequest,
+		Tags: []types.Tag{
+			{
+				Key:   aws.String("Purpose"),
+				Value: aws.String("AuditLog"),
+			},
+		},
+	}
+
+	if _, err := client.CreateTable(ctx, input); err != nil {
+		return err
+	}
+
+	// Wait for table to become active
+	waiter := dynamodb.NewTableExistsWaiter(client)
+	return waiter.Wait(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(AuditLogTableName),
+	}, 5*time.Minute)
+}
+
+func (m *CreateAuditLogTable) Down(ctx context.Context, client *dynamodb.Client) error {
+	_, err := client.DeleteTable(ctx, &dynamodb.DeleteTableInput{
+		TableName: aws.String(AuditLogTableName),
+	})
+	return err
+}
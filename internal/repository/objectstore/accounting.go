@@ -0,0 +1,181 @@
+package objectstore
+
+import (
+	"io"
+	"maps"
+	"sync"
+	"sync/atomic"
+)
+
+// RequestKind is a kind of repository request counted by an Accounting
+type RequestKind string
+
+const (
+	RequestUpload   RequestKind = "upload"
+	RequestDownload RequestKind = "download"
+	RequestDelete   RequestKind = "delete" // Deleting an object, or every object under a prefix
+	RequestHead     RequestKind = "head"   // Reading an object's size
+	RequestList     RequestKind = "list"   // Listing keys or a bucket's usage
+)
+
+// TransferCounts are the repository requests made and the bytes they transferred
+type TransferCounts struct {
+	Requests        int64                 `json:"requests"`
+	ByKind          map[RequestKind]int64 `json:"by_kind"`
+	BytesUploaded   int64                 `json:"bytes_uploaded"`
+	BytesDownloaded int64                 `json:"bytes_downloaded"`
+}
+
+// add counts one request
+func (c *TransferCounts) add(kind RequestKind, bytes int64) {
+	if c.ByKind == nil {
+		c.ByKind = make(map[RequestKind]int64)
+	}
+	c.Requests++
+	c.ByKind[kind]++
+	switch kind {
+	case RequestUpload:
+		c.BytesUploaded += bytes
+	case RequestDownload:
+		c.BytesDownloaded += bytes
+	}
+}
+
+// AccountingSummary totals the requests of an Accounting, overall and per provider
+type AccountingSummary struct {
+	TransferCounts
+	Providers map[string]TransferCounts `json:"providers"` // Keyed by storage type, e.g. "s3"
+}
+
+// Accounting counts the requests repositories make and the bytes they transfer, for
+// attributing the cost of a run. Requests are provider API calls, since that is what
+// providers bill: a multipart upload counts each of its calls, and a prefix delete counts
+// each listing page and each (batch) delete it sends. Failed calls are counted too, with
+// the bytes they moved before failing where known.
+// It is safe for concurrent use; a nil Accounting counts nothing.
+type Accounting struct {
+	mu        sync.Mutex
+	providers map[string]*TransferCounts
+}
+
+// NewAccounting creates an empty accounting collector
+func NewAccounting() *Accounting {
+	return &Accounting{providers: make(map[string]*TransferCounts)}
+}
+
+// Record counts one request to a provider, with the bytes uploaded or downloaded by it
+func (a *Accounting) Record(provider string, kind RequestKind, bytes int64) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	counts, ok := a.providers[provider]
+	if !ok {
+		counts = &TransferCounts{}
+		a.providers[provider] = counts
+	}
+	counts.add(kind, bytes)
+}
+
+// Summary returns the requests counted so far
+func (a *Accounting) Summary() AccountingSummary {
+	summary := AccountingSummary{
+		TransferCounts: TransferCounts{ByKind: make(map[RequestKind]int64)},
+		Providers:      make(map[string]TransferCounts),
+	}
+	if a == nil {
+		return summary
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for provider, counts := range a.providers {
+		summary.Providers[provider] = TransferCounts{
+			Requests:        counts.Requests,
+			ByKind:          maps.Clone(counts.ByKind),
+			BytesUploaded:   counts.BytesUploaded,
+			BytesDownloaded: counts.BytesDownloaded,
+		}
+		summary.Requests += counts.Requests
+		summary.BytesUploaded += counts.BytesUploaded
+		summary.BytesDownloaded += counts.BytesDownloaded
+		for kind, n := range counts.ByKind {
+			summary.ByKind[kind] += n
+		}
+	}
+	return summary
+}
+
+// AccountingConfigurable is implemented by repositories that report their requests to an Accounting
+type AccountingConfigurable interface {
+	SetAccounting(accounting *Accounting)
+}
+
+// requestAccounting is embedded in repositories to report their requests to a configurable Accounting
+type requestAccounting struct {
+	accounting *Accounting // nil counts nothing
+}
+
+// SetAccounting sets the collector requests are reported to
+func (a *requestAccounting) SetAccounting(accounting *Accounting) {
+	a.accounting = accounting
+}
+
+// countUpload returns the body to upload in place of r and a function recording the finished upload
+// Bodies of known size are passed through unchanged, so they can still seek and be retried,
+// and count in full once uploaded. Other bodies count the bytes read from them.
+func (a *requestAccounting) countUpload(provider string, r io.Reader) (io.Reader, func(err error)) {
+	if a.accounting == nil {
+		return r, func(error) {}
+	}
+	if size := readerSize(r); size >= 0 {
+		return r, func(err error) {
+			if err != nil {
+				size = 0
+			}
+			a.accounting.Record(provider, RequestUpload, size)
+		}
+	}
+	counter := &countingReader{Reader: r}
+	return counter, func(error) { a.accounting.Record(provider, RequestUpload, counter.n.Load()) }
+}
+
+// recordCalls counts calls more requests of the given kind, none of them transferring bytes itself
+func (a *requestAccounting) recordCalls(provider string, kind RequestKind, calls int64) {
+	for ; calls > 0; calls-- {
+		a.accounting.Record(provider, kind, 0)
+	}
+}
+
+// countDownload returns the destination to download to in place of dest and a function recording the finished download
+func (a *requestAccounting) countDownload(provider string, dest io.WriterAt) (io.WriterAt, func()) {
+	if a.accounting == nil {
+		return dest, func() {}
+	}
+	counter := &countingWriterAt{w: dest}
+	return counter, func() { a.accounting.Record(provider, RequestDownload, counter.n.Load()) }
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// countingWriterAt counts the bytes written through it; writes may be concurrent
+type countingWriterAt struct {
+	w io.WriterAt
+	n atomic.Int64
+}
+
+func (c *countingWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	n, err := c.w.WriteAt(p, off)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,518 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	log "github.com/sirupsen/logrus"
+	zerrors "github.com/zzenonn/zstore/internal/errors"
+)
+
+const (
+	// ADLSType stores objects in an Azure Data Lake Storage Gen2 file system; the bucket name is "account/filesystem[/path]"
+	ADLSType RepositoryType = "adls"
+
+	// adlsAPIVersion is the Data Lake Storage REST API version requests are signed for
+	adlsAPIVersion = "2021-06-08"
+	// adlsAppendSize is how much of an upload is sent per append request
+	adlsAppendSize = 8 * 1024 * 1024
+)
+
+// init registers ADLS Gen2 file systems as a provider
+func init() {
+	RegisterProvider(Provider{
+		Type:        ADLSType,
+		Scheme:      "adls",
+		Description: "Azure Data Lake Storage Gen2 (adls://account/filesystem/path); needs AZURE_STORAGE_KEY or AZURE_STORAGE_SAS_TOKEN",
+		Create: func(f *ObjectRepositoryFactory, config BucketConfig) (ObjectRepository, error) {
+			credential, err := adlsCredentialFromEnv()
+			if err != nil {
+				return nil, err
+			}
+			repo, err := NewADLSObjectRepository(http.DefaultClient, config.Name, os.Getenv("AZURE_STORAGE_DFS_ENDPOINT"), credential)
+			if err != nil {
+				return nil, err
+			}
+			return repo, nil
+		},
+		Check: func(ctx context.Context, f *ObjectRepositoryFactory) error {
+			_, err := adlsCredentialFromEnv()
+			return err
+		},
+	})
+}
+
+// ADLSCredential authenticates requests to a storage account
+// AccountKey (base64, as shown in the portal) signs each request with Shared Key; SASToken
+// is appended to each request instead. One of them must be set.
+type ADLSCredential struct {
+	AccountKey string
+	SASToken   string
+}
+
+// adlsCredentialFromEnv reads the credential from AZURE_STORAGE_KEY or AZURE_STORAGE_SAS_TOKEN
+func adlsCredentialFromEnv() (ADLSCredential, error) {
+	credential := ADLSCredential{
+		AccountKey: os.Getenv("AZURE_STORAGE_KEY"),
+		SASToken:   strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?"),
+	}
+	if credential.AccountKey == "" && credential.SASToken == "" {
+		return ADLSCredential{}, fmt.Errorf("set AZURE_STORAGE_KEY or AZURE_STORAGE_SAS_TOKEN to use ADLS buckets")
+	}
+	return credential, nil
+}
+
+// ADLSObjectRepository implements ObjectRepository on an Azure Data Lake Storage Gen2 file system.
+// It talks to the Data Lake (DFS) REST API, where keys are paths in a real directory tree
+// on accounts with a hierarchical namespace. That makes DeletePrefix a single recursive
+// directory delete, done by the service, instead of a listing followed by a delete per
+// object, which is what sets it apart from blob-style stores for large prefixes. Keys are
+// stored under the optional base path of the bucket name.
+//
+// Prefixes are paths: DeletePrefix removes the file or directory at the prefix and
+// everything under it, but not siblings whose names merely start with the same string.
+// zstore only deletes whole object prefixes, for which the two are the same.
+type ADLSObjectRepository struct {
+	client     *http.Client
+	bucketName string // As configured: "account/filesystem[/path]"
+	endpoint   string // DFS endpoint without a trailing slash, e.g. "https://account.dfs.core.windows.net"
+	account    string
+	filesystem string
+	basePath   string // Path prefix inside the file system, without slashes at either end
+	key        []byte // Decoded account key (nil with a SAS token)
+	sasQuery   url.Values
+
+	progressOutput
+	requestAccounting
+}
+
+// adlsPathList is the JSON returned by listing paths
+type adlsPathList struct {
+	Paths []struct {
+		Name          string `json:"name"`
+		IsDirectory   string `json:"isDirectory"`
+		ContentLength string `json:"contentLength"`
+	} `json:"paths"`
+}
+
+// adlsErrorResponse is the JSON body of a failed request
+type adlsErrorResponse struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewADLSObjectRepository creates a repository for bucketName ("account/filesystem[/path]")
+// An empty endpoint uses the account's public DFS endpoint.
+func NewADLSObjectRepository(client *http.Client, bucketName, endpoint string, credential ADLSCredential) (*ADLSObjectRepository, error) {
+	parts := strings.SplitN(strings.Trim(bucketName, "/"), "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid ADLS bucket %q (expected account/filesystem[/path])", bucketName)
+	}
+
+	repo := &ADLSObjectRepository{
+		client:     client,
+		bucketName: bucketName,
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		account:    parts[0],
+		filesystem: parts[1],
+	}
+	if len(parts) == 3 {
+		repo.basePath = strings.Trim(parts[2], "/")
+	}
+	if repo.endpoint == "" {
+		repo.endpoint = fmt.Sprintf("https://%s.dfs.core.windows.net", repo.account)
+	}
+
+	switch {
+	case credential.AccountKey != "":
+		key, err := base64.StdEncoding.DecodeString(credential.AccountKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ADLS account key: %w", err)
+		}
+		repo.key = key
+	case credential.SASToken != "":
+		sasQuery, err := url.ParseQuery(credential.SASToken)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ADLS SAS token: %w", err)
+		}
+		repo.sasQuery = sasQuery
+	default:
+		return nil, fmt.Errorf("no ADLS account key or SAS token given")
+	}
+	return repo, nil
+}
+
+// Upload creates (or replaces) the file at key and writes the data in appends of adlsAppendSize
+// It returns "bucket/key" with the bucket name path-escaped, so the bucket stays one segment.
+func (r *ADLSObjectRepository) Upload(ctx context.Context, key string, reader io.Reader, quiet bool) (location string, err error) {
+	reader, recordUpload := r.countUpload(r.GetStorageType(), reader)
+	var calls int64 // Append and flush calls, on top of the create counted by recordUpload
+	defer func() {
+		recordUpload(err)
+		r.recordCalls(r.GetStorageType(), RequestUpload, calls)
+	}()
+
+	if !quiet {
+		log.Debugf("Uploading to ADLS %s: %s", r.bucketName, key)
+		bar := r.newProgressBar(readerSize(reader), "uploading")
+		pbReader := progressbar.NewReader(reader, bar)
+		reader = &pbReader
+	}
+
+	filePath := r.fullPath(key)
+	resp, err := r.do(ctx, http.MethodPut, filePath, url.Values{"resource": {"file"}}, nil, http.StatusCreated)
+	if err != nil {
+		return "", fmt.Errorf("failed to create ADLS file %s: %w", key, err)
+	}
+	resp.Body.Close()
+
+	buf := make([]byte, adlsAppendSize)
+	var position int64
+	for {
+		n, readErr := io.ReadFull(reader, buf)
+		if n > 0 {
+			params := url.Values{"action": {"append"}, "position": {strconv.FormatInt(position, 10)}}
+			calls++
+			resp, err := r.do(ctx, http.MethodPatch, filePath, params, buf[:n], http.StatusAccepted)
+			if err != nil {
+				return "", fmt.Errorf("failed to append to ADLS file %s: %w", key, err)
+			}
+			resp.Body.Close()
+			position += int64(n)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return "", fmt.Errorf("failed to read upload data: %w", readErr)
+		}
+	}
+
+	params := url.Values{"action": {"flush"}, "position": {strconv.FormatInt(position, 10)}}
+	calls++
+	resp, err = r.do(ctx, http.MethodPatch, filePath, params, nil, http.StatusOK)
+	if err != nil {
+		return "", fmt.Errorf("failed to flush ADLS file %s: %w", key, err)
+	}
+	resp.Body.Close()
+
+	log.Debugf("Completed ADLS upload for %s, wrote %d bytes", key, position)
+	return fmt.Sprintf("%s/%s", url.PathEscape(r.bucketName), key), nil
+}
+
+// Download reads the file at key into dest
+func (r *ADLSObjectRepository) Download(ctx context.Context, key string, dest io.WriterAt, quiet bool) error {
+	dest, recordDownload := r.countDownload(r.GetStorageType(), dest)
+	defer recordDownload()
+
+	if !quiet {
+		log.Debugf("Downloading from ADLS %s: %s", r.bucketName, key)
+	}
+
+	resp, err := r.do(ctx, http.MethodGet, r.fullPath(key), nil, nil, http.StatusOK)
+	if err != nil {
+		return fmt.Errorf("failed to download from ADLS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var proxyReader io.Reader = resp.Body
+	if !quiet {
+		bar := r.newProgressBar(resp.ContentLength, "downloading")
+		pbReader := progressbar.NewReader(resp.Body, bar)
+		proxyReader = &pbReader
+	}
+
+	written, err := io.Copy(io.NewOffsetWriter(dest, 0), proxyReader)
+	if err != nil {
+		return fmt.Errorf("failed to read from ADLS: %w", err)
+	}
+
+	log.Debugf("Completed ADLS download for %s, wrote %d bytes", key, written)
+	return nil
+}
+
+// Delete removes the file at key; a missing file is not an error
+func (r *ADLSObjectRepository) Delete(ctx context.Context, key string) error {
+	r.accounting.Record(r.GetStorageType(), RequestDelete, 0)
+	resp, err := r.do(ctx, http.MethodDelete, r.fullPath(key), nil, nil, http.StatusOK)
+	if err != nil {
+		if isADLSNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete from ADLS: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// DeletePrefix removes the directory (or file) at prefix with one recursive delete
+// On accounts without a hierarchical namespace the service deletes in batches and
+// returns a continuation token, which is followed until the delete completes.
+func (r *ADLSObjectRepository) DeletePrefix(ctx context.Context, prefix string) error {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" && r.basePath == "" {
+		return fmt.Errorf("refusing to delete the root of ADLS file system %s", r.filesystem)
+	}
+
+	params := url.Values{"recursive": {"true"}}
+	for {
+		r.accounting.Record(r.GetStorageType(), RequestDelete, 0)
+		resp, err := r.do(ctx, http.MethodDelete, r.fullPath(prefix), params, nil, http.StatusOK)
+		if err != nil {
+			if isADLSNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to delete ADLS directory %s: %w", prefix, err)
+		}
+		resp.Body.Close()
+
+		continuation := resp.Header.Get("x-ms-continuation")
+		if continuation == "" {
+			return nil
+		}
+		params.Set("continuation", continuation)
+	}
+}
+
+// ListKeys returns the keys of every file whose key starts with prefix
+// The deepest directory the prefix names is listed recursively and filtered by the rest.
+func (r *ADLSObjectRepository) ListKeys(ctx context.Context, prefix string) ([]string, error) {
+	r.accounting.Record(r.GetStorageType(), RequestList, 0)
+	directory := r.basePath
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		directory = r.fullPath(prefix[:i])
+	}
+	fullPrefix := strings.TrimPrefix(prefix, "/")
+	if r.basePath != "" {
+		fullPrefix = r.basePath + "/" + fullPrefix
+	}
+
+	params := url.Values{"resource": {"filesystem"}, "recursive": {"true"}}
+	if directory != "" {
+		params.Set("directory", directory)
+	}
+
+	var keys []string
+	for {
+		resp, err := r.do(ctx, http.MethodGet, "", params, nil, http.StatusOK)
+		if err != nil {
+			if isADLSNotFound(err) {
+				return keys, nil // The directory does not exist
+			}
+			return nil, fmt.Errorf("failed to list ADLS paths under %s: %w", prefix, err)
+		}
+		var list adlsPathList
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode ADLS path list: %w", err)
+		}
+
+		for _, path := range list.Paths {
+			if path.IsDirectory == "true" || !strings.HasPrefix(path.Name, fullPrefix) {
+				continue
+			}
+			keys = append(keys, r.relativeKey(path.Name))
+		}
+
+		continuation := resp.Header.Get("x-ms-continuation")
+		if continuation == "" {
+			return keys, nil
+		}
+		params.Set("continuation", continuation)
+	}
+}
+
+// GetObjectSize returns the size of the file at key, or ErrObjectNotFound
+func (r *ADLSObjectRepository) GetObjectSize(ctx context.Context, key string) (int64, error) {
+	r.accounting.Record(r.GetStorageType(), RequestHead, 0)
+	resp, err := r.do(ctx, http.MethodHead, r.fullPath(key), nil, nil, http.StatusOK)
+	if err != nil {
+		if isADLSNotFound(err) {
+			return 0, zerrors.ErrObjectNotFound
+		}
+		return 0, fmt.Errorf("failed to stat ADLS file: %w", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("x-ms-resource-type") == "directory" {
+		return 0, zerrors.ErrObjectNotFound
+	}
+	return resp.ContentLength, nil
+}
+
+// GetBucketName returns the bucket name as configured ("account/filesystem[/path]")
+func (r *ADLSObjectRepository) GetBucketName() string {
+	return r.bucketName
+}
+
+// GetStorageType returns the storage type
+func (r *ADLSObjectRepository) GetStorageType() string {
+	return string(ADLSType)
+}
+
+// fullPath returns the path of key inside the file system
+func (r *ADLSObjectRepository) fullPath(key string) string {
+	key = strings.Trim(key, "/")
+	switch {
+	case r.basePath == "":
+		return key
+	case key == "":
+		return r.basePath
+	default:
+		return r.basePath + "/" + key
+	}
+}
+
+// relativeKey returns the key of a path inside the file system
+func (r *ADLSObjectRepository) relativeKey(path string) string {
+	if r.basePath == "" {
+		return path
+	}
+	return strings.TrimPrefix(path, r.basePath+"/")
+}
+
+// adlsStatusError is a request that failed with an unexpected HTTP status
+type adlsStatusError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *adlsStatusError) Error() string {
+	if e.code != "" {
+		return fmt.Sprintf("adls: %s (%d): %s", e.code, e.status, e.message)
+	}
+	return fmt.Sprintf("adls: unexpected status %d", e.status)
+}
+
+// isADLSNotFound reports whether err is a 404 from the service
+func isADLSNotFound(err error) bool {
+	var statusErr *adlsStatusError
+	return errors.As(err, &statusErr) && statusErr.status == http.StatusNotFound
+}
+
+// do sends a signed request for path (relative to the file system) and returns the response on the expected status
+// On failure the service's error code and message are returned and the body is closed.
+func (r *ADLSObjectRepository) do(ctx context.Context, method, path string, params url.Values, body []byte, expected int) (*http.Response, error) {
+	query := url.Values{}
+	for name, values := range params {
+		query[name] = values
+	}
+	for name, values := range r.sasQuery {
+		query[name] = values
+	}
+
+	target := &url.URL{Path: "/" + r.filesystem}
+	if path != "" {
+		target.Path += "/" + path
+	}
+	endpoint := r.endpoint + target.EscapedPath()
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	req.Header.Set("x-ms-version", adlsAPIVersion)
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	if r.key != nil {
+		req.Header.Set("Authorization", "SharedKey "+r.account+":"+r.sign(req, params))
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != expected {
+		defer resp.Body.Close()
+		statusErr := &adlsStatusError{status: resp.StatusCode}
+		var errorBody adlsErrorResponse
+		if json.NewDecoder(resp.Body).Decode(&errorBody) == nil {
+			statusErr.code, statusErr.message = errorBody.Error.Code, errorBody.Error.Message
+		}
+		if statusErr.code == "" {
+			statusErr.code = resp.Header.Get("x-ms-error-code")
+		}
+		return nil, statusErr
+	}
+	return resp, nil
+}
+
+// sign computes the Shared Key signature of a request
+// See "Authorize with Shared Key" in the Azure Storage REST API reference; the SAS query
+// is never part of a Shared Key request, so only params are canonicalized.
+func (r *ADLSObjectRepository) sign(req *http.Request, params url.Values) string {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	var headerNames []string
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-ms-") {
+			headerNames = append(headerNames, lower)
+		}
+	}
+	sort.Strings(headerNames)
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.TrimSpace(req.Header.Get(name)))
+	}
+
+	var canonicalResource strings.Builder
+	fmt.Fprintf(&canonicalResource, "/%s%s", r.account, req.URL.EscapedPath())
+	paramNames := make([]string, 0, len(params))
+	lowered := make(map[string][]string, len(params))
+	for name, values := range params {
+		lower := strings.ToLower(name)
+		if _, seen := lowered[lower]; !seen {
+			paramNames = append(paramNames, lower)
+		}
+		lowered[lower] = append(lowered[lower], values...)
+	}
+	sort.Strings(paramNames)
+	for _, name := range paramNames {
+		values := lowered[name]
+		sort.Strings(values)
+		fmt.Fprintf(&canonicalResource, "\n%s:%s", name, strings.Join(values, ","))
+	}
+
+	stringToSign := strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date (x-ms-date is used instead)
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	}, "\n") + "\n" + canonicalHeaders.String() + canonicalResource.String()
+
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,33 @@
+package objectstore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChecksumAlgorithm is the checksum a provider validates uploaded objects with
+type ChecksumAlgorithm string
+
+const (
+	// ChecksumCRC32C is a fast CRC computed as the upload streams
+	ChecksumCRC32C ChecksumAlgorithm = "CRC32C"
+	// ChecksumSHA256 is a cryptographic hash, slower to compute than CRC32C
+	ChecksumSHA256 ChecksumAlgorithm = "SHA256"
+)
+
+// ChecksumConfigurable is implemented by repositories that can ask the provider to verify
+// a checksum of every upload and reject corrupted ones (S3 flexible checksums). GCS
+// validates uploads with CRC32C on its own, so its repository does not need this.
+type ChecksumConfigurable interface {
+	SetChecksumAlgorithm(algorithm ChecksumAlgorithm)
+}
+
+// ParseChecksumAlgorithm parses "crc32c" or "sha256" (case-insensitive)
+// An empty value returns "", which leaves the SDK's default checksum behaviour in place.
+func ParseChecksumAlgorithm(value string) (ChecksumAlgorithm, error) {
+	switch algorithm := ChecksumAlgorithm(strings.ToUpper(strings.TrimSpace(value))); algorithm {
+	case "", ChecksumCRC32C, ChecksumSHA256:
+		return algorithm, nil
+	}
+	return "", fmt.Errorf("unknown checksum algorithm %q (use crc32c or sha256)", value)
+}
@@ -0,0 +1,36 @@
+package objectstore
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// CustomerKeySize is the required length of a customer-provided AES-256 key
+const CustomerKeySize = 32
+
+// CustomerKeyConfigurable is implemented by repositories that support provider-side
+// encryption with a customer-provided key (S3 SSE-C, GCS CSEK). The key is never
+// stored by zstore, so the same key must be supplied to read the objects back.
+type CustomerKeyConfigurable interface {
+	SetCustomerKey(key []byte)
+}
+
+// ParseCustomerKey decodes a base64-encoded 256-bit customer key
+func ParseCustomerKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("customer key must be base64 encoded: %w", err)
+	}
+	if len(key) != CustomerKeySize {
+		return nil, fmt.Errorf("customer key must be %d bytes, got %d", CustomerKeySize, len(key))
+	}
+	return key, nil
+}
+
+// sseCustomerHeaders returns the S3 SSE-C algorithm, base64 key and base64 key MD5
+func sseCustomerHeaders(key []byte) (algorithm, encodedKey, keyMD5 string) {
+	sum := md5.Sum(key)
+	return "AES256", base64.StdEncoding.EncodeToString(key), base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -2,49 +2,94 @@ package objectstore
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"sync"
 
 	"cloud.google.com/go/storage"
 	"github.com/schollz/progressbar/v3"
 	log "github.com/sirupsen/logrus"
+	zerrors "github.com/zzenonn/zstore/internal/errors"
+	"google.golang.org/api/iterator"
 )
 
+// init registers GCS under the gs:// scheme
+func init() {
+	RegisterProvider(Provider{
+		Type:        GCSType,
+		Scheme:      "gs",
+		Description: "Google Cloud Storage",
+		Create: func(f *ObjectRepositoryFactory, config BucketConfig) (ObjectRepository, error) {
+			if f.gcsClient == nil {
+				return nil, fmt.Errorf("GCS client not configured")
+			}
+			repo := NewGCSObjectRepository(f.gcsClient, config.Name)
+			return &repo, nil
+		},
+		Check: func(ctx context.Context, f *ObjectRepositoryFactory) error {
+			if f.gcsClient == nil {
+				return fmt.Errorf("GCS client not configured (no application default credentials)")
+			}
+			return nil
+		},
+	})
+}
+
+// gcsDeleteConcurrency bounds the number of parallel object deletes in DeletePrefix
+const gcsDeleteConcurrency = 16
+
 // GCSObjectRepository implements ObjectRepository for Google Cloud Storage
 type GCSObjectRepository struct {
-	client     *storage.Client
-	bucketName string
+	client      *storage.Client
+	bucketName  string
+	customerKey []byte    // Customer-supplied encryption key; nil uses Google-managed keys
+	retention   Retention // Object retention applied to uploads (zero value disables)
+
+	progressOutput
+	requestAccounting
+}
+
+// SetCustomerKey enables customer-supplied encryption with the given 256-bit key
+func (r *GCSObjectRepository) SetCustomerKey(key []byte) {
+	r.customerKey = key
+}
+
+// SetRetention applies GCS object retention to every object uploaded afterwards
+func (r *GCSObjectRepository) SetRetention(retention Retention) {
+	r.retention = retention
+}
+
+// object returns a handle for key, carrying the customer key when one is configured
+func (r *GCSObjectRepository) object(key string) *storage.ObjectHandle {
+	obj := r.client.Bucket(r.bucketName).Object(key)
+	if r.customerKey != nil {
+		obj = obj.Key(r.customerKey)
+	}
+	return obj
 }
 
 // Upload uploads an object to GCS
 func (r *GCSObjectRepository) Upload(ctx context.Context, key string, reader io.Reader, quiet bool) (string, error) {
-	bucket := r.client.Bucket(r.bucketName)
-	obj := bucket.Object(key)
+	reader, recordUpload := r.countUpload(r.GetStorageType(), reader)
+	obj := r.object(key)
 
 	writer := obj.NewWriter(ctx)
 	defer writer.Close()
-
-	// Determine size for progress bar
-	seeker, ok := reader.(io.Seeker)
-	var size int64 = -1
-	if ok {
-		if current, err := seeker.Seek(0, io.SeekCurrent); err == nil {
-			if end, err := seeker.Seek(0, io.SeekEnd); err == nil {
-				size = end - current
-				seeker.Seek(current, io.SeekStart)
-			}
-		}
+	if r.retention.Enabled() {
+		writer.Retention = &storage.ObjectRetention{Mode: gcsRetentionMode(r.retention.Mode), RetainUntil: r.retention.RetainUntil}
 	}
 
 	var proxyReader io.Reader = reader
 	if !quiet {
 		log.Debugf("Uploading to GCS: gs://%s/%s", r.bucketName, key)
-		bar := progressbar.DefaultBytes(size, "uploading")
+		bar := r.newProgressBar(readerSize(reader), "uploading")
 		pbReader := progressbar.NewReader(reader, bar)
 		proxyReader = &pbReader
 	}
 
 	_, err := io.Copy(writer, proxyReader)
+	recordUpload(err)
 	if err != nil {
 		return "", fmt.Errorf("failed to upload to GCS: %w", err)
 	}
@@ -72,13 +117,15 @@ func (pr *progressReader) Close() error {
 
 // Download downloads an object from GCS
 func (r *GCSObjectRepository) Download(ctx context.Context, key string, dest io.WriterAt, quiet bool) error {
+	dest, recordDownload := r.countDownload(r.GetStorageType(), dest)
+	defer recordDownload()
+
 	if !quiet {
 		log.Debugf("Downloading from GCS: gs://%s/%s", r.bucketName, key)
 	}
 
 	// Get object attributes first to check size
-	bucket := r.client.Bucket(r.bucketName)
-	obj := bucket.Object(key)
+	obj := r.object(key)
 	attrs, err := obj.Attrs(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get GCS object attributes: %w", err)
@@ -95,7 +142,7 @@ func (r *GCSObjectRepository) Download(ctx context.Context, key string, dest io.
 	// Setup progress bar if not quiet
 	var proxyReader io.Reader = reader
 	if !quiet {
-		bar := progressbar.DefaultBytes(attrs.Size, "downloading")
+		bar := r.newProgressBar(attrs.Size, "downloading")
 		pbReader := progressbar.NewReader(reader, bar)
 		proxyReader = &pbReader
 	}
@@ -118,6 +165,7 @@ func (r *GCSObjectRepository) Download(ctx context.Context, key string, dest io.
 
 // Delete deletes an object from GCS
 func (r *GCSObjectRepository) Delete(ctx context.Context, key string) error {
+	r.accounting.Record(r.GetStorageType(), RequestDelete, 0)
 	bucket := r.client.Bucket(r.bucketName)
 	obj := bucket.Object(key)
 
@@ -129,31 +177,109 @@ func (r *GCSObjectRepository) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// GetObjectSize returns the size of an object from its attributes
+func (r *GCSObjectRepository) GetObjectSize(ctx context.Context, key string) (int64, error) {
+	r.accounting.Record(r.GetStorageType(), RequestHead, 0)
+	attrs, err := r.client.Bucket(r.bucketName).Object(key).Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return 0, zerrors.ErrObjectNotFound
+		}
+		return 0, fmt.Errorf("failed to get GCS object attributes: %w", err)
+	}
+	return attrs.Size, nil
+}
+
 // DeletePrefix deletes all objects with the given prefix from GCS
+// Objects are deleted concurrently and failures are aggregated into one error.
 func (r *GCSObjectRepository) DeletePrefix(ctx context.Context, prefix string) error {
+	r.accounting.Record(r.GetStorageType(), RequestList, 0)
 	bucket := r.client.Bucket(r.bucketName)
 
 	// List objects with prefix
 	query := &storage.Query{Prefix: prefix}
 	it := bucket.Objects(ctx, query)
 
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	semaphore := make(chan struct{}, gcsDeleteConcurrency) // Limits concurrent deletes
+
 	for {
 		attrs, err := it.Next()
-		if err == storage.ErrObjectNotExist {
-			break
+		if err == iterator.Done {
+			break // Listing exhausted
 		}
 		if err != nil {
-			return fmt.Errorf("failed to list objects with prefix %s: %w", prefix, err)
+			// A listing failure is a real error; stop listing but let started deletes finish
+			mu.Lock()
+			errs = append(errs, fmt.Errorf("failed to list objects with prefix %s: %w", prefix, err))
+			mu.Unlock()
+			break
 		}
 
-		// Delete each object
-		obj := bucket.Object(attrs.Name)
-		if err := obj.Delete(ctx); err != nil {
-			log.Warnf("Failed to delete object %s: %v", attrs.Name, err)
+		// Delete each object in its own goroutine
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			r.accounting.Record(r.GetStorageType(), RequestDelete, 0)
+			err := bucket.Object(name).Delete(ctx)
+			if errors.Is(err, storage.ErrObjectNotExist) {
+				return // Already gone, e.g. deleted concurrently
+			}
+			if err != nil {
+				log.Warnf("Failed to delete object %s: %v", name, err)
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("failed to delete %s: %w", name, err))
+				mu.Unlock()
+			}
+		}(attrs.Name)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// ListKeys returns the names of all objects with the given prefix
+func (r *GCSObjectRepository) ListKeys(ctx context.Context, prefix string) ([]string, error) {
+	r.accounting.Record(r.GetStorageType(), RequestList, 0)
+	it := r.client.Bucket(r.bucketName).Objects(ctx, &storage.Query{Prefix: prefix})
+
+	var keys []string
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects with prefix %s: %w", prefix, err)
 		}
+		keys = append(keys, attrs.Name)
 	}
+	return keys, nil
+}
 
-	return nil
+// GetUsage counts the objects in the bucket and their total size
+func (r *GCSObjectRepository) GetUsage(ctx context.Context) (BucketUsage, error) {
+	r.accounting.Record(r.GetStorageType(), RequestList, 0)
+	it := r.client.Bucket(r.bucketName).Objects(ctx, nil)
+
+	var usage BucketUsage
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return BucketUsage{}, fmt.Errorf("failed to list objects in %s: %w", r.bucketName, err)
+		}
+		usage.Objects++
+		usage.Bytes += attrs.Size
+	}
+	return usage, nil
 }
 
 // GetBucketName returns the bucket name
@@ -0,0 +1,226 @@
+package objectstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/schollz/progressbar/v3"
+	log "github.com/sirupsen/logrus"
+	zerrors "github.com/zzenonn/zstore/internal/errors"
+)
+
+// init registers IPFS nodes as a provider
+func init() {
+	RegisterProvider(Provider{
+		Type:        IPFSType,
+		Scheme:      "ipfs",
+		Description: "IPFS node (HTTP RPC API); needs no credentials",
+		Create: func(f *ObjectRepositoryFactory, config BucketConfig) (ObjectRepository, error) {
+			repo := NewIPFSObjectRepository(http.DefaultClient, config.Name)
+			return &repo, nil
+		},
+	})
+}
+
+// IPFSObjectRepository implements ObjectRepository on top of an IPFS node's HTTP RPC API.
+// IPFS is content-addressed: Upload adds and pins the data and returns its CID, which
+// callers store as the object key. Identical content always maps to the same CID, so
+// duplicate shards are stored once. Delete unpins the CID and lets the node garbage
+// collect it; a pin is shared by every object referencing the same content.
+type IPFSObjectRepository struct {
+	client  *http.Client
+	apiAddr string // Node API address, e.g. "127.0.0.1:5001"
+
+	progressOutput
+	requestAccounting
+}
+
+// ipfsAddResponse is the JSON returned by /api/v0/add
+type ipfsAddResponse struct {
+	Name string `json:"Name"`
+	Hash string `json:"Hash"`
+	Size string `json:"Size"`
+}
+
+// ipfsFilesStatResponse is the JSON returned by /api/v0/files/stat
+type ipfsFilesStatResponse struct {
+	Hash string `json:"Hash"`
+	Size int64  `json:"Size"`
+}
+
+// ipfsErrorResponse is the JSON body of a failed RPC call
+type ipfsErrorResponse struct {
+	Message string `json:"Message"`
+}
+
+// Upload adds and pins the data on the IPFS node and returns "apiAddr/CID"
+// The key is only used for logging; the stored key is the CID.
+func (r *IPFSObjectRepository) Upload(ctx context.Context, key string, reader io.Reader, quiet bool) (string, error) {
+	reader, recordUpload := r.countUpload(r.GetStorageType(), reader)
+	var proxyReader io.Reader = reader
+	if !quiet {
+		log.Debugf("Uploading to IPFS node %s: %s", r.apiAddr, key)
+		bar := r.newProgressBar(readerSize(reader), "uploading")
+		pbReader := progressbar.NewReader(reader, bar)
+		proxyReader = &pbReader
+	}
+
+	// Stream the multipart body instead of buffering the shard twice
+	pipeReader, pipeWriter := io.Pipe()
+	form := multipart.NewWriter(pipeWriter)
+	go func() {
+		part, err := form.CreateFormFile("file", "shard")
+		if err == nil {
+			_, err = io.Copy(part, proxyReader)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+
+	params := url.Values{"pin": {"true"}, "cid-version": {"1"}, "quieter": {"true"}}
+	resp, err := r.call(ctx, "add", params, pipeReader, form.FormDataContentType())
+	recordUpload(err)
+	if err != nil {
+		pipeReader.CloseWithError(err)
+		return "", fmt.Errorf("failed to upload to IPFS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var added ipfsAddResponse
+	if err := json.NewDecoder(resp.Body).Decode(&added); err != nil {
+		return "", fmt.Errorf("failed to decode IPFS add response: %w", err)
+	}
+	if added.Hash == "" {
+		return "", fmt.Errorf("IPFS add returned no CID")
+	}
+
+	log.Debugf("Pinned %s as %s", key, added.Hash)
+	return fmt.Sprintf("%s/%s", r.apiAddr, added.Hash), nil
+}
+
+// Download fetches the content for a CID
+func (r *IPFSObjectRepository) Download(ctx context.Context, key string, dest io.WriterAt, quiet bool) error {
+	dest, recordDownload := r.countDownload(r.GetStorageType(), dest)
+	defer recordDownload()
+
+	if !quiet {
+		log.Debugf("Downloading from IPFS node %s: %s", r.apiAddr, key)
+	}
+
+	resp, err := r.call(ctx, "cat", url.Values{"arg": {key}}, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to download from IPFS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var proxyReader io.Reader = resp.Body
+	if !quiet {
+		bar := r.newProgressBar(resp.ContentLength, "downloading")
+		pbReader := progressbar.NewReader(resp.Body, bar)
+		proxyReader = &pbReader
+	}
+
+	written, err := io.Copy(io.NewOffsetWriter(dest, 0), proxyReader)
+	if err != nil {
+		return fmt.Errorf("failed to read from IPFS: %w", err)
+	}
+
+	log.Debugf("Completed IPFS download for %s, wrote %d bytes", key, written)
+	return nil
+}
+
+// Delete unpins a CID so the node can garbage collect it
+func (r *IPFSObjectRepository) Delete(ctx context.Context, key string) error {
+	r.accounting.Record(r.GetStorageType(), RequestDelete, 0)
+	resp, err := r.call(ctx, "pin/rm", url.Values{"arg": {key}}, nil, "")
+	if err != nil {
+		if strings.Contains(err.Error(), "not pinned") {
+			return nil // Already unpinned
+		}
+		return fmt.Errorf("failed to unpin from IPFS: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// DeletePrefix is a no-op for IPFS because CIDs carry no path prefix
+// Callers remove content-addressed shards individually with Delete.
+func (r *IPFSObjectRepository) DeletePrefix(ctx context.Context, prefix string) error {
+	return nil
+}
+
+// ListKeys returns nothing for IPFS because CIDs cannot be attributed to an object by prefix
+func (r *IPFSObjectRepository) ListKeys(ctx context.Context, prefix string) ([]string, error) {
+	return nil, nil
+}
+
+// GetObjectSize returns the size of pinned content, or ErrObjectNotFound if the CID is not pinned
+// The pin check runs first so a missing CID is never fetched from the network.
+func (r *IPFSObjectRepository) GetObjectSize(ctx context.Context, key string) (int64, error) {
+	r.accounting.Record(r.GetStorageType(), RequestHead, 0)
+	resp, err := r.call(ctx, "pin/ls", url.Values{"arg": {key}, "type": {"recursive"}}, nil, "")
+	if err != nil {
+		if strings.Contains(err.Error(), "not pinned") {
+			return 0, zerrors.ErrObjectNotFound
+		}
+		return 0, fmt.Errorf("failed to check IPFS pin: %w", err)
+	}
+	resp.Body.Close()
+
+	resp, err = r.call(ctx, "files/stat", url.Values{"arg": {"/ipfs/" + key}}, nil, "")
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat IPFS object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var stat ipfsFilesStatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stat); err != nil {
+		return 0, fmt.Errorf("failed to decode IPFS stat response: %w", err)
+	}
+	return stat.Size, nil
+}
+
+// GetBucketName returns the IPFS node API address
+func (r *IPFSObjectRepository) GetBucketName() string {
+	return r.apiAddr
+}
+
+// GetStorageType returns the storage type
+func (r *IPFSObjectRepository) GetStorageType() string {
+	return "ipfs"
+}
+
+// call issues a POST to the node's RPC API and returns the response on HTTP 200
+// On failure the node's error message is returned and the body is closed.
+func (r *IPFSObjectRepository) call(ctx context.Context, command string, params url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	endpoint := fmt.Sprintf("http://%s/api/v0/%s?%s", r.apiAddr, command, params.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var rpcErr ipfsErrorResponse
+		if json.NewDecoder(resp.Body).Decode(&rpcErr) == nil && rpcErr.Message != "" {
+			return nil, fmt.Errorf("ipfs %s: %s", command, rpcErr.Message)
+		}
+		return nil, fmt.Errorf("ipfs %s: unexpected status %s", command, resp.Status)
+	}
+	return resp, nil
+}
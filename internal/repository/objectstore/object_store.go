@@ -1,6 +1,8 @@
 package objectstore
 
 import (
+	"net/http"
+
 	"cloud.google.com/go/storage"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
@@ -45,3 +47,11 @@ func NewGCSObjectRepository(client *storage.Client, bucketName string) GCSObject
 		bucketName: bucketName,
 	}
 }
+
+// NewIPFSObjectRepository creates a new IPFS object repository for the node API at apiAddr (host:port)
+func NewIPFSObjectRepository(client *http.Client, apiAddr string) IPFSObjectRepository {
+	return IPFSObjectRepository{
+		client:  client,
+		apiAddr: apiAddr,
+	}
+}
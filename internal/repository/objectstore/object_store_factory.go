@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 
 	"cloud.google.com/go/storage"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	log "github.com/sirupsen/logrus"
 )
 
 // ObjectRepository defines the interface for object storage operations
@@ -18,6 +21,10 @@ type ObjectRepository interface {
 	Download(ctx context.Context, key string, dest io.WriterAt, quiet bool) error
 	Delete(ctx context.Context, key string) error
 	DeletePrefix(ctx context.Context, prefix string) error
+	// GetObjectSize returns the stored size of an object, or errors.ErrObjectNotFound
+	GetObjectSize(ctx context.Context, key string) (int64, error)
+	// ListKeys returns the keys of all stored objects with the given prefix
+	ListKeys(ctx context.Context, prefix string) ([]string, error)
 	GetBucketName() string
 	GetStorageType() string
 }
@@ -28,21 +35,44 @@ type RepositoryType string
 const (
 	S3Type  RepositoryType = "s3"
 	GCSType RepositoryType = "gcs"
-	// Add more types as needed
+	// IPFSType stores objects on an IPFS node; the bucket name is the node's API address
+	IPFSType RepositoryType = "ipfs"
+	// Add more types with RegisterProvider (see providers.go)
 )
 
 // BucketConfig holds configuration for a storage bucket
 type BucketConfig struct {
 	Name   string
 	Type   RepositoryType
-	Region string // Required for S3, optional for GCS
+	Region string // S3 region to try first (defaults to the AWS region, corrected on first use), optional for GCS, unused for IPFS
 }
 
 // ObjectRepositoryFactory creates object repository instances
 type ObjectRepositoryFactory struct {
 	awsConfig   aws.Config
 	gcsClient   *storage.Client
+	mu          sync.Mutex            // Guards the caches, which repositories use after creation
 	s3Clients   map[string]*s3.Client // Cache S3 clients by region
+	repos       map[BucketConfig]ObjectRepository // Cache repositories by type, name and configured region
+	regions     map[string]string     // Cache detected S3 bucket regions by bucket name
+	progress    io.Writer             // Progress bar output for created repositories (nil uses stderr)
+	checksum    ChecksumAlgorithm     // Upload checksum for created repositories (empty leaves the SDK default)
+	accounting  *Accounting           // Receives the requests of created repositories (nil counts nothing)
+}
+
+// SetProgressOutput sets the progress bar output for repositories returned afterwards
+func (f *ObjectRepositoryFactory) SetProgressOutput(w io.Writer) {
+	f.progress = w
+}
+
+// SetChecksumAlgorithm sets the upload checksum of repositories returned afterwards
+func (f *ObjectRepositoryFactory) SetChecksumAlgorithm(algorithm ChecksumAlgorithm) {
+	f.checksum = algorithm
+}
+
+// SetAccounting makes repositories returned afterwards report their requests to accounting
+func (f *ObjectRepositoryFactory) SetAccounting(accounting *Accounting) {
+	f.accounting = accounting
 }
 
 // NewObjectRepositoryFactory creates a new factory
@@ -51,35 +81,101 @@ func NewObjectRepositoryFactory(awsConfig aws.Config, gcsClient *storage.Client)
 		awsConfig: awsConfig,
 		gcsClient: gcsClient,
 		s3Clients: make(map[string]*s3.Client),
+		repos:     make(map[BucketConfig]ObjectRepository),
+		regions:   make(map[string]string),
 	}
 }
 
-// CreateRepository creates a repository based on bucket configuration
+// CreateRepository returns the repository for a bucket configuration
+// Repositories are created once per type, bucket name and region and reused afterwards,
+// so repeated raw operations on a bucket share its client and connection pool.
 func (f *ObjectRepositoryFactory) CreateRepository(config BucketConfig) (ObjectRepository, error) {
-	switch config.Type {
-	case S3Type:
-		if config.Region == "" {
-			return nil, fmt.Errorf("region is required for S3 bucket: %s", config.Name)
-		}
-		client, err := f.getS3Client(config.Region)
+	f.mu.Lock()
+	repo, ok := f.repos[config]
+	f.mu.Unlock()
+
+	if !ok {
+		created, err := f.createRepository(config)
 		if err != nil {
 			return nil, err
 		}
-		repo := NewS3ObjectRepository(client, config.Name)
-		return &repo, nil
-	case GCSType:
-		if f.gcsClient == nil {
-			return nil, fmt.Errorf("GCS client not configured")
+
+		// Another caller may have created the same repository meanwhile; keep the first one
+		f.mu.Lock()
+		if repo, ok = f.repos[config]; !ok {
+			repo = created
+			f.repos[config] = repo
+		}
+		f.mu.Unlock()
+	}
+	if f.progress != nil {
+		if configurable, ok := repo.(ProgressConfigurable); ok {
+			configurable.SetProgressOutput(f.progress)
+		}
+	}
+	if f.checksum != "" {
+		if configurable, ok := repo.(ChecksumConfigurable); ok {
+			configurable.SetChecksumAlgorithm(f.checksum)
+		}
+	}
+	if f.accounting != nil {
+		if configurable, ok := repo.(AccountingConfigurable); ok {
+			configurable.SetAccounting(f.accounting)
 		}
-		repo := NewGCSObjectRepository(f.gcsClient, config.Name)
-		return &repo, nil
-	default:
+	}
+	return repo, nil
+}
+
+// createRepository builds the repository implementation for a bucket type
+func (f *ObjectRepositoryFactory) createRepository(config BucketConfig) (ObjectRepository, error) {
+	provider, ok := lookupProvider(config.Type)
+	if !ok {
 		return nil, fmt.Errorf("unsupported repository type: %s", config.Type)
 	}
+	return provider.Create(f, config)
+}
+
+// s3Region picks the region an S3 bucket's client starts in
+// A region detected earlier wins, then the configured region, then the default AWS region.
+// A wrong guess is corrected on first use by locateBucketRegion.
+func (f *ObjectRepositoryFactory) s3Region(config BucketConfig) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if region, ok := f.regions[config.Name]; ok {
+		return region
+	}
+	if config.Region != "" {
+		return config.Region
+	}
+	return f.awsConfig.Region
+}
+
+// locateBucketRegion detects a bucket's region with a HeadBucket request and returns a client for it
+// The detected region is cached, so each bucket is looked up at most once per factory.
+func (f *ObjectRepositoryFactory) locateBucketRegion(ctx context.Context, bucketName string, client *s3.Client) (*s3.Client, error) {
+	f.mu.Lock()
+	region, ok := f.regions[bucketName]
+	f.mu.Unlock()
+
+	if !ok {
+		detected, err := manager.GetBucketRegion(ctx, client, bucketName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect region of bucket %s: %w", bucketName, err)
+		}
+		region = detected
+
+		f.mu.Lock()
+		f.regions[bucketName] = region
+		f.mu.Unlock()
+		log.Infof("Bucket %s is in region %s, not %s", bucketName, region, client.Options().Region)
+	}
+	return f.getS3Client(region)
 }
 
 // getS3Client gets or creates an S3 client for the specified region
 func (f *ObjectRepositoryFactory) getS3Client(region string) (*s3.Client, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	if client, exists := f.s3Clients[region]; exists {
 		return client, nil
 	}
@@ -93,7 +189,7 @@ func (f *ObjectRepositoryFactory) getS3Client(region string) (*s3.Client, error)
 }
 
 // ParseBucketConfig parses bucket configuration from string
-// Formats: "s3://bucket-name", "gs://bucket-name", "s3:bucket-name", or "bucket-name" (defaults to S3)
+// Formats: "s3://bucket-name", "gs://bucket-name", "ipfs://host:port", "adls://account/filesystem/path", "s3:bucket-name", or "bucket-name" (defaults to S3)
 func ParseBucketConfig(bucketStr string) (BucketConfig, error) {
 	bucketStr = strings.TrimSpace(bucketStr)
 
@@ -111,19 +207,14 @@ func ParseBucketConfig(bucketStr string) (BucketConfig, error) {
 			return BucketConfig{}, fmt.Errorf("bucket name cannot be empty")
 		}
 
-		var repoType RepositoryType
-		switch scheme {
-		case "s3":
-			repoType = S3Type
-		case "gs":
-			repoType = GCSType
-		default:
+		provider, ok := providerForScheme(scheme)
+		if !ok {
 			return BucketConfig{}, fmt.Errorf("unsupported scheme: %s", scheme)
 		}
 
 		return BucketConfig{
 			Name: bucketName,
-			Type: repoType,
+			Type: provider.Type,
 		}, nil
 	}
 
@@ -0,0 +1,260 @@
+// Package objectstoretest provides an in-memory object repository for tests.
+//
+// MemoryObjectRepository implements the full objectstore.ObjectRepository interface over
+// a map, so the erasure-coding pipeline can be exercised without cloud credentials.
+// Importing the package also registers it as the "memory" provider, so memory://name
+// buckets can be created through ParseBucketConfig and the factory; repositories created
+// that way are shared by bucket name. The package is only meant to be imported by tests,
+// which keeps the provider out of the zstore binary.
+package objectstoretest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/placement"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+// MemoryType stores objects in process memory; the bucket name is any label
+const MemoryType objectstore.RepositoryType = "memory"
+
+var (
+	sharedMu     sync.Mutex
+	sharedMemory = make(map[string]*MemoryObjectRepository) // Repositories created through the factory, by bucket name
+)
+
+// init registers in-memory buckets as a provider
+func init() {
+	objectstore.RegisterProvider(objectstore.Provider{
+		Type:        MemoryType,
+		Scheme:      "memory",
+		Description: "In-memory buckets for tests (memory://name)",
+		Create: func(f *objectstore.ObjectRepositoryFactory, config objectstore.BucketConfig) (objectstore.ObjectRepository, error) {
+			return SharedMemoryRepository(config.Name), nil
+		},
+		Check: func(ctx context.Context, f *objectstore.ObjectRepositoryFactory) error {
+			return nil
+		},
+	})
+}
+
+// SharedMemoryRepository returns the repository the memory provider uses for a bucket name, creating it if needed
+func SharedMemoryRepository(name string) *MemoryObjectRepository {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	repo, ok := sharedMemory[name]
+	if !ok {
+		repo = NewMemoryObjectRepository(name)
+		sharedMemory[name] = repo
+	}
+	return repo
+}
+
+// MemoryObjectRepository implements objectstore.ObjectRepository in memory
+// It is safe for concurrent use. Operations can be made to fail with FailWith.
+type MemoryObjectRepository struct {
+	mu      sync.Mutex
+	name    string
+	objects map[string][]byte
+	failErr error // Returned by every operation while set
+
+	accounting *objectstore.Accounting // Receives every operation (nil counts nothing)
+}
+
+// NewMemoryObjectRepository creates an empty in-memory bucket
+func NewMemoryObjectRepository(name string) *MemoryObjectRepository {
+	return &MemoryObjectRepository{name: name, objects: make(map[string][]byte)}
+}
+
+// FailWith makes every following operation return err, until it is called with nil
+func (m *MemoryObjectRepository) FailWith(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failErr = err
+}
+
+// SetAccounting reports the repository's operations to accounting
+func (m *MemoryObjectRepository) SetAccounting(accounting *objectstore.Accounting) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accounting = accounting
+}
+
+// Upload stores the reader's content under key and returns "bucket/key"
+func (m *MemoryObjectRepository) Upload(ctx context.Context, key string, r io.Reader, quiet bool) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failErr != nil {
+		m.accounting.Record(m.GetStorageType(), objectstore.RequestUpload, 0)
+		return "", m.failErr
+	}
+	m.accounting.Record(m.GetStorageType(), objectstore.RequestUpload, int64(len(data)))
+	m.objects[key] = data
+	return m.name + "/" + key, nil
+}
+
+// Download writes the object stored under key to dest
+func (m *MemoryObjectRepository) Download(ctx context.Context, key string, dest io.WriterAt, quiet bool) error {
+	m.mu.Lock()
+	data, ok := m.objects[key]
+	failErr := m.failErr
+	accounting := m.accounting
+	m.mu.Unlock()
+	switch {
+	case failErr != nil:
+		accounting.Record(m.GetStorageType(), objectstore.RequestDownload, 0)
+		return failErr
+	case !ok:
+		accounting.Record(m.GetStorageType(), objectstore.RequestDownload, 0)
+		return fmt.Errorf("%w: %s/%s", errors.ErrObjectNotFound, m.name, key)
+	}
+	n, err := dest.WriteAt(data, 0)
+	accounting.Record(m.GetStorageType(), objectstore.RequestDownload, int64(n))
+	return err
+}
+
+// Delete removes the object stored under key; deleting a missing object succeeds
+func (m *MemoryObjectRepository) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accounting.Record(m.GetStorageType(), objectstore.RequestDelete, 0)
+	if m.failErr != nil {
+		return m.failErr
+	}
+	delete(m.objects, key)
+	return nil
+}
+
+// DeletePrefix removes every object whose key starts with prefix
+func (m *MemoryObjectRepository) DeletePrefix(ctx context.Context, prefix string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accounting.Record(m.GetStorageType(), objectstore.RequestDelete, 0)
+	if m.failErr != nil {
+		return m.failErr
+	}
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			delete(m.objects, key)
+		}
+	}
+	return nil
+}
+
+// GetObjectSize returns the size of the object stored under key
+func (m *MemoryObjectRepository) GetObjectSize(ctx context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accounting.Record(m.GetStorageType(), objectstore.RequestHead, 0)
+	if m.failErr != nil {
+		return 0, m.failErr
+	}
+	data, ok := m.objects[key]
+	if !ok {
+		return 0, errors.ErrObjectNotFound
+	}
+	return int64(len(data)), nil
+}
+
+// ListKeys returns the keys starting with prefix, sorted
+func (m *MemoryObjectRepository) ListKeys(ctx context.Context, prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accounting.Record(m.GetStorageType(), objectstore.RequestList, 0)
+	if m.failErr != nil {
+		return nil, m.failErr
+	}
+	var keys []string
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// GetUsage reports the objects and bytes stored, so the repository works with usage-based placement
+func (m *MemoryObjectRepository) GetUsage(ctx context.Context) (objectstore.BucketUsage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accounting.Record(m.GetStorageType(), objectstore.RequestList, 0)
+	if m.failErr != nil {
+		return objectstore.BucketUsage{}, m.failErr
+	}
+	usage := objectstore.BucketUsage{Objects: int64(len(m.objects))}
+	for _, data := range m.objects {
+		usage.Bytes += int64(len(data))
+	}
+	return usage, nil
+}
+
+func (m *MemoryObjectRepository) GetBucketName() string  { return m.name }
+func (m *MemoryObjectRepository) GetStorageType() string { return string(MemoryType) }
+
+// Object returns a copy of the object stored under key
+func (m *MemoryObjectRepository) Object(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	return append([]byte(nil), data...), ok
+}
+
+// PutObject stores data under key directly, e.g. to corrupt or replace a shard
+func (m *MemoryObjectRepository) PutObject(key string, data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = append([]byte(nil), data...)
+}
+
+// Objects returns a copy of every stored object, by key
+func (m *MemoryObjectRepository) Objects() map[string][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	objects := make(map[string][]byte, len(m.objects))
+	for key, data := range m.objects {
+		objects[key] = append([]byte(nil), data...)
+	}
+	return objects
+}
+
+// Clear removes every stored object, e.g. to simulate a lost bucket
+func (m *MemoryObjectRepository) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects = make(map[string][]byte)
+}
+
+// Len returns the number of stored objects
+func (m *MemoryObjectRepository) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.objects)
+}
+
+// NewRoundRobinPlacer builds a round-robin placer over bucketCount new in-memory buckets
+// The buckets are named "bucket-0", "bucket-1", ... and registered in order, so shard i
+// of an upload is placed in buckets[i % bucketCount].
+func NewRoundRobinPlacer(t testing.TB, bucketCount int) (*placement.RoundRobinPlacer, []*MemoryObjectRepository) {
+	t.Helper()
+	placer := placement.NewRoundRobinPlacer()
+	buckets := make([]*MemoryObjectRepository, bucketCount)
+	for i := range buckets {
+		buckets[i] = NewMemoryObjectRepository(fmt.Sprintf("bucket-%d", i))
+		if err := placer.RegisterBucket(buckets[i].name, buckets[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return placer, buckets
+}
@@ -0,0 +1,51 @@
+package objectstore
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// ProgressConfigurable is implemented by repositories whose progress bars can be redirected
+// Passing io.Discard disables progress output regardless of the per-call quiet flag.
+type ProgressConfigurable interface {
+	SetProgressOutput(w io.Writer)
+}
+
+// progressOutput is embedded in repositories to route progress bars to a configurable writer
+type progressOutput struct {
+	writer io.Writer // nil uses the progressbar default (stderr)
+}
+
+// SetProgressOutput sets where progress bars are written
+func (p *progressOutput) SetProgressOutput(w io.Writer) {
+	p.writer = w
+}
+
+// newProgressBar creates a byte progress bar on the configured writer
+// It matches progressbar.DefaultBytes apart from the output destination.
+func (p *progressOutput) newProgressBar(size int64, description string) *progressbar.ProgressBar {
+	if p.writer == nil {
+		return progressbar.DefaultBytes(size, description)
+	}
+
+	writer := p.writer
+	return progressbar.NewOptions64(
+		size,
+		progressbar.OptionSetDescription(description),
+		progressbar.OptionSetWriter(writer),
+		progressbar.OptionShowBytes(true),
+		progressbar.OptionShowTotalBytes(true),
+		progressbar.OptionSetWidth(10),
+		progressbar.OptionThrottle(65*time.Millisecond),
+		progressbar.OptionShowCount(),
+		progressbar.OptionOnCompletion(func() {
+			fmt.Fprint(writer, "\n")
+		}),
+		progressbar.OptionSpinnerType(14),
+		progressbar.OptionFullWidth(),
+		progressbar.OptionSetRenderBlankState(true),
+	)
+}
@@ -0,0 +1,92 @@
+// Package objectstore provides object storage repository implementations and factory.
+// This file implements the registry of storage providers the factory can create.
+//
+// Each backend registers a Provider from an init function in its own file, so adding a
+// backend never touches the factory: CreateRepository, ParseBucketConfig and the
+// providers command all read the registry.
+package objectstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Provider describes a storage backend the factory can create repositories for
+type Provider struct {
+	Type        RepositoryType
+	Scheme      string // URL scheme accepted by ParseBucketConfig (e.g. "gs" for GCS)
+	Description string
+	// Create builds a repository for one bucket
+	Create func(f *ObjectRepositoryFactory, config BucketConfig) (ObjectRepository, error)
+	// Check reports whether the environment is set up to use the provider (nil when ready)
+	Check func(ctx context.Context, f *ObjectRepositoryFactory) error
+}
+
+// ProviderStatus reports whether a provider can be used in the current environment
+type ProviderStatus struct {
+	Provider
+	Ready  bool
+	Detail string // Why the provider is not ready (empty when it is)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[RepositoryType]Provider)
+)
+
+// RegisterProvider adds a provider to the registry, replacing any provider of the same type
+func RegisterProvider(provider Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[provider.Type] = provider
+}
+
+// Providers returns the registered providers ordered by type
+func Providers() []Provider {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	list := make([]Provider, 0, len(providers))
+	for _, provider := range providers {
+		list = append(list, provider)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Type < list[j].Type })
+	return list
+}
+
+// lookupProvider returns the registered provider of a type
+func lookupProvider(repoType RepositoryType) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	provider, ok := providers[repoType]
+	return provider, ok
+}
+
+// providerForScheme returns the registered provider accepting a URL scheme
+func providerForScheme(scheme string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	for _, provider := range providers {
+		if provider.Scheme == scheme {
+			return provider, true
+		}
+	}
+	return Provider{}, false
+}
+
+// ProviderStatuses checks every registered provider against the factory's clients and credentials
+func (f *ObjectRepositoryFactory) ProviderStatuses(ctx context.Context) []ProviderStatus {
+	var statuses []ProviderStatus
+	for _, provider := range Providers() {
+		status := ProviderStatus{Provider: provider, Ready: true}
+		if provider.Check != nil {
+			if err := provider.Check(ctx, f); err != nil {
+				status.Ready = false
+				status.Detail = err.Error()
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
@@ -0,0 +1,53 @@
+package objectstore
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RetentionMode is the object lock mode applied to uploaded objects
+type RetentionMode string
+
+const (
+	// RetentionGovernance can be lifted by users with special permissions (S3 GOVERNANCE, GCS Unlocked)
+	RetentionGovernance RetentionMode = "GOVERNANCE"
+	// RetentionCompliance cannot be shortened or removed by anyone (S3 COMPLIANCE, GCS Locked)
+	RetentionCompliance RetentionMode = "COMPLIANCE"
+)
+
+// Retention is a write-once-read-many lock applied to every object a repository uploads
+// The zero value disables retention.
+type Retention struct {
+	Mode        RetentionMode
+	RetainUntil time.Time
+}
+
+// Enabled reports whether a retention lock is configured
+func (r Retention) Enabled() bool {
+	return r.Mode != ""
+}
+
+// RetentionConfigurable is implemented by repositories that can lock uploaded objects
+// (S3 Object Lock, GCS object retention). The bucket itself must have object lock or
+// object retention enabled, otherwise uploads are rejected by the provider.
+type RetentionConfigurable interface {
+	SetRetention(retention Retention)
+}
+
+// ParseRetentionMode parses "governance" or "compliance" (case-insensitive)
+func ParseRetentionMode(value string) (RetentionMode, error) {
+	switch mode := RetentionMode(strings.ToUpper(strings.TrimSpace(value))); mode {
+	case RetentionGovernance, RetentionCompliance:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown retention mode %q (use governance or compliance)", value)
+}
+
+// gcsRetentionMode maps a retention mode to its GCS object retention mode
+func gcsRetentionMode(mode RetentionMode) string {
+	if mode == RetentionCompliance {
+		return "Locked"
+	}
+	return "Unlocked"
+}
@@ -2,18 +2,178 @@ package objectstore
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go/middleware"
 	"github.com/schollz/progressbar/v3"
+	log "github.com/sirupsen/logrus"
+	zerrors "github.com/zzenonn/zstore/internal/errors"
 )
 
+// init makes s3 buckets and s3:// URLs available to the factory
+func init() {
+	RegisterProvider(Provider{
+		Type:        S3Type,
+		Scheme:      "s3",
+		Description: "Amazon S3",
+		Create: func(f *ObjectRepositoryFactory, config BucketConfig) (ObjectRepository, error) {
+			region := f.s3Region(config)
+			if region == "" {
+				return nil, fmt.Errorf("region is required for S3 bucket: %s", config.Name)
+			}
+			client, err := f.getS3Client(region)
+			if err != nil {
+				return nil, err
+			}
+			repo := NewS3ObjectRepository(client, config.Name)
+			repo.SetRegionLocator(f.locateBucketRegion)
+			return &repo, nil
+		},
+		Check: func(ctx context.Context, f *ObjectRepositoryFactory) error {
+			if f.awsConfig.Credentials == nil {
+				return fmt.Errorf("no AWS credentials found")
+			}
+			if _, err := f.awsConfig.Credentials.Retrieve(ctx); err != nil {
+				return fmt.Errorf("AWS credentials unavailable: %w", err)
+			}
+			return nil
+		},
+	})
+}
+
+const (
+	// s3DeleteConcurrency bounds the number of parallel DeleteObjects batches in DeletePrefix
+	s3DeleteConcurrency = 4
+	// s3DeleteAttempts is how many DeleteObjects calls a key failing with a transient error gets
+	s3DeleteAttempts = 3
+	// s3DeleteRetryDelay is the wait before the first retry of failed keys, doubled for each further one
+	s3DeleteRetryDelay = 100 * time.Millisecond
+)
+
+// s3TransientDeleteErrors are the per-key DeleteObjects error codes worth retrying
+var s3TransientDeleteErrors = map[string]bool{
+	"InternalError":      true,
+	"ServiceUnavailable": true,
+	"SlowDown":           true,
+	"RequestTimeout":     true,
+}
+
 // S3ObjectRepository manages S3 interactions for objects.
 type S3ObjectRepository struct {
-	client     *s3.Client
-	bucketName string
+	client      *s3.Client
+	bucketName  string
+	customerKey []byte            // SSE-C key; nil uses the bucket's default encryption
+	retention   Retention         // Object Lock applied to uploads (zero value disables)
+	checksum    ChecksumAlgorithm // Checksum S3 verifies on upload (empty leaves the SDK default)
+	region      *s3BucketRegion   // Moves requests to the bucket's actual region after a redirect (nil disables)
+
+	progressOutput
+	requestAccounting
+}
+
+// BucketRegionLocator returns a client for the region a bucket actually lives in
+// client is the client whose request was rejected for being sent to the wrong region.
+type BucketRegionLocator func(ctx context.Context, bucketName string, client *s3.Client) (*s3.Client, error)
+
+// s3BucketRegion tracks the client to use once a bucket's region has been corrected
+type s3BucketRegion struct {
+	mu        sync.RWMutex
+	corrected *s3.Client // Client for the detected region; nil until a redirect was handled
+	locate    BucketRegionLocator
+}
+
+// SetRegionLocator enables region correction: requests rejected because the bucket lives
+// in another region are retried once with a client for that region, which is then used
+// for every later request.
+func (r *S3ObjectRepository) SetRegionLocator(locate BucketRegionLocator) {
+	r.region = &s3BucketRegion{locate: locate}
+}
+
+// s3Client returns the client for the bucket's region
+func (r *S3ObjectRepository) s3Client() *s3.Client {
+	if r.region == nil {
+		return r.client
+	}
+	r.region.mu.RLock()
+	defer r.region.mu.RUnlock()
+	if r.region.corrected != nil {
+		return r.region.corrected
+	}
+	return r.client
+}
+
+// inBucketRegion runs op and, if S3 reports that the bucket lives in another region,
+// detects that region and runs op again with a client for it. retryable is false when op
+// consumed input it cannot replay; the wrong-region error is then returned as is.
+// If the region cannot be detected, the original error is returned and the configured
+// region stays in use.
+func (r *S3ObjectRepository) inBucketRegion(ctx context.Context, retryable bool, op func(client *s3.Client) error) error {
+	client := r.s3Client()
+	err := op(client)
+	if err == nil || r.region == nil || !retryable || !isWrongRegion(err) {
+		return err
+	}
+
+	corrected, locateErr := r.region.locate(ctx, r.bucketName, client)
+	if locateErr != nil {
+		log.Warnf("Bucket %s is not in its configured region and its region could not be detected: %v", r.bucketName, locateErr)
+		return err
+	}
+	r.region.mu.Lock()
+	r.region.corrected = corrected
+	r.region.mu.Unlock()
+	log.Debugf("Bucket %s moved to region %s", r.bucketName, corrected.Options().Region)
+	return op(corrected)
+}
+
+// isWrongRegion reports whether S3 rejected a request because the bucket is in another region
+func isWrongRegion(err error) bool {
+	var apiErr interface{ ErrorCode() string }
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "PermanentRedirect", "AuthorizationHeaderMalformed", "IllegalLocationConstraintException":
+			return true
+		}
+	}
+	// HEAD responses carry no error body, so only the status code identifies the redirect
+	var statusErr interface{ HTTPStatusCode() int }
+	return errors.As(err, &statusErr) && statusErr.HTTPStatusCode() == http.StatusMovedPermanently
+}
+
+// SetCustomerKey enables SSE-C with the given 256-bit key for all subsequent requests
+func (r *S3ObjectRepository) SetCustomerKey(key []byte) {
+	r.customerKey = key
+}
+
+// SetRetention applies an S3 Object Lock retention to every object uploaded afterwards
+func (r *S3ObjectRepository) SetRetention(retention Retention) {
+	r.retention = retention
+}
+
+// SetChecksumAlgorithm makes S3 verify a checksum of every object uploaded afterwards
+// The SDK sends the checksum with the request (as a trailer when the body is streamed), so
+// corrupted uploads are rejected without an extra round trip.
+func (r *S3ObjectRepository) SetChecksumAlgorithm(algorithm ChecksumAlgorithm) {
+	r.checksum = algorithm
+}
+
+// applySSECustomerKey sets the SSE-C fields on a request when a customer key is configured
+func (r *S3ObjectRepository) applySSECustomerKey(algorithm, key, keyMD5 **string) {
+	if r.customerKey == nil {
+		return
+	}
+	a, k, m := sseCustomerHeaders(r.customerKey)
+	*algorithm, *key, *keyMD5 = aws.String(a), aws.String(k), aws.String(m)
 }
 
 // GetBucketName returns the bucket name.
@@ -28,39 +188,76 @@ func (r *S3ObjectRepository) GetStorageType() string {
 
 // Upload uploads an object file to S3
 func (r *S3ObjectRepository) Upload(ctx context.Context, key string, reader io.Reader, quiet bool) (string, error) {
-	uploader := manager.NewUploader(r.client)
-	
+	reader, recordUpload := r.countUpload(r.GetStorageType(), reader)
+	size := readerSize(reader)
 	seeker, ok := reader.(io.Seeker)
-	var size int64 = -1
+	var start int64 // Body position to rewind to before retrying in another region
 	if ok {
 		if current, err := seeker.Seek(0, io.SeekCurrent); err == nil {
-			if end, err := seeker.Seek(0, io.SeekEnd); err == nil {
-				size = end - current
-				seeker.Seek(current, io.SeekStart)
-			}
+			start = current
 		}
 	}
 
-	var proxyReader io.Reader = reader
-	if !quiet {
-		bar := progressbar.DefaultBytes(size, "uploading")
-		pbReader := progressbar.NewReader(reader, bar)
-		proxyReader = &pbReader
-	}
+	// The body can only be replayed in another region if it can be rewound
+	attempt := 0
+	var calls atomic.Int64 // S3 calls the uploader made: one PutObject, or each step of a multipart upload
+	err := r.inBucketRegion(ctx, ok, func(client *s3.Client) error {
+		if attempt++; attempt > 1 {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return err
+			}
+		}
 
-	input := &s3.PutObjectInput{
-		Bucket: aws.String(r.bucketName),
-		Key:    aws.String(key),
-		Body:   proxyReader,
-	}
+		var proxyReader io.Reader = reader
+		if !quiet {
+			bar := r.newProgressBar(size, "uploading")
+			pbReader := progressbar.NewReader(reader, bar)
+			proxyReader = &pbReader
+		}
 
-	_, err := uploader.Upload(ctx, input)
+		input := &s3.PutObjectInput{
+			Bucket: aws.String(r.bucketName),
+			Key:    aws.String(key),
+			Body:   proxyReader,
+		}
+		r.applySSECustomerKey(&input.SSECustomerAlgorithm, &input.SSECustomerKey, &input.SSECustomerKeyMD5)
+		if r.retention.Enabled() {
+			input.ObjectLockMode = types.ObjectLockMode(r.retention.Mode)
+			input.ObjectLockRetainUntilDate = aws.Time(r.retention.RetainUntil)
+		}
+		if r.checksum != "" {
+			input.ChecksumAlgorithm = types.ChecksumAlgorithm(r.checksum)
+		}
+
+		_, err := manager.NewUploader(client, func(u *manager.Uploader) {
+			u.PartSize = uploadPartSize(size)
+			u.ClientOptions = append(u.ClientOptions, countS3Calls(&calls))
+		}).Upload(ctx, input)
+		return err
+	})
+	recordUpload(err)
+	// recordUpload counted the transfer as one request; count the other calls of a multipart upload
+	r.recordCalls(r.GetStorageType(), RequestUpload, calls.Load()-1)
 	if err != nil {
 		return "", err
 	}
 	return r.bucketName + "/" + key, nil
 }
 
+// countS3Calls returns a client option that adds one to calls for every S3 operation the client starts
+// SDK retries of an operation are not counted again.
+func countS3Calls(calls *atomic.Int64) func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("ZstoreCountCalls",
+				func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+					calls.Add(1)
+					return next.HandleInitialize(ctx, in)
+				}), middleware.After)
+		})
+	}
+}
+
 // progressWriterAt wraps a WriterAt with a progress bar
 type progressWriterAt struct {
 	w   io.WriterAt
@@ -80,38 +277,71 @@ func (pw *progressWriterAt) WriteAt(p []byte, off int64) (n int, err error) {
 // pre-allocates entire file size in memory which will fail for very large objects.
 // Consider: size limit check, temp file fallback, or hybrid approach (small files in memory, large files to temp file)
 func (r *S3ObjectRepository) Download(ctx context.Context, key string, dest io.WriterAt, quiet bool) error {
-	downloader := manager.NewDownloader(r.client)
-	
+	dest, recordDownload := r.countDownload(r.GetStorageType(), dest)
+	defer recordDownload()
+
 	// Add progress bar if not quiet
 	var writer io.WriterAt = dest
 	if !quiet {
-		headResult, err := r.client.HeadObject(ctx, &s3.HeadObjectInput{
-			Bucket: aws.String(r.bucketName),
-			Key:    aws.String(key),
-		})
-		if err == nil && headResult.ContentLength != nil {
-			bar := progressbar.DefaultBytes(*headResult.ContentLength, "downloading")
+		if size, err := r.GetObjectSize(ctx, key); err == nil {
+			bar := r.newProgressBar(size, "downloading")
 			writer = &progressWriterAt{w: dest, bar: bar}
 		}
 	}
-	
-	_, err := downloader.Download(ctx, writer, &s3.GetObjectInput{
+
+	input := &s3.GetObjectInput{
 		Bucket: aws.String(r.bucketName),
 		Key:    aws.String(key),
+	}
+	r.applySSECustomerKey(&input.SSECustomerAlgorithm, &input.SSECustomerKey, &input.SSECustomerKeyMD5)
+	return r.inBucketRegion(ctx, true, func(client *s3.Client) error {
+		_, err := manager.NewDownloader(client).Download(ctx, writer, input)
+		return err
 	})
-	return err
 }
 
 // Delete removes an object file from S3
 func (r *S3ObjectRepository) Delete(ctx context.Context, key string) error {
-	_, err := r.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+	r.accounting.Record(r.GetStorageType(), RequestDelete, 0)
+	return r.inBucketRegion(ctx, true, func(client *s3.Client) error {
+		_, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(r.bucketName),
+			Key:    aws.String(key),
+		})
+		return err
+	})
+}
+
+// GetObjectSize returns the size of an object using a HEAD request
+func (r *S3ObjectRepository) GetObjectSize(ctx context.Context, key string) (int64, error) {
+	r.accounting.Record(r.GetStorageType(), RequestHead, 0)
+	input := &s3.HeadObjectInput{
 		Bucket: aws.String(r.bucketName),
 		Key:    aws.String(key),
+	}
+	r.applySSECustomerKey(&input.SSECustomerAlgorithm, &input.SSECustomerKey, &input.SSECustomerKeyMD5)
+	var result *s3.HeadObjectOutput
+	err := r.inBucketRegion(ctx, true, func(client *s3.Client) error {
+		var err error
+		result, err = client.HeadObject(ctx, input)
+		return err
 	})
-	return err
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return 0, zerrors.ErrObjectNotFound
+		}
+		return 0, err
+	}
+	return aws.ToInt64(result.ContentLength), nil
 }
 
 // DeletePrefix removes all objects with the given prefix from S3
+// Objects are removed with batched DeleteObjects calls, one per listing page of up to
+// 1000 keys. Batches run concurrently with the listing of the next pages (up to
+// s3DeleteConcurrency at a time), keys the service failed to delete with a transient
+// error are retried, and the remaining per-key failures are aggregated instead of
+// aborting early.
 func (r *S3ObjectRepository) DeletePrefix(ctx context.Context, prefix string) error {
 	// List objects with the prefix
 	listInput := &s3.ListObjectsV2Input{
@@ -119,17 +349,45 @@ func (r *S3ObjectRepository) DeletePrefix(ctx context.Context, prefix string) er
 		Prefix: aws.String(prefix),
 	}
 
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	semaphore := make(chan struct{}, s3DeleteConcurrency) // Limits concurrent batches
+
 	for {
-		result, err := r.client.ListObjectsV2(ctx, listInput)
-		if err != nil {
+		var result *s3.ListObjectsV2Output
+		err := r.inBucketRegion(ctx, true, func(client *s3.Client) error {
+			r.accounting.Record(r.GetStorageType(), RequestList, 0)
+			var err error
+			result, err = client.ListObjectsV2(ctx, listInput)
 			return err
+		})
+		if err != nil {
+			// Stop listing but let started batches finish
+			mu.Lock()
+			errs = append(errs, fmt.Errorf("failed to list objects with prefix %s: %w", prefix, err))
+			mu.Unlock()
+			break
 		}
 
-		// Delete objects using existing Delete function
-		for _, obj := range result.Contents {
-			if err := r.Delete(ctx, *obj.Key); err != nil {
-				return err
+		// Delete the whole page in a single batch request
+		if len(result.Contents) > 0 {
+			objects := make([]types.ObjectIdentifier, 0, len(result.Contents))
+			for _, obj := range result.Contents {
+				objects = append(objects, types.ObjectIdentifier{Key: obj.Key})
 			}
+
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+				if err := r.deleteBatch(ctx, objects); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}()
 		}
 
 		// Check if there are more objects to delete
@@ -138,6 +396,108 @@ func (r *S3ObjectRepository) DeletePrefix(ctx context.Context, prefix string) er
 		}
 		listInput.ContinuationToken = result.NextContinuationToken
 	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// deleteBatch deletes up to 1000 objects with one DeleteObjects call
+// Keys reported in the response's Errors with a transient code are sent again, up to
+// s3DeleteAttempts calls in all; the other failures are returned joined.
+func (r *S3ObjectRepository) deleteBatch(ctx context.Context, objects []types.ObjectIdentifier) error {
+	var errs []error
+	delay := s3DeleteRetryDelay
+	for attempt := 1; len(objects) > 0; attempt++ {
+		var output *s3.DeleteObjectsOutput
+		err := r.inBucketRegion(ctx, true, func(client *s3.Client) error {
+			r.accounting.Record(r.GetStorageType(), RequestDelete, 0)
+			var err error
+			output, err = client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+				Bucket: aws.String(r.bucketName),
+				Delete: &types.Delete{
+					Objects: objects,
+					Quiet:   aws.Bool(true),
+				},
+			})
+			return err
+		})
+		if err != nil {
+			return errors.Join(append(errs, fmt.Errorf("failed to delete %d objects: %w", len(objects), err))...)
+		}
 
-	return nil
+		var retry []types.ObjectIdentifier
+		for _, deleteErr := range output.Errors {
+			if attempt < s3DeleteAttempts && s3TransientDeleteErrors[aws.ToString(deleteErr.Code)] {
+				retry = append(retry, types.ObjectIdentifier{Key: deleteErr.Key, VersionId: deleteErr.VersionId})
+				continue
+			}
+			errs = append(errs, fmt.Errorf("failed to delete %s: %s", aws.ToString(deleteErr.Key), aws.ToString(deleteErr.Message)))
+		}
+		if objects = retry; len(objects) > 0 {
+			log.Debugf("Retrying delete of %d objects in S3 bucket %s", len(objects), r.bucketName)
+			select {
+			case <-ctx.Done():
+				return errors.Join(append(errs, ctx.Err())...)
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// GetUsage counts the objects in the bucket and their total size
+func (r *S3ObjectRepository) GetUsage(ctx context.Context) (BucketUsage, error) {
+	var usage BucketUsage
+	err := r.inBucketRegion(ctx, true, func(client *s3.Client) error {
+		paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+			Bucket: aws.String(r.bucketName),
+		})
+
+		usage = BucketUsage{}
+		for paginator.HasMorePages() {
+			r.accounting.Record(r.GetStorageType(), RequestList, 0)
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return err
+			}
+			for _, obj := range page.Contents {
+				usage.Objects++
+				usage.Bytes += aws.ToInt64(obj.Size)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return BucketUsage{}, fmt.Errorf("failed to list objects in %s: %w", r.bucketName, err)
+	}
+	return usage, nil
+}
+
+// ListKeys returns the keys of all objects with the given prefix
+func (r *S3ObjectRepository) ListKeys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := r.inBucketRegion(ctx, true, func(client *s3.Client) error {
+		paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+			Bucket: aws.String(r.bucketName),
+			Prefix: aws.String(prefix),
+		})
+
+		keys = nil
+		for paginator.HasMorePages() {
+			r.accounting.Record(r.GetStorageType(), RequestList, 0)
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return err
+			}
+			for _, obj := range page.Contents {
+				keys = append(keys, aws.ToString(obj.Key))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects with prefix %s: %w", prefix, err)
+	}
+	return keys, nil
 }
@@ -0,0 +1,57 @@
+package objectstore
+
+import (
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+)
+
+// SizeHinter is implemented by readers that know how many bytes they will return
+type SizeHinter interface {
+	SizeHint() int64
+}
+
+// sizeHintReader is a reader carrying its caller-provided length
+type sizeHintReader struct {
+	io.Reader
+	size int64
+}
+
+func (r *sizeHintReader) SizeHint() int64 {
+	return r.size
+}
+
+// WithSizeHint wraps a reader that cannot seek, such as a pipe or a network stream, with its length
+// Uploads use the hint for progress bar totals and S3 multipart part sizes. A wrong hint
+// only affects those; the uploaded bytes are always what the reader returns.
+func WithSizeHint(r io.Reader, size int64) io.Reader {
+	return &sizeHintReader{Reader: r, size: size}
+}
+
+// readerSize returns the bytes left in a reader, or -1 if unknown
+// Seekable readers are measured and their offset restored; others report their size hint.
+func readerSize(r io.Reader) int64 {
+	if seeker, ok := r.(io.Seeker); ok {
+		if current, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			if end, err := seeker.Seek(0, io.SeekEnd); err == nil {
+				seeker.Seek(current, io.SeekStart)
+				return end - current
+			}
+		}
+		return -1
+	}
+	if hinter, ok := r.(SizeHinter); ok && hinter.SizeHint() >= 0 {
+		return hinter.SizeHint()
+	}
+	return -1
+}
+
+// uploadPartSize returns the S3 multipart part size for an upload of size bytes
+// The uploader only sizes parts itself for seekable bodies, and a progress bar hides the
+// seeker, so parts are sized here to keep large uploads within the part limit.
+func uploadPartSize(size int64) int64 {
+	if size < 0 {
+		return manager.DefaultUploadPartSize
+	}
+	return max(size/int64(manager.MaxUploadParts)+1, manager.DefaultUploadPartSize)
+}
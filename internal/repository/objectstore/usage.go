@@ -0,0 +1,15 @@
+package objectstore
+
+import "context"
+
+// BucketUsage is the amount of data stored in a bucket
+type BucketUsage struct {
+	Objects int64
+	Bytes   int64
+}
+
+// UsageReporter is implemented by repositories that can report how much their bucket stores
+// Reporting lists the whole bucket, so callers should cache the result.
+type UsageReporter interface {
+	GetUsage(ctx context.Context) (BucketUsage, error)
+}
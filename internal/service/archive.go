@@ -0,0 +1,275 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements archives: many files stored as one erasure-coded object.
+//
+// ArchiveDir writes a directory as a tar stream, compresses it with zstd and uploads the
+// result as a single object, so a directory of small files pays the shard, request and
+// metadata overhead once instead of per file. The tar writer is piped straight into the
+// upload. The archive's member listing is stored in the object's metadata, so stat can
+// show what an archive holds without downloading it. ExtractArchive streams the object
+// back through the decompressor and recreates the files under a local directory;
+// ExtractArchiveMember stops the stream once a single requested file has been copied out.
+//
+// Only directories and regular files are archived; symlinks and special files are skipped.
+package service
+
+import (
+	"archive/tar"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+// ArchiveDir stores every file under dir as one zstd-compressed tar archive at key
+// The returned members are the files written to the archive, in walk order.
+func (s *FileService) ArchiveDir(ctx context.Context, dir, key string, quiet bool, dataShards, parityShards, concurrency int) ([]domain.ArchiveMember, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	var members []domain.ArchiveMember
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeArchive(pw, dir, &members))
+	}()
+	// The upload reads the whole stream before storing metadata, so the listing is complete by then
+	attributes := fileAttributes{
+		name:    filepath.Base(key),
+		members: func() []domain.ArchiveMember { return members },
+	}
+	err = s.uploadFile(ctx, key, attributes, pr, quiet, dataShards, parityShards, concurrency)
+	pr.CloseWithError(err) // Stops the writer if the upload gave up before reading everything
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// writeArchive writes dir to w as a zstd-compressed tar stream, recording each file in members
+func writeArchive(w io.Writer, dir string, members *[]domain.ArchiveMember) error {
+	encoder, err := zstd.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(encoder)
+
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		relative, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(relative)
+		if !entry.IsDir() && !entry.Type().IsRegular() {
+			log.Warnf("Skipping %s: not a regular file", name)
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if entry.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if _, err := io.Copy(tw, file); err != nil {
+			return fmt.Errorf("failed to archive %s: %w", name, err)
+		}
+		*members = append(*members, domain.ArchiveMember{Name: name, Size: info.Size(), ModTime: info.ModTime().UTC()})
+		return nil
+	})
+	if err != nil {
+		encoder.Close()
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// ExtractArchive downloads an archive created by ArchiveDir and recreates its files under destDir
+// Files are written as the stream arrives, so the archive is never held in memory or on
+// disk in full. Entries whose paths would land outside destDir are rejected. The returned
+// names are the files extracted, in archive order.
+func (s *FileService) ExtractArchive(ctx context.Context, key, destDir string, verifyIntegrity bool) ([]string, error) {
+	key = s.NormalizeKey(key)
+	var extracted []string
+	err := s.readArchive(ctx, key, verifyIntegrity, func(r io.Reader) (bool, error) {
+		var err error
+		extracted, err = extractArchive(r, destDir)
+		return false, err
+	})
+	return extracted, err
+}
+
+// ExtractArchiveMember writes the content of one file in an archive to dest
+// The archive is streamed through the tar reader until the member is found, and the download
+// stops once it has been copied, so nothing else in the archive is written to disk. Because
+// the rest of the archive is not read, the whole-file hash is not checked; verifyIntegrity
+// still checks each shard that was downloaded. A missing member is reported as
+// errors.ErrArchiveMemberNotFound.
+func (s *FileService) ExtractArchiveMember(ctx context.Context, key, member string, dest io.Writer, verifyIntegrity bool) error {
+	key = s.NormalizeKey(key)
+	member = strings.TrimPrefix(path.Clean(filepath.ToSlash(member)), "/")
+	return s.readArchive(ctx, key, verifyIntegrity, func(r io.Reader) (bool, error) {
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return false, err
+		}
+		defer decoder.Close()
+
+		tr := tar.NewReader(decoder)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				return false, fmt.Errorf("%w: %s", errors.ErrArchiveMemberNotFound, member)
+			}
+			if err != nil {
+				return false, fmt.Errorf("not a readable zstd-compressed tar archive: %w", err)
+			}
+			if header.Typeflag != tar.TypeReg || strings.TrimPrefix(path.Clean(header.Name), "./") != member {
+				continue
+			}
+			if _, err := io.Copy(dest, tr); err != nil {
+				return false, fmt.Errorf("failed to extract %s: %w", member, err)
+			}
+			return true, nil
+		}
+	})
+}
+
+// readArchive streams an object into read, which reports whether it stopped before the end
+// An archive read to its end is drained so a failed integrity check at the end of the
+// stream is still reported; one read partially has its download cancelled instead.
+// The read is audited as one download whose outcome is the returned error.
+func (s *FileService) readArchive(ctx context.Context, key string, verifyIntegrity bool, read func(r io.Reader) (bool, error)) (err error) {
+	start := time.Now()
+	var size int64
+	defer func() { s.audit(ctx, domain.AuditDownload, key, size, start, err) }()
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	streamDone := make(chan error, 1)
+	go func() {
+		var err error
+		size, err = s.streamFile(streamCtx, key, pw, verifyIntegrity)
+		pw.CloseWithError(err)
+		streamDone <- err
+	}()
+
+	stopped, err := read(pr)
+	if err == nil && !stopped {
+		_, err = io.Copy(io.Discard, pr)
+	}
+	pr.CloseWithError(err)
+	cancel()
+
+	// A download failure explains any read error it caused; one caused by stopping early does not count
+	streamErr := <-streamDone
+	if streamErr != nil && !stderrors.Is(streamErr, io.ErrClosedPipe) && (ctx.Err() != nil || !stderrors.Is(streamErr, context.Canceled)) {
+		return streamErr
+	}
+	return err
+}
+
+// extractArchive unpacks a zstd-compressed tar stream into destDir
+func extractArchive(r io.Reader, destDir string) ([]string, error) {
+	decoder, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+
+	var extracted []string
+	tr := tar.NewReader(decoder)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return extracted, nil
+		}
+		if err != nil {
+			return extracted, fmt.Errorf("not a readable zstd-compressed tar archive: %w", err)
+		}
+		name := filepath.FromSlash(header.Name)
+		if !filepath.IsLocal(name) {
+			return extracted, fmt.Errorf("archive entry %q is outside the destination directory", header.Name)
+		}
+		path := filepath.Join(destDir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return extracted, err
+			}
+		case tar.TypeReg:
+			if err := extractFile(tr, path, header); err != nil {
+				return extracted, err
+			}
+			extracted = append(extracted, header.Name)
+		default:
+			log.Warnf("Skipping archive entry %s: not a regular file", header.Name)
+		}
+	}
+}
+
+// extractFile writes one archived file and restores its permission bits and modification time
+func extractFile(r io.Reader, path string, header *tar.Header) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to extract %s: %w", header.Name, err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, header.FileInfo().Mode()&fs.ModePerm); err != nil {
+		return err
+	}
+	return os.Chtimes(path, header.ModTime, header.ModTime)
+}
@@ -0,0 +1,98 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements audit logging of uploads, downloads and deletes.
+//
+// Audit records say who did what to which object, how many bytes it involved, how long
+// it took and whether it worked. They are separate from the debug log: every audited
+// operation produces exactly one record, failures included, and a record is written
+// before the operation returns. The record is written with a context that ignores
+// cancellation, so an operation aborted by its caller is still recorded. A sink that
+// fails to write is reported in the error log but does not fail the operation. Purges from
+// the trash are recorded as their own operation; streamed and range reads count as downloads.
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/domain"
+)
+
+// AuditLogger records operations for compliance
+type AuditLogger interface {
+	LogOperation(ctx context.Context, op domain.AuditOperation, key, user string, result domain.AuditResult) error
+}
+
+// auditUserKey is the context key of the user operations are attributed to
+type auditUserKey struct{}
+
+// WithAuditUser attributes the operations run with the returned context to user
+// It overrides the default set with SetAuditLogger, e.g. for the caller of a gateway request.
+func WithAuditUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, auditUserKey{}, user)
+}
+
+// SetAuditLogger records every upload, download and delete with logger (nil disables auditing)
+// Operations are attributed to defaultUser unless their context names a user (see WithAuditUser).
+func (s *FileService) SetAuditLogger(logger AuditLogger, defaultUser string) {
+	s.auditLogger = logger
+	s.auditUser = defaultUser
+}
+
+// audit records an operation that started at start and ended with err
+func (s *FileService) audit(ctx context.Context, op domain.AuditOperation, key string, bytes int64, start time.Time, err error) {
+	if s.auditLogger == nil {
+		return
+	}
+	user := s.auditUser
+	if contextUser, ok := ctx.Value(auditUserKey{}).(string); ok {
+		user = contextUser
+	}
+
+	result := domain.AuditResult{Bytes: bytes, Duration: time.Since(start), Err: err}
+	if logErr := s.auditLogger.LogOperation(context.WithoutCancel(ctx), op, key, user, result); logErr != nil {
+		log.Errorf("Failed to write audit record for %s of %s: %v", op, key, logErr)
+	}
+}
+
+// FileAuditLogger appends audit records to a file as JSON lines
+// Each record is synced to disk before LogOperation returns.
+type FileAuditLogger struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileAuditLogger opens (or creates) an audit log file for appending
+func NewFileAuditLogger(path string) (*FileAuditLogger, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log %s: %w", path, err)
+	}
+	return &FileAuditLogger{file: file}, nil
+}
+
+// LogOperation appends one record and syncs the file
+func (l *FileAuditLogger) LogOperation(ctx context.Context, op domain.AuditOperation, key, user string, result domain.AuditResult) error {
+	line, err := json.Marshal(domain.NewAuditRecord(op, key, user, result))
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// Close closes the audit log file
+func (l *FileAuditLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,239 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements a throughput benchmark against the configured buckets.
+//
+// Bench uploads and downloads random data through the normal erasure-coded paths and
+// records whole-object latencies alongside every shard transfer, so slow results can be
+// attributed either to zstore itself or to a particular bucket or network path.
+// Every benchmark object is deleted afterwards, including when the run fails.
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ShardOperation identifies the direction of a shard transfer
+type ShardOperation string
+
+const (
+	ShardUpload   ShardOperation = "upload"
+	ShardDownload ShardOperation = "download"
+)
+
+// ShardTiming describes a single shard transfer
+type ShardTiming struct {
+	Operation  ShardOperation
+	Index      int
+	BucketName string
+	Bytes      int64
+	Duration   time.Duration
+	Err        error
+	Verified   bool // The downloaded shard's hash was checked
+}
+
+// BenchOptions configures a benchmark run
+type BenchOptions struct {
+	Size                int64 // Bytes per benchmark object
+	Iterations          int   // Upload/download round trips
+	DataShards          int
+	ParityShards        int
+	UploadConcurrency   int    // Concurrent shard uploads (0 uses the service's upload concurrency)
+	DownloadConcurrency int    // Concurrent shard downloads (0 uses the service's download concurrency)
+	ShardAlignment      int64  // Pad shards to a multiple of this many bytes (0 uses the service's alignment, negative disables it)
+	Prefix              string // Key prefix for benchmark objects
+}
+
+// LatencyStats summarizes a set of timed transfers
+type LatencyStats struct {
+	Count int
+	Total time.Duration
+	Min   time.Duration
+	Max   time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	MBps  float64 // Throughput over Total, in MiB per second
+}
+
+// ShardStats summarizes the transfers of one shard position across iterations
+type ShardStats struct {
+	Index      int
+	BucketName string
+	Upload     LatencyStats
+	Download   LatencyStats
+	Failures   int
+}
+
+// BenchReport is the result of a benchmark run
+type BenchReport struct {
+	Options  BenchOptions
+	Upload   LatencyStats
+	Download LatencyStats
+	Shards   []ShardStats
+}
+
+// Bench measures upload and download performance of the configured buckets
+func (s *FileService) Bench(ctx context.Context, opts BenchOptions) (BenchReport, error) {
+	report := BenchReport{Options: opts}
+	if opts.Size <= 0 || opts.Iterations <= 0 {
+		return report, fmt.Errorf("benchmark size and iterations must be positive")
+	}
+	if opts.UploadConcurrency <= 0 {
+		opts.UploadConcurrency = s.uploadWorkers()
+	}
+	if opts.DownloadConcurrency <= 0 {
+		opts.DownloadConcurrency = s.downloadWorkers()
+	}
+	if opts.ShardAlignment == 0 {
+		opts.ShardAlignment = s.shardAlignment
+	}
+	opts.ShardAlignment = max(opts.ShardAlignment, 0)
+	report.Options = opts
+	if err := s.checkPlacement(opts.DataShards, opts.ParityShards); err != nil {
+		return report, err
+	}
+
+	data := make([]byte, opts.Size)
+	if _, err := rand.Read(data); err != nil {
+		return report, err
+	}
+
+	var mu sync.Mutex
+	var timings []ShardTiming
+	previous := s.shardObserver
+	s.SetShardObserver(func(timing ShardTiming) {
+		mu.Lock()
+		timings = append(timings, timing)
+		mu.Unlock()
+		if previous != nil {
+			previous(timing) // Keep feeding an observer such as --perf-csv
+		}
+	})
+	defer s.SetShardObserver(previous)
+
+	previousDownloads := s.downloadConcurrency
+	s.SetDownloadConcurrency(opts.DownloadConcurrency)
+	defer s.SetDownloadConcurrency(previousDownloads)
+
+	previousAlignment := s.shardAlignment
+	s.SetShardAlignment(opts.ShardAlignment)
+	defer s.SetShardAlignment(previousAlignment)
+
+	var uploaded []string
+	defer func() {
+		// Always clean up, even if the context was cancelled mid-run
+		for _, key := range uploaded {
+			if err := s.DeleteFile(context.WithoutCancel(ctx), key); err != nil {
+				log.Warnf("Failed to delete benchmark object %s: %v", key, err)
+			}
+		}
+	}()
+
+	runID := time.Now().UnixNano()
+	var uploads, downloads []time.Duration
+	for i := 0; i < opts.Iterations; i++ {
+		key := path.Join(opts.Prefix, fmt.Sprintf("bench-%d-%d", runID, i))
+
+		start := time.Now()
+		err := s.UploadFile(ctx, key, bytes.NewReader(data), true, opts.DataShards, opts.ParityShards, opts.UploadConcurrency)
+		if err != nil {
+			return report, fmt.Errorf("iteration %d upload: %w", i+1, err)
+		}
+		uploads = append(uploads, time.Since(start))
+		uploaded = append(uploaded, key)
+
+		buf := &writeAtBuffer{}
+		start = time.Now()
+		if err := s.DownloadFile(ctx, key, buf, true, false); err != nil {
+			return report, fmt.Errorf("iteration %d download: %w", i+1, err)
+		}
+		downloads = append(downloads, time.Since(start))
+
+		if !bytes.Equal(buf.Bytes(), data) {
+			return report, fmt.Errorf("iteration %d: downloaded data does not match what was uploaded", i+1)
+		}
+		log.Debugf("Benchmark iteration %d: upload %v, download %v", i+1, uploads[i], downloads[i])
+	}
+
+	report.Upload = summarizeLatencies(uploads, opts.Size)
+	report.Download = summarizeLatencies(downloads, opts.Size)
+	report.Shards = summarizeShards(timings)
+	return report, nil
+}
+
+// summarizeShards groups shard timings by shard position
+func summarizeShards(timings []ShardTiming) []ShardStats {
+	type shardSamples struct {
+		stats                      ShardStats
+		uploads, downloads         []time.Duration
+		uploadBytes, downloadBytes int64
+	}
+	byIndex := make(map[int]*shardSamples)
+	for _, timing := range timings {
+		samples, ok := byIndex[timing.Index]
+		if !ok {
+			samples = &shardSamples{stats: ShardStats{Index: timing.Index}}
+			byIndex[timing.Index] = samples
+		}
+		samples.stats.BucketName = timing.BucketName
+		switch {
+		case timing.Err != nil:
+			samples.stats.Failures++
+		case timing.Operation == ShardUpload:
+			samples.uploads = append(samples.uploads, timing.Duration)
+			samples.uploadBytes = timing.Bytes
+		default:
+			samples.downloads = append(samples.downloads, timing.Duration)
+			samples.downloadBytes = timing.Bytes
+		}
+	}
+
+	stats := make([]ShardStats, 0, len(byIndex))
+	for _, samples := range byIndex {
+		samples.stats.Upload = summarizeLatencies(samples.uploads, samples.uploadBytes)
+		samples.stats.Download = summarizeLatencies(samples.downloads, samples.downloadBytes)
+		stats = append(stats, samples.stats)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Index < stats[j].Index })
+	return stats
+}
+
+// summarizeLatencies computes totals, throughput and nearest-rank percentiles
+// size is the number of bytes moved by each transfer.
+func summarizeLatencies(durations []time.Duration, size int64) LatencyStats {
+	stats := LatencyStats{Count: len(durations)}
+	if len(durations) == 0 {
+		return stats
+	}
+
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for _, d := range sorted {
+		stats.Total += d
+	}
+	stats.Min = sorted[0]
+	stats.Max = sorted[len(sorted)-1]
+	stats.P50 = percentile(sorted, 50)
+	stats.P95 = percentile(sorted, 95)
+	if stats.Total > 0 {
+		stats.MBps = float64(size) * float64(len(sorted)) / (1024 * 1024) / stats.Total.Seconds()
+	}
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,97 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements comparing two objects from their metadata alone.
+//
+// Objects of different sizes always differ. Otherwise the whole-file CRC64 recorded at
+// upload decides. Sizes and hashes are those of the content as uploaded, so objects
+// compressed or encrypted differently still compare by what they hold. Records written
+// before that hash existed fall back to the shard hashes: splitting is deterministic,
+// so two objects with the same shard layout are identical exactly when their data
+// shards are. Shards of compressed or encrypted objects hold the transformed bytes,
+// which differ between uploads of the same content (each encryption gets a random
+// nonce), so they are never compared. Objects without a file hash and with different
+// layouts cannot be compared without downloading them.
+package service
+
+import (
+	"context"
+
+	"github.com/zzenonn/zstore/internal/domain"
+)
+
+// ComparisonVerdict is the outcome of comparing two objects
+type ComparisonVerdict string
+
+const (
+	ObjectsIdentical ComparisonVerdict = "identical"
+	ObjectsDifferent ComparisonVerdict = "different"
+	ObjectsUnknown   ComparisonVerdict = "unknown" // Only a full download can tell
+)
+
+// ObjectComparison describes how two objects were compared and the result
+type ObjectComparison struct {
+	KeyA    string            `json:"key_a"`
+	KeyB    string            `json:"key_b"`
+	SizeA   int64             `json:"size_a"`
+	SizeB   int64             `json:"size_b"`
+	HashA   string            `json:"file_hash_a,omitempty"`
+	HashB   string            `json:"file_hash_b,omitempty"`
+	Verdict ComparisonVerdict `json:"verdict"`
+	Basis   string            `json:"basis"` // What the verdict rests on: "size", "file hash", "shard hashes" or why none applies
+}
+
+// CompareFiles compares two objects using their metadata, without downloading any shard
+func (s *FileService) CompareFiles(ctx context.Context, keyA, keyB string) (ObjectComparison, error) {
+	a, err := s.StatFile(ctx, keyA)
+	if err != nil {
+		return ObjectComparison{}, err
+	}
+	b, err := s.StatFile(ctx, keyB)
+	if err != nil {
+		return ObjectComparison{}, err
+	}
+
+	comparison := ObjectComparison{
+		KeyA:  keyA,
+		KeyB:  keyB,
+		SizeA: a.ContentSize(),
+		SizeB: b.ContentSize(),
+		HashA: a.ContentHash(),
+		HashB: b.ContentHash(),
+	}
+	switch {
+	case comparison.SizeA != comparison.SizeB:
+		comparison.Verdict, comparison.Basis = ObjectsDifferent, "size"
+	case comparison.HashA != "" && comparison.HashB != "":
+		comparison.Verdict, comparison.Basis = verdict(comparison.HashA == comparison.HashB), "file hash"
+	case len(a.Transforms) > 0 || len(b.Transforms) > 0:
+		comparison.Verdict, comparison.Basis = ObjectsUnknown, "shards hold compressed or encrypted bytes; a full download is required"
+	default:
+		comparison.Verdict, comparison.Basis = compareDataShards(a, b)
+	}
+	return comparison, nil
+}
+
+// compareDataShards compares two objects of equal size by the hashes of their data shards
+func compareDataShards(a, b domain.ObjectMetadata) (ComparisonVerdict, string) {
+	dataShards := len(a.ShardHashes) - a.ParityShards
+	if dataShards <= 0 || len(b.ShardHashes)-b.ParityShards != dataShards || a.ShardSize != b.ShardSize {
+		return ObjectsUnknown, "no file hash recorded and shard layouts differ; a full download is required"
+	}
+	for i := 0; i < dataShards; i++ {
+		if a.ShardHashes[i].Hash == "" || b.ShardHashes[i].Hash == "" {
+			return ObjectsUnknown, "no file hash or shard hash recorded; a full download is required"
+		}
+		if a.ShardHashes[i].Hash != b.ShardHashes[i].Hash {
+			return ObjectsDifferent, "shard hashes"
+		}
+	}
+	return ObjectsIdentical, "shard hashes"
+}
+
+// verdict turns an equality check into a verdict
+func verdict(equal bool) ComparisonVerdict {
+	if equal {
+		return ObjectsIdentical
+	}
+	return ObjectsDifferent
+}
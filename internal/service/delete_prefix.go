@@ -0,0 +1,101 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements deleting every object under a prefix.
+//
+// DeletePrefix lists the objects under a prefix and every sub-prefix (ListFilesRecursive)
+// and deletes each one through DeleteFile, so soft delete, retention locks and auditing
+// apply to every object as they would to a single delete. Objects are deleted
+// deleteWorkers() at a time. A failure does not stop the others; each one is reported in
+// the result. This works on objects and their metadata, unlike the repositories'
+// DeletePrefix, which removes raw storage keys.
+//
+// Metadata is keyed by exact prefix, so sub-prefixes are found through a PrefixLister;
+// with a store that cannot enumerate prefixes, the listing fails with
+// errors.ErrNotImplemented rather than silently skipping sub-prefixes.
+package service
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+// PrefixDeleteFailure is an object under a prefix that could not be deleted
+type PrefixDeleteFailure struct {
+	Key string `json:"key"`
+	Err string `json:"error"`
+}
+
+// PrefixDeleteResult summarizes a DeletePrefix call
+type PrefixDeleteResult struct {
+	Deleted []string              `json:"deleted"` // Sorted object keys
+	Failed  []PrefixDeleteFailure `json:"failed"`  // Sorted by object key
+}
+
+// DeletePrefix deletes every object under prefix and its sub-prefixes concurrently
+// The error is only set when the objects could not be listed.
+func (s *FileService) DeletePrefix(ctx context.Context, prefix string) (PrefixDeleteResult, error) {
+	files, err := s.ListFilesRecursive(ctx, prefix)
+	if err != nil {
+		return PrefixDeleteResult{}, err
+	}
+	return s.deleteObjects(ctx, files), nil
+}
+
+// ListFilesRecursive lists the files under prefix and under every prefix below it
+func (s *FileService) ListFilesRecursive(ctx context.Context, prefix string) ([]domain.ObjectMetadata, error) {
+	prefix = s.NormalizeKey(prefix)
+	lister, ok := s.metadataRepo.(PrefixLister)
+	if !ok {
+		return nil, fmt.Errorf("metadata store cannot list sub-prefixes: %w", errors.ErrNotImplemented)
+	}
+	prefixes, err := lister.ListPrefixes(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []domain.ObjectMetadata
+	for _, subPrefix := range prefixes {
+		listed, err := s.ListFiles(ctx, subPrefix)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, listed...)
+	}
+	return files, nil
+}
+
+// deleteObjects deletes the given objects through DeleteFile, deleteWorkers() at a time
+func (s *FileService) deleteObjects(ctx context.Context, files []domain.ObjectMetadata) PrefixDeleteResult {
+	result := PrefixDeleteResult{Deleted: []string{}, Failed: []PrefixDeleteFailure{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, s.deleteWorkers())
+	for _, file := range files {
+		key := path.Join(file.Prefix, file.FileName)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			err := s.DeleteFile(ctx, key)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Failed = append(result.Failed, PrefixDeleteFailure{Key: key, Err: err.Error()})
+				return
+			}
+			result.Deleted = append(result.Deleted, key)
+		}()
+	}
+	wg.Wait()
+
+	sort.Strings(result.Deleted)
+	sort.Slice(result.Failed, func(i, j int) bool { return result.Failed[i].Key < result.Failed[j].Key })
+	return result
+}
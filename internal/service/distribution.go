@@ -0,0 +1,121 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements per-bucket storage distribution statistics for capacity planning.
+//
+// Distribution tallies, from metadata alone, how many shard copies and bytes each bucket
+// holds for the objects under a prefix. Every recorded copy counts at the object's shard
+// size, so the bytes are an estimate of what the objects occupy, not a listing of the
+// buckets. Registered buckets that hold nothing are listed too, since an empty bucket is
+// the clearest sign of placement skew.
+package service
+
+import (
+	"context"
+	"sort"
+)
+
+// BucketDistribution is what one bucket holds of the objects under a prefix
+type BucketDistribution struct {
+	Bucket       string  `json:"bucket"`
+	StorageType  string  `json:"storage_type"`
+	Shards       int     `json:"shards"` // Shard copies, counting each mirror
+	Bytes        int64   `json:"bytes"`  // Shards times their shard size
+	Percent      float64 `json:"percent"`
+	Unregistered bool    `json:"unregistered,omitempty"` // Named in metadata but not in the configuration
+}
+
+// ProviderDistribution is what the buckets of one storage type hold together
+type ProviderDistribution struct {
+	StorageType string  `json:"storage_type"`
+	Buckets     int     `json:"buckets"`
+	Shards      int     `json:"shards"`
+	Bytes       int64   `json:"bytes"`
+	Percent     float64 `json:"percent"`
+}
+
+// StorageDistribution reports how the shards of the objects under a prefix are spread over buckets
+type StorageDistribution struct {
+	Prefix    string                 `json:"prefix"`
+	Objects   int                    `json:"objects"`
+	Shards    int                    `json:"shards"`
+	Bytes     int64                  `json:"bytes"`
+	Buckets   []BucketDistribution   `json:"buckets"`   // Most bytes first
+	Providers []ProviderDistribution `json:"providers"` // Most bytes first
+}
+
+// Distribution tallies the shard copies and bytes each bucket holds for the objects under prefix
+func (s *FileService) Distribution(ctx context.Context, prefix string) (StorageDistribution, error) {
+	files, err := s.ListFiles(ctx, prefix)
+	if err != nil {
+		return StorageDistribution{}, err
+	}
+
+	distribution := StorageDistribution{Prefix: prefix, Buckets: []BucketDistribution{}, Providers: []ProviderDistribution{}}
+	byBucket := make(map[string]*BucketDistribution)
+	for _, bucketName := range s.placer.ListBuckets() {
+		bucket := &BucketDistribution{Bucket: bucketName}
+		if repo, err := s.placer.GetRepositoryForBucket(bucketName); err == nil {
+			bucket.StorageType = repo.GetStorageType()
+		}
+		byBucket[bucketName] = bucket
+	}
+
+	for _, metadata := range files {
+		distribution.Objects++
+		for _, shard := range metadata.ShardHashes {
+			for _, location := range shard.Locations {
+				bucket, ok := byBucket[location.BucketName]
+				if !ok {
+					bucket = &BucketDistribution{Bucket: location.BucketName, StorageType: location.StorageType, Unregistered: true}
+					byBucket[location.BucketName] = bucket
+				}
+				bucket.Shards++
+				bucket.Bytes += metadata.ShardSize
+				distribution.Shards++
+				distribution.Bytes += metadata.ShardSize
+			}
+		}
+	}
+
+	byProvider := make(map[string]*ProviderDistribution)
+	for _, bucket := range byBucket {
+		bucket.Percent = percentOf(bucket.Bytes, distribution.Bytes)
+		distribution.Buckets = append(distribution.Buckets, *bucket)
+
+		provider, ok := byProvider[bucket.StorageType]
+		if !ok {
+			provider = &ProviderDistribution{StorageType: bucket.StorageType}
+			byProvider[bucket.StorageType] = provider
+		}
+		provider.Buckets++
+		provider.Shards += bucket.Shards
+		provider.Bytes += bucket.Bytes
+	}
+	for _, provider := range byProvider {
+		provider.Percent = percentOf(provider.Bytes, distribution.Bytes)
+		distribution.Providers = append(distribution.Providers, *provider)
+	}
+
+	sort.Slice(distribution.Buckets, func(i, j int) bool {
+		a, b := distribution.Buckets[i], distribution.Buckets[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.Bucket < b.Bucket
+	})
+	sort.Slice(distribution.Providers, func(i, j int) bool {
+		a, b := distribution.Providers[i], distribution.Providers[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.StorageType < b.StorageType
+	})
+	return distribution, nil
+}
+
+// percentOf returns part as a percentage of total (0 when total is 0)
+func percentOf(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
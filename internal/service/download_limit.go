@@ -0,0 +1,73 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements the limit on concurrent downloads.
+//
+// Every download buffers shards and runs its own shard goroutines, so a spike of downloads,
+// as a multi-tenant gateway sees, can exhaust memory. With a limit set, each download holds
+// one slot of a shared semaphore while it reads shards and reconstructs. Downloads beyond the
+// limit either queue for a slot or fail at once with errors.ErrTooManyDownloads.
+//
+// A slot travels in the context, so a download that reaches reconstruction through another
+// entry point (a stream falling back to full reconstruction, for example) uses the slot it
+// already holds instead of waiting for a second one.
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+// What happens to downloads beyond the limit set with SetMaxConcurrentDownloads
+const (
+	DownloadLimitQueue  = "queue"  // Wait for a slot, or until the context ends
+	DownloadLimitReject = "reject" // Fail with errors.ErrTooManyDownloads
+)
+
+// downloadSlotKey marks a context whose download already holds a slot
+type downloadSlotKey struct{}
+
+// SetMaxConcurrentDownloads limits how many downloads read and reconstruct objects at once
+// A limit of 0 or less removes the limit. The mode is DownloadLimitQueue (or empty) or DownloadLimitReject.
+func (s *FileService) SetMaxConcurrentDownloads(limit int, mode string) error {
+	switch mode {
+	case "", DownloadLimitQueue:
+		s.rejectExcessDownloads = false
+	case DownloadLimitReject:
+		s.rejectExcessDownloads = true
+	default:
+		return fmt.Errorf("invalid download limit mode %q: must be %q or %q", mode, DownloadLimitQueue, DownloadLimitReject)
+	}
+
+	s.downloadSlots = nil
+	if limit > 0 {
+		s.downloadSlots = make(chan struct{}, limit)
+	}
+	return nil
+}
+
+// AcquireDownloadSlot reserves a download slot for the operations run with the returned context
+// The release function must be called once they are done. Without a limit, or when ctx already
+// holds a slot, nothing is reserved. Callers that must answer before a download starts, such as
+// the gateway, use this to learn of a rejection while they can still report it.
+func (s *FileService) AcquireDownloadSlot(ctx context.Context) (context.Context, func(), error) {
+	slots := s.downloadSlots
+	if slots == nil || ctx.Value(downloadSlotKey{}) != nil {
+		return ctx, func() {}, nil
+	}
+
+	if s.rejectExcessDownloads {
+		select {
+		case slots <- struct{}{}:
+		default:
+			return ctx, nil, fmt.Errorf("%w: limit is %d", errors.ErrTooManyDownloads, cap(slots))
+		}
+	} else {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx, nil, ctx.Err()
+		}
+	}
+	return context.WithValue(ctx, downloadSlotKey{}, true), func() { <-slots }, nil
+}
@@ -0,0 +1,196 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements whole-object replication of critical objects to a disaster recovery bucket.
+//
+// Erasure coding survives losing some buckets, not losing too many at once. An upload run with
+// a context from WithDRReplication also stores the whole object, unsharded, in a DR bucket
+// through the RawFileService once the erasure-coded object is stored, and records the copy's
+// location in the object's metadata (ObjectMetadata.DRCopy). RestoreWithDRFallback reads the
+// object as usual and only turns to the DR copy when reconstruction fails; DownloadFromDR
+// reads the DR copy directly. Either way the copy is checked against the object's recorded
+// size and hash before anything is written.
+//
+// The DR copy follows the object: re-encoding keeps it, uploading the key again replaces or
+// removes it, and permanently deleting the object deletes it.
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+// DRTarget is a bucket receiving whole-object disaster recovery copies
+type DRTarget struct {
+	Type   objectstore.RepositoryType
+	Bucket string
+	Prefix string // Key prefix of the copies in the bucket (empty stores them under the object's key)
+}
+
+// ParseDRTarget parses a DR target of the form scheme://bucket[/prefix], e.g. gs://dr-bucket/zstore
+func ParseDRTarget(target string) (DRTarget, error) {
+	scheme, rest, ok := strings.Cut(strings.TrimSpace(target), "://")
+	if !ok {
+		return DRTarget{}, fmt.Errorf("DR target %q must look like gs://bucket[/prefix]", target)
+	}
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	config, err := objectstore.ParseBucketConfig(scheme + "://" + bucket)
+	if err != nil {
+		return DRTarget{}, fmt.Errorf("invalid DR target %q: %w", target, err)
+	}
+	return DRTarget{Type: config.Type, Bucket: config.Name, Prefix: strings.Trim(prefix, "/")}, nil
+}
+
+// String returns the target in the form ParseDRTarget accepts
+func (t DRTarget) String() string {
+	scheme := string(t.Type)
+	for _, provider := range objectstore.Providers() {
+		if provider.Type == t.Type && provider.Scheme != "" {
+			scheme = provider.Scheme
+		}
+	}
+	return fmt.Sprintf("%s://%s", scheme, path.Join(t.Bucket, t.Prefix))
+}
+
+// location returns where the DR copy of an object is stored
+func (t DRTarget) location(key string) domain.Location {
+	return domain.Location{StorageType: string(t.Type), BucketName: t.Bucket, Key: path.Join(t.Prefix, key)}
+}
+
+// drTargetKey is the context key of an upload's DR target
+type drTargetKey struct{}
+
+// WithDRReplication makes uploads run with the returned context also store a whole copy in target
+func WithDRReplication(ctx context.Context, target DRTarget) context.Context {
+	return context.WithValue(ctx, drTargetKey{}, target)
+}
+
+// drTarget returns the DR target of an upload run with ctx
+func drTarget(ctx context.Context) (DRTarget, bool) {
+	target, ok := ctx.Value(drTargetKey{}).(DRTarget)
+	return target, ok
+}
+
+// SetDRService sets the raw file service DR copies are written and read through
+// Without one, uploads asking for DR replication fail before reading their input.
+func (s *FileService) SetDRService(raw *RawFileService) {
+	s.drService = raw
+}
+
+// checkDRReplication fails when an upload run with ctx asks for a DR copy that cannot be written
+func (s *FileService) checkDRReplication(ctx context.Context) error {
+	if _, ok := drTarget(ctx); ok && s.drService == nil {
+		return errors.ErrDRNotConfigured
+	}
+	return nil
+}
+
+// replicateToDR stores the whole object in the upload's DR target and records the copy in metadata
+// previous is the copy recorded before the upload (nil when there was none); it is removed once
+// the object no longer refers to it.
+func (s *FileService) replicateToDR(ctx context.Context, key string, data []byte, metadata *domain.ObjectMetadata, previous *domain.Location, quiet bool) error {
+	target, ok := drTarget(ctx)
+	if !ok {
+		if previous != nil {
+			s.deleteDRCopy(ctx, key, *previous)
+		}
+		return nil
+	}
+
+	location := target.location(key)
+	if err := s.drService.UploadToRepository(ctx, location.BucketName, location.Key, bytes.NewReader(data), quiet, target.Type, ""); err != nil {
+		return fmt.Errorf("failed to store DR copy in %s: %w", target, err)
+	}
+	metadata.DRCopy = &location
+	if _, err := s.metadataRepo.UpdateMetadata(ctx, *metadata); err != nil {
+		metadata.DRCopy = nil
+		return fmt.Errorf("DR copy stored but not recorded in metadata: %w", err)
+	}
+	if previous != nil && *previous != location {
+		s.deleteDRCopy(ctx, key, *previous)
+	}
+	return nil
+}
+
+// deleteDRCopy removes an object's DR copy, logging failures
+func (s *FileService) deleteDRCopy(ctx context.Context, key string, location domain.Location) {
+	if s.drService == nil {
+		log.Warnf("DR copy of %s in %s/%s was not removed: no DR service is configured", key, location.BucketName, location.Key)
+		return
+	}
+	if err := s.drService.DeleteFromRepository(ctx, location.BucketName, location.Key, objectstore.RepositoryType(location.StorageType), ""); err != nil {
+		log.Warnf("Could not remove DR copy of %s in %s/%s: %v", key, location.BucketName, location.Key, err)
+	}
+}
+
+// DownloadFromDR writes an object's DR copy to dest without reading any shard
+// The copy must match the object's recorded size and hash. Objects without a DR copy fail
+// with errors.ErrNoDRCopy.
+func (s *FileService) DownloadFromDR(ctx context.Context, key string, dest io.WriterAt, quiet bool) error {
+	key = s.NormalizeKey(key)
+	metadata, err := s.lookupMetadata(ctx, key)
+	if err != nil {
+		return err
+	}
+	return s.downloadDRCopy(ctx, metadata, dest, quiet)
+}
+
+// RestoreWithDRFallback downloads an object, turning to its DR copy if reconstruction fails
+// It reports whether the DR copy was used. Objects that cannot be found, and objects without
+// a DR copy, return the reconstruction error unchanged.
+func (s *FileService) RestoreWithDRFallback(ctx context.Context, key string, dest io.WriterAt, quiet bool, verifyIntegrity bool) (bool, error) {
+	key = s.NormalizeKey(key)
+	err := s.DownloadFile(ctx, key, dest, quiet, verifyIntegrity)
+	if err == nil || stderrors.Is(err, errors.ErrMetadataNotFound) || ctx.Err() != nil {
+		return false, err
+	}
+
+	metadata, lookupErr := s.lookupMetadata(ctx, key)
+	if lookupErr != nil || metadata.DRCopy == nil {
+		return false, err
+	}
+	log.Warnf("Reconstruction of %s failed (%v); restoring from its DR copy", key, err)
+	if drErr := s.downloadDRCopy(ctx, metadata, dest, quiet); drErr != nil {
+		return false, fmt.Errorf("reconstruction failed (%v) and the DR copy could not be restored: %w", err, drErr)
+	}
+	return true, nil
+}
+
+// downloadDRCopy reads an object's DR copy, checks it and writes it to dest
+func (s *FileService) downloadDRCopy(ctx context.Context, metadata domain.ObjectMetadata, dest io.WriterAt, quiet bool) error {
+	location := metadata.DRCopy
+	if location == nil {
+		return fmt.Errorf("%w: %s/%s", errors.ErrNoDRCopy, metadata.Prefix, metadata.FileName)
+	}
+	if s.drService == nil {
+		return errors.ErrDRNotConfigured
+	}
+
+	buf := &writeAtBuffer{}
+	if err := s.drService.DownloadFromRepository(ctx, location.BucketName, location.Key, buf, quiet, objectstore.RepositoryType(location.StorageType), ""); err != nil {
+		return fmt.Errorf("failed to read DR copy from %s/%s: %w", location.BucketName, location.Key, err)
+	}
+	data := buf.Bytes()
+	if err := verifyReconstructedSize(data, metadata); err != nil {
+		return fmt.Errorf("DR copy %s/%s: %w", location.BucketName, location.Key, err)
+	}
+	if metadata.FileHash != "" {
+		if err := verifyFileIntegrity(data, metadata.FileHash); err != nil {
+			return fmt.Errorf("DR copy %s/%s: %w", location.BucketName, location.Key, err)
+		}
+	}
+	// The DR copy is stored compressed and encrypted like the shards
+	data, err := s.decodeContent(data, metadata)
+	if err != nil {
+		return fmt.Errorf("DR copy %s/%s: %w", location.BucketName, location.Key, err)
+	}
+	return s.writeReconstructed(dest, data, quiet)
+}
@@ -21,6 +21,15 @@
 //   metadata, shards, err := ShardFile(data, 4, 2)  // 4 data + 2 parity shards
 //   reconstructed, err := ReconstructFile(shards, metadata)
 //
+// Encoding and reconstruction split each shard across goroutines. By default the
+// number of goroutines is sized from the shard size and GOMAXPROCS, so large objects
+// use every CPU while small ones are not split at all; ShardFileConcurrent caps it.
+//
+// AlignShards pads every shard with zeros up to a block boundary (shard_alignment) for
+// providers and filesystems that handle aligned object sizes faster. Padding all shards,
+// parity included, by the same amount keeps them a valid Reed-Solomon set once trimmed:
+// the metadata records the unpadded size, and shards are cut back to it before decoding.
+//
 // The service integrates with FileService to provide distributed, fault-tolerant
 // file storage across multiple buckets and cloud providers.
 package service
@@ -30,16 +39,81 @@ import (
 	"fmt"
 	"hash/crc64"
 	"io"
+	"math"
 	"os"
 
 	"github.com/klauspost/reedsolomon"
+	log "github.com/sirupsen/logrus"
 	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
 )
 
 
+// EffectiveDataShards returns the data shard count to use for an object of the given size
+// When minShardSize is set and the requested layout would produce shards smaller than it,
+// the data shard count is reduced until each shard holds at least minShardSize bytes.
+// Objects smaller than minShardSize end up with a single data shard, which makes every
+// parity shard a full copy of the object (replication mode).
+func EffectiveDataShards(size int64, dataShards int, minShardSize int64) int {
+	if minShardSize <= 0 || dataShards <= 1 || size <= 0 {
+		return dataShards
+	}
+
+	shardSize := (size + int64(dataShards) - 1) / int64(dataShards)
+	if shardSize >= minShardSize {
+		return dataShards
+	}
 
+	reduced := int(size / minShardSize)
+	if reduced < 1 {
+		return 1
+	}
+	return reduced
+}
+
+// MaxTotalShards is the largest number of data plus parity shards Reed-Solomon supports
+// The codec works over GF(2^8), which has 256 elements to use as shard coordinates.
+const MaxTotalShards = 256
+
+// ValidateShardConfig checks a shard layout before any data is read or encoded
+func ValidateShardConfig(dataShards, parityShards int) error {
+	switch {
+	case dataShards < 1:
+		return fmt.Errorf("%w: %d data shards; at least 1 data shard is required", errors.ErrInvalidShardConfig, dataShards)
+	case parityShards < 0:
+		return fmt.Errorf("%w: %d parity shards; parity cannot be negative", errors.ErrInvalidShardConfig, parityShards)
+	case dataShards+parityShards > MaxTotalShards:
+		return fmt.Errorf("%w: %d data + %d parity = %d shards; Reed-Solomon supports at most %d shards in total", errors.ErrInvalidShardConfig, dataShards, parityShards, dataShards+parityShards, MaxTotalShards)
+	}
+	return nil
+}
+
+// encoderOptions returns the Reed-Solomon options for shards of the given size
+// A maxGoroutines of 0 or less sizes the goroutine pool from the shard size and
+// GOMAXPROCS; a positive value caps the number of goroutines each call is split into.
+func encoderOptions(shardSize int64, maxGoroutines int) []reedsolomon.Option {
+	if maxGoroutines > 0 {
+		return []reedsolomon.Option{reedsolomon.WithMaxGoroutines(maxGoroutines)}
+	}
+	if shardSize > math.MaxInt32 {
+		shardSize = math.MaxInt32
+	}
+	return []reedsolomon.Option{reedsolomon.WithAutoGoroutines(int(shardSize))}
+}
+
+// ShardFile splits data into data and parity shards with the default encoder concurrency
 func ShardFile(data []byte, dataShards, parityShards int) (domain.ObjectMetadata, [][]byte, error) {
-	enc, err := reedsolomon.New(dataShards, parityShards)
+	return ShardFileConcurrent(data, dataShards, parityShards, 0)
+}
+
+// ShardFileConcurrent splits data into shards, encoding with at most maxGoroutines goroutines
+// A maxGoroutines of 0 or less picks the number from the shard size and CPU count.
+func ShardFileConcurrent(data []byte, dataShards, parityShards, maxGoroutines int) (domain.ObjectMetadata, [][]byte, error) {
+	if err := ValidateShardConfig(dataShards, parityShards); err != nil {
+		return domain.ObjectMetadata{}, nil, err
+	}
+	shardSize := (int64(len(data)) + int64(dataShards) - 1) / int64(dataShards)
+	enc, err := reedsolomon.New(dataShards, parityShards, encoderOptions(shardSize, maxGoroutines)...)
 	if err != nil {
 		return domain.ObjectMetadata{}, nil, err
 	}
@@ -58,10 +132,7 @@ func ShardFile(data []byte, dataShards, parityShards int) (domain.ObjectMetadata
 	for _, shard := range shards {
 		crc := crc64.Checksum(shard, table)
 		shardStorage := domain.ShardStorage{
-			Hash:        fmt.Sprintf("%016x", crc),
-			StorageType: "",
-			BucketName:  "",
-			Key:         "",
+			Hash: fmt.Sprintf("%016x", crc),
 		}
 		hashes = append(hashes, shardStorage)
 	}
@@ -69,19 +140,62 @@ func ShardFile(data []byte, dataShards, parityShards int) (domain.ObjectMetadata
 	meta := domain.ObjectMetadata{
 		OriginalSize: int64(len(data)),
 		ShardSize:    int64(len(shards[0])),
+		DataShards:   dataShards,
 		ParityShards: parityShards,
 		ShardHashes:  hashes,
+		FileHash:     fmt.Sprintf("%016x", crc64.Checksum(data, table)),
 	}
 
 	return meta, shards, nil
 }
 
+// AlignShards pads every shard with zeros to the next multiple of alignment bytes
+// The returned metadata records the padded size as ShardSize, the unpadded one as
+// UnalignedShardSize, and hashes of the padded shards, which are what gets stored.
+// An alignment of 1 or less, or shards already on a boundary, leave both unchanged.
+func AlignShards(meta domain.ObjectMetadata, shards [][]byte, alignment int64) (domain.ObjectMetadata, [][]byte) {
+	if alignment <= 1 || meta.ShardSize%alignment == 0 {
+		return meta, shards
+	}
+	return padShards(meta, shards, (meta.ShardSize+alignment-1)/alignment*alignment)
+}
+
+// padShards pads every shard with zeros to size bytes and rehashes them
+func padShards(meta domain.ObjectMetadata, shards [][]byte, size int64) (domain.ObjectMetadata, [][]byte) {
+	if size <= meta.ShardSize {
+		return meta, shards
+	}
+	table := crc64.MakeTable(crc64.ISO)
+	padded := make([][]byte, len(shards))
+	hashes := make([]domain.ShardStorage, len(meta.ShardHashes))
+	copy(hashes, meta.ShardHashes)
+	for i, shard := range shards {
+		padded[i] = make([]byte, size)
+		copy(padded[i], shard)
+		hashes[i].Hash = fmt.Sprintf("%016x", crc64.Checksum(padded[i], table))
+	}
+	meta.UnalignedShardSize = meta.ShardSize
+	meta.ShardSize = size
+	meta.ShardHashes = hashes
+	return meta, padded
+}
+
+// trimShards cuts alignment padding off the shards present so they can be decoded
+func trimShards(shards [][]byte, meta domain.ObjectMetadata) {
+	size := meta.EncodedShardSize()
+	for i, shard := range shards {
+		if int64(len(shard)) > size {
+			shards[i] = shard[:size]
+		}
+	}
+}
+
 func ReconstructFile(shards [][]byte, meta domain.ObjectMetadata) ([]byte, error) {
 	totalShards := len(meta.ShardHashes)
 	dataShards := totalShards - meta.ParityShards
 	parityShards := meta.ParityShards
 
-	enc, err := reedsolomon.New(dataShards, parityShards)
+	enc, err := reedsolomon.New(dataShards, parityShards, encoderOptions(meta.EncodedShardSize(), 0)...)
 	if err != nil {
 		return nil, err
 	}
@@ -89,17 +203,91 @@ func ReconstructFile(shards [][]byte, meta domain.ObjectMetadata) ([]byte, error
 	// Make shards slice with total shards capacity
 	reconstructShards := make([][]byte, totalShards)
 	copy(reconstructShards, shards)
+	trimShards(reconstructShards, meta)
 
 	if err := enc.Reconstruct(reconstructShards); err != nil {
 		return nil, err
 	}
 
-	var buf bytes.Buffer
-	if err := enc.Join(&buf, reconstructShards, int(meta.OriginalSize)); err != nil {
+	return joinShards(enc, reconstructShards, meta, io.Discard)
+}
+
+// maxReconstructionAttempts bounds how many shard subsets ReconstructVerifiedFile tries
+const maxReconstructionAttempts = 64
+
+// ReconstructVerifiedFile rebuilds a file and checks it against the whole-file hash
+// A shard can be corrupt yet match its recorded CRC (for example if it was damaged
+// before hashing), which only shows up as a bad whole-file hash. When that happens,
+// reconstruction is retried while leaving out progressively larger sets of shards,
+// as long as enough remain, until the output matches or the attempts run out.
+// Missing shards are nil. Metadata without a file hash is returned unverified.
+func ReconstructVerifiedFile(shards [][]byte, meta domain.ObjectMetadata) ([]byte, error) {
+	data, err := ReconstructFile(shards, meta)
+	if err == nil && (meta.FileHash == "" || verifyFileIntegrity(data, meta.FileHash) == nil) {
+		return data, nil
+	}
+	if meta.FileHash == "" {
 		return nil, err
 	}
 
-	return buf.Bytes(), nil
+	var present []int
+	for i, shard := range shards {
+		if shard != nil {
+			present = append(present, i)
+		}
+	}
+	minShardsNeeded := len(meta.ShardHashes) - meta.ParityShards
+
+	attempts := 0
+	var recovered []byte
+	for dropCount := 1; recovered == nil && attempts < maxReconstructionAttempts && len(present)-dropCount >= minShardsNeeded; dropCount++ {
+		forEachCombination(present, dropCount, func(dropped []int) bool {
+			if attempts >= maxReconstructionAttempts {
+				return false
+			}
+			attempts++
+
+			subset := make([][]byte, len(shards))
+			copy(subset, shards)
+			for _, i := range dropped {
+				subset[i] = nil
+			}
+
+			data, err := ReconstructFile(subset, meta)
+			if err == nil && verifyFileIntegrity(data, meta.FileHash) == nil {
+				log.Warnf("Recovered file by excluding suspect shards %v after %d attempts", dropped, attempts)
+				recovered = data
+				return false
+			}
+			return true
+		})
+	}
+
+	if recovered == nil {
+		return nil, errors.ErrFileIntegrityCheck
+	}
+	return recovered, nil
+}
+
+// forEachCombination calls fn with every k-element subset of items in lexicographic order
+// Iteration stops early when fn returns false.
+func forEachCombination(items []int, k int, fn func([]int) bool) {
+	current := make([]int, 0, k)
+	var walk func(start int) bool
+	walk = func(start int) bool {
+		if len(current) == k {
+			return fn(current)
+		}
+		for i := start; i <= len(items)-(k-len(current)); i++ {
+			current = append(current, items[i])
+			if !walk(i + 1) {
+				return false
+			}
+			current = current[:len(current)-1]
+		}
+		return true
+	}
+	walk(0)
 }
 
 // ReconstructFileFromFiles reconstructs a file from shard files without loading all into memory
@@ -108,7 +296,7 @@ func ReconstructFileFromFiles(shardFiles []*os.File, meta domain.ObjectMetadata)
 	dataShards := totalShards - meta.ParityShards
 	parityShards := meta.ParityShards
 
-	enc, err := reedsolomon.New(dataShards, parityShards)
+	enc, err := reedsolomon.New(dataShards, parityShards, encoderOptions(meta.EncodedShardSize(), 0)...)
 	if err != nil {
 		return nil, err
 	}
@@ -125,50 +313,110 @@ func ReconstructFileFromFiles(shardFiles []*os.File, meta domain.ObjectMetadata)
 			reconstructShards[i] = shardData
 		}
 	}
+	trimShards(reconstructShards, meta)
 
 	if err := enc.Reconstruct(reconstructShards); err != nil {
 		return nil, err
 	}
 
-	var buf bytes.Buffer
-	if err := enc.Join(&buf, reconstructShards, int(meta.OriginalSize)); err != nil {
-		return nil, err
-	}
-
-	return buf.Bytes(), nil
+	return joinShards(enc, reconstructShards, meta, io.Discard)
 }
 
 // ReconstructFileFromPaths reconstructs a file from shard file paths
 func ReconstructFileFromPaths(filePaths []string, meta domain.ObjectMetadata) ([]byte, error) {
+	return reconstructFileFromPaths(filePaths, meta, phaseProgress{quiet: true})
+}
+
+// reconstructFileFromPaths reconstructs a file from shard file paths, showing a bar per phase
+// Reading the shards and joining them count bytes; reconstruction, which only runs when
+// data shards are missing, counts the shards it rebuilds.
+func reconstructFileFromPaths(filePaths []string, meta domain.ObjectMetadata, progress phaseProgress) ([]byte, error) {
 	totalShards := len(meta.ShardHashes)
 	dataShards := totalShards - meta.ParityShards
 	parityShards := meta.ParityShards
 
-	enc, err := reedsolomon.New(dataShards, parityShards)
+	enc, err := reedsolomon.New(dataShards, parityShards, encoderOptions(meta.EncodedShardSize(), 0)...)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create sparse array for reconstruction - only first N files are valid
+	// Create sparse array for reconstruction - paths are indexed by shard position
+	// and empty paths mark shards that are missing
 	reconstructShards := make([][]byte, totalShards)
+	var present int64
 	for i, path := range filePaths {
-		if i < totalShards {
+		if i < totalShards && path != "" {
+			present++
+		}
+	}
+	readBar := progress.bar(present*meta.ShardSize, "reading shards", true)
+	for i, path := range filePaths {
+		if i < totalShards && path != "" {
 			shardData, err := os.ReadFile(path)
 			if err != nil {
 				return nil, fmt.Errorf("failed to read shard file %s: %w", path, err)
 			}
 			reconstructShards[i] = shardData
+			readBar.Add(len(shardData))
 		}
 	}
+	readBar.Finish()
+	trimShards(reconstructShards, meta)
 
-	if err := enc.Reconstruct(reconstructShards); err != nil {
+	// Only the data shards are joined, so parity shards that were never downloaded stay missing
+	missing := 0
+	for _, shard := range reconstructShards[:dataShards] {
+		if shard == nil {
+			missing++
+		}
+	}
+	if missing > 0 {
+		reconstructBar := progress.bar(int64(missing), "reconstructing", false)
+		if err := enc.ReconstructData(reconstructShards); err != nil {
+			return nil, err
+		}
+		reconstructBar.Add(missing)
+		reconstructBar.Finish()
+	}
+
+	joinBar := progress.bar(meta.OriginalSize, "joining", true)
+	data, err := joinShards(enc, reconstructShards, meta, joinBar)
+	if err != nil {
 		return nil, err
 	}
+	joinBar.Finish()
+	return data, nil
+}
+
+// joinShards joins reconstructed data shards into the original file
+// Shards that do not match the recorded shard size (after trimming alignment padding), or that hold fewer bytes than the
+// recorded original size, indicate metadata that does not describe these shards and are
+// reported as errors.ErrSizeMismatch rather than producing output of the wrong length.
+// Every byte joined is also written to progress.
+func joinShards(enc reedsolomon.Encoder, shards [][]byte, meta domain.ObjectMetadata, progress io.Writer) ([]byte, error) {
+	dataShards := len(shards) - meta.ParityShards
+	if size := meta.EncodedShardSize(); size > 0 {
+		for i, shard := range shards[:dataShards] {
+			if int64(len(shard)) != size {
+				return nil, fmt.Errorf("%w: shard %d is %d bytes, metadata records %d", errors.ErrSizeMismatch, i, len(shard), size)
+			}
+		}
+	}
 
 	var buf bytes.Buffer
-	if err := enc.Join(&buf, reconstructShards, int(meta.OriginalSize)); err != nil {
+	if err := enc.Join(io.MultiWriter(&buf, progress), shards, int(meta.OriginalSize)); err != nil {
+		if err == reedsolomon.ErrShortData {
+			return nil, fmt.Errorf("%w: %d data shards hold fewer than the %d bytes metadata records", errors.ErrSizeMismatch, dataShards, meta.OriginalSize)
+		}
 		return nil, err
 	}
-
 	return buf.Bytes(), nil
 }
+
+// verifyReconstructedSize checks that a reconstructed file has the size recorded in its metadata
+func verifyReconstructedSize(data []byte, meta domain.ObjectMetadata) error {
+	if int64(len(data)) != meta.OriginalSize {
+		return fmt.Errorf("%w: reconstructed %d bytes, metadata records %d", errors.ErrSizeMismatch, len(data), meta.OriginalSize)
+	}
+	return nil
+}
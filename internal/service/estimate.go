@@ -0,0 +1,150 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements cost estimation for uploads.
+//
+// EstimateUpload answers "what will this upload store and cost" from sizing math alone:
+// it applies the same shard layout, minimum shard size and mirror factor an upload would,
+// places the shards with the configured placer, and prices stored bytes and PUT requests
+// with per-provider rates. Nothing is read from or written to the buckets.
+package service
+
+import (
+	"fmt"
+	"sort"
+)
+
+// s3MultipartPartSize is the part size the S3 upload manager uses for bodies larger than one part
+const s3MultipartPartSize = 5 * 1024 * 1024
+
+// ProviderRates are the prices used to estimate costs for one storage provider
+type ProviderRates struct {
+	StoragePerGBMonth float64 // Price of storing 1 GiB for a month
+	PerThousandPuts   float64 // Price of 1000 PUT (write) requests
+}
+
+// BucketEstimate is the share of an upload stored in one bucket
+type BucketEstimate struct {
+	BucketName  string
+	StorageType string
+	Shards      int   // Shard copies stored in the bucket
+	Bytes       int64 // Bytes stored, including parity and padding
+	Puts        int   // Write requests needed to store the shards
+	StorageCost float64
+	RequestCost float64
+}
+
+// ProviderEstimate totals the bucket estimates of one provider
+type ProviderEstimate struct {
+	StorageType string
+	Buckets     int
+	Bytes       int64
+	Puts        int
+	StorageCost float64
+	RequestCost float64
+	Priced      bool // False when no rates are configured for the provider
+}
+
+// UploadEstimate is the projected footprint and cost of uploading one object
+type UploadEstimate struct {
+	OriginalSize   int64
+	DataShards     int // Effective data shards after applying the minimum shard size
+	ParityShards   int
+	Copies         int   // Copies of each shard (mirror factor)
+	ShardSize      int64 // Bytes per shard, including padding
+	PaddingBytes   int64 // Zero bytes added to fill the last data shard
+	AlignmentBytes int64 // Zero bytes added to each shard to reach the shard alignment
+	StoredBytes    int64 // Total bytes stored across all buckets
+	Puts           int
+	Buckets        []BucketEstimate   // Ordered by bucket name
+	Providers      []ProviderEstimate // Ordered by storage type
+	StorageCost    float64            // Monthly storage cost
+	RequestCost    float64            // One-time cost of the upload requests
+}
+
+// EstimateUpload estimates the stored bytes, write requests and cost of uploading size bytes
+// rates are keyed by storage type ("s3", "gcs", ...); providers without rates are reported
+// with zero cost and Priced set to false.
+func (s *FileService) EstimateUpload(size int64, dataShards, parityShards int, rates map[string]ProviderRates) (UploadEstimate, error) {
+	if size <= 0 {
+		return UploadEstimate{}, fmt.Errorf("cannot estimate an empty upload")
+	}
+	if err := s.checkPlacement(dataShards, parityShards); err != nil {
+		return UploadEstimate{}, err
+	}
+
+	dataShards = EffectiveDataShards(size, dataShards, s.minShardSize)
+	copies := max(s.mirrorFactor, 1)
+	encodedSize := (size + int64(dataShards) - 1) / int64(dataShards)
+	shardSize := encodedSize
+	if s.shardAlignment > 1 {
+		shardSize = (encodedSize + s.shardAlignment - 1) / s.shardAlignment * s.shardAlignment
+	}
+	estimate := UploadEstimate{
+		OriginalSize:   size,
+		DataShards:     dataShards,
+		ParityShards:   parityShards,
+		Copies:         copies,
+		ShardSize:      shardSize,
+		PaddingBytes:   encodedSize*int64(dataShards) - size,
+		AlignmentBytes: shardSize - encodedSize,
+	}
+
+	byBucket := make(map[string]*BucketEstimate)
+	for i := 0; i < dataShards+parityShards; i++ {
+		placements, err := s.placeCopies(s.placer, i, copies)
+		if err != nil {
+			return UploadEstimate{}, err
+		}
+		for _, placed := range placements {
+			bucket, ok := byBucket[placed.bucketName]
+			if !ok {
+				bucket = &BucketEstimate{BucketName: placed.bucketName, StorageType: placed.repo.GetStorageType()}
+				byBucket[placed.bucketName] = bucket
+			}
+			bucket.Shards++
+			bucket.Bytes += shardSize
+			bucket.Puts += putRequests(bucket.StorageType, shardSize)
+		}
+	}
+
+	byProvider := make(map[string]*ProviderEstimate)
+	for _, bucket := range byBucket {
+		rate, priced := rates[bucket.StorageType]
+		bucket.StorageCost = float64(bucket.Bytes) / (1 << 30) * rate.StoragePerGBMonth
+		bucket.RequestCost = float64(bucket.Puts) / 1000 * rate.PerThousandPuts
+
+		provider, ok := byProvider[bucket.StorageType]
+		if !ok {
+			provider = &ProviderEstimate{StorageType: bucket.StorageType, Priced: priced}
+			byProvider[bucket.StorageType] = provider
+		}
+		provider.Buckets++
+		provider.Bytes += bucket.Bytes
+		provider.Puts += bucket.Puts
+		provider.StorageCost += bucket.StorageCost
+		provider.RequestCost += bucket.RequestCost
+
+		estimate.StoredBytes += bucket.Bytes
+		estimate.Puts += bucket.Puts
+		estimate.StorageCost += bucket.StorageCost
+		estimate.RequestCost += bucket.RequestCost
+		estimate.Buckets = append(estimate.Buckets, *bucket)
+	}
+	for _, provider := range byProvider {
+		estimate.Providers = append(estimate.Providers, *provider)
+	}
+
+	sort.Slice(estimate.Buckets, func(i, j int) bool { return estimate.Buckets[i].BucketName < estimate.Buckets[j].BucketName })
+	sort.Slice(estimate.Providers, func(i, j int) bool { return estimate.Providers[i].StorageType < estimate.Providers[j].StorageType })
+	return estimate, nil
+}
+
+// putRequests returns the write requests needed to store one shard
+// The S3 upload manager switches to multipart uploads above one part, which costs an
+// initiate and a complete request on top of one request per part.
+func putRequests(storageType string, shardSize int64) int {
+	if storageType == "s3" && shardSize > s3MultipartPartSize {
+		parts := (shardSize + s3MultipartPartSize - 1) / s3MultipartPartSize
+		return int(parts) + 2
+	}
+	return 1
+}
@@ -22,11 +22,15 @@
 package service
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	stderrors "errors"
 	"fmt"
 	"hash/crc64"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -37,6 +41,7 @@ import (
 	"github.com/zzenonn/zstore/internal/domain"
 	"github.com/zzenonn/zstore/internal/errors"
 	"github.com/zzenonn/zstore/internal/placement"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
 )
 
 type MetadataRepository interface {
@@ -47,10 +52,86 @@ type MetadataRepository interface {
 	DeleteMetadata(ctx context.Context, prefix, fileName string) error
 }
 
+// SortedMetadataLister is implemented by metadata stores that can order listings themselves
+type SortedMetadataLister interface {
+	ListMetadataSorted(ctx context.Context, prefix string, sortBy domain.MetadataSortField, order domain.SortOrder) ([]domain.ObjectMetadata, error)
+}
+
+// PrefixLister is implemented by metadata stores that can enumerate the prefixes holding objects
+// ListPrefixes returns prefix and every prefix below it ("prefix/...") that holds objects.
+type PrefixLister interface {
+	ListPrefixes(ctx context.Context, prefix string) ([]string, error)
+}
+
+// MetadataTransactor is implemented by metadata stores that can apply several writes atomically
+// Transact stores every record in puts and removes every record in deletes, or changes
+// nothing if any of them fails, so an object is never visible at two keys or at none
+// while its metadata moves between them.
+type MetadataTransactor interface {
+	Transact(ctx context.Context, puts []domain.ObjectMetadata, deletes []domain.MetadataKey) error
+}
+
 type FileService struct {
 	placer       placement.Placer
 	metadataRepo MetadataRepository
-	concurrency  int
+	concurrency  int // Shared default for shard transfers in both directions
+	minShardSize int64 // Shards smaller than this trigger fewer data shards (0 disables)
+	shardAlignment int64 // Shards are padded to a multiple of this many bytes (0 disables)
+	compression    string // Compression applied to uploads before sharding (empty disables)
+	encryptionKey  []byte // Client-side AES-256-GCM key for uploads and downloads (nil disables)
+	maxObjectSize int64 // Uploads larger than this are rejected (0 disables)
+
+	uploadConcurrency     int // Concurrent shard uploads when an upload passes none (0 uses concurrency)
+	downloadConcurrency   int // Concurrent shard downloads (0 uses concurrency)
+	deleteConcurrency     int // Objects deleted at once by DeletePrefix and Prune (0 uses concurrency)
+	initialDownloadBuffer int // Shards beyond the needed ones a download starts with (negative starts downloadConcurrency)
+
+	downloadSlots         chan struct{} // Semaphore limiting concurrent downloads (nil is unlimited, see download_limit.go)
+	rejectExcessDownloads bool          // Downloads beyond the limit fail instead of queuing
+
+	encoderGoroutines int // Goroutines each Reed-Solomon encode is split into (0 sizes them from the shard size and CPU count)
+
+	writeBufferSize int // Downloads write the object in buffered chunks of this size (0 writes it in one call)
+
+	tempDir       string // Directory for shard temp files (empty uses the system temp directory)
+	tempPrefix    string // Name prefix of shard temp files, so sweeps only touch zstore's files
+	keepTempFiles bool   // Leave shard temp files in place after a download and log where they are
+
+	maxShardFailures int // Shard upload failures tolerated per upload (negative uses the parity count)
+	mirrorFactor     int // Copies stored per shard, each in a different bucket (1 stores a single copy)
+
+	shardObserver func(ShardTiming) // Receives per-shard transfer timings (nil disables)
+
+	retention objectstore.Retention // Object lock applied to uploaded shards (zero value disables)
+
+	manifestOnUpload bool              // Store key/.manifest.json after each successful upload
+	manifestBucket   string            // Bucket holding manifests (empty uses the first registered bucket)
+	signingKey       *ecdsa.PrivateKey // Signs manifests and verifies them on read (nil leaves them unsigned)
+
+	resumeDownloads bool // Keep downloaded shards in a cache so a failed download resumes (see resume_cache.go)
+
+	softDelete     bool          // DeleteFile moves objects to the trash instead of removing them (see trash.go)
+	trashRetention time.Duration // How long objects in the trash can be restored (0 keeps them until purged)
+
+	progressWriter io.Writer // Where reconstruction and write progress goes (nil uses stderr, see reconstruct_progress.go)
+
+	caseInsensitiveKeys bool // Keys and prefixes are lower-cased before use (see key_case.go)
+
+	metadataWriteAttempts int           // Attempts at an upload's metadata write before its shards are rolled back (see upload_rollback.go)
+	metadataWriteDelay    time.Duration // Backoff before the first retry of that write, doubled for each further one
+
+	auditLogger AuditLogger // Records uploads, downloads and deletes (nil disables auditing, see audit.go)
+	auditUser   string      // User operations are attributed to when their context names none
+
+	reconstructionCache *ReconstructionCache // Local copies of reconstructed objects (nil disables, see reconstruction_cache.go)
+
+	verifySampleRate float64 // Fraction of shards hash-checked on reads that do not ask for verification (see verify_sampling.go)
+
+	indexedShardKeys bool // Name new shards with their index as well as their hash (see shard_keys.go)
+
+	uploadLogDir string // Directory recording uploads in progress for crash recovery (empty disables, see upload_log.go)
+
+	drService *RawFileService // Writes and reads whole-object DR copies (nil disables them, see dr_replication.go)
 }
 
 // NewFileService creates a new FileService instance
@@ -59,19 +140,89 @@ func NewFileService(placer placement.Placer, metadataRepo MetadataRepository) *F
 		placer:       placer,
 		metadataRepo: metadataRepo,
 		concurrency:  1,
+		tempPrefix:   DefaultTempFilePrefix,
+
+		maxShardFailures: -1,
+		mirrorFactor:     1,
+
+		initialDownloadBuffer: -1,
+
+		metadataWriteAttempts: DefaultMetadataWriteAttempts,
+		metadataWriteDelay:    DefaultMetadataWriteDelay,
 	}
 }
 
 // UploadFile uploads a file across multiple cloud storage buckets
+// A concurrency of 0 or less uses the service's upload concurrency (see SetUploadConcurrency).
+// Readers that cannot seek can be wrapped with objectstore.WithSizeHint when their length is
+// known, so the object is buffered without regrowing and an oversized one fails before it is read.
 func (s *FileService) UploadFile(ctx context.Context, key string, r io.Reader, quiet bool, dataShards, parityShards, concurrency int) error {
+	return s.UploadNamedFile(ctx, key, "", r, quiet, dataShards, parityShards, concurrency)
+}
+
+// UploadNamedFile uploads a file and records the name it had before upload
+// The original name (for example "report.pdf" stored under the key "x/report") lets
+// downloads into a directory restore the file's extension. An empty name records nothing.
+func (s *FileService) UploadNamedFile(ctx context.Context, key, originalName string, r io.Reader, quiet bool, dataShards, parityShards, concurrency int) error {
+	return s.uploadFile(ctx, key, fileAttributes{name: originalName}, r, quiet, dataShards, parityShards, concurrency)
+}
+
+// UploadLocalFile uploads a local file and records its name, permission bits and
+// modification time from info, so RestoreFileAttributes can reapply them after a download.
+func (s *FileService) UploadLocalFile(ctx context.Context, key string, info fs.FileInfo, r io.Reader, quiet bool, dataShards, parityShards, concurrency int) error {
+	attributes := fileAttributes{
+		name:    info.Name(),
+		mode:    info.Mode() & preservedModeBits,
+		modTime: info.ModTime(),
+	}
+	return s.uploadFile(ctx, key, attributes, r, quiet, dataShards, parityShards, concurrency)
+}
+
+// preservedModeBits are the file mode bits recorded at upload and restored on download
+const preservedModeBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// fileAttributes describe the local file an upload came from; zero values record nothing
+type fileAttributes struct {
+	name    string
+	mode    fs.FileMode
+	modTime time.Time
+	members func() []domain.ArchiveMember // Lists an archive's files once its content has been read (nil for other uploads)
+}
+
+// uploadFile shards, uploads and records an object with the given source file attributes
+func (s *FileService) uploadFile(ctx context.Context, key string, attributes fileAttributes, r io.Reader, quiet bool, dataShards, parityShards, concurrency int) (err error) {
+	displayKey := key
+	key = s.NormalizeKey(key)
 	start := time.Now()
+	var size int64
+	defer func() { s.audit(ctx, domain.AuditUpload, key, size, start, err) }()
 
-	// Read file data
+	// Fail before reading or sharding anything if the shards cannot be placed
+	if err := s.checkUploadPlacement(ctx, dataShards, parityShards); err != nil {
+		return err
+	}
+	if err := s.checkDRReplication(ctx); err != nil {
+		return err
+	}
+
+	// Overwriting deletes the current shards, which a retention lock forbids
+	var previousDRCopy *domain.Location
+	if existing, err := s.metadataRepo.GetMetadata(ctx, filepath.Dir(key), filepath.Base(key)); err == nil {
+		if err := checkRetention(existing); err != nil {
+			return err
+		}
+		previousDRCopy = existing.DRCopy
+	}
+
+	// Read file data, refusing oversized input before buffering it
+	progress := progressReporter(ctx)
+	progress.StartPhase(PhaseReading, 0, 0)
 	readStart := time.Now()
-	data, err := io.ReadAll(r)
+	data, err := s.readObject(r)
 	if err != nil {
 		return err
 	}
+	size = int64(len(data))
 	log.Debugf("File read took: %v", time.Since(readStart))
 
 	// Check for empty file
@@ -79,12 +230,34 @@ func (s *FileService) UploadFile(ctx context.Context, key string, r io.Reader, q
 		return errors.ErrEmptyFile
 	}
 
+	// Compress, then encrypt, the content; shards hold the result
+	content := data
+	data, transforms, err := s.encodeContent(data, attributes.members != nil)
+	if err != nil {
+		return err
+	}
+
+	// Avoid tiny shards whose request overhead would dwarf their data
+	if effective := EffectiveDataShards(int64(len(data)), dataShards, s.minShardSize); effective != dataShards {
+		log.Debugf("Reducing data shards from %d to %d to keep shards >= %d bytes", dataShards, effective, s.minShardSize)
+		dataShards = effective
+	}
+
+	maxFailures, err := s.shardFailureTolerance(parityShards)
+	if err != nil {
+		return err
+	}
+
 	// Create shards using erasure coding
+	progress.StartPhase(PhaseEncoding, size, 0)
 	shardStart := time.Now()
-	metadata, shards, err := ShardFile(data, dataShards, parityShards)
+	metadata, shards, err := ShardFileConcurrent(data, dataShards, parityShards, s.encoderGoroutines)
 	if err != nil {
 		return err
 	}
+	metadata, shards = AlignShards(metadata, shards, s.shardAlignment)
+	recordTransforms(&metadata, content, transforms)
+	progress.Advance(size, 0)
 	log.Debugf("Sharding took: %v", time.Since(shardStart))
 
 	log.Debugf("Uploading %s", key)
@@ -94,212 +267,806 @@ func (s *FileService) UploadFile(ctx context.Context, key string, r io.Reader, q
 
 	metadata.Prefix = prefix
 	metadata.FileName = filepath.Base(key)
+	metadata.CreatedAt = time.Now().UTC()
+	if attributes.name != "" {
+		metadata.OriginalName = filepath.Base(attributes.name)
+	}
+	if displayKey != key {
+		metadata.DisplayKey = displayKey
+	}
+	if s.retention.Enabled() {
+		metadata.RetentionMode = string(s.retention.Mode)
+		metadata.RetainUntil = s.retention.RetainUntil.UTC()
+	}
+	metadata.FileMode = attributes.mode
+	if !attributes.modTime.IsZero() {
+		metadata.ModTime = attributes.modTime.UTC()
+	}
+	if attributes.members != nil {
+		metadata.ArchiveMembers = attributes.members()
+	}
+
+	// Record the upload's intent so a crash from here on can be recovered
+	ctx, uploadLog, err := s.beginUploadLog(ctx, key)
+	if err != nil {
+		return err
+	}
 
 	// Delete prefix contents if it exists from all buckets
 	deleteStart := time.Now()
-	buckets := s.placer.ListBuckets()
-	for _, bucketName := range buckets {
-		if repo, err := s.placer.GetRepositoryForBucket(bucketName); err == nil {
-			repo.DeletePrefix(ctx, key) // Ignore errors
-		}
+	s.deleteContentAddressedShards(ctx, key)
+	if err := s.deletePrefixFromAllBuckets(ctx, shardPrefix(key)); err != nil {
+		log.Debugf("Ignoring errors while clearing existing shards for %s: %v", key, err)
 	}
 	log.Debugf("Delete prefix took: %v", time.Since(deleteStart))
 
 	// Upload shards in parallel
 	uploadStart := time.Now()
-	if err := s.uploadShards(ctx, key, shards, &metadata, quiet, concurrency, parityShards); err != nil {
+	if concurrency <= 0 {
+		concurrency = s.uploadWorkers()
+	}
+	if err := s.uploadShards(ctx, key, shards, &metadata, quiet, concurrency, maxFailures); err != nil {
+		// The shards that were stored would be unreachable without metadata
+		s.rollbackShards(ctx, key, metadata)
+		uploadLog.finish()
 		return err
 	}
 	log.Debugf("Shard uploads took: %v", time.Since(uploadStart))
+	if err := uploadLog.markStored(metadata); err != nil {
+		s.rollbackShards(ctx, key, metadata)
+		uploadLog.finish()
+		return err
+	}
 
-	// Store metadata
+	// Store metadata, removing the shards again if it cannot be stored
+	progress.StartPhase(PhaseRecording, 0, 0)
 	metadataStart := time.Now()
-	_, err = s.metadataRepo.CreateMetadata(ctx, metadata)
+	err = s.storeUploadMetadata(ctx, key, metadata)
 	log.Debugf("Metadata storage took: %v", time.Since(metadataStart))
+	uploadLog.finish()
+	if err != nil {
+		return err
+	}
+
+	// Store a whole copy in the DR bucket when asked, for objects that must survive losing too many shards
+	if err := s.replicateToDR(ctx, key, data, &metadata, previousDRCopy, quiet); err != nil {
+		return fmt.Errorf("object stored but its DR copy could not be written: %w", err)
+	}
+
+	// Store a self-describing manifest for recovery without the metadata store
+	if s.manifestOnUpload {
+		if err := s.writeManifest(ctx, key, metadata); err != nil {
+			return fmt.Errorf("object stored but manifest could not be written: %w", err)
+		}
+	}
 	log.Debugf("Total upload took: %v", time.Since(start))
-	return err
+	return nil
+}
+
+// readObject reads an upload's content, enforcing the maximum object size
+// A seekable reader is measured first, and a reader with a size hint trusted for its
+// length, so an oversized file fails before anything is read. Other readers are read
+// through a limit one byte past the maximum, so at most that much is buffered before
+// the upload is aborted.
+func (s *FileService) readObject(r io.Reader) ([]byte, error) {
+	size := int64(-1)
+	if seeker, ok := r.(io.Seeker); ok {
+		if remaining, err := remainingSize(seeker); err == nil {
+			size = remaining
+		}
+	} else if hinter, ok := r.(objectstore.SizeHinter); ok {
+		size = hinter.SizeHint()
+	}
+
+	if s.maxObjectSize <= 0 {
+		return readSized(r, size)
+	}
+	if size > s.maxObjectSize {
+		return nil, fmt.Errorf("%w: %d bytes exceeds the limit of %d bytes", errors.ErrObjectTooLarge, size, s.maxObjectSize)
+	}
+
+	data, err := readSized(io.LimitReader(r, s.maxObjectSize+1), size)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.maxObjectSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", errors.ErrObjectTooLarge, s.maxObjectSize)
+	}
+	return data, nil
+}
+
+// readSized reads r to its end into a buffer sized for the expected length (negative if unknown)
+// The buffer has a spare byte so the end of the reader is seen without growing it; a reader
+// longer than expected grows it as io.ReadAll would. Preallocation is capped so a wrong
+// hint cannot reserve more than maxReadPrealloc up front.
+func readSized(r io.Reader, size int64) ([]byte, error) {
+	if size < 0 {
+		return io.ReadAll(r)
+	}
+	data := make([]byte, 0, min(size, maxReadPrealloc)+1)
+	for {
+		n, err := r.Read(data[len(data):cap(data)])
+		data = data[:len(data)+n]
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return data, err
+		}
+		if len(data) == cap(data) {
+			data = append(data, 0)[:len(data)]
+		}
+	}
+}
+
+// maxReadPrealloc caps the buffer readSized reserves before reading
+const maxReadPrealloc = 1 << 30
+
+// remainingSize returns the bytes between a seeker's current offset and its end, leaving the offset unchanged
+func remainingSize(seeker io.Seeker) (int64, error) {
+	current, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := seeker.Seek(current, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return end - current, nil
 }
 
 // DownloadFile downloads a file from cloud storage
-func (s *FileService) DownloadFile(ctx context.Context, key string, dest io.WriterAt, quiet bool, verifyIntegrity bool) error {
-	// Get prefix and filename for metadata lookup
-	prefix := filepath.Dir(key)
-	fileName := filepath.Base(key)
+func (s *FileService) DownloadFile(ctx context.Context, key string, dest io.WriterAt, quiet bool, verifyIntegrity bool) (err error) {
+	key = s.NormalizeKey(key)
+	start := time.Now()
+	var size int64
+	defer func() { s.audit(ctx, domain.AuditDownload, key, size, start, err) }()
+
+	if len(s.placer.ListBuckets()) == 0 {
+		return errors.ErrNoBucketsRegistered
+	}
 
-	// Get metadata
-	metadata, err := s.metadataRepo.GetMetadata(ctx, prefix, fileName)
+	metadata, err := s.lookupMetadata(ctx, key)
 	if err != nil {
 		return err
 	}
+	size = metadata.ContentSize()
+	return s.downloadObject(ctx, metadata, dest, quiet, verifyIntegrity)
+}
+
+// DownloadFileIfModified downloads a file unless its current ETag equals knownETag
+// The current ETag is returned either way; when it matches, nothing is downloaded and
+// errors.ErrNotModified is returned. An empty knownETag always downloads.
+// Only downloads that transfer the object are audited.
+func (s *FileService) DownloadFileIfModified(ctx context.Context, key string, knownETag string, dest io.WriterAt, quiet bool, verifyIntegrity bool) (string, error) {
+	key = s.NormalizeKey(key)
+	start := time.Now()
+	if len(s.placer.ListBuckets()) == 0 {
+		s.audit(ctx, domain.AuditDownload, key, 0, start, errors.ErrNoBucketsRegistered)
+		return "", errors.ErrNoBucketsRegistered
+	}
+
+	metadata, err := s.lookupMetadata(ctx, key)
+	if err != nil {
+		s.audit(ctx, domain.AuditDownload, key, 0, start, err)
+		return "", err
+	}
+	etag := metadata.ETag()
+	if knownETag != "" && knownETag == etag {
+		return etag, errors.ErrNotModified
+	}
+	err = s.downloadObject(ctx, metadata, dest, quiet, verifyIntegrity)
+	s.audit(ctx, domain.AuditDownload, key, metadata.ContentSize(), start, err)
+	return etag, err
+}
+
+// RestoreFileAttributes applies the permission bits and modification time recorded at
+// upload to a downloaded file. Attributes that were not recorded (for example because the
+// object was uploaded from a stream) are left as they are.
+func (s *FileService) RestoreFileAttributes(ctx context.Context, key, path string) error {
+	key = s.NormalizeKey(key)
+	metadata, err := s.lookupMetadata(ctx, key)
+	if err != nil {
+		return err
+	}
+	if metadata.FileMode != 0 {
+		if err := os.Chmod(path, metadata.FileMode&preservedModeBits); err != nil {
+			return fmt.Errorf("failed to restore mode of %s: %w", path, err)
+		}
+	}
+	if !metadata.ModTime.IsZero() {
+		if err := os.Chtimes(path, time.Time{}, metadata.ModTime); err != nil {
+			return fmt.Errorf("failed to restore modification time of %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// lookupMetadata reads an object's metadata, falling back to its manifest if the lookup fails
+func (s *FileService) lookupMetadata(ctx context.Context, key string) (domain.ObjectMetadata, error) {
+	metadata, err := s.metadataRepo.GetMetadata(ctx, filepath.Dir(key), filepath.Base(key))
+	if err == nil && metadata.Deleted {
+		// The manifest of an object in the trash is kept, but must not bring it back
+		return domain.ObjectMetadata{}, fmt.Errorf("%w: %s is in the trash", errors.ErrMetadataNotFound, key)
+	}
+	if err != nil {
+		manifestMetadata, manifestErr := s.readManifest(ctx, key)
+		if manifestErr != nil {
+			log.Debugf("Manifest fallback for %s failed: %v", key, manifestErr)
+			return domain.ObjectMetadata{}, err
+		}
+		log.Warnf("Metadata lookup for %s failed (%v); using stored manifest", key, err)
+		metadata = manifestMetadata
+	}
 
 	log.Debugf("Object Metadata: %+v\n", metadata)
+	return metadata, nil
+}
+
+// downloadObject reconstructs an object and writes it to dest
+func (s *FileService) downloadObject(ctx context.Context, metadata domain.ObjectMetadata, dest io.WriterAt, quiet bool, verifyIntegrity bool) error {
+	ctx, release, err := s.AcquireDownloadSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	reconstructedData, err := s.objectContent(ctx, metadata, quiet, verifyIntegrity)
+	if err != nil {
+		return err
+	}
+
+	// Write reconstructed data to destination
+	progress := progressReporter(ctx)
+	progress.StartPhase(PhaseWriting, int64(len(reconstructedData)), 0)
+	return s.writeReconstructed(progressWriterAtReporter{dest: dest, reporter: progress}, reconstructedData, quiet)
+}
+
+// writeBuffered writes data to dest through a bufio.Writer of the given size
+// Data is fed to the buffer in chunks of at most size bytes, so the destination sees a
+// steady sequence of writes no larger than the buffer instead of one huge write, which
+// smooths throughput to slow or high-latency destinations such as NFS-backed files.
+func writeBuffered(dest io.WriterAt, data []byte, size int) error {
+	writer := bufio.NewWriterSize(io.NewOffsetWriter(dest, 0), size)
+	for offset := 0; offset < len(data); offset += size {
+		end := min(offset+size, len(data))
+		if _, err := writer.Write(data[offset:end]); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// reconstructObject downloads enough shards of an object and rebuilds its original bytes
+func (s *FileService) reconstructObject(ctx context.Context, metadata domain.ObjectMetadata, quiet bool, verifyIntegrity bool) (data []byte, err error) {
+	ctx, release, err := s.AcquireDownloadSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := s.checkProviderFilter(ctx, metadata); err != nil {
+		return nil, err
+	}
 
 	// Download shards to temporary files
+	progress := progressReporter(ctx)
+	dataShards := len(metadata.ShardHashes) - metadata.ParityShards
+	progress.StartPhase(PhaseDownloading, int64(dataShards)*metadata.ShardSize, dataShards)
 	tempFilePaths, err := s.downloadShards(ctx, metadata.ShardHashes, metadata.ParityShards, quiet, verifyIntegrity)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Cleanup temp files when done
+	// Cleanup temp files when done; a resumable download keeps them unless it succeeds
 	defer func() {
-		for _, path := range tempFilePaths {
-			os.Remove(path)
-		}
+		s.releaseTempFiles(tempFilePaths, err != nil)
 	}()
 
 	// Reconstruct file from temp files
-	reconstructedData, err := ReconstructFileFromPaths(tempFilePaths, metadata)
+	progress.StartPhase(PhaseReconstructing, 0, 0)
+	data, err = reconstructFileFromPaths(tempFilePaths, metadata, s.reconstructionProgress(quiet))
+	if err == nil && metadata.FileHash != "" && verifyFileIntegrity(data, metadata.FileHash) != nil {
+		// A shard passed its own check but corrupted the file; fetch every shard and retry with subsets
+		log.Warnf("Reconstructed %s/%s failed the whole-file integrity check; retrying with additional shards", metadata.Prefix, metadata.FileName)
+		shards := s.downloadAllShards(ctx, metadata.ShardHashes, tempFilePaths, verifyIntegrity)
+		data, err = ReconstructVerifiedFile(shards, metadata)
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Write reconstructed data to destination
-	_, err = dest.WriteAt(reconstructedData, 0)
-	return err
+	// Nothing is written unless the output has exactly the recorded size
+	if err := verifyReconstructedSize(data, metadata); err != nil {
+		return nil, err
+	}
+	if s.resumeDownloads && !s.keepTempFiles {
+		if err := os.RemoveAll(s.resumeCacheDir(metadata.ShardHashes)); err != nil {
+			log.Warnf("Failed to remove resume cache of %s/%s: %v", metadata.Prefix, metadata.FileName, err)
+		}
+	}
+	return data, nil
+}
+
+// removeTempFiles removes the staged shard files of a download
+func removeTempFiles(tempFilePaths []string) {
+	for _, path := range tempFilePaths {
+		if path != "" {
+			os.Remove(path)
+		}
+	}
+}
+
+// downloadAllShards loads every shard that can be read, reusing already downloaded temp files
+// Shards that fail to download (or fail verification when enabled) are left nil.
+func (s *FileService) downloadAllShards(ctx context.Context, shardHashes []domain.ShardStorage, tempFilePaths []string, verifyIntegrity bool) [][]byte {
+	shards := make([][]byte, len(shardHashes))
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, s.downloadWorkers())
+	for i, shardInfo := range shardHashes {
+		if i < len(tempFilePaths) && tempFilePaths[i] != "" {
+			if data, err := os.ReadFile(tempFilePaths[i]); err == nil {
+				shards[i] = data
+				continue
+			}
+		}
+
+		wg.Add(1)
+		go func(i int, shardInfo domain.ShardStorage) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			data, err := s.downloadShardCopy(ctx, shardInfo, verifyIntegrity)
+			if err != nil {
+				log.Debugf("Shard %d: unavailable during recovery: %v", i, err)
+				return
+			}
+			shards[i] = data
+		}(i, shardInfo)
+	}
+	wg.Wait()
+
+	return shards
 }
 
 // DeleteFile deletes a file from cloud storage
-func (s *FileService) DeleteFile(ctx context.Context, key string) error {
+// With soft delete enabled the file is moved to the trash instead (see trash.go).
+func (s *FileService) DeleteFile(ctx context.Context, key string) (err error) {
+	key = s.NormalizeKey(key)
+	start := time.Now()
+	defer func() { s.audit(ctx, domain.AuditDelete, key, 0, start, err) }()
+
+	defer s.invalidateCachedObject(key)
+	if s.softDelete {
+		return s.trashFile(ctx, key)
+	}
+	return s.removeFile(ctx, key)
+}
+
+// removeFile deletes a file's shards and metadata
+func (s *FileService) removeFile(ctx context.Context, key string) error {
+	prefix := filepath.Dir(key)
+	fileName := filepath.Base(key)
+
+	// Locked shards must not be deleted before their retention period ends
+	var drCopy *domain.Location
+	if metadata, err := s.metadataRepo.GetMetadata(ctx, prefix, fileName); err == nil {
+		if err := checkRetention(metadata); err != nil {
+			return err
+		}
+		drCopy = metadata.DRCopy
+	}
+
 	// Delete all shards using prefix from all buckets
 	log.Debugf("Deleting Key %s", key)
+	s.deleteContentAddressedShards(ctx, key)
+	if err := s.deletePrefixFromAllBuckets(ctx, shardPrefix(key)); err != nil {
+		log.Warnf("Some shards of %s could not be deleted: %v", key, err)
+	}
+	if drCopy != nil {
+		s.deleteDRCopy(ctx, key, *drCopy)
+	}
+
+	// Delete metadata
+	return s.metadataRepo.DeleteMetadata(ctx, prefix, fileName)
+}
+
+// checkRetention returns errors.ErrObjectLocked if an object's shards are still under retention
+func checkRetention(metadata domain.ObjectMetadata) error {
+	if metadata.RetainUntil.IsZero() || !time.Now().Before(metadata.RetainUntil) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s/%s is retained in %s mode until %s", errors.ErrObjectLocked,
+		metadata.Prefix, metadata.FileName, strings.ToLower(metadata.RetentionMode), metadata.RetainUntil.Format(time.RFC3339))
+}
+
+// deletePrefixFromAllBuckets deletes a prefix from every registered bucket concurrently
+// Errors from individual buckets are aggregated so one failing bucket does not
+// prevent cleanup of the others.
+func (s *FileService) deletePrefixFromAllBuckets(ctx context.Context, prefix string) error {
 	buckets := s.placer.ListBuckets()
+
+	var wg sync.WaitGroup
+	errorCh := make(chan error, len(buckets))
 	for _, bucketName := range buckets {
 		repo, err := s.placer.GetRepositoryForBucket(bucketName)
 		if err != nil {
 			continue // Skip failed buckets
 		}
-		repo.DeletePrefix(ctx, key)
+		wg.Add(1)
+		go func(bucketName string) {
+			defer wg.Done()
+			if err := repo.DeletePrefix(ctx, prefix); err != nil {
+				errorCh <- fmt.Errorf("bucket %s: %w", bucketName, err)
+			}
+		}(bucketName)
+	}
+	wg.Wait()
+	close(errorCh)
+
+	var errs []error
+	for err := range errorCh {
+		errs = append(errs, err)
+	}
+	return stderrors.Join(errs...)
+}
+
+// deleteContentAddressedShards removes an object's shards whose keys are not under the object's prefix
+// Content-addressed backends such as IPFS store shards by hash, so prefix deletion cannot find them
+// and each recorded shard is deleted individually. Missing metadata means there is nothing to remove.
+func (s *FileService) deleteContentAddressedShards(ctx context.Context, key string) {
+	metadata, err := s.metadataRepo.GetMetadata(ctx, filepath.Dir(key), filepath.Base(key))
+	if err != nil {
+		return
+	}
+
+	for i, shard := range metadata.ShardHashes {
+		for _, location := range shard.Locations {
+			if strings.HasPrefix(location.Key, shardPrefix(key)) {
+				continue
+			}
+			repo, err := s.placer.GetRepositoryForBucket(location.BucketName)
+			if err != nil {
+				log.Warnf("Could not remove shard %d of %s: %v", i, key, err)
+				continue
+			}
+			if err := repo.Delete(ctx, location.Key); err != nil {
+				log.Warnf("Could not remove shard %d of %s: %v", i, key, err)
+			}
+		}
+	}
+}
+
+// ReEncode changes the shard configuration of a stored object
+// The object is reconstructed, re-sharded with the new parameters and uploaded.
+// New metadata is written only after every new shard is stored, so a failure at
+// any earlier point leaves the original object readable. Old shards are removed last.
+func (s *FileService) ReEncode(ctx context.Context, key string, newDataShards, newParityShards int, quiet bool) error {
+	key = s.NormalizeKey(key)
+	// Reject an impossible layout before reconstructing anything
+	if err := ValidateShardConfig(newDataShards, newParityShards); err != nil {
+		return err
+	}
+
+	oldMetadata, err := s.getLiveMetadata(ctx, key)
+	if err != nil {
+		return err
+	}
+	// Re-encoding replaces the current shards, which a retention lock forbids
+	if err := checkRetention(oldMetadata); err != nil {
+		return err
+	}
+
+	// Rebuild the original object from its current shards
+	data, err := s.reconstructObject(ctx, oldMetadata, quiet, true)
+	if err != nil {
+		return fmt.Errorf("failed to reconstruct %s: %w", key, err)
+	}
+
+	// Re-shard with the new configuration
+	encoded, shards, err := ShardFileConcurrent(data, newDataShards, newParityShards, s.encoderGoroutines)
+	if err != nil {
+		return err
+	}
+	encoded, shards = AlignShards(encoded, shards, s.shardAlignment)
+
+	// Everything but the shard layout carries over: attributes, tags, retention, DR copy
+	newMetadata := oldMetadata
+	newMetadata.OriginalSize = encoded.OriginalSize
+	newMetadata.ShardSize = encoded.ShardSize
+	newMetadata.UnalignedShardSize = encoded.UnalignedShardSize
+	newMetadata.DataShards = encoded.DataShards
+	newMetadata.ParityShards = encoded.ParityShards
+	newMetadata.ShardHashes = encoded.ShardHashes
+	newMetadata.FileHash = encoded.FileHash
+	newMetadata.StorageOverhead = 0 // Recomputed from what uploadShards stores
+
+	// Upload the new shards; the old metadata stays authoritative until this succeeds
+	maxFailures, err := s.shardFailureTolerance(newParityShards)
+	if err != nil {
+		return err
+	}
+	if err := s.uploadShards(ctx, key, shards, &newMetadata, quiet, s.uploadWorkers(), maxFailures); err != nil {
+		s.rollbackShards(ctx, key, newShardsOnly(newMetadata, oldMetadata))
+		return fmt.Errorf("failed to upload re-encoded shards for %s: %w", key, err)
+	}
+
+	if _, err := s.metadataRepo.UpdateMetadata(ctx, newMetadata); err != nil {
+		s.rollbackShards(ctx, key, newShardsOnly(newMetadata, oldMetadata))
+		return fmt.Errorf("failed to update metadata for %s: %w", key, err)
+	}
+	s.invalidateCachedObject(key)
+	if err := s.refreshManifest(ctx, key, newMetadata); err != nil {
+		log.Warnf("Could not update manifest for %s: %v", key, err)
+	}
+
+	// Remove old shards that are not reused by the new layout
+	newLocations := make(map[string]bool, len(newMetadata.ShardHashes))
+	for _, shard := range newMetadata.ShardHashes {
+		for _, location := range shard.Locations {
+			newLocations[location.BucketName+"/"+location.Key] = true
+		}
+	}
+	for i, shard := range oldMetadata.ShardHashes {
+		for _, location := range shard.Locations {
+			if newLocations[location.BucketName+"/"+location.Key] {
+				continue
+			}
+			repo, err := s.placer.GetRepositoryForBucket(location.BucketName)
+			if err != nil {
+				log.Warnf("Could not remove old shard %d of %s: %v", i, key, err)
+				continue
+			}
+			if err := repo.Delete(ctx, location.Key); err != nil {
+				log.Warnf("Could not remove old shard %d of %s: %v", i, key, err)
+			}
+		}
 	}
 
-	// Delete metadata
-	prefix := filepath.Dir(key)
-	fileName := filepath.Base(key)
-	return s.metadataRepo.DeleteMetadata(ctx, prefix, fileName)
+	return nil
+}
+
+// newShardsOnly returns metadata recording only the shard copies of updated that current does not reference
+// Shard keys are derived from the shard hash, so a re-encode can store a shard at the
+// key the current object already uses; rolling that copy back would damage the object.
+func newShardsOnly(updated, current domain.ObjectMetadata) domain.ObjectMetadata {
+	referenced := make(map[string]bool)
+	for _, shard := range current.ShardHashes {
+		for _, location := range shard.Locations {
+			referenced[location.BucketName+"/"+location.Key] = true
+		}
+	}
+	updated.ShardHashes = append([]domain.ShardStorage(nil), updated.ShardHashes...)
+	for i, shard := range updated.ShardHashes {
+		var fresh []domain.Location
+		for _, location := range shard.Locations {
+			if !referenced[location.BucketName+"/"+location.Key] {
+				fresh = append(fresh, location)
+			}
+		}
+		updated.ShardHashes[i].Locations = fresh
+	}
+	return updated
 }
 
 // uploadShards uploads erasure-coded shards in parallel with concurrency control
 // This function implements the core shard upload strategy:
-// 1. Creates goroutines for each shard upload (limited by semaphore)
-// 2. Uses fail-fast logic - stops if too many uploads fail
-// 3. Updates metadata with actual storage locations after successful uploads
-func (s *FileService) uploadShards(ctx context.Context, key string, shards [][]byte, metadata *domain.ObjectMetadata, quiet bool, concurrency, parityShards int) error {
+// 1. Places every copy of every shard (mirror factor copies per shard, each in a distinct bucket)
+// 2. Creates goroutines for each copy upload (limited by semaphore)
+// 3. Tolerates up to maxFailures shards with no stored copy, which are left without a storage location
+// 4. Updates metadata with actual storage locations after successful uploads
+func (s *FileService) uploadShards(ctx context.Context, key string, shards [][]byte, metadata *domain.ObjectMetadata, quiet bool, concurrency, maxFailures int) error {
+	copies := max(s.mirrorFactor, 1)
+
+	placer, err := s.placerFor(ctx)
+	if err != nil {
+		return err
+	}
+	// Decide every placement up front so copies of a shard never share a bucket
+	placements := make([][]shardPlacement, len(shards))
+	for i := range shards {
+		placed, err := s.placeCopies(placer, i, copies)
+		if err != nil {
+			return err
+		}
+		placements[i] = placed
+	}
+	if err := logPlannedShards(ctx, placements, func(i int) string { return s.shardKey(key, i, metadata.ShardHashes[i].Hash) }); err != nil {
+		return err
+	}
+	progress := progressReporter(ctx)
+	progress.StartPhase(PhaseUploading, int64(len(shards)*copies)*metadata.ShardSize, len(shards)*copies)
+
 	// Setup channels for goroutine coordination
 	var wg sync.WaitGroup
-	errorCh := make(chan error, len(shards)) // Buffered to prevent goroutine blocking
-	pathCh := make(chan struct {             // Channel for successful upload results
-		index       int    // Shard index for metadata update
-		storageType string // Storage backend type (e.g., "s3", "gcs")
-		bucketName  string // Cloud storage bucket name
-		key         string // Actual storage key where shard was stored
-	}, len(shards))
+	resultCh := make(chan struct { // Channel for upload results, one per copy
+		index    int                  // Shard index for metadata update
+		copy     int                  // Copy number (0 is the primary)
+		location domain.Location // Where the copy was stored
+		err      error
+	}, len(shards)*copies) // Buffered to prevent goroutine blocking
 	semaphore := make(chan struct{}, concurrency) // Limits concurrent uploads
 
-	// Launch upload goroutines for each shard
+	// Launch upload goroutines for each copy of each shard
 	for i, shard := range shards {
-		wg.Add(1)
-		go func(i int, shard []byte) {
-			defer wg.Done()
-			semaphore <- struct{}{}        // Acquire semaphore slot
-			defer func() { <-semaphore }() // Release semaphore slot
-
-			// Generate shard key using original hash from metadata
-			// Format: "original-file-key/shard-hash"
-			originalHash := metadata.ShardHashes[i].Hash
-			shardKey := fmt.Sprintf("%s/%s", key, originalHash)
-
-			// Select bucket and repository for this shard using placement algorithm
-			bucketName, repo, err := s.placer.Place(i)
-			if err != nil {
-				errorCh <- err
-				return
-			}
-
-			// Upload shard to selected bucket
-			path, err := repo.Upload(ctx, shardKey, bytes.NewReader(shard), quiet)
-			if err != nil {
-				errorCh <- err // Send error to main thread
-				return
-			}
-
-			// Parse returned path to extract actual storage key
-			// Expected format: "bucket/actual-key"
-			parts := strings.SplitN(path, "/", 2)
-			pathCh <- struct {
-				index       int
-				storageType string
-				bucketName  string
-				key         string
-			}{
-				index:       i,
-				storageType: repo.GetStorageType(),
-				bucketName:  bucketName,
-				key:         parts[1], // Extract key part after bucket
-			}
-		}(i, shard)
+		for copyIndex, placed := range placements[i] {
+			wg.Add(1)
+			go func(i, copyIndex int, shard []byte, placed shardPlacement) {
+				defer wg.Done()
+				semaphore <- struct{}{}        // Acquire semaphore slot
+				defer func() { <-semaphore }() // Release semaphore slot
+
+				// Generate shard key using original hash from metadata (see shard_keys.go)
+				shardKey := s.shardKey(key, i, metadata.ShardHashes[i].Hash)
+
+				// Upload shard copy to its bucket
+				uploadStart := time.Now()
+				path, err := placed.repo.Upload(ctx, shardKey, bytes.NewReader(shard), quiet)
+				s.observeShard(ShardTiming{Operation: ShardUpload, Index: i, BucketName: placed.bucketName, Bytes: int64(len(shard)), Duration: time.Since(uploadStart), Err: err})
+
+				result := struct {
+					index    int
+					copy     int
+					location domain.Location
+					err      error
+				}{index: i, copy: copyIndex, err: err}
+				if err == nil {
+					result.location = storedLocation(placed, path)
+					s.recordUpload(placed.bucketName, int64(len(shard)))
+					reportUploadProgress(ctx, metadata.OriginalSize, int64(len(shard)), int64(len(shards)*copies)*metadata.ShardSize)
+					progress.Advance(int64(len(shard)), 1)
+				}
+				resultCh <- result
+			}(i, copyIndex, shard, placed)
+		}
 	}
 
 	// Wait for all uploads to complete
 	wg.Wait()
-	close(errorCh)
-	close(pathCh)
+	close(resultCh)
+
+	// Collect stored copies per shard, keeping copy order so the primary comes first
+	stored := make([][]domain.Location, len(shards))
+	shardErrs := make([]error, len(shards))
+	failedCopies := 0
+	for result := range resultCh {
+		if stored[result.index] == nil {
+			stored[result.index] = make([]domain.Location, copies)
+		}
+		if result.err != nil {
+			failedCopies++
+			if shardErrs[result.index] == nil {
+				shardErrs[result.index] = result.err
+			}
+			continue
+		}
+		stored[result.index][result.copy] = result.location
+	}
+
+	// Shards with no stored copy get another attempt before they count as failed
+	s.reuploadMissingShards(ctx, placer, key, shards, metadata, placements, stored, quiet)
 
-	// Implement fail-fast error handling
-	// Reed-Solomon can tolerate up to 'parityShards' failures
-	// If more than parityShards fail, we cannot guarantee reconstruction
+	// Reed-Solomon can tolerate up to 'parityShards' missing shards, so the
+	// configured tolerance (never above parity) decides whether the upload stands.
+	// A shard only counts as failed when none of its copies could be stored.
 	errorCount := 0
 	var uploadErr error
-	for err := range errorCh {
-		if err != nil {
+	for i := range shards {
+		var locations []domain.Location
+		for _, location := range stored[i] {
+			if location.Key != "" {
+				locations = append(locations, location)
+			}
+		}
+		if len(locations) == 0 {
 			errorCount++
 			if uploadErr == nil {
-				uploadErr = err // Capture first error for reporting
-			}
-			// Fail fast if too many shards failed
-			if errorCount > parityShards {
-				return uploadErr
+				uploadErr = shardErrs[i] // Capture first error for reporting
 			}
+			continue
 		}
+
+		// Update metadata with actual storage locations
+		// This allows the download process to find shards later
+		metadata.ShardHashes[i].Locations = locations
 	}
-	// If we had some failures but within tolerance, still return error
-	if uploadErr != nil {
-		return uploadErr
+	if errorCount > maxFailures {
+		return fmt.Errorf("%d of %d shard uploads failed (tolerance %d): %w", errorCount, len(shards), maxFailures, uploadErr)
 	}
-
-	// Update metadata with actual storage locations
-	// This allows the download process to find shards later
-	for result := range pathCh {
-		metadata.ShardHashes[result.index].StorageType = result.storageType
-		metadata.ShardHashes[result.index].BucketName = result.bucketName
-		metadata.ShardHashes[result.index].Key = result.key
+	if errorCount > 0 {
+		log.Warnf("%d of %d shard uploads failed for %s; stored degraded within tolerance of %d: %v", errorCount, len(shards), key, maxFailures, uploadErr)
+	}
+	if copies > 1 && failedCopies > 0 {
+		log.Warnf("%d of %d shard copies failed for %s; some shards have fewer than %d copies", failedCopies, len(shards)*copies, key, copies)
 	}
 
+	// Record the storage amplification of what was actually stored
+	metadata.StorageOverhead = float64(metadata.StoredBytes()) / float64(metadata.OriginalSize)
 	return nil
 }
 
+// shardPlacement is a bucket chosen for one copy of a shard
+type shardPlacement struct {
+	bucketName string
+	repo       objectstore.ObjectRepository
+}
+
+// storedLocation returns the location of a copy uploaded to placed, from the "bucket/key" path its upload returned
+func storedLocation(placed shardPlacement, path string) domain.Location {
+	parts := strings.SplitN(path, "/", 2)
+	return domain.Location{
+		StorageType: placed.repo.GetStorageType(),
+		BucketName:  placed.bucketName,
+		Key:         parts[1], // Extract key part after bucket
+	}
+}
+
+// placeCopies chooses a distinct bucket for each copy of a shard
+// Copy k normally goes where the placer would put shard index+k, which is a different
+// bucket for round-robin and least-loaded placement; duplicates are skipped.
+func (s *FileService) placeCopies(placer placement.Placer, shardIndex, copies int) ([]shardPlacement, error) {
+	return distinctCopies(shardIndex, copies, len(placer.ListBuckets()), placer.Place)
+}
+
+// recordUpload tells a placer that tracks bucket usage about a stored shard
+func (s *FileService) recordUpload(bucketName string, bytes int64) {
+	if recorder, ok := s.placer.(placement.UsageRecorder); ok {
+		recorder.RecordUpload(bucketName, bytes)
+	}
+}
+
+// distinctCopies applies the placeCopies rule to any placement function
+func distinctCopies(shardIndex, copies, bucketCount int, place func(int) (string, objectstore.ObjectRepository, error)) ([]shardPlacement, error) {
+	placements := make([]shardPlacement, 0, copies)
+	used := make(map[string]bool, copies)
+	for offset := 0; len(placements) < copies && offset < copies+bucketCount; offset++ {
+		bucketName, repo, err := place(shardIndex + offset)
+		if err != nil {
+			return nil, err
+		}
+		if used[bucketName] {
+			continue
+		}
+		used[bucketName] = true
+		placements = append(placements, shardPlacement{bucketName: bucketName, repo: repo})
+	}
+	if len(placements) < copies {
+		return nil, fmt.Errorf("%w: %d copies per shard, %d distinct buckets available", errors.ErrMirrorFactor, copies, len(placements))
+	}
+	return placements, nil
+}
+
 // downloadShards downloads shards using dynamic concurrency strategy with temp files
 func (s *FileService) downloadShards(ctx context.Context, shardHashes []domain.ShardStorage, parityShards int, quiet bool, verifyIntegrity bool) ([]string, error) {
 	// Dynamic Shard Downloading Strategy:
-	// 1. Start with limited concurrent downloads (s.concurrency)
-	// 2. When a shard completes, check if we need more shards
-	// 3. If still needed, start downloading the next available shard
+	// 1. Start with limited concurrent downloads (s.initialDownloads: the concurrency, or the needed shards plus a buffer)
+	// 2. When a shard completes or fails, check if we need more shards
+	// 3. If still needed, start downloading the next available shard, so shards on a
+	//    provider that is down fail fast and the download moves on to the other providers
 	// 4. Stop early once we have enough shards for reconstruction
 	// This optimizes network usage and reduces unnecessary downloads
 
+	// Calculate minimum shards needed for Reed-Solomon reconstruction
+	// Formula: total_shards - parity_shards = minimum_data_shards_needed
+	minShardsNeeded := len(shardHashes) - parityShards
+
 	tempFilePaths := make([]string, len(shardHashes))
 	var wg sync.WaitGroup
 	var mu sync.Mutex               // Protects shared state between goroutines
 	successfulShards := 0           // Count of successfully downloaded shards
-	nextShardIndex := s.concurrency // Index of next shard to download
+	workers := s.initialDownloads(minShardsNeeded)
+	nextShardIndex := workers // Index of next shard to download
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	// Calculate minimum shards needed for Reed-Solomon reconstruction
-	// Formula: total_shards - parity_shards = minimum_data_shards_needed
-	minShardsNeeded := len(shardHashes) - parityShards
-
 	// Phase 1: Start initial batch of downloads (up to concurrency limit)
 	// This prevents overwhelming the network with too many simultaneous requests
-	for i := 0; i < s.concurrency && i < len(shardHashes); i++ {
+	for i := 0; i < workers && i < len(shardHashes); i++ {
 		wg.Add(1)
 		go s.downloadShard(ctx, &wg, &mu, tempFilePaths, shardHashes[i], i, quiet, &successfulShards, &nextShardIndex, minShardsNeeded, shardHashes, cancel, verifyIntegrity)
 	}
@@ -315,24 +1082,13 @@ func (s *FileService) downloadShards(ctx context.Context, shardHashes []domain.S
 	// Phase 4: Ensure we have enough shards for Reed-Solomon reconstruction
 	// If insufficient, return error rather than attempting reconstruction
 	if successfulShards < minShardsNeeded {
-		// Cleanup temp files on failure
-		for _, path := range tempFilePaths {
-			if path != "" {
-				os.Remove(path)
-			}
-		}
+		// Cleanup temp files on failure; a resumable download keeps them for the next attempt
+		s.releaseTempFiles(tempFilePaths, true)
 		return nil, errors.ErrInsufficientShards
 	}
 
-	// Filter out empty paths (failed downloads)
-	var successfulPaths []string
-	for _, path := range tempFilePaths {
-		if path != "" {
-			successfulPaths = append(successfulPaths, path)
-		}
-	}
-
-	return successfulPaths, nil
+	// Paths stay indexed by shard position; failed or skipped shards are empty strings
+	return tempFilePaths, nil
 }
 
 // verifyFileIntegrity checks if the reconstructed file matches the expected CRC64 hash
@@ -348,6 +1104,89 @@ func verifyFileIntegrity(data []byte, expectedHash string) error {
 	return nil
 }
 
+// shardRepository returns the repository holding a shard copy for a download
+// Metadata can outlive a bucket's entry in the config; such copies are skipped like any
+// unreadable copy, so the object still reconstructs from the others, but the bucket is
+// named in a warning so an operator can register it again.
+func (s *FileService) shardRepository(bucketName string) (objectstore.ObjectRepository, error) {
+	repo, err := s.placer.GetRepositoryForBucket(bucketName)
+	if stderrors.Is(err, errors.ErrBucketNotRegistered) {
+		log.Warnf("Bucket %s holds shards but is not registered; add it back under 'buckets' in the config file to read them", bucketName)
+	}
+	return repo, err
+}
+
+// downloadShardCopy downloads the first readable copy of a shard into memory
+// Copies are tried primary first; a copy that fails to download or, when it is
+// checked (see shouldVerifyShard), fails its hash check falls through to the next mirror.
+func (s *FileService) downloadShardCopy(ctx context.Context, shard domain.ShardStorage, verifyIntegrity bool) ([]byte, error) {
+	locations := s.readableLocations(ctx, shard)
+	if len(locations) == 0 {
+		return nil, noReadableCopiesError(shard)
+	}
+
+	var lastErr error
+	for _, location := range locations {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		repo, err := s.shardRepository(location.BucketName)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		buf := &writeAtBuffer{}
+		if err := repo.Download(ctx, location.Key, buf, true); err != nil {
+			lastErr = fmt.Errorf("bucket %s: %w", location.BucketName, err)
+			continue
+		}
+		if s.shouldVerifyShard(verifyIntegrity) {
+			if err := verifyFileIntegrity(buf.Bytes(), shard.Hash); err != nil {
+				lastErr = fmt.Errorf("bucket %s: %w", location.BucketName, err)
+				continue
+			}
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, lastErr
+}
+
+// downloadShardTo downloads the first available copy of a shard into a temp file
+// Copies are tried primary first and the file is truncated before each retry.
+// Returns the bucket the shard was read from (the last one tried on failure).
+func (s *FileService) downloadShardTo(ctx context.Context, shard domain.ShardStorage, dest *os.File, quiet bool) (string, error) {
+	locations := s.readableLocations(ctx, shard)
+	if len(locations) == 0 {
+		return "", noReadableCopiesError(shard)
+	}
+
+	var lastErr error
+	bucketName := ""
+	for i, location := range locations {
+		if err := ctx.Err(); err != nil {
+			return bucketName, err
+		}
+		bucketName = location.BucketName
+		if i > 0 {
+			log.Debugf("Retrying shard from mirror in bucket %s after: %v", bucketName, lastErr)
+			if err := dest.Truncate(0); err != nil {
+				return bucketName, err
+			}
+		}
+		repo, err := s.shardRepository(bucketName)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if err := repo.Download(ctx, location.Key, dest, quiet); err != nil {
+			lastErr = err
+			continue
+		}
+		return bucketName, nil
+	}
+	return bucketName, lastErr
+}
+
 // downloadShard downloads a single shard to temp file and manages dynamic concurrency
 // This function implements the core logic for the dynamic downloading strategy:
 // 1. Downloads the assigned shard to a temp file
@@ -366,22 +1205,33 @@ func (s *FileService) downloadShard(ctx context.Context, wg *sync.WaitGroup, mu
 	}
 
 	shardStart := time.Now()
-	log.Debugf("[PERF] Starting shard %d download: bucket=%s, key=%s", i, shardInfo.BucketName, shardInfo.Key)
-
-	// Step 1: Get repository for the shard's bucket
-	repoStart := time.Now()
-	repo, err := s.placer.GetRepositoryForBucket(shardInfo.BucketName)
-	if err != nil {
-		// Mark shard as failed and potentially start next download
-		tempFilePaths[i] = ""
-		s.maybeStartNext(wg, mu, tempFilePaths, successfulShards, nextShardIndex, minShardsNeeded, allShards, ctx, cancel, quiet, verifyIntegrity)
-		return
+	log.Debugf("[PERF] Starting shard %d download: bucket=%s, key=%s", i, shardInfo.Primary().BucketName, shardInfo.Primary().Key)
+
+	// A shard completed by an earlier attempt of a resumable download is used as it is
+	cachePath := ""
+	if s.resumeDownloads {
+		path, err := s.resumeCachePath(allShards, i)
+		switch {
+		case err != nil:
+			log.Warnf("Shard %d: resume cache unavailable: %v", i, err)
+		case cachedShard(path, shardInfo):
+			log.Debugf("Shard %d: reusing cached copy %s", i, path)
+			s.shardDownloaded(wg, mu, tempFilePaths, path, i, shardStart, successfulShards, nextShardIndex, minShardsNeeded, allShards, ctx, cancel, quiet, verifyIntegrity)
+			return
+		default:
+			cachePath = path
+		}
 	}
-	log.Debugf("[PERF] Shard %d: Repository lookup took %v", i, time.Since(repoStart))
 
-	// Step 2: Create temp file for this shard
+	// Step 1: Create temp file for this shard (a .part file in the resume cache when resuming)
 	tempFileStart := time.Now()
-	tempFile, err := os.CreateTemp("", fmt.Sprintf("shard_%d_*.tmp", i))
+	var tempFile *os.File
+	var err error
+	if cachePath != "" {
+		tempFile, err = os.Create(cachePath + ".part")
+	} else {
+		tempFile, err = os.CreateTemp(s.tempDir, tempShardPattern(s.tempPrefix, i))
+	}
 	if err != nil {
 		tempFilePaths[i] = ""
 		s.maybeStartNext(wg, mu, tempFilePaths, successfulShards, nextShardIndex, minShardsNeeded, allShards, ctx, cancel, quiet, verifyIntegrity)
@@ -390,9 +1240,9 @@ func (s *FileService) downloadShard(ctx context.Context, wg *sync.WaitGroup, mu
 	tempFilePath := tempFile.Name()
 	log.Debugf("[PERF] Shard %d: Temp file creation took %v", i, time.Since(tempFileStart))
 
-	// Step 3: Download directly to temp file using WriterAt interface
+	// Step 2: Download the first available copy directly to temp file using WriterAt interface
 	downloadStart := time.Now()
-	err = repo.Download(ctx, shardInfo.Key, tempFile, quiet)
+	bucketName, err := s.downloadShardTo(ctx, shardInfo, tempFile, quiet)
 	log.Debugf("[PERF] Shard %d: Download initiation took %v", i, time.Since(downloadStart))
 	tempFile.Close()
 	if err != nil {
@@ -405,6 +1255,7 @@ func (s *FileService) downloadShard(ctx context.Context, wg *sync.WaitGroup, mu
 		}
 		// Mark shard as failed and potentially start next download
 		log.Errorf("Shard %d download failed: %v", i, err)
+		s.observeShard(ShardTiming{Operation: ShardDownload, Index: i, BucketName: bucketName, Duration: time.Since(downloadStart), Err: err})
 		os.Remove(tempFilePath)
 		tempFilePaths[i] = ""
 		s.maybeStartNext(wg, mu, tempFilePaths, successfulShards, nextShardIndex, minShardsNeeded, allShards, ctx, cancel, quiet, verifyIntegrity)
@@ -418,39 +1269,72 @@ func (s *FileService) downloadShard(ctx context.Context, wg *sync.WaitGroup, mu
 		log.Errorf("Shard %d: Failed to stat temp file: %v", i, err)
 	}
 
-	// Copy temp file content for performance measurement
-	copyStart := time.Now()
-	shardData, err := os.ReadFile(tempFilePath)
-	if err != nil {
-		log.Errorf("Shard %d: Failed to read temp file: %v", i, err)
-		os.Remove(tempFilePath)
-		tempFilePaths[i] = ""
-		s.maybeStartNext(wg, mu, tempFilePaths, successfulShards, nextShardIndex, minShardsNeeded, allShards, ctx, cancel, quiet, verifyIntegrity)
-		return
+	// Step 3: Verify shard integrity using CRC64 hash, for every shard when verifyIntegrity
+	// is set and for a sample of them otherwise. Only checked shards are read back.
+	// This ensures downloaded data matches what was originally stored
+	verify := s.shouldVerifyShard(verifyIntegrity)
+	var shardSize int64
+	var shardData []byte
+	if verify {
+		copyStart := time.Now()
+		shardData, err = os.ReadFile(tempFilePath)
+		if err != nil {
+			log.Errorf("Shard %d: Failed to read temp file: %v", i, err)
+			os.Remove(tempFilePath)
+			tempFilePaths[i] = ""
+			s.maybeStartNext(wg, mu, tempFilePaths, successfulShards, nextShardIndex, minShardsNeeded, allShards, ctx, cancel, quiet, verifyIntegrity)
+			return
+		}
+		shardSize = int64(len(shardData))
+		log.Debugf("[PERF] Shard %d: Copied %d bytes in %v (%.2f MB/s)", i, len(shardData), time.Since(copyStart), float64(len(shardData))/1024/1024/time.Since(copyStart).Seconds())
+	} else if fileInfo, err := os.Stat(tempFilePath); err == nil {
+		shardSize = fileInfo.Size()
 	}
-	log.Debugf("[PERF] Shard %d: Copied %d bytes in %v (%.2f MB/s)", i, len(shardData), time.Since(copyStart), float64(len(shardData))/1024/1024/time.Since(copyStart).Seconds())
+	s.observeShard(ShardTiming{Operation: ShardDownload, Index: i, BucketName: bucketName, Bytes: shardSize, Duration: time.Since(downloadStart), Verified: verify})
 
-	// Step 4: Verify shard integrity using CRC64 hash (optional)
-	// This ensures downloaded data matches what was originally stored
-	if verifyIntegrity {
+	if verify {
 		if err := verifyFileIntegrity(shardData, shardInfo.Hash); err != nil {
 			log.Warnf("Shard %d failed integrity check", i)
+			if s.keepTempFiles {
+				log.Warnf("Kept shard %d that failed its integrity check at %s", i, tempFilePath)
+			} else {
+				os.Remove(tempFilePath)
+			}
+			tempFilePaths[i] = ""
+			s.maybeStartNext(wg, mu, tempFilePaths, successfulShards, nextShardIndex, minShardsNeeded, allShards, ctx, cancel, quiet, verifyIntegrity)
+			return
+		}
+	}
+
+	// Only complete shards take the cached name, so an interrupted one is never reused
+	if cachePath != "" {
+		if err := os.Rename(tempFilePath, cachePath); err != nil {
+			log.Errorf("Shard %d: Failed to cache shard: %v", i, err)
 			os.Remove(tempFilePath)
 			tempFilePaths[i] = ""
 			s.maybeStartNext(wg, mu, tempFilePaths, successfulShards, nextShardIndex, minShardsNeeded, allShards, ctx, cancel, quiet, verifyIntegrity)
 			return
 		}
+		tempFilePath = cachePath
 	}
 
-	// Step 5: Successfully downloaded shard
+	s.shardDownloaded(wg, mu, tempFilePaths, tempFilePath, i, shardStart, successfulShards, nextShardIndex, minShardsNeeded, allShards, ctx, cancel, quiet, verifyIntegrity)
+}
+
+// shardDownloaded records a shard staged at tempFilePath and starts the next download if more are needed
+func (s *FileService) shardDownloaded(wg *sync.WaitGroup, mu *sync.Mutex, tempFilePaths []string, tempFilePath string, i int, shardStart time.Time, successfulShards *int, nextShardIndex *int, minShardsNeeded int, allShards []domain.ShardStorage, ctx context.Context, cancel context.CancelFunc, quiet bool, verifyIntegrity bool) {
+	// Step 4: Successfully downloaded shard
 	// Update shared state under mutex protection
 	mu.Lock()
 	tempFilePaths[i] = tempFilePath
 	*successfulShards++
 	shardTotal := time.Since(shardStart)
+	if info, err := os.Stat(tempFilePath); err == nil {
+		progressReporter(ctx).Advance(info.Size(), 1)
+	}
 	log.Debugf("[PERF] Shard %d: TOTAL time %v (%d/%d needed)", i, shardTotal, *successfulShards, minShardsNeeded)
 
-	// Step 6: Early termination optimization
+	// Step 5: Early termination optimization
 	// If we have enough shards for reconstruction, cancel remaining downloads
 	// This prevents unnecessary network traffic and speeds up the process
 	if *successfulShards >= minShardsNeeded {
@@ -460,8 +1344,13 @@ func (s *FileService) downloadShard(ctx context.Context, wg *sync.WaitGroup, mu
 	}
 	mu.Unlock()
 
-	// Step 7: Dynamic concurrency - start next download if needed
-	// This maintains optimal network utilization by keeping downloads active
+	// Step 6: Dynamic concurrency - start next download if needed
+	// This maintains optimal network utilization by keeping downloads active.
+	// A first batch of the needed shards plus a buffer already covers every success,
+	// so only failed shards are replaced.
+	if s.replacesFailedShardsOnly(minShardsNeeded) {
+		return
+	}
 	s.maybeStartNext(wg, mu, tempFilePaths, successfulShards, nextShardIndex, minShardsNeeded, allShards, ctx, cancel, quiet, verifyIntegrity)
 }
 
@@ -496,12 +1385,321 @@ func (s *FileService) maybeStartNext(wg *sync.WaitGroup, mu *sync.Mutex, tempFil
 
 
 
+// StatFile returns the stored metadata for an object without downloading it
+func (s *FileService) StatFile(ctx context.Context, key string) (domain.ObjectMetadata, error) {
+	key = s.NormalizeKey(key)
+	return s.getLiveMetadata(ctx, key)
+}
+
 // ListFiles lists all files stored under a given prefix
 func (s *FileService) ListFiles(ctx context.Context, prefix string) ([]domain.ObjectMetadata, error) {
-	return s.metadataRepo.ListMetadataByPrefix(ctx, prefix)
+	prefix = s.NormalizeKey(prefix)
+	files, err := s.metadataRepo.ListMetadataByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return liveOnly(files), nil
+}
+
+// ListFilesSorted lists the files under a prefix ordered by size or upload time
+// Metadata stores that implement SortedMetadataLister order the listing themselves;
+// otherwise the prefix listing is sorted in memory.
+func (s *FileService) ListFilesSorted(ctx context.Context, prefix string, sortBy domain.MetadataSortField, order domain.SortOrder) ([]domain.ObjectMetadata, error) {
+	prefix = s.NormalizeKey(prefix)
+	if sortBy != domain.SortBySize && sortBy != domain.SortByDate {
+		return nil, fmt.Errorf("%w: unknown sort field %q (use size or date)", errors.ErrInvalidListSort, sortBy)
+	}
+	if order != domain.SortAscending && order != domain.SortDescending {
+		return nil, fmt.Errorf("%w: unknown order %q (use asc or desc)", errors.ErrInvalidListSort, order)
+	}
+
+	if lister, ok := s.metadataRepo.(SortedMetadataLister); ok {
+		files, err := lister.ListMetadataSorted(ctx, prefix, sortBy, order)
+		if err != nil {
+			return nil, err
+		}
+		return liveOnly(files), nil
+	}
+
+	files, err := s.ListFiles(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	domain.SortObjectMetadata(files, sortBy, order)
+	return files, nil
+}
+
+// SetMinShardSize sets the minimum shard size below which fewer data shards are used
+func (s *FileService) SetMinShardSize(minShardSize int64) {
+	s.minShardSize = minShardSize
+}
+
+// SetShardAlignment sets the block size shards are padded to a multiple of (0 disables)
+// Padding only applies to new uploads and re-encodes; existing objects keep their layout.
+func (s *FileService) SetShardAlignment(alignment int64) {
+	s.shardAlignment = alignment
+}
+
+// SetMaxObjectSize sets the largest object size uploads accept (0 disables the limit)
+func (s *FileService) SetMaxObjectSize(maxObjectSize int64) {
+	s.maxObjectSize = maxObjectSize
+}
+
+// SetWriteManifest enables storing a manifest alongside each uploaded object
+func (s *FileService) SetWriteManifest(enabled bool) {
+	s.manifestOnUpload = enabled
+}
+
+// SetManifestBucket sets the bucket that holds object manifests
+func (s *FileService) SetManifestBucket(bucketName string) {
+	s.manifestBucket = bucketName
+}
+
+// SetManifestSigningKey sets the key used to sign manifests and verify them on read
+func (s *FileService) SetManifestSigningKey(key *ecdsa.PrivateKey) {
+	s.signingKey = key
+}
+
+// SetCustomerKey configures provider-side encryption with a customer-provided key on every bucket
+// The key is never persisted, so it must be supplied again to download. An error is returned if
+// any registered bucket cannot use customer keys, since its shards would otherwise be stored
+// without the requested encryption.
+func (s *FileService) SetCustomerKey(key []byte) error {
+	var unsupported []string
+	for _, bucketName := range s.placer.ListBuckets() {
+		repo, err := s.placer.GetRepositoryForBucket(bucketName)
+		if err != nil {
+			return err
+		}
+		configurable, ok := repo.(objectstore.CustomerKeyConfigurable)
+		if !ok {
+			unsupported = append(unsupported, bucketName)
+			continue
+		}
+		configurable.SetCustomerKey(key)
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("customer-provided keys are not supported by buckets: %s", strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+// SetChecksumAlgorithm makes providers that support it verify a checksum of every shard uploaded afterwards
+// Buckets that cannot be configured are skipped: GCS validates uploads on its own and IPFS
+// content is addressed by its hash. This complements the CRC64 shard hashes, which are
+// only checked on download.
+func (s *FileService) SetChecksumAlgorithm(algorithm objectstore.ChecksumAlgorithm) error {
+	for _, bucketName := range s.placer.ListBuckets() {
+		repo, err := s.placer.GetRepositoryForBucket(bucketName)
+		if err != nil {
+			return err
+		}
+		if configurable, ok := repo.(objectstore.ChecksumConfigurable); ok {
+			configurable.SetChecksumAlgorithm(algorithm)
+		}
+	}
+	return nil
+}
+
+// SetRetention locks every shard uploaded afterwards until retention.RetainUntil
+// (S3 Object Lock, GCS object retention); the zero value stops locking new uploads.
+// Locked objects are recorded in metadata, and deleting, overwriting or re-encoding them
+// fails with errors.ErrObjectLocked until the retention period ends. An error is returned
+// if the retain-until date is not in the future or any registered bucket cannot lock
+// objects, since its shards would otherwise be stored unprotected.
+func (s *FileService) SetRetention(retention objectstore.Retention) error {
+	if retention.Enabled() && !retention.RetainUntil.After(time.Now()) {
+		return fmt.Errorf("retain-until date %s is not in the future", retention.RetainUntil.Format(time.RFC3339))
+	}
+
+	var unsupported []string
+	for _, bucketName := range s.placer.ListBuckets() {
+		repo, err := s.placer.GetRepositoryForBucket(bucketName)
+		if err != nil {
+			return err
+		}
+		configurable, ok := repo.(objectstore.RetentionConfigurable)
+		if !ok {
+			if retention.Enabled() {
+				unsupported = append(unsupported, bucketName)
+			}
+			continue
+		}
+		configurable.SetRetention(retention)
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("object retention is not supported by buckets: %s", strings.Join(unsupported, ", "))
+	}
+	s.retention = retention
+	return nil
+}
+
+// SetProgressOutput routes progress bars of every bucket to w (io.Discard disables them)
+// The per-call quiet flag still suppresses progress entirely; this only chooses the sink.
+func (s *FileService) SetProgressOutput(w io.Writer) {
+	s.progressWriter = w
+	for _, bucketName := range s.placer.ListBuckets() {
+		repo, err := s.placer.GetRepositoryForBucket(bucketName)
+		if err != nil {
+			continue
+		}
+		if configurable, ok := repo.(objectstore.ProgressConfigurable); ok {
+			configurable.SetProgressOutput(w)
+		}
+	}
+}
+
+// checkPlacement verifies that a shard layout is valid and that there are buckets to place it in
+// Round-robin placement puts several shards in one bucket when there are fewer buckets
+// than shards; that is allowed, but a warning is logged when losing a single bucket
+// would lose more shards than parity can recover.
+func (s *FileService) checkPlacement(dataShards, parityShards int) error {
+	return s.checkPlacementIn(s.placer, dataShards, parityShards)
+}
+
+// checkPlacementIn applies the checkPlacement rules to the buckets of placer
+func (s *FileService) checkPlacementIn(placer placement.Placer, dataShards, parityShards int) error {
+	if err := ValidateShardConfig(dataShards, parityShards); err != nil {
+		return err
+	}
+
+	bucketCount := len(placer.ListBuckets())
+	if bucketCount == 0 {
+		return errors.ErrNoBucketsRegistered
+	}
+	if s.mirrorFactor > bucketCount {
+		return fmt.Errorf("%w: %d copies per shard, %d buckets registered", errors.ErrMirrorFactor, s.mirrorFactor, bucketCount)
+	}
+
+	totalShards := dataShards + parityShards
+	if shardsPerBucket := (totalShards + bucketCount - 1) / bucketCount; shardsPerBucket > parityShards {
+		log.Warnf("%d shards across %d buckets puts up to %d shards in one bucket, more than the %d parity shards; losing a single bucket will make the object unreadable", totalShards, bucketCount, shardsPerBucket, parityShards)
+	}
+	return nil
+}
+
+// SetShardObserver registers a callback that receives the timing of every shard transfer
+// The callback is invoked concurrently from transfer goroutines. Pass nil to disable it.
+func (s *FileService) SetShardObserver(observer func(ShardTiming)) {
+	s.shardObserver = observer
+}
+
+// observeShard reports a shard transfer to the registered observer, if any
+func (s *FileService) observeShard(timing ShardTiming) {
+	if s.shardObserver != nil {
+		s.shardObserver(timing)
+	}
+}
+
+// SetWriteBufferSize sets the buffer size used when writing downloaded objects
+// Zero or a negative value writes each object to its destination in a single call.
+func (s *FileService) SetWriteBufferSize(size int) {
+	s.writeBufferSize = max(size, 0)
 }
 
-// SetConcurrency sets the concurrency limit for uploads
+// SetMirrorFactor sets how many copies of each shard are stored, each in a different bucket
+// Values below 1 store a single copy. Uploads fail if there are fewer buckets than copies.
+func (s *FileService) SetMirrorFactor(mirrorFactor int) {
+	s.mirrorFactor = max(mirrorFactor, 1)
+}
+
+// SetMaxShardFailures sets how many shard upload failures an upload tolerates
+// A negative value restores the default of tolerating up to the parity shard count.
+func (s *FileService) SetMaxShardFailures(maxFailures int) {
+	s.maxShardFailures = maxFailures
+}
+
+// shardFailureTolerance resolves the shard failure tolerance for an upload with the given parity
+func (s *FileService) shardFailureTolerance(parityShards int) (int, error) {
+	if s.maxShardFailures < 0 {
+		return parityShards, nil
+	}
+	if s.maxShardFailures > parityShards {
+		return 0, fmt.Errorf("%w: %d failures allowed with %d parity shards", errors.ErrShardFailureTolerance, s.maxShardFailures, parityShards)
+	}
+	return s.maxShardFailures, nil
+}
+
+// SetConcurrency sets the concurrency limit shared by uploads and downloads
+// SetUploadConcurrency and SetDownloadConcurrency override it for one direction.
 func (s *FileService) SetConcurrency(concurrency int) {
 	s.concurrency = concurrency
 }
+
+// SetEncoderGoroutines caps the goroutines each Reed-Solomon encode is split into
+// Zero or less sizes them from the shard size and GOMAXPROCS, which suits most machines;
+// a cap keeps encoding from competing with other work for every CPU.
+func (s *FileService) SetEncoderGoroutines(goroutines int) {
+	s.encoderGoroutines = goroutines
+}
+
+// SetUploadConcurrency sets how many shards an upload stores at once when it passes no limit itself
+// Zero falls back to the shared concurrency.
+func (s *FileService) SetUploadConcurrency(concurrency int) {
+	s.uploadConcurrency = concurrency
+}
+
+// SetDownloadConcurrency sets how many shards a download fetches at once
+// Downloads stop once enough shards have arrived, so they usually benefit from more
+// parallelism than uploads. Zero falls back to the shared concurrency.
+func (s *FileService) SetDownloadConcurrency(concurrency int) {
+	s.downloadConcurrency = concurrency
+}
+
+// SetDeleteConcurrency sets how many objects DeletePrefix and Prune delete at once
+// Zero falls back to the shared concurrency.
+func (s *FileService) SetDeleteConcurrency(concurrency int) {
+	s.deleteConcurrency = concurrency
+}
+
+// SetInitialDownloadBuffer sizes the first batch of shard downloads to the shards needed plus buffer
+// By default a download starts as many shards as its concurrency allows, which fetches
+// shards that are never used when the concurrency exceeds the data shard count. With a
+// buffer of 0 or more, it starts at most the needed shards plus buffer, still capped by
+// the concurrency, and only starts another shard when one fails (or, when the cap
+// applied, when one completes while more are needed). A buffer of 1 or 2
+// keeps a single slow or failed shard from holding up the download. Negative restores
+// the default.
+func (s *FileService) SetInitialDownloadBuffer(buffer int) {
+	s.initialDownloadBuffer = buffer
+}
+
+// initialDownloads returns how many shards a download starts with, when minShardsNeeded are needed
+func (s *FileService) initialDownloads(minShardsNeeded int) int {
+	workers := s.downloadWorkers()
+	if s.initialDownloadBuffer < 0 {
+		return workers
+	}
+	return max(min(workers, minShardsNeeded+s.initialDownloadBuffer), 1)
+}
+
+// replacesFailedShardsOnly reports whether a download's first batch holds every shard it needs plus the buffer
+// Such a download only starts another shard when one fails; one whose first batch was
+// capped by the concurrency keeps starting shards as they complete.
+func (s *FileService) replacesFailedShardsOnly(minShardsNeeded int) bool {
+	return s.initialDownloadBuffer >= 0 && minShardsNeeded+s.initialDownloadBuffer <= s.downloadWorkers()
+}
+
+// uploadWorkers returns the number of concurrent shard uploads, at least 1
+func (s *FileService) uploadWorkers() int {
+	if s.uploadConcurrency > 0 {
+		return s.uploadConcurrency
+	}
+	return max(s.concurrency, 1)
+}
+
+// deleteWorkers returns the number of objects deleted concurrently, at least 1
+func (s *FileService) deleteWorkers() int {
+	if s.deleteConcurrency > 0 {
+		return s.deleteConcurrency
+	}
+	return max(s.concurrency, 1)
+}
+
+// downloadWorkers returns the number of concurrent shard downloads, at least 1
+func (s *FileService) downloadWorkers() int {
+	if s.downloadConcurrency > 0 {
+		return s.downloadConcurrency
+	}
+	return max(s.concurrency, 1)
+}
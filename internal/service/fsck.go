@@ -0,0 +1,357 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements a consistency check between metadata and stored shards.
+//
+// Fsck audits one prefix (directory) in both directions:
+// - Every shard referenced by metadata must exist with the recorded shard size
+// - Every shard stored under the prefix must be referenced by metadata
+//
+// Each inconsistency is reported with a suggested fix. With repair enabled:
+// - Missing or wrong-sized shards are regenerated from the surviving shards
+// - Orphaned shards (no metadata) are deleted
+// - Objects that can no longer be reconstructed are only reported
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+// FsckIssueKind classifies an inconsistency found by Fsck
+type FsckIssueKind string
+
+const (
+	FsckMissingShard FsckIssueKind = "missing-shard"
+	FsckSizeMismatch FsckIssueKind = "size-mismatch"
+	FsckLostObject   FsckIssueKind = "lost-object"
+	FsckOrphanShard  FsckIssueKind = "orphan-shard"
+)
+
+// FsckIssue describes a single inconsistency and, when repairing, its outcome
+type FsckIssue struct {
+	Kind        FsckIssueKind
+	ObjectKey   string // Object the issue belongs to (derived from the shard key for orphans)
+	ShardIndex  int    // -1 when the issue is not about a specific shard
+	BucketName  string // Where the shard is recorded, or where it was stored once repaired
+	ShardKey    string
+	Detail      string
+	Suggestion  string
+	Repaired    bool
+	RepairError error
+
+	unverified bool // Shard state could not be determined, so it must not be overwritten
+}
+
+// FsckReport summarizes a consistency check
+type FsckReport struct {
+	ObjectsChecked int
+	ShardsChecked  int
+	Issues         []FsckIssue
+}
+
+// shardCheck is the result of inspecting one referenced shard
+type shardCheck struct {
+	index  int
+	issue  *FsckIssue
+	usable bool
+}
+
+// Fsck checks metadata and shards under a prefix for drift, optionally repairing what it can
+func (s *FileService) Fsck(ctx context.Context, prefix string, repair bool) (FsckReport, error) {
+	prefix = s.NormalizeKey(prefix)
+	var report FsckReport
+
+	objects, err := s.metadataRepo.ListMetadataByPrefix(ctx, prefix)
+	if err != nil {
+		return report, err
+	}
+
+	// Storage locations referenced by metadata, used to detect orphans
+	referenced := make(map[string]bool)
+
+	for _, metadata := range objects {
+		objectKey := path.Join(metadata.Prefix, metadata.FileName)
+		report.ObjectsChecked++
+		report.ShardsChecked += len(metadata.ShardHashes)
+
+		for _, shard := range metadata.ShardHashes {
+			for _, location := range shard.Locations {
+				referenced[location.BucketName+"/"+location.Key] = true
+			}
+		}
+		// A manifest belongs to the object even though it is not a shard
+		if bucketName, err := s.manifestBucketName(); err == nil {
+			referenced[bucketName+"/"+ManifestKey(objectKey)] = true
+		}
+
+		issues := s.fsckObject(ctx, objectKey, metadata, repair)
+		for _, issue := range issues {
+			// A regenerated shard may be stored where metadata recorded no location before
+			if issue.Repaired && issue.ShardIndex >= 0 {
+				referenced[issue.BucketName+"/"+issue.ShardKey] = true
+			}
+		}
+		report.Issues = append(report.Issues, issues...)
+	}
+
+	orphans, err := s.findOrphanShards(ctx, prefix, referenced)
+	if err != nil {
+		return report, err
+	}
+	for _, orphan := range orphans {
+		if repair {
+			orphan.RepairError = s.deleteShard(ctx, orphan.BucketName, orphan.ShardKey)
+			orphan.Repaired = orphan.RepairError == nil
+		}
+		report.Issues = append(report.Issues, orphan)
+	}
+
+	return report, nil
+}
+
+// fsckObject verifies every shard of one object and regenerates bad shards when repairing
+func (s *FileService) fsckObject(ctx context.Context, objectKey string, metadata domain.ObjectMetadata, repair bool) []FsckIssue {
+	checks := make([]shardCheck, len(metadata.ShardHashes))
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, healthCheckConcurrency)
+	for i, shard := range metadata.ShardHashes {
+		wg.Add(1)
+		go func(i int, shard domain.ShardStorage) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			checks[i] = s.checkShard(ctx, objectKey, i, shard, metadata.ShardSize)
+		}(i, shard)
+	}
+	wg.Wait()
+
+	var issues []FsckIssue
+	usable := 0
+	for _, check := range checks {
+		if check.usable {
+			usable++
+		}
+		if check.issue != nil {
+			issues = append(issues, *check.issue)
+		}
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+
+	minShardsNeeded := len(metadata.ShardHashes) - metadata.ParityShards
+	if usable < minShardsNeeded {
+		// Shard-level repairs are impossible; report the object as a whole
+		return []FsckIssue{{
+			Kind:       FsckLostObject,
+			ObjectKey:  objectKey,
+			ShardIndex: -1,
+			Detail:     fmt.Sprintf("%d of %d shards usable, %d needed", usable, len(metadata.ShardHashes), minShardsNeeded),
+			Suggestion: "restore the object from another copy and re-upload it, or delete its metadata",
+		}}
+	}
+
+	if repair {
+		s.repairShards(ctx, objectKey, metadata, issues)
+	}
+	return issues
+}
+
+// checkShard inspects one referenced shard's existence and size
+func (s *FileService) checkShard(ctx context.Context, objectKey string, index int, shard domain.ShardStorage, expectedSize int64) shardCheck {
+	primary := shard.Primary()
+	issue := &FsckIssue{
+		ObjectKey:  objectKey,
+		ShardIndex: index,
+		BucketName: primary.BucketName,
+		ShardKey:   primary.Key,
+		Suggestion: "re-upload the shard (run fsck --repair to regenerate it from the other shards)",
+	}
+
+	if primary.Key == "" {
+		issue.Kind = FsckMissingShard
+		issue.Detail = "metadata has no storage key for this shard"
+		return shardCheck{index: index, issue: issue}
+	}
+
+	repo, err := s.placer.GetRepositoryForBucket(primary.BucketName)
+	if err != nil {
+		issue.Kind = FsckMissingShard
+		issue.Detail = err.Error()
+		issue.Suggestion = "register the bucket in the configuration, or update the metadata to point at an existing bucket"
+		return shardCheck{index: index, issue: issue}
+	}
+
+	size, err := repo.GetObjectSize(ctx, primary.Key)
+	switch {
+	case stderrors.Is(err, errors.ErrObjectNotFound):
+		issue.Kind = FsckMissingShard
+		issue.Detail = "shard does not exist"
+	case err != nil:
+		// Unknown state: report it but do not try to overwrite the shard
+		issue.Kind = FsckMissingShard
+		issue.Detail = fmt.Sprintf("could not check shard: %v", err)
+		issue.Suggestion = "re-run fsck once the storage provider is reachable"
+		issue.unverified = true
+		return shardCheck{index: index, issue: issue}
+	case size != expectedSize:
+		issue.Kind = FsckSizeMismatch
+		issue.Detail = fmt.Sprintf("stored size %d bytes, expected %d", size, expectedSize)
+	default:
+		return shardCheck{index: index, usable: true}
+	}
+	return shardCheck{index: index, issue: issue}
+}
+
+// repairShards regenerates bad shards from the surviving ones and stores them at their recorded locations
+// Reed-Solomon encoding is deterministic, so re-encoding the reconstructed object reproduces
+// the original shards exactly; each regenerated shard is checked against its recorded hash.
+func (s *FileService) repairShards(ctx context.Context, objectKey string, metadata domain.ObjectMetadata, issues []FsckIssue) {
+	markAll := func(err error) {
+		for i := range issues {
+			if !issues[i].unverified {
+				issues[i].Repaired = false
+				issues[i].RepairError = err
+			}
+		}
+	}
+
+	data, err := s.reconstructObject(ctx, metadata, true, true)
+	if err != nil {
+		markAll(fmt.Errorf("failed to reconstruct object: %w", err))
+		return
+	}
+
+	dataShards := metadata.DataShards
+	if dataShards == 0 {
+		dataShards = len(metadata.ShardHashes) - metadata.ParityShards
+	}
+	regenerated, shards, err := ShardFileConcurrent(data, dataShards, metadata.ParityShards, s.encoderGoroutines)
+	if err != nil {
+		markAll(fmt.Errorf("failed to re-encode object: %w", err))
+		return
+	}
+	// Pad to the object's recorded alignment rather than the current configuration
+	regenerated, shards = padShards(regenerated, shards, metadata.ShardSize)
+
+	updated := metadata
+	updated.ShardHashes = append([]domain.ShardStorage(nil), metadata.ShardHashes...)
+	metadataChanged := false
+
+	for i := range issues {
+		issue := &issues[i]
+		if issue.unverified {
+			continue // Shard state unknown; leave it alone
+		}
+		index := issue.ShardIndex
+		if regenerated.ShardHashes[index].Hash != metadata.ShardHashes[index].Hash {
+			issue.RepairError = fmt.Errorf("regenerated shard hash does not match metadata")
+			continue
+		}
+
+		shard := metadata.ShardHashes[index]
+		primary := shard.Primary()
+		bucketName := primary.BucketName
+		repo, err := s.placer.GetRepositoryForBucket(bucketName)
+		if err != nil {
+			// The recorded bucket is gone; place the shard somewhere that exists
+			bucketName, repo, err = s.placer.Place(index)
+			if err != nil {
+				issue.RepairError = err
+				continue
+			}
+		}
+
+		shardKey := primary.Key
+		if shardKey == "" {
+			shardKey = s.shardKey(objectKey, index, shard.Hash)
+		}
+		stored, err := repo.Upload(ctx, shardKey, bytes.NewReader(shards[index]), true)
+		if err != nil {
+			issue.RepairError = err
+			continue
+		}
+
+		// Content-addressed backends may return a different key than requested
+		storedKey := shardKey
+		if parts := strings.SplitN(stored, "/", 2); len(parts) == 2 {
+			storedKey = parts[1]
+		}
+		issue.BucketName, issue.ShardKey = bucketName, storedKey
+		if storedKey != primary.Key || bucketName != primary.BucketName {
+			// The regenerated copy replaces the primary location; other copies are kept
+			location := domain.Location{StorageType: repo.GetStorageType(), BucketName: bucketName, Key: storedKey}
+			locations := append([]domain.Location{location}, shard.Locations[min(1, len(shard.Locations)):]...)
+			updated.ShardHashes[index].Locations = locations
+			metadataChanged = true
+		}
+		issue.Repaired = true
+	}
+
+	if metadataChanged {
+		if _, err := s.metadataRepo.UpdateMetadata(ctx, updated); err != nil {
+			log.Warnf("Shards of %s were regenerated but metadata could not be updated: %v", objectKey, err)
+			markAll(err)
+		}
+	}
+}
+
+// findOrphanShards lists stored shards under a prefix that no metadata references
+// Only objects directly inside the prefix are considered, matching ListMetadataByPrefix.
+func (s *FileService) findOrphanShards(ctx context.Context, prefix string, referenced map[string]bool) ([]FsckIssue, error) {
+	listPrefix := prefix + "/"
+	if prefix == "." || prefix == "" {
+		listPrefix = ""
+	}
+
+	buckets := s.placer.ListBuckets()
+	sort.Strings(buckets)
+
+	var orphans []FsckIssue
+	for _, bucketName := range buckets {
+		repo, err := s.placer.GetRepositoryForBucket(bucketName)
+		if err != nil {
+			continue
+		}
+		keys, err := repo.ListKeys(ctx, listPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("bucket %s: %w", bucketName, err)
+		}
+
+		for _, key := range keys {
+			objectKey := path.Dir(key)
+			if path.Dir(objectKey) != path.Clean(prefix) || referenced[bucketName+"/"+key] {
+				continue
+			}
+			orphans = append(orphans, FsckIssue{
+				Kind:       FsckOrphanShard,
+				ObjectKey:  objectKey,
+				ShardIndex: -1,
+				BucketName: bucketName,
+				ShardKey:   key,
+				Detail:     "stored object is not referenced by any metadata",
+				Suggestion: "garbage-collect the orphan (run fsck --repair to delete it)",
+			})
+		}
+	}
+	return orphans, nil
+}
+
+// deleteShard removes a single stored object from a bucket
+func (s *FileService) deleteShard(ctx context.Context, bucketName, key string) error {
+	repo, err := s.placer.GetRepositoryForBucket(bucketName)
+	if err != nil {
+		return err
+	}
+	return repo.Delete(ctx, key)
+}
@@ -0,0 +1,27 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements case-insensitive object keys.
+//
+// Metadata keys are case-sensitive, so by default zs://Photos/X and zs://photos/X are two
+// objects. With case-insensitive keys enabled, every key and prefix passed to the service
+// is lower-cased before it reaches the metadata store or the buckets, so both names refer
+// to the same object. The key as given at upload is kept in the metadata's DisplayKey and
+// shown in listings. Enabling or disabling the mode does not rename stored objects: keys
+// uploaded with upper-case letters before it was enabled can no longer be reached.
+package service
+
+import "strings"
+
+// SetCaseInsensitiveKeys enables or disables case-insensitive object keys
+func (s *FileService) SetCaseInsensitiveKeys(enabled bool) {
+	s.caseInsensitiveKeys = enabled
+}
+
+// NormalizeKey returns a key or prefix as the service stores it
+// Keys are lower-cased when case-insensitive keys are enabled and returned unchanged
+// otherwise. Patterns matched against stored file names should be normalized the same way.
+func (s *FileService) NormalizeKey(key string) string {
+	if !s.caseInsensitiveKeys {
+		return key
+	}
+	return strings.ToLower(key)
+}
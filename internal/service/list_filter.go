@@ -0,0 +1,73 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements filtering listings by storage overhead and size.
+//
+// Small objects store a padded shard per data shard and so cost far more than their size
+// suggests, and objects stored with a wide parity layout cost more than they need to. The
+// thresholds are applied to the listed metadata after the query, using the overhead and
+// original size recorded at upload (Overhead computes the ratio for older records), so
+// finding re-encoding or archiving candidates reads no shard.
+package service
+
+import (
+	"fmt"
+
+	"github.com/zzenonn/zstore/internal/domain"
+)
+
+// ListThresholds selects listed files by storage overhead and original size
+// Zero fields are not applied.
+type ListThresholds struct {
+	MinOverhead float64 // Only files storing at least this many bytes per original byte
+	MaxOverhead float64 // Only files storing at most this many bytes per original byte
+	MinSize     int64   // Only files of at least this many bytes
+	MaxSize     int64   // Only files of at most this many bytes
+}
+
+// Active reports whether any threshold is set
+func (t ListThresholds) Active() bool {
+	return t != ListThresholds{}
+}
+
+// Validate rejects negative thresholds and ranges that no file can fall in
+func (t ListThresholds) Validate() error {
+	if t.MinOverhead < 0 || t.MaxOverhead < 0 || t.MinSize < 0 || t.MaxSize < 0 {
+		return fmt.Errorf("overhead and size thresholds cannot be negative")
+	}
+	if t.MaxOverhead > 0 && t.MinOverhead > t.MaxOverhead {
+		return fmt.Errorf("minimum overhead %.2f is above the maximum %.2f", t.MinOverhead, t.MaxOverhead)
+	}
+	if t.MaxSize > 0 && t.MinSize > t.MaxSize {
+		return fmt.Errorf("minimum size %d is above the maximum %d", t.MinSize, t.MaxSize)
+	}
+	return nil
+}
+
+// Matches reports whether a file falls within every threshold
+func (t ListThresholds) Matches(file domain.ObjectMetadata) bool {
+	overhead := file.Overhead()
+	switch {
+	case t.MinOverhead > 0 && overhead < t.MinOverhead:
+		return false
+	case t.MaxOverhead > 0 && overhead > t.MaxOverhead:
+		return false
+	case t.MinSize > 0 && file.ContentSize() < t.MinSize:
+		return false
+	case t.MaxSize > 0 && file.ContentSize() > t.MaxSize:
+		return false
+	}
+	return true
+}
+
+// FilterByThresholds keeps the files within the thresholds, in order
+func FilterByThresholds(files []domain.ObjectMetadata, thresholds ListThresholds) []domain.ObjectMetadata {
+	if !thresholds.Active() {
+		return files
+	}
+	matched := files[:0]
+	for _, file := range files {
+		if thresholds.Matches(file) {
+			matched = append(matched, file)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,52 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements glob patterns for listings.
+//
+// Metadata is queried by exact prefix (the object's directory), so a pattern such as
+// photos/2024/*.jpg is split into the prefix photos/2024, which drives the query, and
+// the file name pattern *.jpg, which filters the result with path.Match. Wildcards are
+// therefore only allowed in the last path segment.
+package service
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+// globMeta are the characters that make a path segment a path.Match pattern
+const globMeta = `*?[\`
+
+// SplitListPattern splits a listing argument into the prefix to query and a file name pattern
+// An argument without wildcards is a plain prefix and returns an empty pattern.
+func SplitListPattern(key string) (prefix, pattern string, err error) {
+	dir, name := path.Split(strings.TrimSuffix(key, "/"))
+	if strings.ContainsAny(dir, globMeta) {
+		return "", "", fmt.Errorf("%w: wildcards are only supported in the file name, not in %q", errors.ErrInvalidListPattern, dir)
+	}
+	if !strings.ContainsAny(name, globMeta) {
+		return strings.TrimSuffix(key, "/"), "", nil
+	}
+	if _, err := path.Match(name, ""); err != nil {
+		return "", "", fmt.Errorf("%w: %q: %v", errors.ErrInvalidListPattern, name, err)
+	}
+	return strings.TrimSuffix(dir, "/"), name, nil
+}
+
+// MatchFileNames keeps the files whose names match a path.Match pattern, in order
+// An empty pattern matches every file and an invalid one matches none; SplitListPattern
+// rejects invalid patterns up front.
+func MatchFileNames(files []domain.ObjectMetadata, pattern string) []domain.ObjectMetadata {
+	if pattern == "" {
+		return files
+	}
+	matched := files[:0]
+	for _, file := range files {
+		if ok, _ := path.Match(pattern, file.FileName); ok {
+			matched = append(matched, file)
+		}
+	}
+	return matched
+}
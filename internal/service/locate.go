@@ -0,0 +1,58 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements shard location lookup for a single object.
+package service
+
+import (
+	"context"
+	"path"
+	"sort"
+)
+
+// BucketShards lists the shards of an object held by one bucket
+type BucketShards struct {
+	BucketName  string `json:"bucket"`
+	StorageType string `json:"storage_type"`
+	Shards      []int  `json:"shards"` // Shard indices, ascending
+}
+
+// ObjectLocation maps an object's shards to the buckets holding them
+type ObjectLocation struct {
+	Key      string         `json:"key"`
+	Buckets  []BucketShards `json:"buckets"`            // Ordered by bucket name
+	Unstored []int          `json:"unstored,omitempty"` // Shards with no recorded location
+}
+
+// LocateFile reports which buckets hold each shard of an object, according to its metadata
+// Every copy of a mirrored shard is listed under its own bucket. Shard presence is
+// not checked; use HealthStatus or Fsck for that.
+func (s *FileService) LocateFile(ctx context.Context, key string) (ObjectLocation, error) {
+	metadata, err := s.StatFile(ctx, key)
+	if err != nil {
+		return ObjectLocation{}, err
+	}
+
+	location := ObjectLocation{Key: path.Join(metadata.Prefix, metadata.FileName), Buckets: []BucketShards{}}
+	byBucket := make(map[string]*BucketShards)
+	for index, shard := range metadata.ShardHashes {
+		if len(shard.Locations) == 0 {
+			location.Unstored = append(location.Unstored, index)
+			continue
+		}
+		for _, stored := range shard.Locations {
+			bucket, ok := byBucket[stored.BucketName]
+			if !ok {
+				bucket = &BucketShards{BucketName: stored.BucketName, StorageType: stored.StorageType}
+				byBucket[stored.BucketName] = bucket
+			}
+			bucket.Shards = append(bucket.Shards, index)
+		}
+	}
+
+	for _, bucket := range byBucket {
+		location.Buckets = append(location.Buckets, *bucket)
+	}
+	sort.Slice(location.Buckets, func(i, j int) bool {
+		return location.Buckets[i].BucketName < location.Buckets[j].BucketName
+	})
+	return location, nil
+}
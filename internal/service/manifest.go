@@ -0,0 +1,179 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements self-describing manifests stored alongside an object's shards.
+//
+// A manifest is a JSON copy of the object's metadata written to "key/.manifest.json"
+// in a designated bucket. When a signing key is configured the manifest carries an
+// ECDSA signature over the canonical JSON serialization of the metadata, so a manifest
+// read back during recovery can be trusted even though it lives in object storage.
+// If the metadata store is unavailable, DownloadFile falls back to the manifest.
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"sort"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+// manifestFileName is the object name of a manifest under the object's key
+const manifestFileName = ".manifest.json"
+
+// ManifestKey returns the storage key of the manifest for an object key
+func ManifestKey(key string) string {
+	return key + "/" + manifestFileName
+}
+
+// canonicalMetadata returns the byte serialization that manifest signatures cover
+// Struct fields marshal in declaration order, so the output is stable for identical metadata.
+func canonicalMetadata(metadata domain.ObjectMetadata) ([]byte, error) {
+	return json.Marshal(metadata)
+}
+
+// BuildManifest wraps metadata in a manifest, signing it when a private key is given
+func BuildManifest(metadata domain.ObjectMetadata, signingKey *ecdsa.PrivateKey) (domain.Manifest, error) {
+	manifest := domain.Manifest{
+		Version:  domain.ManifestVersion,
+		Metadata: metadata,
+	}
+	if signingKey == nil {
+		return manifest, nil
+	}
+
+	canonical, err := canonicalMetadata(metadata)
+	if err != nil {
+		return domain.Manifest{}, err
+	}
+	digest := sha256.Sum256(canonical)
+	signature, err := ecdsa.SignASN1(rand.Reader, signingKey, digest[:])
+	if err != nil {
+		return domain.Manifest{}, fmt.Errorf("failed to sign manifest: %w", err)
+	}
+	manifest.Signature = base64.StdEncoding.EncodeToString(signature)
+	return manifest, nil
+}
+
+// VerifyManifest checks a manifest's signature against a public key
+func VerifyManifest(manifest domain.Manifest, publicKey *ecdsa.PublicKey) error {
+	signature, err := base64.StdEncoding.DecodeString(manifest.Signature)
+	if err != nil || len(signature) == 0 {
+		return errors.ErrManifestSignature
+	}
+
+	canonical, err := canonicalMetadata(manifest.Metadata)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(canonical)
+	if !ecdsa.VerifyASN1(publicKey, digest[:], signature) {
+		return errors.ErrManifestSignature
+	}
+	return nil
+}
+
+// manifestBucketName returns the designated manifest bucket, defaulting to the first registered bucket
+func (s *FileService) manifestBucketName() (string, error) {
+	if s.manifestBucket != "" {
+		return s.manifestBucket, nil
+	}
+	buckets := s.placer.ListBuckets()
+	if len(buckets) == 0 {
+		return "", errors.ErrManifestNotConfigured
+	}
+	sort.Strings(buckets)
+	return buckets[0], nil
+}
+
+// writeManifest stores the (optionally signed) manifest for an object
+func (s *FileService) writeManifest(ctx context.Context, key string, metadata domain.ObjectMetadata) error {
+	bucketName, err := s.manifestBucketName()
+	if err != nil {
+		return err
+	}
+	repo, err := s.placer.GetRepositoryForBucket(bucketName)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := BuildManifest(metadata, s.signingKey)
+	if err != nil {
+		return err
+	}
+	return storeManifest(ctx, repo, key, manifest)
+}
+
+// storeManifest uploads a manifest for an object key
+func storeManifest(ctx context.Context, repo objectstore.ObjectRepository, key string, manifest domain.Manifest) error {
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Upload(ctx, ManifestKey(key), bytes.NewReader(body), true)
+	return err
+}
+
+// loadManifest downloads and parses the manifest of an object key
+func loadManifest(ctx context.Context, repo objectstore.ObjectRepository, key string) (domain.Manifest, error) {
+	buf := &writeAtBuffer{}
+	if err := repo.Download(ctx, ManifestKey(key), buf, true); err != nil {
+		return domain.Manifest{}, err
+	}
+
+	var manifest domain.Manifest
+	if err := json.Unmarshal(buf.Bytes(), &manifest); err != nil {
+		return domain.Manifest{}, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// readManifest loads an object's manifest and verifies it when a signing key is configured
+func (s *FileService) readManifest(ctx context.Context, key string) (domain.ObjectMetadata, error) {
+	bucketName, err := s.manifestBucketName()
+	if err != nil {
+		return domain.ObjectMetadata{}, err
+	}
+	repo, err := s.placer.GetRepositoryForBucket(bucketName)
+	if err != nil {
+		return domain.ObjectMetadata{}, err
+	}
+
+	manifest, err := loadManifest(ctx, repo, key)
+	if err != nil {
+		return domain.ObjectMetadata{}, err
+	}
+	if s.signingKey != nil {
+		if err := VerifyManifest(manifest, &s.signingKey.PublicKey); err != nil {
+			return domain.ObjectMetadata{}, err
+		}
+	}
+	return manifest.Metadata, nil
+}
+
+// refreshManifest rewrites an object's manifest if one exists, keeping it in step with new metadata
+func (s *FileService) refreshManifest(ctx context.Context, key string, metadata domain.ObjectMetadata) error {
+	bucketName, err := s.manifestBucketName()
+	if err != nil {
+		return nil // No buckets, so no manifest to refresh
+	}
+	repo, err := s.placer.GetRepositoryForBucket(bucketName)
+	if err != nil {
+		return err
+	}
+	if _, err := repo.GetObjectSize(ctx, ManifestKey(key)); err != nil {
+		if stderrors.Is(err, errors.ErrObjectNotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.writeManifest(ctx, key, metadata)
+}
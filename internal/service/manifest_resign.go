@@ -0,0 +1,103 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements re-signing manifests when the signing key is rotated.
+//
+// ResignManifests walks the manifests under a prefix in the manifest bucket. Each one must
+// verify against the old key before it is signed with the new one, so rotation never
+// vouches for a manifest that was tampered with. The signature covers the canonical
+// serialization of the metadata as read back, which is exactly what verification checks,
+// so re-signed manifests verify without re-uploading any data. Manifests that already
+// verify against the new key are left alone, so an interrupted rotation can be rerun.
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+// ManifestResignFailure is a manifest that could not be re-signed
+type ManifestResignFailure struct {
+	Key string `json:"key"`
+	Err string `json:"error"`
+}
+
+// ManifestResignResult summarizes a manifest key rotation
+type ManifestResignResult struct {
+	Resigned []string                `json:"resigned"` // Object keys whose manifests now carry the new signature
+	Current  []string                `json:"current"`  // Object keys whose manifests were already signed with the new key
+	Failed   []ManifestResignFailure `json:"failed"`
+}
+
+// ResignManifests re-signs every manifest under prefix that verifies against oldKey with newKey
+// An empty prefix covers the whole manifest bucket. Manifests that fail verification
+// (unsigned, tampered or signed with another key) are reported and left unchanged.
+func (s *FileService) ResignManifests(ctx context.Context, prefix string, oldKey *ecdsa.PublicKey, newKey *ecdsa.PrivateKey) (ManifestResignResult, error) {
+	prefix = s.NormalizeKey(prefix)
+	if oldKey == nil || newKey == nil {
+		return ManifestResignResult{}, fmt.Errorf("both the old and the new signing key are required")
+	}
+	bucketName, err := s.manifestBucketName()
+	if err != nil {
+		return ManifestResignResult{}, err
+	}
+	repo, err := s.placer.GetRepositoryForBucket(bucketName)
+	if err != nil {
+		return ManifestResignResult{}, err
+	}
+
+	listPrefix := strings.TrimSuffix(prefix, "/")
+	if listPrefix != "" {
+		listPrefix += "/"
+	}
+	storageKeys, err := repo.ListKeys(ctx, listPrefix)
+	if err != nil {
+		return ManifestResignResult{}, fmt.Errorf("failed to list manifests in bucket %s: %w", bucketName, err)
+	}
+
+	var result ManifestResignResult
+	for _, storageKey := range storageKeys {
+		key, ok := strings.CutSuffix(storageKey, "/"+manifestFileName)
+		if !ok {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		current, err := resignManifest(ctx, repo, key, oldKey, newKey)
+		switch {
+		case err != nil:
+			log.Warnf("Manifest of %s not re-signed: %v", key, err)
+			result.Failed = append(result.Failed, ManifestResignFailure{Key: key, Err: err.Error()})
+		case current:
+			result.Current = append(result.Current, key)
+		default:
+			result.Resigned = append(result.Resigned, key)
+		}
+	}
+	return result, nil
+}
+
+// resignManifest re-signs one manifest, reporting whether it was already signed with the new key
+func resignManifest(ctx context.Context, repo objectstore.ObjectRepository, key string, oldKey *ecdsa.PublicKey, newKey *ecdsa.PrivateKey) (bool, error) {
+	manifest, err := loadManifest(ctx, repo, key)
+	if err != nil {
+		return false, err
+	}
+	if VerifyManifest(manifest, &newKey.PublicKey) == nil {
+		return true, nil
+	}
+	if err := VerifyManifest(manifest, oldKey); err != nil {
+		return false, fmt.Errorf("does not verify against the old key: %w", err)
+	}
+
+	resigned, err := BuildManifest(manifest.Metadata, newKey)
+	if err != nil {
+		return false, err
+	}
+	return false, storeManifest(ctx, repo, key, resigned)
+}
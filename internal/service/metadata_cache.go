@@ -0,0 +1,222 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements a local metadata cache used when the metadata store is unavailable.
+//
+// CachedMetadataRepository decorates a MetadataRepository with a bounded LRU cache.
+// Reads always go to the underlying store first so results stay fresh; the cache is
+// only served when the store returns an error other than "not found" (throttling,
+// network failures) and the cached entry is younger than the TTL. Writes and deletes
+// through the decorator update or invalidate the cached entry.
+package service
+
+import (
+	"container/list"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+// cacheEntry is a cached metadata record and the time it was stored
+type cacheEntry struct {
+	key      string
+	metadata domain.ObjectMetadata
+	storedAt time.Time
+}
+
+// CachedMetadataRepository serves recently accessed metadata during metadata store outages
+type CachedMetadataRepository struct {
+	inner   MetadataRepository
+	maxSize int
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Front is most recently used
+}
+
+// NewCachedMetadataRepository wraps a metadata repository with an LRU cache of maxSize entries
+func NewCachedMetadataRepository(inner MetadataRepository, maxSize int, ttl time.Duration) *CachedMetadataRepository {
+	return &CachedMetadataRepository{
+		inner:   inner,
+		maxSize: maxSize,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// CreateMetadata stores metadata and refreshes the cached copy
+func (c *CachedMetadataRepository) CreateMetadata(ctx context.Context, metadata domain.ObjectMetadata) (domain.ObjectMetadata, error) {
+	key := cacheKey(metadata.Prefix, metadata.FileName)
+	c.remove(key)
+
+	created, err := c.inner.CreateMetadata(ctx, metadata)
+	if err != nil {
+		return created, err
+	}
+	c.put(key, created)
+	return created, nil
+}
+
+// GetMetadata reads from the store, falling back to a fresh cached copy if the store fails
+func (c *CachedMetadataRepository) GetMetadata(ctx context.Context, prefix, fileName string) (domain.ObjectMetadata, error) {
+	key := cacheKey(prefix, fileName)
+
+	metadata, err := c.inner.GetMetadata(ctx, prefix, fileName)
+	if err == nil {
+		c.put(key, metadata)
+		return metadata, nil
+	}
+	if stderrors.Is(err, errors.ErrMetadataNotFound) {
+		c.remove(key)
+		return metadata, err
+	}
+
+	if cached, ok := c.get(key); ok {
+		log.Warnf("Metadata store unavailable (%v); serving cached metadata for %s", err, key)
+		return cached, nil
+	}
+	return metadata, err
+}
+
+// ListMetadataByPrefix is passed through uncached
+func (c *CachedMetadataRepository) ListMetadataByPrefix(ctx context.Context, prefix string) ([]domain.ObjectMetadata, error) {
+	return c.inner.ListMetadataByPrefix(ctx, prefix)
+}
+
+// ListMetadataSorted is passed through uncached, sorting in memory if the store cannot order listings
+func (c *CachedMetadataRepository) ListMetadataSorted(ctx context.Context, prefix string, sortBy domain.MetadataSortField, order domain.SortOrder) ([]domain.ObjectMetadata, error) {
+	if lister, ok := c.inner.(SortedMetadataLister); ok {
+		return lister.ListMetadataSorted(ctx, prefix, sortBy, order)
+	}
+	metadataList, err := c.inner.ListMetadataByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	domain.SortObjectMetadata(metadataList, sortBy, order)
+	return metadataList, nil
+}
+
+// ListPrefixes is passed through uncached
+// It fails with errors.ErrNotImplemented if the store cannot enumerate prefixes.
+func (c *CachedMetadataRepository) ListPrefixes(ctx context.Context, prefix string) ([]string, error) {
+	lister, ok := c.inner.(PrefixLister)
+	if !ok {
+		return nil, fmt.Errorf("metadata store cannot list prefixes: %w", errors.ErrNotImplemented)
+	}
+	return lister.ListPrefixes(ctx, prefix)
+}
+
+// UpdateMetadata stores metadata and refreshes the cached copy
+func (c *CachedMetadataRepository) UpdateMetadata(ctx context.Context, metadata domain.ObjectMetadata) (domain.ObjectMetadata, error) {
+	key := cacheKey(metadata.Prefix, metadata.FileName)
+	c.remove(key)
+
+	updated, err := c.inner.UpdateMetadata(ctx, metadata)
+	if err != nil {
+		return updated, err
+	}
+	c.put(key, updated)
+	return updated, nil
+}
+
+// DeleteMetadata removes metadata and its cached copy
+func (c *CachedMetadataRepository) DeleteMetadata(ctx context.Context, prefix, fileName string) error {
+	c.remove(cacheKey(prefix, fileName))
+	return c.inner.DeleteMetadata(ctx, prefix, fileName)
+}
+
+// Transact applies the writes atomically in the underlying store and updates the cached copies
+// It fails with errors.ErrNotImplemented if the store cannot apply writes atomically.
+func (c *CachedMetadataRepository) Transact(ctx context.Context, puts []domain.ObjectMetadata, deletes []domain.MetadataKey) error {
+	transactor, ok := c.inner.(MetadataTransactor)
+	if !ok {
+		return fmt.Errorf("metadata store cannot apply writes atomically: %w", errors.ErrNotImplemented)
+	}
+	for _, metadata := range puts {
+		c.remove(cacheKey(metadata.Prefix, metadata.FileName))
+	}
+	for _, key := range deletes {
+		c.remove(cacheKey(key.Prefix, key.FileName))
+	}
+
+	if err := transactor.Transact(ctx, puts, deletes); err != nil {
+		return err
+	}
+	for _, metadata := range puts {
+		c.put(cacheKey(metadata.Prefix, metadata.FileName), metadata)
+	}
+	return nil
+}
+
+// Len returns the number of cached entries
+func (c *CachedMetadataRepository) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// get returns a cached entry younger than the TTL, evicting it if expired
+func (c *CachedMetadataRepository) get(key string) (domain.ObjectMetadata, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return domain.ObjectMetadata{}, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if c.ttl > 0 && time.Since(entry.storedAt) > c.ttl {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return domain.ObjectMetadata{}, false
+	}
+	c.order.MoveToFront(element)
+	return entry.metadata, true
+}
+
+// put stores an entry as most recently used, evicting the least recently used beyond maxSize
+func (c *CachedMetadataRepository) put(key string, metadata domain.ObjectMetadata) {
+	if c.maxSize <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		entry.metadata = metadata
+		entry.storedAt = time.Now()
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, metadata: metadata, storedAt: time.Now()})
+	for c.order.Len() > c.maxSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// remove invalidates a cached entry
+func (c *CachedMetadataRepository) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+}
+
+// cacheKey identifies an object by prefix and file name
+func cacheKey(prefix, fileName string) string {
+	return prefix + "/" + fileName
+}
@@ -0,0 +1,182 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements retries of throttled and transiently failing metadata store requests.
+//
+// RetryingMetadataRepository decorates a MetadataRepository and retries calls that fail
+// with errors.ErrMetadataThrottled or errors.ErrMetadataUnavailable, backing off
+// exponentially with jitter between attempts. Other errors, including "not found", are
+// returned immediately. The retries are separate from shard transfers and from the SDK's
+// own retries, so a burst of throttling at the end of an upload does not discard shards
+// that were already stored. The same policy, through retryMetadataCall, drives the final
+// metadata write of an upload (see upload_rollback.go).
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+// maxMetadataRetryDelay caps the backoff between metadata retries
+const maxMetadataRetryDelay = 5 * time.Second
+
+// RetryingMetadataRepository retries throttled and transiently failing metadata store requests
+type RetryingMetadataRepository struct {
+	inner       MetadataRepository
+	maxAttempts int           // Total attempts per call, including the first
+	baseDelay   time.Duration // Backoff before the first retry, doubled for each further one
+}
+
+// NewRetryingMetadataRepository wraps a metadata repository so throttled and transiently failing calls are tried up to maxAttempts times
+func NewRetryingMetadataRepository(inner MetadataRepository, maxAttempts int, baseDelay time.Duration) *RetryingMetadataRepository {
+	return &RetryingMetadataRepository{
+		inner:       inner,
+		maxAttempts: max(maxAttempts, 1),
+		baseDelay:   baseDelay,
+	}
+}
+
+// CreateMetadata stores metadata, retrying while the store is throttling
+func (r *RetryingMetadataRepository) CreateMetadata(ctx context.Context, metadata domain.ObjectMetadata) (domain.ObjectMetadata, error) {
+	var created domain.ObjectMetadata
+	err := r.retry(ctx, "CreateMetadata", func() (err error) {
+		created, err = r.inner.CreateMetadata(ctx, metadata)
+		return err
+	})
+	return created, err
+}
+
+// GetMetadata reads metadata, retrying while the store is throttling
+func (r *RetryingMetadataRepository) GetMetadata(ctx context.Context, prefix, fileName string) (domain.ObjectMetadata, error) {
+	var metadata domain.ObjectMetadata
+	err := r.retry(ctx, "GetMetadata", func() (err error) {
+		metadata, err = r.inner.GetMetadata(ctx, prefix, fileName)
+		return err
+	})
+	return metadata, err
+}
+
+// ListMetadataByPrefix lists metadata, retrying while the store is throttling
+func (r *RetryingMetadataRepository) ListMetadataByPrefix(ctx context.Context, prefix string) ([]domain.ObjectMetadata, error) {
+	var metadataList []domain.ObjectMetadata
+	err := r.retry(ctx, "ListMetadataByPrefix", func() (err error) {
+		metadataList, err = r.inner.ListMetadataByPrefix(ctx, prefix)
+		return err
+	})
+	return metadataList, err
+}
+
+// ListMetadataSorted lists ordered metadata, sorting in memory if the store cannot order listings
+func (r *RetryingMetadataRepository) ListMetadataSorted(ctx context.Context, prefix string, sortBy domain.MetadataSortField, order domain.SortOrder) ([]domain.ObjectMetadata, error) {
+	lister, ok := r.inner.(SortedMetadataLister)
+	if !ok {
+		metadataList, err := r.ListMetadataByPrefix(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		domain.SortObjectMetadata(metadataList, sortBy, order)
+		return metadataList, nil
+	}
+
+	var metadataList []domain.ObjectMetadata
+	err := r.retry(ctx, "ListMetadataSorted", func() (err error) {
+		metadataList, err = lister.ListMetadataSorted(ctx, prefix, sortBy, order)
+		return err
+	})
+	return metadataList, err
+}
+
+// ListPrefixes lists the prefixes under prefix, retrying while the store is throttling
+// It fails with errors.ErrNotImplemented if the store cannot enumerate prefixes.
+func (r *RetryingMetadataRepository) ListPrefixes(ctx context.Context, prefix string) ([]string, error) {
+	lister, ok := r.inner.(PrefixLister)
+	if !ok {
+		return nil, fmt.Errorf("metadata store cannot list prefixes: %w", errors.ErrNotImplemented)
+	}
+	var prefixes []string
+	err := r.retry(ctx, "ListPrefixes", func() (err error) {
+		prefixes, err = lister.ListPrefixes(ctx, prefix)
+		return err
+	})
+	return prefixes, err
+}
+
+// UpdateMetadata replaces metadata, retrying while the store is throttling
+func (r *RetryingMetadataRepository) UpdateMetadata(ctx context.Context, metadata domain.ObjectMetadata) (domain.ObjectMetadata, error) {
+	var updated domain.ObjectMetadata
+	err := r.retry(ctx, "UpdateMetadata", func() (err error) {
+		updated, err = r.inner.UpdateMetadata(ctx, metadata)
+		return err
+	})
+	return updated, err
+}
+
+// DeleteMetadata removes metadata, retrying while the store is throttling
+func (r *RetryingMetadataRepository) DeleteMetadata(ctx context.Context, prefix, fileName string) error {
+	return r.retry(ctx, "DeleteMetadata", func() error {
+		return r.inner.DeleteMetadata(ctx, prefix, fileName)
+	})
+}
+
+// Transact applies the writes atomically, retrying the whole transaction while the store is throttling
+// It fails with errors.ErrNotImplemented if the store cannot apply writes atomically.
+func (r *RetryingMetadataRepository) Transact(ctx context.Context, puts []domain.ObjectMetadata, deletes []domain.MetadataKey) error {
+	transactor, ok := r.inner.(MetadataTransactor)
+	if !ok {
+		return fmt.Errorf("metadata store cannot apply writes atomically: %w", errors.ErrNotImplemented)
+	}
+	return r.retry(ctx, "Transact", func() error {
+		return transactor.Transact(ctx, puts, deletes)
+	})
+}
+
+// retry runs call until it succeeds, fails with an error that is not transient, or runs out of attempts
+func (r *RetryingMetadataRepository) retry(ctx context.Context, operation string, call func() error) error {
+	return retryMetadataCall(ctx, operation, r.maxAttempts, r.baseDelay, call)
+}
+
+// transientMetadataError reports whether a metadata store error is worth retrying: the store
+// throttled the request or was temporarily unavailable. Any other error is returned as is.
+func transientMetadataError(err error) bool {
+	return stderrors.Is(err, errors.ErrMetadataThrottled) || stderrors.Is(err, errors.ErrMetadataUnavailable)
+}
+
+// retryMetadataCall runs call up to maxAttempts times while it fails with a transient error,
+// backing off from baseDelay between attempts. It gives up early if ctx is done.
+func retryMetadataCall(ctx context.Context, operation string, maxAttempts int, baseDelay time.Duration, call func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = call()
+		if err == nil || !transientMetadataError(err) || attempt >= maxAttempts {
+			return err
+		}
+
+		delay := metadataBackoff(baseDelay, attempt)
+		log.Warnf("%s failed (attempt %d of %d); retrying in %v: %v", operation, attempt, maxAttempts, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// metadataBackoff returns the delay before the retry following the given attempt
+// The delay doubles with each attempt up to maxMetadataRetryDelay, and a random amount up to
+// half of it is dropped so that processes throttled together do not retry in lockstep.
+func metadataBackoff(baseDelay time.Duration, attempt int) time.Duration {
+	delay := baseDelay
+	for i := 1; i < attempt && delay < maxMetadataRetryDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxMetadataRetryDelay)
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
@@ -0,0 +1,119 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements downloading an object to several destinations at once.
+//
+// Fan-out restores, such as writing an object to local disk while re-uploading it to another
+// store, would otherwise reconstruct it once per destination. DownloadFileMulti reconstructs
+// once and writes the object to every destination in chunks, each chunk going to all of them
+// before the next, much as io.MultiWriter does. By default the first destination that fails
+// stops the writes to all of them. A context from WithContinueOnDestinationError instead
+// drops only the failed destination and finishes writing the others.
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+// DestinationError reports a destination of DownloadFileMulti that could not be written
+type DestinationError struct {
+	Index int // Position of the destination in the call
+	Err   error
+}
+
+func (e *DestinationError) Error() string {
+	return fmt.Sprintf("destination %d: %v", e.Index, e.Err)
+}
+
+func (e *DestinationError) Unwrap() error {
+	return e.Err
+}
+
+// continueOnDestinationErrorKey marks a context whose multi-destination downloads keep going past a failed destination
+type continueOnDestinationErrorKey struct{}
+
+// WithContinueOnDestinationError makes DownloadFileMulti calls run with the returned context
+// finish writing the remaining destinations when one of them fails
+func WithContinueOnDestinationError(ctx context.Context) context.Context {
+	return context.WithValue(ctx, continueOnDestinationErrorKey{}, true)
+}
+
+// DownloadFileMulti reconstructs an object once and writes it to every destination
+// A failed destination is reported as a *DestinationError. Without
+// WithContinueOnDestinationError, the first failure stops the writes to all destinations
+// and is returned; with it, the other destinations are written in full and the failures
+// are returned joined.
+func (s *FileService) DownloadFileMulti(ctx context.Context, key string, quiet bool, verifyIntegrity bool, dests ...io.Writer) (err error) {
+	key = s.NormalizeKey(key)
+	start := time.Now()
+	var size int64
+	defer func() { s.audit(ctx, domain.AuditDownload, key, size, start, err) }()
+
+	if len(dests) == 0 {
+		return fmt.Errorf("no download destinations given")
+	}
+	if len(s.placer.ListBuckets()) == 0 {
+		return errors.ErrNoBucketsRegistered
+	}
+
+	metadata, err := s.lookupMetadata(ctx, key)
+	if err != nil {
+		return err
+	}
+	size = metadata.ContentSize()
+
+	ctx, release, err := s.AcquireDownloadSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	data, err := s.objectContent(ctx, metadata, quiet, verifyIntegrity)
+	if err != nil {
+		return err
+	}
+
+	progress := progressReporter(ctx)
+	progress.StartPhase(PhaseWriting, int64(len(data)), 0)
+	continueOnError, _ := ctx.Value(continueOnDestinationErrorKey{}).(bool)
+	return s.writeToAll(dests, data, quiet, continueOnError, progress)
+}
+
+// writeToAll writes data to every destination chunk by chunk
+func (s *FileService) writeToAll(dests []io.Writer, data []byte, quiet, continueOnError bool, progress ProgressReporter) error {
+	bar := s.reconstructionProgress(quiet).bar(int64(len(data)), "writing", true)
+	chunk := progressWriteChunk
+	if s.writeBufferSize > 0 {
+		chunk = s.writeBufferSize
+	}
+
+	failed := make([]error, len(dests))
+	live := len(dests)
+	for offset := 0; offset < len(data) && live > 0; offset += chunk {
+		end := min(offset+chunk, len(data))
+		for i, dest := range dests {
+			if failed[i] != nil {
+				continue
+			}
+			if _, err := dest.Write(data[offset:end]); err != nil {
+				failed[i] = &DestinationError{Index: i, Err: err}
+				if !continueOnError {
+					return failed[i]
+				}
+				live--
+			}
+		}
+		bar.Add(end - offset)
+		progress.Advance(int64(end-offset), 0)
+	}
+
+	if err := stderrors.Join(failed...); err != nil {
+		return err
+	}
+	return bar.Finish()
+}
@@ -0,0 +1,66 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements per-upload restriction of shard placement to chosen buckets.
+//
+// An upload run with a context from WithPlacementBuckets places its shards only in the
+// listed buckets, in the configured placer's order (see placement.SubsetPlacer). Nothing
+// extra is recorded: the object's metadata already names the bucket of every shard, so
+// reads, repairs and deletes find them as usual. Later re-encodes and repairs use the
+// default placement again.
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/placement"
+)
+
+// placementBucketsKey is the context key of the buckets an upload is restricted to
+type placementBucketsKey struct{}
+
+// WithPlacementBuckets restricts uploads run with the returned context to the given buckets
+// The buckets must be registered, and must be enough for the upload's shard configuration:
+// at least the mirror factor, and enough that losing any one of them leaves the object
+// readable. Uploads fail before reading their input otherwise. An empty list keeps the
+// default placement.
+func WithPlacementBuckets(ctx context.Context, buckets []string) context.Context {
+	if len(buckets) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, placementBucketsKey{}, append([]string(nil), buckets...))
+}
+
+// placerFor returns the placer for an upload run with ctx
+func (s *FileService) placerFor(ctx context.Context) (placement.Placer, error) {
+	buckets, ok := ctx.Value(placementBucketsKey{}).([]string)
+	if !ok {
+		return s.placer, nil
+	}
+	return placement.NewSubsetPlacer(s.placer, buckets)
+}
+
+// checkUploadPlacement checks that an upload run with ctx can place its shards
+// Unlike the configured buckets, which only warn when a bucket would hold more shards
+// than parity covers, an explicit bucket list that small is rejected.
+func (s *FileService) checkUploadPlacement(ctx context.Context, dataShards, parityShards int) error {
+	placer, err := s.placerFor(ctx)
+	if err != nil {
+		return err
+	}
+	if placer == s.placer {
+		return s.checkPlacement(dataShards, parityShards)
+	}
+	if err := s.checkPlacementIn(placer, dataShards, parityShards); err != nil {
+		return err
+	}
+
+	bucketCount := len(placer.ListBuckets())
+	totalShards := dataShards + parityShards
+	// Without parity no placement survives losing a bucket, so there is nothing to check
+	if shardsPerBucket := (totalShards + bucketCount - 1) / bucketCount; parityShards > 0 && shardsPerBucket > parityShards {
+		needed := (totalShards + parityShards - 1) / parityShards
+		return fmt.Errorf("%w: %d+%d shards need at least %d buckets, %d given", errors.ErrPlacementTooFewBuckets, dataShards, parityShards, needed, bucketCount)
+	}
+	return nil
+}
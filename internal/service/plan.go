@@ -0,0 +1,75 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements placement previews (dry runs) for uploads.
+package service
+
+import (
+	"fmt"
+
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+// ShardPlan is where one shard of an upload would be stored
+type ShardPlan struct {
+	Shard   int      `json:"shard"`
+	Parity  bool     `json:"parity"`
+	Buckets []string `json:"buckets"` // One bucket per copy, primary first
+}
+
+// PlacementPlan previews the placement of an upload's shards
+type PlacementPlan struct {
+	DataShards         int         `json:"data_shards"` // After min_shard_size adjustment when a size is given
+	ParityShards       int         `json:"parity_shards"`
+	Shards             []ShardPlan `json:"shards"`
+	MaxShardsLost      int         `json:"max_shards_lost"`      // Most shards left with no copy when a single bucket is lost
+	SurvivesBucketLoss bool        `json:"survives_bucket_loss"` // Losing any single bucket leaves enough shards
+}
+
+// PlanPlacement reports which buckets an upload's shards would be stored in, without uploading
+// The active placement strategy and mirror factor are applied exactly as an upload would,
+// but placers are not asked to refresh any state. When size is positive, the data shard
+// count is reduced as min_shard_size requires for an object of that size.
+func (s *FileService) PlanPlacement(size int64, dataShards, parityShards int) (PlacementPlan, error) {
+	if err := s.checkPlacement(dataShards, parityShards); err != nil {
+		return PlacementPlan{}, err
+	}
+	if size > 0 {
+		dataShards = EffectiveDataShards(size, dataShards, s.minShardSize)
+	}
+
+	totalShards := dataShards + parityShards
+	bucketCount := len(s.placer.ListBuckets())
+	preview, err := s.placer.PreviewPlacement(totalShards + s.mirrorFactor + bucketCount)
+	if err != nil {
+		return PlacementPlan{}, err
+	}
+	place := func(index int) (string, objectstore.ObjectRepository, error) {
+		if index >= len(preview) {
+			return "", nil, fmt.Errorf("shard index %d outside the previewed placement", index)
+		}
+		return preview[index], nil, nil
+	}
+
+	plan := PlacementPlan{DataShards: dataShards, ParityShards: parityShards}
+	for i := 0; i < totalShards; i++ {
+		copies, err := distinctCopies(i, s.mirrorFactor, bucketCount, place)
+		if err != nil {
+			return PlacementPlan{}, err
+		}
+		shard := ShardPlan{Shard: i, Parity: i >= dataShards}
+		for _, placement := range copies {
+			shard.Buckets = append(shard.Buckets, placement.bucketName)
+		}
+		plan.Shards = append(plan.Shards, shard)
+	}
+
+	// A lost bucket only takes a shard with it if it holds every copy of that shard
+	shardsLost := make(map[string]int)
+	for _, shard := range plan.Shards {
+		if len(shard.Buckets) == 1 {
+			shardsLost[shard.Buckets[0]]++
+			plan.MaxShardsLost = max(plan.MaxShardsLost, shardsLost[shard.Buckets[0]])
+		}
+	}
+	plan.SurvivesBucketLoss = plan.MaxShardsLost <= parityShards
+	return plan, nil
+}
@@ -0,0 +1,102 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements per-download restriction of shard reads to chosen providers.
+//
+// During a provider incident a slow provider can hold a download up until its requests time
+// out. A download run with a context from WithProviderFilter only reads shard copies whose
+// location names an allowed storage type (Location.StorageType, e.g. "s3" or "gcs"); copies
+// on other providers are skipped without a request, like copies in unregistered buckets.
+// When the allowed copies cover fewer shards than reconstruction needs, the download fails
+// with errors.ErrInsufficientShards before reading anything. Objects already in the
+// reconstruction cache are served from it as usual.
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+// providerFilterKey is the context key of the providers a download may read from
+type providerFilterKey struct{}
+
+// providerFilter selects the storage types a download may read shard copies from
+type providerFilter struct {
+	include []string // Only these storage types, when not empty
+	exclude []string // Never these storage types
+}
+
+// WithProviderFilter restricts downloads run with the returned context to chosen providers
+// With include set, only shard copies stored on those storage types are read; copies on
+// the storage types in exclude are never read. Both empty keeps reading from every provider.
+func WithProviderFilter(ctx context.Context, include, exclude []string) context.Context {
+	if len(include) == 0 && len(exclude) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, providerFilterKey{}, providerFilter{
+		include: append([]string(nil), include...),
+		exclude: append([]string(nil), exclude...),
+	})
+}
+
+// allows reports whether a copy on the given storage type may be read
+func (f providerFilter) allows(storageType string) bool {
+	if len(f.include) > 0 && !slices.Contains(f.include, storageType) {
+		return false
+	}
+	return !slices.Contains(f.exclude, storageType)
+}
+
+// readableLocations returns the copies of a shard a download run with ctx may read, primary first
+func (s *FileService) readableLocations(ctx context.Context, shard domain.ShardStorage) []domain.Location {
+	filter, ok := ctx.Value(providerFilterKey{}).(providerFilter)
+	if !ok {
+		return shard.Locations
+	}
+	var locations []domain.Location
+	for _, location := range shard.Locations {
+		if filter.allows(s.locationStorageType(location)) {
+			locations = append(locations, location)
+		}
+	}
+	return locations
+}
+
+// locationStorageType returns the storage type of a shard copy
+// Locations recorded without one are attributed to their bucket's current repository.
+func (s *FileService) locationStorageType(location domain.Location) string {
+	if location.StorageType != "" {
+		return location.StorageType
+	}
+	if repo, err := s.placer.GetRepositoryForBucket(location.BucketName); err == nil {
+		return repo.GetStorageType()
+	}
+	return ""
+}
+
+// checkProviderFilter fails when the providers a download may read from hold too few shards
+func (s *FileService) checkProviderFilter(ctx context.Context, metadata domain.ObjectMetadata) error {
+	if _, ok := ctx.Value(providerFilterKey{}).(providerFilter); !ok {
+		return nil
+	}
+	readable := 0
+	for _, shard := range metadata.ShardHashes {
+		if len(s.readableLocations(ctx, shard)) > 0 {
+			readable++
+		}
+	}
+	if needed := metadata.RequiredShards(); readable < needed {
+		return fmt.Errorf("%w: the selected providers hold %d of %s/%s's shards, %d needed", errors.ErrInsufficientShards, readable, metadata.Prefix, metadata.FileName, needed)
+	}
+	return nil
+}
+
+// noReadableCopiesError describes a shard none of whose copies a download may read
+func noReadableCopiesError(shard domain.ShardStorage) error {
+	if len(shard.Locations) == 0 {
+		return fmt.Errorf("shard has no stored copies")
+	}
+	return fmt.Errorf("shard has no copies on the selected providers")
+}
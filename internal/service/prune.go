@@ -0,0 +1,59 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements deleting the objects under a prefix that are older than a cutoff.
+//
+// Pruning is age-based retention that zstore enforces itself: unlike an expiry attribute
+// on the metadata table, it also removes the shards, and it works on any metadata store.
+// Age is the upload time recorded in CreatedAt. Objects uploaded before upload times were
+// recorded have none, so their age is unknown and they are never pruned; they are
+// reported separately so they can be deleted by hand. Candidates are deleted through
+// DeleteFile like DeletePrefix does, so soft delete, retention locks and auditing apply.
+package service
+
+import (
+	"context"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/domain"
+)
+
+// PruneResult summarizes a Prune call
+type PruneResult struct {
+	PrefixDeleteResult
+	Undated []string `json:"undated"` // Sorted keys of objects without an upload time, which were kept
+}
+
+// PruneCandidates lists the objects under prefix uploaded before cutoff, oldest first
+// Objects without a recorded upload time are returned separately in undated.
+func (s *FileService) PruneCandidates(ctx context.Context, prefix string, cutoff time.Time) (old, undated []domain.ObjectMetadata, err error) {
+	files, err := s.ListFilesSorted(ctx, prefix, domain.SortByDate, domain.SortAscending)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, file := range files {
+		switch {
+		case file.CreatedAt.IsZero():
+			undated = append(undated, file)
+		case file.CreatedAt.Before(cutoff):
+			old = append(old, file)
+		}
+	}
+	return old, undated, nil
+}
+
+// Prune deletes every object under prefix uploaded before cutoff, deleteWorkers() at a time
+// The error is only set when the objects could not be listed.
+func (s *FileService) Prune(ctx context.Context, prefix string, cutoff time.Time) (PruneResult, error) {
+	old, undated, err := s.PruneCandidates(ctx, prefix, cutoff)
+	if err != nil {
+		return PruneResult{}, err
+	}
+
+	result := PruneResult{PrefixDeleteResult: s.deleteObjects(ctx, old), Undated: []string{}}
+	for _, file := range undated {
+		result.Undated = append(result.Undated, path.Join(file.Prefix, file.FileName))
+	}
+	sort.Strings(result.Undated)
+	return result, nil
+}
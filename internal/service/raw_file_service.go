@@ -37,6 +37,11 @@ func NewRawFileService(factory *objectstore.ObjectRepositoryFactory) *RawFileSer
 	}
 }
 
+// SetProgressOutput routes progress bars of raw transfers to w (io.Discard disables them)
+func (r *RawFileService) SetProgressOutput(w io.Writer) {
+	r.factory.SetProgressOutput(w)
+}
+
 // UploadToRepository uploads a file directly to a repository without erasure coding
 func (r *RawFileService) UploadToRepository(ctx context.Context, bucketName, key string, reader io.Reader, quiet bool, providerType objectstore.RepositoryType, region string) error {
 	log.Debugf("Uploading raw file %s to bucket %s", key, bucketName)
@@ -0,0 +1,100 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements progress reporting for the phases of a download after the shard transfers.
+//
+// Once its shards are on disk, a download still reads them back, runs Reed-Solomon
+// reconstruction when shards are missing, joins the data shards into the original
+// bytes and writes them to the destination. For large objects each of these takes long
+// enough that a silent terminal looks hung, so every phase gets its own bar on the
+// same sink as the transfer bars. The quiet flag of the download suppresses them all.
+package service
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// progressWriteChunk is how much of a reconstructed object is written per call when progress is shown
+const progressWriteChunk = 4 * 1024 * 1024
+
+// phaseProgress creates progress bars for the phases that follow the shard transfers
+type phaseProgress struct {
+	writer io.Writer // nil uses the progressbar default (stderr)
+	quiet  bool
+}
+
+// reconstructionProgress returns the phase progress of a download with the given quiet flag
+func (s *FileService) reconstructionProgress(quiet bool) phaseProgress {
+	return phaseProgress{writer: s.progressWriter, quiet: quiet}
+}
+
+// bar creates a progress bar for one phase, or a silent one when the download is quiet
+// Bars counting bytes match the transfer bars; the others count items such as shards.
+func (p phaseProgress) bar(size int64, description string, bytes bool) *progressbar.ProgressBar {
+	if p.quiet || p.writer == io.Discard {
+		return progressbar.DefaultSilent(size, description)
+	}
+	if p.writer == nil {
+		if bytes {
+			return progressbar.DefaultBytes(size, description)
+		}
+		return progressbar.Default(size, description)
+	}
+
+	writer := p.writer
+	return progressbar.NewOptions64(
+		size,
+		progressbar.OptionSetDescription(description),
+		progressbar.OptionSetWriter(writer),
+		progressbar.OptionShowBytes(bytes),
+		progressbar.OptionShowTotalBytes(bytes),
+		progressbar.OptionSetWidth(10),
+		progressbar.OptionThrottle(65*time.Millisecond),
+		progressbar.OptionShowCount(),
+		progressbar.OptionOnCompletion(func() {
+			fmt.Fprint(writer, "\n")
+		}),
+		progressbar.OptionSpinnerType(14),
+		progressbar.OptionFullWidth(),
+		progressbar.OptionSetRenderBlankState(true),
+	)
+}
+
+// writeReconstructed writes a reconstructed object to dest, advancing a "writing" bar unless quiet
+// Without a write buffer a single WriteAt would move the bar from empty to full in one
+// step, so a download that shows progress writes in chunks instead.
+func (s *FileService) writeReconstructed(dest io.WriterAt, data []byte, quiet bool) error {
+	bar := s.reconstructionProgress(quiet).bar(int64(len(data)), "writing", true)
+	dest = progressWriterAt{dest: dest, bar: bar}
+
+	var err error
+	switch {
+	case s.writeBufferSize > 0:
+		err = writeBuffered(dest, data, s.writeBufferSize)
+	case quiet:
+		_, err = dest.WriteAt(data, 0)
+	default:
+		for offset := 0; offset < len(data) && err == nil; offset += progressWriteChunk {
+			end := min(offset+progressWriteChunk, len(data))
+			_, err = dest.WriteAt(data[offset:end], int64(offset))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return bar.Finish()
+}
+
+// progressWriterAt advances a progress bar by the bytes written through it
+type progressWriterAt struct {
+	dest io.WriterAt
+	bar  *progressbar.ProgressBar
+}
+
+func (w progressWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	n, err := w.dest.WriteAt(p, off)
+	w.bar.Add(n)
+	return n, err
+}
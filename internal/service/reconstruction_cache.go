@@ -0,0 +1,299 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements a read-through cache of reconstructed objects on a local disk.
+//
+// Reconstructing an object downloads at least its data shard count of shards and may
+// decode them; for hot objects read over and over (e.g. through the gateway) a copy of
+// the reconstructed bytes on a fast local disk skips all of that. Entries are keyed by
+// object key and ETag, so an object that is uploaded again or re-encoded is never served
+// stale, and uploads, deletes and re-encodes also drop the key's entries right away to
+// free the space. Every hit is checked against the object's whole-file hash, so a
+// corrupted cache file is dropped and read from the buckets instead.
+//
+// Layout: <dir>/<hash of key>/<etag>. The index is rebuilt from the directory when the
+// cache is opened, so a cache directory is shared by successive CLI runs; it should not
+// be shared by processes running at the same time, since each evicts on its own.
+package service
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/domain"
+)
+
+// reconstructionCacheTempPrefix marks cache files still being written
+const reconstructionCacheTempPrefix = ".tmp-"
+
+// reconstructionEntry is one cached object on disk
+type reconstructionEntry struct {
+	path     string
+	size     int64
+	storedAt time.Time
+}
+
+// ReconstructionCache keeps reconstructed objects on local disk, bounded by total size and age
+type ReconstructionCache struct {
+	dir      string
+	maxBytes int64
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // By path
+	order   *list.List               // Front is most recently used
+	size    int64                    // Bytes of every indexed entry
+}
+
+// NewReconstructionCache opens a cache of at most maxBytes in dir, whose entries expire after ttl
+// The directory is created if needed and entries left by earlier runs are indexed, oldest
+// first. A ttl of 0 keeps entries until they are evicted.
+func NewReconstructionCache(dir string, maxBytes int64, ttl time.Duration) (*ReconstructionCache, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("reconstruction cache size must be positive, got %d", maxBytes)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create reconstruction cache directory: %w", err)
+	}
+
+	c := &ReconstructionCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+
+	var found []*reconstructionEntry
+	keyDirs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, keyDir := range keyDirs {
+		if !keyDir.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(dir, keyDir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			path := filepath.Join(dir, keyDir.Name(), file.Name())
+			info, err := file.Info()
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			if strings.HasPrefix(file.Name(), reconstructionCacheTempPrefix) {
+				// Left behind by a run that stopped while writing it
+				os.Remove(path)
+				continue
+			}
+			found = append(found, &reconstructionEntry{path: path, size: info.Size(), storedAt: info.ModTime()})
+		}
+	}
+
+	// Oldest at the back, so they are evicted first
+	sort.Slice(found, func(i, j int) bool { return found[i].storedAt.Before(found[j].storedAt) })
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range found {
+		c.entries[entry.path] = c.order.PushFront(entry)
+		c.size += entry.size
+	}
+	c.evict()
+	return c, nil
+}
+
+// Len returns the number of cached objects
+func (c *ReconstructionCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Size returns the bytes of every cached object
+func (c *ReconstructionCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// keyDir returns the directory holding a key's entries
+func (c *ReconstructionCache) keyDir(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:16]))
+}
+
+// get returns an object's cached bytes if they are fresh and match its whole-file hash
+func (c *ReconstructionCache) get(metadata domain.ObjectMetadata) ([]byte, bool) {
+	path := filepath.Join(c.keyDir(objectKey(metadata)), metadata.ETag())
+
+	c.mu.Lock()
+	element, ok := c.entries[path]
+	if ok {
+		entry := element.Value.(*reconstructionEntry)
+		if c.ttl > 0 && time.Since(entry.storedAt) > c.ttl {
+			c.removeElement(element)
+			ok = false
+		} else {
+			c.order.MoveToFront(element)
+		}
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		err = verifyReconstructedSize(data, metadata)
+	}
+	if err == nil && metadata.FileHash != "" {
+		err = verifyFileIntegrity(data, metadata.FileHash)
+	}
+	if err != nil {
+		log.Warnf("Dropping cached copy of %s: %v", objectKey(metadata), err)
+		c.drop(path)
+		return nil, false
+	}
+	return data, true
+}
+
+// put caches an object's reconstructed bytes, evicting the least recently used beyond the size limit
+// Objects larger than the whole cache are not cached. Failures are logged, since the
+// object itself was read successfully.
+func (c *ReconstructionCache) put(metadata domain.ObjectMetadata, data []byte) {
+	if int64(len(data)) > c.maxBytes {
+		return
+	}
+	keyDir := c.keyDir(objectKey(metadata))
+	path := filepath.Join(keyDir, metadata.ETag())
+	if err := writeCacheFile(keyDir, path, data); err != nil {
+		log.Warnf("Failed to cache %s: %v", objectKey(metadata), err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[path]; ok {
+		entry := element.Value.(*reconstructionEntry)
+		c.size += int64(len(data)) - entry.size
+		entry.size, entry.storedAt = int64(len(data)), time.Now()
+		c.order.MoveToFront(element)
+	} else {
+		c.entries[path] = c.order.PushFront(&reconstructionEntry{path: path, size: int64(len(data)), storedAt: time.Now()})
+		c.size += int64(len(data))
+	}
+	c.evict()
+}
+
+// writeCacheFile writes data to path through a temp file, so readers never see a partial entry
+func writeCacheFile(keyDir, path string, data []byte) error {
+	if err := os.MkdirAll(keyDir, 0o700); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(keyDir, reconstructionCacheTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return err
+}
+
+// invalidate drops every cached version of a key
+func (c *ReconstructionCache) invalidate(key string) {
+	keyDir := c.keyDir(key)
+
+	c.mu.Lock()
+	for path, element := range c.entries {
+		if filepath.Dir(path) == keyDir {
+			c.removeElement(element)
+		}
+	}
+	c.mu.Unlock()
+
+	if err := os.RemoveAll(keyDir); err != nil {
+		log.Warnf("Failed to remove cached copies of %s: %v", key, err)
+	}
+}
+
+// drop removes one entry and its file
+func (c *ReconstructionCache) drop(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[path]; ok {
+		c.removeElement(element)
+	}
+}
+
+// evict removes least recently used entries until the cache fits its size limit; c.mu must be held
+func (c *ReconstructionCache) evict() {
+	for c.size > c.maxBytes && c.order.Len() > 0 {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// removeElement removes an entry from the index and deletes its file; c.mu must be held
+func (c *ReconstructionCache) removeElement(element *list.Element) {
+	entry := element.Value.(*reconstructionEntry)
+	c.order.Remove(element)
+	delete(c.entries, entry.path)
+	c.size -= entry.size
+	if err := os.Remove(entry.path); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Failed to remove cached object %s: %v", entry.path, err)
+	}
+}
+
+// objectKey returns the key an object's metadata is stored under
+func objectKey(metadata domain.ObjectMetadata) string {
+	return cacheKey(metadata.Prefix, metadata.FileName)
+}
+
+// SetReconstructionCache serves repeated reads of an object from a local copy of its reconstructed bytes
+// DownloadFile, StreamFile and DownloadRange check the cache before touching any shard;
+// whole-object reads fill it on a miss. nil disables caching.
+func (s *FileService) SetReconstructionCache(cache *ReconstructionCache) {
+	s.reconstructionCache = cache
+}
+
+// cachedObject returns an object's bytes from the reconstruction cache, if enabled and valid
+func (s *FileService) cachedObject(metadata domain.ObjectMetadata) ([]byte, bool) {
+	if s.reconstructionCache == nil {
+		return nil, false
+	}
+	data, ok := s.reconstructionCache.get(metadata)
+	if ok {
+		log.Debugf("Serving %s from the reconstruction cache", objectKey(metadata))
+	}
+	return data, ok
+}
+
+// cacheObject stores an object's reconstructed bytes in the reconstruction cache, if enabled
+func (s *FileService) cacheObject(metadata domain.ObjectMetadata, data []byte) {
+	if s.reconstructionCache != nil {
+		s.reconstructionCache.put(metadata, data)
+	}
+}
+
+// invalidateCachedObject drops a key's cached bytes after it was uploaded, deleted or re-encoded
+func (s *FileService) invalidateCachedObject(key string) {
+	if s.reconstructionCache != nil {
+		s.reconstructionCache.invalidate(cacheKey(filepath.Dir(key), filepath.Base(key)))
+	}
+}
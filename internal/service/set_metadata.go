@@ -0,0 +1,111 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements changing an object's descriptive metadata without re-uploading it.
+//
+// The content type and user metadata (tags) only describe an object; they are not part
+// of its shards, hashes or ETag. UpdateMetadataFields reads the stored record, applies a
+// MetadataUpdate and writes the record back, so no shard is read or written. The update
+// type has no way to express a change to the shard layout, size or hashes, which would
+// make the record disagree with the stored shards. A manifest stored for the object is
+// rewritten as well, so the manifest fallback serves the same metadata.
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"mime"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+// MetadataUpdate - changes to an object's descriptive metadata
+type MetadataUpdate struct {
+	ContentType *string           // New MIME type; "" clears it so it is derived from the original name again (nil keeps it)
+	SetTags     map[string]string // User metadata to add or replace
+	RemoveTags  []string          // User metadata names to remove; applied before SetTags
+}
+
+// Empty reports whether the update changes nothing
+func (u MetadataUpdate) Empty() bool {
+	return u.ContentType == nil && len(u.SetTags) == 0 && len(u.RemoveTags) == 0
+}
+
+// Validate checks that the update can be stored and served as HTTP headers
+// Tag names must be HTTP header tokens, since the gateway sends them as X-Zstore-Meta-<name>,
+// and tag values and the content type must not contain control characters.
+func (u MetadataUpdate) Validate() error {
+	if u.Empty() {
+		return fmt.Errorf("%w: nothing to change", errors.ErrInvalidMetadataUpdate)
+	}
+	if u.ContentType != nil && *u.ContentType != "" {
+		if _, _, err := mime.ParseMediaType(*u.ContentType); err != nil {
+			return fmt.Errorf("%w: content type %q: %v", errors.ErrInvalidMetadataUpdate, *u.ContentType, err)
+		}
+	}
+	for name, value := range u.SetTags {
+		if !domain.ValidMetadataName(name) {
+			return fmt.Errorf("%w: tag name %q is not an HTTP header token", errors.ErrInvalidMetadataUpdate, name)
+		}
+		if strings.ContainsFunc(value, isControl) {
+			return fmt.Errorf("%w: value of tag %q contains control characters", errors.ErrInvalidMetadataUpdate, name)
+		}
+	}
+	return nil
+}
+
+// apply returns metadata with the update applied
+func (u MetadataUpdate) apply(metadata domain.ObjectMetadata) domain.ObjectMetadata {
+	if u.ContentType != nil {
+		metadata.ContentType = *u.ContentType
+	}
+
+	tags := maps.Clone(metadata.UserMetadata)
+	for _, name := range u.RemoveTags {
+		delete(tags, name)
+	}
+	if len(u.SetTags) > 0 && tags == nil {
+		tags = make(map[string]string, len(u.SetTags))
+	}
+	maps.Copy(tags, u.SetTags)
+	if len(tags) == 0 {
+		tags = nil
+	}
+	metadata.UserMetadata = tags
+	return metadata
+}
+
+// UpdateMetadataFields changes an object's content type and tags without touching its shards
+// It returns the updated metadata. Objects in the trash cannot be changed.
+func (s *FileService) UpdateMetadataFields(ctx context.Context, key string, update MetadataUpdate) (domain.ObjectMetadata, error) {
+	if err := update.Validate(); err != nil {
+		return domain.ObjectMetadata{}, err
+	}
+	key = s.NormalizeKey(key)
+	metadata, err := s.getLiveMetadata(ctx, key)
+	if err != nil {
+		return domain.ObjectMetadata{}, err
+	}
+
+	updated, err := s.metadataRepo.UpdateMetadata(ctx, update.apply(metadata))
+	if err != nil {
+		return domain.ObjectMetadata{}, err
+	}
+
+	// A stale manifest would bring the old metadata back through the manifest fallback
+	if _, err := s.readManifest(ctx, key); err == nil {
+		if err := s.writeManifest(ctx, key, updated); err != nil {
+			return updated, fmt.Errorf("metadata updated but manifest could not be rewritten: %w", err)
+		}
+	} else {
+		log.Debugf("No manifest to rewrite for %s: %v", key, err)
+	}
+	return updated, nil
+}
+
+// isControl reports whether r is a control character, which HTTP header values cannot contain
+func isControl(r rune) bool {
+	return (r < ' ' && r != '\t') || r == 0x7f
+}
@@ -0,0 +1,51 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements the naming of shard keys.
+//
+// Every shard is stored under its object's key, so shards of different objects never share a
+// storage key even when their content, and therefore their hash, is identical. Within one object
+// the default "content" scheme names a shard after its hash alone, so two shards of an object
+// with identical content (an object of zeros, for example) placed in the same bucket are stored
+// once. The "indexed" scheme adds the shard index, giving each shard of each object a key of its
+// own. Cleanup that removes an object's shards by prefix always ends the prefix with a slash, so
+// removing "docs/a" leaves the shards of "docs/ab" alone.
+//
+// Content-addressed backends such as IPFS ignore the requested key and store a shard under its
+// content identifier, deduplicating identical shards across objects whatever the scheme. That
+// is the opposite of per-object uniqueness: see the IPFS notes in the README for what deleting
+// an object that shares content does there.
+package service
+
+import "fmt"
+
+// Shard key schemes accepted by SetShardKeyScheme
+const (
+	ShardKeyContent = "content" // "<object key>/<shard hash>", the default
+	ShardKeyIndexed = "indexed" // "<object key>/<shard index>-<shard hash>"
+)
+
+// SetShardKeyScheme sets how new shards are named: ShardKeyContent (or empty) or ShardKeyIndexed
+// Existing objects keep the keys recorded in their metadata, so the scheme can change at any time.
+func (s *FileService) SetShardKeyScheme(scheme string) error {
+	switch scheme {
+	case "", ShardKeyContent:
+		s.indexedShardKeys = false
+	case ShardKeyIndexed:
+		s.indexedShardKeys = true
+	default:
+		return fmt.Errorf("invalid shard key scheme %q: must be %q or %q", scheme, ShardKeyContent, ShardKeyIndexed)
+	}
+	return nil
+}
+
+// shardKey returns the storage key for shard index of the object stored under key
+func (s *FileService) shardKey(key string, index int, hash string) string {
+	if s.indexedShardKeys {
+		return fmt.Sprintf("%s/%d-%s", key, index, hash)
+	}
+	return fmt.Sprintf("%s/%s", key, hash)
+}
+
+// shardPrefix returns the prefix every shard key of the object stored under key starts with
+func shardPrefix(key string) string {
+	return key + "/"
+}
@@ -0,0 +1,107 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements the second attempt at shards whose upload failed.
+//
+// An upload tolerates up to its shard failure limit, and a shard that failed is recorded
+// without a location, leaving the object degraded. Many failures are transient (a
+// throttled request, a dropped connection), so once every shard upload has finished, each
+// shard with no stored copy is uploaded once more: first to the buckets it was placed in,
+// then to spare buckets that hold no shard of the object, so the retry never puts two
+// shards of one object in the same bucket. Shards that still fail stay unstored; the
+// object's metadata then shows it as degraded (see RecordedHealth), and fsck reports the
+// shards as missing and regenerates them with --repair.
+package service
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/placement"
+)
+
+// reuploadMissingShards gives every shard without a stored copy one more upload attempt
+// stored holds the copies stored so far per shard (an empty location for a failed copy);
+// a shard that is stored on retry gets it as its first copy.
+func (s *FileService) reuploadMissingShards(ctx context.Context, placer placement.Placer, key string, shards [][]byte, metadata *domain.ObjectMetadata, placements [][]shardPlacement, stored [][]domain.Location, quiet bool) {
+	var missing []int
+	for i := range shards {
+		if !hasStoredCopy(stored[i]) {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 || ctx.Err() != nil {
+		return
+	}
+
+	// Buckets holding no shard of the object can take a missing shard without weakening placement
+	used := make(map[string]bool)
+	for _, placed := range placements {
+		for _, copyPlacement := range placed {
+			used[copyPlacement.bucketName] = true
+		}
+	}
+	var spares []shardPlacement
+	for _, bucketName := range placer.ListBuckets() {
+		if used[bucketName] {
+			continue
+		}
+		if repo, err := placer.GetRepositoryForBucket(bucketName); err == nil {
+			spares = append(spares, shardPlacement{bucketName: bucketName, repo: repo})
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex // Guards spares, which each go to one shard only
+	for _, i := range missing {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			shardKey := s.shardKey(key, i, metadata.ShardHashes[i].Hash)
+
+			// Retry the buckets the shard was placed in, then take spares until one works
+			candidates := append([]shardPlacement(nil), placements[i]...)
+			for attempt := 0; ; attempt++ {
+				var placed shardPlacement
+				if attempt < len(candidates) {
+					placed = candidates[attempt]
+				} else {
+					mu.Lock()
+					if len(spares) == 0 {
+						mu.Unlock()
+						log.Warnf("Shard %d of %s could not be stored; the object is degraded until fsck --repair regenerates it", i, key)
+						return
+					}
+					placed, spares = spares[0], spares[1:]
+					mu.Unlock()
+				}
+				if ctx.Err() != nil {
+					return
+				}
+
+				path, err := placed.repo.Upload(ctx, shardKey, bytes.NewReader(shards[i]), quiet)
+				if err != nil {
+					log.Debugf("Retry of shard %d in bucket %s failed: %v", i, placed.bucketName, err)
+					continue
+				}
+				log.Infof("Shard %d of %s stored in bucket %s on retry", i, key, placed.bucketName)
+				s.recordUpload(placed.bucketName, int64(len(shards[i])))
+				progressReporter(ctx).Advance(int64(len(shards[i])), 1)
+				stored[i] = []domain.Location{storedLocation(placed, path)}
+				return
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// hasStoredCopy reports whether any copy of a shard was stored
+func hasStoredCopy(locations []domain.Location) bool {
+	for _, location := range locations {
+		if location.Key != "" {
+			return true
+		}
+	}
+	return false
+}
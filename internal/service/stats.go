@@ -0,0 +1,51 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements storage overhead statistics for a prefix.
+package service
+
+import (
+	"context"
+)
+
+// PrefixStats summarizes the storage amplification of the objects directly under a prefix
+type PrefixStats struct {
+	Prefix          string  `json:"prefix"`
+	Objects         int     `json:"objects"`
+	OriginalBytes   int64   `json:"original_bytes"`
+	StoredBytes     int64   `json:"stored_bytes"`     // All shard copies, including parity and padding
+	AverageOverhead float64 `json:"average_overhead"` // Mean of the per-object overhead ratios
+	TotalOverhead   float64 `json:"total_overhead"`   // StoredBytes / OriginalBytes, weighted by size
+	MinOverhead     float64 `json:"min_overhead"`
+	MaxOverhead     float64 `json:"max_overhead"`
+}
+
+// Stats reports the storage overhead of the objects directly under a prefix
+// The average weighs every object equally, so small objects with a reduced data shard
+// count show up in it; the total ratio reflects what the prefix actually costs.
+func (s *FileService) Stats(ctx context.Context, prefix string) (PrefixStats, error) {
+	files, err := s.ListFiles(ctx, prefix)
+	if err != nil {
+		return PrefixStats{}, err
+	}
+
+	stats := PrefixStats{Prefix: prefix}
+	var overheadSum float64
+	for _, metadata := range files {
+		overhead := metadata.Overhead()
+		if stats.Objects == 0 || overhead < stats.MinOverhead {
+			stats.MinOverhead = overhead
+		}
+		stats.MaxOverhead = max(stats.MaxOverhead, overhead)
+		overheadSum += overhead
+
+		stats.Objects++
+		stats.OriginalBytes += metadata.ContentSize()
+		stats.StoredBytes += metadata.StoredBytes()
+	}
+	if stats.Objects > 0 {
+		stats.AverageOverhead = overheadSum / float64(stats.Objects)
+	}
+	if stats.OriginalBytes > 0 {
+		stats.TotalOverhead = float64(stats.StoredBytes) / float64(stats.OriginalBytes)
+	}
+	return stats, nil
+}
@@ -0,0 +1,273 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements machine-readable progress reporting, including a JSON status file.
+//
+// Progress bars are for people at a terminal; automation wrapping zstore (CI jobs,
+// dashboards) needs the same information in a form it can poll. Uploads and downloads
+// report their phases and the shards and bytes each phase has done to the
+// ProgressReporter of their context (see WithProgressReporter), independently of the
+// quiet flag. StatusFile is a reporter that keeps a JSON snapshot of the operation on
+// disk, rewriting it periodically through a temp file and a rename, so a reader never
+// sees a partial document.
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Phases reported by uploads and downloads
+const (
+	PhaseReading        = "reading"
+	PhaseEncoding       = "encoding"
+	PhaseUploading      = "uploading"
+	PhaseRecording      = "recording metadata"
+	PhaseDownloading    = "downloading"
+	PhaseReconstructing = "reconstructing"
+	PhaseWriting        = "writing"
+)
+
+// ProgressReporter receives the progress of an upload or download
+// StartPhase is called when the operation moves to a new phase, with the bytes and
+// shards the phase will handle (0 when it does not count them). Advance is then called
+// as they are done, possibly from several goroutines at once.
+type ProgressReporter interface {
+	StartPhase(phase string, totalBytes int64, totalShards int)
+	Advance(bytes int64, shards int)
+}
+
+// progressReporterKey is the context key of an operation's progress reporter
+type progressReporterKey struct{}
+
+// WithProgressReporter reports the progress of uploads and downloads run with the returned context to reporter
+// Several reporters can be combined with MultiProgressReporter.
+func WithProgressReporter(ctx context.Context, reporter ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressReporterKey{}, reporter)
+}
+
+// progressReporter returns the progress reporter of ctx, or one that discards everything
+func progressReporter(ctx context.Context) ProgressReporter {
+	if reporter, ok := ctx.Value(progressReporterKey{}).(ProgressReporter); ok && reporter != nil {
+		return reporter
+	}
+	return discardProgress{}
+}
+
+// discardProgress is the reporter of operations nobody watches
+type discardProgress struct{}
+
+func (discardProgress) StartPhase(string, int64, int) {}
+func (discardProgress) Advance(int64, int)            {}
+
+// multiProgressReporter passes progress on to several reporters
+type multiProgressReporter []ProgressReporter
+
+// MultiProgressReporter returns a reporter that passes progress on to every given reporter
+func MultiProgressReporter(reporters ...ProgressReporter) ProgressReporter {
+	return multiProgressReporter(reporters)
+}
+
+func (m multiProgressReporter) StartPhase(phase string, totalBytes int64, totalShards int) {
+	for _, reporter := range m {
+		reporter.StartPhase(phase, totalBytes, totalShards)
+	}
+}
+
+func (m multiProgressReporter) Advance(bytes int64, shards int) {
+	for _, reporter := range m {
+		reporter.Advance(bytes, shards)
+	}
+}
+
+// progressWriterAtReporter advances a progress reporter by the bytes written through it
+type progressWriterAtReporter struct {
+	dest     io.WriterAt
+	reporter ProgressReporter
+}
+
+func (w progressWriterAtReporter) WriteAt(p []byte, off int64) (int, error) {
+	n, err := w.dest.WriteAt(p, off)
+	w.reporter.Advance(int64(n), 0)
+	return n, err
+}
+
+// OperationStatus - snapshot of a long-running operation, as written to a status file
+type OperationStatus struct {
+	Operation   string    `json:"operation"` // "upload" or "download"
+	Key         string    `json:"key"`
+	Phase       string    `json:"phase"`
+	BytesDone   int64     `json:"bytes_done"`            // Bytes done in the current phase
+	BytesTotal  int64     `json:"bytes_total,omitempty"` // Bytes the current phase handles (0 when not counted)
+	ShardsDone  int       `json:"shards_done"`
+	ShardsTotal int       `json:"shards_total,omitempty"`
+	ETASeconds  *float64  `json:"eta_seconds,omitempty"` // Estimated time left in the current phase, from its rate so far
+	StartedAt   time.Time `json:"started_at"`
+	PhaseAt     time.Time `json:"phase_started_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Done        bool      `json:"done"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// StatusFile is a ProgressReporter that keeps an operation's status in a JSON file
+// The file is written when the operation starts, on every phase change, every interval
+// while progress is made and when it is closed.
+type StatusFile struct {
+	path    string
+	writeMu sync.Mutex // Serializes writes, so an older snapshot never replaces a newer one
+
+	mu     sync.Mutex
+	status OperationStatus
+	dirty  bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewStatusFile starts reporting an operation on key to path, rewriting it at most once per interval
+// The initial status is written before it returns, so an unwritable path fails up front.
+func NewStatusFile(path, operation, key string, interval time.Duration) (*StatusFile, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("status file interval must be positive, got %v", interval)
+	}
+	now := time.Now().UTC()
+	f := &StatusFile{
+		path:   path,
+		status: OperationStatus{Operation: operation, Key: key, StartedAt: now, PhaseAt: now, UpdatedAt: now},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := f.write(); err != nil {
+		return nil, err
+	}
+	go f.run(interval)
+	return f, nil
+}
+
+// StartPhase records a new phase and writes the file right away
+func (f *StatusFile) StartPhase(phase string, totalBytes int64, totalShards int) {
+	f.mu.Lock()
+	f.status.Phase = phase
+	f.status.BytesDone, f.status.BytesTotal = 0, totalBytes
+	f.status.ShardsDone, f.status.ShardsTotal = 0, totalShards
+	f.status.PhaseAt = time.Now().UTC()
+	f.dirty = true
+	f.mu.Unlock()
+	f.flush()
+}
+
+// Advance adds done bytes and shards to the current phase
+func (f *StatusFile) Advance(bytes int64, shards int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status.BytesDone += bytes
+	f.status.ShardsDone += shards
+	// Shards finishing after the phase had enough are not counted past its total
+	if f.status.BytesTotal > 0 {
+		f.status.BytesDone = min(f.status.BytesDone, f.status.BytesTotal)
+	}
+	if f.status.ShardsTotal > 0 {
+		f.status.ShardsDone = min(f.status.ShardsDone, f.status.ShardsTotal)
+	}
+	f.dirty = true
+}
+
+// Status returns the current status
+func (f *StatusFile) Status() OperationStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.snapshot()
+}
+
+// Close marks the operation done, with its error if it failed, and writes the final status
+func (f *StatusFile) Close(opErr error) error {
+	close(f.stop)
+	<-f.done
+
+	f.mu.Lock()
+	f.status.Done = true
+	if opErr != nil {
+		f.status.Error = opErr.Error()
+	}
+	f.dirty = true
+	f.mu.Unlock()
+	return f.write()
+}
+
+// run rewrites the file every interval while there is new progress
+func (f *StatusFile) run(interval time.Duration) {
+	defer close(f.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			f.flush()
+		}
+	}
+}
+
+// flush writes the file if anything changed since the last write
+// Write errors are not fatal to the operation being reported; the next write retries.
+func (f *StatusFile) flush() {
+	f.mu.Lock()
+	dirty := f.dirty
+	f.mu.Unlock()
+	if dirty {
+		f.write()
+	}
+}
+
+// snapshot returns the status with its update time and ETA filled in; f.mu must be held
+func (f *StatusFile) snapshot() OperationStatus {
+	status := f.status
+	status.UpdatedAt = time.Now().UTC()
+	status.ETASeconds = nil
+	if !status.Done && status.BytesTotal > 0 && status.BytesDone > 0 {
+		elapsed := status.UpdatedAt.Sub(status.PhaseAt).Seconds()
+		eta := elapsed * float64(status.BytesTotal-status.BytesDone) / float64(status.BytesDone)
+		status.ETASeconds = &eta
+	}
+	return status
+}
+
+// write replaces the file with the current status through a temp file and a rename
+func (f *StatusFile) write() error {
+	f.writeMu.Lock()
+	defer f.writeMu.Unlock()
+
+	f.mu.Lock()
+	status := f.snapshot()
+	f.dirty = false
+	f.mu.Unlock()
+
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(f.path), "."+filepath.Base(f.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write status file: %w", err)
+	}
+	_, err = temp.Write(append(data, '\n'))
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		f.mu.Lock()
+		f.dirty = true
+		f.mu.Unlock()
+		return fmt.Errorf("failed to write status file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements streaming reads that emit data shards as they arrive.
+//
+// Data shards hold the original object as contiguous stripes (see range_reader.go), so
+// when they download cleanly the object can be written out shard by shard without any
+// decoding. Shards are requested in index order, at most s.downloadWorkers() at a time, and
+// each one is written as soon as every shard before it has been written.
+//
+// Streaming Strategy:
+// 1. Download data shards in order and write each one once its predecessors are out
+// 2. If a data shard cannot be read, stop the remaining downloads
+// 3. Reconstruct the object from any sufficient set of shards, as DownloadFile does
+// 4. Write the part of the reconstructed object that was not streamed yet
+//
+// Objects in the reconstruction cache are written from it without touching any shard,
+// and a streamed or reconstructed object is added to it once it has been verified.
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+// streamedShard is the outcome of downloading one data shard
+type streamedShard struct {
+	data []byte
+	err  error
+}
+
+// StreamFile writes an object to dest, emitting each data shard as soon as it is available
+// Time to first byte is that of the first data shard rather than of the whole object.
+// The whole-file hash can only be checked once everything is written, so a mismatch is
+// reported as errors.ErrFileIntegrityCheck after the corrupt bytes have reached dest;
+// with verifyIntegrity set, each shard is checked against its own hash before it is written.
+func (s *FileService) StreamFile(ctx context.Context, key string, dest io.Writer, verifyIntegrity bool) error {
+	key = s.NormalizeKey(key)
+	start := time.Now()
+	size, err := s.streamFile(ctx, key, dest, verifyIntegrity)
+	s.audit(ctx, domain.AuditDownload, key, size, start, err)
+	return err
+}
+
+// streamFile streams an object without auditing it, returning the object's size once known
+func (s *FileService) streamFile(ctx context.Context, key string, dest io.Writer, verifyIntegrity bool) (int64, error) {
+	if len(s.placer.ListBuckets()) == 0 {
+		return 0, errors.ErrNoBucketsRegistered
+	}
+
+	metadata, err := s.lookupMetadata(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	return metadata.ContentSize(), s.streamObject(ctx, metadata, dest, verifyIntegrity)
+}
+
+// streamObject streams an object's data shards to dest, falling back to reconstruction
+func (s *FileService) streamObject(ctx context.Context, metadata domain.ObjectMetadata, dest io.Writer, verifyIntegrity bool) error {
+	dataShards := len(metadata.ShardHashes) - metadata.ParityShards
+	if dataShards <= 0 || metadata.ShardSize <= 0 {
+		return fmt.Errorf("invalid shard layout: %d data shards of %d bytes", dataShards, metadata.ShardSize)
+	}
+
+	// Compressed or encrypted content can only be decoded whole
+	if len(metadata.Transforms) > 0 {
+		data, err := s.objectContent(ctx, metadata, true, verifyIntegrity)
+		if err != nil {
+			return err
+		}
+		_, err = dest.Write(data)
+		return err
+	}
+
+	if data, ok := s.cachedObject(metadata); ok {
+		_, err := dest.Write(data)
+		return err
+	}
+
+	ctx, release, err := s.AcquireDownloadSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	downloadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Each shard reports on its own channel so results can be consumed in order
+	results := make([]chan streamedShard, dataShards)
+	for i := range results {
+		results[i] = make(chan streamedShard, 1)
+	}
+	go func() {
+		semaphore := make(chan struct{}, s.downloadWorkers())
+		for i := 0; i < dataShards; i++ {
+			select {
+			case semaphore <- struct{}{}:
+			case <-downloadCtx.Done():
+				results[i] <- streamedShard{err: downloadCtx.Err()}
+				continue
+			}
+			go func(i int) {
+				defer func() { <-semaphore }()
+				data, err := s.downloadShardCopy(downloadCtx, metadata.ShardHashes[i], verifyIntegrity)
+				results[i] <- streamedShard{data: data, err: err}
+			}(i)
+		}
+	}()
+
+	hash := crc64.New(crc64.MakeTable(crc64.ISO))
+	out := io.MultiWriter(dest, hash)
+	var streamed bytes.Buffer // Copy of the streamed object for the reconstruction cache
+	if s.reconstructionCache != nil {
+		out = io.MultiWriter(out, &streamed)
+	}
+	var written int64
+	stripeSize := metadata.EncodedShardSize()
+	for i := 0; i < dataShards && written < metadata.OriginalSize; i++ {
+		result := <-results[i]
+		if result.err == nil && int64(len(result.data)) < min(stripeSize, metadata.OriginalSize-written) {
+			result.err = fmt.Errorf("shard is %d bytes, expected %d", len(result.data), metadata.ShardSize)
+		}
+		if result.err != nil {
+			cancel()
+			log.Debugf("Data shard %d of %s/%s unavailable (%v); falling back to reconstruction", i, metadata.Prefix, metadata.FileName, result.err)
+			return s.streamReconstructed(ctx, metadata, dest, written, verifyIntegrity)
+		}
+
+		// The last stripe and any alignment padding are zeros; only the object's own bytes are written
+		chunk := result.data[:min(int64(len(result.data)), stripeSize, metadata.OriginalSize-written)]
+		if _, err := out.Write(chunk); err != nil {
+			return err
+		}
+		written += int64(len(chunk))
+	}
+
+	if metadata.FileHash != "" && fmt.Sprintf("%016x", hash.Sum64()) != metadata.FileHash {
+		return errors.ErrFileIntegrityCheck
+	}
+	if s.reconstructionCache != nil {
+		s.cacheObject(metadata, streamed.Bytes())
+	}
+	return nil
+}
+
+// streamReconstructed reconstructs an object and writes everything past the first written bytes
+func (s *FileService) streamReconstructed(ctx context.Context, metadata domain.ObjectMetadata, dest io.Writer, written int64, verifyIntegrity bool) error {
+	data, err := s.reconstructObject(ctx, metadata, true, verifyIntegrity)
+	if err != nil {
+		return err
+	}
+	s.cacheObject(metadata, data)
+	_, err = dest.Write(data[written:])
+	return err
+}
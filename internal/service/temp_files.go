@@ -0,0 +1,132 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements naming and cleanup of the temporary files used by downloads.
+//
+// Downloads stage each shard in a temp file named <prefix>shard_<index>_<random>.tmp.
+// The files are removed when the download ends, but a crash leaves them behind, so
+// SweepTempFiles removes old files matching that pattern. The prefix namespaces zstore's
+// files, so a sweep never touches anything another program created. Stale resume caches
+// of downloads that were never retried (see resume_cache.go) are removed as well.
+//
+// For post-mortem debugging, SetKeepTempFiles leaves the staged shards in place after a
+// download, successful or not, and logs their locations. A shard that fails its integrity
+// check is kept too, so the bytes that were actually received can be inspected.
+package service
+
+import (
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultTempFilePrefix starts the names of zstore's temp files
+const DefaultTempFilePrefix = "zstore_"
+
+// tempShardPattern is the os.CreateTemp pattern for the shard staged at index
+func tempShardPattern(prefix string, index int) string {
+	return fmt.Sprintf("%sshard_%d_*.tmp", prefix, index)
+}
+
+// isTempShardFile reports whether a file name matches tempShardPattern for prefix
+func isTempShardFile(name, prefix string) bool {
+	return strings.HasPrefix(name, prefix+"shard_") && strings.HasSuffix(name, ".tmp")
+}
+
+// SetTempFiles sets the directory and name prefix of the temp files used by downloads
+// An empty directory uses the system temp directory; an empty prefix uses DefaultTempFilePrefix.
+func (s *FileService) SetTempFiles(dir, prefix string) error {
+	if prefix == "" {
+		prefix = DefaultTempFilePrefix
+	}
+	if strings.ContainsAny(prefix, `/\*`) {
+		return fmt.Errorf("invalid temp file prefix %q: must not contain path separators or '*'", prefix)
+	}
+	s.tempDir, s.tempPrefix = dir, prefix
+	return nil
+}
+
+// SetKeepTempFiles keeps the shard temp files of downloads instead of removing them
+// Kept files stay until a temp file sweep finds them old enough.
+func (s *FileService) SetKeepTempFiles(keep bool) {
+	s.keepTempFiles = keep
+}
+
+// releaseTempFiles removes the staged shard files of a finished download
+// With keepTempFiles they are kept and their locations logged. A resumable download
+// leaves them to its cache, which is removed once the object has been reconstructed.
+func (s *FileService) releaseTempFiles(tempFilePaths []string, failed bool) {
+	if s.keepTempFiles {
+		for i, path := range tempFilePaths {
+			if path == "" {
+				continue
+			}
+			if failed {
+				log.Warnf("Kept temp file of shard %d: %s", i, path)
+			} else {
+				log.Infof("Kept temp file of shard %d: %s", i, path)
+			}
+		}
+		return
+	}
+	if !s.resumeDownloads {
+		removeTempFiles(tempFilePaths)
+	}
+}
+
+// SweepTempFiles removes zstore temp files and resume caches with the given prefix that were
+// last modified more than olderThan ago. An empty directory uses the system temp directory
+// and an empty prefix uses DefaultTempFilePrefix. It returns the number of files and caches
+// removed; those that cannot be removed are reported in the error and do not stop the sweep.
+func SweepTempFiles(dir, prefix string, olderThan time.Duration) (int, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if prefix == "" {
+		prefix = DefaultTempFilePrefix
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read temp directory %s: %w", dir, err)
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	var errs []error
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isTempShardFile(entry.Name(), prefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+
+	// Resume caches of downloads that were never retried are swept the same way
+	caches, err := os.ReadDir(resumeCacheRoot(dir, prefix))
+	if err != nil && !os.IsNotExist(err) {
+		errs = append(errs, err)
+	}
+	for _, entry := range caches {
+		info, err := entry.Info()
+		if !entry.IsDir() || err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(resumeCacheRoot(dir, prefix), entry.Name())); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	return removed, stderrors.Join(errs...)
+}
@@ -0,0 +1,247 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements compressing and encrypting an object's content before it is sharded.
+//
+// Transforms are applied to the whole object in a fixed order, compression first and
+// encryption second: encrypted bytes look random and do not compress, so the reverse
+// order would cost CPU and save nothing. The transforms applied are recorded in the
+// object's metadata in that order and undone in reverse on download; a record listing
+// them in any other order is rejected rather than decoded wrongly.
+//
+// Sharding, shard hashes, OriginalSize and FileHash all describe the transformed bytes,
+// so reconstruction, repair and re-encoding work on them unchanged. The size and CRC64
+// of the content as uploaded are recorded as PlainSize and PlainHash and checked after
+// the transforms are undone.
+//
+// Encryption is client-side AES-256-GCM: each object gets a random 12-byte nonce,
+// stored in front of the ciphertext. The key is never stored, so objects can only be
+// read back with the same key configured.
+package service
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"hash/crc64"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+const (
+	TransformZstd      = "zstd"        // zstd compression
+	TransformAES256GCM = "aes-256-gcm" // Client-side AES-256-GCM encryption
+)
+
+// EncryptionKeySize is the required length of a client-side encryption key
+const EncryptionKeySize = 32
+
+// transformOrder is the position of each transform; transforms must be applied in this order
+var transformOrder = map[string]int{
+	TransformZstd:      0,
+	TransformAES256GCM: 1,
+}
+
+// ValidateTransforms checks that transforms are known, not repeated, and in the supported order
+func ValidateTransforms(transforms []string) error {
+	last := -1
+	for _, transform := range transforms {
+		position, ok := transformOrder[transform]
+		if !ok {
+			return fmt.Errorf("%w: unknown transform %q", errors.ErrInvalidTransforms, transform)
+		}
+		if position <= last {
+			return fmt.Errorf("%w: %s", errors.ErrInvalidTransforms, strings.Join(transforms, ", "))
+		}
+		last = position
+	}
+	return nil
+}
+
+// ParseEncryptionKey decodes a base64-encoded 256-bit client-side encryption key
+func ParseEncryptionKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key must be base64 encoded: %w", err)
+	}
+	if len(key) != EncryptionKeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", EncryptionKeySize, len(key))
+	}
+	return key, nil
+}
+
+// SetCompression sets the compression applied to uploads ("" disables, TransformZstd compresses)
+func (s *FileService) SetCompression(algorithm string) error {
+	if algorithm != "" && algorithm != TransformZstd {
+		return fmt.Errorf("unsupported compression %q (supported: %s)", algorithm, TransformZstd)
+	}
+	s.compression = algorithm
+	return nil
+}
+
+// SetEncryptionKey enables client-side encryption of uploads, and decryption of downloads, with key
+// A nil key disables encryption; encrypted objects then fail to download.
+func (s *FileService) SetEncryptionKey(key []byte) error {
+	if key != nil && len(key) != EncryptionKeySize {
+		return fmt.Errorf("encryption key must be %d bytes, got %d", EncryptionKeySize, len(key))
+	}
+	s.encryptionKey = key
+	return nil
+}
+
+// uploadTransforms returns the transforms new uploads get, in the order they are applied
+// Content that is already compressed, such as an archive, is not compressed again.
+func (s *FileService) uploadTransforms(compressed bool) []string {
+	var transforms []string
+	if s.compression != "" && !compressed {
+		transforms = append(transforms, s.compression)
+	}
+	if s.encryptionKey != nil {
+		transforms = append(transforms, TransformAES256GCM)
+	}
+	return transforms
+}
+
+// encodeContent applies the configured transforms to an upload's content
+// It returns the bytes to shard and the transforms applied, in order; without any
+// configured transforms the content is returned unchanged.
+func (s *FileService) encodeContent(data []byte, compressed bool) ([]byte, []string, error) {
+	transforms := s.uploadTransforms(compressed)
+	if err := ValidateTransforms(transforms); err != nil {
+		return nil, nil, err
+	}
+	for _, transform := range transforms {
+		var err error
+		switch transform {
+		case TransformZstd:
+			data, err = compressContent(data)
+		case TransformAES256GCM:
+			data, err = encryptContent(data, s.encryptionKey)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to apply %s: %w", transform, err)
+		}
+	}
+	return data, transforms, nil
+}
+
+// decodeContent undoes an object's recorded transforms, in reverse order, and checks the result
+func (s *FileService) decodeContent(data []byte, metadata domain.ObjectMetadata) ([]byte, error) {
+	if len(metadata.Transforms) == 0 {
+		return data, nil
+	}
+	if err := ValidateTransforms(metadata.Transforms); err != nil {
+		return nil, fmt.Errorf("%s/%s: %w", metadata.Prefix, metadata.FileName, err)
+	}
+	for i := len(metadata.Transforms) - 1; i >= 0; i-- {
+		var err error
+		switch metadata.Transforms[i] {
+		case TransformAES256GCM:
+			data, err = s.decryptContent(data)
+		case TransformZstd:
+			data, err = decompressContent(data, metadata.PlainSize)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", metadata.Prefix, metadata.FileName, err)
+		}
+	}
+
+	if int64(len(data)) != metadata.PlainSize {
+		return nil, fmt.Errorf("%w: decoded %d bytes, metadata records %d", errors.ErrSizeMismatch, len(data), metadata.PlainSize)
+	}
+	if metadata.PlainHash != "" {
+		if err := verifyFileIntegrity(data, metadata.PlainHash); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// recordTransforms records the transforms applied to content in the metadata of its upload
+func recordTransforms(metadata *domain.ObjectMetadata, content []byte, transforms []string) {
+	if len(transforms) == 0 {
+		return
+	}
+	metadata.Transforms = transforms
+	metadata.PlainSize = int64(len(content))
+	metadata.PlainHash = fmt.Sprintf("%016x", crc64.Checksum(content, crc64.MakeTable(crc64.ISO)))
+}
+
+// objectContent returns an object's content as uploaded: reconstructed (or cached) and decoded
+func (s *FileService) objectContent(ctx context.Context, metadata domain.ObjectMetadata, quiet bool, verifyIntegrity bool) ([]byte, error) {
+	data, ok := s.cachedObject(metadata)
+	if !ok {
+		var err error
+		if data, err = s.reconstructObject(ctx, metadata, quiet, verifyIntegrity); err != nil {
+			return nil, err
+		}
+		// The cache keeps the stored form, so encrypted objects are never cached in plain text
+		s.cacheObject(metadata, data)
+	}
+	return s.decodeContent(data, metadata)
+}
+
+func compressContent(data []byte) ([]byte, error) {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer encoder.Close()
+	return encoder.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+}
+
+// decompressContent decompresses data, refusing to produce more than size bytes
+func decompressContent(data []byte, size int64) ([]byte, error) {
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(max(size, 1))))
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+	out, err := decoder.DecodeAll(data, make([]byte, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress: %w", err)
+	}
+	return out, nil
+}
+
+func encryptContent(data, key []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func (s *FileService) decryptContent(data []byte) ([]byte, error) {
+	if s.encryptionKey == nil {
+		return nil, errors.ErrEncryptionKeyRequired
+	}
+	aead, err := newAEAD(s.encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.ErrDecryptionFailed
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.ErrDecryptionFailed
+	}
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
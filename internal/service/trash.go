@@ -0,0 +1,149 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements soft delete: a trash that deleted objects stay in until purged.
+//
+// With soft delete enabled, DeleteFile only marks the object's metadata as deleted and
+// records when. A deleted object is hidden from downloads, stat and listings (including
+// the manifest fallback), but its shards and manifest are kept, so RestoreFile can bring
+// it back within the retention window. PurgeFile removes a deleted object for good, and
+// PurgeExpired removes every deleted object whose window has passed. Consistency checks
+// still see deleted objects, so their shards are never reported as orphans.
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+// SetSoftDelete makes DeleteFile move objects to the trash instead of removing them
+// Deleted objects can be restored for the given retention window (0 keeps them restorable until purged).
+func (s *FileService) SetSoftDelete(enabled bool, retention time.Duration) {
+	s.softDelete = enabled
+	s.trashRetention = retention
+}
+
+// trashExpired reports whether a deleted object's retention window has passed
+func (s *FileService) trashExpired(metadata domain.ObjectMetadata) bool {
+	return s.trashRetention > 0 && time.Since(metadata.DeletedAt) > s.trashRetention
+}
+
+// getLiveMetadata reads an object's metadata, treating an object in the trash as missing
+func (s *FileService) getLiveMetadata(ctx context.Context, key string) (domain.ObjectMetadata, error) {
+	metadata, err := s.metadataRepo.GetMetadata(ctx, filepath.Dir(key), filepath.Base(key))
+	if err != nil {
+		return domain.ObjectMetadata{}, err
+	}
+	if metadata.Deleted {
+		return domain.ObjectMetadata{}, fmt.Errorf("%w: %s is in the trash", errors.ErrMetadataNotFound, key)
+	}
+	return metadata, nil
+}
+
+// liveOnly drops objects in the trash from a listing
+func liveOnly(files []domain.ObjectMetadata) []domain.ObjectMetadata {
+	live := files[:0]
+	for _, file := range files {
+		if !file.Deleted {
+			live = append(live, file)
+		}
+	}
+	return live
+}
+
+// trashFile marks an object as deleted, leaving its shards in place
+func (s *FileService) trashFile(ctx context.Context, key string) error {
+	metadata, err := s.getLiveMetadata(ctx, key)
+	if err != nil {
+		return err
+	}
+	metadata.Deleted = true
+	metadata.DeletedAt = time.Now().UTC()
+	if _, err := s.metadataRepo.UpdateMetadata(ctx, metadata); err != nil {
+		return err
+	}
+	log.Debugf("Moved %s to the trash", key)
+	return nil
+}
+
+// RestoreFile brings an object back from the trash
+// Objects whose retention window has passed fail with errors.ErrTrashExpired.
+func (s *FileService) RestoreFile(ctx context.Context, key string) error {
+	key = s.NormalizeKey(key)
+	metadata, err := s.metadataRepo.GetMetadata(ctx, filepath.Dir(key), filepath.Base(key))
+	if err != nil {
+		return err
+	}
+	if !metadata.Deleted {
+		return fmt.Errorf("%w: %s", errors.ErrNotInTrash, key)
+	}
+	if s.trashExpired(metadata) {
+		return fmt.Errorf("%w: %s was deleted at %s", errors.ErrTrashExpired, key, metadata.DeletedAt.Format(time.RFC3339))
+	}
+
+	metadata.Deleted = false
+	metadata.DeletedAt = time.Time{}
+	_, err = s.metadataRepo.UpdateMetadata(ctx, metadata)
+	return err
+}
+
+// PurgeFile permanently removes an object that is in the trash
+func (s *FileService) PurgeFile(ctx context.Context, key string) (err error) {
+	key = s.NormalizeKey(key)
+	start := time.Now()
+	defer func() { s.audit(ctx, domain.AuditPurge, key, 0, start, err) }()
+
+	metadata, err := s.metadataRepo.GetMetadata(ctx, filepath.Dir(key), filepath.Base(key))
+	if err != nil {
+		return err
+	}
+	if !metadata.Deleted {
+		return fmt.Errorf("%w: %s", errors.ErrNotInTrash, key)
+	}
+	return s.removeFile(ctx, key)
+}
+
+// ListTrash lists the objects under a prefix that are in the trash
+func (s *FileService) ListTrash(ctx context.Context, prefix string) ([]domain.ObjectMetadata, error) {
+	prefix = s.NormalizeKey(prefix)
+	files, err := s.metadataRepo.ListMetadataByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []domain.ObjectMetadata
+	for _, file := range files {
+		if file.Deleted {
+			deleted = append(deleted, file)
+		}
+	}
+	return deleted, nil
+}
+
+// PurgeExpired permanently removes the objects under a prefix whose trash retention window has passed
+// It returns the keys purged before the first failure. Without a retention window nothing expires.
+func (s *FileService) PurgeExpired(ctx context.Context, prefix string) ([]string, error) {
+	deleted, err := s.ListTrash(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []string
+	for _, metadata := range deleted {
+		if !s.trashExpired(metadata) {
+			continue
+		}
+		key := filepath.Join(metadata.Prefix, metadata.FileName)
+		start := time.Now()
+		err := s.removeFile(ctx, key)
+		s.audit(ctx, domain.AuditPurge, key, 0, start, err)
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge %s: %w", key, err)
+		}
+		purged = append(purged, key)
+	}
+	return purged, nil
+}
@@ -0,0 +1,187 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements batch uploads of arbitrary local files to arbitrary keys.
+//
+// A batch is a list of jobs, each mapping one local file to one object key, optionally with
+// its own shard layout. Unlike UploadDir, sources and keys need not mirror a directory tree,
+// and a failed job does not stop the batch: every job gets its own result. Jobs are checked
+// up front (the source must be a non-empty regular file and the layout placeable), then
+// uploaded in order through the same per-file path and single progress bar as UploadDir.
+//
+// Job files are either a JSON array of jobs or text with one job per line:
+//
+//	# source -> destination [data+parity]
+//	/var/backups/db.dump -> zs://backups/db/latest.dump
+//	/etc/app/config.yaml -> zs://backups/config.yaml 6+3
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// BatchJob uploads one local file to one object key
+type BatchJob struct {
+	Source       string `json:"source"`
+	Destination  string `json:"destination"`             // zs://key
+	DataShards   int    `json:"data_shards,omitempty"`   // 0 uses the batch's layout
+	ParityShards int    `json:"parity_shards,omitempty"` // Only used when DataShards is set
+}
+
+// BatchJobResult is the outcome of one job
+type BatchJobResult struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Err         string `json:"error,omitempty"`
+}
+
+// BatchUploadResult summarizes an UploadBatch call
+type BatchUploadResult struct {
+	Jobs   []BatchJobResult `json:"jobs"` // In job order
+	Failed int              `json:"failed"`
+}
+
+// ParseBatchJobs reads upload jobs from a JSON array or from "source -> destination [data+parity]" lines
+// Blank lines and lines starting with # are ignored. Destinations must be zs:// URLs.
+func ParseBatchJobs(r io.Reader) ([]BatchJob, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []BatchJob
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &jobs); err != nil {
+			return nil, fmt.Errorf("invalid job list: %w", err)
+		}
+		for i, job := range jobs {
+			if job.Source == "" || !strings.HasPrefix(job.Destination, "zs://") {
+				return nil, fmt.Errorf("job %d: source and a zs:// destination are required", i+1)
+			}
+		}
+		return jobs, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		job, err := parseBatchLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, scanner.Err()
+}
+
+// parseBatchLine parses "source -> zs://key [data+parity]"
+// The source may contain spaces; the destination and layout may not.
+func parseBatchLine(text string) (BatchJob, error) {
+	source, rest, ok := strings.Cut(text, " -> ")
+	if !ok {
+		return BatchJob{}, fmt.Errorf("expected \"source -> zs://key\", got %q", text)
+	}
+	job := BatchJob{Source: strings.TrimSpace(source)}
+	fields := strings.Fields(rest)
+	if job.Source == "" || len(fields) == 0 || len(fields) > 2 || !strings.HasPrefix(fields[0], "zs://") {
+		return BatchJob{}, fmt.Errorf("expected \"source -> zs://key [data+parity]\", got %q", text)
+	}
+	job.Destination = fields[0]
+
+	if len(fields) == 2 {
+		data, parity, ok := strings.Cut(fields[1], "+")
+		var dataErr, parityErr error
+		job.DataShards, dataErr = strconv.Atoi(data)
+		job.ParityShards, parityErr = strconv.Atoi(parity)
+		if !ok || dataErr != nil || parityErr != nil || job.DataShards <= 0 {
+			return BatchJob{}, fmt.Errorf("invalid shard layout %q: expected data+parity, e.g. 4+2", fields[1])
+		}
+	}
+	return job, nil
+}
+
+// UploadBatch uploads every job, reporting each one's outcome instead of stopping at a failure
+// Jobs without their own layout use dataShards and parityShards. Unless quiet, progress is
+// one bar for all jobs, as for UploadDir.
+func (s *FileService) UploadBatch(ctx context.Context, jobs []BatchJob, quiet bool, dataShards, parityShards, concurrency int) BatchUploadResult {
+	result := BatchUploadResult{Jobs: make([]BatchJobResult, len(jobs))}
+	uploader := &dirUploader{service: s, concurrency: concurrency}
+	indices := make([]int, 0, len(jobs)) // Job index of each file in uploader.files
+
+	for i, job := range jobs {
+		result.Jobs[i] = BatchJobResult{Source: job.Source, Destination: job.Destination}
+		file, err := s.batchFile(job, dataShards, parityShards)
+		if err != nil {
+			result.Jobs[i].Err = err.Error()
+			continue
+		}
+		uploader.files = append(uploader.files, file)
+		indices = append(indices, i)
+	}
+
+	var total int64
+	for _, file := range uploader.files {
+		total += file.size
+	}
+	uploader.bar = s.reconstructionProgress(quiet).bar(total, dirProgressDescription(0, len(uploader.files)), true)
+	for n, file := range uploader.files {
+		err := ctx.Err()
+		if err == nil {
+			err = uploader.uploadFile(ctx, file)
+		}
+		if err != nil {
+			result.Jobs[indices[n]].Err = err.Error()
+		}
+	}
+	uploader.bar.Finish()
+
+	for _, job := range result.Jobs {
+		if job.Err != "" {
+			result.Failed++
+		}
+	}
+	return result
+}
+
+// batchFile checks a job and turns it into a file for the directory uploader
+func (s *FileService) batchFile(job BatchJob, dataShards, parityShards int) (dirFile, error) {
+	key, ok := strings.CutPrefix(job.Destination, "zs://")
+	if !ok || key == "" {
+		return dirFile{}, fmt.Errorf("destination must be a zs:// URL")
+	}
+	if job.DataShards > 0 {
+		dataShards, parityShards = job.DataShards, job.ParityShards
+	}
+	if err := s.checkPlacement(dataShards, parityShards); err != nil {
+		return dirFile{}, err
+	}
+
+	info, err := os.Stat(job.Source)
+	if err != nil {
+		return dirFile{}, err
+	}
+	switch {
+	case !info.Mode().IsRegular():
+		return dirFile{}, fmt.Errorf("%s is not a regular file", job.Source)
+	case info.Size() == 0:
+		return dirFile{}, fmt.Errorf("%s is empty", job.Source)
+	}
+	return dirFile{
+		diskPath:     job.Source,
+		rel:          filepath.ToSlash(job.Source),
+		key:          key,
+		size:         info.Size(),
+		dataShards:   dataShards,
+		parityShards: parityShards,
+	}, nil
+}
@@ -0,0 +1,261 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements recursive directory uploads.
+//
+// UploadDir uploads every regular file under a directory, keyed by its path relative to
+// the directory. Entries that cannot be stored as erasure-coded objects are skipped and
+// reported rather than failing the whole upload:
+// - Symlinks are skipped unless followSymlinks is set
+// - Empty directories are skipped, since an object cannot be zero bytes
+// - Empty files are skipped for the same reason
+// - Other special files (sockets, devices, named pipes) are skipped
+//
+// A followed link to a file uploads the target's content under the link's path; a followed
+// link to a directory is walked, and a directory reached a second time is skipped so link
+// cycles terminate. Restoring an uploaded tree only recreates directories that held files.
+//
+// The tree is walked before anything is uploaded, so the total size is known up front.
+// Instead of a bar per shard, the upload shows one bar for the bytes of all files,
+// advanced as each shard is stored, and a count of the files completed.
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/schollz/progressbar/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// SkippedPath is a directory entry UploadDir did not upload
+type SkippedPath struct {
+	Path   string // Path relative to the uploaded directory, slash-separated
+	Reason string
+}
+
+// DirUploadResult summarizes a directory upload
+type DirUploadResult struct {
+	Uploaded []string      // Keys of the uploaded objects, in walk order
+	Skipped  []SkippedPath // Entries that were not uploaded, in walk order
+}
+
+// UploadDir uploads every regular file under dir to keys under prefix
+// A file at dir/a/b.txt is stored at prefix/a/b.txt. The upload stops at the first file
+// that fails; the result lists what was uploaded up to that point and every skipped entry.
+// Unless quiet, progress is one bar for the whole directory (see uploadProgress).
+func (s *FileService) UploadDir(ctx context.Context, dir, prefix string, followSymlinks, quiet bool, dataShards, parityShards, concurrency int) (DirUploadResult, error) {
+	if err := s.checkPlacement(dataShards, parityShards); err != nil {
+		return DirUploadResult{}, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return DirUploadResult{}, err
+	}
+	if !info.IsDir() {
+		return DirUploadResult{}, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	walker := &dirUploader{
+		service:        s,
+		prefix:         prefix,
+		followSymlinks: followSymlinks,
+		quiet:          quiet,
+		dataShards:     dataShards,
+		parityShards:   parityShards,
+		concurrency:    concurrency,
+		visited:        make(map[string]bool),
+	}
+	if err := walker.walk(ctx, dir, ""); err != nil {
+		return walker.result, err
+	}
+
+	var total int64
+	for _, file := range walker.files {
+		total += file.size
+	}
+	walker.bar = s.reconstructionProgress(quiet).bar(total, dirProgressDescription(0, len(walker.files)), true)
+	for _, file := range walker.files {
+		if err := walker.uploadFile(ctx, file); err != nil {
+			return walker.result, err
+		}
+	}
+	return walker.result, walker.bar.Finish()
+}
+
+// dirProgressDescription labels the directory upload bar with the files completed so far
+func dirProgressDescription(done, total int) string {
+	return fmt.Sprintf("uploading (%d/%d files)", done, total)
+}
+
+// dirUploader carries the state of one UploadDir call through the recursive walk
+type dirUploader struct {
+	service        *FileService
+	prefix         string
+	followSymlinks bool
+	quiet          bool
+	dataShards     int
+	parityShards   int
+	concurrency    int
+	visited        map[string]bool // Resolved paths of directories already walked
+	files          []dirFile       // Files to upload, in walk order
+	done           int             // Files finished, uploaded or not
+	bar            *progressbar.ProgressBar
+	result         DirUploadResult
+}
+
+// dirFile is a regular file to upload
+type dirFile struct {
+	diskPath     string
+	rel          string // Path relative to the uploaded directory, slash-separated; its base is the recorded name
+	key          string
+	size         int64
+	dataShards   int
+	parityShards int
+}
+
+// walk collects the files under the directory at diskPath, whose path relative to the root is rel
+func (u *dirUploader) walk(ctx context.Context, diskPath, rel string) error {
+	resolved, err := filepath.EvalSymlinks(diskPath)
+	if err != nil {
+		return err
+	}
+	if u.visited[resolved] {
+		u.skip(rel, "directory already uploaded through another path (symlink cycle)")
+		return nil
+	}
+	u.visited[resolved] = true
+
+	entries, err := os.ReadDir(diskPath)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 && rel != "" {
+		u.skip(rel, "empty directory")
+		return nil
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entryPath := filepath.Join(diskPath, entry.Name())
+		entryRel := path.Join(rel, entry.Name())
+
+		mode := entry.Type()
+		var info fs.FileInfo
+		if mode&fs.ModeSymlink != 0 {
+			if !u.followSymlinks {
+				u.skip(entryRel, "symlink (use --follow-symlinks to upload its target)")
+				continue
+			}
+			target, err := os.Stat(entryPath)
+			if err != nil {
+				u.skip(entryRel, fmt.Sprintf("broken symlink: %v", err))
+				continue
+			}
+			info, mode = target, target.Mode().Type()
+		} else if info, err = entry.Info(); err != nil {
+			return err
+		}
+
+		switch {
+		case mode.IsDir():
+			if err := u.walk(ctx, entryPath, entryRel); err != nil {
+				return err
+			}
+		case mode.IsRegular() && info.Size() == 0:
+			u.skip(entryRel, "empty file")
+		case mode.IsRegular():
+			u.files = append(u.files, dirFile{
+				diskPath:     entryPath,
+				rel:          entryRel,
+				key:          path.Join(u.prefix, entryRel),
+				size:         info.Size(),
+				dataShards:   u.dataShards,
+				parityShards: u.parityShards,
+			})
+		default:
+			u.skip(entryRel, "not a regular file")
+		}
+	}
+	return nil
+}
+
+// uploadFile uploads one regular file (or followed symlink to one)
+// The bar advances as the file's shards are stored and is topped up to the size found
+// up front once the file is done, even if the file changed since or failed to upload.
+func (u *dirUploader) uploadFile(ctx context.Context, file dirFile) error {
+	var reported atomic.Int64
+	defer func() {
+		// The count is updated first: the bar renders for the last time when it fills up
+		u.done++
+		u.bar.Describe(dirProgressDescription(u.done, len(u.files)))
+		if remaining := file.size - reported.Load(); remaining > 0 {
+			u.bar.Add64(remaining)
+		}
+	}()
+
+	f, err := os.Open(file.diskPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	ctx = withUploadProgress(ctx, func(n int64) {
+		// Never run past the file's share of the bar
+		if reported.Add(n) <= file.size {
+			u.bar.Add64(n)
+		}
+	})
+
+	// A followed link is recorded under its own name, not its target's
+	info = namedFileInfo{FileInfo: info, name: path.Base(file.rel)}
+	if err := u.service.UploadLocalFile(ctx, file.key, info, f, true, file.dataShards, file.parityShards, u.concurrency); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", file.rel, err)
+	}
+	u.result.Uploaded = append(u.result.Uploaded, file.key)
+	return nil
+}
+
+// uploadProgressKey is the context key of an upload's progress callback
+type uploadProgressKey struct{}
+
+// withUploadProgress reports the progress of uploads run with the returned context to fn
+// fn receives each stored shard copy's share of the object's original size, so the
+// values add up to the object's size once every copy is stored. It is called from
+// the shard upload goroutines and must be safe for concurrent use.
+func withUploadProgress(ctx context.Context, fn func(n int64)) context.Context {
+	return context.WithValue(ctx, uploadProgressKey{}, fn)
+}
+
+// reportUploadProgress reports a stored shard copy of shardBytes out of totalBytes stored for the object
+func reportUploadProgress(ctx context.Context, originalSize, shardBytes, totalBytes int64) {
+	fn, ok := ctx.Value(uploadProgressKey{}).(func(int64))
+	if !ok || totalBytes <= 0 {
+		return
+	}
+	fn(shardBytes * originalSize / totalBytes)
+}
+
+// namedFileInfo reports a different name for a file
+type namedFileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (n namedFileInfo) Name() string { return n.name }
+
+// skip records an entry that is not uploaded
+func (u *dirUploader) skip(rel, reason string) {
+	log.Warnf("Skipping %s: %s", rel, reason)
+	u.result.Skipped = append(u.result.Skipped, SkippedPath{Path: rel, Reason: reason})
+}
@@ -0,0 +1,339 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements the upload log that makes uploads crash-consistent.
+//
+// Shards are stored before the metadata that makes them reachable, so a process that dies in
+// between leaves shards no listing or delete will ever find. With an upload log directory set,
+// every upload first writes an entry there recording its intent: the object key and, once the
+// shards are placed, the bucket and key planned for every shard copy. When all shards are
+// stored the entry is rewritten with the complete metadata, and once the metadata is written
+// (or the upload has rolled itself back) the entry is removed. Entries are written to a temp
+// file and renamed, so a crash never leaves a partial one.
+//
+// An entry still present belongs to an upload that never finished. RecoverUploads resolves it:
+//   - Metadata recorded and enough shards present to reconstruct: the metadata is written,
+//     completing the upload, unless the key has since been uploaded again.
+//   - Otherwise every shard copy the upload planned or stored is deleted, except ones the
+//     object's current metadata references.
+//
+// Content-addressed buckets (IPFS) return keys only once a shard is stored, so shards of an
+// upload that crashed before its metadata was recorded cannot be found there.
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/domain"
+)
+
+// Phases of an upload log entry
+const (
+	uploadPhasePlanned = "planned" // Shards may be partly stored; no metadata yet
+	uploadPhaseStored  = "stored"  // Every shard that will be stored is; metadata not yet written
+)
+
+// Actions RecoverUploads takes for an interrupted upload
+const (
+	RecoveryCompleted = "completed" // The recorded metadata was written
+	RecoveryCleaned   = "cleaned"   // The upload's shards were deleted
+	RecoveryFinished  = "finished"  // The metadata was already written; only the entry was left
+)
+
+// uploadLogSuffix names upload log entries
+const uploadLogSuffix = ".upload.json"
+
+// uploadLogEntry is the on-disk record of an upload in progress
+type uploadLogEntry struct {
+	Key       string                 `json:"key"`
+	StartedAt time.Time              `json:"started_at"`
+	Phase     string                 `json:"phase"`
+	Planned   []domain.Location      `json:"planned,omitempty"`  // Every planned shard copy
+	Metadata  *domain.ObjectMetadata `json:"metadata,omitempty"` // Set in the stored phase
+
+	path string // File the entry is stored in
+}
+
+// InterruptedUpload describes an upload found in the upload log and what recovery did with it
+type InterruptedUpload struct {
+	Key       string    `json:"key"`
+	StartedAt time.Time `json:"started_at"`
+	Phase     string    `json:"phase"`
+	Action    string    `json:"action"`          // One of the Recovery* actions; planned only in a dry run
+	Deleted   int       `json:"deleted_shards"`  // Shard copies deleted (or that would be)
+	Err       string    `json:"error,omitempty"` // Why the entry could not be resolved; it is kept for another attempt
+}
+
+// uploadLogKey is the context key of the log entry of the upload in progress
+type uploadLogKey struct{}
+
+// SetUploadLogDir sets the directory of the upload log, creating it if needed
+// An empty directory disables the log.
+func (s *FileService) SetUploadLogDir(dir string) error {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create upload log directory: %w", err)
+		}
+	}
+	s.uploadLogDir = dir
+	return nil
+}
+
+// beginUploadLog records the intent to upload key and returns a context carrying the entry
+// Without an upload log the context is returned unchanged with a nil entry.
+func (s *FileService) beginUploadLog(ctx context.Context, key string) (context.Context, *uploadLogEntry, error) {
+	if s.uploadLogDir == "" {
+		return ctx, nil, nil
+	}
+	started := time.Now().UTC()
+	id := sha256.Sum256([]byte(key))
+	name := fmt.Sprintf("%s-%d-%d%s", hex.EncodeToString(id[:8]), started.UnixNano(), os.Getpid(), uploadLogSuffix)
+	entry := &uploadLogEntry{Key: key, StartedAt: started, Phase: uploadPhasePlanned, path: filepath.Join(s.uploadLogDir, name)}
+	if err := entry.write(); err != nil {
+		return ctx, nil, err
+	}
+	return context.WithValue(ctx, uploadLogKey{}, entry), entry, nil
+}
+
+// logPlannedShards records where the upload run with ctx will store its shards
+func logPlannedShards(ctx context.Context, placements [][]shardPlacement, shardKey func(index int) string) error {
+	entry, ok := ctx.Value(uploadLogKey{}).(*uploadLogEntry)
+	if !ok {
+		return nil
+	}
+	for i, placed := range placements {
+		for _, placedCopy := range placed {
+			entry.Planned = append(entry.Planned, domain.Location{StorageType: placedCopy.repo.GetStorageType(), BucketName: placedCopy.bucketName, Key: shardKey(i)})
+		}
+	}
+	return entry.write()
+}
+
+// markStored records the complete metadata of an upload whose shards are stored
+func (e *uploadLogEntry) markStored(metadata domain.ObjectMetadata) error {
+	if e == nil {
+		return nil
+	}
+	e.Phase = uploadPhaseStored
+	e.Metadata = &metadata
+	return e.write()
+}
+
+// finish removes the entry of an upload that completed or rolled itself back
+func (e *uploadLogEntry) finish() {
+	if e == nil {
+		return
+	}
+	if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Could not remove upload log entry %s: %v", e.path, err)
+	}
+}
+
+// write stores the entry through a temp file and rename
+func (e *uploadLogEntry) write() error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(e.path), ".upload-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write upload log: %w", err)
+	}
+	_, err = temp.Write(data)
+	if syncErr := temp.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), e.path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("failed to write upload log: %w", err)
+	}
+	return nil
+}
+
+// readUploadLog reads the entries in the upload log, oldest first
+func (s *FileService) readUploadLog() ([]*uploadLogEntry, error) {
+	if s.uploadLogDir == "" {
+		return nil, fmt.Errorf("no upload log directory is configured")
+	}
+	names, err := filepath.Glob(filepath.Join(s.uploadLogDir, "*"+uploadLogSuffix))
+	if err != nil {
+		return nil, err
+	}
+	var entries []*uploadLogEntry
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		entry := &uploadLogEntry{path: name}
+		if err := json.Unmarshal(data, entry); err != nil {
+			log.Warnf("Skipping unreadable upload log entry %s: %v", name, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].StartedAt.Before(entries[j].StartedAt) })
+	return entries, nil
+}
+
+// RecoverUploads resolves the uploads left in the upload log by processes that did not finish them
+// Entries younger than olderThan are skipped, since they may belong to uploads still running.
+// A dry run reports what would be done without changing anything.
+func (s *FileService) RecoverUploads(ctx context.Context, olderThan time.Duration, dryRun bool) ([]InterruptedUpload, error) {
+	entries, err := s.readUploadLog()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []InterruptedUpload{}
+	for _, entry := range entries {
+		if time.Since(entry.StartedAt) < olderThan {
+			continue
+		}
+		result := InterruptedUpload{Key: entry.Key, StartedAt: entry.StartedAt, Phase: entry.Phase}
+		if err := s.recoverUpload(ctx, entry, &result, dryRun); err != nil {
+			result.Err = err.Error()
+		} else if !dryRun {
+			entry.finish()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// recoverUpload completes or cleans up one interrupted upload
+func (s *FileService) recoverUpload(ctx context.Context, entry *uploadLogEntry, result *InterruptedUpload, dryRun bool) error {
+	current, err := s.metadataRepo.GetMetadata(ctx, filepath.Dir(entry.Key), filepath.Base(entry.Key))
+	hasCurrent := err == nil
+	superseded := hasCurrent && current.CreatedAt.After(entry.StartedAt)
+
+	if entry.Metadata != nil && !superseded {
+		if hasCurrent && current.ETag() == entry.Metadata.ETag() {
+			result.Action = RecoveryFinished
+			return nil
+		}
+		if s.presentShards(ctx, *entry.Metadata) >= entry.Metadata.DataShards {
+			result.Action = RecoveryCompleted
+			if dryRun {
+				return nil
+			}
+			if _, err := s.metadataRepo.CreateMetadata(ctx, *entry.Metadata); err != nil {
+				return fmt.Errorf("metadata for %s could not be stored: %w", entry.Key, err)
+			}
+			s.invalidateCachedObject(entry.Key)
+			return nil
+		}
+	}
+
+	// Nothing to complete: delete what the upload stored, sparing shards the current object uses
+	result.Action = RecoveryCleaned
+	keep := map[string]bool{}
+	if hasCurrent {
+		for _, shard := range current.ShardHashes {
+			for _, location := range shard.Locations {
+				keep[location.BucketName+"/"+location.Key] = true
+			}
+		}
+	}
+	var errs []error
+	for _, location := range s.spreadLocations(entry.locations(), entry.Key) {
+		if keep[location.BucketName+"/"+location.Key] {
+			continue
+		}
+		repo, err := s.placer.GetRepositoryForBucket(location.BucketName)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err := repo.GetObjectSize(ctx, location.Key); err != nil {
+			continue // Never stored, or already gone
+		}
+		result.Deleted++
+		if dryRun {
+			continue
+		}
+		if err := repo.Delete(ctx, location.Key); err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s: %w", location.BucketName, location.Key, err))
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+// locations returns every shard copy an entry planned or recorded, without duplicates
+func (e *uploadLogEntry) locations() []domain.Location {
+	seen := map[string]bool{}
+	var locations []domain.Location
+	add := func(location domain.Location) {
+		id := location.BucketName + "/" + location.Key
+		if location.Key != "" && !seen[id] {
+			seen[id] = true
+			locations = append(locations, location)
+		}
+	}
+	for _, location := range e.Planned {
+		add(location)
+	}
+	if e.Metadata != nil {
+		for _, shard := range e.Metadata.ShardHashes {
+			for _, location := range shard.Locations {
+				add(location)
+			}
+		}
+	}
+	return locations
+}
+
+// spreadLocations adds the copy of every shard key of the object in every registered bucket to locations
+// A shard moved to a spare bucket by a retry keeps its key, so it is found there too.
+func (s *FileService) spreadLocations(locations []domain.Location, key string) []domain.Location {
+	seen := map[string]bool{}
+	for _, location := range locations {
+		seen[location.BucketName+"/"+location.Key] = true
+	}
+	spread := append([]domain.Location(nil), locations...)
+	for _, location := range locations {
+		if !strings.HasPrefix(location.Key, shardPrefix(key)) {
+			continue
+		}
+		for _, bucketName := range s.placer.ListBuckets() {
+			if id := bucketName + "/" + location.Key; !seen[id] {
+				seen[id] = true
+				spread = append(spread, domain.Location{BucketName: bucketName, Key: location.Key})
+			}
+		}
+	}
+	return spread
+}
+
+// presentShards counts the shards of an object with at least one stored copy
+func (s *FileService) presentShards(ctx context.Context, metadata domain.ObjectMetadata) int {
+	present := 0
+	for _, shard := range metadata.ShardHashes {
+		for _, location := range shard.Locations {
+			repo, err := s.placer.GetRepositoryForBucket(location.BucketName)
+			if err != nil {
+				continue
+			}
+			if _, err := repo.GetObjectSize(ctx, location.Key); err == nil {
+				present++
+				break
+			}
+		}
+	}
+	return present
+}
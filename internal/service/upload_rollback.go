@@ -0,0 +1,110 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements the final metadata write of an upload and the rollback of its shards.
+//
+// An object exists once its metadata is stored; shards without metadata are unreachable
+// and are never listed, so nothing would ever delete them. The metadata write is therefore
+// retried while the store throttles or is temporarily unavailable, backing off between
+// attempts with the upload's own budget; when the repository is a RetryingMetadataRepository
+// its retries are bypassed for this write so the two loops do not multiply.
+//
+// A write that failed with anything but throttling may still have landed, so before rolling
+// back the record is read again. If it holds this upload's shards the upload succeeded; if
+// it belongs to another upload, only the shard copies that record does not reference are
+// deleted; if it cannot be read, the shards are kept and logged rather than risk deleting
+// a stored object. If too many shard uploads failed, every shard copy the upload stored is
+// deleted. Copies that cannot be deleted are logged by location.
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+// Defaults for the final metadata write of an upload
+const (
+	DefaultMetadataWriteAttempts = 3
+	DefaultMetadataWriteDelay    = 200 * time.Millisecond
+)
+
+// SetMetadataWriteRetries sets how often the metadata of an upload is written before its shards are rolled back
+// Attempts below 1 write once; the delay before each retry doubles, capped at maxMetadataRetryDelay.
+func (s *FileService) SetMetadataWriteRetries(attempts int, delay time.Duration) {
+	s.metadataWriteAttempts = max(attempts, 1)
+	s.metadataWriteDelay = delay
+}
+
+// storeUploadMetadata writes the metadata of an uploaded object, rolling back its shards if that fails
+func (s *FileService) storeUploadMetadata(ctx context.Context, key string, metadata domain.ObjectMetadata) error {
+	repo := s.metadataRepo
+	if retrying, ok := repo.(*RetryingMetadataRepository); ok {
+		repo = retrying.inner
+	}
+	err := retryMetadataCall(ctx, "Storing metadata for "+key, s.metadataWriteAttempts, s.metadataWriteDelay, func() error {
+		_, err := repo.CreateMetadata(ctx, metadata)
+		return err
+	})
+	if err == nil {
+		s.invalidateCachedObject(key)
+		return nil
+	}
+
+	// A throttled write was rejected; any other failure may have been applied
+	if stderrors.Is(err, errors.ErrMetadataThrottled) {
+		s.rollbackShards(ctx, key, metadata)
+		return fmt.Errorf("%w: metadata for %s could not be stored: %w", errors.ErrUploadRolledBack, key, err)
+	}
+	stored, readErr := s.metadataRepo.GetMetadata(context.WithoutCancel(ctx), metadata.Prefix, metadata.FileName)
+	switch {
+	case readErr == nil:
+		// Shard keys derive from content hashes, so the stored record may reference these copies
+		orphaned := newShardsOnly(metadata, stored)
+		if shardLocationCount(orphaned) == 0 {
+			log.Warnf("Storing metadata for %s reported an error but the record was written: %v", key, err)
+			s.invalidateCachedObject(key)
+			return nil
+		}
+		s.rollbackShards(ctx, key, orphaned)
+	case stderrors.Is(readErr, errors.ErrMetadataNotFound):
+		s.rollbackShards(ctx, key, metadata)
+	default:
+		for i, shard := range metadata.ShardHashes {
+			for _, location := range shard.Locations {
+				log.Warnf("Kept shard %d of %s at %s/%s; its metadata may not have been stored", i, key, location.BucketName, location.Key)
+			}
+		}
+		return fmt.Errorf("metadata for %s could not be stored or read back, shards were kept: %w", key, err)
+	}
+	return fmt.Errorf("%w: metadata for %s could not be stored: %w", errors.ErrUploadRolledBack, key, err)
+}
+
+// shardLocationCount returns how many shard copies metadata records
+func shardLocationCount(metadata domain.ObjectMetadata) int {
+	count := 0
+	for _, shard := range metadata.ShardHashes {
+		count += len(shard.Locations)
+	}
+	return count
+}
+
+// rollbackShards deletes every shard copy recorded in the metadata of a failed upload
+// The deletes run even if ctx was cancelled, since an interrupted upload orphans shards too.
+func (s *FileService) rollbackShards(ctx context.Context, key string, metadata domain.ObjectMetadata) {
+	ctx = context.WithoutCancel(ctx)
+	for i, shard := range metadata.ShardHashes {
+		for _, location := range shard.Locations {
+			repo, err := s.placer.GetRepositoryForBucket(location.BucketName)
+			if err == nil {
+				err = repo.Delete(ctx, location.Key)
+			}
+			if err != nil {
+				log.Warnf("Could not roll back shard %d of %s; delete %s/%s by hand: %v", i, key, location.BucketName, location.Key, err)
+			}
+		}
+	}
+}
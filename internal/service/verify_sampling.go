@@ -0,0 +1,39 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements sampled shard integrity checks on reads.
+//
+// Checking a shard's CRC64 hash means reading it back in full, which costs CPU and I/O on
+// every read. Reads that do not ask for verification instead check a random fraction of the
+// shards they download (see SetVerifySampleRate), so corruption still surfaces early without
+// paying for a full check every time. Reads that ask for it (download --verify-integrity)
+// and fsck repairs check every shard regardless of the rate. A sampled shard that fails
+// its check is replaced like any other unreadable shard.
+package service
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// SetVerifySampleRate sets the fraction of shards checked on reads that do not ask for verification
+// The rate must be between 0 (no shard is checked, the default) and 1 (every shard is).
+func (s *FileService) SetVerifySampleRate(rate float64) error {
+	if math.IsNaN(rate) || rate < 0 || rate > 1 {
+		return fmt.Errorf("invalid verify sample rate %v: must be between 0 and 1", rate)
+	}
+	s.verifySampleRate = rate
+	return nil
+}
+
+// shouldVerifyShard decides whether a downloaded shard's hash is checked
+// Every shard is checked when verifyIntegrity is set; otherwise each one is with probability verifySampleRate.
+func (s *FileService) shouldVerifyShard(verifyIntegrity bool) bool {
+	switch {
+	case verifyIntegrity || s.verifySampleRate >= 1:
+		return true
+	case s.verifySampleRate <= 0:
+		return false
+	default:
+		return rand.Float64() < s.verifySampleRate
+	}
+}
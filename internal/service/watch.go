@@ -0,0 +1,331 @@
+// Package service provides the core business logic for the erasure coding object storage system.
+// This file implements watching a directory and uploading its changes as they happen.
+//
+// WatchDir first brings the prefix up to date with the directory, walking it like UploadDir
+// does, then keeps it up to date from filesystem notifications (fsnotify) until its context
+// is cancelled. Changes are debounced per file: a file is handled once it has seen no event
+// for the debounce period, so a file being written is uploaded once, after the last write.
+//
+// A file is only uploaded when it differs from the object stored under its key: a file of
+// the recorded size whose CRC64 matches the recorded file hash is left alone, so restarting
+// the watch does not upload the whole tree again. Objects recorded without a file hash are
+// uploaded again. With DeleteRemote, removing a file deletes its object.
+//
+// Files are handled one at a time, in the order their debounce periods end. On
+// cancellation, changes still waiting for their debounce period are handled before
+// WatchDir returns, so stopping the watch does not lose them.
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+// minWatchTick bounds how often WatchDir checks for files whose debounce period has ended
+const minWatchTick = 10 * time.Millisecond
+
+// WatchOptions configure a directory watch
+type WatchOptions struct {
+	Prefix       string        // Key prefix the directory is stored under
+	Ignore       []string      // path.Match patterns; a file is ignored if its path or any of its path segments match
+	Debounce     time.Duration // Time without events after which a changed file is handled
+	DeleteRemote bool          // Delete a file's object when the file is removed
+	DataShards   int
+	ParityShards int
+	Concurrency  int
+	OnEvent      func(WatchEvent) // Called after each file is handled (may be nil)
+}
+
+// WatchAction is what a watch did about a file
+type WatchAction string
+
+const (
+	WatchUploaded  WatchAction = "uploaded"
+	WatchUnchanged WatchAction = "unchanged" // The stored object already has the file's content
+	WatchDeleted   WatchAction = "deleted"
+	WatchSkipped   WatchAction = "skipped" // The file cannot be stored (empty or not a regular file)
+	WatchFailed    WatchAction = "failed"
+)
+
+// WatchEvent reports a file handled by a watch
+type WatchEvent struct {
+	Path   string // Path relative to the watched directory, slash-separated
+	Key    string
+	Action WatchAction
+	Reason string // Why the file was skipped
+	Err    error  // Why handling the file failed
+}
+
+// WatchDir keeps the objects under opts.Prefix up to date with dir until ctx is cancelled
+// Failures to upload or delete single files are reported through opts.OnEvent and retried
+// on the file's next change; WatchDir only returns an error if the watch cannot be set up.
+func (s *FileService) WatchDir(ctx context.Context, dir string, opts WatchOptions) error {
+	if err := s.checkPlacement(opts.DataShards, opts.ParityShards); err != nil {
+		return err
+	}
+	for _, pattern := range opts.Ignore {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid ignore pattern %q: %w", pattern, err)
+		}
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start watching %s: %w", dir, err)
+	}
+	defer watcher.Close()
+
+	w := &dirWatcher{
+		service: s,
+		watcher: watcher,
+		dir:     dir,
+		opts:    opts,
+		pending: make(map[string]time.Time),
+	}
+	// Watch first, so changes made during the initial sync are not missed
+	if err := w.addTree(""); err != nil {
+		return err
+	}
+	if err := w.syncTree(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	ticker := time.NewTicker(max(opts.Debounce/2, minWatchTick))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// Handle what is still pending, without letting the cancellation abort the uploads
+			w.handleDue(context.WithoutCancel(ctx), time.Time{})
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			w.event(event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Warnf("Watching %s: %v", dir, err)
+		case now := <-ticker.C:
+			w.handleDue(ctx, now.Add(-opts.Debounce))
+		}
+	}
+}
+
+// dirWatcher carries the state of one WatchDir call
+type dirWatcher struct {
+	service *FileService
+	watcher *fsnotify.Watcher
+	dir     string
+	opts    WatchOptions
+	pending map[string]time.Time // Last event time of each changed path, relative to dir
+}
+
+// addTree watches the directory at rel and every directory below it
+// fsnotify only reports changes to a directory's direct entries, so every directory needs its own watch.
+func (w *dirWatcher) addTree(rel string) error {
+	root := filepath.Join(w.dir, filepath.FromSlash(rel))
+	return filepath.WalkDir(root, func(diskPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if entryRel := w.rel(diskPath); entryRel != "" && w.ignored(entryRel) {
+			return filepath.SkipDir
+		}
+		if err := w.watcher.Add(diskPath); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", diskPath, err)
+		}
+		return nil
+	})
+}
+
+// syncTree handles every file under the directory, uploading those that differ from their objects
+func (w *dirWatcher) syncTree(ctx context.Context) error {
+	walker := &dirUploader{service: w.service, visited: make(map[string]bool)}
+	if err := walker.walk(ctx, w.dir, ""); err != nil {
+		return err
+	}
+	for _, file := range walker.files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !w.ignored(file.rel) {
+			w.handle(ctx, file.rel)
+		}
+	}
+	return nil
+}
+
+// event records a filesystem notification, starting to watch directories created in the tree
+func (w *dirWatcher) event(event fsnotify.Event) {
+	rel := w.rel(event.Name)
+	if rel == "" || w.ignored(rel) || event.Op == fsnotify.Chmod {
+		return
+	}
+	now := time.Now()
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			// Files can be created in the directory before it is watched
+			if err := w.addTree(rel); err != nil {
+				log.Warnf("%v", err)
+			}
+			filepath.WalkDir(event.Name, func(diskPath string, entry fs.DirEntry, err error) error {
+				if err == nil && !entry.IsDir() {
+					w.pending[w.rel(diskPath)] = now
+				}
+				return nil
+			})
+			return
+		}
+	}
+	w.pending[rel] = now
+}
+
+// handleDue handles the pending paths whose last event is not after cutoff, oldest first
+// A zero cutoff handles every pending path.
+func (w *dirWatcher) handleDue(ctx context.Context, cutoff time.Time) {
+	var due []string
+	for rel, last := range w.pending {
+		if cutoff.IsZero() || !last.After(cutoff) {
+			due = append(due, rel)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return w.pending[due[i]].Before(w.pending[due[j]]) })
+	for _, rel := range due {
+		delete(w.pending, rel)
+		w.handle(ctx, rel)
+	}
+}
+
+// handle brings the object of the file at rel up to date with the file
+func (w *dirWatcher) handle(ctx context.Context, rel string) {
+	diskPath := filepath.Join(w.dir, filepath.FromSlash(rel))
+	event := WatchEvent{Path: rel, Key: path.Join(w.opts.Prefix, rel)}
+
+	info, err := os.Lstat(diskPath)
+	switch {
+	case stderrors.Is(err, fs.ErrNotExist):
+		if !w.opts.DeleteRemote {
+			return
+		}
+		if _, err := w.service.StatFile(ctx, event.Key); stderrors.Is(err, errors.ErrMetadataNotFound) {
+			return // Never uploaded, or a removed directory
+		}
+		event.Action, event.Err = WatchDeleted, w.service.DeleteFile(ctx, event.Key)
+	case err != nil:
+		event.Action, event.Err = WatchFailed, err
+	case info.IsDir():
+		return
+	case !info.Mode().IsRegular():
+		event.Action, event.Reason = WatchSkipped, "not a regular file"
+	case info.Size() == 0:
+		event.Action, event.Reason = WatchSkipped, "empty file"
+	default:
+		event.Action, event.Err = w.upload(ctx, diskPath, event.Key, info)
+	}
+	if event.Err != nil {
+		event.Action = WatchFailed
+	}
+	if w.opts.OnEvent != nil {
+		w.opts.OnEvent(event)
+	}
+}
+
+// upload stores the file at diskPath under key unless the stored object already has its content
+func (w *dirWatcher) upload(ctx context.Context, diskPath, key string, info fs.FileInfo) (WatchAction, error) {
+	f, err := os.Open(diskPath)
+	if err != nil {
+		return WatchFailed, err
+	}
+	defer f.Close()
+
+	unchanged, err := w.service.localFileUnchanged(ctx, key, f, info.Size())
+	if err != nil {
+		return WatchFailed, err
+	}
+	if unchanged {
+		return WatchUnchanged, nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return WatchFailed, err
+	}
+	if err := w.service.UploadLocalFile(ctx, key, info, f, true, w.opts.DataShards, w.opts.ParityShards, w.opts.Concurrency); err != nil {
+		return WatchFailed, err
+	}
+	return WatchUploaded, nil
+}
+
+// localFileUnchanged reports whether the object at key holds exactly the size bytes of r
+// Objects recorded without a file hash are never considered unchanged. r is read only
+// when the sizes match.
+func (s *FileService) localFileUnchanged(ctx context.Context, key string, r io.Reader, size int64) (bool, error) {
+	metadata, err := s.StatFile(ctx, key)
+	if stderrors.Is(err, errors.ErrMetadataNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if metadata.ContentSize() != size || metadata.ContentHash() == "" {
+		return false, nil
+	}
+	hash := crc64.New(crc64.MakeTable(crc64.ISO))
+	if _, err := io.Copy(hash, r); err != nil {
+		return false, err
+	}
+	return fmt.Sprintf("%016x", hash.Sum64()) == metadata.ContentHash(), nil
+}
+
+// rel returns a path below the watched directory relative to it, slash-separated
+func (w *dirWatcher) rel(diskPath string) string {
+	rel, err := filepath.Rel(w.dir, diskPath)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// ignored reports whether a path, or a directory it is in, matches an ignore pattern
+func (w *dirWatcher) ignored(rel string) bool {
+	segments := strings.Split(rel, "/")
+	for _, pattern := range w.opts.Ignore {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		for _, segment := range segments {
+			if ok, _ := path.Match(pattern, segment); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
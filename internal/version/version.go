@@ -0,0 +1,14 @@
+// Package version reports the zstore build version and the User-Agent derived from it.
+package version
+
+// Product identifies zstore in User-Agent headers
+const Product = "zstore"
+
+// Version is the zstore release, set at build time with
+// -ldflags "-X github.com/zzenonn/zstore/internal/version.Version=v1.2.3"
+var Version = "dev"
+
+// UserAgent returns the token zstore adds to the User-Agent of cloud requests, e.g. "zstore/v1.2.3"
+func UserAgent() string {
+	return Product + "/" + Version
+}
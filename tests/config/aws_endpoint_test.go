@@ -0,0 +1,102 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/zzenonn/zstore/internal/config"
+)
+
+// hostTransport records the host of each request and answers with an empty success
+type hostTransport struct {
+	hosts []string
+}
+
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.hosts = append(t.hosts, req.URL.Host)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(bytes.NewReader([]byte("{}"))),
+		Request:    req,
+	}, nil
+}
+
+// loadEndpointConfig loads an AWS config with the endpoint options and a recording transport
+func loadEndpointConfig(t *testing.T, useFIPS bool, endpointURL string) (aws.Config, *hostTransport) {
+	t.Helper()
+	endpointOpts, err := config.AWSEndpointOptions(useFIPS, endpointURL)
+	if err != nil {
+		t.Fatalf("AWSEndpointOptions failed: %v", err)
+	}
+	transport := &hostTransport{}
+	opts := append([]func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion("us-east-1"),
+		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("AKID", "SECRET", "")),
+	}, endpointOpts...)
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.HTTPClient = &http.Client{Transport: transport}
+	return cfg, transport
+}
+
+func TestAWSEndpointOptions_FIPSPropagatesToClients(t *testing.T) {
+	cfg, transport := loadEndpointConfig(t, true, "")
+
+	var fipsState aws.FIPSEndpointState
+	for _, source := range cfg.ConfigSources {
+		if options, ok := source.(awsconfig.LoadOptions); ok {
+			fipsState = options.UseFIPSEndpoint
+		}
+	}
+	if fipsState != aws.FIPSEndpointStateEnabled {
+		t.Fatalf("Expected the resolved config to enable FIPS endpoints, got %v", fipsState)
+	}
+
+	ctx := context.Background()
+	if _, err := dynamodb.NewFromConfig(cfg).ListTables(ctx, &dynamodb.ListTablesInput{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s3.NewFromConfig(cfg).DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("b"), Key: aws.String("k")}); err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.hosts) != 2 || transport.hosts[0] != "dynamodb-fips.us-east-1.amazonaws.com" || !strings.Contains(transport.hosts[1], "s3-fips.us-east-1.amazonaws.com") {
+		t.Errorf("Expected requests to the DynamoDB and S3 FIPS endpoints, got %v", transport.hosts)
+	}
+}
+
+func TestAWSEndpointOptions_CustomEndpointURL(t *testing.T) {
+	cfg, transport := loadEndpointConfig(t, false, "https://vpce-123.dynamodb.us-east-1.vpce.amazonaws.com")
+	if cfg.BaseEndpoint == nil || *cfg.BaseEndpoint != "https://vpce-123.dynamodb.us-east-1.vpce.amazonaws.com" {
+		t.Fatalf("Expected the endpoint URL in the resolved config, got %v", cfg.BaseEndpoint)
+	}
+	if _, err := dynamodb.NewFromConfig(cfg).ListTables(context.Background(), &dynamodb.ListTablesInput{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.hosts) != 1 || transport.hosts[0] != "vpce-123.dynamodb.us-east-1.vpce.amazonaws.com" {
+		t.Errorf("Expected the request to go to the custom endpoint, got %v", transport.hosts)
+	}
+}
+
+func TestAWSEndpointOptions_RejectsInvalidURL(t *testing.T) {
+	for _, endpointURL := range []string{"not a url", "s3.amazonaws.com", "https://"} {
+		if _, err := config.AWSEndpointOptions(false, endpointURL); err == nil {
+			t.Errorf("Expected %q to be rejected", endpointURL)
+		}
+	}
+	opts, err := config.AWSEndpointOptions(false, "")
+	if err != nil || len(opts) != 0 {
+		t.Errorf("Expected no options by default, got %d (%v)", len(opts), err)
+	}
+}
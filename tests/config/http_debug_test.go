@@ -0,0 +1,77 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/config"
+)
+
+func TestRedactHTTPDump_RedactsCredentials(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		line   string
+		secret string
+	}{
+		{"authorization", "Authorization: AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20250101/us-east-1/s3/aws4_request, Signature=abc123", "AKIDEXAMPLE"},
+		{"session token", "X-Amz-Security-Token: FQoGZXIvYXdzEXAMPLETOKEN", "FQoGZXIvYXdzEXAMPLETOKEN"},
+		{"SSE-C key", "X-Amz-Server-Side-Encryption-Customer-Key: c2VjcmV0LWN1c3RvbWVyLWtleQ==", "c2VjcmV0LWN1c3RvbWVyLWtleQ=="},
+		{"SSE-C key MD5", "X-Amz-Server-Side-Encryption-Customer-Key-MD5: a2V5LWRpZ2VzdA==", "a2V5LWRpZ2VzdA=="},
+		{"copy source SSE-C key", "X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key: Y29weS1rZXk=", "Y29weS1rZXk="},
+		{"GCS customer key", "X-Goog-Encryption-Key: Z2NzLWtleQ==", "Z2NzLWtleQ=="},
+		{"lower case header", "x-amz-security-token: lowercasetoken", "lowercasetoken"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dump := "PUT /bucket/key HTTP/1.1\r\nHost: s3.amazonaws.com\r\n" + tc.line + "\r\nContent-Length: 5\r\n\r\n"
+			redacted := config.RedactHTTPDump(dump)
+			if strings.Contains(redacted, tc.secret) {
+				t.Errorf("Expected %q to be redacted, got %q", tc.secret, redacted)
+			}
+			if !strings.Contains(redacted, "[REDACTED]") {
+				t.Errorf("Expected a redaction marker, got %q", redacted)
+			}
+			if !strings.Contains(redacted, "Host: s3.amazonaws.com\r\n") || !strings.Contains(redacted, "Content-Length: 5\r\n") {
+				t.Errorf("Expected the surrounding headers to be kept, got %q", redacted)
+			}
+		})
+	}
+}
+
+func TestRedactHTTPDump_RedactsPresignedQuerySignatures(t *testing.T) {
+	for _, tc := range []struct {
+		url     string
+		secrets []string
+	}{
+		{
+			"GET /bucket/key?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=AKIDEXAMPLE%2F20250101&X-Amz-Security-Token=sessiontoken&X-Amz-Signature=deadbeef HTTP/1.1",
+			[]string{"AKIDEXAMPLE", "sessiontoken", "deadbeef"},
+		},
+		{
+			"https://storage.googleapis.com/bucket/key?X-Goog-Algorithm=GOOG4-RSA-SHA256&X-Goog-Credential=svc%40project&X-Goog-Signature=0123abcd",
+			[]string{"svc%40project", "0123abcd"},
+		},
+	} {
+		redacted := config.RedactHTTPDump(tc.url)
+		for _, secret := range tc.secrets {
+			if strings.Contains(redacted, secret) {
+				t.Errorf("Expected %q to be redacted, got %q", secret, redacted)
+			}
+		}
+		if !strings.Contains(redacted, "Algorithm=") {
+			t.Errorf("Expected harmless query parameters to be kept, got %q", redacted)
+		}
+	}
+}
+
+func TestRedactHTTPDump_KeepsHarmlessHeaders(t *testing.T) {
+	dump := "GET /bucket/key?list-type=2&prefix=docs HTTP/1.1\r\n" +
+		"Host: s3.amazonaws.com\r\n" +
+		"Content-Type: application/octet-stream\r\n" +
+		"X-Amz-Date: 20250101T000000Z\r\n" +
+		"X-Amz-Content-Sha256: UNSIGNED-PAYLOAD\r\n" +
+		"X-Amz-Server-Side-Encryption-Customer-Algorithm: AES256\r\n" +
+		"User-Agent: zstore/1.0\r\n\r\n"
+	if redacted := config.RedactHTTPDump(dump); redacted != dump {
+		t.Errorf("Expected a dump without credentials to be unchanged, got %q", redacted)
+	}
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/config"
+)
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadECDSAPublicKey_AcceptsPublicAndPrivateKeys(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	privateDER, _ := x509.MarshalECPrivateKey(key)
+
+	for name, path := range map[string]string{
+		"public":  writePEM(t, "PUBLIC KEY", publicDER),
+		"private": writePEM(t, "EC PRIVATE KEY", privateDER),
+	} {
+		loaded, err := config.LoadECDSAPublicKey(path)
+		if err != nil {
+			t.Fatalf("Loading the %s key failed: %v", name, err)
+		}
+		if !loaded.Equal(&key.PublicKey) {
+			t.Errorf("Expected the %s key file to yield the original public key", name)
+		}
+	}
+
+	if _, err := config.LoadECDSAPublicKey(writePEM(t, "PUBLIC KEY", []byte("garbage"))); err == nil {
+		t.Error("Expected an unparseable key to fail")
+	}
+}
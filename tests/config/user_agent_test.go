@@ -0,0 +1,62 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/zzenonn/zstore/internal/config"
+	"github.com/zzenonn/zstore/internal/version"
+)
+
+// userAgentTransport records the User-Agent of each request and answers with an empty success
+type userAgentTransport struct {
+	userAgents []string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.userAgents = append(t.userAgents, req.Header.Get("User-Agent"))
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(bytes.NewReader(nil)),
+		Request:    req,
+	}, nil
+}
+
+func TestAWSUserAgent_IdentifiesZstoreAndVersion(t *testing.T) {
+	original := version.Version
+	version.Version = "v1.2.3"
+	defer func() { version.Version = original }()
+
+	transport := &userAgentTransport{}
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String("http://s3.test"),
+		UsePathStyle: true,
+		HTTPClient:   &http.Client{Transport: transport},
+		APIOptions:   []func(*middleware.Stack) error{config.AWSUserAgent()},
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil
+		}),
+	})
+	if _, err := client.DeleteObject(context.Background(), &s3.DeleteObjectInput{Bucket: aws.String("b"), Key: aws.String("k")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transport.userAgents) != 1 || !strings.Contains(transport.userAgents[0], "zstore/v1.2.3") {
+		t.Errorf("Expected the User-Agent to contain zstore/v1.2.3, got %q", transport.userAgents)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	if got := version.UserAgent(); got != "zstore/"+version.Version {
+		t.Errorf("Expected zstore/%s, got %q", version.Version, got)
+	}
+}
@@ -0,0 +1,445 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/db"
+)
+
+// tableTransport is an in-memory DynamoDB table keyed on two string attributes
+// It records the body of every request so tests can check the attribute names sent.
+type tableTransport struct {
+	mu           sync.Mutex
+	partitionKey string
+	sortKey      string
+	items        map[string]map[string]any
+	requests     map[string][]map[string]any // Request bodies by operation
+	throttled    bool                        // Reject every request with ProvisionedThroughputExceededException
+	cancelKey    string                      // Cancel any transaction writing this item key, with cancelCode as the reason
+	cancelCode   string
+}
+
+func newTableTransport(partitionKey, sortKey string) *tableTransport {
+	return &tableTransport{
+		partitionKey: partitionKey,
+		sortKey:      sortKey,
+		items:        make(map[string]map[string]any),
+		requests:     make(map[string][]map[string]any),
+	}
+}
+
+// itemKey returns the table key of an item or key map, failing if a key attribute is missing
+func (t *tableTransport) itemKey(item map[string]any) (string, bool) {
+	pk, ok1 := item[t.partitionKey].(map[string]any)
+	sk, ok2 := item[t.sortKey].(map[string]any)
+	if !ok1 || !ok2 {
+		return "", false
+	}
+	return pk["S"].(string) + "\x00" + sk["S"].(string), true
+}
+
+func (t *tableTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	operation := strings.TrimPrefix(req.Header.Get("X-Amz-Target"), "DynamoDB_20120810.")
+	var body map[string]any
+	data, _ := io.ReadAll(req.Body)
+	json.Unmarshal(data, &body)
+	t.requests[operation] = append(t.requests[operation], body)
+
+	status, response := http.StatusOK, map[string]any{}
+	if t.throttled {
+		status, response = http.StatusBadRequest, map[string]any{"__type": "com.amazonaws.dynamodb.v20120810#ProvisionedThroughputExceededException", "message": "Rate exceeded"}
+		operation = ""
+	}
+	missingKey := map[string]any{"__type": "com.amazonaws.dynamodb.v20120810#ValidationException", "message": "The provided key element does not match the schema"}
+	switch operation {
+	case "PutItem":
+		item := body["Item"].(map[string]any)
+		key, ok := t.itemKey(item)
+		if !ok {
+			status, response = http.StatusBadRequest, missingKey
+			break
+		}
+		t.items[key] = item
+	case "GetItem":
+		key, ok := t.itemKey(body["Key"].(map[string]any))
+		if !ok {
+			status, response = http.StatusBadRequest, missingKey
+			break
+		}
+		if item, found := t.items[key]; found {
+			response["Item"] = item
+		}
+	case "Query":
+		attribute := body["ExpressionAttributeNames"].(map[string]any)["#prefix"].(string)
+		value := body["ExpressionAttributeValues"].(map[string]any)[":prefix"].(map[string]any)["S"]
+		var items []any
+		for _, item := range t.items {
+			if attr, ok := item[attribute].(map[string]any); ok && attr["S"] == value {
+				items = append(items, item)
+			}
+		}
+		response["Items"], response["Count"] = items, len(items)
+	case "DeleteItem":
+		key, ok := t.itemKey(body["Key"].(map[string]any))
+		if !ok {
+			status, response = http.StatusBadRequest, missingKey
+			break
+		}
+		delete(t.items, key)
+	case "TransactWriteItems":
+		status, response = t.transact(body["TransactItems"].([]any))
+	}
+
+	encoded, _ := json.Marshal(response)
+	return &http.Response{
+		StatusCode:    status,
+		Header:        http.Header{"Content-Type": {"application/x-amz-json-1.0"}},
+		Body:          io.NopCloser(bytes.NewReader(encoded)),
+		ContentLength: int64(len(encoded)),
+		Request:       req,
+	}, nil
+}
+
+// transact applies every write of a transaction, or none of them if one is cancelled
+func (t *tableTransport) transact(writes []any) (int, map[string]any) {
+	type write struct {
+		key  string
+		item map[string]any // nil for deletes
+	}
+	var planned []write
+	reasons := make([]any, len(writes))
+	cancelled := false
+	for i, entry := range writes {
+		var w write
+		var ok bool
+		if put, isPut := entry.(map[string]any)["Put"].(map[string]any); isPut {
+			w.item = put["Item"].(map[string]any)
+			w.key, ok = t.itemKey(w.item)
+		} else {
+			w.key, ok = t.itemKey(entry.(map[string]any)["Delete"].(map[string]any)["Key"].(map[string]any))
+		}
+		if !ok {
+			return http.StatusBadRequest, map[string]any{"__type": "com.amazonaws.dynamodb.v20120810#ValidationException", "message": "The provided key element does not match the schema"}
+		}
+		reasons[i] = map[string]any{"Code": "None"}
+		if w.key == t.cancelKey {
+			reasons[i] = map[string]any{"Code": t.cancelCode, "Message": "cancelled by the test"}
+			cancelled = true
+		}
+		planned = append(planned, w)
+	}
+	if cancelled {
+		return http.StatusBadRequest, map[string]any{
+			"__type":              "com.amazonaws.dynamodb.v20120810#TransactionCanceledException",
+			"message":             "Transaction cancelled",
+			"CancellationReasons": reasons,
+		}
+	}
+	for _, w := range planned {
+		if w.item == nil {
+			delete(t.items, w.key)
+		} else {
+			t.items[w.key] = w.item
+		}
+	}
+	return http.StatusOK, map[string]any{}
+}
+
+func newTestRepository(transport *tableTransport) db.MetadataRepository {
+	client := dynamodb.New(dynamodb.Options{
+		Region:           "us-east-1",
+		BaseEndpoint:     aws.String("http://dynamodb.test"),
+		HTTPClient:       &http.Client{Transport: transport},
+		RetryMaxAttempts: 1,
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil
+		}),
+	})
+	return db.NewMetadataRepository(client, "objects")
+}
+
+func TestMetadataRepository_CustomKeyNames(t *testing.T) {
+	ctx := context.Background()
+	transport := newTableTransport("pk", "sk")
+	repo := newTestRepository(transport)
+	repo.SetKeyNames("pk", "sk")
+
+	metadata := domain.ObjectMetadata{Prefix: "docs", FileName: "report.pdf", OriginalSize: 42, ParityShards: 2}
+	if _, err := repo.CreateMetadata(ctx, metadata); err != nil {
+		t.Fatalf("CreateMetadata failed: %v", err)
+	}
+	item := transport.requests["PutItem"][0]["Item"].(map[string]any)
+	for _, attribute := range []string{"prefix", "file_name"} {
+		if _, ok := item[attribute]; ok {
+			t.Errorf("Expected %s to be stored under the configured key name", attribute)
+		}
+	}
+
+	got, err := repo.GetMetadata(ctx, "docs", "report.pdf")
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if got.Prefix != "docs" || got.FileName != "report.pdf" || got.OriginalSize != 42 {
+		t.Errorf("Expected the stored metadata back, got %+v", got)
+	}
+
+	list, err := repo.ListMetadataByPrefix(ctx, "docs")
+	if err != nil || len(list) != 1 || list[0].FileName != "report.pdf" {
+		t.Fatalf("Expected one listed object, got %+v (%v)", list, err)
+	}
+
+	if err := repo.DeleteMetadata(ctx, "docs", "report.pdf"); err != nil {
+		t.Fatalf("DeleteMetadata failed: %v", err)
+	}
+	if len(transport.items) != 0 {
+		t.Errorf("Expected the item to be deleted, %d left", len(transport.items))
+	}
+}
+
+func TestMetadataRepository_DefaultKeyNames(t *testing.T) {
+	ctx := context.Background()
+	transport := newTableTransport("prefix", "file_name")
+	repo := newTestRepository(transport)
+
+	if _, err := repo.CreateMetadata(ctx, domain.ObjectMetadata{Prefix: "docs", FileName: "a.txt"}); err != nil {
+		t.Fatalf("CreateMetadata failed: %v", err)
+	}
+	if got, err := repo.GetMetadata(ctx, "docs", "a.txt"); err != nil || got.FileName != "a.txt" {
+		t.Fatalf("Expected default key names to keep working, got %+v (%v)", got, err)
+	}
+}
+
+func TestMetadataRepository_NamespacesAreIsolated(t *testing.T) {
+	ctx := context.Background()
+	transport := newTableTransport("prefix", "file_name")
+	teamA, teamB := newTestRepository(transport), newTestRepository(transport)
+	if err := teamA.SetNamespace("team-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := teamB.SetNamespace("team-b"); err != nil {
+		t.Fatal(err)
+	}
+
+	for repo, size := range map[*db.MetadataRepository]int64{&teamA: 1, &teamB: 2} {
+		if _, err := repo.CreateMetadata(ctx, domain.ObjectMetadata{Prefix: "docs", FileName: "report.pdf", OriginalSize: size}); err != nil {
+			t.Fatalf("CreateMetadata failed: %v", err)
+		}
+	}
+	if len(transport.items) != 2 {
+		t.Fatalf("Expected both namespaces to store their own item, got %d items", len(transport.items))
+	}
+
+	got, err := teamA.GetMetadata(ctx, "docs", "report.pdf")
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if got.OriginalSize != 1 || got.Prefix != "docs" || got.Namespace != "team-a" {
+		t.Errorf("Expected team-a's record with its plain prefix, got %+v", got)
+	}
+
+	list, err := teamB.ListMetadataByPrefix(ctx, "docs")
+	if err != nil || len(list) != 1 || list[0].OriginalSize != 2 {
+		t.Fatalf("Expected only team-b's object to be listed, got %+v (%v)", list, err)
+	}
+
+	if err := teamA.DeleteMetadata(ctx, "docs", "report.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := teamB.GetMetadata(ctx, "docs", "report.pdf"); err != nil {
+		t.Errorf("Expected team-b's object to survive team-a's delete: %v", err)
+	}
+
+	// Items without a namespace stay separate from namespaced ones
+	unscoped := newTestRepository(transport)
+	if _, err := unscoped.GetMetadata(ctx, "docs", "report.pdf"); err == nil {
+		t.Error("Expected a repository without a namespace not to see namespaced items")
+	}
+}
+
+func TestMetadataRepository_NamespaceRejectsSeparator(t *testing.T) {
+	repo := newTestRepository(newTableTransport("prefix", "file_name"))
+	if err := repo.SetNamespace("team#a"); err == nil {
+		t.Error("Expected a namespace containing the separator to be rejected")
+	}
+}
+
+func TestMetadataRepository_ThrottlingIsTyped(t *testing.T) {
+	ctx := context.Background()
+	transport := newTableTransport("prefix", "file_name")
+	repo := newTestRepository(transport)
+	transport.throttled = true
+
+	if _, err := repo.CreateMetadata(ctx, domain.ObjectMetadata{Prefix: "docs", FileName: "a.txt"}); !stderrors.Is(err, errors.ErrMetadataThrottled) {
+		t.Errorf("Expected a throttled write to match ErrMetadataThrottled, got %v", err)
+	}
+	if _, err := repo.GetMetadata(ctx, "docs", "a.txt"); !stderrors.Is(err, errors.ErrMetadataThrottled) {
+		t.Errorf("Expected a throttled read to match ErrMetadataThrottled, got %v", err)
+	}
+
+	transport.throttled = false
+	if _, err := repo.GetMetadata(ctx, "docs", "a.txt"); stderrors.Is(err, errors.ErrMetadataThrottled) || !stderrors.Is(err, errors.ErrMetadataNotFound) {
+		t.Errorf("Expected only not found once throttling stops, got %v", err)
+	}
+}
+
+func TestMetadataRepository_ChecksumVerification(t *testing.T) {
+	ctx := context.Background()
+	transport := newTableTransport("prefix", "file_name")
+	repo := newTestRepository(transport)
+	repo.SetVerifyChecksum(true)
+
+	metadata := domain.ObjectMetadata{
+		Prefix:       "docs",
+		FileName:     "report.pdf",
+		OriginalSize: 42,
+		ParityShards: 2,
+		CreatedAt:    time.Now(), // Stored in whole seconds
+		ShardHashes:  []domain.ShardStorage{{Hash: "abc", Locations: []domain.Location{{BucketName: "b1", Key: "docs/report.pdf/0"}}}},
+	}
+	if _, err := repo.CreateMetadata(ctx, metadata); err != nil {
+		t.Fatalf("CreateMetadata failed: %v", err)
+	}
+	item := transport.items["docs\x00report.pdf"]
+	if _, ok := item["metadata_checksum"]; !ok {
+		t.Fatal("Expected the item to be written with a checksum")
+	}
+	if _, err := repo.GetMetadata(ctx, "docs", "report.pdf"); err != nil {
+		t.Fatalf("Expected an untouched item to verify, got %v", err)
+	}
+
+	// Edit the stored size behind the repository's back
+	item["original_size"] = map[string]any{"N": "43"}
+	if _, err := repo.GetMetadata(ctx, "docs", "report.pdf"); !stderrors.Is(err, errors.ErrMetadataChecksum) {
+		t.Errorf("Expected a tampered item to fail with ErrMetadataChecksum, got %v", err)
+	}
+
+	// Removing the checksum does not get around verification
+	delete(item, "metadata_checksum")
+	if _, err := repo.GetMetadata(ctx, "docs", "report.pdf"); !stderrors.Is(err, errors.ErrMetadataChecksum) {
+		t.Errorf("Expected an item without a checksum to fail with ErrMetadataChecksum, got %v", err)
+	}
+
+	// Without verification, items written before checksums existed are still readable
+	repo.SetVerifyChecksum(false)
+	if got, err := repo.GetMetadata(ctx, "docs", "report.pdf"); err != nil || got.OriginalSize != 43 {
+		t.Errorf("Expected the unverified item back, got %+v (%v)", got, err)
+	}
+}
+
+func TestMetadataRepository_ChecksumWithNamespaceAndCustomKeys(t *testing.T) {
+	ctx := context.Background()
+	transport := newTableTransport("pk", "sk")
+	repo := newTestRepository(transport)
+	repo.SetKeyNames("pk", "sk")
+	if err := repo.SetNamespace("team-a"); err != nil {
+		t.Fatal(err)
+	}
+	repo.SetVerifyChecksum(true)
+
+	metadata := domain.ObjectMetadata{Prefix: "docs", FileName: "a.txt", OriginalSize: 7, UserMetadata: map[string]string{"b": "2", "a": "1"}}
+	if _, err := repo.CreateMetadata(ctx, metadata); err != nil {
+		t.Fatalf("CreateMetadata failed: %v", err)
+	}
+	if _, err := repo.GetMetadata(ctx, "docs", "a.txt"); err != nil {
+		t.Errorf("Expected the item to verify with a namespace and custom key names, got %v", err)
+	}
+}
+
+func TestMetadataRepository_TransactAppliesPutAndDeleteTogether(t *testing.T) {
+	ctx := context.Background()
+	transport := newTableTransport("prefix", "file_name")
+	repo := newTestRepository(transport)
+	old := domain.ObjectMetadata{Prefix: "docs", FileName: "old.txt", OriginalSize: 42}
+	if _, err := repo.CreateMetadata(ctx, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// A move whose new record is rejected leaves the object where it was
+	moved := old
+	moved.Prefix, moved.FileName = "archive", "new.txt"
+	transport.cancelKey, transport.cancelCode = "archive\x00new.txt", "ConditionalCheckFailed"
+	if err := repo.Transact(ctx, []domain.ObjectMetadata{moved}, []domain.MetadataKey{old.Key()}); err == nil {
+		t.Fatal("Expected the cancelled transaction to fail")
+	}
+	if _, err := repo.GetMetadata(ctx, "docs", "old.txt"); err != nil {
+		t.Errorf("Expected the old record to survive a cancelled transaction, got %v", err)
+	}
+	if _, err := repo.GetMetadata(ctx, "archive", "new.txt"); !stderrors.Is(err, errors.ErrMetadataNotFound) {
+		t.Errorf("Expected no new record after a cancelled transaction, got %v", err)
+	}
+
+	// Once accepted, the object is at the new key only
+	transport.cancelKey = ""
+	if err := repo.Transact(ctx, []domain.ObjectMetadata{moved}, []domain.MetadataKey{old.Key()}); err != nil {
+		t.Fatalf("Transact failed: %v", err)
+	}
+	if got, err := repo.GetMetadata(ctx, "archive", "new.txt"); err != nil || got.OriginalSize != 42 {
+		t.Errorf("Expected the new record, got %+v (%v)", got, err)
+	}
+	if _, err := repo.GetMetadata(ctx, "docs", "old.txt"); !stderrors.Is(err, errors.ErrMetadataNotFound) {
+		t.Errorf("Expected the old record to be deleted, got %v", err)
+	}
+	if len(transport.requests["TransactWriteItems"]) != 2 || len(transport.requests["PutItem"]) != 1 || len(transport.requests["DeleteItem"]) != 0 {
+		t.Errorf("Expected both changes to go through TransactWriteItems, got %d transactions, %d puts and %d deletes",
+			len(transport.requests["TransactWriteItems"]), len(transport.requests["PutItem"]), len(transport.requests["DeleteItem"]))
+	}
+}
+
+func TestMetadataRepository_TransactThrottlingIsTyped(t *testing.T) {
+	transport := newTableTransport("prefix", "file_name")
+	repo := newTestRepository(transport)
+	transport.cancelKey, transport.cancelCode = "docs\x00a.txt", "ThrottlingError"
+
+	err := repo.Transact(context.Background(), []domain.ObjectMetadata{{Prefix: "docs", FileName: "a.txt"}}, nil)
+	if !stderrors.Is(err, errors.ErrMetadataThrottled) {
+		t.Errorf("Expected a transaction cancelled by throttling to match ErrMetadataThrottled, got %v", err)
+	}
+}
+
+func TestMetadataRepository_TransactLimits(t *testing.T) {
+	ctx := context.Background()
+	transport := newTableTransport("prefix", "file_name")
+	repo := newTestRepository(transport)
+
+	var deletes []domain.MetadataKey
+	for i := 0; i <= db.MaxTransactionItems; i++ {
+		deletes = append(deletes, domain.MetadataKey{Prefix: "docs", FileName: fmt.Sprintf("%d.txt", i)})
+	}
+	if err := repo.Transact(ctx, nil, deletes); !stderrors.Is(err, errors.ErrTransactionTooLarge) {
+		t.Errorf("Expected %d writes to be rejected, got %v", len(deletes), err)
+	}
+
+	// Each record is under DynamoDB's 400KB item limit, but together they exceed 4MB
+	var puts []domain.ObjectMetadata
+	for i := 0; i < 12; i++ {
+		puts = append(puts, domain.ObjectMetadata{Prefix: "docs", FileName: fmt.Sprintf("%d.txt", i), OriginalName: strings.Repeat("x", 380<<10)})
+	}
+	if err := repo.Transact(ctx, puts, nil); !stderrors.Is(err, errors.ErrTransactionTooLarge) {
+		t.Errorf("Expected a transaction over 4MB to be rejected, got %v", err)
+	}
+
+	same := domain.MetadataKey{Prefix: "docs", FileName: "a.txt"}
+	if err := repo.Transact(ctx, []domain.ObjectMetadata{{Prefix: "docs", FileName: "a.txt"}}, []domain.MetadataKey{same}); err == nil {
+		t.Error("Expected a record written twice in one transaction to be rejected")
+	}
+	if n := len(transport.requests["TransactWriteItems"]); n != 0 {
+		t.Errorf("Expected rejected transactions not to be sent, got %d requests", n)
+	}
+}
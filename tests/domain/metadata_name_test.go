@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/domain"
+)
+
+func TestValidMetadataName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"owner":         true,
+		"Cost-Center":   true,
+		"x_ref.v2":      true,
+		"it's~ok!#$%&*": true,
+		"":              false,
+		"two words":     false,
+		"owner:":        false,
+		"a/b":           false,
+		"line\nbreak":   false,
+		"tab\t":         false,
+		"café":          false,
+		`quoted"name`:   false,
+	} {
+		if got := domain.ValidMetadataName(name); got != valid {
+			t.Errorf("ValidMetadataName(%q) = %v, expected %v", name, got, valid)
+		}
+	}
+}
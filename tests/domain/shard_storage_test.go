@@ -0,0 +1,128 @@
+package domain
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/zzenonn/zstore/internal/domain"
+)
+
+const legacyShardJSON = `{"hash":"a1","storage_type":"s3","bucket_name":"primary","key":"docs/report.pdf/a1"}`
+
+func TestShardStorage_LegacyJSONReadsAsOneLocation(t *testing.T) {
+	var shard domain.ShardStorage
+	if err := json.Unmarshal([]byte(legacyShardJSON), &shard); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []domain.Location{{StorageType: "s3", BucketName: "primary", Key: "docs/report.pdf/a1"}}
+	if shard.Hash != "a1" || !reflect.DeepEqual(shard.Locations, expected) {
+		t.Errorf("Expected one location %v, got %+v", expected, shard)
+	}
+
+	// A single-location shard must serialize exactly as before so manifest signatures stay valid
+	encoded, err := json.Marshal(shard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != legacyShardJSON {
+		t.Errorf("Expected legacy encoding %s, got %s", legacyShardJSON, encoded)
+	}
+}
+
+func TestShardStorage_UnstoredShardHasNoLocations(t *testing.T) {
+	var shard domain.ShardStorage
+	if err := json.Unmarshal([]byte(`{"hash":"a1","storage_type":"","bucket_name":"","key":""}`), &shard); err != nil {
+		t.Fatal(err)
+	}
+	if len(shard.Locations) != 0 {
+		t.Errorf("Expected no locations, got %v", shard.Locations)
+	}
+	if shard.Primary() != (domain.Location{}) {
+		t.Errorf("Expected an empty primary location, got %v", shard.Primary())
+	}
+}
+
+func TestShardStorage_MultipleLocationsRoundTrip(t *testing.T) {
+	metadata := domain.ObjectMetadata{
+		Prefix:   "docs",
+		FileName: "report.pdf",
+		ShardHashes: []domain.ShardStorage{{
+			Hash: "a1",
+			Locations: []domain.Location{
+				{StorageType: "s3", BucketName: "primary", Key: "docs/report.pdf/a1"},
+				{StorageType: "gcs", BucketName: "backup", Key: "docs/report.pdf/a1"},
+			},
+		}},
+	}
+
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON domain.ObjectMetadata
+	if err := json.Unmarshal(encoded, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromJSON.ShardHashes, metadata.ShardHashes) {
+		t.Errorf("JSON round trip changed shards: %+v", fromJSON.ShardHashes)
+	}
+
+	item, err := attributevalue.MarshalMap(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromDynamo domain.ObjectMetadata
+	if err := attributevalue.UnmarshalMap(item, &fromDynamo); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromDynamo.ShardHashes, metadata.ShardHashes) {
+		t.Errorf("DynamoDB round trip changed shards: %+v", fromDynamo.ShardHashes)
+	}
+}
+
+func TestShardStorage_LegacyDynamoDBItemReadsAsOneLocation(t *testing.T) {
+	item := map[string]types.AttributeValue{
+		"prefix":    &types.AttributeValueMemberS{Value: "docs"},
+		"file_name": &types.AttributeValueMemberS{Value: "report.pdf"},
+		"shard_hashes": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			&types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"hash":         &types.AttributeValueMemberS{Value: "a1"},
+				"storage_type": &types.AttributeValueMemberS{Value: "s3"},
+				"bucket_name":  &types.AttributeValueMemberS{Value: "primary"},
+				"key":          &types.AttributeValueMemberS{Value: "docs/report.pdf/a1"},
+			}},
+		}},
+	}
+
+	var metadata domain.ObjectMetadata
+	if err := attributevalue.UnmarshalMap(item, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	expected := []domain.Location{{StorageType: "s3", BucketName: "primary", Key: "docs/report.pdf/a1"}}
+	if len(metadata.ShardHashes) != 1 || !reflect.DeepEqual(metadata.ShardHashes[0].Locations, expected) {
+		t.Errorf("Expected one location %v, got %+v", expected, metadata.ShardHashes)
+	}
+}
+
+func TestObjectMetadata_StoredAndRequiredShards(t *testing.T) {
+	stored := domain.ShardStorage{Hash: "a", Locations: []domain.Location{{BucketName: "b", Key: "k"}}}
+	metadata := domain.ObjectMetadata{
+		ShardHashes:  []domain.ShardStorage{stored, {Hash: "b"}, stored, stored},
+		ParityShards: 1,
+	}
+	if metadata.StoredShards() != 3 {
+		t.Errorf("Expected 3 stored shards, got %d", metadata.StoredShards())
+	}
+	// Records without a data shard count derive it from the layout
+	if metadata.RequiredShards() != 3 {
+		t.Errorf("Expected 3 required shards, got %d", metadata.RequiredShards())
+	}
+	metadata.DataShards = 2
+	if metadata.RequiredShards() != 2 {
+		t.Errorf("Expected the recorded data shard count, got %d", metadata.RequiredShards())
+	}
+}
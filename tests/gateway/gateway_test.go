@@ -0,0 +1,327 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/gateway"
+)
+
+// fakeObjectService serves objects from memory
+type fakeObjectService struct {
+	objects map[string][]byte
+	names   map[string]string                // Original file names recorded at upload
+	content map[string]domain.ObjectMetadata // Content type and custom metadata recorded at upload
+	streams int                              // Number of StreamFile calls
+}
+
+func (f *fakeObjectService) StatFile(ctx context.Context, key string) (domain.ObjectMetadata, error) {
+	data, ok := f.objects[key]
+	if !ok {
+		return domain.ObjectMetadata{}, errors.ErrMetadataNotFound
+	}
+	return domain.ObjectMetadata{
+		OriginalSize: int64(len(data)),
+		OriginalName: f.names[key],
+		ContentType:  f.content[key].ContentType,
+		UserMetadata: f.content[key].UserMetadata,
+	}, nil
+}
+
+func (f *fakeObjectService) DownloadRange(ctx context.Context, key string, offset, length int64, dest io.Writer, verifyIntegrity bool) error {
+	data := f.objects[key]
+	if offset < 0 || offset+length > int64(len(data)) {
+		return errors.ErrInvalidRange
+	}
+	_, err := dest.Write(data[offset : offset+length])
+	return err
+}
+
+func (f *fakeObjectService) StreamFile(ctx context.Context, key string, dest io.Writer, verifyIntegrity bool) error {
+	f.streams++
+	_, err := dest.Write(f.objects[key])
+	return err
+}
+
+func newTestHandler() (*gateway.Handler, []byte) {
+	data := []byte("0123456789abcdefghij")
+	service := &fakeObjectService{objects: map[string][]byte{"media/video.bin": data}}
+	return gateway.NewHandler(service), data
+}
+
+func TestGateway_FullObject(t *testing.T) {
+	handler, data := newTestHandler()
+
+	req := httptest.NewRequest(http.MethodGet, "/objects/media/video.bin", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), data) {
+		t.Errorf("Body mismatch: got %q", rec.Body.String())
+	}
+	if got := rec.Header().Get("Accept-Ranges"); got != "bytes" {
+		t.Errorf("Expected Accept-Ranges: bytes, got %q", got)
+	}
+}
+
+func TestGateway_FullObjectIsStreamed(t *testing.T) {
+	service := &fakeObjectService{objects: map[string][]byte{"media/video.bin": []byte("0123456789")}}
+	handler := gateway.NewHandler(service)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/objects/media/video.bin", nil))
+	if service.streams != 1 {
+		t.Fatalf("Expected a full GET to stream the object, got %d StreamFile calls", service.streams)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/objects/media/video.bin", nil)
+	req.Header.Set("Range", "bytes=2-4")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if service.streams != 1 {
+		t.Errorf("Expected a range request to be served by DownloadRange, got %d StreamFile calls", service.streams)
+	}
+}
+
+func TestGateway_SingleRange(t *testing.T) {
+	handler, data := newTestHandler()
+
+	testCases := []struct {
+		name         string
+		rangeHeader  string
+		start, end   int
+		contentRange string
+	}{
+		{"Bounded range", "bytes=2-5", 2, 5, "bytes 2-5/20"},
+		{"Open-ended range", "bytes=15-", 15, 19, "bytes 15-19/20"},
+		{"Suffix range", "bytes=-4", 16, 19, "bytes 16-19/20"},
+		{"Range past end is truncated", "bytes=18-100", 18, 19, "bytes 18-19/20"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/objects/media/video.bin", nil)
+			req.Header.Set("Range", tc.rangeHeader)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusPartialContent {
+				t.Fatalf("Expected status 206, got %d", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Range"); got != tc.contentRange {
+				t.Errorf("Expected Content-Range %q, got %q", tc.contentRange, got)
+			}
+			expected := data[tc.start : tc.end+1]
+			if !bytes.Equal(rec.Body.Bytes(), expected) {
+				t.Errorf("Expected body %q, got %q", expected, rec.Body.String())
+			}
+			if got := rec.Header().Get("Content-Length"); got != fmt.Sprint(len(expected)) {
+				t.Errorf("Expected Content-Length %d, got %s", len(expected), got)
+			}
+		})
+	}
+}
+
+func TestGateway_InvalidRange(t *testing.T) {
+	handler, _ := newTestHandler()
+
+	for _, rangeHeader := range []string{"bytes=20-", "bytes=5-2", "bytes=abc", "items=0-1", "bytes=0-1,4-5"} {
+		t.Run(rangeHeader, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/objects/media/video.bin", nil)
+			req.Header.Set("Range", rangeHeader)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusRequestedRangeNotSatisfiable {
+				t.Fatalf("Expected status 416, got %d", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Range"); got != "bytes */20" {
+				t.Errorf("Expected Content-Range bytes */20, got %q", got)
+			}
+		})
+	}
+}
+
+func TestGateway_SuffixRangeOfEmptyObject(t *testing.T) {
+	service := &fakeObjectService{objects: map[string][]byte{"media/empty.bin": {}}}
+	handler := gateway.NewHandler(service)
+
+	req := httptest.NewRequest(http.MethodGet, "/objects/media/empty.bin", nil)
+	req.Header.Set("Range", "bytes=-5")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("Expected status 416, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes */0" {
+		t.Errorf("Expected Content-Range bytes */0, got %q", got)
+	}
+}
+
+func TestGateway_NotFound(t *testing.T) {
+	handler, _ := newTestHandler()
+
+	req := httptest.NewRequest(http.MethodGet, "/objects/missing.bin", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestGateway_ContentTypeFromOriginalName(t *testing.T) {
+	service := &fakeObjectService{
+		objects: map[string][]byte{"docs/report": []byte("%PDF-1.7"), "media/video.bin": []byte("raw")},
+		names:   map[string]string{"docs/report": "report.pdf"},
+	}
+	handler := gateway.NewHandler(service)
+
+	req := httptest.NewRequest(http.MethodGet, "/objects/docs/report", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Content-Type"); got != "application/pdf" {
+		t.Errorf("Expected Content-Type application/pdf, got %q", got)
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `inline; filename=report.pdf` {
+		t.Errorf("Expected Content-Disposition with the original name, got %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/objects/media/video.bin", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Content-Type"); got != "application/octet-stream" {
+		t.Errorf("Expected octet-stream without a recorded name, got %q", got)
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != "" {
+		t.Errorf("Expected no Content-Disposition without a recorded name, got %q", got)
+	}
+}
+
+func TestGateway_StoredContentTypeAndUserMetadata(t *testing.T) {
+	service := &fakeObjectService{
+		objects: map[string][]byte{"site/index": []byte("<html></html>")},
+		names:   map[string]string{"site/index": "index.bin"},
+		content: map[string]domain.ObjectMetadata{"site/index": {
+			ContentType:  "text/html; charset=utf-8",
+			UserMetadata: map[string]string{"cache-policy": "public", "Owner": "web team", "bad name": "dropped"},
+		}},
+	}
+	handler := gateway.NewHandler(service)
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		req := httptest.NewRequest(method, "/objects/site/index", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		// The stored type wins over the one the original name implies
+		if got := rec.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+			t.Errorf("%s: expected the stored Content-Type, got %q", method, got)
+		}
+		if got := rec.Header().Get("X-Zstore-Meta-Cache-Policy"); got != "public" {
+			t.Errorf("%s: expected X-Zstore-Meta-Cache-Policy: public, got %q", method, got)
+		}
+		if got := rec.Header().Get("X-Zstore-Meta-Owner"); got != "web team" {
+			t.Errorf("%s: expected X-Zstore-Meta-Owner: web team, got %q", method, got)
+		}
+		for name := range rec.Header() {
+			if strings.Contains(name, " ") {
+				t.Errorf("%s: expected attributes with invalid header names to be dropped, got %q", method, name)
+			}
+		}
+	}
+}
+
+func TestGateway_IfNoneMatch(t *testing.T) {
+	handler, data := newTestHandler()
+
+	req := httptest.NewRequest(http.MethodGet, "/objects/media/video.bin", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	etag := rec.Header().Get("ETag")
+	if etag == "" || etag[0] != '"' {
+		t.Fatalf("Expected a quoted ETag, got %q", etag)
+	}
+
+	testCases := []struct {
+		name        string
+		ifNoneMatch string
+		status      int
+	}{
+		{"Matching tag", etag, http.StatusNotModified},
+		{"Weak matching tag in a list", `"other", W/` + etag, http.StatusNotModified},
+		{"Wildcard", "*", http.StatusNotModified},
+		{"Stale tag", `"stale"`, http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/objects/media/video.bin", nil)
+			req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("Expected status %d, got %d", tc.status, rec.Code)
+			}
+			if rec.Header().Get("ETag") != etag {
+				t.Errorf("Expected ETag %s, got %s", etag, rec.Header().Get("ETag"))
+			}
+			if tc.status == http.StatusNotModified && rec.Body.Len() != 0 {
+				t.Errorf("Expected an empty 304 body, got %q", rec.Body.String())
+			}
+			if tc.status == http.StatusOK && !bytes.Equal(rec.Body.Bytes(), data) {
+				t.Errorf("Body mismatch: got %q", rec.Body.String())
+			}
+		})
+	}
+}
+
+// limitedObjectService is a fakeObjectService whose single download slot can be taken
+type limitedObjectService struct {
+	*fakeObjectService
+	busy bool
+}
+
+func (l *limitedObjectService) AcquireDownloadSlot(ctx context.Context) (context.Context, func(), error) {
+	if l.busy {
+		return ctx, nil, errors.ErrTooManyDownloads
+	}
+	l.busy = true
+	return ctx, func() { l.busy = false }, nil
+}
+
+func TestGateway_RejectsDownloadsBeyondTheLimit(t *testing.T) {
+	service := &limitedObjectService{fakeObjectService: &fakeObjectService{objects: map[string][]byte{"media/video.bin": []byte("0123456789")}}}
+	handler := gateway.NewHandler(service)
+
+	service.busy = true
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/objects/media/video.bin", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("Expected 503 with Retry-After while the limit is reached, got %d", rec.Code)
+	}
+	if service.streams != 0 {
+		t.Errorf("Expected no download for a rejected request, got %d", service.streams)
+	}
+
+	service.busy = false
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/objects/media/video.bin", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "0123456789" {
+		t.Fatalf("Expected the object once a slot is free, got %d %q", rec.Code, rec.Body.String())
+	}
+	if service.busy {
+		t.Error("Expected the slot to be released after the response")
+	}
+}
@@ -0,0 +1,198 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/grpcapi"
+	"github.com/zzenonn/zstore/internal/grpcapi/zstorepb"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// memoryMetadataRepository keeps object metadata in memory
+type memoryMetadataRepository struct {
+	mu      sync.Mutex
+	records map[string]domain.ObjectMetadata
+}
+
+func (m *memoryMetadataRepository) CreateMetadata(ctx context.Context, metadata domain.ObjectMetadata) (domain.ObjectMetadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[metadata.Prefix+"/"+metadata.FileName] = metadata
+	return metadata, nil
+}
+
+func (m *memoryMetadataRepository) GetMetadata(ctx context.Context, prefix, fileName string) (domain.ObjectMetadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	metadata, ok := m.records[prefix+"/"+fileName]
+	if !ok {
+		return domain.ObjectMetadata{}, errors.ErrMetadataNotFound
+	}
+	return metadata, nil
+}
+
+func (m *memoryMetadataRepository) ListMetadataByPrefix(ctx context.Context, prefix string) ([]domain.ObjectMetadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var metadataList []domain.ObjectMetadata
+	for _, metadata := range m.records {
+		if metadata.Prefix == prefix {
+			metadataList = append(metadataList, metadata)
+		}
+	}
+	return metadataList, nil
+}
+
+func (m *memoryMetadataRepository) UpdateMetadata(ctx context.Context, metadata domain.ObjectMetadata) (domain.ObjectMetadata, error) {
+	return m.CreateMetadata(ctx, metadata)
+}
+
+func (m *memoryMetadataRepository) DeleteMetadata(ctx context.Context, prefix, fileName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, prefix+"/"+fileName)
+	return nil
+}
+
+// newTestClient serves a memory-backed FileService over an in-process connection
+func newTestClient(t *testing.T) zstorepb.ZstoreClient {
+	t.Helper()
+	placer, _ := objectstoretest.NewRoundRobinPlacer(t, 6)
+	fileService := service.NewFileService(placer, &memoryMetadataRepository{records: make(map[string]domain.ObjectMetadata)})
+	fileService.SetProgressOutput(io.Discard)
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	zstorepb.RegisterZstoreServer(server, grpcapi.NewServer(fileService, 4, 2))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return zstorepb.NewZstoreClient(conn)
+}
+
+// upload streams data to key in chunks of chunkSize bytes
+func upload(t *testing.T, client zstorepb.ZstoreClient, key string, data []byte, chunkSize int) *zstorepb.ObjectInfo {
+	t.Helper()
+	stream, err := client.Upload(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &zstorepb.UploadHeader{Key: key, Size: int64(len(data))}
+	if err := stream.Send(&zstorepb.UploadRequest{Payload: &zstorepb.UploadRequest_Header{Header: header}}); err != nil {
+		t.Fatal(err)
+	}
+	for offset := 0; offset < len(data); offset += chunkSize {
+		chunk := data[offset:min(offset+chunkSize, len(data))]
+		if err := stream.Send(&zstorepb.UploadRequest{Payload: &zstorepb.UploadRequest_Chunk{Chunk: chunk}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	return resp.GetObject()
+}
+
+// download reads an object's full content
+func download(client zstorepb.ZstoreClient, key string) ([]byte, error) {
+	stream, err := client.Download(context.Background(), &zstorepb.DownloadRequest{Key: key, VerifyIntegrity: true})
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return buf.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(resp.GetChunk())
+	}
+}
+
+func TestServer_UploadDownloadStatListDelete(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("streamed over grpc "), 4096)
+
+	info := upload(t, client, "docs/report.txt", data, 7000)
+	if info.GetKey() != "docs/report.txt" || info.GetSize() != int64(len(data)) {
+		t.Errorf("Unexpected upload response: %+v", info)
+	}
+	if info.GetDataShards() != 4 || info.GetParityShards() != 2 || info.GetStoredShards() != 6 {
+		t.Errorf("Expected the server's default 4+2 layout, got %+v", info)
+	}
+
+	got, err := download(client, "docs/report.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("Downloaded %d bytes that do not match the %d uploaded", len(got), len(data))
+	}
+
+	stat, err := client.Stat(ctx, &zstorepb.StatRequest{Key: "docs/report.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.GetEtag() == "" || stat.GetEtag() != info.GetEtag() || stat.GetCreatedAt() == nil {
+		t.Errorf("Expected Stat to match the upload, got %+v", stat)
+	}
+
+	upload(t, client, "docs/notes.txt", []byte("short"), 2)
+	list, err := client.List(ctx, &zstorepb.ListRequest{Prefix: "docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.GetObjects()) != 2 {
+		t.Errorf("Expected 2 objects under docs, got %+v", list.GetObjects())
+	}
+
+	if _, err := client.Delete(ctx, &zstorepb.DeleteRequest{Key: "docs/report.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Stat(ctx, &zstorepb.StatRequest{Key: "docs/report.txt"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound after delete, got %v", err)
+	}
+	if _, err := download(client, "docs/report.txt"); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected downloading a deleted object to fail with NotFound, got %v", err)
+	}
+}
+
+func TestServer_UploadRejectsMissingHeader(t *testing.T) {
+	client := newTestClient(t)
+
+	stream, err := client.Upload(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&zstorepb.UploadRequest{Payload: &zstorepb.UploadRequest_Chunk{Chunk: []byte("data")}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.CloseAndRecv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument without a header, got %v", err)
+	}
+}
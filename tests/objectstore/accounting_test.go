@@ -0,0 +1,191 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+)
+
+func TestAccounting_CountsRequestsAndBytesPerProvider(t *testing.T) {
+	accounting := objectstore.NewAccounting()
+	s3Repo := newRecordingS3Repository(&recordingTransport{})
+	s3Repo.SetAccounting(accounting)
+	ipfsRepo, _ := newTestIPFSRepository(t)
+	ipfsRepo.SetAccounting(accounting)
+	ctx := context.Background()
+	shard := bytes.Repeat([]byte("s"), 1000)
+
+	if _, err := s3Repo.Upload(ctx, "a", bytes.NewReader(shard), true); err != nil {
+		t.Fatal(err)
+	}
+	// A body of unknown size is counted as it is read
+	if _, err := s3Repo.Upload(ctx, "b", struct{ io.Reader }{bytes.NewReader(shard[:300])}, true); err != nil {
+		t.Fatal(err)
+	}
+	dest, err := os.CreateTemp(t.TempDir(), "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dest.Close()
+	if err := s3Repo.Download(ctx, "b", dest, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s3Repo.GetObjectSize(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s3Repo.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ipfsRepo.Upload(ctx, "c", bytes.NewReader(shard[:200]), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := ipfsRepo.Download(ctx, "bafymissing", dest, true); err == nil {
+		t.Fatal("Expected downloading an unknown CID to fail")
+	}
+
+	summary := accounting.Summary()
+	s3 := summary.Providers["s3"]
+	if s3.Requests != 5 || s3.BytesUploaded != 1300 || s3.BytesDownloaded != 300 {
+		t.Errorf("Unexpected S3 counts %+v", s3)
+	}
+	for kind, expected := range map[objectstore.RequestKind]int64{objectstore.RequestUpload: 2, objectstore.RequestDownload: 1, objectstore.RequestHead: 1, objectstore.RequestDelete: 1} {
+		if s3.ByKind[kind] != expected {
+			t.Errorf("Expected %d S3 %s requests, got %d", expected, kind, s3.ByKind[kind])
+		}
+	}
+	ipfs := summary.Providers["ipfs"]
+	if ipfs.Requests != 2 || ipfs.BytesUploaded != 200 || ipfs.BytesDownloaded != 0 {
+		t.Errorf("Unexpected IPFS counts %+v (the failed download is a request without bytes)", ipfs)
+	}
+	if summary.Requests != 7 || summary.BytesUploaded != 1500 || summary.BytesDownloaded != 300 || summary.ByKind[objectstore.RequestUpload] != 3 {
+		t.Errorf("Unexpected totals %+v", summary.TransferCounts)
+	}
+}
+
+func TestAccounting_CountsEachCallOfAPrefixDelete(t *testing.T) {
+	transport := newBatchDeleteTransport(2, "logs/a", "logs/b", "logs/c", "logs/d", "logs/e")
+	transport.failOnce["logs/c"] = "SlowDown"
+	repo := newBatchDeleteRepository(transport)
+	accounting := objectstore.NewAccounting()
+	repo.SetAccounting(accounting)
+
+	if err := repo.DeletePrefix(context.Background(), "logs/"); err != nil {
+		t.Fatal(err)
+	}
+	s3 := accounting.Summary().Providers["s3"]
+	// Three listing pages, three batches and the retry of the throttled key
+	if s3.ByKind[objectstore.RequestList] != 3 || int(s3.ByKind[objectstore.RequestList]) != transport.lists {
+		t.Errorf("Expected each of the %d listing pages counted, got %d", transport.lists, s3.ByKind[objectstore.RequestList])
+	}
+	if s3.ByKind[objectstore.RequestDelete] != 4 || int(s3.ByKind[objectstore.RequestDelete]) != transport.batches {
+		t.Errorf("Expected each of the %d DeleteObjects calls counted, got %d", transport.batches, s3.ByKind[objectstore.RequestDelete])
+	}
+}
+
+// multipartTransport answers the calls of a multipart upload and counts them
+type multipartTransport struct {
+	mu    sync.Mutex
+	calls int
+	parts int
+}
+
+func (t *multipartTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls++
+
+	query := req.URL.Query()
+	header := http.Header{"Content-Type": {"application/xml"}}
+	var body string
+	switch {
+	case req.Method == http.MethodPost && query.Has("uploads"):
+		body = "<InitiateMultipartUploadResult><Bucket>test-bucket</Bucket><Key>big</Key><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>"
+	case req.Method == http.MethodPut && query.Has("partNumber"):
+		io.Copy(io.Discard, req.Body)
+		t.parts++
+		header.Set("ETag", fmt.Sprintf(`"part-%s"`, query.Get("partNumber")))
+	case req.Method == http.MethodPost && query.Has("uploadId"):
+		body = "<CompleteMultipartUploadResult><Bucket>test-bucket</Bucket><Key>big</Key><ETag>\"done\"</ETag></CompleteMultipartUploadResult>"
+	default:
+		return &http.Response{StatusCode: http.StatusBadRequest, Header: header, Body: http.NoBody, Request: req}, nil
+	}
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func TestAccounting_CountsEachPartOfAMultipartUpload(t *testing.T) {
+	transport := &multipartTransport{}
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String("http://s3.test"),
+		UsePathStyle: true,
+		HTTPClient:   &http.Client{Transport: transport},
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil
+		}),
+	})
+	repo := objectstore.NewS3ObjectRepository(client, "test-bucket")
+	accounting := objectstore.NewAccounting()
+	repo.SetAccounting(accounting)
+
+	// Three parts of the default 5 MiB part size
+	data := bytes.Repeat([]byte("m"), 11<<20)
+	if _, err := repo.Upload(context.Background(), "big", bytes.NewReader(data), true); err != nil {
+		t.Fatal(err)
+	}
+	s3Counts := accounting.Summary().Providers["s3"]
+	if transport.parts != 3 {
+		t.Fatalf("Expected a 3-part upload, got %d parts", transport.parts)
+	}
+	// Create, three parts and complete
+	if s3Counts.ByKind[objectstore.RequestUpload] != 5 || int(s3Counts.Requests) != transport.calls {
+		t.Errorf("Expected each of the %d upload calls counted, got %+v", transport.calls, s3Counts)
+	}
+	if s3Counts.BytesUploaded != int64(len(data)) {
+		t.Errorf("Expected %d bytes uploaded, got %d", len(data), s3Counts.BytesUploaded)
+	}
+}
+
+func TestAccounting_AppliedByFactory(t *testing.T) {
+	accounting := objectstore.NewAccounting()
+	factory := objectstore.NewObjectRepositoryFactory(aws.Config{}, nil)
+	factory.SetAccounting(accounting)
+	repo, err := factory.CreateRepository(objectstore.BucketConfig{Name: "accounting-factory", Type: objectstoretest.MemoryType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Upload(context.Background(), "k", bytes.NewReader([]byte("counted")), true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ListKeys(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+	summary := accounting.Summary()
+	if summary.Requests != 2 || summary.BytesUploaded != 7 || summary.Providers["memory"].ByKind[objectstore.RequestList] != 1 {
+		t.Errorf("Expected the factory's repositories to be counted, got %+v", summary)
+	}
+}
+
+func TestAccounting_NilCountsNothing(t *testing.T) {
+	var accounting *objectstore.Accounting
+	accounting.Record("s3", objectstore.RequestUpload, 10)
+	if summary := accounting.Summary(); summary.Requests != 0 {
+		t.Errorf("Expected an empty summary, got %+v", summary)
+	}
+}
@@ -0,0 +1,231 @@
+package objectstore
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+// fakeDataLake implements the subset of the ADLS Gen2 DFS API used by the repository for one file system
+type fakeDataLake struct {
+	mu       sync.Mutex
+	files    map[string][]byte // Flushed content by path
+	pending  map[string][]byte // Appended but not flushed
+	requests []string          // "METHOD path?query" of every request
+	authOK   func(r *http.Request) bool
+}
+
+func newFakeDataLake() *fakeDataLake {
+	return &fakeDataLake{files: make(map[string][]byte), pending: make(map[string][]byte)}
+}
+
+func (d *fakeDataLake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests = append(d.requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+	if d.authOK != nil && !d.authOK(r) {
+		adlsError(w, http.StatusForbidden, "AuthenticationFailed")
+		return
+	}
+
+	query := r.URL.Query()
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/fs"), "/")
+	switch {
+	case r.Method == http.MethodPut && query.Get("resource") == "file":
+		d.files[path] = nil
+		d.pending[path] = nil
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPatch && query.Get("action") == "append":
+		body, _ := io.ReadAll(r.Body)
+		if position, _ := strconv.Atoi(query.Get("position")); position != len(d.pending[path]) {
+			adlsError(w, http.StatusBadRequest, "InvalidFlushPosition")
+			return
+		}
+		d.pending[path] = append(d.pending[path], body...)
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPatch && query.Get("action") == "flush":
+		d.files[path] = d.pending[path]
+		delete(d.pending, path)
+	case r.Method == http.MethodGet && query.Get("resource") == "filesystem":
+		directory := query.Get("directory")
+		var list struct {
+			Paths []map[string]string `json:"paths"`
+		}
+		for name := range d.files {
+			if directory == "" || strings.HasPrefix(name, directory+"/") {
+				list.Paths = append(list.Paths, map[string]string{"name": name, "contentLength": strconv.Itoa(len(d.files[name]))})
+			}
+		}
+		if directory != "" && len(list.Paths) == 0 {
+			adlsError(w, http.StatusNotFound, "PathNotFound")
+			return
+		}
+		json.NewEncoder(w).Encode(list)
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		data, ok := d.files[path]
+		if !ok {
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			adlsError(w, http.StatusNotFound, "PathNotFound")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+	case r.Method == http.MethodDelete:
+		var matched []string
+		for name := range d.files {
+			if name == path || (query.Get("recursive") == "true" && strings.HasPrefix(name, path+"/")) {
+				matched = append(matched, name)
+			}
+		}
+		if len(matched) == 0 {
+			adlsError(w, http.StatusNotFound, "PathNotFound")
+			return
+		}
+		// Delete in batches of two, as the service does without a hierarchical namespace
+		slices.Sort(matched)
+		for _, name := range matched[:min(2, len(matched))] {
+			delete(d.files, name)
+		}
+		if len(matched) > 2 {
+			w.Header().Set("x-ms-continuation", "next")
+		}
+	default:
+		adlsError(w, http.StatusBadRequest, "UnsupportedRequest")
+	}
+}
+
+func adlsError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": code, "message": code}})
+}
+
+func newADLSRepository(t *testing.T, bucket string, credential objectstore.ADLSCredential) (*objectstore.ADLSObjectRepository, *fakeDataLake) {
+	t.Helper()
+	lake := newFakeDataLake()
+	server := httptest.NewServer(lake)
+	t.Cleanup(server.Close)
+	repo, err := objectstore.NewADLSObjectRepository(server.Client(), bucket, server.URL, credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.SetProgressOutput(io.Discard)
+	return repo, lake
+}
+
+func TestADLSObjectRepository_RoundTrip(t *testing.T) {
+	repo, lake := newADLSRepository(t, "account/fs/zstore", objectstore.ADLSCredential{AccountKey: "c2VjcmV0LWtleQ=="})
+	lake.authOK = func(r *http.Request) bool {
+		return strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey account:") && r.Header.Get("x-ms-version") != ""
+	}
+	ctx := context.Background()
+
+	data := strings.Repeat("shard bytes ", 1000)
+	path, err := repo.Upload(ctx, "docs/report.txt/abc123", strings.NewReader(data), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The service keeps everything after the first slash as the key
+	if key := strings.SplitN(path, "/", 2)[1]; key != "docs/report.txt/abc123" {
+		t.Errorf("Expected the returned path to end in the key, got %s", path)
+	}
+	if _, ok := lake.files["zstore/docs/report.txt/abc123"]; !ok {
+		t.Errorf("Expected the file under the base path, got %v", lake.files)
+	}
+
+	tempFile, err := os.CreateTemp(t.TempDir(), "adls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempFile.Close()
+	if err := repo.Download(ctx, "docs/report.txt/abc123", tempFile, true); err != nil {
+		t.Fatal(err)
+	}
+	if downloaded, _ := os.ReadFile(tempFile.Name()); string(downloaded) != data {
+		t.Error("Downloaded data does not match the upload")
+	}
+	if size, err := repo.GetObjectSize(ctx, "docs/report.txt/abc123"); err != nil || size != int64(len(data)) {
+		t.Errorf("Expected size %d, got %d (%v)", len(data), size, err)
+	}
+	if _, err := repo.GetObjectSize(ctx, "docs/missing"); !stderrors.Is(err, errors.ErrObjectNotFound) {
+		t.Errorf("Expected ErrObjectNotFound, got %v", err)
+	}
+	if err := repo.Delete(ctx, "docs/report.txt/abc123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, "docs/report.txt/abc123"); err != nil {
+		t.Errorf("Expected deleting a missing file to succeed, got %v", err)
+	}
+	if repo.GetStorageType() != "adls" || repo.GetBucketName() != "account/fs/zstore" {
+		t.Errorf("Unexpected storage type %s or bucket %s", repo.GetStorageType(), repo.GetBucketName())
+	}
+}
+
+func TestADLSObjectRepository_DeletePrefixIsADirectoryDelete(t *testing.T) {
+	repo, lake := newADLSRepository(t, "account/fs", objectstore.ADLSCredential{SASToken: "sv=2022&sig=abc"})
+	lake.authOK = func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "" && r.URL.Query().Get("sig") == "abc"
+	}
+	ctx := context.Background()
+	for _, key := range []string{"big/0", "big/1", "big/2", "big/3", "big/4", "bigger/0", "other/0"} {
+		if _, err := repo.Upload(ctx, key, strings.NewReader("x"), true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := repo.ListKeys(ctx, "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(keys)
+	if expected := []string{"big/0", "big/1", "big/2", "big/3", "big/4", "bigger/0"}; !slices.Equal(keys, expected) {
+		t.Errorf("Expected string-prefix listing %v, got %v", expected, keys)
+	}
+
+	lake.requests = nil
+	if err := repo.DeletePrefix(ctx, "big"); err != nil {
+		t.Fatal(err)
+	}
+	for _, request := range lake.requests {
+		if !strings.HasPrefix(request, "DELETE /fs/big?") || !strings.Contains(request, "recursive=true") {
+			t.Errorf("Expected only recursive deletes of the directory, got %s", request)
+		}
+	}
+	if len(lake.requests) != 3 {
+		t.Errorf("Expected the continuation to be followed over 3 requests, got %d", len(lake.requests))
+	}
+	if _, ok := lake.files["bigger/0"]; !ok || len(lake.files) != 2 {
+		t.Errorf("Expected only big/ to be removed, left %v", lake.files)
+	}
+	if err := repo.DeletePrefix(ctx, "big"); err != nil {
+		t.Errorf("Expected deleting a missing directory to succeed, got %v", err)
+	}
+}
+
+func TestParseBucketConfig_ADLS(t *testing.T) {
+	config, err := objectstore.ParseBucketConfig("adls://account/fs/path/to/data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Type != objectstore.ADLSType || config.Name != "account/fs/path/to/data" {
+		t.Errorf("Unexpected bucket config %+v", config)
+	}
+	if _, err := objectstore.NewADLSObjectRepository(http.DefaultClient, "account", "", objectstore.ADLSCredential{AccountKey: "a2V5"}); err == nil {
+		t.Error("Expected a bucket without a file system to be rejected")
+	}
+}
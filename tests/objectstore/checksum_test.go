@@ -0,0 +1,44 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+func TestS3ObjectRepository_ChecksumAlgorithmSetOnUpload(t *testing.T) {
+	for _, algorithm := range []objectstore.ChecksumAlgorithm{objectstore.ChecksumCRC32C, objectstore.ChecksumSHA256} {
+		transport := &recordingTransport{}
+		repo := newRecordingS3Repository(transport)
+		repo.SetChecksumAlgorithm(algorithm)
+
+		if _, err := repo.Upload(context.Background(), "docs/a/0", bytes.NewReader([]byte("checked shard")), true); err != nil {
+			t.Fatal(err)
+		}
+
+		puts := transport.requestsFor("PUT")
+		if len(puts) != 1 {
+			t.Fatalf("Expected one PUT, got %d", len(puts))
+		}
+		if got := puts[0].Header.Get("X-Amz-Sdk-Checksum-Algorithm"); got != string(algorithm) {
+			t.Errorf("Expected checksum algorithm %s, got %q", algorithm, got)
+		}
+	}
+}
+
+func TestParseChecksumAlgorithm(t *testing.T) {
+	for value, expected := range map[string]objectstore.ChecksumAlgorithm{
+		"crc32c": objectstore.ChecksumCRC32C,
+		"SHA256": objectstore.ChecksumSHA256,
+		"":       "",
+	} {
+		if algorithm, err := objectstore.ParseChecksumAlgorithm(value); err != nil || algorithm != expected {
+			t.Errorf("ParseChecksumAlgorithm(%q) = %q, %v; expected %q", value, algorithm, err, expected)
+		}
+	}
+	if _, err := objectstore.ParseChecksumAlgorithm("md5"); err == nil {
+		t.Error("Expected an unknown algorithm to be rejected")
+	}
+}
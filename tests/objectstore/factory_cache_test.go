@@ -0,0 +1,51 @@
+package objectstore
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+func TestObjectRepositoryFactory_ReusesRepositories(t *testing.T) {
+	factory := objectstore.NewObjectRepositoryFactory(aws.Config{Region: "us-east-1"}, nil)
+	config := objectstore.BucketConfig{Name: "bucket", Type: objectstore.S3Type, Region: "us-east-1"}
+
+	first, err := factory.CreateRepository(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent callers all get the cached instance, and with it the same S3 client
+	var wg sync.WaitGroup
+	repos := make([]objectstore.ObjectRepository, 16)
+	for i := range repos {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			repos[i], _ = factory.CreateRepository(config)
+		}()
+	}
+	wg.Wait()
+	for i, repo := range repos {
+		if repo != first {
+			t.Errorf("Call %d returned a new repository instead of the cached one", i)
+		}
+	}
+
+	other, err := factory.CreateRepository(objectstore.BucketConfig{Name: "bucket", Type: objectstore.S3Type, Region: "eu-west-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == first {
+		t.Error("Expected a separate repository for another region")
+	}
+	ipfs, err := factory.CreateRepository(objectstore.BucketConfig{Name: "bucket", Type: objectstore.IPFSType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ipfs == first || ipfs.GetStorageType() != "ipfs" {
+		t.Error("Expected a separate repository for another type")
+	}
+}
@@ -0,0 +1,152 @@
+package objectstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+	"google.golang.org/api/option"
+)
+
+// fakeGCSServer serves paged object listings and deletes from memory
+// Listings return pageSize objects per page, so iteration has to follow page tokens
+// and end on iterator.Done after the last page.
+type fakeGCSServer struct {
+	mu        sync.Mutex
+	objects   map[string]bool
+	pageSize  int
+	failPage  int // Listing request (1-based) that fails; 0 never fails
+	pages     int // Listing requests served
+	goneOnDel map[string]bool
+	deleted   []string
+}
+
+func (f *fakeGCSServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	const objectsPath = "/storage/v1/b/bucket/o"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == objectsPath:
+		var names []string
+		for name := range f.objects {
+			if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		// Like GCS, the page token marks the last name returned, so deletes between
+		// pages do not shift the listing
+		f.pages++
+		if f.pages == f.failPage {
+			http.Error(w, `{"error":{"code":403,"message":"listing denied"}}`, http.StatusForbidden)
+			return
+		}
+		token := r.URL.Query().Get("pageToken")
+		start := sort.SearchStrings(names, token)
+		if token != "" && start < len(names) && names[start] == token {
+			start++
+		}
+
+		end := min(start+f.pageSize, len(names))
+		response := map[string]any{"kind": "storage#objects"}
+		var items []map[string]string
+		for _, name := range names[start:end] {
+			items = append(items, map[string]string{"name": name, "bucket": "bucket"})
+		}
+		response["items"] = items
+		if end < len(names) {
+			response["nextPageToken"] = names[end-1]
+		}
+		json.NewEncoder(w).Encode(response)
+
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, objectsPath+"/"):
+		name := strings.TrimPrefix(r.URL.Path, objectsPath+"/")
+		if f.goneOnDel[name] {
+			http.Error(w, `{"error":{"code":404,"message":"not found"}}`, http.StatusNotFound)
+			return
+		}
+		delete(f.objects, name)
+		f.deleted = append(f.deleted, name)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func newFakeGCSRepository(t *testing.T, fake *fakeGCSServer) objectstore.GCSObjectRepository {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := storage.NewClient(context.Background(),
+		option.WithEndpoint(server.URL+"/storage/v1/"),
+		option.WithoutAuthentication(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return objectstore.NewGCSObjectRepository(client, "bucket")
+}
+
+func newFakeGCSServer(pageSize int, names ...string) *fakeGCSServer {
+	fake := &fakeGCSServer{objects: make(map[string]bool), pageSize: pageSize, goneOnDel: make(map[string]bool)}
+	for _, name := range names {
+		fake.objects[name] = true
+	}
+	return fake
+}
+
+func TestGCSDeletePrefix_DeletesEveryPageAndTerminates(t *testing.T) {
+	fake := newFakeGCSServer(2, "docs/a", "docs/b", "docs/c", "docs/d", "docs/e", "other/f")
+	repo := newFakeGCSRepository(t, fake)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := repo.DeletePrefix(ctx, "docs/"); err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+
+	sort.Strings(fake.deleted)
+	if strings.Join(fake.deleted, ",") != "docs/a,docs/b,docs/c,docs/d,docs/e" {
+		t.Errorf("Expected every docs/ object deleted, got %v", fake.deleted)
+	}
+	if !fake.objects["other/f"] || len(fake.objects) != 1 {
+		t.Errorf("Expected only other/f to remain, got %v", fake.objects)
+	}
+}
+
+func TestGCSDeletePrefix_AlreadyDeletedObjectIsNotAnError(t *testing.T) {
+	fake := newFakeGCSServer(10, "docs/a", "docs/b")
+	fake.goneOnDel["docs/a"] = true
+	repo := newFakeGCSRepository(t, fake)
+
+	if err := repo.DeletePrefix(context.Background(), "docs/"); err != nil {
+		t.Errorf("Expected a concurrently deleted object to be ignored, got %v", err)
+	}
+}
+
+func TestGCSDeletePrefix_ListingErrorIsReported(t *testing.T) {
+	fake := newFakeGCSServer(2, "docs/a", "docs/b", "docs/c")
+	fake.failPage = 2
+	repo := newFakeGCSRepository(t, fake)
+
+	err := repo.DeletePrefix(context.Background(), "docs/")
+	if err == nil || !strings.Contains(err.Error(), "failed to list objects") {
+		t.Fatalf("Expected a listing error, got %v", err)
+	}
+	if len(fake.deleted) != 2 {
+		t.Errorf("Expected objects from the first page to be deleted, got %v", fake.deleted)
+	}
+}
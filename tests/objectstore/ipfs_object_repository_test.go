@@ -0,0 +1,150 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+// fakeIPFSNode implements the subset of the IPFS RPC API used by the repository
+type fakeIPFSNode struct {
+	mu     sync.Mutex
+	blocks map[string][]byte
+	pins   map[string]bool
+}
+
+func newFakeIPFSNode() *fakeIPFSNode {
+	return &fakeIPFSNode{blocks: make(map[string][]byte), pins: make(map[string]bool)}
+}
+
+func (n *fakeIPFSNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	arg := r.URL.Query().Get("arg")
+	switch strings.TrimPrefix(r.URL.Path, "/api/v0/") {
+	case "add":
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			rpcError(w, err.Error())
+			return
+		}
+		data, _ := io.ReadAll(file)
+		sum := sha256.Sum256(data)
+		cid := "bafy" + hex.EncodeToString(sum[:8])
+		n.blocks[cid] = data
+		n.pins[cid] = r.URL.Query().Get("pin") == "true"
+		json.NewEncoder(w).Encode(map[string]string{"Name": "shard", "Hash": cid, "Size": fmt.Sprint(len(data))})
+	case "cat":
+		data, ok := n.blocks[arg]
+		if !ok {
+			rpcError(w, "block not found")
+			return
+		}
+		w.Write(data)
+	case "pin/ls", "pin/rm":
+		if !n.pins[arg] {
+			rpcError(w, fmt.Sprintf("path '%s' is not pinned", arg))
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "rm") {
+			delete(n.pins, arg)
+		}
+		fmt.Fprint(w, "{}")
+	case "files/stat":
+		data := n.blocks[strings.TrimPrefix(arg, "/ipfs/")]
+		json.NewEncoder(w).Encode(map[string]any{"Hash": arg, "Size": len(data)})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func rpcError(w http.ResponseWriter, message string) {
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]string{"Message": message})
+}
+
+func newTestIPFSRepository(t *testing.T) (*objectstore.IPFSObjectRepository, *fakeIPFSNode) {
+	node := newFakeIPFSNode()
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+
+	repo := objectstore.NewIPFSObjectRepository(server.Client(), strings.TrimPrefix(server.URL, "http://"))
+	return &repo, node
+}
+
+func TestIPFSObjectRepository_RoundTrip(t *testing.T) {
+	repo, node := newTestIPFSRepository(t)
+	ctx := context.Background()
+	data := []byte("content-addressed shard data")
+
+	path, err := repo.Upload(ctx, "docs/report.pdf/abc123", bytes.NewReader(data), true)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	cid := strings.SplitN(path, "/", 2)[1]
+	if !node.pins[cid] {
+		t.Errorf("Expected CID %s to be pinned", cid)
+	}
+
+	// Uploading the same content again yields the same key
+	again, err := repo.Upload(ctx, "other/key/def456", bytes.NewReader(data), true)
+	if err != nil {
+		t.Fatalf("Second upload failed: %v", err)
+	}
+	if again != path {
+		t.Errorf("Expected identical content to dedupe to %s, got %s", path, again)
+	}
+
+	size, err := repo.GetObjectSize(ctx, cid)
+	if err != nil || size != int64(len(data)) {
+		t.Errorf("GetObjectSize = %d, %v; expected %d", size, err, len(data))
+	}
+
+	tempFile, err := os.CreateTemp(t.TempDir(), "ipfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempFile.Close()
+	if err := repo.Download(ctx, cid, tempFile, true); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	downloaded, _ := os.ReadFile(tempFile.Name())
+	if !bytes.Equal(downloaded, data) {
+		t.Errorf("Downloaded %q, expected %q", downloaded, data)
+	}
+
+	if err := repo.Delete(ctx, cid); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := repo.GetObjectSize(ctx, cid); !stderrors.Is(err, errors.ErrObjectNotFound) {
+		t.Errorf("Expected ErrObjectNotFound after unpin, got %v", err)
+	}
+	if err := repo.Delete(ctx, cid); err != nil {
+		t.Errorf("Deleting an unpinned CID should succeed, got %v", err)
+	}
+}
+
+func TestParseBucketConfig_IPFS(t *testing.T) {
+	config, err := objectstore.ParseBucketConfig("ipfs://127.0.0.1:5001")
+	if err != nil {
+		t.Fatalf("ParseBucketConfig failed: %v", err)
+	}
+	if config.Type != objectstore.IPFSType || config.Name != "127.0.0.1:5001" {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+}
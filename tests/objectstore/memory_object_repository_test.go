@@ -0,0 +1,97 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+)
+
+func TestMemoryObjectRepository_ImplementsRepository(t *testing.T) {
+	var repo objectstore.ObjectRepository = objectstoretest.NewMemoryObjectRepository("bucket-a")
+	ctx := context.Background()
+
+	path, err := repo.Upload(ctx, "docs/report.pdf/abc", bytes.NewReader([]byte("shard data")), true)
+	if err != nil || path != "bucket-a/docs/report.pdf/abc" {
+		t.Fatalf("Expected bucket/key from Upload, got %q, %v", path, err)
+	}
+	for _, key := range []string{"docs/report.pdf/def", "docs/report.pdf2/abc", "other/x"} {
+		if _, err := repo.Upload(ctx, key, bytes.NewReader([]byte("x")), true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tempFile, err := os.CreateTemp(t.TempDir(), "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempFile.Close()
+	if err := repo.Download(ctx, "docs/report.pdf/abc", tempFile, true); err != nil {
+		t.Fatal(err)
+	}
+	if downloaded, _ := os.ReadFile(tempFile.Name()); string(downloaded) != "shard data" {
+		t.Errorf("Downloaded %q", downloaded)
+	}
+	if err := repo.Download(ctx, "docs/missing", tempFile, true); !stderrors.Is(err, errors.ErrObjectNotFound) {
+		t.Errorf("Expected ErrObjectNotFound for a missing object, got %v", err)
+	}
+	if size, err := repo.GetObjectSize(ctx, "docs/report.pdf/abc"); err != nil || size != 10 {
+		t.Errorf("Expected size 10, got %d, %v", size, err)
+	}
+
+	if err := repo.DeletePrefix(ctx, "docs/report.pdf/"); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := repo.ListKeys(ctx, "")
+	if err != nil || !slices.Equal(keys, []string{"docs/report.pdf2/abc", "other/x"}) {
+		t.Errorf("Expected only keys outside the prefix to remain, got %v, %v", keys, err)
+	}
+	if err := repo.Delete(ctx, "docs/report.pdf/abc"); err != nil {
+		t.Errorf("Expected deleting a missing object to succeed, got %v", err)
+	}
+	if repo.GetStorageType() != "memory" || repo.GetBucketName() != "bucket-a" {
+		t.Errorf("Unexpected storage type %s or bucket %s", repo.GetStorageType(), repo.GetBucketName())
+	}
+}
+
+func TestMemoryObjectRepository_FailWith(t *testing.T) {
+	repo := objectstoretest.NewMemoryObjectRepository("bucket-a")
+	failure := stderrors.New("bucket unavailable")
+	repo.FailWith(failure)
+	if _, err := repo.Upload(context.Background(), "k", bytes.NewReader(nil), true); !stderrors.Is(err, failure) {
+		t.Errorf("Expected the injected failure, got %v", err)
+	}
+	repo.FailWith(nil)
+	if _, err := repo.Upload(context.Background(), "k", bytes.NewReader(nil), true); err != nil || repo.Len() != 1 {
+		t.Errorf("Expected uploads to work again, got %v", err)
+	}
+}
+
+func TestMemoryProvider_CreatedThroughFactory(t *testing.T) {
+	config, err := objectstore.ParseBucketConfig("memory://factory-bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Type != objectstoretest.MemoryType || config.Name != "factory-bucket" {
+		t.Fatalf("Unexpected bucket config %+v", config)
+	}
+
+	repo, err := objectstore.NewObjectRepositoryFactory(aws.Config{}, nil).CreateRepository(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Upload(context.Background(), "k", bytes.NewReader([]byte("v")), true); err != nil {
+		t.Fatal(err)
+	}
+	// Factories share the bucket's contents by name
+	if data, ok := objectstoretest.SharedMemoryRepository("factory-bucket").Object("k"); !ok || string(data) != "v" {
+		t.Errorf("Expected the upload to be visible through the shared repository, got %q", data)
+	}
+}
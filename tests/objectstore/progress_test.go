@@ -0,0 +1,58 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+func TestProgressOutput_CustomWriter(t *testing.T) {
+	repo, _ := newTestIPFSRepository(t)
+	var progress bytes.Buffer
+	repo.SetProgressOutput(&progress)
+
+	if _, err := repo.Upload(context.Background(), "docs/a.txt/abc", strings.NewReader("some shard data"), false); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if !strings.Contains(progress.String(), "uploading") {
+		t.Errorf("Expected progress bar on custom writer, got %q", progress.String())
+	}
+}
+
+func TestProgressOutput_QuietWritesNothing(t *testing.T) {
+	repo, _ := newTestIPFSRepository(t)
+	var progress bytes.Buffer
+	repo.SetProgressOutput(&progress)
+
+	if _, err := repo.Upload(context.Background(), "docs/a.txt/abc", strings.NewReader("some shard data"), true); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if progress.Len() != 0 {
+		t.Errorf("Expected no progress output in quiet mode, got %q", progress.String())
+	}
+
+	// Discarding output works for non-quiet calls too
+	repo.SetProgressOutput(io.Discard)
+	if _, err := repo.Upload(context.Background(), "docs/b.txt/def", strings.NewReader("more data"), false); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+}
+
+func TestProgressOutput_SizeHintGivesNonSeekableUploadsATotal(t *testing.T) {
+	repo, _ := newTestIPFSRepository(t)
+	var progress bytes.Buffer
+	repo.SetProgressOutput(&progress)
+
+	// Hide the Seek method of the source, as a pipe would
+	reader := struct{ io.Reader }{strings.NewReader("some shard data")}
+	if _, err := repo.Upload(context.Background(), "docs/a.txt/abc", objectstore.WithSizeHint(reader, 15), false); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if !strings.Contains(progress.String(), "100%") {
+		t.Errorf("Expected a progress bar with a total, got %q", progress.String())
+	}
+}
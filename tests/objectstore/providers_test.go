@@ -0,0 +1,95 @@
+package objectstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+func TestProviders_BuiltInsAreRegistered(t *testing.T) {
+	schemes := make(map[objectstore.RepositoryType]string)
+	for _, provider := range objectstore.Providers() {
+		schemes[provider.Type] = provider.Scheme
+	}
+	expected := map[objectstore.RepositoryType]string{objectstore.S3Type: "s3", objectstore.GCSType: "gs", objectstore.IPFSType: "ipfs"}
+	for repoType, scheme := range expected {
+		if schemes[repoType] != scheme {
+			t.Errorf("Expected provider %s with scheme %s, got %q", repoType, scheme, schemes[repoType])
+		}
+	}
+}
+
+func TestProviders_RegisteredProviderIsUsedByFactory(t *testing.T) {
+	created := 0
+	objectstore.RegisterProvider(objectstore.Provider{
+		Type:        "test-provider",
+		Scheme:      "testp",
+		Description: "Test provider",
+		Create: func(f *objectstore.ObjectRepositoryFactory, config objectstore.BucketConfig) (objectstore.ObjectRepository, error) {
+			created++
+			repo := objectstore.NewIPFSObjectRepository(nil, config.Name)
+			return &repo, nil
+		},
+	})
+
+	config, err := objectstore.ParseBucketConfig("testp://bucket")
+	if err != nil {
+		t.Fatalf("Expected the registered scheme to parse, got %v", err)
+	}
+	if config.Type != "test-provider" || config.Name != "bucket" {
+		t.Fatalf("Unexpected bucket config %+v", config)
+	}
+
+	factory := objectstore.NewObjectRepositoryFactory(aws.Config{}, nil)
+	if _, err := factory.CreateRepository(config); err != nil {
+		t.Fatal(err)
+	}
+	if created != 1 {
+		t.Errorf("Expected the provider to create the repository, got %d calls", created)
+	}
+}
+
+func TestProviderStatuses(t *testing.T) {
+	ctx := context.Background()
+	statusOf := func(factory *objectstore.ObjectRepositoryFactory, repoType objectstore.RepositoryType) objectstore.ProviderStatus {
+		for _, status := range factory.ProviderStatuses(ctx) {
+			if status.Type == repoType {
+				return status
+			}
+		}
+		t.Fatalf("No status for %s", repoType)
+		return objectstore.ProviderStatus{}
+	}
+
+	unconfigured := objectstore.NewObjectRepositoryFactory(aws.Config{}, nil)
+	if status := statusOf(unconfigured, objectstore.S3Type); status.Ready || status.Detail == "" {
+		t.Errorf("Expected S3 without credentials not to be ready, got %+v", status)
+	}
+	if status := statusOf(unconfigured, objectstore.GCSType); status.Ready {
+		t.Errorf("Expected GCS without a client not to be ready, got %+v", status)
+	}
+	if status := statusOf(unconfigured, objectstore.IPFSType); !status.Ready {
+		t.Errorf("Expected IPFS to need no credentials, got %+v", status)
+	}
+
+	credentialed := objectstore.NewObjectRepositoryFactory(aws.Config{
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil
+		}),
+	}, nil)
+	if status := statusOf(credentialed, objectstore.S3Type); !status.Ready {
+		t.Errorf("Expected S3 with credentials to be ready, got %+v", status)
+	}
+}
+
+func TestProviders_UnknownTypeIsRejected(t *testing.T) {
+	factory := objectstore.NewObjectRepositoryFactory(aws.Config{}, nil)
+	if _, err := factory.CreateRepository(objectstore.BucketConfig{Name: "bucket", Type: "azure"}); err == nil {
+		t.Error("Expected an unregistered provider type to be rejected")
+	}
+	if _, err := objectstore.ParseBucketConfig("azure://bucket"); err == nil {
+		t.Error("Expected an unregistered scheme to be rejected")
+	}
+}
@@ -0,0 +1,60 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+func TestS3ObjectRepository_RetentionSetsObjectLockFields(t *testing.T) {
+	transport := &recordingTransport{}
+	repo := newRecordingS3Repository(transport)
+	retainUntil := time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo.SetRetention(objectstore.Retention{Mode: objectstore.RetentionCompliance, RetainUntil: retainUntil})
+
+	if _, err := repo.Upload(context.Background(), "docs/a/0", bytes.NewReader([]byte("locked shard")), true); err != nil {
+		t.Fatal(err)
+	}
+
+	puts := transport.requestsFor("PUT")
+	if len(puts) != 1 {
+		t.Fatalf("Expected one PUT, got %d", len(puts))
+	}
+	if got := puts[0].Header.Get("X-Amz-Object-Lock-Mode"); got != "COMPLIANCE" {
+		t.Errorf("Expected lock mode COMPLIANCE, got %q", got)
+	}
+	if got := puts[0].Header.Get("X-Amz-Object-Lock-Retain-Until-Date"); got != "2031-01-01T00:00:00Z" {
+		t.Errorf("Expected retain-until 2031-01-01T00:00:00Z, got %q", got)
+	}
+}
+
+func TestS3ObjectRepository_NoObjectLockByDefault(t *testing.T) {
+	transport := &recordingTransport{}
+	repo := newRecordingS3Repository(transport)
+
+	if _, err := repo.Upload(context.Background(), "docs/a/0", bytes.NewReader([]byte("shard")), true); err != nil {
+		t.Fatal(err)
+	}
+	for _, header := range []string{"X-Amz-Object-Lock-Mode", "X-Amz-Object-Lock-Retain-Until-Date"} {
+		if got := transport.requestsFor("PUT")[0].Header.Get(header); got != "" {
+			t.Errorf("Expected no %s header, got %q", header, got)
+		}
+	}
+}
+
+func TestParseRetentionMode(t *testing.T) {
+	for value, expected := range map[string]objectstore.RetentionMode{
+		"governance": objectstore.RetentionGovernance,
+		"COMPLIANCE": objectstore.RetentionCompliance,
+	} {
+		if mode, err := objectstore.ParseRetentionMode(value); err != nil || mode != expected {
+			t.Errorf("ParseRetentionMode(%q) = %q, %v; expected %q", value, mode, err, expected)
+		}
+	}
+	if _, err := objectstore.ParseRetentionMode("legal-hold"); err == nil {
+		t.Error("Expected an unknown mode to be rejected")
+	}
+}
@@ -0,0 +1,156 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+// recordingTransport answers S3 requests from memory and records request headers
+type recordingTransport struct {
+	mu       sync.Mutex
+	body     []byte
+	requests []*http.Request
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests = append(t.requests, req)
+
+	header := http.Header{}
+	status := http.StatusOK
+	var body []byte
+	switch req.Method {
+	case http.MethodPut:
+		t.body, _ = io.ReadAll(req.Body)
+		header.Set("ETag", `"etag"`)
+	case http.MethodHead:
+		header.Set("Content-Length", fmt.Sprint(len(t.body)))
+	case http.MethodGet:
+		status = http.StatusPartialContent
+		body = t.body
+		header.Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(t.body)-1, len(t.body)))
+		header.Set("Content-Length", fmt.Sprint(len(t.body)))
+	}
+
+	return &http.Response{
+		StatusCode:    status,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func (t *recordingTransport) requestsFor(method string) []*http.Request {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var matched []*http.Request
+	for _, req := range t.requests {
+		if req.Method == method {
+			matched = append(matched, req)
+		}
+	}
+	return matched
+}
+
+func newRecordingS3Repository(transport *recordingTransport) *objectstore.S3ObjectRepository {
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String("http://s3.test"),
+		UsePathStyle: true,
+		HTTPClient:   &http.Client{Transport: transport},
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil
+		}),
+	})
+	repo := objectstore.NewS3ObjectRepository(client, "test-bucket")
+	return &repo
+}
+
+func assertSSECustomerHeaders(t *testing.T, req *http.Request, key []byte) {
+	t.Helper()
+	sum := md5.Sum(key)
+	expected := map[string]string{
+		"X-Amz-Server-Side-Encryption-Customer-Algorithm": "AES256",
+		"X-Amz-Server-Side-Encryption-Customer-Key":       base64.StdEncoding.EncodeToString(key),
+		"X-Amz-Server-Side-Encryption-Customer-Key-Md5":   base64.StdEncoding.EncodeToString(sum[:]),
+	}
+	for header, value := range expected {
+		if got := req.Header.Get(header); got != value {
+			t.Errorf("%s %s: expected %s %q, got %q", req.Method, req.URL.Path, header, value, got)
+		}
+	}
+}
+
+func TestS3ObjectRepository_SSECustomerKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, objectstore.CustomerKeySize)
+	transport := &recordingTransport{}
+	repo := newRecordingS3Repository(transport)
+	repo.SetCustomerKey(key)
+	ctx := context.Background()
+
+	data := []byte("shard encrypted with a customer key")
+	if _, err := repo.Upload(ctx, "docs/report.pdf/abc", bytes.NewReader(data), true); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	tempFile, err := os.CreateTemp(t.TempDir(), "s3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempFile.Close()
+	if err := repo.Download(ctx, "docs/report.pdf/abc", tempFile, false); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	for _, method := range []string{http.MethodPut, http.MethodHead, http.MethodGet} {
+		requests := transport.requestsFor(method)
+		if len(requests) == 0 {
+			t.Fatalf("Expected at least one %s request", method)
+		}
+		for _, req := range requests {
+			assertSSECustomerHeaders(t, req, key)
+		}
+	}
+}
+
+func TestS3ObjectRepository_NoCustomerKeyByDefault(t *testing.T) {
+	transport := &recordingTransport{}
+	repo := newRecordingS3Repository(transport)
+
+	if _, err := repo.Upload(context.Background(), "plain/key", strings.NewReader("data"), true); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	for _, req := range transport.requestsFor(http.MethodPut) {
+		if req.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key") != "" {
+			t.Error("Expected no SSE-C headers without a customer key")
+		}
+	}
+}
+
+func TestParseCustomerKey(t *testing.T) {
+	valid := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	if key, err := objectstore.ParseCustomerKey(valid); err != nil || len(key) != 32 {
+		t.Errorf("Expected 32-byte key, got %d bytes, %v", len(key), err)
+	}
+
+	for _, invalid := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := objectstore.ParseCustomerKey(invalid); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
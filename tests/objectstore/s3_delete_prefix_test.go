@@ -0,0 +1,241 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+// batchDeleteTransport answers ListObjectsV2, DeleteObjects and DeleteObject requests for one bucket from memory
+type batchDeleteTransport struct {
+	mu         sync.Mutex
+	objects    map[string]bool
+	pageSize   int
+	latency    time.Duration     // Added to every request, to make round trips count
+	failOnce   map[string]string // Error code returned the first time a key is batch-deleted
+	failAlways map[string]string // Error code returned every time a key is batch-deleted
+
+	lists, batches, singles int
+}
+
+func newBatchDeleteTransport(pageSize int, keys ...string) *batchDeleteTransport {
+	t := &batchDeleteTransport{objects: make(map[string]bool), pageSize: pageSize, failOnce: map[string]string{}, failAlways: map[string]string{}}
+	for _, key := range keys {
+		t.objects[key] = true
+	}
+	return t
+}
+
+func (t *batchDeleteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	time.Sleep(t.latency)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	query := req.URL.Query()
+	key := strings.TrimPrefix(req.URL.Path, "/test-bucket/")
+	var body string
+	status := http.StatusOK
+	switch {
+	case req.Method == http.MethodGet && query.Get("list-type") == "2":
+		t.lists++
+		body = t.listPage(query.Get("prefix"), query.Get("continuation-token"))
+	case req.Method == http.MethodPost && query.Has("delete"):
+		t.batches++
+		var request struct {
+			Objects []struct {
+				Key string `xml:"Key"`
+			} `xml:"Object"`
+		}
+		data, _ := io.ReadAll(req.Body)
+		if err := xml.Unmarshal(data, &request); err != nil {
+			return nil, err
+		}
+		var result strings.Builder
+		result.WriteString("<DeleteResult>")
+		for _, object := range request.Objects {
+			code, failing := t.failAlways[object.Key]
+			if once, ok := t.failOnce[object.Key]; ok {
+				code, failing = once, true
+				delete(t.failOnce, object.Key)
+			}
+			if failing {
+				fmt.Fprintf(&result, "<Error><Key>%s</Key><Code>%s</Code><Message>%s for %s</Message></Error>", object.Key, code, code, object.Key)
+				continue
+			}
+			delete(t.objects, object.Key)
+		}
+		result.WriteString("</DeleteResult>")
+		body = result.String()
+	case req.Method == http.MethodDelete:
+		t.singles++
+		delete(t.objects, key)
+		status = http.StatusNoContent
+	default:
+		status = http.StatusBadRequest
+	}
+
+	return &http.Response{
+		StatusCode:    status,
+		Header:        http.Header{"Content-Type": {"application/xml"}},
+		Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// listPage returns a ListObjectsV2 page of keys after the continuation token, which is the last key of the previous page
+func (t *batchDeleteTransport) listPage(prefix, token string) string {
+	var keys []string
+	for key := range t.objects {
+		if strings.HasPrefix(key, prefix) && key > token {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	truncated := len(keys) > t.pageSize
+	keys = keys[:min(len(keys), t.pageSize)]
+
+	var page strings.Builder
+	fmt.Fprintf(&page, "<ListBucketResult><Name>test-bucket</Name><KeyCount>%d</KeyCount><IsTruncated>%t</IsTruncated>", len(keys), truncated)
+	if truncated {
+		fmt.Fprintf(&page, "<NextContinuationToken>%s</NextContinuationToken>", keys[len(keys)-1])
+	}
+	for _, key := range keys {
+		fmt.Fprintf(&page, "<Contents><Key>%s</Key><Size>1</Size></Contents>", key)
+	}
+	page.WriteString("</ListBucketResult>")
+	return page.String()
+}
+
+func (t *batchDeleteTransport) remaining(prefix string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	count := 0
+	for key := range t.objects {
+		if strings.HasPrefix(key, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func newBatchDeleteRepository(transport *batchDeleteTransport) *objectstore.S3ObjectRepository {
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String("http://s3.test"),
+		UsePathStyle: true,
+		HTTPClient:   &http.Client{Transport: transport},
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil
+		}),
+	})
+	repo := objectstore.NewS3ObjectRepository(client, "test-bucket")
+	return &repo
+}
+
+func shardKeys(prefix string, count int) []string {
+	keys := make([]string, count)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%s%05d", prefix, i)
+	}
+	return keys
+}
+
+func TestS3DeletePrefix_BatchesEveryPage(t *testing.T) {
+	transport := newBatchDeleteTransport(1000, append(shardKeys("docs/big/", 2500), shardKeys("docs/other/", 10)...)...)
+	repo := newBatchDeleteRepository(transport)
+
+	if err := repo.DeletePrefix(context.Background(), "docs/big/"); err != nil {
+		t.Fatal(err)
+	}
+	if left := transport.remaining("docs/big/"); left != 0 {
+		t.Errorf("Expected every object under the prefix to be deleted, %d left", left)
+	}
+	if left := transport.remaining("docs/other/"); left != 10 {
+		t.Errorf("Expected objects outside the prefix to be kept, %d of 10 left", left)
+	}
+	if transport.batches != 3 || transport.singles != 0 {
+		t.Errorf("Expected 3 DeleteObjects calls and no single deletes, got %d and %d", transport.batches, transport.singles)
+	}
+}
+
+func TestS3DeletePrefix_RetriesTransientKeyErrors(t *testing.T) {
+	transport := newBatchDeleteTransport(1000, shardKeys("docs/big/", 20)...)
+	transport.failOnce["docs/big/00003"] = "SlowDown"
+	transport.failOnce["docs/big/00007"] = "InternalError"
+	repo := newBatchDeleteRepository(transport)
+
+	if err := repo.DeletePrefix(context.Background(), "docs/big/"); err != nil {
+		t.Fatalf("Expected transient failures to be retried, got %v", err)
+	}
+	if left := transport.remaining("docs/big/"); left != 0 {
+		t.Errorf("Expected every object to be deleted after the retry, %d left", left)
+	}
+	if transport.batches != 2 {
+		t.Errorf("Expected one retry batch, got %d DeleteObjects calls", transport.batches)
+	}
+}
+
+func TestS3DeletePrefix_ReportsPermanentKeyErrors(t *testing.T) {
+	transport := newBatchDeleteTransport(10, shardKeys("docs/big/", 25)...)
+	transport.failAlways["docs/big/00004"] = "AccessDenied"
+	transport.failAlways["docs/big/00012"] = "SlowDown"
+	repo := newBatchDeleteRepository(transport)
+
+	err := repo.DeletePrefix(context.Background(), "docs/big/")
+	if err == nil || !strings.Contains(err.Error(), "docs/big/00004") || !strings.Contains(err.Error(), "docs/big/00012") {
+		t.Fatalf("Expected both failing keys to be reported, got %v", err)
+	}
+	if left := transport.remaining("docs/big/"); left != 2 {
+		t.Errorf("Expected only the failing objects to be left, %d left", left)
+	}
+	// Three pages, plus two retries of the key that keeps failing with a transient code
+	if transport.batches != 5 {
+		t.Errorf("Expected 5 DeleteObjects calls, got %d", transport.batches)
+	}
+}
+
+// BenchmarkS3DeletePrefix compares deleting a prefix key by key, as DeletePrefix used to, with batched deletes
+func BenchmarkS3DeletePrefix(b *testing.B) {
+	const objects = 2000
+	keys := shardKeys("docs/big/", objects)
+
+	b.Run("per-key", func(b *testing.B) {
+		for range b.N {
+			transport := newBatchDeleteTransport(1000, keys...)
+			transport.latency = 200 * time.Microsecond
+			repo := newBatchDeleteRepository(transport)
+			listed, err := repo.ListKeys(context.Background(), "docs/big/")
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, key := range listed {
+				if err := repo.Delete(context.Background(), key); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("batched", func(b *testing.B) {
+		for range b.N {
+			transport := newBatchDeleteTransport(1000, keys...)
+			transport.latency = 200 * time.Microsecond
+			repo := newBatchDeleteRepository(transport)
+			if err := repo.DeletePrefix(context.Background(), "docs/big/"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
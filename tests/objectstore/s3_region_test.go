@@ -0,0 +1,145 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+// regionTransport serves a bucket that lives in bucketRegion
+// Signed requests scoped to another region get the redirect S3 sends for a wrong
+// region; unsigned HeadBucket requests (region detection) report the bucket region.
+type regionTransport struct {
+	mu           sync.Mutex
+	bucketRegion string // Empty makes region detection fail
+	body         []byte
+	regions      []string // Signing region of every signed request
+	lookups      int
+}
+
+func (t *regionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	respond := func(status int, header http.Header, body string) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    status,
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	auth := req.Header.Get("Authorization")
+	if auth == "" {
+		t.lookups++
+		if t.bucketRegion == "" {
+			return respond(http.StatusForbidden, http.Header{}, "")
+		}
+		return respond(http.StatusOK, http.Header{"X-Amz-Bucket-Region": {t.bucketRegion}}, "")
+	}
+
+	// Credential=AKID/<date>/<region>/s3/aws4_request
+	region := strings.Split(strings.SplitN(auth, "Credential=", 2)[1], "/")[2]
+	t.regions = append(t.regions, region)
+	if region != t.bucketRegion {
+		if req.Method == http.MethodHead {
+			return respond(http.StatusMovedPermanently, http.Header{}, "")
+		}
+		return respond(http.StatusMovedPermanently, http.Header{"Content-Type": {"application/xml"}},
+			`<Error><Code>PermanentRedirect</Code><Message>The bucket you are attempting to access must be addressed using the specified endpoint.</Message></Error>`)
+	}
+
+	switch req.Method {
+	case http.MethodPut:
+		t.body, _ = io.ReadAll(req.Body)
+		return respond(http.StatusOK, http.Header{"Etag": {`"etag"`}}, "")
+	case http.MethodHead:
+		return respond(http.StatusOK, http.Header{"Content-Length": {fmt.Sprint(len(t.body))}}, "")
+	}
+	return respond(http.StatusBadRequest, http.Header{}, "")
+}
+
+func newRegionFactory(transport *regionTransport) *objectstore.ObjectRepositoryFactory {
+	return objectstore.NewObjectRepositoryFactory(aws.Config{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String("http://s3.test"),
+		HTTPClient:   &http.Client{Transport: transport},
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil
+		}),
+	}, nil)
+}
+
+func TestS3Region_RedirectIsDetectedAndRetried(t *testing.T) {
+	transport := &regionTransport{bucketRegion: "eu-west-1"}
+	factory := newRegionFactory(transport)
+	repo, err := factory.CreateRepository(objectstore.BucketConfig{Name: "bucket", Type: objectstore.S3Type, Region: "us-east-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The upload is redirected, so its body has to be replayed in the detected region
+	data := []byte("shard stored in another region")
+	if _, err := repo.Upload(context.Background(), "docs/a/1", bytes.NewReader(data), true); err != nil {
+		t.Fatalf("Expected upload to be retried in the bucket's region, got %v", err)
+	}
+	if !bytes.Equal(transport.body, data) {
+		t.Errorf("Expected the full body after the retry, got %q", transport.body)
+	}
+
+	size, err := repo.GetObjectSize(context.Background(), "docs/a/1")
+	if err != nil || size != int64(len(data)) {
+		t.Fatalf("Expected size %d after region correction, got %d (%v)", len(data), size, err)
+	}
+
+	expected := []string{"us-east-1", "eu-west-1", "eu-west-1"}
+	if strings.Join(transport.regions, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected signing regions %v, got %v", expected, transport.regions)
+	}
+	if transport.lookups != 1 {
+		t.Errorf("Expected one region lookup, got %d", transport.lookups)
+	}
+
+	// Repositories created later for the same bucket start in the cached region
+	again, err := factory.CreateRepository(objectstore.BucketConfig{Name: "bucket", Type: objectstore.S3Type})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := again.GetObjectSize(context.Background(), "docs/a/1"); err != nil {
+		t.Fatalf("Expected cached region to be used, got %v", err)
+	}
+	if last := transport.regions[len(transport.regions)-1]; last != "eu-west-1" || transport.lookups != 1 {
+		t.Errorf("Expected a direct request to eu-west-1 without a lookup, got %s after %d lookups", last, transport.lookups)
+	}
+}
+
+func TestS3Region_FailedDetectionKeepsConfiguredRegion(t *testing.T) {
+	transport := &regionTransport{}
+	factory := newRegionFactory(transport)
+	repo, err := factory.CreateRepository(objectstore.BucketConfig{Name: "bucket", Type: objectstore.S3Type, Region: "us-east-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.GetObjectSize(context.Background(), "docs/a/1"); err == nil {
+		t.Fatal("Expected the redirect error when the region cannot be detected")
+	}
+	if _, err := repo.GetObjectSize(context.Background(), "docs/a/1"); err == nil {
+		t.Fatal("Expected the redirect error again")
+	}
+	for _, region := range transport.regions {
+		if region != "us-east-1" {
+			t.Errorf("Expected requests to stay in the configured region, got %s", region)
+		}
+	}
+}
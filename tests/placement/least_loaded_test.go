@@ -0,0 +1,153 @@
+package placement
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/placement"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+// usageRepository is a bucket that only reports how much it stores
+type usageRepository struct {
+	mu    sync.Mutex
+	name  string
+	bytes int64
+	err   error
+}
+
+func (r *usageRepository) GetUsage(ctx context.Context) (objectstore.BucketUsage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return objectstore.BucketUsage{}, r.err
+	}
+	return objectstore.BucketUsage{Objects: 1, Bytes: r.bytes}, nil
+}
+
+func (r *usageRepository) setBytes(bytes int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bytes = bytes
+}
+
+func (r *usageRepository) Upload(ctx context.Context, key string, reader io.Reader, quiet bool) (string, error) {
+	return r.name + "/" + key, nil
+}
+func (r *usageRepository) Download(ctx context.Context, key string, dest io.WriterAt, quiet bool) error {
+	return nil
+}
+func (r *usageRepository) Delete(ctx context.Context, key string) error          { return nil }
+func (r *usageRepository) DeletePrefix(ctx context.Context, prefix string) error { return nil }
+func (r *usageRepository) GetObjectSize(ctx context.Context, key string) (int64, error) {
+	return 0, nil
+}
+func (r *usageRepository) ListKeys(ctx context.Context, prefix string) ([]string, error) {
+	return nil, nil
+}
+func (r *usageRepository) GetBucketName() string  { return r.name }
+func (r *usageRepository) GetStorageType() string { return "memory" }
+
+// opaqueRepository hides GetUsage, like a repository that cannot report usage
+type opaqueRepository struct {
+	objectstore.ObjectRepository
+}
+
+func placedBuckets(t *testing.T, placer placement.Placer, shards int) []string {
+	t.Helper()
+	buckets := make([]string, shards)
+	for i := range buckets {
+		name, _, err := placer.Place(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buckets[i] = name
+	}
+	return buckets
+}
+
+func TestLeastLoadedPlacer_RanksByUsage(t *testing.T) {
+	placer := placement.NewLeastLoadedPlacer(time.Hour)
+	full := &usageRepository{name: "full", bytes: 900}
+	empty := &usageRepository{name: "empty", bytes: 10}
+	half := &usageRepository{name: "half", bytes: 500}
+	for _, repo := range []*usageRepository{full, empty, half} {
+		placer.RegisterBucket(repo.name, repo)
+	}
+
+	if err := placer.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Shards of one object spread over distinct buckets, least loaded first
+	if got := placedBuckets(t, placer, 4); !reflect.DeepEqual(got, []string{"empty", "half", "full", "empty"}) {
+		t.Errorf("Unexpected placement: %v", got)
+	}
+}
+
+func TestLeastLoadedPlacer_UnknownUsageRanksLast(t *testing.T) {
+	placer := placement.NewLeastLoadedPlacer(time.Hour)
+	opaque := &opaqueRepository{&usageRepository{name: "opaque"}}
+	failing := &usageRepository{name: "failing", err: stderrors.New("access denied")}
+	known := &usageRepository{name: "known", bytes: 1 << 40}
+	placer.RegisterBucket("opaque", opaque)
+	placer.RegisterBucket("failing", failing)
+	placer.RegisterBucket("known", known)
+
+	if err := placer.Refresh(context.Background()); err == nil {
+		t.Error("Expected the failed usage query to be reported")
+	}
+	if got := placer.Ranking(); !reflect.DeepEqual(got, []string{"known", "opaque", "failing"}) {
+		t.Errorf("Expected buckets with unknown usage last in registration order, got %v", got)
+	}
+
+	// A bucket that fails later keeps its last known usage
+	failing.err = nil
+	failing.setBytes(1)
+	placer.Refresh(context.Background())
+	failing.err = stderrors.New("throttled")
+	placer.Refresh(context.Background())
+	if got := placer.Ranking(); !reflect.DeepEqual(got, []string{"failing", "known", "opaque"}) {
+		t.Errorf("Expected last known usage to be kept, got %v", got)
+	}
+}
+
+func TestLeastLoadedPlacer_RefreshesAfterTTL(t *testing.T) {
+	placer := placement.NewLeastLoadedPlacer(20 * time.Millisecond)
+	a := &usageRepository{name: "a", bytes: 1}
+	b := &usageRepository{name: "b", bytes: 2}
+	placer.RegisterBucket("a", a)
+	placer.RegisterBucket("b", b)
+
+	// The first placement triggers a background refresh without blocking
+	placedBuckets(t, placer, 1)
+	waitForRanking(t, placer, []string{"a", "b"})
+
+	a.setBytes(100)
+	time.Sleep(30 * time.Millisecond)
+	placedBuckets(t, placer, 1)
+	waitForRanking(t, placer, []string{"b", "a"})
+}
+
+func waitForRanking(t *testing.T, placer *placement.LeastLoadedPlacer, expected []string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !reflect.DeepEqual(placer.Ranking(), expected) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected ranking %v, got %v", expected, placer.Ranking())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLeastLoadedPlacer_NoBuckets(t *testing.T) {
+	placer := placement.NewLeastLoadedPlacer(time.Minute)
+	if _, _, err := placer.Place(0); err == nil {
+		t.Error("Expected an error with no buckets registered")
+	}
+}
@@ -0,0 +1,61 @@
+package placement
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/placement"
+)
+
+func TestRoundRobinPlacer_PreviewMatchesPlace(t *testing.T) {
+	placer := placement.NewRoundRobinPlacer()
+	for _, name := range []string{"a", "b", "c"} {
+		placer.RegisterBucket(name, &usageRepository{name: name})
+	}
+
+	preview, err := placer.PreviewPlacement(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"a", "b", "c", "a", "b", "c", "a"}; !reflect.DeepEqual(preview, expected) {
+		t.Errorf("Expected %v, got %v", expected, preview)
+	}
+	if placed := placedBuckets(t, placer, 7); !reflect.DeepEqual(preview, placed) {
+		t.Errorf("Expected the preview %v to match Place %v", preview, placed)
+	}
+}
+
+func TestLeastLoadedPlacer_PreviewDoesNotRefresh(t *testing.T) {
+	placer := placement.NewLeastLoadedPlacer(time.Nanosecond)
+	full := &usageRepository{name: "full", bytes: 900}
+	empty := &usageRepository{name: "empty", bytes: 10}
+	placer.RegisterBucket(full.name, full)
+	placer.RegisterBucket(empty.name, empty)
+
+	// Usage is stale, but a preview must not start a refresh
+	preview, err := placer.PreviewPlacement(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if expected := []string{"full", "empty", "full"}; !reflect.DeepEqual(preview, expected) || !reflect.DeepEqual(placer.Ranking(), []string{"full", "empty"}) {
+		t.Errorf("Expected the unrefreshed registration order, got preview %v and ranking %v", preview, placer.Ranking())
+	}
+
+	if err := placer.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if preview, _ := placer.PreviewPlacement(2); !reflect.DeepEqual(preview, []string{"empty", "full"}) {
+		t.Errorf("Expected the preview to follow the refreshed ranking, got %v", preview)
+	}
+}
+
+func TestPreviewPlacement_NoBuckets(t *testing.T) {
+	for _, placer := range []placement.Placer{placement.NewRoundRobinPlacer(), placement.NewLeastLoadedPlacer(time.Hour)} {
+		if _, err := placer.PreviewPlacement(3); err == nil {
+			t.Errorf("Expected %T to fail without buckets", placer)
+		}
+	}
+}
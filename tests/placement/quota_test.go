@@ -0,0 +1,88 @@
+package placement
+
+import (
+	stderrors "errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/placement"
+)
+
+func newQuotaPlacer(t *testing.T, repos ...*usageRepository) *placement.QuotaPlacer {
+	t.Helper()
+	inner := placement.NewRoundRobinPlacer()
+	for _, repo := range repos {
+		if err := inner.RegisterBucket(repo.name, repo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return placement.NewQuotaPlacer(inner, time.Hour)
+}
+
+func TestQuotaPlacer_SkipsBucketsAtQuota(t *testing.T) {
+	placer := newQuotaPlacer(t,
+		&usageRepository{name: "a", bytes: 100},
+		&usageRepository{name: "full", bytes: 1000},
+		&usageRepository{name: "c", bytes: 100},
+	)
+	placer.SetQuota("full", placement.Quota{MaxBytes: 1000})
+
+	buckets := placedBuckets(t, placer, 6)
+	if slices.Contains(buckets, "full") {
+		t.Errorf("Placed shards in a bucket at quota: %v", buckets)
+	}
+	// Shards meant for the full bucket go to the next bucket round-robin would pick
+	if expected := []string{"a", "c", "c", "a", "c", "c"}; !slices.Equal(buckets, expected) {
+		t.Errorf("Expected placement %v, got %v", expected, buckets)
+	}
+
+	preview, err := placer.PreviewPlacement(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(preview, buckets) {
+		t.Errorf("Preview %v does not match placement %v", preview, buckets)
+	}
+}
+
+func TestQuotaPlacer_ObjectQuota(t *testing.T) {
+	// usageRepository reports one object per bucket
+	placer := newQuotaPlacer(t, &usageRepository{name: "a"}, &usageRepository{name: "b"})
+	placer.SetQuota("a", placement.Quota{MaxObjects: 1})
+
+	if buckets := placedBuckets(t, placer, 2); !slices.Equal(buckets, []string{"b", "b"}) {
+		t.Errorf("Expected every shard in b, got %v", buckets)
+	}
+}
+
+func TestQuotaPlacer_RecordedUploadsFillBucket(t *testing.T) {
+	placer := newQuotaPlacer(t, &usageRepository{name: "a", bytes: 0}, &usageRepository{name: "b", bytes: 0})
+	placer.SetQuota("a", placement.Quota{MaxBytes: 500})
+
+	if name, _, err := placer.Place(0); err != nil || name != "a" {
+		t.Fatalf("Expected shard 0 in a, got %q, %v", name, err)
+	}
+	placer.RecordUpload("a", 500)
+
+	if name, _, err := placer.Place(0); err != nil || name != "b" {
+		t.Errorf("Expected shard 0 in b once a is full, got %q, %v", name, err)
+	}
+	if usage := placer.Usage("a"); usage.Bytes != 500 || usage.Objects != 2 {
+		t.Errorf("Expected a to track 2 objects and 500 bytes, got %+v", usage)
+	}
+}
+
+func TestQuotaPlacer_AllBucketsAtQuota(t *testing.T) {
+	placer := newQuotaPlacer(t, &usageRepository{name: "a", bytes: 10}, &usageRepository{name: "b", bytes: 10})
+	placer.SetQuota("a", placement.Quota{MaxBytes: 10})
+	placer.SetQuota("b", placement.Quota{MaxBytes: 10})
+
+	if _, _, err := placer.Place(0); !stderrors.Is(err, errors.ErrBucketsAtQuota) {
+		t.Errorf("Expected ErrBucketsAtQuota, got %v", err)
+	}
+	if _, err := placer.PreviewPlacement(2); !stderrors.Is(err, errors.ErrBucketsAtQuota) {
+		t.Errorf("Expected ErrBucketsAtQuota from the preview, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package placement
+
+import (
+	stderrors "errors"
+	"slices"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/placement"
+)
+
+func TestSubsetPlacer_CyclesThroughSubsetInPlacerOrder(t *testing.T) {
+	inner := placement.NewRoundRobinPlacer()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if err := inner.RegisterBucket(name, &usageRepository{name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	placer, err := placement.NewSubsetPlacer(inner, []string{"d", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buckets := placedBuckets(t, placer, 5)
+	if expected := []string{"b", "d", "b", "d", "b"}; !slices.Equal(buckets, expected) {
+		t.Errorf("Expected placement %v, got %v", expected, buckets)
+	}
+	preview, err := placer.PreviewPlacement(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(preview, buckets) {
+		t.Errorf("Preview %v does not match placement %v", preview, buckets)
+	}
+	if listed := placer.ListBuckets(); !slices.Equal(listed, []string{"d", "b"}) {
+		t.Errorf("Expected the subset to be listed, got %v", listed)
+	}
+	if _, err := placer.GetRepositoryForBucket("a"); err != nil {
+		t.Errorf("Expected buckets outside the subset to stay readable, got %v", err)
+	}
+}
+
+func TestSubsetPlacer_SkipsBucketsAtQuota(t *testing.T) {
+	quota := newQuotaPlacer(t,
+		&usageRepository{name: "a", bytes: 100},
+		&usageRepository{name: "full", bytes: 1000},
+		&usageRepository{name: "c", bytes: 100},
+	)
+	quota.SetQuota("full", placement.Quota{MaxBytes: 1000})
+	placer, err := placement.NewSubsetPlacer(quota, []string{"full", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if buckets := placedBuckets(t, placer, 3); !slices.Equal(buckets, []string{"c", "c", "c"}) {
+		t.Errorf("Expected every shard in c, got %v", buckets)
+	}
+}
+
+func TestNewSubsetPlacer_RejectsUnknownAndDuplicateBuckets(t *testing.T) {
+	inner := placement.NewRoundRobinPlacer()
+	if err := inner.RegisterBucket("a", &usageRepository{name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := placement.NewSubsetPlacer(inner, []string{"a", "missing"}); !stderrors.Is(err, errors.ErrBucketNotRegistered) {
+		t.Errorf("Expected ErrBucketNotRegistered, got %v", err)
+	}
+	if _, err := placement.NewSubsetPlacer(inner, []string{"a", "a"}); err == nil {
+		t.Error("Expected a duplicate bucket to be rejected")
+	}
+	if _, err := placement.NewSubsetPlacer(inner, nil); err == nil {
+		t.Error("Expected an empty subset to be rejected")
+	}
+}
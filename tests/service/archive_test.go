@@ -0,0 +1,184 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/rand"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/placement"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestArchiveDir_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+
+	src := t.TempDir()
+	files := map[string]string{
+		"a.txt":          strings.Repeat("0123456789", 400) + "distinct tail",
+		"nested/b.txt":   "small file",
+		"nested/c/d.bin": strings.Repeat("xyz", 1000),
+	}
+	for name, content := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	members, err := fileService.ArchiveDir(ctx, src, "bundles/src.tar.zst", true, 4, 2, 6)
+	if err != nil {
+		t.Fatalf("ArchiveDir failed: %v", err)
+	}
+	if len(members) != len(files) {
+		t.Fatalf("Expected %d members, got %+v", len(files), members)
+	}
+
+	// The listing is readable from metadata alone
+	stored := metadataRepo.records["bundles/src.tar.zst"]
+	if len(stored.ArchiveMembers) != len(files) {
+		t.Fatalf("Expected the member listing in metadata, got %+v", stored.ArchiveMembers)
+	}
+	for _, member := range stored.ArchiveMembers {
+		if int64(len(files[member.Name])) != member.Size {
+			t.Errorf("Expected %s to be listed with size %d, got %d", member.Name, len(files[member.Name]), member.Size)
+		}
+	}
+
+	dest := t.TempDir()
+	extracted, err := fileService.ExtractArchive(ctx, "bundles/src.tar.zst", dest, true)
+	if err != nil {
+		t.Fatalf("ExtractArchive failed: %v", err)
+	}
+	if len(extracted) != len(files) {
+		t.Errorf("Expected %d extracted files, got %v", len(files), extracted)
+	}
+	for name, content := range files {
+		path := filepath.Join(dest, filepath.FromSlash(name))
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("Extracted %s does not match the original", name)
+		}
+		if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+			t.Errorf("Expected %s to keep mode 0640, got %v", name, info.Mode().Perm())
+		}
+	}
+}
+
+func TestExtractArchive_RejectsEntriesOutsideDestination(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, _ := newMemoryFileService(t, 6)
+
+	var archive bytes.Buffer
+	encoder, _ := zstd.NewWriter(&archive)
+	tw := tar.NewWriter(encoder)
+	content := []byte("escaped")
+	if err := tw.WriteHeader(&tar.Header{Name: "../escape.txt", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(content)
+	tw.Close()
+	encoder.Close()
+	if err := fileService.UploadFile(ctx, "bundles/evil.tar.zst", &archive, true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	parent := t.TempDir()
+	dest := filepath.Join(parent, "out")
+	if _, err := fileService.ExtractArchive(ctx, "bundles/evil.tar.zst", dest, false); err == nil {
+		t.Fatal("Expected an entry outside the destination to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(parent, "escape.txt")); !os.IsNotExist(err) {
+		t.Error("Expected nothing written outside the destination")
+	}
+}
+
+func TestExtractArchive_NotAnArchive(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, _ := newMemoryFileService(t, 6)
+	if err := fileService.UploadFile(ctx, "docs/plain", strings.NewReader(strings.Repeat("plain text ", 100)), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fileService.ExtractArchive(ctx, "docs/plain", t.TempDir(), false); err == nil {
+		t.Fatal("Expected a plain object to fail extraction")
+	}
+}
+
+// archiveWithSlowLastDataShard archives a small file followed by a large incompressible one,
+// then slows down the bucket holding the archive's last data shard
+func archiveWithSlowLastDataShard(t *testing.T, delay time.Duration) *service.FileService {
+	t.Helper()
+	placer := placement.NewRoundRobinPlacer()
+	buckets := make(map[string]*slowObjectRepository)
+	for i := 0; i < 6; i++ {
+		bucket := &slowObjectRepository{MemoryObjectRepository: objectstoretest.NewMemoryObjectRepository(fmt.Sprintf("bucket-%d", i))}
+		buckets[bucket.GetBucketName()] = bucket
+		if err := placer.RegisterBucket(bucket.GetBucketName(), bucket); err != nil {
+			t.Fatal(err)
+		}
+	}
+	metadataRepo := newFlakyMetadataRepository()
+	fileService := service.NewFileService(placer, metadataRepo)
+	fileService.SetConcurrency(4)
+
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "a-first.txt"), []byte("the first member"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	noise := make([]byte, 256*1024)
+	rand.Read(noise)
+	if err := os.WriteFile(filepath.Join(src, "b-noise.bin"), noise, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fileService.ArchiveDir(context.Background(), src, "bundles/src.tar.zst", true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	buckets[metadataRepo.records["bundles/src.tar.zst"].ShardHashes[3].Locations[0].BucketName].delay = delay
+	return fileService
+}
+
+func TestExtractArchiveMember_StopsAtMember(t *testing.T) {
+	fileService := archiveWithSlowLastDataShard(t, 5*time.Second)
+
+	var out bytes.Buffer
+	start := time.Now()
+	if err := fileService.ExtractArchiveMember(context.Background(), "bundles/src.tar.zst", "./a-first.txt", &out, true); err != nil {
+		t.Fatalf("ExtractArchiveMember failed: %v", err)
+	}
+	if out.String() != "the first member" {
+		t.Errorf("Expected the member's content, got %q", out.String())
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected extraction to stop without waiting for the last shard, took %v", elapsed)
+	}
+}
+
+func TestExtractArchiveMember_NotFound(t *testing.T) {
+	fileService := archiveWithSlowLastDataShard(t, 0)
+
+	var out bytes.Buffer
+	err := fileService.ExtractArchiveMember(context.Background(), "bundles/src.tar.zst", "missing.txt", &out, false)
+	if !stderrors.Is(err, errors.ErrArchiveMemberNotFound) {
+		t.Fatalf("Expected ErrArchiveMemberNotFound, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Error("Expected nothing written for a missing member")
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// recordingAuditLogger keeps every audit record in memory
+type recordingAuditLogger struct {
+	mu      sync.Mutex
+	records []domain.AuditRecord
+	ctxErrs []error
+}
+
+func (l *recordingAuditLogger) LogOperation(ctx context.Context, op domain.AuditOperation, key, user string, result domain.AuditResult) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, domain.NewAuditRecord(op, key, user, result))
+	l.ctxErrs = append(l.ctxErrs, ctx.Err())
+	return nil
+}
+
+func TestAudit_RecordsUploadDownloadAndDelete(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 6)
+	logger := &recordingAuditLogger{}
+	fileService.SetAuditLogger(logger, "alice")
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(service.WithAuditUser(ctx, "bob"), "docs/x", &dest, true, true); err != nil {
+		t.Fatalf("DownloadFile failed: %v", err)
+	}
+	if err := fileService.DeleteFile(ctx, "docs/x"); err != nil {
+		t.Fatalf("DeleteFile failed: %v", err)
+	}
+
+	expected := []struct {
+		op    domain.AuditOperation
+		user  string
+		bytes int64
+	}{
+		{domain.AuditUpload, "alice", int64(len(data))},
+		{domain.AuditDownload, "bob", int64(len(data))},
+		{domain.AuditDelete, "alice", 0},
+	}
+	if len(logger.records) != len(expected) {
+		t.Fatalf("Expected %d audit records, got %d: %+v", len(expected), len(logger.records), logger.records)
+	}
+	for i, want := range expected {
+		record := logger.records[i]
+		if record.Operation != want.op || record.Key != "docs/x" || record.User != want.user || record.Bytes != want.bytes {
+			t.Errorf("Record %d = %+v, expected %s of docs/x by %s with %d bytes", i, record, want.op, want.user, want.bytes)
+		}
+		if record.Outcome != domain.AuditSuccess || record.Error != "" {
+			t.Errorf("Record %d: expected success, got %s (%s)", i, record.Outcome, record.Error)
+		}
+	}
+}
+
+func TestAudit_RecordsFailures(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 6)
+	logger := &recordingAuditLogger{}
+	fileService.SetAuditLogger(logger, "alice")
+
+	// The caller has already given up; the failure must still be recorded
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/missing", &dest, true, true); err == nil {
+		t.Fatal("Expected downloading a missing file to fail")
+	}
+	if err := fileService.UploadFile(context.Background(), "docs/empty", bytes.NewReader(nil), true, 4, 2, 3); !stderrors.Is(err, errors.ErrEmptyFile) {
+		t.Fatalf("Expected ErrEmptyFile, got %v", err)
+	}
+
+	if len(logger.records) != 2 {
+		t.Fatalf("Expected 2 audit records, got %d", len(logger.records))
+	}
+	for i, op := range []domain.AuditOperation{domain.AuditDownload, domain.AuditUpload} {
+		if record := logger.records[i]; record.Operation != op || record.Outcome != domain.AuditFailure || record.Error == "" {
+			t.Errorf("Record %d = %+v, expected a failed %s", i, record, op)
+		}
+	}
+	if logger.ctxErrs[0] != nil {
+		t.Errorf("Audit record of a canceled download was written with a canceled context: %v", logger.ctxErrs[0])
+	}
+}
+
+func TestFileAuditLogger_AppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := service.NewFileAuditLogger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	fileService, _, _ := newMemoryFileService(t, 6)
+	fileService.SetAuditLogger(logger, "alice")
+	ctx := context.Background()
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	var dest recordingWriterAt
+	fileService.DownloadFile(ctx, "docs/missing", &dest, true, true)
+
+	// Records are synced as they are written, so they are readable without closing the logger
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var records []domain.AuditRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record domain.AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Audit line %q is not JSON: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 audit lines, got %d", len(records))
+	}
+	if records[0].Operation != domain.AuditUpload || records[0].Outcome != domain.AuditSuccess || records[0].Bytes != int64(len(data)) {
+		t.Errorf("Unexpected upload record %+v", records[0])
+	}
+	if records[1].Operation != domain.AuditDownload || records[1].Outcome != domain.AuditFailure {
+		t.Errorf("Unexpected download record %+v", records[1])
+	}
+	if records[0].ID == records[1].ID || records[0].ID > records[1].ID {
+		t.Errorf("Record IDs %q and %q are not unique and time-ordered", records[0].ID, records[1].ID)
+	}
+}
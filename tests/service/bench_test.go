@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestBench_ReportsAndCleansUp(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	fileService.SetConcurrency(6)
+
+	report, err := fileService.Bench(context.Background(), service.BenchOptions{
+		Size:              64 * 1024,
+		Iterations:        3,
+		DataShards:        4,
+		ParityShards:      2,
+		UploadConcurrency: 3,
+		Prefix:            "bench",
+	})
+	if err != nil {
+		t.Fatalf("Bench failed: %v", err)
+	}
+
+	if report.Upload.Count != 3 || report.Download.Count != 3 {
+		t.Errorf("Expected 3 upload and download samples, got %d and %d", report.Upload.Count, report.Download.Count)
+	}
+	if report.Upload.P50 <= 0 || report.Upload.P95 < report.Upload.P50 || report.Upload.MBps <= 0 {
+		t.Errorf("Unexpected upload stats: %+v", report.Upload)
+	}
+	if len(report.Shards) != 6 {
+		t.Fatalf("Expected timings for 6 shards, got %d", len(report.Shards))
+	}
+	for i, shard := range report.Shards {
+		if shard.Index != i || shard.BucketName != buckets[i].GetBucketName() || shard.Upload.Count != 3 {
+			t.Errorf("Unexpected stats for shard %d: %+v", i, shard)
+		}
+	}
+
+	for _, bucket := range buckets {
+		if keys, _ := bucket.ListKeys(context.Background(), ""); len(keys) != 0 {
+			t.Errorf("Expected %s to be empty after the benchmark, found %v", bucket.GetBucketName(), keys)
+		}
+	}
+	if len(metadataRepo.records) != 0 {
+		t.Errorf("Expected benchmark metadata to be deleted, found %d records", len(metadataRepo.records))
+	}
+}
+
+func TestBench_CleansUpAfterFailure(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	buckets[0].FailWith(errBucketUnavailable)
+	fileService.SetMaxShardFailures(0)
+
+	_, err := fileService.Bench(context.Background(), service.BenchOptions{Size: 4096, Iterations: 2, DataShards: 4, ParityShards: 2, Prefix: "bench"})
+	if err == nil {
+		t.Fatal("Expected benchmark to fail when uploads fail")
+	}
+	if len(metadataRepo.records) != 0 {
+		t.Errorf("Expected no benchmark metadata left behind, found %d records", len(metadataRepo.records))
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestCompareFiles(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	other := []byte(strings.Repeat("0123456789", 400) + "distinct TAIL")
+	for key, content := range map[string][]byte{"a/x": data, "b/x": data, "c/x": other, "d/x": data[:100]} {
+		if err := fileService.UploadFile(ctx, key, bytes.NewReader(content), true, 4, 2, 3); err != nil {
+			t.Fatalf("UploadFile %s failed: %v", key, err)
+		}
+	}
+	// Comparing must not read any shard
+	for _, bucket := range buckets {
+		bucket.Clear()
+	}
+
+	tests := []struct {
+		name    string
+		a, b    string
+		verdict service.ComparisonVerdict
+		basis   string
+	}{
+		{"same content", "a/x", "b/x", service.ObjectsIdentical, "file hash"},
+		{"same size, different content", "a/x", "c/x", service.ObjectsDifferent, "file hash"},
+		{"different size", "a/x", "d/x", service.ObjectsDifferent, "size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison, err := fileService.CompareFiles(ctx, tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("CompareFiles failed: %v", err)
+			}
+			if comparison.Verdict != tt.verdict || comparison.Basis != tt.basis {
+				t.Errorf("Expected %s by %s, got %s by %s", tt.verdict, tt.basis, comparison.Verdict, comparison.Basis)
+			}
+		})
+	}
+
+	// Records written before file hashes existed are compared by their shard hashes
+	for _, key := range []string{"a/x", "b/x", "c/x"} {
+		record := metadataRepo.records[key]
+		record.FileHash = ""
+		metadataRepo.records[key] = record
+	}
+	if comparison, _ := fileService.CompareFiles(ctx, "a/x", "b/x"); comparison.Verdict != service.ObjectsIdentical || comparison.Basis != "shard hashes" {
+		t.Errorf("Expected identical by shard hashes, got %+v", comparison)
+	}
+	if comparison, _ := fileService.CompareFiles(ctx, "a/x", "c/x"); comparison.Verdict != service.ObjectsDifferent || comparison.Basis != "shard hashes" {
+		t.Errorf("Expected different by shard hashes, got %+v", comparison)
+	}
+}
+
+func TestCompareFiles_DifferentLayoutsWithoutFileHash(t *testing.T) {
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "a/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileService.UploadFile(ctx, "b/x", bytes.NewReader(data), true, 3, 3, 3); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a/x", "b/x"} {
+		record := metadataRepo.records[key]
+		record.FileHash = ""
+		metadataRepo.records[key] = record
+	}
+
+	comparison, err := fileService.CompareFiles(ctx, "a/x", "b/x")
+	if err != nil {
+		t.Fatalf("CompareFiles failed: %v", err)
+	}
+	if comparison.Verdict != service.ObjectsUnknown || !strings.Contains(comparison.Basis, "full download") {
+		t.Errorf("Expected an unknown verdict that requires a full download, got %+v", comparison)
+	}
+
+	if _, err := fileService.CompareFiles(ctx, "a/x", "missing/x"); err == nil {
+		t.Error("Expected comparing with a missing object to fail")
+	}
+}
+
+func TestCompareFiles_ComparesContentOfEncryptedObjects(t *testing.T) {
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	ctx := context.Background()
+	fileService.SetCompression(service.TransformZstd)
+	fileService.SetEncryptionKey(newEncryptionKey(t))
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	other := []byte(strings.Repeat("0123456789", 400) + "distinct TAIL")
+	for key, content := range map[string][]byte{"a/x": data, "b/x": data, "c/x": other} {
+		if err := fileService.UploadFile(ctx, key, bytes.NewReader(content), true, 4, 2, 3); err != nil {
+			t.Fatalf("UploadFile %s failed: %v", key, err)
+		}
+	}
+	// The same content, uploaded without any transform
+	fileService.SetCompression("")
+	fileService.SetEncryptionKey(nil)
+	if err := fileService.UploadFile(ctx, "d/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if metadataRepo.records["a/x"].FileHash == metadataRepo.records["b/x"].FileHash {
+		t.Fatal("Expected each encryption to produce different stored bytes")
+	}
+
+	for _, tt := range []struct {
+		a, b    string
+		verdict service.ComparisonVerdict
+	}{
+		{"a/x", "b/x", service.ObjectsIdentical},
+		{"a/x", "c/x", service.ObjectsDifferent},
+		{"a/x", "d/x", service.ObjectsIdentical},
+	} {
+		comparison, err := fileService.CompareFiles(ctx, tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("CompareFiles failed: %v", err)
+		}
+		if comparison.Verdict != tt.verdict || comparison.Basis != "file hash" || comparison.SizeA != int64(len(data)) {
+			t.Errorf("%s vs %s: expected %s by file hash of %d bytes, got %+v", tt.a, tt.b, tt.verdict, len(data), comparison)
+		}
+	}
+
+	// Shards of encrypted objects hold ciphertext, so they cannot stand in for a missing hash
+	for _, key := range []string{"a/x", "b/x"} {
+		record := metadataRepo.records[key]
+		record.PlainHash = ""
+		metadataRepo.records[key] = record
+	}
+	if comparison, _ := fileService.CompareFiles(ctx, "a/x", "b/x"); comparison.Verdict != service.ObjectsUnknown {
+		t.Errorf("Expected an unknown verdict without content hashes, got %+v", comparison)
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/placement"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// transferCounter tracks the peak number of shard transfers in flight across buckets
+type transferCounter struct {
+	mu       sync.Mutex
+	inFlight map[string]int
+	peak     map[string]int
+}
+
+func (c *transferCounter) track(operation string) func() {
+	c.mu.Lock()
+	c.inFlight[operation]++
+	c.peak[operation] = max(c.peak[operation], c.inFlight[operation])
+	c.mu.Unlock()
+
+	// Hold the slot long enough for the other transfers to overlap
+	time.Sleep(20 * time.Millisecond)
+	return func() {
+		c.mu.Lock()
+		c.inFlight[operation]--
+		c.mu.Unlock()
+	}
+}
+
+func (c *transferCounter) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.peak = make(map[string]int)
+}
+
+// countingObjectRepository is an in-memory bucket that reports its transfers to a transferCounter
+type countingObjectRepository struct {
+	*objectstoretest.MemoryObjectRepository
+	counter *transferCounter
+}
+
+func (r *countingObjectRepository) Upload(ctx context.Context, key string, reader io.Reader, quiet bool) (string, error) {
+	defer r.counter.track("upload")()
+	return r.MemoryObjectRepository.Upload(ctx, key, reader, quiet)
+}
+
+func (r *countingObjectRepository) Download(ctx context.Context, key string, dest io.WriterAt, quiet bool) error {
+	defer r.counter.track("download")()
+	return r.MemoryObjectRepository.Download(ctx, key, dest, quiet)
+}
+
+func newCountingFileService(t *testing.T, n int) (*service.FileService, *transferCounter) {
+	t.Helper()
+	counter := &transferCounter{inFlight: make(map[string]int), peak: make(map[string]int)}
+	placer := placement.NewRoundRobinPlacer()
+	for i := 0; i < n; i++ {
+		bucket := &countingObjectRepository{MemoryObjectRepository: objectstoretest.NewMemoryObjectRepository(fmt.Sprintf("bucket-%d", i)), counter: counter}
+		if err := placer.RegisterBucket(bucket.GetBucketName(), bucket); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return service.NewFileService(placer, newFlakyMetadataRepository()), counter
+}
+
+func TestConcurrency_UploadAndDownloadLimitsAreSeparate(t *testing.T) {
+	fileService, counter := newCountingFileService(t, 6)
+	fileService.SetConcurrency(1)
+	fileService.SetUploadConcurrency(2)
+	fileService.SetDownloadConcurrency(4)
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	// A concurrency of 0 uses the service's upload concurrency
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 0); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/x", &dest, true, false); err != nil {
+		t.Fatalf("DownloadFile failed: %v", err)
+	}
+	if !bytes.Equal(dest.data, data) {
+		t.Fatal("Downloaded data does not match the upload")
+	}
+
+	if counter.peak["upload"] != 2 {
+		t.Errorf("Expected 2 concurrent shard uploads, got %d", counter.peak["upload"])
+	}
+	if counter.peak["download"] != 4 {
+		t.Errorf("Expected 4 concurrent shard downloads, got %d", counter.peak["download"])
+	}
+}
+
+func TestConcurrency_SharedDefault(t *testing.T) {
+	fileService, counter := newCountingFileService(t, 6)
+	fileService.SetConcurrency(3)
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 0); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	if err := fileService.StreamFile(ctx, "docs/x", io.Discard, false); err != nil {
+		t.Fatalf("StreamFile failed: %v", err)
+	}
+	if counter.peak["upload"] != 3 || counter.peak["download"] != 3 {
+		t.Errorf("Expected --concurrency to limit both directions to 3, got %d uploads and %d downloads", counter.peak["upload"], counter.peak["download"])
+	}
+
+	// An explicit limit passed to the upload still wins
+	counter.reset()
+	fileService.SetUploadConcurrency(1)
+	if err := fileService.UploadFile(ctx, "docs/y", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	if counter.peak["upload"] != 6 {
+		t.Errorf("Expected 6 concurrent shard uploads, got %d", counter.peak["upload"])
+	}
+}
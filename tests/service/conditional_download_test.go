@@ -0,0 +1,86 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+func downloadIfModified(t *testing.T, download func(dest *os.File) (string, error)) (string, []byte, error) {
+	t.Helper()
+	dest, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dest.Close()
+	etag, err := download(dest)
+	data, readErr := os.ReadFile(dest.Name())
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	return etag, data, err
+}
+
+func TestDownloadFileIfModified_UnchangedObjectIsNotReconstructed(t *testing.T) {
+	ctx := context.Background()
+	fileService, buckets, _ := newMemoryFileService(t, 6)
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	etag, downloaded, err := downloadIfModified(t, func(dest *os.File) (string, error) {
+		return fileService.DownloadFileIfModified(ctx, "docs/x", "", dest, true, true)
+	})
+	if err != nil || !bytes.Equal(downloaded, data) || etag == "" {
+		t.Fatalf("Expected a full download with an ETag, got %d bytes, etag %q (%v)", len(downloaded), etag, err)
+	}
+
+	// With every shard gone, only a download that skips reconstruction can succeed
+	for _, bucket := range buckets {
+		bucket.Clear()
+	}
+	again, downloaded, err := downloadIfModified(t, func(dest *os.File) (string, error) {
+		return fileService.DownloadFileIfModified(ctx, "docs/x", etag, dest, true, true)
+	})
+	if !stderrors.Is(err, errors.ErrNotModified) {
+		t.Fatalf("Expected ErrNotModified for a matching ETag, got %v", err)
+	}
+	if again != etag || len(downloaded) != 0 {
+		t.Errorf("Expected the same ETag and nothing written, got %q and %d bytes", again, len(downloaded))
+	}
+}
+
+func TestDownloadFileIfModified_ChangedObjectIsDownloaded(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, _ := newMemoryFileService(t, 6)
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader([]byte(strings.Repeat("0123456789", 400)+"first")), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := fileService.StatFile(ctx, "docs/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldETag := metadata.ETag()
+
+	updated := []byte(strings.Repeat("0123456789", 400) + "second")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(updated), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	etag, downloaded, err := downloadIfModified(t, func(dest *os.File) (string, error) {
+		return fileService.DownloadFileIfModified(ctx, "docs/x", oldETag, dest, true, true)
+	})
+	if err != nil {
+		t.Fatalf("Expected the changed object to be downloaded, got %v", err)
+	}
+	if etag == oldETag || !bytes.Equal(downloaded, updated) {
+		t.Errorf("Expected a new ETag and the updated content, got %q and %q", etag, downloaded)
+	}
+}
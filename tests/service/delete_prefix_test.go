@@ -0,0 +1,114 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestDeletePrefix_DeletesOnlyObjectsUnderPrefix(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	fileService.SetDeleteConcurrency(2)
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	for _, key := range []string{"docs/a", "docs/b", "docs/c", "docs/2023/q1/e", "docs2/f", "other/d"} {
+		if err := fileService.UploadFile(ctx, key, bytes.NewReader(data), true, 4, 2, 3); err != nil {
+			t.Fatalf("UploadFile %s failed: %v", key, err)
+		}
+	}
+
+	result, err := fileService.DeletePrefix(ctx, "docs")
+	if err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+	if strings.Join(result.Deleted, ",") != "docs/2023/q1/e,docs/a,docs/b,docs/c" || len(result.Failed) != 0 {
+		t.Fatalf("Expected docs/a, docs/b, docs/c and docs/2023/q1/e deleted, got %+v", result)
+	}
+
+	for _, key := range []string{"docs/a", "docs/b", "docs/c", "docs/2023/q1/e"} {
+		if _, ok := metadataRepo.records[key]; ok {
+			t.Errorf("Metadata of %s was not deleted", key)
+		}
+	}
+	for _, key := range []string{"docs2/f", "other/d"} {
+		if _, ok := metadataRepo.records[key]; !ok {
+			t.Fatalf("Object %s outside the prefix was deleted", key)
+		}
+	}
+	// Only the shards of docs2/f and other/d are left
+	for _, bucket := range buckets {
+		if bucket.Len() != 2 {
+			t.Errorf("Bucket %s holds %d shards, expected 2", bucket.GetBucketName(), bucket.Len())
+		}
+	}
+}
+
+func TestDeletePrefix_RequiresAPrefixLister(t *testing.T) {
+	placer, _ := objectstoretest.NewRoundRobinPlacer(t, 6)
+	metadataRepo := newFlakyMetadataRepository()
+	// Embedding only the interface hides ListPrefixes
+	fileService := service.NewFileService(placer, struct{ service.MetadataRepository }{metadataRepo})
+	ctx := context.Background()
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/a", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fileService.DeletePrefix(ctx, "docs"); !stderrors.Is(err, errors.ErrNotImplemented) {
+		t.Fatalf("Expected DeletePrefix to refuse a store that cannot list sub-prefixes, got %v", err)
+	}
+	if _, ok := metadataRepo.records["docs/a"]; !ok {
+		t.Fatal("Expected nothing to be deleted")
+	}
+}
+
+func TestDeletePrefix_ReportsFailuresAndContinues(t *testing.T) {
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	for _, key := range []string{"docs/a", "docs/b"} {
+		if err := fileService.UploadFile(ctx, key, bytes.NewReader(data), true, 4, 2, 3); err != nil {
+			t.Fatalf("UploadFile %s failed: %v", key, err)
+		}
+	}
+
+	// A retention lock keeps docs/a from being deleted
+	record := metadataRepo.records["docs/a"]
+	record.RetentionMode, record.RetainUntil = "GOVERNANCE", time.Now().Add(time.Hour)
+	metadataRepo.records["docs/a"] = record
+
+	result, err := fileService.DeletePrefix(ctx, "docs")
+	if err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Key != "docs/a" || result.Failed[0].Err == "" {
+		t.Fatalf("Expected docs/a to be reported as failed, got %+v", result.Failed)
+	}
+	if len(result.Deleted) != 1 || result.Deleted[0] != "docs/b" {
+		t.Fatalf("Expected docs/b to be deleted, got %v", result.Deleted)
+	}
+	if _, ok := metadataRepo.records["docs/a"]; !ok {
+		t.Fatal("Locked object was deleted")
+	}
+}
+
+func TestDeletePrefix_EmptyPrefix(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 3)
+
+	result, err := fileService.DeletePrefix(context.Background(), "nothing")
+	if err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+	if len(result.Deleted) != 0 || len(result.Failed) != 0 {
+		t.Fatalf("Expected an empty result, got %+v", result)
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/zzenonn/zstore/internal/placement"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// withoutBuckets returns a service over the same metadata whose placer lacks the named buckets,
+// as if they had been removed from the config after the upload
+func withoutBuckets(t *testing.T, buckets []*objectstoretest.MemoryObjectRepository, metadataRepo *flakyMetadataRepository, removed ...string) *service.FileService {
+	t.Helper()
+	placer := placement.NewRoundRobinPlacer()
+	for _, bucket := range buckets {
+		if strings.Contains(strings.Join(removed, ","), bucket.GetBucketName()) {
+			continue
+		}
+		if err := placer.RegisterBucket(bucket.GetBucketName(), bucket); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fileService := service.NewFileService(placer, metadataRepo)
+	fileService.SetConcurrency(3)
+	return fileService
+}
+
+func TestDownloadFile_ReconstructsWithoutDeregisteredBuckets(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+
+	// Data shards 0 and 1 were placed on the two buckets that are no longer configured
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	reduced := withoutBuckets(t, buckets, metadataRepo, "bucket-0", "bucket-1")
+
+	var dest recordingWriterAt
+	if err := reduced.DownloadFile(ctx, "docs/x", &dest, true, true); err != nil {
+		t.Fatalf("DownloadFile failed: %v", err)
+	}
+	if !bytes.Equal(dest.data, data) {
+		t.Fatal("Downloaded data does not match the upload")
+	}
+
+	var streamed bytes.Buffer
+	if err := reduced.StreamFile(ctx, "docs/x", &streamed, true); err != nil {
+		t.Fatalf("StreamFile failed: %v", err)
+	}
+	if !bytes.Equal(streamed.Bytes(), data) {
+		t.Fatal("Streamed data does not match the upload")
+	}
+
+	warned := false
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.WarnLevel && strings.Contains(entry.Message, "bucket-0") && strings.Contains(entry.Message, "not registered") {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Error("Expected a warning naming the deregistered bucket")
+	}
+}
+
+func TestDownloadFile_FailsWhenTooManyBucketsAreDeregistered(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+
+	// Three missing shards are more than two parity shards can recover
+	reduced := withoutBuckets(t, buckets, metadataRepo, "bucket-0", "bucket-1", "bucket-2")
+	var dest recordingWriterAt
+	if err := reduced.DownloadFile(ctx, "docs/x", &dest, true, false); err == nil {
+		t.Fatal("Expected the download to fail with three of six shards unreachable")
+	}
+}
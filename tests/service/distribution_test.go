@@ -0,0 +1,58 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestDistribution_TalliesShardsPerBucket(t *testing.T) {
+	fileService, _, metadataRepo := newMemoryFileService(t, 4)
+	data := bytes.Repeat([]byte("distributed payload "), 64)
+	for _, key := range []string{"docs/a.txt", "docs/b.txt"} {
+		if err := fileService.UploadFile(context.Background(), key, bytes.NewReader(data), true, 2, 1, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A record pointing at a bucket that is no longer configured
+	legacy := metadataRepo.records["docs/a.txt"]
+	legacy.FileName = "legacy.txt"
+	legacy.ShardHashes = []domain.ShardStorage{{Hash: "h", Locations: []domain.Location{{StorageType: "s3", BucketName: "retired", Key: "docs/legacy.txt/h"}}}}
+	metadataRepo.records["docs/legacy.txt"] = legacy
+
+	distribution, err := fileService.Distribution(context.Background(), "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shardSize := metadataRepo.records["docs/a.txt"].ShardSize
+	if distribution.Objects != 3 || distribution.Shards != 7 || distribution.Bytes != 7*shardSize {
+		t.Errorf("Expected 3 objects with 7 shards, got %+v", distribution)
+	}
+
+	byBucket := make(map[string]service.BucketDistribution)
+	for _, bucket := range distribution.Buckets {
+		byBucket[bucket.Bucket] = bucket
+	}
+	for _, name := range []string{"bucket-0", "bucket-1", "bucket-2"} {
+		if bucket := byBucket[name]; bucket.Shards != 2 || bucket.StorageType != "memory" || math.Abs(bucket.Percent-200.0/7) > 0.01 {
+			t.Errorf("Expected 2 shards in %s, got %+v", name, bucket)
+		}
+	}
+	if bucket, ok := byBucket["bucket-3"]; !ok || bucket.Shards != 0 {
+		t.Errorf("Expected the empty registered bucket to be listed, got %+v", bucket)
+	}
+	if bucket := byBucket["retired"]; !bucket.Unregistered || bucket.Shards != 1 || bucket.StorageType != "s3" {
+		t.Errorf("Expected the retired bucket to be listed as unregistered, got %+v", bucket)
+	}
+	if last := distribution.Buckets[len(distribution.Buckets)-1]; last.Bucket != "bucket-3" {
+		t.Errorf("Expected buckets ordered by bytes, got %s last", last.Bucket)
+	}
+
+	if len(distribution.Providers) != 2 || distribution.Providers[0].StorageType != "memory" || distribution.Providers[0].Buckets != 4 || distribution.Providers[0].Shards != 6 {
+		t.Errorf("Unexpected provider totals %+v", distribution.Providers)
+	}
+}
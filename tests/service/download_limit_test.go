@@ -0,0 +1,89 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestSetMaxConcurrentDownloads_QueuesDownloadsBeyondTheLimit(t *testing.T) {
+	fileService, counter := newCountingFileService(t, 6)
+	fileService.SetDownloadConcurrency(1) // One shard transfer in flight per download
+	if err := fileService.SetMaxConcurrentDownloads(2, service.DownloadLimitQueue); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	for i := 0; i < 6; i++ {
+		if err := fileService.UploadFile(ctx, fmt.Sprintf("docs/%d", i), bytes.NewReader(data), true, 4, 2, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 6)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var dest recordingWriterAt
+			errs[i] = fileService.DownloadFile(ctx, fmt.Sprintf("docs/%d", i), &dest, true, false)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Download %d failed: %v", i, err)
+		}
+	}
+	if peak := counter.peak["download"]; peak != 2 {
+		t.Errorf("Expected 2 downloads in flight at most, got %d", peak)
+	}
+}
+
+func TestSetMaxConcurrentDownloads_RejectsDownloadsBeyondTheLimit(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 6)
+	if err := fileService.SetMaxConcurrentDownloads(1, "drop"); err == nil {
+		t.Fatal("Expected an unknown mode to be rejected")
+	}
+	if err := fileService.SetMaxConcurrentDownloads(1, service.DownloadLimitReject); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	held, release, err := fileService.AcquireDownloadSlot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/x", &dest, true, false); !stderrors.Is(err, errors.ErrTooManyDownloads) {
+		t.Fatalf("Expected the download to be rejected while the only slot is held, got %v", err)
+	}
+	var streamed bytes.Buffer
+	if err := fileService.StreamFile(ctx, "docs/x", &streamed, false); !stderrors.Is(err, errors.ErrTooManyDownloads) {
+		t.Fatalf("Expected the stream to be rejected while the only slot is held, got %v", err)
+	}
+
+	// The holder of a slot downloads with it instead of waiting for another
+	if err := fileService.DownloadFile(held, "docs/x", &dest, true, false); err != nil || !bytes.Equal(dest.data, data) {
+		t.Fatalf("Expected the slot holder to download, got %v", err)
+	}
+	release()
+	if err := fileService.StreamFile(ctx, "docs/x", &streamed, false); err != nil || !bytes.Equal(streamed.Bytes(), data) {
+		t.Fatalf("Expected the stream to succeed once the slot is released, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// newDRFileService returns a 6-bucket service writing DR copies to a memory:// bucket named after the test
+func newDRFileService(t *testing.T) (*service.FileService, []*objectstoretest.MemoryObjectRepository, service.DRTarget, *objectstoretest.MemoryObjectRepository) {
+	t.Helper()
+	fileService, buckets, _ := newMemoryFileService(t, 6)
+	fileService.SetDRService(service.NewRawFileService(objectstore.NewObjectRepositoryFactory(aws.Config{}, nil)))
+
+	target, err := service.ParseDRTarget("memory://dr-" + strings.ReplaceAll(t.Name(), "/", "-") + "/zstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fileService, buckets, target, objectstoretest.SharedMemoryRepository(target.Bucket)
+}
+
+func TestDRReplication_StoresWholeCopyAndRecordsIt(t *testing.T) {
+	fileService, _, target, drBucket := newDRFileService(t)
+	data := bytes.Repeat([]byte("critical data "), 1024)
+
+	ctx := service.WithDRReplication(context.Background(), target)
+	if err := fileService.UploadFile(ctx, "docs/ledger.db", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	if stored, ok := drBucket.Object("zstore/docs/ledger.db"); !ok || !bytes.Equal(stored, data) {
+		t.Fatalf("Expected the whole object in the DR bucket, got %d bytes (found %v)", len(stored), ok)
+	}
+	metadata, err := fileService.StatFile(context.Background(), "docs/ledger.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.DRCopy == nil || metadata.DRCopy.BucketName != target.Bucket || metadata.DRCopy.Key != "zstore/docs/ledger.db" || metadata.DRCopy.StorageType != "memory" {
+		t.Errorf("Expected the DR copy to be recorded, got %+v", metadata.DRCopy)
+	}
+
+	// Uploading the key again without DR replication removes the stale copy
+	if err := fileService.UploadFile(context.Background(), "docs/ledger.db", bytes.NewReader([]byte("newer")), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := drBucket.Object("zstore/docs/ledger.db"); ok {
+		t.Error("Expected the DR copy of the replaced object to be removed")
+	}
+}
+
+func TestRestoreWithDRFallback_UsesDRCopyWhenReconstructionFails(t *testing.T) {
+	fileService, buckets, target, _ := newDRFileService(t)
+	data := bytes.Repeat([]byte("defense in depth "), 1024)
+	ctx := service.WithDRReplication(context.Background(), target)
+	if err := fileService.UploadFile(ctx, "docs/ledger.db", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	// While the shards are readable the DR copy is not needed
+	var dest recordingWriterAt
+	fromDR, err := fileService.RestoreWithDRFallback(context.Background(), "docs/ledger.db", &dest, true, true)
+	if err != nil || fromDR || !bytes.Equal(dest.data, data) {
+		t.Fatalf("Expected a normal download, got fromDR=%v, %v", fromDR, err)
+	}
+
+	// Three of six buckets lost: a 4+2 object cannot be reconstructed
+	for _, bucket := range buckets[:3] {
+		bucket.FailWith(errBucketUnavailable)
+	}
+	if err := fileService.DownloadFile(context.Background(), "docs/ledger.db", &recordingWriterAt{}, true, true); !stderrors.Is(err, errors.ErrInsufficientShards) {
+		t.Fatalf("Expected reconstruction to fail, got %v", err)
+	}
+	dest = recordingWriterAt{}
+	fromDR, err = fileService.RestoreWithDRFallback(context.Background(), "docs/ledger.db", &dest, true, true)
+	if err != nil || !fromDR {
+		t.Fatalf("Expected the DR copy to be used, got fromDR=%v, %v", fromDR, err)
+	}
+	if !bytes.Equal(dest.data, data) {
+		t.Errorf("Restored %d bytes that do not match the %d uploaded", len(dest.data), len(data))
+	}
+}
+
+func TestRestoreWithDRFallback_RejectsCorruptDRCopy(t *testing.T) {
+	fileService, buckets, target, drBucket := newDRFileService(t)
+	data := bytes.Repeat([]byte("checked copy "), 1024)
+	ctx := service.WithDRReplication(context.Background(), target)
+	if err := fileService.UploadFile(ctx, "docs/ledger.db", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := bytes.Clone(data)
+	corrupt[0] ^= 0xff
+	drBucket.PutObject("zstore/docs/ledger.db", corrupt)
+	for _, bucket := range buckets[:3] {
+		bucket.FailWith(errBucketUnavailable)
+	}
+
+	dest := recordingWriterAt{}
+	if _, err := fileService.RestoreWithDRFallback(context.Background(), "docs/ledger.db", &dest, true, false); !stderrors.Is(err, errors.ErrFileIntegrityCheck) {
+		t.Errorf("Expected a corrupt DR copy to fail the integrity check, got %v", err)
+	}
+	if len(dest.data) != 0 {
+		t.Errorf("Expected nothing to be written from a corrupt DR copy, got %d bytes", len(dest.data))
+	}
+}
+
+func TestDRReplication_WithoutCopyOrService(t *testing.T) {
+	fileService, _, _, _ := newDRFileService(t)
+	if err := fileService.UploadFile(context.Background(), "docs/plain.txt", bytes.NewReader([]byte("no DR copy")), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileService.DownloadFromDR(context.Background(), "docs/plain.txt", &recordingWriterAt{}, true); !stderrors.Is(err, errors.ErrNoDRCopy) {
+		t.Errorf("Expected ErrNoDRCopy, got %v", err)
+	}
+
+	unconfigured, _, _ := newMemoryFileService(t, 6)
+	ctx := service.WithDRReplication(context.Background(), service.DRTarget{Type: objectstoretest.MemoryType, Bucket: "dr"})
+	if err := unconfigured.UploadFile(ctx, "docs/a.txt", bytes.NewReader([]byte("data")), true, 4, 2, 6); !stderrors.Is(err, errors.ErrDRNotConfigured) {
+		t.Errorf("Expected ErrDRNotConfigured, got %v", err)
+	}
+
+	if _, err := service.ParseDRTarget("dr-bucket"); err == nil {
+		t.Error("Expected a DR target without a scheme to be rejected")
+	}
+}
+
+func TestDeleteFile_RemovesDRCopy(t *testing.T) {
+	fileService, _, target, drBucket := newDRFileService(t)
+	ctx := service.WithDRReplication(context.Background(), target)
+	if err := fileService.UploadFile(ctx, "docs/ledger.db", bytes.NewReader([]byte("to be deleted")), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileService.DeleteFile(context.Background(), "docs/ledger.db"); err != nil {
+		t.Fatal(err)
+	}
+	if drBucket.Len() != 0 {
+		t.Errorf("Expected the DR copy to be deleted with the object, %d objects left", drBucket.Len())
+	}
+}
+
+func TestParseDRTarget_RoundTrips(t *testing.T) {
+	target, err := service.ParseDRTarget("memory://dr-bucket/zstore/critical/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.Bucket != "dr-bucket" || target.Prefix != "zstore/critical" {
+		t.Errorf("Unexpected target %+v", target)
+	}
+	if target.String() != "memory://dr-bucket/zstore/critical" {
+		t.Errorf("Expected the target to print as it was given, got %s", target)
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	stderrors "errors"
+	"fmt"
+	"hash/crc64"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestEffectiveDataShards_MinShardSizeBoundary(t *testing.T) {
+	testCases := []struct {
+		name         string
+		size         int64
+		dataShards   int
+		minShardSize int64
+		expected     int
+	}{
+		{"Policy disabled", 100, 4, 0, 4},
+		{"Shards exactly at threshold", 4096, 4, 1024, 4},
+		{"Shards one byte below threshold", 4092, 4, 1024, 3},
+		{"Shards just above threshold", 4097, 4, 1024, 4},
+		{"Reduced to fit threshold", 2048, 4, 1024, 2},
+		{"Smaller than threshold falls back to replication", 100, 4, 1024, 1},
+		{"Single data shard unchanged", 100, 1, 1024, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := service.EffectiveDataShards(tc.size, tc.dataShards, tc.minShardSize)
+			if got != tc.expected {
+				t.Errorf("EffectiveDataShards(%d, %d, %d) = %d, expected %d", tc.size, tc.dataShards, tc.minShardSize, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestShardFile_RecordsEffectiveParameters(t *testing.T) {
+	data := make([]byte, 2048)
+	rand.Read(data)
+
+	dataShards := service.EffectiveDataShards(int64(len(data)), 4, 1024)
+	metadata, shards, err := service.ShardFile(data, dataShards, 2)
+	if err != nil {
+		t.Fatalf("ShardFile failed: %v", err)
+	}
+
+	if metadata.DataShards != 2 || metadata.ParityShards != 2 {
+		t.Errorf("Expected 2+2 layout in metadata, got %d+%d", metadata.DataShards, metadata.ParityShards)
+	}
+	if metadata.ShardSize < 1024 {
+		t.Errorf("Expected shard size >= 1024, got %d", metadata.ShardSize)
+	}
+
+	reconstructed, err := service.ReconstructFile(shards, metadata)
+	if err != nil {
+		t.Fatalf("ReconstructFile failed: %v", err)
+	}
+	if !bytes.Equal(reconstructed, data) {
+		t.Error("Reconstructed data does not match original")
+	}
+}
+
+func TestReconstructFileFromPaths_MissingLeadingShards(t *testing.T) {
+	data := make([]byte, 4000)
+	rand.Read(data)
+
+	metadata, shards, err := service.ShardFile(data, 4, 2)
+	if err != nil {
+		t.Fatalf("ShardFile failed: %v", err)
+	}
+
+	// Shards 0 and 1 are missing; paths stay indexed by shard position
+	paths := make([]string, len(shards))
+	for i := 2; i < len(shards); i++ {
+		paths[i] = filepath.Join(t.TempDir(), fmt.Sprintf("shard_%d", i))
+		if err := os.WriteFile(paths[i], shards[i], 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reconstructed, err := service.ReconstructFileFromPaths(paths, metadata)
+	if err != nil {
+		t.Fatalf("ReconstructFileFromPaths failed: %v", err)
+	}
+	if !bytes.Equal(reconstructed, data) {
+		t.Error("Reconstructed data does not match original")
+	}
+}
+
+func TestReconstructVerifiedFile_SubtlyCorruptShard(t *testing.T) {
+	data := make([]byte, 4096)
+	rand.Read(data)
+
+	// Split may alias the input buffer, so shard a copy
+	metadata, shards, err := service.ShardFile(bytes.Clone(data), 4, 2)
+	if err != nil {
+		t.Fatalf("ShardFile failed: %v", err)
+	}
+
+	// Corrupt data shard 1 and record the corrupt content's CRC, so the shard
+	// passes its own check but the reconstructed file does not
+	shards[1][10] ^= 0xff
+	table := crc64.MakeTable(crc64.ISO)
+	metadata.ShardHashes[1].Hash = fmt.Sprintf("%016x", crc64.Checksum(shards[1], table))
+
+	naive, err := service.ReconstructFile(shards, metadata)
+	if err != nil {
+		t.Fatalf("ReconstructFile failed: %v", err)
+	}
+	if bytes.Equal(naive, data) {
+		t.Fatal("Expected naive reconstruction to be corrupt")
+	}
+
+	recovered, err := service.ReconstructVerifiedFile(shards, metadata)
+	if err != nil {
+		t.Fatalf("ReconstructVerifiedFile failed: %v", err)
+	}
+	if !bytes.Equal(recovered, data) {
+		t.Error("Recovered data does not match original")
+	}
+}
+
+func TestReconstructVerifiedFile_GivesUpWithoutRedundancy(t *testing.T) {
+	data := make([]byte, 4096)
+	rand.Read(data)
+
+	metadata, shards, err := service.ShardFile(bytes.Clone(data), 4, 2)
+	if err != nil {
+		t.Fatalf("ShardFile failed: %v", err)
+	}
+
+	// Only the four data shards remain and one is corrupt: no subset can fix it
+	shards[4], shards[5] = nil, nil
+	shards[0][0] ^= 0xff
+
+	if _, err := service.ReconstructVerifiedFile(shards, metadata); !stderrors.Is(err, errors.ErrFileIntegrityCheck) {
+		t.Errorf("Expected ErrFileIntegrityCheck, got %v", err)
+	}
+}
+
+func TestShardFileConcurrent_SameShardsForAnyGoroutineCount(t *testing.T) {
+	data := make([]byte, 3<<20+17)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, _, err := service.ShardFileConcurrent(data, 4, 2, 1)
+	if err != nil {
+		t.Fatalf("ShardFileConcurrent failed: %v", err)
+	}
+	for _, goroutines := range []int{0, 2, 16, 384} {
+		metadata, shards, err := service.ShardFileConcurrent(data, 4, 2, goroutines)
+		if err != nil {
+			t.Fatalf("ShardFileConcurrent with %d goroutines failed: %v", goroutines, err)
+		}
+		for i, shard := range metadata.ShardHashes {
+			if shard.Hash != expected.ShardHashes[i].Hash {
+				t.Errorf("Shard %d differs with %d goroutines", i, goroutines)
+			}
+		}
+		reconstructed, err := service.ReconstructFile(shards, metadata)
+		if err != nil || !bytes.Equal(reconstructed, data) {
+			t.Fatalf("Shards encoded with %d goroutines do not reconstruct the data: %v", goroutines, err)
+		}
+	}
+}
+
+// BenchmarkShardFile_EncoderGoroutines compares encoder concurrency settings on large inputs
+// "auto" is the default; "384" is the Reed-Solomon library's own default used before
+// goroutines were sized from the shard size. Run with -benchmem on a multi-core machine;
+// the 1GB input is skipped with -short.
+func BenchmarkShardFile_EncoderGoroutines(b *testing.B) {
+	settings := []struct {
+		name       string
+		goroutines int
+	}{
+		{"auto", 0},
+		{"1", 1},
+		{fmt.Sprintf("gomaxprocs=%d", runtime.GOMAXPROCS(0)), runtime.GOMAXPROCS(0)},
+		{"384", 384},
+	}
+
+	for _, size := range []int{100 << 20, 1 << 30} {
+		if size > 100<<20 && testing.Short() {
+			continue
+		}
+		data := make([]byte, size)
+		if _, err := rand.Read(data); err != nil {
+			b.Fatal(err)
+		}
+		for _, setting := range settings {
+			b.Run(fmt.Sprintf("size=%dMB/goroutines=%s", size>>20, setting.name), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				for i := 0; i < b.N; i++ {
+					if _, _, err := service.ShardFileConcurrent(data, 4, 2, setting.goroutines); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestEstimateUpload_CountsParityPaddingAndMirrors(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 3)
+	fileService.SetMirrorFactor(2)
+	rates := map[string]service.ProviderRates{"memory": {StoragePerGBMonth: 1, PerThousandPuts: 5}}
+
+	estimate, err := fileService.EstimateUpload(1001, 4, 2, rates)
+	if err != nil {
+		t.Fatalf("EstimateUpload failed: %v", err)
+	}
+
+	// 1001 bytes over 4 data shards: 251-byte shards, 3 bytes of padding
+	if estimate.ShardSize != 251 || estimate.PaddingBytes != 3 {
+		t.Errorf("Expected 251-byte shards with 3 bytes of padding, got %d and %d", estimate.ShardSize, estimate.PaddingBytes)
+	}
+	// 6 shards, 2 copies each
+	if estimate.StoredBytes != 251*6*2 || estimate.Puts != 12 {
+		t.Errorf("Expected %d bytes in 12 PUTs, got %d bytes in %d PUTs", 251*6*2, estimate.StoredBytes, estimate.Puts)
+	}
+	if math.Abs(estimate.RequestCost-12*5.0/1000) > 1e-9 {
+		t.Errorf("Expected request cost %.6f, got %.6f", 12*5.0/1000, estimate.RequestCost)
+	}
+	if math.Abs(estimate.StorageCost-float64(251*6*2)/(1<<30)) > 1e-12 {
+		t.Errorf("Unexpected storage cost %.12f", estimate.StorageCost)
+	}
+
+	if len(estimate.Buckets) != 3 {
+		t.Fatalf("Expected 3 buckets in the breakdown, got %d", len(estimate.Buckets))
+	}
+	for i, bucket := range estimate.Buckets {
+		if expected := "bucket-" + string(rune('0'+i)); bucket.BucketName != expected {
+			t.Errorf("Expected bucket %s at position %d, got %s", expected, i, bucket.BucketName)
+		}
+		if bucket.Shards != 4 || bucket.Bytes != 4*251 {
+			t.Errorf("Expected 4 shard copies in %s, got %d (%d bytes)", bucket.BucketName, bucket.Shards, bucket.Bytes)
+		}
+	}
+
+	if len(estimate.Providers) != 1 || !estimate.Providers[0].Priced || estimate.Providers[0].Buckets != 3 {
+		t.Errorf("Expected one priced provider spanning 3 buckets, got %+v", estimate.Providers)
+	}
+}
+
+func TestEstimateUpload_UnpricedProviderAndInvalidLayout(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 6)
+
+	estimate, err := fileService.EstimateUpload(4096, 4, 2, nil)
+	if err != nil {
+		t.Fatalf("EstimateUpload failed: %v", err)
+	}
+	if estimate.StorageCost != 0 || estimate.RequestCost != 0 || estimate.Providers[0].Priced {
+		t.Errorf("Expected an unpriced zero-cost estimate, got %+v", estimate.Providers)
+	}
+
+	if _, err := fileService.EstimateUpload(4096, 200, 100, nil); err == nil {
+		t.Error("Expected a layout above the shard limit to be rejected")
+	}
+	if _, err := fileService.EstimateUpload(0, 4, 2, nil); err == nil {
+		t.Error("Expected an empty upload to be rejected")
+	}
+}
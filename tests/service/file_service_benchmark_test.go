@@ -281,9 +281,10 @@ func BenchmarkFileService_ErasureCoded_ConcurrencyComparison(b *testing.B) {
 	data := make([]byte, 1024*1024)
 	rand.Read(data)
 
+	// Uploads and downloads are swept independently; each has its own limit
 	for _, concurrency := range concurrencyLevels {
-		b.Run(fmt.Sprintf("ErasureCoded_Concurrency_%d", concurrency), func(b *testing.B) {
-			fileService.SetConcurrency(concurrency)
+		b.Run(fmt.Sprintf("ErasureCoded_UploadConcurrency_%d", concurrency), func(b *testing.B) {
+			fileService.SetUploadConcurrency(concurrency)
 
 			b.ResetTimer()
 			b.ReportAllocs()
@@ -293,7 +294,7 @@ func BenchmarkFileService_ErasureCoded_ConcurrencyComparison(b *testing.B) {
 				key := "benchmark/concurrency-test"
 				reader := bytes.NewReader(data)
 				
-				err := fileService.UploadFile(context.Background(), key, reader, true, 4, 2, concurrency)
+				err := fileService.UploadFile(context.Background(), key, reader, true, 4, 2, 0)
 				if err != nil {
 					b.Fatalf("UploadFile failed: %v", err)
 				}
@@ -302,4 +303,72 @@ func BenchmarkFileService_ErasureCoded_ConcurrencyComparison(b *testing.B) {
 			}
 		})
 	}
-}
\ No newline at end of file
+
+	key := "benchmark/download-concurrency-test"
+	if err := fileService.UploadFile(context.Background(), key, bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		b.Fatalf("UploadFile failed: %v", err)
+	}
+	defer fileService.DeleteFile(context.Background(), key)
+
+	for _, concurrency := range concurrencyLevels {
+		b.Run(fmt.Sprintf("ErasureCoded_DownloadConcurrency_%d", concurrency), func(b *testing.B) {
+			fileService.SetDownloadConcurrency(concurrency)
+
+			b.ResetTimer()
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+
+			for i := 0; i < b.N; i++ {
+				tempFile, err := os.CreateTemp("", "benchmark_*.tmp")
+				if err != nil {
+					b.Fatalf("Failed to create temp file: %v", err)
+				}
+
+				err = fileService.DownloadFile(context.Background(), key, tempFile, true, false)
+				tempFile.Close()
+				os.Remove(tempFile.Name())
+				if err != nil {
+					b.Fatalf("DownloadFile failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFileService_ErasureCoded_DeleteFile(b *testing.B) {
+	fileService, _, _ := setupTestServices(b)
+
+	shardConfigs := []struct {
+		name         string
+		dataShards   int
+		parityShards int
+	}{
+		{"4+2", 4, 2},
+		{"8+4", 8, 4},
+		{"16+8", 16, 8},
+	}
+
+	data := make([]byte, 100*1024)
+	rand.Read(data)
+
+	for _, sc := range shardConfigs {
+		b.Run(sc.name, func(b *testing.B) {
+			key := "benchmark/delete-test-file"
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				err := fileService.UploadFile(context.Background(), key, bytes.NewReader(data), true, sc.dataShards, sc.parityShards, 3)
+				if err != nil {
+					b.Fatalf("Setup failed: %v", err)
+				}
+				b.StartTimer()
+
+				err = fileService.DeleteFile(context.Background(), key)
+				if err != nil {
+					b.Fatalf("DeleteFile failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+func TestDownloadShard_ReturnsShardAsStored(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	location := metadataRepo.records["docs/x"].ShardHashes[3].Locations[0]
+
+	// Corrupt the stored shard; get-shard must hand it back untouched rather than reject or repair it
+	stored, _ := buckets[3].Object(location.Key)
+	stored[0] ^= 0xff
+	buckets[3].PutObject(location.Key, stored)
+
+	var dest recordingWriterAt
+	shard, err := fileService.DownloadShard(ctx, "docs/x", 3, 0, &dest, true)
+	if err != nil {
+		t.Fatalf("DownloadShard failed: %v", err)
+	}
+	if !bytes.Equal(dest.data, stored) {
+		t.Error("Downloaded shard differs from the stored bytes")
+	}
+	if shard.Index != 3 || shard.BucketName != "bucket-3" || shard.Key != location.Key || shard.Size != int64(len(stored)) {
+		t.Errorf("Unexpected shard description %+v", shard)
+	}
+	if shard.Hash != metadataRepo.records["docs/x"].ShardHashes[3].Hash {
+		t.Errorf("Expected the recorded hash, got %q", shard.Hash)
+	}
+}
+
+func TestDownloadShard_OutOfRange(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 6)
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+
+	for _, tc := range []struct{ index, copy int }{{6, 0}, {-1, 0}, {0, 1}} {
+		var dest recordingWriterAt
+		if _, err := fileService.DownloadShard(ctx, "docs/x", tc.index, tc.copy, &dest, true); !stderrors.Is(err, errors.ErrShardIndexOutOfRange) {
+			t.Errorf("Shard %d copy %d: expected ErrShardIndexOutOfRange, got %v", tc.index, tc.copy, err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLatency keeps every started download in flight long enough to be counted before the download is canceled
+const testLatency = 20 * time.Millisecond
+
+// totalDownloads sums the download attempts of every bucket
+func totalDownloads(buckets []*providerObjectRepository) int {
+	total := 0
+	for _, bucket := range buckets {
+		total += int(bucket.downloads.Load())
+	}
+	return total
+}
+
+func TestSetInitialDownloadBuffer_StartsOnlyTheNeededShards(t *testing.T) {
+	fileService, buckets, _ := newTwoProviderFileService(t, "s3", "s3", "s3", "s3", "s3", "s3", "s3", "s3")
+	fileService.SetConcurrency(8)
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(data), true, 4, 4, 8); err != nil {
+		t.Fatal(err)
+	}
+	for _, bucket := range buckets {
+		bucket.latency = testLatency
+	}
+
+	for _, tc := range []struct {
+		buffer    int
+		downloads int
+	}{
+		{-1, 8}, // The whole concurrency: every shard is started
+		{0, 4},
+		{2, 6},
+		{10, 8}, // Capped by the concurrency
+	} {
+		for _, bucket := range buckets {
+			bucket.downloads.Store(0)
+		}
+		fileService.SetInitialDownloadBuffer(tc.buffer)
+		var dest recordingWriterAt
+		if err := fileService.DownloadFile(context.Background(), "docs/report.txt", &dest, true, true); err != nil {
+			t.Fatalf("buffer %d: %v", tc.buffer, err)
+		}
+		if !bytes.Equal(dest.data, data) {
+			t.Errorf("buffer %d: reconstructed data does not match the upload", tc.buffer)
+		}
+		if got := totalDownloads(buckets); got != tc.downloads {
+			t.Errorf("buffer %d: expected %d shard downloads, got %d", tc.buffer, tc.downloads, got)
+		}
+	}
+
+	// A first batch capped below the needed shards keeps starting shards as they complete
+	for _, bucket := range buckets {
+		bucket.downloads.Store(0)
+	}
+	fileService.SetConcurrency(2)
+	fileService.SetInitialDownloadBuffer(0)
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(context.Background(), "docs/report.txt", &dest, true, true); err != nil {
+		t.Fatalf("Expected a download capped at 2 shards at a time to complete, got %v", err)
+	}
+	if got := totalDownloads(buckets); got < 4 || got > 5 {
+		t.Errorf("Expected 4 or 5 shard downloads at a concurrency of 2, got %d", got)
+	}
+}
+
+func TestSetInitialDownloadBuffer_FailedShardsAreReplaced(t *testing.T) {
+	// Shards 0 and 2 are on the provider that is down
+	fileService, buckets, outages := newTwoProviderFileService(t, "s3", "gcs", "s3", "gcs", "gcs", "gcs")
+	fileService.SetConcurrency(6)
+	fileService.SetInitialDownloadBuffer(0)
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	for _, bucket := range buckets {
+		bucket.latency = testLatency
+	}
+
+	outages["s3"].Store(true)
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(context.Background(), "docs/report.txt", &dest, true, true); err != nil {
+		t.Fatalf("Expected the failed shards to be replaced by the remaining ones, got %v", err)
+	}
+	if !bytes.Equal(dest.data, data) {
+		t.Error("Reconstructed data does not match the upload")
+	}
+	if got := totalDownloads(buckets); got != 6 {
+		t.Errorf("Expected the 4 initial downloads and 2 replacements, got %d downloads", got)
+	}
+}
+
+// BenchmarkDownloadFile_InitialDownloadBuffer compares starting every shard the concurrency
+// allows (over-fetch) with starting only the needed shards plus a buffer (tight fetch)
+// Run with -benchtime to taste; shards/op is the number of shard downloads started per object.
+func BenchmarkDownloadFile_InitialDownloadBuffer(b *testing.B) {
+	providers := make([]string, 12)
+	for i := range providers {
+		providers[i] = "s3"
+	}
+	fileService, buckets, _ := newTwoProviderFileService(b, providers...)
+	fileService.SetConcurrency(12)
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1<<20/16)
+	data = append(data, "distinct tail"...)
+	if err := fileService.UploadFile(context.Background(), "bench/object", bytes.NewReader(data), true, 4, 8, 12); err != nil {
+		b.Fatal(err)
+	}
+	for _, bucket := range buckets {
+		bucket.latency = 5 * time.Millisecond
+	}
+
+	for _, buffer := range []int{-1, 0, 1, 2} {
+		name := fmt.Sprintf("buffer=%d", buffer)
+		if buffer < 0 {
+			name = "over-fetch"
+		}
+		b.Run(name, func(b *testing.B) {
+			fileService.SetInitialDownloadBuffer(buffer)
+			for _, bucket := range buckets {
+				bucket.downloads.Store(0)
+			}
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				var dest recordingWriterAt
+				if err := fileService.DownloadFile(context.Background(), "bench/object", &dest, true, false); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(totalDownloads(buckets))/float64(b.N), "shards/op")
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+func TestCaseInsensitiveKeys_DownloadWithDifferentCase(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	fileService.SetCaseInsensitiveKeys(true)
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "Photos/Summer.JPG", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+
+	record, ok := metadataRepo.records["photos/summer.jpg"]
+	if !ok {
+		t.Fatal("Expected the metadata to be stored under the lower-cased key")
+	}
+	if record.DisplayKey != "Photos/Summer.JPG" || record.DisplayName() != "Photos/Summer.JPG" {
+		t.Errorf("Expected the upload's key to be kept for display, got %q", record.DisplayKey)
+	}
+	for _, bucket := range buckets {
+		for key := range bucket.Objects() {
+			if key != strings.ToLower(key) {
+				t.Errorf("Shard stored under %s, expected a lower-cased key", key)
+			}
+		}
+	}
+
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "PHOTOS/summer.jpg", &dest, true, true); err != nil {
+		t.Fatalf("DownloadFile with different case failed: %v", err)
+	}
+	if !bytes.Equal(dest.data, data) {
+		t.Fatal("Downloaded data does not match the upload")
+	}
+
+	files, err := fileService.ListFiles(ctx, "PHOTOS")
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one file listed under PHOTOS, got %d (%v)", len(files), err)
+	}
+
+	// Uploading with yet another case replaces the object instead of adding one
+	if err := fileService.UploadFile(ctx, "photos/SUMMER.jpg", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	if len(metadataRepo.records) != 1 || metadataRepo.records["photos/summer.jpg"].DisplayKey != "photos/SUMMER.jpg" {
+		t.Fatalf("Expected a single object displayed as photos/SUMMER.jpg, got %d records", len(metadataRepo.records))
+	}
+
+	if err := fileService.DeleteFile(ctx, "Photos/Summer.jpg"); err != nil {
+		t.Fatalf("DeleteFile with different case failed: %v", err)
+	}
+	if len(metadataRepo.records) != 0 {
+		t.Fatal("Expected the object to be deleted")
+	}
+}
+
+func TestCaseInsensitiveKeys_DefaultIsCaseSensitive(t *testing.T) {
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "Photos/Summer.JPG", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	if record := metadataRepo.records["Photos/Summer.JPG"]; record.DisplayKey != "" {
+		t.Errorf("Expected no display key when keys are case-sensitive, got %q", record.DisplayKey)
+	}
+
+	var dest recordingWriterAt
+	err := fileService.DownloadFile(ctx, "photos/summer.jpg", &dest, true, true)
+	if !stderrors.Is(err, errors.ErrMetadataNotFound) {
+		t.Fatalf("Expected a download with different case to find nothing, got %v", err)
+	}
+}
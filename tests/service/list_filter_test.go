@@ -0,0 +1,74 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestFilterByThresholds(t *testing.T) {
+	files := func() []domain.ObjectMetadata {
+		legacyShards := make([]domain.ShardStorage, 6)
+		for i := range legacyShards {
+			legacyShards[i].Locations = []domain.Location{{BucketName: "bucket"}}
+		}
+		return []domain.ObjectMetadata{
+			{Prefix: "docs", FileName: "tiny.txt", OriginalSize: 100, StorageOverhead: 61.4},
+			{Prefix: "docs", FileName: "small.txt", OriginalSize: 900, StorageOverhead: 2.2},
+			{Prefix: "docs", FileName: "large.bin", OriginalSize: 64 << 20, StorageOverhead: 1.5},
+			// Recorded before the overhead was: 4+2 shards of 512 bytes for 2000 bytes
+			{Prefix: "docs", FileName: "legacy.bin", OriginalSize: 2000, ShardSize: 512, ParityShards: 2, ShardHashes: legacyShards},
+		}
+	}
+	names := func(files []domain.ObjectMetadata) []string {
+		var names []string
+		for _, file := range files {
+			names = append(names, file.FileName)
+		}
+		return names
+	}
+
+	for _, tc := range []struct {
+		name       string
+		thresholds service.ListThresholds
+		expected   []string
+	}{
+		{"none", service.ListThresholds{}, []string{"tiny.txt", "small.txt", "large.bin", "legacy.bin"}},
+		{"min overhead", service.ListThresholds{MinOverhead: 2.0}, []string{"tiny.txt", "small.txt"}},
+		{"max size", service.ListThresholds{MaxSize: 1 << 10}, []string{"tiny.txt", "small.txt"}},
+		{"overhead range", service.ListThresholds{MinOverhead: 1.5, MaxOverhead: 2.0}, []string{"large.bin", "legacy.bin"}},
+		{"combined", service.ListThresholds{MinOverhead: 2.0, MinSize: 500}, []string{"small.txt"}},
+	} {
+		if got := names(service.FilterByThresholds(files(), tc.thresholds)); !slices.Equal(got, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestFilterByThresholds_UsesContentSize(t *testing.T) {
+	files := []domain.ObjectMetadata{
+		// 64MB compressed to 300 bytes before sharding
+		{Prefix: "logs", FileName: "app.log", OriginalSize: 300, Transforms: []string{service.TransformZstd}, PlainSize: 64 << 20},
+		{Prefix: "logs", FileName: "small.log", OriginalSize: 900},
+	}
+	if got := service.FilterByThresholds(files, service.ListThresholds{MinSize: 1 << 20}); len(got) != 1 || got[0].FileName != "app.log" {
+		t.Errorf("Expected the size filter to use the uploaded size, got %v", got)
+	}
+}
+
+func TestListThresholds_Validate(t *testing.T) {
+	for _, thresholds := range []service.ListThresholds{
+		{MinOverhead: -1},
+		{MinOverhead: 3, MaxOverhead: 2},
+		{MinSize: 2048, MaxSize: 1024},
+	} {
+		if err := thresholds.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", thresholds)
+		}
+	}
+	if err := (service.ListThresholds{MinOverhead: 2, MaxSize: 1024}).Validate(); err != nil {
+		t.Errorf("Expected valid thresholds to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	stderrors "errors"
+	"slices"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestSplitListPattern(t *testing.T) {
+	for _, tc := range []struct {
+		key, prefix, pattern string
+	}{
+		{"photos/2024/*.jpg", "photos/2024", "*.jpg"},
+		{"photos/2024/", "photos/2024", ""},
+		{"photos/2024", "photos/2024", ""},
+		{"photos/img_00?.png", "photos", "img_00?.png"},
+		{"photos/[ab]*", "photos", "[ab]*"},
+		{"*.txt", "", "*.txt"},
+	} {
+		prefix, pattern, err := service.SplitListPattern(tc.key)
+		if err != nil || prefix != tc.prefix || pattern != tc.pattern {
+			t.Errorf("SplitListPattern(%q) = %q, %q, %v; expected %q, %q", tc.key, prefix, pattern, err, tc.prefix, tc.pattern)
+		}
+	}
+}
+
+func TestSplitListPattern_RejectsWildcardDirectoriesAndBadPatterns(t *testing.T) {
+	for _, key := range []string{"photos/*/a.jpg", "photos/[a-"} {
+		if _, _, err := service.SplitListPattern(key); !stderrors.Is(err, errors.ErrInvalidListPattern) {
+			t.Errorf("SplitListPattern(%q): expected ErrInvalidListPattern, got %v", key, err)
+		}
+	}
+}
+
+func TestMatchFileNames(t *testing.T) {
+	files := func() []domain.ObjectMetadata {
+		var files []domain.ObjectMetadata
+		for _, name := range []string{"a.jpg", "b.JPG", "c.png", "img_001.png", "img_010.png", "notes.txt"} {
+			files = append(files, domain.ObjectMetadata{Prefix: "photos", FileName: name})
+		}
+		return files
+	}
+	names := func(files []domain.ObjectMetadata) []string {
+		var names []string
+		for _, file := range files {
+			names = append(names, file.FileName)
+		}
+		return names
+	}
+
+	for pattern, expected := range map[string][]string{
+		"*.jpg":       {"a.jpg"},
+		"*.[jJ][pP]G": {"b.JPG"},
+		"img_00?.png": {"img_001.png"},
+		"[a-c].*":     {"a.jpg", "b.JPG", "c.png"},
+		"":            {"a.jpg", "b.JPG", "c.png", "img_001.png", "img_010.png", "notes.txt"},
+		"*.gif":       nil,
+	} {
+		if got := names(service.MatchFileNames(files(), pattern)); !slices.Equal(got, expected) {
+			t.Errorf("MatchFileNames(%q) = %v, expected %v", pattern, got, expected)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/placement"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func fileNames(files []domain.ObjectMetadata) string {
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = file.FileName
+	}
+	return strings.Join(names, ",")
+}
+
+func TestListFilesSorted(t *testing.T) {
+	ctx := context.Background()
+	metadataRepo := newFlakyMetadataRepository()
+	base := time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+	for _, record := range []domain.ObjectMetadata{
+		{Prefix: "docs", FileName: "b.txt", OriginalSize: 300, CreatedAt: base},
+		{Prefix: "docs", FileName: "a.txt", OriginalSize: 100, CreatedAt: base.Add(time.Hour)},
+		{Prefix: "docs", FileName: "c.txt", OriginalSize: 200, CreatedAt: base.Add(-time.Hour)},
+		{Prefix: "other", FileName: "d.txt", OriginalSize: 1},
+	} {
+		metadataRepo.CreateMetadata(ctx, record)
+	}
+	fileService := service.NewFileService(placement.NewRoundRobinPlacer(), metadataRepo)
+
+	tests := []struct {
+		sortBy   domain.MetadataSortField
+		order    domain.SortOrder
+		expected string
+	}{
+		{domain.SortBySize, domain.SortAscending, "a.txt,c.txt,b.txt"},
+		{domain.SortBySize, domain.SortDescending, "b.txt,c.txt,a.txt"},
+		{domain.SortByDate, domain.SortAscending, "c.txt,b.txt,a.txt"},
+		{domain.SortByDate, domain.SortDescending, "a.txt,b.txt,c.txt"},
+	}
+	for _, tt := range tests {
+		files, err := fileService.ListFilesSorted(ctx, "docs", tt.sortBy, tt.order)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.sortBy, tt.order, err)
+		}
+		if got := fileNames(files); got != tt.expected {
+			t.Errorf("%s %s: expected %s, got %s", tt.sortBy, tt.order, tt.expected, got)
+		}
+	}
+
+	for _, invalid := range [][2]string{{"name", "asc"}, {"size", "up"}} {
+		_, err := fileService.ListFilesSorted(ctx, "docs", domain.MetadataSortField(invalid[0]), domain.SortOrder(invalid[1]))
+		if !stderrors.Is(err, errors.ErrInvalidListSort) {
+			t.Errorf("%v: expected ErrInvalidListSort, got %v", invalid, err)
+		}
+	}
+}
+
+// sortingMetadataRepository orders listings itself, like the DynamoDB created_at index
+type sortingMetadataRepository struct {
+	*flakyMetadataRepository
+	sortedCalls int
+}
+
+func (r *sortingMetadataRepository) ListMetadataSorted(ctx context.Context, prefix string, sortBy domain.MetadataSortField, order domain.SortOrder) ([]domain.ObjectMetadata, error) {
+	r.sortedCalls++
+	return nil, nil
+}
+
+func TestListFilesSorted_UsesStoreOrdering(t *testing.T) {
+	metadataRepo := &sortingMetadataRepository{flakyMetadataRepository: newFlakyMetadataRepository()}
+	cache := service.NewCachedMetadataRepository(metadataRepo, 10, time.Minute)
+	fileService := service.NewFileService(placement.NewRoundRobinPlacer(), cache)
+
+	if _, err := fileService.ListFilesSorted(context.Background(), "docs", domain.SortByDate, domain.SortDescending); err != nil {
+		t.Fatal(err)
+	}
+	if metadataRepo.sortedCalls != 1 {
+		t.Errorf("Expected the store to order the listing through the cache, got %d calls", metadataRepo.sortedCalls)
+	}
+}
+
+func TestUploadFile_RecordsCreatedAt(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+
+	before := time.Now().Add(-time.Second)
+	if err := fileService.UploadFile(ctx, "docs/a.txt", bytes.NewReader([]byte("created at test payload")), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	createdAt := metadataRepo.records["docs/a.txt"].CreatedAt
+	if createdAt.Before(before) || createdAt.After(time.Now()) {
+		t.Fatalf("Expected upload time to be recorded, got %v", createdAt)
+	}
+
+	// Re-encoding keeps the original upload time
+	if err := fileService.ReEncode(ctx, "docs/a.txt", 3, 1, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := metadataRepo.records["docs/a.txt"].CreatedAt; !got.Equal(createdAt) {
+		t.Errorf("Expected re-encode to preserve created_at %v, got %v", createdAt, got)
+	}
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestLocateFile_GroupsShardsByBucket(t *testing.T) {
+	fileService, _, metadataRepo := newMemoryFileService(t, 3)
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	// Shards wrap around the three buckets
+	location, err := fileService.LocateFile(context.Background(), "docs/report.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []service.BucketShards{
+		{BucketName: "bucket-0", StorageType: "memory", Shards: []int{0, 3}},
+		{BucketName: "bucket-1", StorageType: "memory", Shards: []int{1, 4}},
+		{BucketName: "bucket-2", StorageType: "memory", Shards: []int{2, 5}},
+	}
+	if location.Key != "docs/report.txt" || !reflect.DeepEqual(location.Buckets, expected) {
+		t.Errorf("Expected %v, got %+v", expected, location)
+	}
+	if len(location.Unstored) != 0 {
+		t.Errorf("Expected every shard to be stored, got unstored %v", location.Unstored)
+	}
+
+	// A shard without a location is reported separately
+	metadata := metadataRepo.records["docs/report.txt"]
+	metadata.ShardHashes[4].Locations = nil
+	metadataRepo.records["docs/report.txt"] = metadata
+	location, err = fileService.LocateFile(context.Background(), "docs/report.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(location.Unstored, []int{4}) || !reflect.DeepEqual(location.Buckets[1].Shards, []int{1}) {
+		t.Errorf("Expected shard 4 to be unstored, got %+v", location)
+	}
+}
+
+func TestLocateFile_MissingObject(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 3)
+	if _, err := fileService.LocateFile(context.Background(), "docs/missing.txt"); err == nil {
+		t.Error("Expected an error for an object without metadata")
+	}
+}
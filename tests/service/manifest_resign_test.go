@@ -0,0 +1,98 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// storedManifest parses the manifest of key from the manifest bucket
+func storedManifest(t *testing.T, bucket *objectstoretest.MemoryObjectRepository, key string) domain.Manifest {
+	t.Helper()
+	var manifest domain.Manifest
+	body, _ := bucket.Object(service.ManifestKey(key))
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		t.Fatalf("Manifest of %s unreadable: %v", key, err)
+	}
+	return manifest
+}
+
+func TestResignManifests_RotatesKey(t *testing.T) {
+	ctx := context.Background()
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	fileService, buckets, _ := newMemoryFileService(t, 3)
+	fileService.SetManifestBucket("bucket-0")
+	fileService.SetManifestSigningKey(oldKey)
+	fileService.SetWriteManifest(true)
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	for _, key := range []string{"docs/a", "docs/b", "other/c"} {
+		if err := fileService.UploadFile(ctx, key, bytes.NewReader(data), true, 2, 1, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := fileService.ResignManifests(ctx, "docs", &oldKey.PublicKey, newKey)
+	if err != nil {
+		t.Fatalf("ResignManifests failed: %v", err)
+	}
+	if len(result.Resigned) != 2 || len(result.Current) != 0 || len(result.Failed) != 0 {
+		t.Fatalf("Expected both manifests under docs to be re-signed, got %+v", result)
+	}
+	for _, key := range []string{"docs/a", "docs/b"} {
+		if err := service.VerifyManifest(storedManifest(t, buckets[0], key), &newKey.PublicKey); err != nil {
+			t.Errorf("Expected %s to verify against the new key: %v", key, err)
+		}
+	}
+	if err := service.VerifyManifest(storedManifest(t, buckets[0], "other/c"), &oldKey.PublicKey); err != nil {
+		t.Errorf("Expected manifests outside the prefix to keep the old signature: %v", err)
+	}
+
+	// A rerun finds nothing left to do
+	result, err = fileService.ResignManifests(ctx, "docs", &oldKey.PublicKey, newKey)
+	if err != nil || len(result.Current) != 2 || len(result.Resigned) != 0 {
+		t.Errorf("Expected both manifests to be current on a rerun, got %+v (%v)", result, err)
+	}
+}
+
+func TestResignManifests_RejectsUnverifiedManifests(t *testing.T) {
+	ctx := context.Background()
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	fileService, buckets, _ := newMemoryFileService(t, 3)
+	fileService.SetManifestBucket("bucket-0")
+	fileService.SetManifestSigningKey(oldKey)
+	fileService.SetWriteManifest(true)
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/a", bytes.NewReader(data), true, 2, 1, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Point a shard elsewhere without re-signing
+	tampered := storedManifest(t, buckets[0], "docs/a")
+	tampered.Metadata.ShardHashes[0].Locations[0].BucketName = "attacker"
+	body, _ := json.Marshal(tampered)
+	buckets[0].PutObject(service.ManifestKey("docs/a"), body)
+
+	result, err := fileService.ResignManifests(ctx, "docs", &oldKey.PublicKey, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Key != "docs/a" || len(result.Resigned) != 0 {
+		t.Fatalf("Expected the tampered manifest to be rejected, got %+v", result)
+	}
+	if stored, _ := buckets[0].Object(service.ManifestKey("docs/a")); !bytes.Equal(stored, body) {
+		t.Error("Expected the rejected manifest to be left unchanged")
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	stderrors "errors"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func testMetadata() domain.ObjectMetadata {
+	return domain.ObjectMetadata{
+		Prefix:       "docs",
+		FileName:     "report.pdf",
+		OriginalSize: 4096,
+		ShardSize:    1024,
+		DataShards:   4,
+		ParityShards: 2,
+		ShardHashes: []domain.ShardStorage{
+			{Hash: "a1", Locations: []domain.Location{{StorageType: "s3", BucketName: "primary", Key: "docs/report.pdf/a1"}}},
+			{Hash: "b2", Locations: []domain.Location{{StorageType: "gcs", BucketName: "secondary", Key: "docs/report.pdf/b2"}}},
+		},
+	}
+}
+
+func TestManifest_SignAndVerify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := service.BuildManifest(testMetadata(), key)
+	if err != nil {
+		t.Fatalf("BuildManifest failed: %v", err)
+	}
+	if manifest.Version != domain.ManifestVersion || manifest.Signature == "" {
+		t.Fatalf("Expected a signed version %d manifest, got %+v", domain.ManifestVersion, manifest)
+	}
+	if err := service.VerifyManifest(manifest, &key.PublicKey); err != nil {
+		t.Errorf("VerifyManifest failed on untouched manifest: %v", err)
+	}
+
+	// Any change to the metadata invalidates the signature
+	manifest.Metadata.ShardHashes[1].Locations[0].BucketName = "attacker"
+	if err := service.VerifyManifest(manifest, &key.PublicKey); !stderrors.Is(err, errors.ErrManifestSignature) {
+		t.Errorf("Expected ErrManifestSignature for tampered manifest, got %v", err)
+	}
+}
+
+func TestManifest_UnsignedFailsVerification(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := service.BuildManifest(testMetadata(), nil)
+	if err != nil {
+		t.Fatalf("BuildManifest failed: %v", err)
+	}
+	if manifest.Signature != "" {
+		t.Errorf("Expected unsigned manifest without a key, got signature %q", manifest.Signature)
+	}
+	if err := service.VerifyManifest(manifest, &key.PublicKey); !stderrors.Is(err, errors.ErrManifestSignature) {
+		t.Errorf("Expected ErrManifestSignature for unsigned manifest, got %v", err)
+	}
+	if got := service.ManifestKey("docs/report.pdf"); got != "docs/report.pdf/.manifest.json" {
+		t.Errorf("Unexpected manifest key %q", got)
+	}
+}
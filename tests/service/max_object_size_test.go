@@ -0,0 +1,125 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+)
+
+// countingReader counts the bytes read through it and hides any Seek method of the source
+type countingReader struct {
+	r    io.Reader
+	read int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += int64(n)
+	return n, err
+}
+
+// countingSeeker is a seekable reader that counts the bytes read through it
+type countingSeeker struct {
+	*bytes.Reader
+	read int64
+}
+
+func (c *countingSeeker) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.read += int64(n)
+	return n, err
+}
+
+func TestUploadFile_MaxObjectSizeBoundary(t *testing.T) {
+	ctx := context.Background()
+	const limit = 4096
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	fileService.SetMaxObjectSize(limit)
+
+	atLimit := []byte(strings.Repeat("0123456789abcdef", limit/16))
+	overLimit := append(append([]byte{}, atLimit...), 'x')
+
+	for name, reader := range map[string]func([]byte) io.Reader{
+		"seekable":     func(data []byte) io.Reader { return bytes.NewReader(data) },
+		"non-seekable": func(data []byte) io.Reader { return &countingReader{r: bytes.NewReader(data)} },
+	} {
+		if err := fileService.UploadFile(ctx, "docs/"+name+"-at", reader(atLimit), true, 4, 2, 6); err != nil {
+			t.Errorf("%s: expected an object of exactly the limit to upload, got %v", name, err)
+		}
+		err := fileService.UploadFile(ctx, "docs/"+name+"-over", reader(overLimit), true, 4, 2, 6)
+		if !stderrors.Is(err, errors.ErrObjectTooLarge) {
+			t.Errorf("%s: expected ErrObjectTooLarge one byte over the limit, got %v", name, err)
+		}
+		if _, ok := metadataRepo.records["docs/"+name+"-over"]; ok {
+			t.Errorf("%s: expected nothing recorded for a rejected upload", name)
+		}
+	}
+}
+
+func TestUploadFile_MaxObjectSizeStopsReading(t *testing.T) {
+	ctx := context.Background()
+	const limit = 4096
+	fileService, _, _ := newMemoryFileService(t, 6)
+	fileService.SetMaxObjectSize(limit)
+
+	// A seekable input is measured without being read
+	seekable := &countingSeeker{Reader: bytes.NewReader(make([]byte, 10*limit))}
+	if err := fileService.UploadFile(ctx, "docs/big", seekable, true, 4, 2, 6); !stderrors.Is(err, errors.ErrObjectTooLarge) {
+		t.Fatalf("Expected ErrObjectTooLarge, got %v", err)
+	}
+	if seekable.read != 0 {
+		t.Errorf("Expected an oversized seekable input not to be read, read %d bytes", seekable.read)
+	}
+
+	// A stream is abandoned once it crosses the limit
+	stream := &countingReader{r: io.LimitReader(neverEnding('z'), 1<<30)}
+	if err := fileService.UploadFile(ctx, "docs/stream", stream, true, 4, 2, 6); !stderrors.Is(err, errors.ErrObjectTooLarge) {
+		t.Fatalf("Expected ErrObjectTooLarge, got %v", err)
+	}
+	if stream.read > limit+1 {
+		t.Errorf("Expected at most %d bytes read from the stream, read %d", limit+1, stream.read)
+	}
+}
+
+// neverEnding is an endless stream of one byte
+type neverEnding byte
+
+func (b neverEnding) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(b)
+	}
+	return len(p), nil
+}
+
+func TestUploadFile_SizeHintOfNonSeekableReader(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, _ := newMemoryFileService(t, 6)
+	fileService.SetMaxObjectSize(4096)
+
+	// An oversized hint fails before anything is read
+	tooLarge := &countingReader{r: bytes.NewReader(make([]byte, 5000))}
+	err := fileService.UploadFile(ctx, "docs/big", objectstore.WithSizeHint(tooLarge, 5000), true, 4, 2, 3)
+	if !stderrors.Is(err, errors.ErrObjectTooLarge) {
+		t.Fatalf("Expected ErrObjectTooLarge, got %v", err)
+	}
+	if tooLarge.read != 0 {
+		t.Errorf("Expected nothing to be read, read %d bytes", tooLarge.read)
+	}
+
+	// A hint shorter than the stream still uploads every byte
+	data := []byte(strings.Repeat("0123456789", 400))
+	source := &countingReader{r: bytes.NewReader(data)}
+	if err := fileService.UploadFile(ctx, "docs/x", objectstore.WithSizeHint(source, 1000), true, 4, 2, 3); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/x", &dest, true, true); err != nil || !bytes.Equal(dest.data, data) {
+		t.Fatalf("Expected the whole stream to be stored, got %d bytes (%v)", len(dest.data), err)
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"testing"
+)
+
+func TestFileService_MemoryRepositoriesRoundTrip(t *testing.T) {
+	fileService, buckets, _ := newMemoryFileService(t, 6)
+	data := bytes.Repeat([]byte("erasure coded in memory "), 512)
+
+	if err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	for _, bucket := range buckets {
+		if bucket.Len() != 1 {
+			t.Errorf("Expected one shard in %s, got %d", bucket.GetBucketName(), bucket.Len())
+		}
+	}
+
+	// Losing as many buckets as there are parity shards leaves the object readable
+	buckets[0].FailWith(stderrors.New("bucket unavailable"))
+	buckets[3].FailWith(stderrors.New("bucket unavailable"))
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(context.Background(), "docs/report.txt", &dest, true, true); err != nil || !bytes.Equal(dest.data, data) {
+		t.Fatalf("Expected the object to be reconstructed from the remaining buckets, got %v", err)
+	}
+	buckets[0].FailWith(nil)
+	buckets[3].FailWith(nil)
+
+	if err := fileService.DeleteFile(context.Background(), "docs/report.txt"); err != nil {
+		t.Fatal(err)
+	}
+	for _, bucket := range buckets {
+		if bucket.Len() != 0 {
+			t.Errorf("Expected %s to be empty after the delete, got %d objects", bucket.GetBucketName(), bucket.Len())
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+var errThrottled = stderrors.New("ProvisionedThroughputExceededException")
+
+// flakyMetadataRepository is an in-memory metadata store that can simulate an outage
+type flakyMetadataRepository struct {
+	records  map[string]domain.ObjectMetadata
+	down     bool
+	getCalls int
+}
+
+func newFlakyMetadataRepository() *flakyMetadataRepository {
+	return &flakyMetadataRepository{records: make(map[string]domain.ObjectMetadata)}
+}
+
+func (f *flakyMetadataRepository) CreateMetadata(ctx context.Context, metadata domain.ObjectMetadata) (domain.ObjectMetadata, error) {
+	if f.down {
+		return domain.ObjectMetadata{}, errThrottled
+	}
+	f.records[metadata.Prefix+"/"+metadata.FileName] = metadata
+	return metadata, nil
+}
+
+func (f *flakyMetadataRepository) GetMetadata(ctx context.Context, prefix, fileName string) (domain.ObjectMetadata, error) {
+	f.getCalls++
+	if f.down {
+		return domain.ObjectMetadata{}, errThrottled
+	}
+	metadata, ok := f.records[prefix+"/"+fileName]
+	if !ok {
+		return domain.ObjectMetadata{}, errors.ErrMetadataNotFound
+	}
+	return metadata, nil
+}
+
+func (f *flakyMetadataRepository) ListMetadataByPrefix(ctx context.Context, prefix string) ([]domain.ObjectMetadata, error) {
+	if f.down {
+		return nil, errThrottled
+	}
+	var metadataList []domain.ObjectMetadata
+	for _, metadata := range f.records {
+		if metadata.Prefix == prefix {
+			metadataList = append(metadataList, metadata)
+		}
+	}
+	return metadataList, nil
+}
+
+func (f *flakyMetadataRepository) ListPrefixes(ctx context.Context, prefix string) ([]string, error) {
+	if f.down {
+		return nil, errThrottled
+	}
+	found := make(map[string]bool)
+	for _, metadata := range f.records {
+		if domain.PrefixWithin(metadata.Prefix, prefix) {
+			found[metadata.Prefix] = true
+		}
+	}
+	prefixes := make([]string, 0, len(found))
+	for itemPrefix := range found {
+		prefixes = append(prefixes, itemPrefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes, nil
+}
+
+func (f *flakyMetadataRepository) UpdateMetadata(ctx context.Context, metadata domain.ObjectMetadata) (domain.ObjectMetadata, error) {
+	return f.CreateMetadata(ctx, metadata)
+}
+
+func (f *flakyMetadataRepository) DeleteMetadata(ctx context.Context, prefix, fileName string) error {
+	if f.down {
+		return errThrottled
+	}
+	delete(f.records, prefix+"/"+fileName)
+	return nil
+}
+
+func cachedObject(fileName string, size int64) domain.ObjectMetadata {
+	return domain.ObjectMetadata{Prefix: "docs", FileName: fileName, OriginalSize: size}
+}
+
+func TestCachedMetadataRepository_ServesCacheDuringOutage(t *testing.T) {
+	ctx := context.Background()
+	inner := newFlakyMetadataRepository()
+	cache := service.NewCachedMetadataRepository(inner, 10, time.Minute)
+
+	if _, err := cache.CreateMetadata(ctx, cachedObject("a.txt", 1)); err != nil {
+		t.Fatal(err)
+	}
+	inner.records["docs/b.txt"] = cachedObject("b.txt", 2) // Written by another client, never read here
+
+	inner.down = true
+
+	// Hit: recently written object is still readable
+	metadata, err := cache.GetMetadata(ctx, "docs", "a.txt")
+	if err != nil || metadata.OriginalSize != 1 {
+		t.Errorf("Expected cached metadata during outage, got %+v, %v", metadata, err)
+	}
+
+	// Miss: object never seen by the cache surfaces the store error
+	if _, err := cache.GetMetadata(ctx, "docs", "b.txt"); !stderrors.Is(err, errThrottled) {
+		t.Errorf("Expected store error for uncached object, got %v", err)
+	}
+}
+
+func TestCachedMetadataRepository_PrefersFreshStoreData(t *testing.T) {
+	ctx := context.Background()
+	inner := newFlakyMetadataRepository()
+	cache := service.NewCachedMetadataRepository(inner, 10, time.Minute)
+
+	cache.CreateMetadata(ctx, cachedObject("a.txt", 1))
+	inner.records["docs/a.txt"] = cachedObject("a.txt", 99) // Overwritten by another client
+
+	metadata, err := cache.GetMetadata(ctx, "docs", "a.txt")
+	if err != nil || metadata.OriginalSize != 99 {
+		t.Errorf("Expected fresh store data, got %+v, %v", metadata, err)
+	}
+	if inner.getCalls != 1 {
+		t.Errorf("Expected one store read, got %d", inner.getCalls)
+	}
+}
+
+func TestCachedMetadataRepository_Invalidation(t *testing.T) {
+	ctx := context.Background()
+	inner := newFlakyMetadataRepository()
+	cache := service.NewCachedMetadataRepository(inner, 10, time.Minute)
+
+	cache.CreateMetadata(ctx, cachedObject("a.txt", 1))
+	cache.UpdateMetadata(ctx, cachedObject("a.txt", 2))
+
+	inner.down = true
+	metadata, err := cache.GetMetadata(ctx, "docs", "a.txt")
+	if err != nil || metadata.OriginalSize != 2 {
+		t.Errorf("Expected updated metadata from cache, got %+v, %v", metadata, err)
+	}
+
+	// A delete removes the cached copy even if the store call fails
+	cache.DeleteMetadata(ctx, "docs", "a.txt")
+	if _, err := cache.GetMetadata(ctx, "docs", "a.txt"); err == nil {
+		t.Error("Expected deleted object to be uncached")
+	}
+
+	// A store that reports "not found" also drops the cached copy
+	inner.down = false
+	cache.CreateMetadata(ctx, cachedObject("c.txt", 3))
+	delete(inner.records, "docs/c.txt")
+	if _, err := cache.GetMetadata(ctx, "docs", "c.txt"); !stderrors.Is(err, errors.ErrMetadataNotFound) {
+		t.Errorf("Expected ErrMetadataNotFound, got %v", err)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected empty cache, got %d entries", cache.Len())
+	}
+}
+
+func TestCachedMetadataRepository_EvictionAndTTL(t *testing.T) {
+	ctx := context.Background()
+	inner := newFlakyMetadataRepository()
+	cache := service.NewCachedMetadataRepository(inner, 2, 50*time.Millisecond)
+
+	cache.CreateMetadata(ctx, cachedObject("a.txt", 1))
+	cache.CreateMetadata(ctx, cachedObject("b.txt", 2))
+	cache.GetMetadata(ctx, "docs", "a.txt") // a.txt becomes most recently used
+	cache.CreateMetadata(ctx, cachedObject("c.txt", 3))
+
+	inner.down = true
+	if _, err := cache.GetMetadata(ctx, "docs", "b.txt"); err == nil {
+		t.Error("Expected least recently used entry to be evicted")
+	}
+	if _, err := cache.GetMetadata(ctx, "docs", "a.txt"); err != nil {
+		t.Errorf("Expected recently used entry to survive eviction, got %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, err := cache.GetMetadata(ctx, "docs", "c.txt"); err == nil {
+		t.Error("Expected expired entry not to be served")
+	}
+}
+
+// transactingMetadataRepository applies transactions to an in-memory store, all or nothing
+type transactingMetadataRepository struct {
+	*flakyMetadataRepository
+	transactions int
+}
+
+func (r *transactingMetadataRepository) Transact(ctx context.Context, puts []domain.ObjectMetadata, deletes []domain.MetadataKey) error {
+	r.transactions++
+	if r.down {
+		return errThrottled
+	}
+	for _, metadata := range puts {
+		r.records[metadata.Prefix+"/"+metadata.FileName] = metadata
+	}
+	for _, key := range deletes {
+		delete(r.records, key.Prefix+"/"+key.FileName)
+	}
+	return nil
+}
+
+func TestMetadataDecorators_ForwardTransactions(t *testing.T) {
+	ctx := context.Background()
+	store := &transactingMetadataRepository{flakyMetadataRepository: newFlakyMetadataRepository()}
+	repo := service.NewCachedMetadataRepository(service.NewRetryingMetadataRepository(store, 3, 0), 10, time.Hour)
+	old := cachedObject("old.txt", 42)
+	if _, err := repo.CreateMetadata(ctx, old); err != nil {
+		t.Fatal(err)
+	}
+
+	moved := cachedObject("new.txt", 42)
+	if err := repo.Transact(ctx, []domain.ObjectMetadata{moved}, []domain.MetadataKey{old.Key()}); err != nil {
+		t.Fatalf("Transact failed: %v", err)
+	}
+	if store.transactions != 1 || len(store.records) != 1 {
+		t.Fatalf("Expected one transaction moving the record, got %d transactions and %d records", store.transactions, len(store.records))
+	}
+
+	// The cache follows the transaction: during an outage only the new key is served
+	store.down = true
+	if _, err := repo.GetMetadata(ctx, "docs", "new.txt"); err != nil {
+		t.Errorf("Expected the moved record to be cached, got %v", err)
+	}
+	if _, err := repo.GetMetadata(ctx, "docs", "old.txt"); err == nil {
+		t.Error("Expected the deleted record to be dropped from the cache")
+	}
+
+	plain := service.NewCachedMetadataRepository(newFlakyMetadataRepository(), 10, time.Hour)
+	if err := plain.Transact(ctx, []domain.ObjectMetadata{moved}, nil); !stderrors.Is(err, errors.ErrNotImplemented) {
+		t.Errorf("Expected a store without transactions to fail with ErrNotImplemented, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// throttlingMetadataRepository throttles the first writes and reads it receives
+type throttlingMetadataRepository struct {
+	*flakyMetadataRepository
+	writeThrottles int // Writes left to throttle
+	readThrottles  int // Reads left to throttle
+	createCalls    int
+}
+
+func throttle(remaining *int) error {
+	if *remaining > 0 {
+		*remaining--
+		return fmt.Errorf("%w: ProvisionedThroughputExceededException", errors.ErrMetadataThrottled)
+	}
+	return nil
+}
+
+func (t *throttlingMetadataRepository) CreateMetadata(ctx context.Context, metadata domain.ObjectMetadata) (domain.ObjectMetadata, error) {
+	t.createCalls++
+	if err := throttle(&t.writeThrottles); err != nil {
+		return domain.ObjectMetadata{}, err
+	}
+	return t.flakyMetadataRepository.CreateMetadata(ctx, metadata)
+}
+
+func (t *throttlingMetadataRepository) GetMetadata(ctx context.Context, prefix, fileName string) (domain.ObjectMetadata, error) {
+	if err := throttle(&t.readThrottles); err != nil {
+		return domain.ObjectMetadata{}, err
+	}
+	return t.flakyMetadataRepository.GetMetadata(ctx, prefix, fileName)
+}
+
+// newThrottledFileService returns a file service whose metadata store throttles the first writes
+func newThrottledFileService(t *testing.T, writeThrottles, maxAttempts int) (*service.FileService, *throttlingMetadataRepository) {
+	t.Helper()
+	placer, _ := objectstoretest.NewRoundRobinPlacer(t, 6)
+	throttled := &throttlingMetadataRepository{flakyMetadataRepository: newFlakyMetadataRepository(), writeThrottles: writeThrottles}
+	retrying := service.NewRetryingMetadataRepository(throttled, maxAttempts, time.Millisecond)
+	return service.NewFileService(placer, retrying), throttled
+}
+
+func TestRetryingMetadataRepository_UploadSurvivesThrottling(t *testing.T) {
+	ctx := context.Background()
+	fileService, throttled := newThrottledFileService(t, 2, 5)
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatalf("Expected the upload to ride out two throttled metadata writes, got %v", err)
+	}
+	if throttled.createCalls != 3 {
+		t.Errorf("Expected 3 metadata write attempts, got %d", throttled.createCalls)
+	}
+
+	throttled.readThrottles = 1
+	var out bytes.Buffer
+	if err := fileService.StreamFile(ctx, "docs/x", &out, true); err != nil || !bytes.Equal(out.Bytes(), data) {
+		t.Errorf("Expected the download to retry a throttled metadata read, got %v", err)
+	}
+}
+
+func TestRetryingMetadataRepository_GivesUpAfterMaxAttempts(t *testing.T) {
+	throttled := &throttlingMetadataRepository{flakyMetadataRepository: newFlakyMetadataRepository(), writeThrottles: 10}
+	retrying := service.NewRetryingMetadataRepository(throttled, 3, time.Millisecond)
+
+	_, err := retrying.CreateMetadata(context.Background(), domain.ObjectMetadata{Prefix: "docs", FileName: "x"})
+	if !stderrors.Is(err, errors.ErrMetadataThrottled) {
+		t.Fatalf("Expected a throttling error once retries run out, got %v", err)
+	}
+	if throttled.createCalls != 3 {
+		t.Errorf("Expected 3 metadata write attempts, got %d", throttled.createCalls)
+	}
+}
+
+func TestRetryingMetadataRepository_OtherErrorsAreNotRetried(t *testing.T) {
+	inner := newFlakyMetadataRepository()
+	retrying := service.NewRetryingMetadataRepository(inner, 5, time.Millisecond)
+
+	if _, err := retrying.GetMetadata(context.Background(), "docs", "missing"); !stderrors.Is(err, errors.ErrMetadataNotFound) {
+		t.Fatalf("Expected not found, got %v", err)
+	}
+	if inner.getCalls != 1 {
+		t.Errorf("Expected a single lookup for a missing object, got %d", inner.getCalls)
+	}
+}
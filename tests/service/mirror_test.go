@@ -0,0 +1,116 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func uploadMirrored(t *testing.T, mirrorFactor int) (*service.FileService, []*objectstoretest.MemoryObjectRepository, *flakyMetadataRepository, []byte) {
+	t.Helper()
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	fileService.SetMirrorFactor(mirrorFactor)
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatalf("Expected mirrored upload to succeed, got %v", err)
+	}
+	return fileService, buckets, metadataRepo, data
+}
+
+func TestUploadFile_MirrorFactorStoresCopiesInDistinctBuckets(t *testing.T) {
+	_, buckets, metadataRepo, _ := uploadMirrored(t, 2)
+
+	metadata := metadataRepo.records["docs/report.txt"]
+	for i, shard := range metadata.ShardHashes {
+		locations := shard.Locations
+		if len(locations) != 2 {
+			t.Fatalf("Expected 2 copies of shard %d, got %d", i, len(locations))
+		}
+		if locations[0].BucketName == locations[1].BucketName {
+			t.Errorf("Expected copies of shard %d in different buckets, both in %s", i, locations[0].BucketName)
+		}
+	}
+
+	stored := 0
+	for _, bucket := range buckets {
+		stored += bucket.Len()
+	}
+	if stored != 12 {
+		t.Errorf("Expected 12 stored shard copies, got %d", stored)
+	}
+}
+
+func TestDownloadFile_ReadsMirrorWhenPrimaryIsLost(t *testing.T) {
+	fileService, buckets, _, data := uploadMirrored(t, 2)
+
+	// Losing three buckets exceeds the parity count, so only the mirrors can save the object
+	for _, i := range []int{0, 2, 4} {
+		buckets[i].DeletePrefix(context.Background(), "")
+	}
+
+	tempFile, err := os.CreateTemp(t.TempDir(), "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempFile.Close()
+	if err := fileService.DownloadFile(context.Background(), "docs/report.txt", tempFile, true, true); err != nil {
+		t.Fatalf("Expected download from mirrors to succeed, got %v", err)
+	}
+	downloaded, err := os.ReadFile(tempFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Error("Expected downloaded data to match the upload")
+	}
+}
+
+func TestUploadFile_MirrorCopyFailureIsNotAShardFailure(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	fileService.SetMirrorFactor(2)
+	fileService.SetMaxShardFailures(0)
+	buckets[3].FailWith(errBucketUnavailable)
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatalf("Expected upload to succeed while every shard has a copy, got %v", err)
+	}
+	for i, shard := range metadataRepo.records["docs/report.txt"].ShardHashes {
+		if len(shard.Locations) == 0 {
+			t.Errorf("Expected shard %d to have a stored copy", i)
+		}
+		for _, location := range shard.Locations {
+			if location.BucketName == "bucket-3" {
+				t.Errorf("Shard %d recorded a copy in the failing bucket", i)
+			}
+		}
+	}
+}
+
+func TestUploadFile_SingleCopyHasNoMirrors(t *testing.T) {
+	_, _, metadataRepo, _ := uploadMirrored(t, 1)
+
+	for i, shard := range metadataRepo.records["docs/report.txt"].ShardHashes {
+		if len(shard.Locations) != 1 {
+			t.Errorf("Expected shard %d to have a single location, got %v", i, shard.Locations)
+		}
+	}
+}
+
+func TestUploadFile_MirrorFactorAboveBucketCount(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 2)
+	fileService.SetMirrorFactor(3)
+
+	err := fileService.UploadFile(context.Background(), "docs/report.txt", unreadableReader{t}, true, 1, 1, 1)
+	if !stderrors.Is(err, errors.ErrMirrorFactor) {
+		t.Errorf("Expected ErrMirrorFactor, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// failingWriter accepts limit bytes and then fails every write
+type failingWriter struct {
+	limit   int
+	written int
+}
+
+var errDestinationFull = stderrors.New("destination full")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.written+len(p) > w.limit {
+		return 0, errDestinationFull
+	}
+	w.written += len(p)
+	return len(p), nil
+}
+
+// newMultiDownloadService uploads data as "restore/object" and returns the service with a count of shard downloads
+func newMultiDownloadService(t *testing.T, data []byte) (*service.FileService, *atomic.Int32) {
+	t.Helper()
+	fileService, _, _ := newMemoryFileService(t, 6)
+	fileService.SetWriteBufferSize(1024) // Several chunks, so a failure can land mid-write
+	if err := fileService.UploadFile(context.Background(), "restore/object", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	var downloads atomic.Int32
+	fileService.SetShardObserver(func(timing service.ShardTiming) {
+		if timing.Operation == service.ShardDownload {
+			downloads.Add(1)
+		}
+	})
+	return fileService, &downloads
+}
+
+func TestDownloadFileMulti_WritesEveryDestination(t *testing.T) {
+	data := bytes.Repeat([]byte("fan-out restore "), 1024)
+	fileService, downloads := newMultiDownloadService(t, data)
+
+	var disk, mirror bytes.Buffer
+	if err := fileService.DownloadFileMulti(context.Background(), "restore/object", true, true, &disk, &mirror); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(disk.Bytes(), data) || !bytes.Equal(mirror.Bytes(), data) {
+		t.Error("Expected both destinations to receive the whole object")
+	}
+	if got := downloads.Load(); got > 6 {
+		t.Errorf("Expected the object to be reconstructed once (at most 6 shard downloads), got %d", got)
+	}
+}
+
+func TestDownloadFileMulti_AbortsOnDestinationFailure(t *testing.T) {
+	data := bytes.Repeat([]byte("fan-out restore "), 1024)
+	fileService, _ := newMultiDownloadService(t, data)
+
+	broken := &failingWriter{limit: 4096}
+	var disk bytes.Buffer
+	err := fileService.DownloadFileMulti(context.Background(), "restore/object", true, false, &disk, broken)
+	var destErr *service.DestinationError
+	if !stderrors.As(err, &destErr) || destErr.Index != 1 || !stderrors.Is(err, errDestinationFull) {
+		t.Fatalf("Expected the second destination's failure, got %v", err)
+	}
+	if disk.Len() >= len(data) {
+		t.Errorf("Expected writes to the healthy destination to stop, got all %d bytes", disk.Len())
+	}
+}
+
+func TestDownloadFileMulti_ContinuesPastDestinationFailure(t *testing.T) {
+	data := bytes.Repeat([]byte("fan-out restore "), 1024)
+	fileService, _ := newMultiDownloadService(t, data)
+
+	broken := &failingWriter{limit: 4096}
+	var disk bytes.Buffer
+	ctx := service.WithContinueOnDestinationError(context.Background())
+	err := fileService.DownloadFileMulti(ctx, "restore/object", true, false, broken, &disk)
+	var destErr *service.DestinationError
+	if !stderrors.As(err, &destErr) || destErr.Index != 0 {
+		t.Fatalf("Expected the first destination's failure to be reported, got %v", err)
+	}
+	if !bytes.Equal(disk.Bytes(), data) {
+		t.Errorf("Expected the healthy destination to receive the whole object, got %d of %d bytes", disk.Len(), len(data))
+	}
+	if broken.written != 4096 {
+		t.Errorf("Expected the failed destination to stop receiving writes, got %d bytes", broken.written)
+	}
+}
+
+func TestDownloadFileMulti_RequiresDestination(t *testing.T) {
+	fileService, _ := newMultiDownloadService(t, []byte("tiny"))
+	if err := fileService.DownloadFileMulti(context.Background(), "restore/object", true, false); err == nil {
+		t.Error("Expected a download without destinations to fail")
+	}
+}
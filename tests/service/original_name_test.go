@@ -0,0 +1,114 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadNamedFile_RecordsOriginalName(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+
+	if err := fileService.UploadNamedFile(ctx, "x/report", "/home/user/report.pdf", bytes.NewReader([]byte("%PDF-1.7 payload")), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := fileService.StatFile(ctx, "x/report")
+	if err != nil || metadata.OriginalName != "report.pdf" {
+		t.Fatalf("Expected original name report.pdf, got %q (%v)", metadata.OriginalName, err)
+	}
+
+	if err := fileService.ReEncode(ctx, "x/report", 3, 1, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := metadataRepo.records["x/report"].OriginalName; got != "report.pdf" {
+		t.Errorf("Expected re-encode to keep the original name, got %q", got)
+	}
+
+	// Plain uploads record no name, so downloads fall back to the key
+	if err := fileService.UploadFile(ctx, "x/plain", bytes.NewReader([]byte("plain payload")), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if got := metadataRepo.records["x/plain"].OriginalName; got != "" {
+		t.Errorf("Expected no original name for UploadFile, got %q", got)
+	}
+}
+
+// fileInfo is a fs.FileInfo for a local file that does not exist on disk
+type fileInfo struct {
+	name    string
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (f fileInfo) Name() string       { return f.name }
+func (f fileInfo) Size() int64        { return 0 }
+func (f fileInfo) Mode() fs.FileMode  { return f.mode }
+func (f fileInfo) ModTime() time.Time { return f.modTime }
+func (f fileInfo) IsDir() bool        { return false }
+func (f fileInfo) Sys() any           { return nil }
+
+func TestUploadLocalFile_PreservesModeAndModTime(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	modTime := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	info := fileInfo{name: "backup.sh", mode: 0o750, modTime: modTime}
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadLocalFile(ctx, "x/backup", info, bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	record := metadataRepo.records["x/backup"]
+	if record.OriginalName != "backup.sh" || record.FileMode != 0o750 || !record.ModTime.Equal(modTime) {
+		t.Fatalf("Expected name, mode and mtime to be recorded, got %q %v %v", record.OriginalName, record.FileMode, record.ModTime)
+	}
+
+	// Re-encoding keeps the attributes
+	if err := fileService.ReEncode(ctx, "x/backup", 3, 1, true); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "backup.sh")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileService.RestoreFileAttributes(ctx, "x/backup", path); err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm() != 0o750 || !stat.ModTime().Equal(modTime) {
+		t.Errorf("Expected mode 0750 and mtime %v, got %v and %v", modTime, stat.Mode().Perm(), stat.ModTime())
+	}
+}
+
+func TestRestoreFileAttributes_StreamUploadLeavesFileUnchanged(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	if err := fileService.UploadFile(ctx, "x/stream", bytes.NewReader([]byte("streamed payload")), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if record := metadataRepo.records["x/stream"]; record.FileMode != 0 || !record.ModTime.IsZero() {
+		t.Fatalf("Expected no attributes for a stream upload, got %v %v", record.FileMode, record.ModTime)
+	}
+
+	path := filepath.Join(t.TempDir(), "stream")
+	if err := os.WriteFile(path, []byte("streamed payload"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.Stat(path)
+	if err := fileService.RestoreFileAttributes(ctx, "x/stream", path); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(path)
+	if after.Mode() != before.Mode() || !after.ModTime().Equal(before.ModTime()) {
+		t.Errorf("Expected attributes to stay unchanged, got %v/%v from %v/%v", after.Mode(), after.ModTime(), before.Mode(), before.ModTime())
+	}
+}
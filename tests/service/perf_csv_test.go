@@ -0,0 +1,144 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// readPerfCSV parses the CSV written by a ShardTimingCSV into rows keyed by column name
+func readPerfCSV(t *testing.T, data []byte) []map[string]string {
+	t.Helper()
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) == 0 {
+		t.Fatal("Expected a CSV header")
+	}
+	want := "time,operation,shard,bucket,provider,bytes,duration_ms,mb_per_s,verified,error"
+	if header := strings.Join(records[0], ","); header != want {
+		t.Fatalf("Expected header %q, got %q", want, header)
+	}
+
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := map[string]string{}
+		for i, column := range records[0] {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestShardTimingCSV_RecordsUploadsAndDownloads(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 6)
+	var out bytes.Buffer
+	timings, err := fileService.NewShardTimingCSV(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileService.SetShardObserver(timings.Observe)
+
+	data := bytes.Repeat([]byte("timed shard transfer "), 1024)
+	if err := fileService.UploadFile(context.Background(), "perf/object", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(context.Background(), "perf/object", &dest, true, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := timings.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]int{}
+	for _, row := range readPerfCSV(t, out.Bytes()) {
+		counts[row["operation"]]++
+		if row["provider"] != string(objectstoretest.MemoryType) || !strings.HasPrefix(row["bucket"], "bucket-") {
+			t.Errorf("Expected a memory bucket and its provider, got %v", row)
+		}
+		if size, err := strconv.ParseInt(row["bytes"], 10, 64); err != nil || size <= 0 {
+			t.Errorf("Expected the shard size, got %q", row["bytes"])
+		}
+		if _, err := strconv.ParseFloat(row["duration_ms"], 64); err != nil {
+			t.Errorf("Expected a numeric duration, got %q", row["duration_ms"])
+		}
+		if row["error"] != "" {
+			t.Errorf("Expected no error, got %q", row["error"])
+		}
+		if row["operation"] == "download" && row["verified"] != "true" {
+			t.Errorf("Expected verified downloads, got %v", row)
+		}
+	}
+	if counts["upload"] != 6 {
+		t.Errorf("Expected 6 upload rows, got %d", counts["upload"])
+	}
+	if counts["download"] < 4 {
+		t.Errorf("Expected at least the 4 needed download rows, got %d", counts["download"])
+	}
+}
+
+func TestShardTimingCSV_RecordsFailedTransfers(t *testing.T) {
+	fileService, buckets, _ := newMemoryFileService(t, 6)
+	buckets[2].FailWith(errBucketUnavailable)
+	fileService.SetMaxShardFailures(2)
+	var out bytes.Buffer
+	timings, err := fileService.NewShardTimingCSV(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileService.SetShardObserver(timings.Observe)
+
+	data := bytes.Repeat([]byte("partly failing upload "), 256)
+	if err := fileService.UploadFile(context.Background(), "perf/degraded", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	var failed int
+	for _, row := range readPerfCSV(t, out.Bytes()) {
+		if row["error"] == "" {
+			continue
+		}
+		failed++
+		if row["bucket"] != "bucket-2" || row["mb_per_s"] != "" {
+			t.Errorf("Expected the failure in bucket-2 without a throughput, got %v", row)
+		}
+	}
+	if failed == 0 {
+		t.Error("Expected the failed shard upload to be recorded")
+	}
+}
+
+func TestBench_ForwardsTimingsToObserver(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 6)
+	var out bytes.Buffer
+	timings, err := fileService.NewShardTimingCSV(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileService.SetShardObserver(timings.Observe)
+
+	if _, err := fileService.Bench(context.Background(), service.BenchOptions{
+		Size: 16 * 1024, Iterations: 2, DataShards: 4, ParityShards: 2, Prefix: "bench",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var uploads int
+	for _, row := range readPerfCSV(t, out.Bytes()) {
+		if row["operation"] == "upload" {
+			uploads++
+		}
+	}
+	if uploads != 12 {
+		t.Errorf("Expected 12 shard uploads from 2 iterations, got %d", uploads)
+	}
+}
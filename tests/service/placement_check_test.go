@@ -0,0 +1,118 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/placement"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// unreadableReader fails the test if UploadFile reads input it should have rejected upfront
+type unreadableReader struct{ t *testing.T }
+
+func (r unreadableReader) Read(p []byte) (int, error) {
+	r.t.Error("Expected upload to fail before reading its input")
+	return 0, os.ErrClosed
+}
+
+func TestUploadFile_NoBucketsFailsFast(t *testing.T) {
+	metadataRepo := newFlakyMetadataRepository()
+	fileService := service.NewFileService(placement.NewRoundRobinPlacer(), metadataRepo)
+
+	err := fileService.UploadFile(context.Background(), "docs/a.txt", unreadableReader{t}, true, 4, 2, 3)
+	if !stderrors.Is(err, errors.ErrNoBucketsRegistered) {
+		t.Errorf("Expected ErrNoBucketsRegistered, got %v", err)
+	}
+	if len(metadataRepo.records) != 0 {
+		t.Error("Expected no metadata to be written")
+	}
+}
+
+func TestDownloadFile_NoBucketsFailsFast(t *testing.T) {
+	metadataRepo := newFlakyMetadataRepository()
+	fileService := service.NewFileService(placement.NewRoundRobinPlacer(), metadataRepo)
+
+	tempFile, err := os.CreateTemp(t.TempDir(), "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempFile.Close()
+
+	err = fileService.DownloadFile(context.Background(), "docs/a.txt", tempFile, true, false)
+	if !stderrors.Is(err, errors.ErrNoBucketsRegistered) {
+		t.Errorf("Expected ErrNoBucketsRegistered, got %v", err)
+	}
+	if metadataRepo.getCalls != 0 {
+		t.Errorf("Expected no metadata lookups, got %d", metadataRepo.getCalls)
+	}
+}
+
+func TestUploadFile_InvalidShardConfigFailsFast(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 3)
+
+	for _, layout := range [][2]int{{0, 2}, {4, -1}, {200, 100}} {
+		err := fileService.UploadFile(context.Background(), "docs/a.txt", unreadableReader{t}, true, layout[0], layout[1], 3)
+		if !stderrors.Is(err, errors.ErrInvalidShardConfig) {
+			t.Errorf("%d+%d: expected ErrInvalidShardConfig, got %v", layout[0], layout[1], err)
+		}
+	}
+}
+
+func TestUploadFile_FewerBucketsThanShards(t *testing.T) {
+	// Round-robin wraps around, so a single bucket can still hold every shard
+	fileService, buckets, _ := newMemoryFileService(t, 1)
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/a.txt", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatalf("Expected upload to a single bucket to succeed, got %v", err)
+	}
+	if keys, _ := buckets[0].ListKeys(context.Background(), "docs/a.txt/"); len(keys) != 6 {
+		t.Errorf("Expected 6 shards in the only bucket, got %d", len(keys))
+	}
+}
+
+func TestValidateShardConfig_TotalShardBoundary(t *testing.T) {
+	if err := service.ValidateShardConfig(255, 1); err != nil {
+		t.Errorf("Expected 255+1 shards to be accepted, got %v", err)
+	}
+	if err := service.ValidateShardConfig(1, 0); err != nil {
+		t.Errorf("Expected 1+0 shards to be accepted, got %v", err)
+	}
+
+	err := service.ValidateShardConfig(256, 1)
+	if !stderrors.Is(err, errors.ErrInvalidShardConfig) {
+		t.Fatalf("Expected 256+1 shards to be rejected, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "at most 256") {
+		t.Errorf("Expected the error to explain the 256-shard limit, got %q", err)
+	}
+}
+
+func TestUploadFile_MaximumShardCount(t *testing.T) {
+	fileService, _, metadataRepo := newMemoryFileService(t, 3)
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/wide.txt", bytes.NewReader(data), true, 255, 1, 8); err != nil {
+		t.Fatalf("Expected 255+1 shards to upload, got %v", err)
+	}
+	if shards := len(metadataRepo.records["docs/wide.txt"].ShardHashes); shards != 256 {
+		t.Errorf("Expected 256 shards, got %d", shards)
+	}
+
+	err := fileService.UploadFile(context.Background(), "docs/wider.txt", unreadableReader{t}, true, 256, 1, 8)
+	if !stderrors.Is(err, errors.ErrInvalidShardConfig) {
+		t.Errorf("Expected 256+1 shards to be rejected before reading, got %v", err)
+	}
+}
+
+func TestShardFile_RejectsTooManyShards(t *testing.T) {
+	if _, _, err := service.ShardFile([]byte("payload"), 200, 100); !stderrors.Is(err, errors.ErrInvalidShardConfig) {
+		t.Errorf("Expected ErrInvalidShardConfig from ShardFile, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestUploadFile_PlacementBucketsRestrictShards(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	data := bytes.Repeat([]byte("pinned payload "), 64)
+
+	ctx := service.WithPlacementBuckets(context.Background(), []string{"bucket-1", "bucket-3", "bucket-5"})
+	if err := fileService.UploadFile(ctx, "docs/pinned.txt", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata := metadataRepo.records["docs/pinned.txt"]
+	allowed := map[string]bool{"bucket-1": true, "bucket-3": true, "bucket-5": true}
+	for i, shard := range metadata.ShardHashes {
+		if bucketName := shard.Primary().BucketName; !allowed[bucketName] {
+			t.Errorf("Shard %d stored in %s, outside the requested buckets", i, bucketName)
+		}
+	}
+	for _, i := range []int{0, 2, 4} {
+		if buckets[i].Len() != 0 {
+			t.Errorf("Expected nothing stored in %s, got %d objects", buckets[i].GetBucketName(), buckets[i].Len())
+		}
+	}
+
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(context.Background(), "docs/pinned.txt", &dest, true, true); err != nil || !bytes.Equal(dest.data, data) {
+		t.Errorf("Expected the pinned object to download without options, got %v", err)
+	}
+}
+
+func TestUploadFile_PlacementBucketsValidated(t *testing.T) {
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	data := bytes.Repeat([]byte("pinned payload "), 64)
+
+	// Two buckets would hold three shards each, more than the two parity shards
+	ctx := service.WithPlacementBuckets(context.Background(), []string{"bucket-0", "bucket-1"})
+	if err := fileService.UploadFile(ctx, "docs/pinned.txt", bytes.NewReader(data), true, 4, 2, 6); !stderrors.Is(err, errors.ErrPlacementTooFewBuckets) {
+		t.Errorf("Expected ErrPlacementTooFewBuckets, got %v", err)
+	}
+
+	ctx = service.WithPlacementBuckets(context.Background(), []string{"bucket-0", "bucket-1", "unknown"})
+	if err := fileService.UploadFile(ctx, "docs/pinned.txt", bytes.NewReader(data), true, 4, 2, 6); !stderrors.Is(err, errors.ErrBucketNotRegistered) {
+		t.Errorf("Expected ErrBucketNotRegistered, got %v", err)
+	}
+
+	fileService.SetMirrorFactor(4)
+	ctx = service.WithPlacementBuckets(context.Background(), []string{"bucket-0", "bucket-1", "bucket-2"})
+	if err := fileService.UploadFile(ctx, "docs/pinned.txt", bytes.NewReader(data), true, 4, 2, 6); !stderrors.Is(err, errors.ErrMirrorFactor) {
+		t.Errorf("Expected ErrMirrorFactor, got %v", err)
+	}
+
+	if len(metadataRepo.records) != 0 {
+		t.Errorf("Expected rejected uploads to record nothing, got %d records", len(metadataRepo.records))
+	}
+}
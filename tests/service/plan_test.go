@@ -0,0 +1,78 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanPlacement_RoundRobin(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 3)
+
+	plan, err := fileService.PlanPlacement(0, 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var placed []string
+	for _, shard := range plan.Shards {
+		if shard.Parity != (shard.Shard >= 4) {
+			t.Errorf("Shard %d marked parity=%v", shard.Shard, shard.Parity)
+		}
+		placed = append(placed, shard.Buckets...)
+	}
+	expected := []string{"bucket-0", "bucket-1", "bucket-2", "bucket-0", "bucket-1", "bucket-2"}
+	if !reflect.DeepEqual(placed, expected) {
+		t.Errorf("Expected %v, got %v", expected, placed)
+	}
+	if plan.MaxShardsLost != 2 || !plan.SurvivesBucketLoss {
+		t.Errorf("Expected two shards per bucket to be survivable with 2 parity shards, got %+v", plan)
+	}
+
+	// A plan is a dry run
+	for _, bucket := range buckets {
+		if bucket.Len() != 0 {
+			t.Errorf("Expected nothing stored in %s", bucket.GetBucketName())
+		}
+	}
+	if len(metadataRepo.records) != 0 {
+		t.Error("Expected no metadata to be written")
+	}
+}
+
+func TestPlanPlacement_MirrorsAndUnsafeLayouts(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 3)
+
+	plan, err := fileService.PlanPlacement(0, 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.SurvivesBucketLoss {
+		t.Errorf("Expected 5 shards in 3 buckets with 1 parity shard not to survive a bucket loss, got %+v", plan)
+	}
+
+	fileService.SetMirrorFactor(2)
+	plan, err = fileService.PlanPlacement(0, 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, shard := range plan.Shards {
+		if len(shard.Buckets) != 2 || shard.Buckets[0] == shard.Buckets[1] {
+			t.Errorf("Expected two copies in distinct buckets for shard %d, got %v", shard.Shard, shard.Buckets)
+		}
+	}
+	if plan.MaxShardsLost != 0 || !plan.SurvivesBucketLoss {
+		t.Errorf("Expected mirrored shards to survive any single bucket loss, got %+v", plan)
+	}
+}
+
+func TestPlanPlacement_AppliesMinShardSize(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 6)
+	fileService.SetMinShardSize(1024)
+
+	plan, err := fileService.PlanPlacement(2048, 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.DataShards != 2 || len(plan.Shards) != 4 {
+		t.Errorf("Expected 2 data shards for a 2KB object with 1KB minimum shards, got %+v", plan)
+	}
+}
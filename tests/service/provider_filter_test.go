@@ -0,0 +1,75 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestDownloadFile_ExcludedProviderIsNeverRead(t *testing.T) {
+	fileService, buckets, _ := newTwoProviderFileService(t, "s3", "gcs", "s3", "gcs", "s3", "s3")
+	data := []byte(strings.Repeat("filtered providers ", 300))
+	if err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	// The GCS buckets answer, but too slowly to wait for during an incident
+	for _, bucket := range buckets {
+		if bucket.provider == "gcs" {
+			bucket.latency = time.Hour
+		}
+	}
+
+	for name, ctx := range map[string]context.Context{
+		"exclude": service.WithProviderFilter(context.Background(), nil, []string{"gcs"}),
+		"include": service.WithProviderFilter(context.Background(), []string{"s3"}, nil),
+	} {
+		var dest recordingWriterAt
+		if err := fileService.DownloadFile(ctx, "docs/report.txt", &dest, true, true); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(dest.data, data) {
+			t.Errorf("%s: downloaded data does not match", name)
+		}
+		var stream bytes.Buffer
+		if err := fileService.StreamFile(ctx, "docs/report.txt", &stream, true); err != nil || !bytes.Equal(stream.Bytes(), data) {
+			t.Errorf("%s: expected the streamed object to match, got %v", name, err)
+		}
+	}
+	for _, bucket := range buckets {
+		if bucket.provider == "gcs" && bucket.downloads.Load() != 0 {
+			t.Errorf("Expected %s not to be read, got %d downloads", bucket.GetBucketName(), bucket.downloads.Load())
+		}
+	}
+}
+
+func TestDownloadFile_ProviderFilterWithTooFewShards(t *testing.T) {
+	fileService, buckets, _ := newTwoProviderFileService(t, "s3", "gcs", "s3", "gcs", "s3", "s3")
+	data := []byte(strings.Repeat("too few shards ", 300))
+	if err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	// GCS holds two shards and S3 four; two are not enough for a 4+2 object
+	ctx := service.WithProviderFilter(context.Background(), []string{"gcs"}, nil)
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/report.txt", &dest, true, false); !stderrors.Is(err, errors.ErrInsufficientShards) {
+		t.Fatalf("Expected ErrInsufficientShards, got %v", err)
+	}
+	for _, bucket := range buckets {
+		if bucket.downloads.Load() != 0 {
+			t.Errorf("Expected no shard to be read, got %d downloads from %s", bucket.downloads.Load(), bucket.GetBucketName())
+		}
+	}
+
+	ctx = service.WithProviderFilter(context.Background(), []string{"s3"}, []string{"s3"})
+	if err := fileService.DownloadFile(ctx, "docs/report.txt", &dest, true, false); !stderrors.Is(err, errors.ErrInsufficientShards) {
+		t.Errorf("Expected excluding every included provider to fail, got %v", err)
+	}
+}
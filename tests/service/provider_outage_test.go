@@ -0,0 +1,120 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/placement"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// providerObjectRepository is an in-memory bucket of a named provider whose downloads fail fast while it is down
+type providerObjectRepository struct {
+	*objectstoretest.MemoryObjectRepository
+	provider  string
+	down      *atomic.Bool  // Shared by every bucket of the provider
+	downloads atomic.Int32  // Download attempts, failed or not
+	latency   time.Duration // Time every download takes (0 answers at once)
+}
+
+func (r *providerObjectRepository) Download(ctx context.Context, key string, dest io.WriterAt, quiet bool) error {
+	r.downloads.Add(1)
+	if r.down.Load() {
+		return errBucketUnavailable
+	}
+	select {
+	case <-time.After(r.latency):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return r.MemoryObjectRepository.Download(ctx, key, dest, quiet)
+}
+
+func (r *providerObjectRepository) GetStorageType() string { return r.provider }
+
+// newTwoProviderFileService registers buckets of the given providers in order, so shard i is placed in buckets[i]
+func newTwoProviderFileService(t testing.TB, providers ...string) (*service.FileService, []*providerObjectRepository, map[string]*atomic.Bool) {
+	t.Helper()
+	outages := make(map[string]*atomic.Bool)
+	placer := placement.NewRoundRobinPlacer()
+	buckets := make([]*providerObjectRepository, len(providers))
+	for i, provider := range providers {
+		if outages[provider] == nil {
+			outages[provider] = &atomic.Bool{}
+		}
+		buckets[i] = &providerObjectRepository{
+			MemoryObjectRepository: objectstoretest.NewMemoryObjectRepository(fmt.Sprintf("%s-%d", provider, i)),
+			provider:               provider,
+			down:                   outages[provider],
+		}
+		if err := placer.RegisterBucket(buckets[i].GetBucketName(), buckets[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return service.NewFileService(placer, newFlakyMetadataRepository()), buckets, outages
+}
+
+func TestDownloadFile_ReconstructsFromOtherProviderDuringOutage(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		providers   []string
+		concurrency int
+	}{
+		// Every S3 shard is in the first batch of downloads
+		{"s3 shards first", []string{"s3", "s3", "s3", "gcs", "gcs", "gcs"}, 3},
+		// One download at a time walks the shards in order
+		{"sequential", []string{"s3", "s3", "s3", "gcs", "gcs", "gcs"}, 1},
+		{"interleaved", []string{"s3", "gcs", "s3", "gcs", "s3", "gcs"}, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fileService, buckets, outages := newTwoProviderFileService(t, tc.providers...)
+			fileService.SetConcurrency(tc.concurrency)
+			data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+			if err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(data), true, 3, 3, 3); err != nil {
+				t.Fatal(err)
+			}
+
+			outages["s3"].Store(true)
+			var dest recordingWriterAt
+			if err := fileService.DownloadFile(context.Background(), "docs/report.txt", &dest, true, true); err != nil {
+				t.Fatalf("Expected reconstruction from the GCS shards, got %v", err)
+			}
+			if !bytes.Equal(dest.data, data) {
+				t.Error("Reconstructed data does not match the upload")
+			}
+
+			// Every GCS shard is needed; S3 shards are tried at most once (or not at all once
+			// enough GCS shards are in) rather than retried in place of the GCS ones
+			for _, bucket := range buckets {
+				n := bucket.downloads.Load()
+				if bucket.provider == "gcs" && n != 1 {
+					t.Errorf("Expected 1 download from %s, got %d", bucket.GetBucketName(), n)
+				}
+				if n > 1 {
+					t.Errorf("Expected at most 1 download from %s, got %d", bucket.GetBucketName(), n)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadFile_FailsWhenReachableProviderHoldsTooFewShards(t *testing.T) {
+	fileService, _, outages := newTwoProviderFileService(t, "s3", "s3", "s3", "s3", "gcs", "gcs")
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	outages["s3"].Store(true)
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(context.Background(), "docs/report.txt", &dest, true, true); err == nil {
+		t.Error("Expected the download to fail with only 2 of 4 needed shards reachable")
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPrune_DeletesObjectsOlderThanCutoff(t *testing.T) {
+	ctx := context.Background()
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	for _, key := range []string{"logs/old.log", "logs/older.log", "logs/new.log", "logs/undated.log", "logs/2023/deep.log"} {
+		if err := fileService.UploadFile(ctx, key, bytes.NewReader([]byte("prune test payload for "+key)), true, 4, 2, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	age := func(key string, createdAt time.Time) {
+		record := metadataRepo.records[key]
+		record.CreatedAt = createdAt
+		metadataRepo.records[key] = record
+	}
+	age("logs/old.log", now.Add(-40*24*time.Hour))
+	age("logs/older.log", now.Add(-90*24*time.Hour))
+	age("logs/undated.log", time.Time{})
+	age("logs/2023/deep.log", now.Add(-90*24*time.Hour))
+	cutoff := now.Add(-30 * 24 * time.Hour)
+
+	old, undated, err := fileService.PruneCandidates(ctx, "logs", cutoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(old) != 2 || old[0].FileName != "older.log" || old[1].FileName != "old.log" {
+		t.Errorf("Expected older.log and old.log, oldest first, got %+v", old)
+	}
+	if len(undated) != 1 || undated[0].FileName != "undated.log" {
+		t.Errorf("Expected undated.log to be reported as undated, got %+v", undated)
+	}
+
+	result, err := fileService.Prune(ctx, "logs", cutoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.Deleted, []string{"logs/old.log", "logs/older.log"}) || len(result.Failed) != 0 {
+		t.Errorf("Unexpected prune result %+v", result)
+	}
+	if !slices.Equal(result.Undated, []string{"logs/undated.log"}) {
+		t.Errorf("Expected undated.log to be kept and reported, got %v", result.Undated)
+	}
+
+	for _, key := range []string{"logs/old.log", "logs/older.log"} {
+		if _, ok := metadataRepo.records[key]; ok {
+			t.Errorf("Expected the metadata of %s to be deleted", key)
+		}
+		for _, bucket := range buckets {
+			for object := range bucket.Objects() {
+				if len(object) > len(key) && object[:len(key)+1] == key+"/" {
+					t.Errorf("Expected the shards of %s to be deleted, found %s in %s", key, object, bucket.GetBucketName())
+				}
+			}
+		}
+	}
+	for _, key := range []string{"logs/new.log", "logs/undated.log", "logs/2023/deep.log"} {
+		if _, ok := metadataRepo.records[key]; !ok {
+			t.Errorf("Expected %s to be kept", key)
+		}
+	}
+}
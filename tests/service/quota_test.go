@@ -0,0 +1,67 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/placement"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// newQuotaFileService places shards round-robin over memory buckets behind a quota placer
+func newQuotaFileService(t *testing.T, bucketCount int) (*service.FileService, *placement.QuotaPlacer, []*objectstoretest.MemoryObjectRepository) {
+	t.Helper()
+	inner, buckets := objectstoretest.NewRoundRobinPlacer(t, bucketCount)
+	placer := placement.NewQuotaPlacer(inner, time.Hour)
+	return service.NewFileService(placer, newFlakyMetadataRepository()), placer, buckets
+}
+
+func TestUploadFile_RoutesShardsAroundBucketAtQuota(t *testing.T) {
+	fileService, placer, buckets := newQuotaFileService(t, 4)
+	ctx := context.Background()
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+
+	// bucket-0 takes one shard, then it is full
+	placer.SetQuota("bucket-0", placement.Quota{MaxObjects: 1})
+	for i, key := range []string{"docs/a", "docs/b"} {
+		if err := fileService.UploadFile(ctx, key, bytes.NewReader(data), true, 2, 1, 3); err != nil {
+			t.Fatalf("Upload %d failed: %v", i, err)
+		}
+	}
+	if stored := buckets[0].Len(); stored != 1 {
+		t.Errorf("Expected bucket-0 to stop at its quota of 1 shard, got %d", stored)
+	}
+
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/b", &dest, true, true); err != nil {
+		t.Fatalf("DownloadFile failed: %v", err)
+	}
+	if !bytes.Equal(dest.data, data) {
+		t.Error("Downloaded data does not match the upload")
+	}
+}
+
+func TestUploadFile_FailsBeforeStoringWhenEveryBucketIsFull(t *testing.T) {
+	fileService, placer, buckets := newQuotaFileService(t, 3)
+	for _, bucket := range buckets {
+		placer.SetQuota(bucket.GetBucketName(), placement.Quota{MaxBytes: 1})
+		bucket.PutObject("existing", []byte{0})
+	}
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	err := fileService.UploadFile(context.Background(), "docs/x", bytes.NewReader(data), true, 2, 1, 3)
+	if !stderrors.Is(err, errors.ErrBucketsAtQuota) {
+		t.Fatalf("Expected ErrBucketsAtQuota, got %v", err)
+	}
+	for _, bucket := range buckets {
+		if bucket.Len() != 1 {
+			t.Errorf("Bucket %s stored shards of a rejected upload", bucket.GetBucketName())
+		}
+	}
+}
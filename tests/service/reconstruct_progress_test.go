@@ -0,0 +1,87 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDownloadFile_ShowsReconstructionPhases(t *testing.T) {
+	fileService, buckets, _ := newMemoryFileService(t, 6)
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	// Losing the first data shard makes the download run Reed-Solomon reconstruction
+	buckets[0].Clear()
+
+	var progress bytes.Buffer
+	fileService.SetProgressOutput(&progress)
+
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/x", &dest, false, true); err != nil {
+		t.Fatalf("DownloadFile failed: %v", err)
+	}
+	if !bytes.Equal(dest.data, data) {
+		t.Fatal("Downloaded data does not match the upload")
+	}
+
+	for _, phase := range []string{"reading shards", "reconstructing", "joining", "writing"} {
+		if !strings.Contains(progress.String(), phase) {
+			t.Errorf("Progress output has no %q phase: %q", phase, progress.String())
+		}
+	}
+}
+
+func TestDownloadFile_SkipsReconstructPhaseWithAllShards(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 6)
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+
+	var progress bytes.Buffer
+	fileService.SetProgressOutput(&progress)
+
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/x", &dest, false, true); err != nil {
+		t.Fatalf("DownloadFile failed: %v", err)
+	}
+	if strings.Contains(progress.String(), "reconstructing") {
+		t.Errorf("Reconstruction phase shown although no shard was missing: %q", progress.String())
+	}
+	if !strings.Contains(progress.String(), "writing") {
+		t.Errorf("Progress output has no writing phase: %q", progress.String())
+	}
+}
+
+func TestDownloadFile_QuietHidesReconstructionProgress(t *testing.T) {
+	fileService, buckets, _ := newMemoryFileService(t, 6)
+	ctx := context.Background()
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	buckets[0].Clear()
+
+	var progress bytes.Buffer
+	fileService.SetProgressOutput(&progress)
+	fileService.SetWriteBufferSize(1024)
+
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/x", &dest, true, true); err != nil {
+		t.Fatalf("DownloadFile failed: %v", err)
+	}
+	if !bytes.Equal(dest.data, data) {
+		t.Fatal("Downloaded data does not match the upload")
+	}
+	if progress.Len() != 0 {
+		t.Errorf("Quiet download wrote progress: %q", progress.String())
+	}
+}
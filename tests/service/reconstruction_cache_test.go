@@ -0,0 +1,180 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// newCachedFileService builds a FileService over four counting buckets with a reconstruction cache of maxBytes
+func newCachedFileService(t *testing.T, maxBytes int64, ttl time.Duration) (*service.FileService, []*providerObjectRepository, *service.ReconstructionCache, string) {
+	t.Helper()
+	fileService, buckets, _ := newTwoProviderFileService(t, "s3", "s3", "s3", "s3")
+	dir := t.TempDir()
+	cache, err := service.NewReconstructionCache(dir, maxBytes, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileService.SetReconstructionCache(cache)
+	return fileService, buckets, cache, dir
+}
+
+// downloadCounting downloads key and returns its bytes and the shard downloads it took
+func downloadCounting(t *testing.T, fileService *service.FileService, buckets []*providerObjectRepository, key string) ([]byte, int) {
+	t.Helper()
+	for _, bucket := range buckets {
+		bucket.downloads.Store(0)
+	}
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(context.Background(), key, &dest, true, true); err != nil {
+		t.Fatal(err)
+	}
+	return dest.data, totalDownloads(buckets)
+}
+
+func TestReconstructionCache_MissThenHit(t *testing.T) {
+	fileService, buckets, cache, _ := newCachedFileService(t, 1<<20, time.Hour)
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(data), true, 2, 2, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	got, shards := downloadCounting(t, fileService, buckets, "docs/report.txt")
+	if !bytes.Equal(got, data) || shards == 0 {
+		t.Fatalf("Expected a miss to reconstruct from shards, got %d shard downloads", shards)
+	}
+	if cache.Len() != 1 || cache.Size() != int64(len(data)) {
+		t.Errorf("Expected the miss to cache the object, got %d entries of %d bytes", cache.Len(), cache.Size())
+	}
+
+	got, shards = downloadCounting(t, fileService, buckets, "docs/report.txt")
+	if !bytes.Equal(got, data) {
+		t.Error("Cached download does not match the upload")
+	}
+	if shards != 0 {
+		t.Errorf("Expected a hit to download no shards, got %d", shards)
+	}
+
+	// Streams and ranges are served from the cache too
+	var streamed bytes.Buffer
+	if err := fileService.StreamFile(context.Background(), "docs/report.txt", &streamed, false); err != nil || !bytes.Equal(streamed.Bytes(), data) {
+		t.Errorf("Expected the cached object to be streamed, got %v", err)
+	}
+	var part bytes.Buffer
+	if err := fileService.DownloadRange(context.Background(), "docs/report.txt", 3990, 20, &part, true); err != nil || part.String() != string(data[3990:4010]) {
+		t.Errorf("Expected a range of the cached object, got %q (%v)", part.String(), err)
+	}
+	if shards := totalDownloads(buckets); shards != 0 {
+		t.Errorf("Expected cached streams and ranges to download no shards, got %d", shards)
+	}
+}
+
+func TestReconstructionCache_InvalidatedOnUploadAndDelete(t *testing.T) {
+	fileService, buckets, cache, _ := newCachedFileService(t, 1<<20, time.Hour)
+	first := []byte(strings.Repeat("0123456789", 400) + "first")
+	if err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(first), true, 2, 2, 4); err != nil {
+		t.Fatal(err)
+	}
+	downloadCounting(t, fileService, buckets, "docs/report.txt")
+
+	second := []byte(strings.Repeat("abcdefghij", 400) + "second")
+	if err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(second), true, 2, 2, 4); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected the upload to invalidate the cached copy, %d entries left", cache.Len())
+	}
+	if got, shards := downloadCounting(t, fileService, buckets, "docs/report.txt"); !bytes.Equal(got, second) || shards == 0 {
+		t.Errorf("Expected the new content from the shards, got %d shard downloads", shards)
+	}
+
+	if err := fileService.DeleteFile(context.Background(), "docs/report.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 0 || cache.Size() != 0 {
+		t.Errorf("Expected the delete to invalidate the cached copy, got %d entries of %d bytes", cache.Len(), cache.Size())
+	}
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(context.Background(), "docs/report.txt", &dest, true, true); err == nil {
+		t.Error("Expected a deleted object not to be served from the cache")
+	}
+}
+
+func TestReconstructionCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	// Room for two objects
+	fileService, buckets, cache, _ := newCachedFileService(t, int64(2*len(data)+10), time.Hour)
+	for _, key := range []string{"docs/a", "docs/b", "docs/c"} {
+		if err := fileService.UploadFile(context.Background(), key, bytes.NewReader(data), true, 2, 2, 4); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	downloadCounting(t, fileService, buckets, "docs/a")
+	downloadCounting(t, fileService, buckets, "docs/b")
+	downloadCounting(t, fileService, buckets, "docs/a") // b is now the least recently used
+	downloadCounting(t, fileService, buckets, "docs/c")
+	if cache.Len() != 2 {
+		t.Fatalf("Expected 2 cached objects, got %d", cache.Len())
+	}
+	if _, shards := downloadCounting(t, fileService, buckets, "docs/a"); shards != 0 {
+		t.Error("Expected docs/a to stay cached")
+	}
+	if _, shards := downloadCounting(t, fileService, buckets, "docs/b"); shards == 0 {
+		t.Error("Expected docs/b to have been evicted")
+	}
+}
+
+func TestReconstructionCache_ExpiredAndCorruptEntriesAreRereads(t *testing.T) {
+	fileService, buckets, _, dir := newCachedFileService(t, 1<<20, 50*time.Millisecond)
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(data), true, 2, 2, 4); err != nil {
+		t.Fatal(err)
+	}
+	downloadCounting(t, fileService, buckets, "docs/report.txt")
+
+	time.Sleep(60 * time.Millisecond)
+	if got, shards := downloadCounting(t, fileService, buckets, "docs/report.txt"); !bytes.Equal(got, data) || shards == 0 {
+		t.Errorf("Expected an expired entry to be read from the shards, got %d shard downloads", shards)
+	}
+
+	// Corrupt the fresh copy on disk
+	files, _ := filepath.Glob(filepath.Join(dir, "*", "*"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one cache file, got %v", files)
+	}
+	corrupt := bytes.ToUpper(data)
+	if err := os.WriteFile(files[0], corrupt, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, shards := downloadCounting(t, fileService, buckets, "docs/report.txt"); !bytes.Equal(got, data) || shards == 0 {
+		t.Errorf("Expected a corrupt entry to be dropped and read from the shards, got %d shard downloads", shards)
+	}
+}
+
+func TestReconstructionCache_ReopenedCacheServesEarlierEntries(t *testing.T) {
+	fileService, buckets, _, dir := newCachedFileService(t, 1<<20, time.Hour)
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(data), true, 2, 2, 4); err != nil {
+		t.Fatal(err)
+	}
+	downloadCounting(t, fileService, buckets, "docs/report.txt")
+
+	reopened, err := service.NewReconstructionCache(dir, 1<<20, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Len() != 1 {
+		t.Fatalf("Expected the reopened cache to index the earlier entry, got %d", reopened.Len())
+	}
+	fileService.SetReconstructionCache(reopened)
+	if got, shards := downloadCounting(t, fileService, buckets, "docs/report.txt"); !bytes.Equal(got, data) || shards != 0 {
+		t.Errorf("Expected the reopened cache to serve the object, got %d shard downloads", shards)
+	}
+}
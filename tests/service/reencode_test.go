@@ -0,0 +1,93 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestReEncode_KeepsObjectAttributes(t *testing.T) {
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte("re-encode me "), 700)
+	if err := fileService.UploadFile(ctx, "docs/report.pdf", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	contentType := "application/pdf"
+	if _, err := fileService.UpdateMetadataFields(ctx, "docs/report.pdf", service.MetadataUpdate{ContentType: &contentType, SetTags: map[string]string{"owner": "finance"}}); err != nil {
+		t.Fatal(err)
+	}
+	record := metadataRepo.records["docs/report.pdf"]
+	record.Namespace = "team-a"
+	metadataRepo.records["docs/report.pdf"] = record
+
+	if err := fileService.ReEncode(ctx, "docs/report.pdf", 3, 1, true); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := fileService.StatFile(ctx, "docs/report.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.RequiredShards() != 3 || metadata.ParityShards != 1 {
+		t.Errorf("Expected a 3+1 layout, got %d+%d", metadata.RequiredShards(), metadata.ParityShards)
+	}
+	if metadata.ContentType != contentType || metadata.UserMetadata["owner"] != "finance" || metadata.Namespace != "team-a" {
+		t.Errorf("Expected the content type, tags and namespace to survive the re-encode, got %q, %v, %q", metadata.ContentType, metadata.UserMetadata, metadata.Namespace)
+	}
+
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/report.pdf", &dest, true, true); err != nil || !bytes.Equal(dest.data, data) {
+		t.Fatalf("Expected the re-encoded object to download, got %v", err)
+	}
+}
+
+// failingUpdateRepository accepts new records but fails every update of an existing one
+type failingUpdateRepository struct {
+	*flakyMetadataRepository
+}
+
+func (f failingUpdateRepository) UpdateMetadata(ctx context.Context, metadata domain.ObjectMetadata) (domain.ObjectMetadata, error) {
+	return domain.ObjectMetadata{}, errThrottled
+}
+
+func TestReEncode_FailedMetadataUpdateLeavesOldObjectReadable(t *testing.T) {
+	placer, buckets := objectstoretest.NewRoundRobinPlacer(t, 6)
+	fileService := service.NewFileService(placer, failingUpdateRepository{newFlakyMetadataRepository()})
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte("keep the original "), 700)
+	if err := fileService.UploadFile(ctx, "docs/report.txt", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	stored := func() int {
+		total := 0
+		for _, bucket := range buckets {
+			total += bucket.Len()
+		}
+		return total
+	}
+	before := stored()
+
+	if err := fileService.ReEncode(ctx, "docs/report.txt", 3, 1, true); err == nil {
+		t.Fatal("Expected the re-encode to fail when its metadata cannot be stored")
+	}
+	if got := stored(); got != before {
+		t.Errorf("Expected the re-encoded shards to be rolled back, have %d objects instead of %d", got, before)
+	}
+	metadata, err := fileService.StatFile(ctx, "docs/report.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.RequiredShards() != 4 || metadata.ParityShards != 2 {
+		t.Errorf("Expected the metadata to keep the old 4+2 layout, got %d+%d", metadata.RequiredShards(), metadata.ParityShards)
+	}
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/report.txt", &dest, true, true); err != nil || !bytes.Equal(dest.data, data) {
+		t.Fatalf("Expected the old object to stay readable, got %v", err)
+	}
+}
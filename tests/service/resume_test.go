@@ -0,0 +1,127 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// takeShards removes the given shards from their buckets and returns a function putting them back
+func takeShards(t *testing.T, buckets []*objectstoretest.MemoryObjectRepository, metadataRepo *flakyMetadataRepository, key string, indices ...int) func() {
+	t.Helper()
+	byName := make(map[string]*objectstoretest.MemoryObjectRepository)
+	for _, bucket := range buckets {
+		byName[bucket.GetBucketName()] = bucket
+	}
+	taken := make(map[int][]byte)
+	for _, i := range indices {
+		location := metadataRepo.records[key].ShardHashes[i].Locations[0]
+		bucket := byName[location.BucketName]
+		taken[i], _ = bucket.Object(location.Key)
+		bucket.Delete(context.Background(), location.Key)
+	}
+	return func() {
+		for i, data := range taken {
+			location := metadataRepo.records[key].ShardHashes[i].Locations[0]
+			byName[location.BucketName].PutObject(location.Key, data)
+		}
+	}
+}
+
+// cachedShardFiles lists the complete shard files in the resume caches under tempDir
+func cachedShardFiles(t *testing.T, tempDir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(tempDir, "zstore_resume", "*", "shard_*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var complete []string
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".part") {
+			complete = append(complete, file)
+		}
+	}
+	return complete
+}
+
+// resumeFixture is a 4+2 object in six in-memory buckets, downloaded with resume enabled
+type resumeFixture struct {
+	fileService  *service.FileService
+	buckets      []*objectstoretest.MemoryObjectRepository
+	metadataRepo *flakyMetadataRepository
+	tempDir      string
+}
+
+func uploadForResume(t *testing.T) (*resumeFixture, []byte) {
+	t.Helper()
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	tempDir := t.TempDir()
+	if err := fileService.SetTempFiles(tempDir, "zstore_"); err != nil {
+		t.Fatal(err)
+	}
+	fileService.SetResumeDownloads(true)
+	fileService.SetConcurrency(6)
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/x", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	return &resumeFixture{fileService, buckets, metadataRepo, tempDir}, data
+}
+
+func TestDownloadFile_ResumeReusesCachedShards(t *testing.T) {
+	ctx := context.Background()
+	f, data := uploadForResume(t)
+
+	// Only shards 0-2 can be read, so the first attempt fails after caching them
+	restore := takeShards(t, f.buckets, f.metadataRepo, "docs/x", 3, 4, 5)
+	if err := f.fileService.DownloadFile(ctx, "docs/x", &recordingWriterAt{}, true, false); !stderrors.Is(err, errors.ErrInsufficientShards) {
+		t.Fatalf("Expected ErrInsufficientShards, got %v", err)
+	}
+	if cached := cachedShardFiles(t, f.tempDir); len(cached) != 3 {
+		t.Fatalf("Expected the 3 downloaded shards to be cached, got %v", cached)
+	}
+
+	// Now shards 0-2 are gone from the buckets; the retry succeeds only by using the cache
+	restore()
+	takeShards(t, f.buckets, f.metadataRepo, "docs/x", 0, 1, 2)
+	out := &recordingWriterAt{}
+	if err := f.fileService.DownloadFile(ctx, "docs/x", out, true, false); err != nil {
+		t.Fatalf("Resumed download failed: %v", err)
+	}
+	if !bytes.Equal(out.data, data) {
+		t.Error("Resumed download does not match the original")
+	}
+	if _, err := os.Stat(filepath.Join(f.tempDir, "zstore_resume")); err == nil {
+		if entries, _ := os.ReadDir(filepath.Join(f.tempDir, "zstore_resume")); len(entries) != 0 {
+			t.Errorf("Expected the cache to be removed after a successful download, found %d entries", len(entries))
+		}
+	}
+}
+
+func TestDownloadFile_ResumeRejectsCorruptCachedShards(t *testing.T) {
+	ctx := context.Background()
+	f, _ := uploadForResume(t)
+
+	restore := takeShards(t, f.buckets, f.metadataRepo, "docs/x", 3, 4, 5)
+	f.fileService.DownloadFile(ctx, "docs/x", &recordingWriterAt{}, true, false)
+	for _, file := range cachedShardFiles(t, f.tempDir) {
+		if err := os.WriteFile(file, []byte("corrupted shard"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	restore()
+	takeShards(t, f.buckets, f.metadataRepo, "docs/x", 0, 1, 2)
+	if err := f.fileService.DownloadFile(ctx, "docs/x", &recordingWriterAt{}, true, false); !stderrors.Is(err, errors.ErrInsufficientShards) {
+		t.Fatalf("Expected corrupt cached shards to be ignored, got %v", err)
+	}
+}
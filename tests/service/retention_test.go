@@ -0,0 +1,113 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/placement"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// lockingObjectRepository is an in-memory bucket that accepts a retention lock
+type lockingObjectRepository struct {
+	*objectstoretest.MemoryObjectRepository
+	retention objectstore.Retention
+}
+
+func (l *lockingObjectRepository) SetRetention(retention objectstore.Retention) {
+	l.retention = retention
+}
+
+func newLockingFileService(t *testing.T, bucketCount int) (*service.FileService, []*lockingObjectRepository, *flakyMetadataRepository) {
+	t.Helper()
+	placer := placement.NewRoundRobinPlacer()
+	buckets := make([]*lockingObjectRepository, bucketCount)
+	for i := range buckets {
+		buckets[i] = &lockingObjectRepository{MemoryObjectRepository: objectstoretest.NewMemoryObjectRepository(fmt.Sprintf("bucket-%d", i))}
+		if err := placer.RegisterBucket(buckets[i].GetBucketName(), buckets[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	metadataRepo := newFlakyMetadataRepository()
+	return service.NewFileService(placer, metadataRepo), buckets, metadataRepo
+}
+
+func TestRetention_LockedObjectCannotBeDeletedOrReplaced(t *testing.T) {
+	ctx := context.Background()
+	fileService, buckets, metadataRepo := newLockingFileService(t, 6)
+	retainUntil := time.Now().Add(time.Hour).UTC()
+	if err := fileService.SetRetention(objectstore.Retention{Mode: objectstore.RetentionGovernance, RetainUntil: retainUntil}); err != nil {
+		t.Fatal(err)
+	}
+	for _, bucket := range buckets {
+		if bucket.retention.RetainUntil != retainUntil {
+			t.Fatalf("Expected retention to be applied to %s", bucket.GetBucketName())
+		}
+	}
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	record := metadataRepo.records["docs/x"]
+	if record.RetentionMode != "GOVERNANCE" || !record.RetainUntil.Equal(retainUntil) {
+		t.Fatalf("Expected the lock to be recorded, got %q until %v", record.RetentionMode, record.RetainUntil)
+	}
+
+	if err := fileService.DeleteFile(ctx, "docs/x"); !stderrors.Is(err, errors.ErrObjectLocked) {
+		t.Errorf("Expected delete to fail with ErrObjectLocked, got %v", err)
+	}
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); !stderrors.Is(err, errors.ErrObjectLocked) {
+		t.Errorf("Expected overwrite to fail with ErrObjectLocked, got %v", err)
+	}
+	if err := fileService.ReEncode(ctx, "docs/x", 3, 1, true); !stderrors.Is(err, errors.ErrObjectLocked) {
+		t.Errorf("Expected re-encode to fail with ErrObjectLocked, got %v", err)
+	}
+
+	stored := 0
+	for _, bucket := range buckets {
+		stored += bucket.Len()
+	}
+	if _, ok := metadataRepo.records["docs/x"]; !ok || stored != 6 {
+		t.Errorf("Expected metadata and all 6 shards to survive, found %d shards", stored)
+	}
+}
+
+func TestRetention_ExpiredLockAllowsDelete(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, metadataRepo := newLockingFileService(t, 6)
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a lock whose retention period has ended
+	record := metadataRepo.records["docs/x"]
+	record.RetentionMode, record.RetainUntil = "COMPLIANCE", time.Now().Add(-time.Minute)
+	metadataRepo.records["docs/x"] = record
+
+	if err := fileService.DeleteFile(ctx, "docs/x"); err != nil {
+		t.Fatalf("Expected delete after the retention period to succeed, got %v", err)
+	}
+}
+
+func TestSetRetention_RejectsPastDatesAndUnsupportedBuckets(t *testing.T) {
+	fileService, _, _ := newLockingFileService(t, 3)
+	if err := fileService.SetRetention(objectstore.Retention{Mode: objectstore.RetentionCompliance, RetainUntil: time.Now().Add(-time.Hour)}); err == nil {
+		t.Error("Expected a retain-until date in the past to be rejected")
+	}
+
+	plain, _, _ := newMemoryFileService(t, 3)
+	err := plain.SetRetention(objectstore.Retention{Mode: objectstore.RetentionCompliance, RetainUntil: time.Now().Add(time.Hour)})
+	if err == nil || !strings.Contains(err.Error(), "bucket-0") {
+		t.Errorf("Expected buckets without lock support to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"maps"
+	"reflect"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestUpdateMetadataFields_LeavesShardsUntouched(t *testing.T) {
+	ctx := context.Background()
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	data := bytes.Repeat([]byte("set-meta payload "), 128)
+	if err := fileService.UploadFile(ctx, "reports/q3", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	before := metadataRepo.records["reports/q3"]
+	stored := make([]map[string][]byte, len(buckets))
+	for i, bucket := range buckets {
+		stored[i] = bucket.Objects()
+	}
+
+	contentType := "application/pdf"
+	updated, err := fileService.UpdateMetadataFields(ctx, "reports/q3", service.MetadataUpdate{
+		ContentType: &contentType,
+		SetTags:     map[string]string{"owner": "finance", "reviewed": "yes"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.ContentType != contentType || !maps.Equal(updated.UserMetadata, map[string]string{"owner": "finance", "reviewed": "yes"}) {
+		t.Errorf("Unexpected updated metadata %+v", updated)
+	}
+	after := metadataRepo.records["reports/q3"]
+	if after.ContentType != contentType || after.UserMetadata["owner"] != "finance" {
+		t.Errorf("Expected the update to be stored, got %+v", after)
+	}
+	if !reflect.DeepEqual(after.ShardHashes, before.ShardHashes) || after.OriginalSize != before.OriginalSize || after.FileHash != before.FileHash || after.ETag() != before.ETag() {
+		t.Error("Expected the shard layout, hashes and ETag to be unchanged")
+	}
+	for i, bucket := range buckets {
+		if !maps.EqualFunc(bucket.Objects(), stored[i], bytes.Equal) {
+			t.Errorf("Expected the shards in %s to be untouched", bucket.GetBucketName())
+		}
+	}
+
+	// Removals apply before additions, and clearing the content type restores derivation
+	empty := ""
+	updated, err = fileService.UpdateMetadataFields(ctx, "reports/q3", service.MetadataUpdate{
+		ContentType: &empty,
+		RemoveTags:  []string{"reviewed", "owner"},
+		SetTags:     map[string]string{"owner": "legal"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.ContentType != "" || !maps.Equal(updated.UserMetadata, map[string]string{"owner": "legal"}) {
+		t.Errorf("Unexpected metadata after the second update %+v", updated)
+	}
+
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "reports/q3", &dest, true, true); err != nil || !bytes.Equal(dest.data, data) {
+		t.Errorf("Expected the object to download unchanged, got %v", err)
+	}
+}
+
+func TestUpdateMetadataFields_RejectsInvalidUpdates(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	if err := fileService.UploadFile(ctx, "reports/q3", bytes.NewReader([]byte("set-meta payload")), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	before := metadataRepo.records["reports/q3"]
+
+	badType := "not a type/"
+	for name, update := range map[string]service.MetadataUpdate{
+		"empty":          {},
+		"content type":   {ContentType: &badType},
+		"tag name":       {SetTags: map[string]string{"bad name": "x"}},
+		"tag value":      {SetTags: map[string]string{"owner": "a\r\nInjected: yes"}},
+		"non-token name": {SetTags: map[string]string{"a/b": "x"}},
+	} {
+		if _, err := fileService.UpdateMetadataFields(ctx, "reports/q3", update); !stderrors.Is(err, errors.ErrInvalidMetadataUpdate) {
+			t.Errorf("%s: expected ErrInvalidMetadataUpdate, got %v", name, err)
+		}
+	}
+	if !reflect.DeepEqual(metadataRepo.records["reports/q3"], before) {
+		t.Error("Expected rejected updates to leave the record unchanged")
+	}
+
+	contentType := "text/plain"
+	if _, err := fileService.UpdateMetadataFields(ctx, "reports/missing", service.MetadataUpdate{ContentType: &contentType}); err == nil {
+		t.Error("Expected updating a missing object to fail")
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"os"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+var errBucketUnavailable = stderrors.New("bucket unavailable")
+
+// newMemoryFileService builds a FileService over one in-memory bucket per shard
+// Buckets are registered in order, so shard i is placed in buckets[i].
+func newMemoryFileService(t testing.TB, bucketCount int) (*service.FileService, []*objectstoretest.MemoryObjectRepository, *flakyMetadataRepository) {
+	t.Helper()
+	placer, buckets := objectstoretest.NewRoundRobinPlacer(t, bucketCount)
+	metadataRepo := newFlakyMetadataRepository()
+	return service.NewFileService(placer, metadataRepo), buckets, metadataRepo
+}
+
+func uploadWithFailures(t *testing.T, maxShardFailures int, failingBuckets ...int) (*service.FileService, *flakyMetadataRepository, error) {
+	t.Helper()
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	for _, i := range failingBuckets {
+		buckets[i].FailWith(errBucketUnavailable)
+	}
+	fileService.SetMaxShardFailures(maxShardFailures)
+
+	data := bytes.Repeat([]byte("erasure coded payload "), 64)
+	err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(data), true, 4, 2, 6)
+	return fileService, metadataRepo, err
+}
+
+func TestUploadFile_MaxShardFailuresZeroAbortsOnAnyFailure(t *testing.T) {
+	_, metadataRepo, err := uploadWithFailures(t, 0, 3)
+	if !stderrors.Is(err, errBucketUnavailable) {
+		t.Fatalf("Expected upload to fail on a single shard failure, got %v", err)
+	}
+	if len(metadataRepo.records) != 0 {
+		t.Error("Expected no metadata for an aborted upload")
+	}
+
+	if _, _, err := uploadWithFailures(t, 0); err != nil {
+		t.Errorf("Expected upload without failures to succeed, got %v", err)
+	}
+}
+
+func TestUploadFile_DefaultToleranceIsParity(t *testing.T) {
+	fileService, metadataRepo, err := uploadWithFailures(t, -1, 1, 4)
+	if err != nil {
+		t.Fatalf("Expected upload within parity tolerance to succeed, got %v", err)
+	}
+
+	metadata := metadataRepo.records["docs/report.txt"]
+	for _, i := range []int{1, 4} {
+		if len(metadata.ShardHashes[i].Locations) != 0 {
+			t.Errorf("Expected failed shard %d to have no storage location, got %v", i, metadata.ShardHashes[i].Locations)
+		}
+	}
+
+	// The degraded object must still be readable
+	tempFile, err := os.CreateTemp(t.TempDir(), "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempFile.Close()
+	if err := fileService.DownloadFile(context.Background(), "docs/report.txt", tempFile, true, true); err != nil {
+		t.Errorf("Expected degraded object to download, got %v", err)
+	}
+
+	if _, _, err := uploadWithFailures(t, -1, 0, 2, 5); !stderrors.Is(err, errBucketUnavailable) {
+		t.Errorf("Expected failure beyond parity to abort, got %v", err)
+	}
+}
+
+func TestUploadFile_MaxShardFailuresBoundaries(t *testing.T) {
+	if _, _, err := uploadWithFailures(t, 1, 2); err != nil {
+		t.Errorf("Expected failures equal to the limit to succeed, got %v", err)
+	}
+	if _, _, err := uploadWithFailures(t, 1, 2, 3); !stderrors.Is(err, errBucketUnavailable) {
+		t.Errorf("Expected failures above the limit to abort, got %v", err)
+	}
+	if _, _, err := uploadWithFailures(t, 2, 0, 1); err != nil {
+		t.Errorf("Expected limit equal to parity to be accepted, got %v", err)
+	}
+	if _, _, err := uploadWithFailures(t, 3); !stderrors.Is(err, errors.ErrShardFailureTolerance) {
+		t.Errorf("Expected ErrShardFailureTolerance for a limit above parity, got %v", err)
+	}
+}
+
+func TestUploadFile_RecordedHealthCountsStoredShards(t *testing.T) {
+	_, metadataRepo, err := uploadWithFailures(t, -1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	health := service.RecordedHealth(metadataRepo.records["docs/report.txt"])
+	if health.TotalShards != 6 || health.AvailableShards != 5 || health.MinShardsNeeded != 4 {
+		t.Errorf("Expected 5 of 6 shards stored with 4 needed, got %+v", health)
+	}
+	if health.String() != "DEGRADED(1 missing)" {
+		t.Errorf("Expected a degraded upload, got %s", health)
+	}
+
+	_, metadataRepo, err = uploadWithFailures(t, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health := service.RecordedHealth(metadataRepo.records["docs/report.txt"]); health.String() != "OK" || health.AvailableShards != 6 {
+		t.Errorf("Expected a fully stored upload, got %+v", health)
+	}
+}
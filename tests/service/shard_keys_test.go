@@ -0,0 +1,105 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestShardKeys_IdenticalObjectsUnderDifferentKeys(t *testing.T) {
+	for _, scheme := range []string{service.ShardKeyContent, service.ShardKeyIndexed} {
+		t.Run(scheme, func(t *testing.T) {
+			fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+			if err := fileService.SetShardKeyScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			data := bytes.Repeat([]byte("same content, different key "), 256)
+
+			// "docs/a" is a string prefix of "docs/ab", so cleaning up one must not reach the other
+			for _, key := range []string{"docs/ab", "docs/a"} {
+				if err := fileService.UploadFile(ctx, key, bytes.NewReader(data), true, 4, 2, 6); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			first, err := metadataRepo.GetMetadata(ctx, "docs", "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := metadataRepo.GetMetadata(ctx, "docs", "ab")
+			if err != nil {
+				t.Fatal(err)
+			}
+			firstKeys := map[string]bool{}
+			for _, shard := range first.ShardHashes {
+				if !strings.HasPrefix(shard.Primary().Key, "docs/a/") {
+					t.Errorf("Expected shard key under docs/a/, got %s", shard.Primary().Key)
+				}
+				firstKeys[shard.Primary().Key] = true
+			}
+			for i, shard := range second.ShardHashes {
+				if shard.Hash != first.ShardHashes[i].Hash {
+					t.Errorf("Expected identical content to hash alike for shard %d", i)
+				}
+				if firstKeys[shard.Primary().Key] {
+					t.Errorf("Expected shard %d of the two objects to have different keys, both use %s", i, shard.Primary().Key)
+				}
+			}
+			for _, bucket := range buckets {
+				if bucket.Len() != 2 {
+					t.Errorf("Expected a shard of each object in %s, got %d objects", bucket.GetBucketName(), bucket.Len())
+				}
+			}
+
+			if err := fileService.DeleteFile(ctx, "docs/a"); err != nil {
+				t.Fatal(err)
+			}
+			for _, bucket := range buckets {
+				if bucket.Len() != 1 {
+					t.Errorf("Expected only the shard of docs/ab left in %s, got %d objects", bucket.GetBucketName(), bucket.Len())
+				}
+			}
+			var dest recordingWriterAt
+			if err := fileService.DownloadFile(ctx, "docs/ab", &dest, true, true); err != nil || !bytes.Equal(dest.data, data) {
+				t.Fatalf("Expected docs/ab to survive deleting docs/a, got %v", err)
+			}
+		})
+	}
+}
+
+func TestShardKeys_IndexedSchemeKeepsIdenticalShardsApart(t *testing.T) {
+	// An object of zeros encodes to identical data and parity shards; with two buckets,
+	// shards 0 and 2 land in the same one
+	data := make([]byte, 4096)
+	for scheme, want := range map[string]int{service.ShardKeyContent: 1, service.ShardKeyIndexed: 2} {
+		fileService, buckets, _ := newMemoryFileService(t, 2)
+		if err := fileService.SetShardKeyScheme(scheme); err != nil {
+			t.Fatal(err)
+		}
+		if err := fileService.UploadFile(context.Background(), "zeros.bin", bytes.NewReader(data), true, 2, 1, 3); err != nil {
+			t.Fatal(err)
+		}
+		if got := buckets[0].Len(); got != want {
+			t.Errorf("Expected %d stored objects in %s with the %s scheme, got %d", want, buckets[0].GetBucketName(), scheme, got)
+		}
+
+		var dest recordingWriterAt
+		if err := fileService.DownloadFile(context.Background(), "zeros.bin", &dest, true, true); err != nil || !bytes.Equal(dest.data, data) {
+			t.Errorf("Expected the %s scheme to round-trip, got %v", scheme, err)
+		}
+	}
+}
+
+func TestSetShardKeyScheme_RejectsUnknownScheme(t *testing.T) {
+	fileService := service.NewFileService(nil, nil)
+	if err := fileService.SetShardKeyScheme("random"); err == nil {
+		t.Error("Expected an unknown shard key scheme to be rejected")
+	}
+	if err := fileService.SetShardKeyScheme(""); err != nil {
+		t.Errorf("Expected an empty scheme to select the default, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/placement"
+	"github.com/zzenonn/zstore/internal/repository/objectstore"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// failFirstUpload is an in-memory bucket whose first upload fails
+type failFirstUpload struct {
+	*objectstoretest.MemoryObjectRepository
+	mu     sync.Mutex
+	failed bool
+}
+
+func (f *failFirstUpload) Upload(ctx context.Context, key string, r io.Reader, quiet bool) (string, error) {
+	f.mu.Lock()
+	first := !f.failed
+	f.failed = true
+	f.mu.Unlock()
+	if first {
+		return "", errBucketUnavailable
+	}
+	return f.MemoryObjectRepository.Upload(ctx, key, r, quiet)
+}
+
+func TestUploadFile_RetriesFailedShardInItsBucket(t *testing.T) {
+	placer := placement.NewRoundRobinPlacer()
+	buckets := make([]*objectstoretest.MemoryObjectRepository, 6)
+	for i := range buckets {
+		buckets[i] = objectstoretest.NewMemoryObjectRepository(fmt.Sprintf("bucket-%d", i))
+		var repo objectstore.ObjectRepository = buckets[i]
+		if i == 2 {
+			repo = &failFirstUpload{MemoryObjectRepository: buckets[i]}
+		}
+		if err := placer.RegisterBucket(buckets[i].GetBucketName(), repo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	metadataRepo := newFlakyMetadataRepository()
+	fileService := service.NewFileService(placer, metadataRepo)
+
+	data := bytes.Repeat([]byte("retried payload "), 64)
+	if err := fileService.UploadFile(context.Background(), "docs/retried.txt", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	metadata := metadataRepo.records["docs/retried.txt"]
+	if health := service.RecordedHealth(metadata); health.String() != "OK" {
+		t.Errorf("Expected the transient failure to be retried, got %s", health)
+	}
+	if bucketName := metadata.ShardHashes[2].Primary().BucketName; bucketName != "bucket-2" || buckets[2].Len() != 1 {
+		t.Errorf("Expected shard 2 to be stored in its own bucket on retry, got %s", bucketName)
+	}
+}
+
+func TestUploadFile_MovesFailedShardToSpareBucket(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 7)
+	buckets[2].FailWith(errBucketUnavailable)
+
+	data := bytes.Repeat([]byte("moved payload "), 64)
+	if err := fileService.UploadFile(context.Background(), "docs/moved.txt", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	metadata := metadataRepo.records["docs/moved.txt"]
+	if health := service.RecordedHealth(metadata); health.String() != "OK" {
+		t.Errorf("Expected the shard to be stored in the spare bucket, got %s", health)
+	}
+	if bucketName := metadata.ShardHashes[2].Primary().BucketName; bucketName != "bucket-6" {
+		t.Errorf("Expected shard 2 in the spare bucket-6, got %q", bucketName)
+	}
+
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(context.Background(), "docs/moved.txt", &dest, true, true); err != nil || !bytes.Equal(dest.data, data) {
+		t.Errorf("Expected the object to download, got %v", err)
+	}
+}
+
+func TestUploadFile_DegradedUploadIsDetectable(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	buckets[2].FailWith(errBucketUnavailable)
+
+	data := bytes.Repeat([]byte("degraded payload "), 64)
+	if err := fileService.UploadFile(context.Background(), "docs/degraded.txt", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatalf("Expected the upload to succeed within tolerance, got %v", err)
+	}
+	buckets[2].FailWith(nil)
+
+	// With no spare bucket the shard stays unstored, and every check reports it
+	metadata := metadataRepo.records["docs/degraded.txt"]
+	if health := service.RecordedHealth(metadata); health.String() != "DEGRADED(1 missing)" {
+		t.Errorf("Expected the recorded health to be degraded, got %s", health)
+	}
+	if health := fileService.HealthStatus(context.Background(), metadata); health.AvailableShards != 5 {
+		t.Errorf("Expected the health check to find 5 shards, got %d", health.AvailableShards)
+	}
+
+	report, err := fileService.Fsck(context.Background(), "docs", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Kind != service.FsckMissingShard || report.Issues[0].ShardIndex != 2 {
+		t.Fatalf("Expected fsck to report shard 2 as missing, got %+v", report.Issues)
+	}
+
+	// Repair stores the shard, after which the object is fully redundant
+	if _, err := fileService.Fsck(context.Background(), "docs", true); err != nil {
+		t.Fatal(err)
+	}
+	if health := fileService.HealthStatus(context.Background(), metadataRepo.records["docs/degraded.txt"]); health.String() != "OK" {
+		t.Errorf("Expected fsck --repair to restore the shard, got %s", health)
+	}
+}
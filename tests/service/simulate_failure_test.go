@@ -0,0 +1,66 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSimulateBucketLoss_ReportsUnrecoverableObjects(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	for _, key := range []string{"docs/wide", "docs/narrow", "other/x"} {
+		if err := fileService.UploadFile(ctx, key, bytes.NewReader(data), true, 4, 2, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Round-robin puts shard i on bucket-i; docs/narrow loses a third shard to a missing upload
+	narrow := metadataRepo.records["docs/narrow"]
+	narrow.ShardHashes[5].Locations = nil
+	metadataRepo.records["docs/narrow"] = narrow
+
+	simulation, err := fileService.SimulateBucketLoss(ctx, "docs", []string{"bucket-0", "bucket-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if simulation.ObjectsChecked != 2 || simulation.ObjectsAffected != 2 {
+		t.Fatalf("Expected 2 objects checked and affected, got %d and %d", simulation.ObjectsChecked, simulation.ObjectsAffected)
+	}
+	if len(simulation.Unrecoverable) != 1 || simulation.Unrecoverable[0].Key != "docs/narrow" || simulation.Unrecoverable[0].ShardsLost != 3 {
+		t.Fatalf("Expected docs/narrow to be unrecoverable with 3 shards lost, got %+v", simulation.Unrecoverable)
+	}
+	if len(simulation.Degraded) != 1 || simulation.Degraded[0].Key != "docs/wide" || !simulation.Degraded[0].Recoverable {
+		t.Fatalf("Expected docs/wide to stay readable, got %+v", simulation.Degraded)
+	}
+
+	// A bucket holding no shards of the objects affects none of them
+	simulation, err = fileService.SimulateBucketLoss(ctx, "docs", []string{"bucket-9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if simulation.ObjectsAffected != 0 || len(simulation.Degraded) != 0 {
+		t.Errorf("Expected no affected objects, got %+v", simulation)
+	}
+}
+
+func TestSimulateBucketLoss_MirroredShardsSurviveOneCopy(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, _ := newMemoryFileService(t, 6)
+	fileService.SetMirrorFactor(2)
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Three buckets hold copies of more shards than the parity covers, but every shard keeps a copy elsewhere
+	simulation, err := fileService.SimulateBucketLoss(ctx, "docs", []string{"bucket-0", "bucket-2", "bucket-4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(simulation.Unrecoverable) != 0 || len(simulation.Degraded) != 1 || simulation.Degraded[0].ShardsLost != 0 {
+		t.Fatalf("Expected the mirrored object to lose no shard, got %+v", simulation)
+	}
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+)
+
+func TestDownloadFile_RejectsSizeMismatch(t *testing.T) {
+	testCases := []struct {
+		name   string
+		tamper func(*domain.ObjectMetadata)
+	}{
+		{"Original size larger than the shards hold", func(m *domain.ObjectMetadata) { m.OriginalSize = m.ShardSize*4 + 1 }},
+		{"Shard size disagrees with the shards", func(m *domain.ObjectMetadata) { m.ShardSize++ }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			fileService, _, metadataRepo := newMemoryFileService(t, 6)
+			fileService.SetConcurrency(6)
+
+			data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+			if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+				t.Fatal(err)
+			}
+			record := metadataRepo.records["docs/x"]
+			tc.tamper(&record)
+			metadataRepo.records["docs/x"] = record
+
+			dest, err := os.Create(filepath.Join(t.TempDir(), "x"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dest.Close()
+
+			err = fileService.DownloadFile(ctx, "docs/x", dest, true, false)
+			if !stderrors.Is(err, errors.ErrSizeMismatch) {
+				t.Fatalf("Expected a size mismatch error, got %v", err)
+			}
+			if info, _ := dest.Stat(); info.Size() != 0 {
+				t.Errorf("Expected nothing to be written, got %d bytes", info.Size())
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestStats_ReportsStorageOverhead(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+
+	// 4000 bytes in 4+2 shards of 1000 bytes: 6000 stored, 1.5x
+	even := []byte(strings.Repeat("0123456789", 400))
+	if err := fileService.UploadFile(ctx, "docs/even", bytes.NewReader(even), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	// 4001 bytes in 4+2 shards of 1001 bytes (3 bytes of padding): 6006 stored
+	padded := append(bytes.Clone(even), 'x')
+	if err := fileService.UploadFile(ctx, "docs/padded", bytes.NewReader(padded), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := metadataRepo.records["docs/even"].StorageOverhead; got != 1.5 {
+		t.Errorf("Expected recorded overhead 1.5, got %v", got)
+	}
+	paddedOverhead := 6006.0 / 4001.0
+	if got := metadataRepo.records["docs/padded"].StorageOverhead; math.Abs(got-paddedOverhead) > 1e-9 {
+		t.Errorf("Expected recorded overhead %v, got %v", paddedOverhead, got)
+	}
+
+	stats, err := fileService.Stats(ctx, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Objects != 2 || stats.OriginalBytes != 8001 || stats.StoredBytes != 12006 {
+		t.Fatalf("Unexpected totals: %+v", stats)
+	}
+	if math.Abs(stats.AverageOverhead-(1.5+paddedOverhead)/2) > 1e-9 || math.Abs(stats.TotalOverhead-12006.0/8001.0) > 1e-9 {
+		t.Errorf("Unexpected overhead: average %v, total %v", stats.AverageOverhead, stats.TotalOverhead)
+	}
+	if stats.MinOverhead != 1.5 || math.Abs(stats.MaxOverhead-paddedOverhead) > 1e-9 {
+		t.Errorf("Unexpected range: %v to %v", stats.MinOverhead, stats.MaxOverhead)
+	}
+}
+
+func TestStats_ComputesOverheadForOlderRecords(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	fileService.SetMirrorFactor(2)
+	data := []byte(strings.Repeat("0123456789", 400))
+	if err := fileService.UploadFile(ctx, "docs/old", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Records written before the ratio existed have none stored
+	record := metadataRepo.records["docs/old"]
+	record.StorageOverhead = 0
+	metadataRepo.records["docs/old"] = record
+
+	stats, err := fileService.Stats(ctx, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.AverageOverhead != 3 {
+		t.Errorf("Expected mirrored 4+2 overhead of 3x, got %v", stats.AverageOverhead)
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// recordingProgress records the phases and the totals reported for each
+type recordingProgress struct {
+	mu     sync.Mutex
+	phases []string
+	bytes  map[string]int64
+	shards map[string]int
+	totals map[string][2]int64
+	phase  string
+}
+
+func newRecordingProgress() *recordingProgress {
+	return &recordingProgress{bytes: make(map[string]int64), shards: make(map[string]int), totals: make(map[string][2]int64)}
+}
+
+func (r *recordingProgress) StartPhase(phase string, totalBytes int64, totalShards int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.phases = append(r.phases, phase)
+	r.phase = phase
+	r.totals[phase] = [2]int64{totalBytes, int64(totalShards)}
+}
+
+func (r *recordingProgress) Advance(bytes int64, shards int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bytes[r.phase] += bytes
+	r.shards[r.phase] += shards
+}
+
+func TestProgressReporter_UploadAndDownloadPhases(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 6)
+	data := bytes.Repeat([]byte("status payload "), 256)
+
+	upload := newRecordingProgress()
+	ctx := service.WithProgressReporter(context.Background(), upload)
+	if err := fileService.UploadFile(ctx, "docs/status.txt", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{service.PhaseReading, service.PhaseEncoding, service.PhaseUploading, service.PhaseRecording}
+	if !slices.Equal(upload.phases, expected) {
+		t.Errorf("Expected upload phases %v, got %v", expected, upload.phases)
+	}
+	if total := upload.totals[service.PhaseUploading]; upload.shards[service.PhaseUploading] != 6 || upload.bytes[service.PhaseUploading] != total[0] {
+		t.Errorf("Expected 6 shards and %d bytes uploaded, got %d shards and %d bytes", total[0], upload.shards[service.PhaseUploading], upload.bytes[service.PhaseUploading])
+	}
+
+	download := newRecordingProgress()
+	ctx = service.WithProgressReporter(context.Background(), download)
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/status.txt", &dest, true, true); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{service.PhaseDownloading, service.PhaseReconstructing, service.PhaseWriting}
+	if !slices.Equal(download.phases, expected) {
+		t.Errorf("Expected download phases %v, got %v", expected, download.phases)
+	}
+	if download.shards[service.PhaseDownloading] < 4 {
+		t.Errorf("Expected at least 4 shards downloaded, got %d", download.shards[service.PhaseDownloading])
+	}
+	if written := download.bytes[service.PhaseWriting]; written != int64(len(data)) {
+		t.Errorf("Expected %d bytes written, got %d", len(data), written)
+	}
+}
+
+func readStatus(t *testing.T, path string) service.OperationStatus {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status service.OperationStatus
+	if err := json.Unmarshal(raw, &status); err != nil {
+		t.Fatalf("Status file is not valid JSON: %v\n%s", err, raw)
+	}
+	return status
+}
+
+func TestStatusFile_WritesProgressAndOutcome(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "status.json")
+	status, err := service.NewStatusFile(path, "upload", "docs/big.bin", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if initial := readStatus(t, path); initial.Operation != "upload" || initial.Key != "docs/big.bin" || initial.Done {
+		t.Errorf("Unexpected initial status %+v", initial)
+	}
+
+	status.StartPhase(service.PhaseUploading, 1000, 4)
+	if current := readStatus(t, path); current.Phase != service.PhaseUploading || current.BytesTotal != 1000 {
+		t.Errorf("Expected the phase change to be written right away, got %+v", current)
+	}
+	status.Advance(250, 1)
+	time.Sleep(50 * time.Millisecond)
+	current := readStatus(t, path)
+	if current.BytesDone != 250 || current.ShardsDone != 1 || current.ETASeconds == nil {
+		t.Errorf("Expected periodic progress with an ETA, got %+v", current)
+	}
+
+	if err := status.Close(fmt.Errorf("bucket unavailable")); err != nil {
+		t.Fatal(err)
+	}
+	if final := readStatus(t, path); !final.Done || final.Error != "bucket unavailable" || final.ETASeconds != nil {
+		t.Errorf("Expected a final failed status, got %+v", final)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the status file, found %d entries", len(entries))
+	}
+}
+
+func TestStatusFile_UnwritablePathFailsUpFront(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "status.json")
+	if _, err := service.NewStatusFile(path, "download", "docs/x", time.Second); err == nil {
+		t.Error("Expected a status file in a missing directory to fail")
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/placement"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// slowObjectRepository is an in-memory bucket whose downloads take at least delay
+type slowObjectRepository struct {
+	*objectstoretest.MemoryObjectRepository
+	delay time.Duration
+}
+
+func (r *slowObjectRepository) Download(ctx context.Context, key string, dest io.WriterAt, quiet bool) error {
+	select {
+	case <-time.After(r.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return r.MemoryObjectRepository.Download(ctx, key, dest, quiet)
+}
+
+// firstByteWriter records when the first byte was written to it
+type firstByteWriter struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	firstByte time.Time
+}
+
+func (w *firstByteWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.firstByte.IsZero() && len(p) > 0 {
+		w.firstByte = time.Now()
+	}
+	return w.buf.Write(p)
+}
+
+// uploadWithSlowLastDataShard uploads a 4+2 object and slows down the bucket holding data shard 3
+func uploadWithSlowLastDataShard(t *testing.T, delay time.Duration) (*service.FileService, *flakyMetadataRepository, map[string]*slowObjectRepository, []byte) {
+	t.Helper()
+	placer := placement.NewRoundRobinPlacer()
+	buckets := make(map[string]*slowObjectRepository)
+	for i := 0; i < 6; i++ {
+		bucket := &slowObjectRepository{MemoryObjectRepository: objectstoretest.NewMemoryObjectRepository(fmt.Sprintf("bucket-%d", i))}
+		buckets[bucket.GetBucketName()] = bucket
+		if err := placer.RegisterBucket(bucket.GetBucketName(), bucket); err != nil {
+			t.Fatal(err)
+		}
+	}
+	metadataRepo := newFlakyMetadataRepository()
+	fileService := service.NewFileService(placer, metadataRepo)
+	fileService.SetConcurrency(4)
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/x", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	buckets[metadataRepo.records["docs/x"].ShardHashes[3].Locations[0].BucketName].delay = delay
+	return fileService, metadataRepo, buckets, data
+}
+
+func TestStreamFile_FirstByteBeforeSlowShard(t *testing.T) {
+	ctx := context.Background()
+	const delay = 300 * time.Millisecond
+	fileService, _, _, data := uploadWithSlowLastDataShard(t, delay)
+
+	streamed := &firstByteWriter{}
+	start := time.Now()
+	if err := fileService.StreamFile(ctx, "docs/x", streamed, true); err != nil {
+		t.Fatalf("StreamFile failed: %v", err)
+	}
+	streamedTTFB := streamed.firstByte.Sub(start)
+	if !bytes.Equal(streamed.buf.Bytes(), data) {
+		t.Fatal("Streamed content does not match the original")
+	}
+
+	buffered := &firstByteWriter{}
+	start = time.Now()
+	if err := fileService.DownloadRange(ctx, "docs/x", 0, int64(len(data)), buffered, true); err != nil {
+		t.Fatalf("DownloadRange failed: %v", err)
+	}
+	bufferedTTFB := buffered.firstByte.Sub(start)
+
+	t.Logf("Time to first byte: streamed %v, buffered %v", streamedTTFB, bufferedTTFB)
+	if streamedTTFB >= delay {
+		t.Errorf("Expected the first byte before the slow shard arrived, got %v", streamedTTFB)
+	}
+	if bufferedTTFB < delay {
+		t.Errorf("Expected the buffered read to wait for the slow shard, got %v", bufferedTTFB)
+	}
+}
+
+func TestStreamFile_FallsBackWhenDataShardMissing(t *testing.T) {
+	fileService, metadataRepo, buckets, data := uploadWithSlowLastDataShard(t, 0)
+
+	// Lose data shard 2 after shards 0 and 1 could already be streamed
+	location := metadataRepo.records["docs/x"].ShardHashes[2].Locations[0]
+	buckets[location.BucketName].Delete(context.Background(), location.Key)
+
+	var out bytes.Buffer
+	if err := fileService.StreamFile(context.Background(), "docs/x", &out, true); err != nil {
+		t.Fatalf("StreamFile failed: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Errorf("Expected the reconstructed object, got %d bytes", out.Len())
+	}
+}
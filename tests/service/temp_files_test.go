@@ -0,0 +1,176 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestSweepTempFiles_RemovesOnlyStaleZstoreFiles(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+	files := map[string]time.Time{
+		"zstore_shard_0_123.tmp": old,        // Stale zstore file
+		"zstore_shard_5_456.tmp": time.Now(), // Possibly in use by a running download
+		"other_shard_0_789.tmp":  old,        // Another prefix
+		"shard_1_999.tmp":        old,        // Unprefixed name used by older versions
+		"zstore_notes.txt":       old,        // Not a shard temp file
+	}
+	for name, modTime := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := service.SweepTempFiles(dir, "zstore_", 24*time.Hour)
+	if err != nil || removed != 1 {
+		t.Fatalf("Expected one file removed, got %d (%v)", removed, err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var remaining []string
+	for _, entry := range entries {
+		remaining = append(remaining, entry.Name())
+	}
+	sort.Strings(remaining)
+	expected := []string{"other_shard_0_789.tmp", "shard_1_999.tmp", "zstore_notes.txt", "zstore_shard_5_456.tmp"}
+	if strings.Join(remaining, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v to remain, got %v", expected, remaining)
+	}
+}
+
+func TestSetTempFiles_DownloadsUseConfiguredDirectory(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, _ := newMemoryFileService(t, 6)
+	if err := fileService.SetTempFiles(t.TempDir(), "bad/prefix"); err == nil {
+		t.Fatal("Expected a prefix with a path separator to be rejected")
+	}
+
+	tempDir := t.TempDir()
+	if err := fileService.SetTempFiles(tempDir, "ns_"); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Shard temp files are staged in tempDir under the prefix and removed afterwards
+	var mu sync.Mutex
+	var staged []string
+	fileService.SetShardObserver(func(service.ShardTiming) {
+		mu.Lock()
+		defer mu.Unlock()
+		entries, _ := os.ReadDir(tempDir)
+		for _, entry := range entries {
+			staged = append(staged, entry.Name())
+		}
+	})
+	dest, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dest.Close()
+	if err := fileService.DownloadFile(ctx, "docs/x", dest, true, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(staged) == 0 {
+		t.Fatal("Expected shard temp files in the configured directory during the download")
+	}
+	for _, name := range staged {
+		if !strings.HasPrefix(name, "ns_shard_") || !strings.HasSuffix(name, ".tmp") {
+			t.Errorf("Unexpected temp file name %s", name)
+		}
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+		t.Errorf("Expected temp files to be removed after the download, found %d", len(entries))
+	}
+}
+
+func TestSetKeepTempFiles_KeepsShardsOfAFailedDownload(t *testing.T) {
+	ctx := context.Background()
+	fileService, buckets, _ := newMemoryFileService(t, 6)
+	tempDir := t.TempDir()
+	if err := fileService.SetTempFiles(tempDir, ""); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Three of six shards are lost, one more than the parity can cover
+	for _, bucket := range buckets[:3] {
+		bucket.Clear()
+	}
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	fileService.SetKeepTempFiles(true)
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/x", &dest, true, false); err == nil {
+		t.Fatal("Expected the download to fail")
+	}
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected the 3 downloaded shards to be kept, found %d files", len(entries))
+	}
+	for _, entry := range entries {
+		path := filepath.Join(tempDir, entry.Name())
+		logged := false
+		for _, e := range hook.AllEntries() {
+			if e.Level == logrus.WarnLevel && strings.Contains(e.Message, path) {
+				logged = true
+			}
+		}
+		if !logged {
+			t.Errorf("Expected a warning naming kept temp file %s", path)
+		}
+	}
+}
+
+func TestSetKeepTempFiles_KeepsShardsOfASuccessfulDownload(t *testing.T) {
+	ctx := context.Background()
+	fileService, _, _ := newMemoryFileService(t, 6)
+	tempDir := t.TempDir()
+	if err := fileService.SetTempFiles(tempDir, ""); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	fileService.SetKeepTempFiles(true)
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/x", &dest, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dest.data, data) {
+		t.Fatal("Downloaded data does not match the upload")
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) < 4 {
+		t.Errorf("Expected at least the 4 data shards to be kept, found %d files", len(entries))
+	}
+}
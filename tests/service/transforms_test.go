@@ -0,0 +1,146 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	stderrors "errors"
+	"slices"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func newEncryptionKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, service.EncryptionKeySize)
+	rand.Read(key)
+	return key
+}
+
+// compressibleContent is large and repetitive, with a marker that must not appear in encrypted shards
+var compressibleContent = bytes.Repeat([]byte("quarterly ledger entry: PLAINTEXT-MARKER\n"), 2000)
+
+// assertTransformRoundTrip uploads data and checks every download path returns it unchanged
+func assertTransformRoundTrip(t *testing.T, fileService *service.FileService, data []byte, transforms []string) {
+	t.Helper()
+	ctx := context.Background()
+	if err := fileService.UploadFile(ctx, "docs/ledger.txt", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := fileService.StatFile(ctx, "docs/ledger.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(metadata.Transforms, transforms) {
+		t.Fatalf("Expected transforms %v recorded, got %v", transforms, metadata.Transforms)
+	}
+	if metadata.ContentSize() != int64(len(data)) {
+		t.Errorf("Expected a content size of %d, got %d", len(data), metadata.ContentSize())
+	}
+
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/ledger.txt", &dest, true, true); err != nil || !bytes.Equal(dest.data, data) {
+		t.Fatalf("Expected the download to return the original content, got %d bytes, %v", len(dest.data), err)
+	}
+	var streamed bytes.Buffer
+	if err := fileService.StreamFile(ctx, "docs/ledger.txt", &streamed, true); err != nil || !bytes.Equal(streamed.Bytes(), data) {
+		t.Fatalf("Expected the stream to return the original content, got %d bytes, %v", streamed.Len(), err)
+	}
+	var part bytes.Buffer
+	if err := fileService.DownloadRange(ctx, "docs/ledger.txt", 1000, 500, &part, true); err != nil || !bytes.Equal(part.Bytes(), data[1000:1500]) {
+		t.Fatalf("Expected the range to be taken from the original content, got %v", err)
+	}
+}
+
+// storedContains reports whether any stored shard holds marker
+func storedContains(buckets []*objectstoretest.MemoryObjectRepository, metadataRepo *flakyMetadataRepository, marker []byte) bool {
+	for _, shard := range metadataRepo.records["docs/ledger.txt"].ShardHashes {
+		location := shard.Primary()
+		for _, bucket := range buckets {
+			if stored, ok := bucket.Object(location.Key); ok && bucket.GetBucketName() == location.BucketName && bytes.Contains(stored, marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestTransforms_CompressOnlyRoundTrip(t *testing.T) {
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	if err := fileService.SetCompression(service.TransformZstd); err != nil {
+		t.Fatal(err)
+	}
+	assertTransformRoundTrip(t, fileService, compressibleContent, []string{service.TransformZstd})
+
+	if stored := metadataRepo.records["docs/ledger.txt"].OriginalSize; stored >= int64(len(compressibleContent))/10 {
+		t.Errorf("Expected repetitive content to shard at under a tenth of its size, sharded %d of %d bytes", stored, len(compressibleContent))
+	}
+}
+
+func TestTransforms_EncryptOnlyRoundTrip(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	key := newEncryptionKey(t)
+	if err := fileService.SetEncryptionKey(key); err != nil {
+		t.Fatal(err)
+	}
+	assertTransformRoundTrip(t, fileService, compressibleContent, []string{service.TransformAES256GCM})
+
+	if storedContains(buckets, metadataRepo, []byte("PLAINTEXT-MARKER")) {
+		t.Error("Expected no shard to hold plain text")
+	}
+
+	// The key is needed to read the object back, and only the right key works
+	var dest recordingWriterAt
+	fileService.SetEncryptionKey(nil)
+	if err := fileService.DownloadFile(context.Background(), "docs/ledger.txt", &dest, true, true); !stderrors.Is(err, errors.ErrEncryptionKeyRequired) {
+		t.Errorf("Expected a download without the key to fail with ErrEncryptionKeyRequired, got %v", err)
+	}
+	fileService.SetEncryptionKey(newEncryptionKey(t))
+	if err := fileService.DownloadFile(context.Background(), "docs/ledger.txt", &dest, true, true); !stderrors.Is(err, errors.ErrDecryptionFailed) {
+		t.Errorf("Expected a download with another key to fail with ErrDecryptionFailed, got %v", err)
+	}
+}
+
+func TestTransforms_CompressThenEncryptRoundTrip(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	fileService.SetCompression(service.TransformZstd)
+	fileService.SetEncryptionKey(newEncryptionKey(t))
+	assertTransformRoundTrip(t, fileService, compressibleContent, []string{service.TransformZstd, service.TransformAES256GCM})
+
+	// Encrypting first would leave nothing for compression to remove
+	if stored := metadataRepo.records["docs/ledger.txt"].OriginalSize; stored >= int64(len(compressibleContent))/10 {
+		t.Errorf("Expected compression to run before encryption, sharded %d of %d bytes", stored, len(compressibleContent))
+	}
+	if storedContains(buckets, metadataRepo, []byte("PLAINTEXT-MARKER")) {
+		t.Error("Expected no shard to hold plain text")
+	}
+}
+
+func TestTransforms_RejectEncryptBeforeCompress(t *testing.T) {
+	if err := service.ValidateTransforms([]string{service.TransformAES256GCM, service.TransformZstd}); !stderrors.Is(err, errors.ErrInvalidTransforms) {
+		t.Errorf("Expected encrypt-then-compress to be rejected, got %v", err)
+	}
+	if err := service.ValidateTransforms([]string{service.TransformZstd, service.TransformZstd}); !stderrors.Is(err, errors.ErrInvalidTransforms) {
+		t.Errorf("Expected a repeated transform to be rejected, got %v", err)
+	}
+	if err := service.ValidateTransforms([]string{service.TransformZstd, service.TransformAES256GCM}); err != nil {
+		t.Errorf("Expected compress-then-encrypt to be accepted, got %v", err)
+	}
+
+	// A record listing the transforms out of order is refused rather than decoded wrongly
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	fileService.SetCompression(service.TransformZstd)
+	fileService.SetEncryptionKey(newEncryptionKey(t))
+	if err := fileService.UploadFile(context.Background(), "docs/ledger.txt", bytes.NewReader(compressibleContent), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	record := metadataRepo.records["docs/ledger.txt"]
+	record.Transforms = []string{service.TransformAES256GCM, service.TransformZstd}
+	metadataRepo.records["docs/ledger.txt"] = record
+	if err := fileService.DownloadFile(context.Background(), "docs/ledger.txt", &recordingWriterAt{}, true, true); !stderrors.Is(err, errors.ErrInvalidTransforms) {
+		t.Errorf("Expected a reversed transform record to fail with ErrInvalidTransforms, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func storedShardCount(buckets []*objectstoretest.MemoryObjectRepository) int {
+	count := 0
+	for _, bucket := range buckets {
+		count += bucket.Len()
+	}
+	return count
+}
+
+func softDeleteService(t *testing.T, retention time.Duration) (*service.FileService, []*objectstoretest.MemoryObjectRepository, *flakyMetadataRepository, []byte) {
+	t.Helper()
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	fileService.SetSoftDelete(true, retention)
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/x", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	return fileService, buckets, metadataRepo, data
+}
+
+func TestSoftDelete_DeleteThenRestore(t *testing.T) {
+	ctx := context.Background()
+	fileService, buckets, _, data := softDeleteService(t, time.Hour)
+	shards := storedShardCount(buckets)
+
+	if err := fileService.DeleteFile(ctx, "docs/x"); err != nil {
+		t.Fatalf("DeleteFile failed: %v", err)
+	}
+
+	// Hidden from reads and listings, but nothing is removed
+	if _, err := fileService.StatFile(ctx, "docs/x"); !stderrors.Is(err, errors.ErrMetadataNotFound) {
+		t.Errorf("Expected a deleted file to be missing from stat, got %v", err)
+	}
+	if err := fileService.DownloadFile(ctx, "docs/x", &recordingWriterAt{}, true, false); !stderrors.Is(err, errors.ErrMetadataNotFound) {
+		t.Errorf("Expected a deleted file not to download, got %v", err)
+	}
+	if files, _ := fileService.ListFiles(ctx, "docs"); len(files) != 0 {
+		t.Errorf("Expected a deleted file to be left out of listings, got %d", len(files))
+	}
+	if trash, _ := fileService.ListTrash(ctx, "docs"); len(trash) != 1 || trash[0].DeletedAt.IsZero() {
+		t.Errorf("Expected the file in the trash with its deletion time, got %+v", trash)
+	}
+	if storedShardCount(buckets) != shards {
+		t.Error("Expected soft delete to keep the shards")
+	}
+
+	if err := fileService.RestoreFile(ctx, "docs/x"); err != nil {
+		t.Fatalf("RestoreFile failed: %v", err)
+	}
+	out := &recordingWriterAt{}
+	if err := fileService.DownloadFile(ctx, "docs/x", out, true, false); err != nil || !bytes.Equal(out.data, data) {
+		t.Errorf("Expected the restored file to download intact, got %v", err)
+	}
+	if err := fileService.RestoreFile(ctx, "docs/x"); !stderrors.Is(err, errors.ErrNotInTrash) {
+		t.Errorf("Expected ErrNotInTrash restoring a live file, got %v", err)
+	}
+}
+
+func TestSoftDelete_DeleteThenPurge(t *testing.T) {
+	ctx := context.Background()
+	fileService, buckets, metadataRepo, _ := softDeleteService(t, time.Hour)
+
+	if err := fileService.PurgeFile(ctx, "docs/x"); !stderrors.Is(err, errors.ErrNotInTrash) {
+		t.Fatalf("Expected purging a live file to fail with ErrNotInTrash, got %v", err)
+	}
+	if err := fileService.DeleteFile(ctx, "docs/x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileService.PurgeFile(ctx, "docs/x"); err != nil {
+		t.Fatalf("PurgeFile failed: %v", err)
+	}
+	if storedShardCount(buckets) != 0 {
+		t.Error("Expected purge to remove every shard")
+	}
+	if _, ok := metadataRepo.records["docs/x"]; ok {
+		t.Error("Expected purge to remove the metadata")
+	}
+	if err := fileService.RestoreFile(ctx, "docs/x"); err == nil {
+		t.Error("Expected a purged file not to be restorable")
+	}
+}
+
+func TestSoftDelete_RetentionWindow(t *testing.T) {
+	ctx := context.Background()
+	fileService, buckets, metadataRepo, _ := softDeleteService(t, time.Hour)
+	if err := fileService.DeleteFile(ctx, "docs/x"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing has expired yet
+	if purged, err := fileService.PurgeExpired(ctx, "docs"); err != nil || len(purged) != 0 {
+		t.Fatalf("Expected nothing to expire within the window, got %v (%v)", purged, err)
+	}
+
+	record := metadataRepo.records["docs/x"]
+	record.DeletedAt = time.Now().Add(-2 * time.Hour)
+	metadataRepo.records["docs/x"] = record
+
+	if err := fileService.RestoreFile(ctx, "docs/x"); !stderrors.Is(err, errors.ErrTrashExpired) {
+		t.Errorf("Expected ErrTrashExpired after the window, got %v", err)
+	}
+	purged, err := fileService.PurgeExpired(ctx, "docs")
+	if err != nil || len(purged) != 1 || purged[0] != "docs/x" {
+		t.Fatalf("Expected docs/x to be purged, got %v (%v)", purged, err)
+	}
+	if storedShardCount(buckets) != 0 {
+		t.Error("Expected expired files' shards to be removed")
+	}
+}
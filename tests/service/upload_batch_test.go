@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+func TestParseBatchJobs_Text(t *testing.T) {
+	input := `# nightly backups
+/var/backups/my db.dump -> zs://backups/db.dump
+
+config.yaml -> zs://backups/config.yaml 6+3
+`
+	jobs, err := service.ParseBatchJobs(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []service.BatchJob{
+		{Source: "/var/backups/my db.dump", Destination: "zs://backups/db.dump"},
+		{Source: "config.yaml", Destination: "zs://backups/config.yaml", DataShards: 6, ParityShards: 3},
+	}
+	if !reflect.DeepEqual(jobs, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, jobs)
+	}
+}
+
+func TestParseBatchJobs_JSON(t *testing.T) {
+	input := ` [{"source": "a.txt", "destination": "zs://a.txt"},
+	  {"source": "b.txt", "destination": "zs://docs/b.txt", "data_shards": 2, "parity_shards": 1}]`
+	jobs, err := service.ParseBatchJobs(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []service.BatchJob{
+		{Source: "a.txt", Destination: "zs://a.txt"},
+		{Source: "b.txt", Destination: "zs://docs/b.txt", DataShards: 2, ParityShards: 1},
+	}
+	if !reflect.DeepEqual(jobs, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, jobs)
+	}
+}
+
+func TestParseBatchJobs_RejectsMalformedJobs(t *testing.T) {
+	for _, input := range []string{
+		"a.txt zs://a.txt",
+		"a.txt -> s3://bucket/a.txt",
+		"a.txt -> zs://a.txt 4",
+		"a.txt -> zs://a.txt 0+2",
+		`[{"source": "a.txt"}]`,
+	} {
+		if _, err := service.ParseBatchJobs(strings.NewReader(input)); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+
+	_, err := service.ParseBatchJobs(strings.NewReader("a.txt -> zs://a.txt\n\nbad line\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Expected the error to name line 3, got %v", err)
+	}
+}
+
+func TestUploadBatch_ContinuesPastFailedJobs(t *testing.T) {
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	dir := t.TempDir()
+	content := strings.Repeat("0123456789", 400) + "distinct tail"
+	for _, name := range []string{"a.txt", "b.txt", "empty.txt"} {
+		data := content
+		if name == "empty.txt" {
+			data = ""
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jobs := []service.BatchJob{
+		{Source: filepath.Join(dir, "a.txt"), Destination: "zs://docs/first.txt"},
+		{Source: filepath.Join(dir, "missing.txt"), Destination: "zs://docs/missing.txt"},
+		{Source: filepath.Join(dir, "empty.txt"), Destination: "zs://docs/empty.txt"},
+		{Source: filepath.Join(dir, "b.txt"), Destination: "zs://docs/second.txt", DataShards: 2, ParityShards: 1},
+	}
+	result := fileService.UploadBatch(context.Background(), jobs, true, 4, 2, 3)
+
+	if result.Failed != 2 {
+		t.Errorf("Expected 2 failed jobs, got %d: %+v", result.Failed, result.Jobs)
+	}
+	for i, failed := range []bool{false, true, true, false} {
+		if (result.Jobs[i].Err != "") != failed {
+			t.Errorf("Job %d: expected failed=%v, got %+v", i, failed, result.Jobs[i])
+		}
+	}
+
+	first, ok := metadataRepo.records["docs/first.txt"]
+	if !ok || first.DataShards != 4 || first.ParityShards != 2 || first.OriginalName != "a.txt" {
+		t.Errorf("Expected docs/first.txt as a.txt with the default 4+2 layout, got %+v", first)
+	}
+	second, ok := metadataRepo.records["docs/second.txt"]
+	if !ok || second.DataShards != 2 || second.ParityShards != 1 {
+		t.Errorf("Expected docs/second.txt with its own 2+1 layout, got %+v", second)
+	}
+	if _, ok := metadataRepo.records["docs/empty.txt"]; ok {
+		t.Error("Expected no metadata for the empty source")
+	}
+}
+
+func TestUploadBatch_ReportsUnplaceableLayout(t *testing.T) {
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	source := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(source, []byte(strings.Repeat("0123456789", 400)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := []service.BatchJob{
+		{Source: source, Destination: "zs://docs/bad.txt", DataShards: 300, ParityShards: 2},
+		{Source: source, Destination: "zs://docs/good.txt"},
+	}
+	result := fileService.UploadBatch(context.Background(), jobs, true, 4, 2, 3)
+
+	if result.Failed != 1 || result.Jobs[0].Err == "" || result.Jobs[1].Err != "" {
+		t.Errorf("Expected only the first job to fail, got %+v", result.Jobs)
+	}
+	if _, ok := metadataRepo.records["docs/good.txt"]; !ok {
+		t.Errorf("Expected good.txt to be uploaded, got %+v", result.Jobs)
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// newDirTree builds a tree with regular files, an empty directory, an empty file, a
+// symlink to a file, a symlink to a directory and a symlink cycle
+func newDirTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	content := strings.Repeat("0123456789", 400)
+	mustWrite := func(rel, data string) {
+		if err := os.WriteFile(filepath.Join(root, rel), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, dir := range []string{"sub", "empty"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustWrite("a.txt", content+"a")
+	mustWrite("sub/b.txt", content+"b")
+	mustWrite("zero.txt", "")
+	for link, target := range map[string]string{"link.txt": "a.txt", "sublink": "sub", "loop": "."} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skipf("Symlinks not supported: %v", err)
+		}
+	}
+	return root
+}
+
+func skippedPaths(result service.DirUploadResult) []string {
+	paths := make([]string, len(result.Skipped))
+	for i, skipped := range result.Skipped {
+		paths[i] = skipped.Path
+	}
+	return paths
+}
+
+func TestUploadDir_SkipsSymlinksAndEmptyEntries(t *testing.T) {
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	root := newDirTree(t)
+
+	result, err := fileService.UploadDir(context.Background(), root, "backup", false, true, 4, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"backup/a.txt", "backup/sub/b.txt"}; !reflect.DeepEqual(result.Uploaded, expected) {
+		t.Errorf("Expected uploads %v, got %v", expected, result.Uploaded)
+	}
+	if expected := []string{"empty", "link.txt", "loop", "sublink", "zero.txt"}; !reflect.DeepEqual(skippedPaths(result), expected) {
+		t.Errorf("Expected skipped %v, got %v", expected, skippedPaths(result))
+	}
+	if record, ok := metadataRepo.records["backup/sub/b.txt"]; !ok || record.OriginalName != "b.txt" {
+		t.Errorf("Expected metadata for backup/sub/b.txt with its original name, got %+v", record)
+	}
+}
+
+func TestUploadDir_FollowSymlinks(t *testing.T) {
+	fileService, _, metadataRepo := newMemoryFileService(t, 6)
+	root := newDirTree(t)
+
+	result, err := fileService.UploadDir(context.Background(), root, "backup", true, true, 4, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sub is walked once, so the link to it is skipped like the cycle back to the root
+	if expected := []string{"backup/a.txt", "backup/link.txt", "backup/sub/b.txt"}; !reflect.DeepEqual(result.Uploaded, expected) {
+		t.Errorf("Expected uploads %v, got %v", expected, result.Uploaded)
+	}
+	if expected := []string{"empty", "loop", "sublink", "zero.txt"}; !reflect.DeepEqual(skippedPaths(result), expected) {
+		t.Errorf("Expected skipped %v, got %v", expected, skippedPaths(result))
+	}
+
+	link := metadataRepo.records["backup/link.txt"]
+	if link.OriginalName != "link.txt" || link.OriginalSize != metadataRepo.records["backup/a.txt"].OriginalSize {
+		t.Errorf("Expected the link to store its target's content under its own name, got %+v", link)
+	}
+}
+
+func TestUploadDir_ShowsOneBarForTheWholeDirectory(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 6)
+	root := newDirTree(t)
+
+	var progress bytes.Buffer
+	fileService.SetProgressOutput(&progress)
+	if _, err := fileService.UploadDir(context.Background(), root, "backup", false, false, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	output := progress.String()
+	for _, expected := range []string{"uploading (0/2 files)", "uploading (2/2 files)", "100%"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Progress output has no %q: %q", expected, output)
+		}
+	}
+}
+
+func TestUploadDir_QuietHidesProgress(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 6)
+	root := newDirTree(t)
+
+	var progress bytes.Buffer
+	fileService.SetProgressOutput(&progress)
+	if _, err := fileService.UploadDir(context.Background(), root, "backup", false, true, 4, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if progress.Len() != 0 {
+		t.Errorf("Quiet directory upload wrote progress: %q", progress.String())
+	}
+}
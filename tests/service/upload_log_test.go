@@ -0,0 +1,236 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/placement"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// crashingMetadataRepository panics on the next metadata write, as if the process died there
+type crashingMetadataRepository struct {
+	*flakyMetadataRepository
+	crash bool
+}
+
+var errSimulatedCrash = stderrors.New("simulated crash")
+
+func (c *crashingMetadataRepository) CreateMetadata(ctx context.Context, metadata domain.ObjectMetadata) (domain.ObjectMetadata, error) {
+	if c.crash {
+		c.crash = false
+		panic(errSimulatedCrash)
+	}
+	return c.flakyMetadataRepository.CreateMetadata(ctx, metadata)
+}
+
+// stallingRepository holds every upload until released, then fails it
+type stallingRepository struct {
+	*objectstoretest.MemoryObjectRepository
+	release chan struct{}
+}
+
+func (r *stallingRepository) Upload(ctx context.Context, key string, reader io.Reader, quiet bool) (string, error) {
+	<-r.release
+	return "", errBucketUnavailable
+}
+
+// newUploadLogService returns a service with an upload log in dir over the given placer and metadata
+func newUploadLogService(t *testing.T, placer placement.Placer, metadataRepo service.MetadataRepository, dir string) *service.FileService {
+	t.Helper()
+	fileService := service.NewFileService(placer, metadataRepo)
+	if err := fileService.SetUploadLogDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	return fileService
+}
+
+// uploadUntilCrash runs an upload that is expected to die with errSimulatedCrash
+func uploadUntilCrash(t *testing.T, fileService *service.FileService, key string, data []byte) {
+	t.Helper()
+	defer func() {
+		if recovered := recover(); recovered != errSimulatedCrash {
+			t.Fatalf("Expected the upload to crash, got %v", recovered)
+		}
+	}()
+	fileService.UploadFile(context.Background(), key, bytes.NewReader(data), true, 4, 2, 6)
+}
+
+func logEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := filepath.Glob(filepath.Join(dir, "*.upload.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestUploadLog_FinishedUploadsLeaveNoEntry(t *testing.T) {
+	dir := t.TempDir()
+	placer, buckets := objectstoretest.NewRoundRobinPlacer(t, 6)
+	fileService := newUploadLogService(t, placer, newFlakyMetadataRepository(), dir)
+
+	data := bytes.Repeat([]byte("logged upload "), 512)
+	if err := fileService.UploadFile(context.Background(), "docs/done.txt", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+	if entries := logEntries(t, dir); len(entries) != 0 {
+		t.Errorf("Expected no log entry after a completed upload, got %v", entries)
+	}
+
+	// An upload that fails and rolls itself back has nothing left to recover either
+	buckets[1].FailWith(errBucketUnavailable)
+	buckets[2].FailWith(errBucketUnavailable)
+	buckets[3].FailWith(errBucketUnavailable)
+	if err := fileService.UploadFile(context.Background(), "docs/failed.txt", bytes.NewReader(data), true, 4, 2, 6); err == nil {
+		t.Fatal("Expected the upload to fail")
+	}
+	if entries := logEntries(t, dir); len(entries) != 0 {
+		t.Errorf("Expected no log entry after a rolled back upload, got %v", entries)
+	}
+}
+
+func TestRecoverUploads_CompletesUploadCrashedBeforeMetadata(t *testing.T) {
+	dir := t.TempDir()
+	placer, _ := objectstoretest.NewRoundRobinPlacer(t, 6)
+	metadataRepo := &crashingMetadataRepository{flakyMetadataRepository: newFlakyMetadataRepository(), crash: true}
+	data := bytes.Repeat([]byte("crash before metadata "), 512)
+	uploadUntilCrash(t, newUploadLogService(t, placer, metadataRepo, dir), "docs/crashed.txt", data)
+	if len(metadataRepo.records) != 0 {
+		t.Fatal("Expected no metadata after the crash")
+	}
+
+	// The restarted process resolves the entry; a dry run changes nothing
+	restarted := newUploadLogService(t, placer, metadataRepo, dir)
+	uploads, err := restarted.RecoverUploads(context.Background(), 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 1 || uploads[0].Action != service.RecoveryCompleted || len(metadataRepo.records) != 0 {
+		t.Fatalf("Expected a dry run to plan completing the upload, got %+v", uploads)
+	}
+
+	uploads, err = restarted.RecoverUploads(context.Background(), 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 1 || uploads[0].Key != "docs/crashed.txt" || uploads[0].Action != service.RecoveryCompleted || uploads[0].Err != "" {
+		t.Fatalf("Expected the upload to be completed, got %+v", uploads)
+	}
+	var dest recordingWriterAt
+	if err := restarted.DownloadFile(context.Background(), "docs/crashed.txt", &dest, true, true); err != nil || !bytes.Equal(dest.data, data) {
+		t.Fatalf("Expected the recovered object to be readable, got %v", err)
+	}
+	if entries := logEntries(t, dir); len(entries) != 0 {
+		t.Errorf("Expected the resolved entry to be removed, got %v", entries)
+	}
+}
+
+func TestRecoverUploads_CleansUpUploadCrashedDuringShards(t *testing.T) {
+	dir := t.TempDir()
+	placer := placement.NewRoundRobinPlacer()
+	var buckets []*objectstoretest.MemoryObjectRepository
+	stalled := &stallingRepository{MemoryObjectRepository: objectstoretest.NewMemoryObjectRepository("bucket-5"), release: make(chan struct{})}
+	for i := 0; i < 5; i++ {
+		bucket := objectstoretest.NewMemoryObjectRepository(fmt.Sprintf("bucket-%d", i))
+		buckets = append(buckets, bucket)
+		if err := placer.RegisterBucket(bucket.GetBucketName(), bucket); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := placer.RegisterBucket("bucket-5", stalled); err != nil {
+		t.Fatal(err)
+	}
+	metadataRepo := newFlakyMetadataRepository()
+
+	// The first process stores five shards and hangs on the sixth, as if it died there
+	crashed := newUploadLogService(t, placer, metadataRepo, dir)
+	crashed.SetMaxShardFailures(0)
+	done := make(chan error, 1)
+	go func() {
+		data := bytes.Repeat([]byte("crash during shard uploads "), 512)
+		done <- crashed.UploadFile(context.Background(), "docs/partial.txt", bytes.NewReader(data), true, 4, 2, 6)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for stored := 0; stored < 5; {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the shard uploads")
+		}
+		time.Sleep(5 * time.Millisecond)
+		stored = 0
+		for _, bucket := range buckets {
+			stored += bucket.Len()
+		}
+	}
+
+	recovering := newUploadLogService(t, placer, metadataRepo, dir)
+	if uploads, err := recovering.RecoverUploads(context.Background(), time.Hour, false); err != nil || len(uploads) != 0 {
+		t.Fatalf("Expected a recent upload to be left alone, got %+v, %v", uploads, err)
+	}
+	uploads, err := recovering.RecoverUploads(context.Background(), 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 1 || uploads[0].Action != service.RecoveryCleaned || uploads[0].Deleted != 5 || uploads[0].Err != "" {
+		t.Fatalf("Expected the five stored shards to be cleaned up, got %+v", uploads)
+	}
+	for _, bucket := range buckets {
+		if bucket.Len() != 0 {
+			t.Errorf("Expected %s to be empty after recovery, got %d objects", bucket.GetBucketName(), bucket.Len())
+		}
+	}
+
+	close(stalled.release)
+	if err := <-done; err == nil {
+		t.Error("Expected the stalled upload to fail once released")
+	}
+}
+
+func TestRecoverUploads_LeavesNewerUploadAlone(t *testing.T) {
+	dir := t.TempDir()
+	placer, _ := objectstoretest.NewRoundRobinPlacer(t, 6)
+	metadataRepo := &crashingMetadataRepository{flakyMetadataRepository: newFlakyMetadataRepository(), crash: true}
+	uploadUntilCrash(t, newUploadLogService(t, placer, metadataRepo, dir), "docs/report.txt", bytes.Repeat([]byte("old "), 1024))
+
+	// The key is uploaded again before anyone recovers the crashed upload
+	restarted := newUploadLogService(t, placer, metadataRepo, dir)
+	newer := bytes.Repeat([]byte("newer content "), 512)
+	if err := restarted.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(newer), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	uploads, err := restarted.RecoverUploads(context.Background(), 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 1 || uploads[0].Action != service.RecoveryCleaned || uploads[0].Err != "" {
+		t.Fatalf("Expected the superseded upload to be cleaned up, got %+v", uploads)
+	}
+	var dest recordingWriterAt
+	if err := restarted.DownloadFile(context.Background(), "docs/report.txt", &dest, true, true); err != nil || !bytes.Equal(dest.data, newer) {
+		t.Fatalf("Expected the newer upload to stay readable, got %v", err)
+	}
+}
+
+func TestSetUploadLogDir_CreatesDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested", "uploads")
+	fileService := service.NewFileService(nil, nil)
+	if err := fileService.SetUploadLogDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Errorf("Expected the upload log directory to be created, got %v", err)
+	}
+	if _, err := service.NewFileService(nil, nil).RecoverUploads(context.Background(), 0, false); err == nil {
+		t.Error("Expected recovery without an upload log to fail")
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/domain"
+	"github.com/zzenonn/zstore/internal/errors"
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+var (
+	errMetadataThrottled   = fmt.Errorf("%w: ProvisionedThroughputExceededException", errors.ErrMetadataThrottled)
+	errMetadataUnavailable = fmt.Errorf("%w: InternalServerError", errors.ErrMetadataUnavailable)
+)
+
+// failingCreatesRepository fails the first failures CreateMetadata calls with err
+// If lands is set, each failing write is stored before the error is returned, as
+// when a response is lost after the store applied the write.
+type failingCreatesRepository struct {
+	*flakyMetadataRepository
+	failures int
+	err      error
+	lands    bool
+	calls    int
+}
+
+func (r *failingCreatesRepository) CreateMetadata(ctx context.Context, metadata domain.ObjectMetadata) (domain.ObjectMetadata, error) {
+	r.calls++
+	if r.calls <= r.failures {
+		if r.lands {
+			r.flakyMetadataRepository.CreateMetadata(ctx, metadata)
+		}
+		return domain.ObjectMetadata{}, r.err
+	}
+	return r.flakyMetadataRepository.CreateMetadata(ctx, metadata)
+}
+
+// newFailingCreatesService returns a file service over six memory buckets whose metadata writes fail
+func newFailingCreatesService(t *testing.T, metadataRepo *failingCreatesRepository) (*service.FileService, []*objectstoretest.MemoryObjectRepository) {
+	t.Helper()
+	placer, buckets := objectstoretest.NewRoundRobinPlacer(t, 6)
+	fileService := service.NewFileService(placer, metadataRepo)
+	fileService.SetMetadataWriteRetries(3, 0)
+	return fileService, buckets
+}
+
+func TestUploadFile_RollsBackShardsWhenMetadataCannotBeStored(t *testing.T) {
+	metadataRepo := &failingCreatesRepository{flakyMetadataRepository: newFlakyMetadataRepository(), failures: 10, err: errMetadataThrottled}
+	fileService, buckets := newFailingCreatesService(t, metadataRepo)
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	err := fileService.UploadFile(context.Background(), "docs/x", bytes.NewReader(data), true, 4, 2, 3)
+	if !stderrors.Is(err, errors.ErrUploadRolledBack) || !stderrors.Is(err, errors.ErrMetadataThrottled) {
+		t.Fatalf("Expected a rolled back upload wrapping the metadata error, got %v", err)
+	}
+	if metadataRepo.calls != 3 {
+		t.Errorf("Expected 3 metadata writes, got %d", metadataRepo.calls)
+	}
+	if count := storedShardCount(buckets); count != 0 {
+		t.Errorf("Expected no orphaned shards, found %d", count)
+	}
+}
+
+func TestUploadFile_DoesNotRetryPermanentMetadataErrors(t *testing.T) {
+	metadataRepo := &failingCreatesRepository{flakyMetadataRepository: newFlakyMetadataRepository(), failures: 10, err: errBucketUnavailable}
+	fileService, buckets := newFailingCreatesService(t, metadataRepo)
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	err := fileService.UploadFile(context.Background(), "docs/x", bytes.NewReader(data), true, 4, 2, 3)
+	if !stderrors.Is(err, errors.ErrUploadRolledBack) {
+		t.Fatalf("Expected a rolled back upload, got %v", err)
+	}
+	if metadataRepo.calls != 1 {
+		t.Errorf("Expected a single metadata write, got %d", metadataRepo.calls)
+	}
+	if count := storedShardCount(buckets); count != 0 {
+		t.Errorf("Expected no orphaned shards, found %d", count)
+	}
+}
+
+func TestUploadFile_KeepsShardsWhenTheFailedWriteLanded(t *testing.T) {
+	metadataRepo := &failingCreatesRepository{flakyMetadataRepository: newFlakyMetadataRepository(), failures: 10, err: errBucketUnavailable, lands: true}
+	fileService, _ := newFailingCreatesService(t, metadataRepo)
+
+	ctx := context.Background()
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatalf("Expected the upload to succeed once its record is read back, got %v", err)
+	}
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/x", &dest, true, true); err != nil || !bytes.Equal(dest.data, data) {
+		t.Fatalf("Expected the object to be readable, got %v", err)
+	}
+}
+
+func TestUploadFile_KeepsShardsWhenTheRecordCannotBeReadBack(t *testing.T) {
+	metadataRepo := &failingCreatesRepository{flakyMetadataRepository: newFlakyMetadataRepository(), failures: 10, err: errBucketUnavailable}
+	fileService, buckets := newFailingCreatesService(t, metadataRepo)
+	metadataRepo.down = true
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	err := fileService.UploadFile(context.Background(), "docs/x", bytes.NewReader(data), true, 4, 2, 3)
+	if err == nil || stderrors.Is(err, errors.ErrUploadRolledBack) {
+		t.Fatalf("Expected the upload to fail without a rollback, got %v", err)
+	}
+	if count := storedShardCount(buckets); count != 6 {
+		t.Errorf("Expected all 6 shards to be kept, found %d", count)
+	}
+}
+
+func TestUploadFile_MetadataWriteDoesNotStackDecoratorRetries(t *testing.T) {
+	throttled := &throttlingMetadataRepository{flakyMetadataRepository: newFlakyMetadataRepository(), writeThrottles: 10}
+	placer, _ := objectstoretest.NewRoundRobinPlacer(t, 6)
+	fileService := service.NewFileService(placer, service.NewRetryingMetadataRepository(throttled, 3, 0))
+	fileService.SetMetadataWriteRetries(2, 0)
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/x", bytes.NewReader(data), true, 4, 2, 3); !stderrors.Is(err, errors.ErrUploadRolledBack) {
+		t.Fatalf("Expected a rolled back upload, got %v", err)
+	}
+	if throttled.createCalls != 2 {
+		t.Errorf("Expected the upload's 2 metadata writes and no decorator retries, got %d", throttled.createCalls)
+	}
+}
+
+func TestUploadFile_RetriesTheMetadataWrite(t *testing.T) {
+	placer, _ := objectstoretest.NewRoundRobinPlacer(t, 6)
+	metadataRepo := &failingCreatesRepository{flakyMetadataRepository: newFlakyMetadataRepository(), failures: 2, err: errMetadataUnavailable}
+	fileService := service.NewFileService(placer, metadataRepo)
+	fileService.SetMetadataWriteRetries(3, 0)
+
+	ctx := context.Background()
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(ctx, "docs/x", bytes.NewReader(data), true, 4, 2, 3); err != nil {
+		t.Fatalf("Expected the third metadata write to succeed, got %v", err)
+	}
+	if metadataRepo.calls != 3 {
+		t.Errorf("Expected 3 metadata writes, got %d", metadataRepo.calls)
+	}
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(ctx, "docs/x", &dest, true, true); err != nil || !bytes.Equal(dest.data, data) {
+		t.Fatalf("Expected the object to be readable, got %v", err)
+	}
+}
+
+func TestUploadFile_RollsBackShardsWhenTooManyUploadsFail(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 6)
+	for _, bucket := range buckets[:3] {
+		bucket.FailWith(errBucketUnavailable)
+	}
+
+	data := []byte(strings.Repeat("0123456789", 400) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/x", bytes.NewReader(data), true, 4, 2, 3); err == nil {
+		t.Fatal("Expected the upload to fail")
+	}
+	if count := storedShardCount(buckets); count != 0 {
+		t.Errorf("Expected the 3 stored shards to be removed, found %d", count)
+	}
+	if _, ok := metadataRepo.records["docs/x"]; ok {
+		t.Error("Expected no metadata for the failed upload")
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// verifiedShardCounter counts downloaded shards and how many of them were hash-checked
+type verifiedShardCounter struct {
+	mu       sync.Mutex
+	shards   int
+	verified int
+}
+
+func (c *verifiedShardCounter) observe(timing service.ShardTiming) {
+	if timing.Operation != service.ShardDownload || timing.Err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shards++
+	if timing.Verified {
+		c.verified++
+	}
+}
+
+func TestVerifySampleRate_ChecksSampledProportion(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 3)
+	data := bytes.Repeat([]byte("sampled payload "), 64)
+	if err := fileService.UploadFile(context.Background(), "docs/sampled.txt", bytes.NewReader(data), true, 2, 1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileService.SetVerifySampleRate(0.25); err != nil {
+		t.Fatal(err)
+	}
+	var counter verifiedShardCounter
+	fileService.SetShardObserver(counter.observe)
+
+	for range 400 {
+		var dest recordingWriterAt
+		if err := fileService.DownloadFile(context.Background(), "docs/sampled.txt", &dest, true, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// With at least 800 shards, 0.25 +- 0.08 is more than five standard deviations wide
+	proportion := float64(counter.verified) / float64(counter.shards)
+	if counter.shards < 800 || math.Abs(proportion-0.25) > 0.08 {
+		t.Errorf("Expected about a quarter of %d shards to be checked, got %d (%.3f)", counter.shards, counter.verified, proportion)
+	}
+}
+
+func TestVerifySampleRate_Bounds(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 3)
+	data := bytes.Repeat([]byte("sampled payload "), 64)
+	if err := fileService.UploadFile(context.Background(), "docs/sampled.txt", bytes.NewReader(data), true, 2, 1, 3); err != nil {
+		t.Fatal(err)
+	}
+	var counter verifiedShardCounter
+	fileService.SetShardObserver(counter.observe)
+
+	download := func(verifyIntegrity bool) {
+		t.Helper()
+		counter = verifiedShardCounter{}
+		for range 20 {
+			var dest recordingWriterAt
+			if err := fileService.DownloadFile(context.Background(), "docs/sampled.txt", &dest, true, verifyIntegrity); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// The default checks nothing unless the read asks for it
+	download(false)
+	if counter.verified != 0 {
+		t.Errorf("Expected no checks at the default rate, got %d of %d", counter.verified, counter.shards)
+	}
+	download(true)
+	if counter.verified != counter.shards {
+		t.Errorf("Expected --verify-integrity to check every shard, got %d of %d", counter.verified, counter.shards)
+	}
+
+	if err := fileService.SetVerifySampleRate(1); err != nil {
+		t.Fatal(err)
+	}
+	download(false)
+	if counter.verified != counter.shards {
+		t.Errorf("Expected a rate of 1 to check every shard, got %d of %d", counter.verified, counter.shards)
+	}
+
+	for _, rate := range []float64{-0.1, 1.5, math.NaN()} {
+		if err := fileService.SetVerifySampleRate(rate); err == nil {
+			t.Errorf("Expected rate %v to be rejected", rate)
+		}
+	}
+}
+
+func TestVerifySampleRate_ReplacesCorruptSampledShard(t *testing.T) {
+	fileService, buckets, metadataRepo := newMemoryFileService(t, 3)
+	data := bytes.Repeat([]byte("sampled payload "), 64)
+	if err := fileService.UploadFile(context.Background(), "docs/sampled.txt", bytes.NewReader(data), true, 2, 1, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a byte of the first data shard, keeping its size
+	primary := metadataRepo.records["docs/sampled.txt"].ShardHashes[0].Primary()
+	for _, bucket := range buckets {
+		if bucket.GetBucketName() == primary.BucketName {
+			stored, _ := bucket.Object(primary.Key)
+			stored[0] ^= 0xff
+			bucket.PutObject(primary.Key, stored)
+		}
+	}
+
+	if err := fileService.SetVerifySampleRate(1); err != nil {
+		t.Fatal(err)
+	}
+	var counter verifiedShardCounter
+	fileService.SetShardObserver(counter.observe)
+	fileService.SetDownloadConcurrency(1)
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(context.Background(), "docs/sampled.txt", &dest, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dest.data, data) {
+		t.Error("Expected the corrupt shard to be replaced by parity")
+	}
+	if counter.shards != 3 {
+		t.Errorf("Expected the parity shard to be fetched in place of the corrupt one, got %d shards", counter.shards)
+	}
+}
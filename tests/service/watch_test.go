@@ -0,0 +1,147 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zzenonn/zstore/internal/repository/objectstore/objectstoretest"
+	"github.com/zzenonn/zstore/internal/service"
+)
+
+// startWatch runs WatchDir over dir in the background and returns its events and a function stopping it
+func startWatch(t *testing.T, fileService *service.FileService, dir string, opts service.WatchOptions) (<-chan service.WatchEvent, func() error) {
+	t.Helper()
+	events := make(chan service.WatchEvent, 64)
+	opts.Prefix, opts.DataShards, opts.ParityShards = "docs", 4, 2
+	opts.OnEvent = func(event service.WatchEvent) { events <- event }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- fileService.WatchDir(ctx, dir, opts) }()
+	stop := func() error {
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(10 * time.Second):
+			t.Fatal("WatchDir did not return after cancellation")
+			return nil
+		}
+	}
+	t.Cleanup(func() { cancel() })
+	return events, stop
+}
+
+// nextWatchEvent waits for the watch's next event
+func nextWatchEvent(t *testing.T, events <-chan service.WatchEvent) service.WatchEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for a watch event")
+		return service.WatchEvent{}
+	}
+}
+
+func expectWatchEvent(t *testing.T, events <-chan service.WatchEvent, path string, action service.WatchAction) {
+	t.Helper()
+	event := nextWatchEvent(t, events)
+	if event.Path != path || event.Action != action {
+		t.Fatalf("Expected %s %s, got %s %s (%v)", action, path, event.Action, event.Path, event.Err)
+	}
+}
+
+func TestWatchDir_UploadsChangesAndSkipsUnchangedFiles(t *testing.T) {
+	placer, _ := objectstoretest.NewRoundRobinPlacer(t, 6)
+	metadata := newFlakyMetadataRepository()
+	fileService := service.NewFileService(placer, metadata)
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "existing.txt"), []byte("already there"), 0o644)
+	os.Mkdir(filepath.Join(dir, "logs"), 0o755)
+
+	events, stop := startWatch(t, fileService, dir, service.WatchOptions{Debounce: 50 * time.Millisecond, Ignore: []string{"*.tmp", "cache"}})
+	expectWatchEvent(t, events, "existing.txt", service.WatchUploaded)
+
+	// Rapid writes are uploaded once, after the last one; ignored files are never uploaded
+	os.WriteFile(filepath.Join(dir, "scratch.tmp"), []byte("ignored"), 0o644)
+	os.MkdirAll(filepath.Join(dir, "cache", "deep"), 0o755)
+	os.WriteFile(filepath.Join(dir, "cache", "deep", "blob"), []byte("ignored"), 0o644)
+	for i := range 5 {
+		os.WriteFile(filepath.Join(dir, "logs", "app.log"), bytes.Repeat([]byte("line\n"), i+1), 0o644)
+	}
+	expectWatchEvent(t, events, "logs/app.log", service.WatchUploaded)
+
+	var dest recordingWriterAt
+	if err := fileService.DownloadFile(context.Background(), "docs/logs/app.log", &dest, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dest.data, bytes.Repeat([]byte("line\n"), 5)) {
+		t.Errorf("Expected the last version to be stored, got %q", dest.data)
+	}
+
+	// Rewriting a file with the same content does not upload it again
+	os.WriteFile(filepath.Join(dir, "existing.txt"), []byte("already there"), 0o644)
+	expectWatchEvent(t, events, "existing.txt", service.WatchUnchanged)
+
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	for key := range metadata.records {
+		if key != "docs/existing.txt" && key != "docs/logs/app.log" {
+			t.Errorf("Unexpected object %s", key)
+		}
+	}
+
+	// A restarted watch finds everything up to date
+	events, stop = startWatch(t, fileService, dir, service.WatchOptions{Debounce: 50 * time.Millisecond, Ignore: []string{"*.tmp", "cache"}})
+	for range 2 {
+		if event := nextWatchEvent(t, events); event.Action != service.WatchUnchanged {
+			t.Errorf("Expected %s to be unchanged after a restart, got %s", event.Path, event.Action)
+		}
+	}
+	stop()
+}
+
+func TestWatchDir_DeletesRemovedFiles(t *testing.T) {
+	placer, _ := objectstoretest.NewRoundRobinPlacer(t, 6)
+	metadata := newFlakyMetadataRepository()
+	fileService := service.NewFileService(placer, metadata)
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("to be removed"), 0o644)
+
+	events, stop := startWatch(t, fileService, dir, service.WatchOptions{Debounce: 50 * time.Millisecond, DeleteRemote: true})
+	expectWatchEvent(t, events, "a.txt", service.WatchUploaded)
+	os.Remove(filepath.Join(dir, "a.txt"))
+	expectWatchEvent(t, events, "a.txt", service.WatchDeleted)
+	stop()
+
+	if _, ok := metadata.records["docs/a.txt"]; ok {
+		t.Error("Expected the object of the removed file to be deleted")
+	}
+}
+
+func TestWatchDir_FlushesPendingChangesOnShutdown(t *testing.T) {
+	placer, _ := objectstoretest.NewRoundRobinPlacer(t, 6)
+	metadata := newFlakyMetadataRepository()
+	fileService := service.NewFileService(placer, metadata)
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "first.txt"), []byte("first"), 0o644)
+
+	// A debounce period far longer than the test: only the shutdown flush can upload the change
+	events, stop := startWatch(t, fileService, dir, service.WatchOptions{Debounce: time.Hour})
+	expectWatchEvent(t, events, "first.txt", service.WatchUploaded)
+	os.WriteFile(filepath.Join(dir, "late.txt"), []byte("written just before shutdown"), 0o644)
+	time.Sleep(200 * time.Millisecond) // Let the notification arrive
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	expectWatchEvent(t, events, "late.txt", service.WatchUploaded)
+	if _, ok := metadata.records["docs/late.txt"]; !ok {
+		t.Error("Expected the pending change to be uploaded on shutdown")
+	}
+}
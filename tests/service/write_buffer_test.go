@@ -0,0 +1,93 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingWriterAt keeps written bytes and the size of every write call
+type recordingWriterAt struct {
+	mu     sync.Mutex
+	data   []byte
+	writes []int
+}
+
+func (w *recordingWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if end := int(off) + len(p); end > len(w.data) {
+		w.data = append(w.data, make([]byte, end-len(w.data))...)
+	}
+	copy(w.data[off:], p)
+	w.writes = append(w.writes, len(p))
+	return len(p), nil
+}
+
+func TestDownloadFile_WriteBufferSizeChunksWrites(t *testing.T) {
+	fileService, _, _ := newMemoryFileService(t, 6)
+	data := []byte(strings.Repeat("0123456789", 1000) + "distinct tail")
+	if err := fileService.UploadFile(context.Background(), "docs/report.txt", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	unbuffered := &recordingWriterAt{}
+	if err := fileService.DownloadFile(context.Background(), "docs/report.txt", unbuffered, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(unbuffered.writes) != 1 {
+		t.Errorf("Expected a single write without a buffer, got %d", len(unbuffered.writes))
+	}
+
+	fileService.SetWriteBufferSize(1024)
+	buffered := &recordingWriterAt{}
+	if err := fileService.DownloadFile(context.Background(), "docs/report.txt", buffered, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buffered.data, data) {
+		t.Fatal("Expected buffered download to match the upload")
+	}
+	if len(buffered.writes) != (len(data)+1023)/1024 {
+		t.Errorf("Expected %d writes, got %d", (len(data)+1023)/1024, len(buffered.writes))
+	}
+	for i, size := range buffered.writes {
+		if size > 1024 {
+			t.Errorf("Write %d was %d bytes, larger than the 1024-byte buffer", i, size)
+		}
+	}
+}
+
+// BenchmarkDownloadFile_WriteBufferSize compares output buffer sizes when writing to a file
+// Point TMPDIR at a slow destination (e.g. an NFS mount) to measure the effect there.
+func BenchmarkDownloadFile_WriteBufferSize(b *testing.B) {
+	fileService, _, _ := newMemoryFileService(b, 6)
+	data := bytes.Repeat([]byte("0123456789abcdef"), 4<<20/16)
+	data = append(data, "distinct tail"...)
+	if err := fileService.UploadFile(context.Background(), "bench/object", bytes.NewReader(data), true, 4, 2, 6); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, size := range []int{0, 64 << 10, 1 << 20} {
+		b.Run(fmt.Sprintf("buffer=%d", size), func(b *testing.B) {
+			fileService.SetWriteBufferSize(size)
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				output, err := os.CreateTemp("", "zstore-write-buffer")
+				if err != nil {
+					b.Fatal(err)
+				}
+				err = fileService.DownloadFile(context.Background(), "bench/object", output, true, false)
+				output.Sync()
+				output.Close()
+				os.Remove(output.Name())
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}